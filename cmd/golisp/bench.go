@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runBench implements `golisp bench`, running every *_bench.lisp file found
+// under the given files or directories
+func runBench(args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s bench [file-or-dir ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nRuns *_bench.lisp files (default: the current directory).\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		found, err := findLispFiles(path, "_bench.lisp")
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *_bench.lisp files found")
+	}
+
+	for _, file := range files {
+		fmt.Printf("== %s\n", file)
+
+		// Each file gets a fresh environment so benchmarks don't interfere
+		repl, err := core.NewREPL()
+		if err != nil {
+			return fmt.Errorf("creating REPL: %v", err)
+		}
+		defer repl.GetEnv().RunExitHooks()
+		if err := useProject(repl); err != nil {
+			return err
+		}
+		if _, err := repl.EvalFile(file); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+	}
+	return nil
+}
+
+// findLispFiles returns path itself if it is a file, or the files beneath it
+// ending in suffix if it is a directory
+func findLispFiles(path, suffix string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	var files []string
+	err = filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(file, suffix) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runDeps implements `golisp deps fetch` and `golisp deps path`
+func runDeps(args []string) error {
+	if len(args) != 1 || (args[0] != "fetch" && args[0] != "path") {
+		fmt.Fprintf(os.Stderr, "Usage: %s deps fetch|path\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\n  fetch   Download the dependencies listed in %s\n", core.ProjectFile)
+		fmt.Fprintf(os.Stderr, "  path    Print the namespace search path\n")
+		return fmt.Errorf("expected deps fetch or deps path")
+	}
+
+	project, err := core.FindProject(".")
+	if err != nil {
+		return err
+	}
+	if project == nil {
+		return fmt.Errorf("no %s found in the current directory or its parents", core.ProjectFile)
+	}
+
+	if args[0] == "path" {
+		for _, dir := range project.LoadPath() {
+			fmt.Println(dir)
+		}
+		return nil
+	}
+
+	if err := project.FetchDeps(os.Stdout); err != nil {
+		return err
+	}
+	fmt.Printf("Fetched %d dependencies into %s\n", len(project.Deps), project.CacheDir())
+	return nil
+}
+
+// runInstall implements `golisp install github.com/user/lib[@version]`
+func runInstall(args []string) error {
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "Usage: %s install <repo>[@version] ...\n", os.Args[0])
+		return fmt.Errorf("expected at least one repository")
+	}
+
+	project, err := core.FindProject(".")
+	if err != nil {
+		return err
+	}
+
+	for _, spec := range args {
+		dep, err := core.InstallLibrary(spec, os.Stdout)
+		if err != nil {
+			return err
+		}
+		if project != nil {
+			project.AddDependency(dep)
+			fmt.Printf("Pinned %s to %s in %s\n", dep.Name, dep.Sha, core.ProjectFile)
+		}
+	}
+
+	if project != nil {
+		return project.Save()
+	}
+	return nil
+}
+
+// useProject adds the enclosing project's source and dependency paths to
+// *load-path*, or outside a project the installed libraries
+func useProject(repl *core.REPL) error {
+	project, err := core.FindProject(".")
+	if err != nil {
+		return err
+	}
+	if project != nil {
+		project.Apply(repl.GetEnv())
+		return nil
+	}
+	core.UseLibraries(repl.GetEnv())
+	return nil
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runDoc implements `golisp doc`, generating a reference from Lisp sources
+func runDoc(args []string) error {
+	flags := flag.NewFlagSet("doc", flag.ExitOnError)
+	format := flags.String("format", "markdown", "Output format: markdown or html")
+	output := flags.String("o", "", "Output file (default: stdout)")
+	title := flags.String("title", "GoLisp API Reference", "Title of the generated reference")
+	builtins := flags.Bool("builtins", false, "Include Go core primitives in the reference")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s doc [options] <dir>...\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	dirs := flags.Args()
+	if len(dirs) == 0 {
+		flags.Usage()
+		return fmt.Errorf("doc expects at least one source directory")
+	}
+
+	var entries []core.DocEntry
+	for _, dir := range dirs {
+		dirEntries, err := collectDirDocs(dir)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, dirEntries...)
+	}
+
+	if *builtins {
+		entries = append(entries, core.CollectBuiltinDocs(core.NewCoreEnvironment())...)
+	}
+
+	var rendered string
+	switch *format {
+	case "markdown", "md":
+		rendered = core.RenderMarkdown(*title, entries)
+	case "html":
+		rendered = core.RenderHTML(*title, entries)
+	default:
+		return fmt.Errorf("unknown doc format: %s", *format)
+	}
+
+	if *output == "" {
+		fmt.Print(rendered)
+		return nil
+	}
+	return os.WriteFile(*output, []byte(rendered), 0644)
+}
+
+// collectDirDocs walks dir and collects documentation from every .lisp file
+func collectDirDocs(dir string) ([]core.DocEntry, error) {
+	var entries []core.DocEntry
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".lisp") {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %v", path, err)
+		}
+
+		fileEntries, err := core.CollectDocs(string(content), path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fileEntries...)
+		return nil
+	})
+	return entries, err
+}
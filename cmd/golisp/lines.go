@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runMap implements `golisp map`, printing an expression's value per line
+func runMap(args []string) error {
+	return runLines("map", core.LineMap, args)
+}
+
+// runFilter implements `golisp filter`, printing the lines that match
+func runFilter(args []string) error {
+	return runLines("filter", core.LineFilter, args)
+}
+
+func runLines(name string, mode core.LineMode, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	code := flags.String("e", "", "Expression to evaluate for each line, which is bound to line (and its number to n)")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s -e EXPR [file ...]\n", os.Args[0], name)
+		if mode == core.LineMap {
+			fmt.Fprintf(os.Stderr, "\nPrints the value of EXPR for each line of the files, or of stdin, skipping\n")
+			fmt.Fprintf(os.Stderr, "lines where it is nil. Strings print without quotes.\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "\nPrints the lines of the files, or of stdin, for which EXPR is true.\n")
+		}
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nExamples:\n")
+		fmt.Fprintf(os.Stderr, "  %s map -e '(upper-case line)' < notes.txt\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  tail -f app.log | %s filter -e '(string-contains? line \"ERR\")'\n", os.Args[0])
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *code == "" {
+		flags.Usage()
+		return fmt.Errorf("%s needs an expression given with -e", name)
+	}
+
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		return fmt.Errorf("creating environment: %v", err)
+	}
+	defer env.RunExitHooks()
+
+	inputs := []io.Reader{os.Stdin}
+	if flags.NArg() > 0 {
+		inputs = nil
+		for _, path := range flags.Args() {
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			inputs = append(inputs, file)
+		}
+	}
+	return core.ProcessLines(env, mode, *code, io.MultiReader(inputs...), os.Stdout)
+}
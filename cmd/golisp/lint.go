@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runLint implements `golisp lint`, statically checking .lisp files
+func runLint(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	disable := flags.String("disable", "", "Comma-separated rules to skip, e.g. unused-def,shadowed-builtin")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s lint [file-or-dir ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nChecks .lisp files (default: the current directory) for unused bindings\n")
+		fmt.Fprintf(os.Stderr, "and defs, shadowed builtins, arity mismatches, unreachable branches and\n")
+		fmt.Fprintf(os.Stderr, "single-argument comparisons.\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		return fmt.Errorf("creating environment: %v", err)
+	}
+
+	linter := core.NewLinter(env)
+	for _, path := range paths {
+		files, err := findLispFiles(path, ".lisp")
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to read file %s: %v", file, err)
+			}
+			if err := linter.AddSource(string(content), file); err != nil {
+				return err
+			}
+		}
+	}
+
+	disabled := make(map[string]bool)
+	for _, rule := range strings.Split(*disable, ",") {
+		disabled[strings.TrimSpace(rule)] = true
+	}
+
+	found := 0
+	for _, issue := range linter.Run() {
+		if !disabled[issue.Rule] {
+			fmt.Println(issue)
+			found++
+		}
+	}
+	if found > 0 {
+		return fmt.Errorf("%d issues found", found)
+	}
+	return nil
+}
@@ -4,15 +4,48 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/leinonen/go-lisp/pkg/core"
 )
 
+// subcommands maps `golisp <name>` to its implementation
+var subcommands = map[string]func(args []string) error{
+	"bench":   runBench,
+	"deps":    runDeps,
+	"doc":     runDoc,
+	"filter":  runFilter,
+	"install": runInstall,
+	"lint":    runLint,
+	"map":     runMap,
+	"repl":    runRepl,
+	"test":    runTest,
+}
+
 func main() {
+	// Dispatch subcommands before parsing the global flags
+	if len(os.Args) > 1 {
+		if run, ok := subcommands[os.Args[1]]; ok {
+			if err := run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	var (
 		help     = flag.Bool("help", false, "Show help message")
 		eval     = flag.String("e", "", "Evaluate code directly instead of reading from a file")
 		filename = flag.String("f", "", "File to execute")
+		quiet    = flag.Bool("quiet", false, "Suppress the REPL banner and result echo")
+		output   = flag.String("output", "text", "Result output format: text or json")
+		werror   = flag.Bool("werror", false, "Treat warnings, such as use of deprecated functions, as errors")
+		sandbox  = flag.Bool("sandbox", false, "Deny file, network and exec access to scripts and plugins")
+		maxDepth = flag.Int("max-depth", core.DefaultMaxCallDepth, "Maximum depth of nested function calls")
+		strict   = flag.Bool("strict", false, "Reject undefined names in functions and redefinitions, and treat warnings as errors")
+		compat   = flag.Bool("compat", false, "Run code written for the legacy interpreter dialect (defun, define, lambda, #t/#f)")
+		progress = flag.Bool("progress", false, "Report progress through the file's top-level forms on stderr")
 	)
 
 	flag.Usage = func() {
@@ -23,6 +56,21 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  %s                     # Start interactive REPL\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -f script.lisp      # Execute a file\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -e '(+ 1 2 3)'      # Evaluate code directly\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -output json -f script.lisp  # Print the final value as JSON\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -werror -f script.lisp  # Fail on warnings, e.g. in CI\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -sandbox -f untrusted.lisp  # Run without file, network or exec access\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -strict -f script.lisp  # Catch undefined names and redefinitions early\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -compat -f old.lisp  # Run a script written for the legacy dialect\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -progress -f data.lisp  # Show how far loading a large file has got\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s repl --load-session s.lisp  # Restore a saved REPL session\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s doc lisp/           # Generate a Markdown API reference\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s test --update test/ # Run *_test.lisp files, rewriting golden files\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s lint src/           # Report likely mistakes in .lisp files\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s bench benchmarks/   # Run *_bench.lisp files\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s map -e '(upper-case line)'  # Transform each line of stdin\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s filter -e '(string-contains? line \"ERR\")'  # Keep matching lines\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s deps fetch          # Download dependencies listed in golisp.edn\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s install github.com/user/lib@v1.0  # Install a library\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -help               # Show this help message\n", os.Args[0])
 	}
 
@@ -33,54 +81,109 @@ func main() {
 		return
 	}
 
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown output format: %s\n", *output)
+		os.Exit(1)
+	}
+
 	// Create a REPL with bootstrapped environment
 	repl, err := core.NewREPL()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error creating REPL: %v\n", err)
 		os.Exit(1)
 	}
+	env := repl.GetEnv()
+	env.SetWarningsAsErrors(*werror)
+	env.SetMaxCallDepth(*maxDepth)
+
+	if err := useProject(repl); err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading project: %v\n", err)
+		env.Exit(1)
+	}
+
+	if *compat {
+		env.SetReaderConfig(core.ReaderConfig{LegacyAliases: true})
+		if _, err := repl.EvalString("(require 'compat)"); err != nil {
+			fmt.Fprintf(os.Stderr, "Error loading compat: %v\n", err)
+			env.Exit(1)
+		}
+	}
+
+	if *sandbox {
+		env.DenyCapabilities(core.CapabilityFS, core.CapabilityNet, core.CapabilityExec)
+	}
+
+	if *strict {
+		env.SetStrict(true)
+	}
 
 	// Handle -e flag: evaluate code directly
 	if *eval != "" {
+		repl.SetCommandLineArgs(flag.Args())
 		// Evaluate the code directly
 		result, err := repl.EvalString(*eval)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error evaluating code: %v\n", err)
-			os.Exit(1)
+			env.Exit(1)
 		}
 
-		// Don't print nil values (used by print functions to avoid duplicate output)
-		if result != nil && result.String() != "nil" {
-			fmt.Println(result)
+		if *output == "json" {
+			printJSON(env, result)
+		} else if !*quiet && result != nil {
+			// Printing realizes lazy results, which can fail like evaluating
+			printed, err := core.PrintString(result, env)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error evaluating code: %v\n", err)
+				env.Exit(1)
+			}
+			// Don't print nil values (used by print functions to avoid duplicate output)
+			if printed != "nil" {
+				fmt.Println(printed)
+			}
 		}
+		env.RunExitHooks()
 		return
 	}
 
-	// Handle -f flag: execute a file
-	if *filename != "" {
-		err := repl.LoadFile(*filename)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error executing file %s: %v\n", *filename, err)
-			os.Exit(1)
-		}
-		return
+	// Handle -f flag (or the legacy positional argument): execute a file
+	scriptFile, scriptArgs := *filename, flag.Args()
+	if scriptFile == "" && len(flag.Args()) > 0 {
+		scriptFile, scriptArgs = flag.Args()[0], flag.Args()[1:]
 	}
 
-	// Check for legacy positional argument (backward compatibility)
-	if len(flag.Args()) > 0 {
-		legacyFilename := flag.Args()[0]
-		err := repl.LoadFile(legacyFilename)
+	if scriptFile != "" {
+		repl.SetCommandLineArgs(scriptArgs)
+		if *progress {
+			repl.SetProgress(progressReporter(os.Stderr, time.Second))
+		}
+		result, err := repl.EvalFile(scriptFile)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error executing file %s: %v\n", legacyFilename, err)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Error executing file %s: %v\n", scriptFile, err)
+			env.Exit(1)
 		}
+		if *output == "json" {
+			printJSON(env, result)
+		}
+		env.RunExitHooks()
 		return
 	}
 
 	// If no arguments provided, start REPL
+	repl.SetQuiet(*quiet)
 	err = repl.Run()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "REPL error: %v\n", err)
-		os.Exit(1)
+		env.Exit(1)
+	}
+	env.RunExitHooks()
+}
+
+// printJSON writes a result value to stdout as JSON
+func printJSON(env *core.Environment, result core.Value) {
+	data, err := core.ValueToJSON(result)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding result as JSON: %v\n", err)
+		env.Exit(1)
 	}
+	fmt.Println(string(data))
 }
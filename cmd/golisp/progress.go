@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// progressReporter returns a REPL progress callback that writes a line to w
+// at most once per interval while a file loads, naming the form being
+// evaluated, and a summary line when it is done
+func progressReporter(w io.Writer, interval time.Duration) func(core.LoadProgress) {
+	var started, last time.Time
+	return func(p core.LoadProgress) {
+		now := time.Now()
+		if p.Done == 0 {
+			started = now
+		}
+		if p.Done == p.Total {
+			fmt.Fprintf(w, "%s: evaluated %d forms in %s\n", p.File, p.Total, now.Sub(started).Round(time.Millisecond))
+			return
+		}
+		if now.Sub(last) < interval {
+			return
+		}
+		last = now
+		fmt.Fprintf(w, "%s: %d/%d forms, evaluating %s\n", p.File, p.Done, p.Total, describeForm(p.Form))
+	}
+}
+
+// describeForm summarizes a top-level form on one short line, with its line
+// number when the reader recorded one
+func describeForm(form core.Value) string {
+	text := strings.Join(strings.Fields(form.String()), " ")
+	if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:57]) + "..."
+	}
+	if located, ok := form.(core.SourceLocated); ok {
+		if pos := located.GetPosition(); pos.Line > 0 {
+			return fmt.Sprintf("line %d: %s", pos.Line, text)
+		}
+	}
+	return text
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runRepl implements `golisp repl`, starting the REPL with optional state
+func runRepl(args []string) error {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	session := flags.String("load-session", "", "Session file to restore before starting")
+	image := flags.String("image", "", "Image written by save-image to load before starting")
+	watch := flags.Bool("watch", false, "Reload required namespaces when their files change")
+	quiet := flags.Bool("quiet", false, "Suppress the banner and result echo")
+	sandbox := flags.Bool("sandbox", false, "Deny file, network and exec access to evaluated code and plugins")
+	strict := flags.Bool("strict", false, "Reject undefined names in functions and redefinitions, and treat warnings as errors")
+
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s repl [options]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	repl, err := core.NewREPL()
+	if err != nil {
+		return fmt.Errorf("creating REPL: %v", err)
+	}
+	defer repl.GetEnv().RunExitHooks()
+
+	repl.SetQuiet(*quiet)
+
+	if err := useProject(repl); err != nil {
+		return err
+	}
+
+	if *image != "" {
+		count, err := core.LoadImage(repl.GetEnv(), *image)
+		if err != nil {
+			return err
+		}
+		if !*quiet {
+			fmt.Printf("Loaded %d definitions from %s\n", count, *image)
+		}
+	}
+
+	if *sandbox {
+		repl.GetEnv().DenyCapabilities(core.CapabilityFS, core.CapabilityNet, core.CapabilityExec)
+	}
+
+	if *strict {
+		repl.GetEnv().SetStrict(true)
+	}
+
+	if *session != "" {
+		if err := repl.LoadSession(*session); err != nil {
+			return err
+		}
+		if !*quiet {
+			fmt.Printf("Restored session from %s\n", *session)
+		}
+	}
+
+	if *watch {
+		stop := repl.WatchNamespaces(500*time.Millisecond, os.Stdout)
+		defer stop()
+	}
+
+	return repl.Run()
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// runTest implements `golisp test`, running the deftests in every
+// *_test.lisp file found under the given files or directories
+func runTest(args []string) error {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	update := flags.Bool("update", false, "Rewrite golden files instead of comparing against them")
+	coverage := flags.Bool("coverage", false, "Record which source forms the tests evaluate")
+	coverageDir := flags.String("coverage-dir", "coverage", "Directory for annotated coverage reports")
+	werror := flags.Bool("werror", false, "Treat warnings, such as use of deprecated functions, as errors")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s test [options] [file-or-dir ...]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "\nRuns *_test.lisp files (default: the current directory).\n")
+		fmt.Fprintf(os.Stderr, "\nOptions:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	var files []string
+	for _, path := range paths {
+		found, err := findLispFiles(path, "_test.lisp")
+		if err != nil {
+			return err
+		}
+		files = append(files, found...)
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no *_test.lisp files found")
+	}
+
+	var recorder *core.Coverage
+	if *coverage {
+		recorder = core.NewCoverage()
+	}
+
+	var total core.TestSummary
+	for _, file := range files {
+		fmt.Printf("Testing %s\n", file)
+
+		// Each file gets a fresh environment so tests don't interfere
+		repl, err := core.NewREPL()
+		if err != nil {
+			return fmt.Errorf("creating REPL: %v", err)
+		}
+		defer repl.GetEnv().RunExitHooks()
+		repl.GetEnv().SetWarningsAsErrors(*werror)
+		if recorder != nil {
+			repl.GetEnv().RecordCoverage(recorder)
+		}
+		if err := useProject(repl); err != nil {
+			return err
+		}
+		if _, err := repl.EvalFile(file); err != nil {
+			return fmt.Errorf("%s: %v", file, err)
+		}
+
+		summary := core.RunTests(repl.GetEnv(), core.TestOptions{
+			GoldenDir: filepath.Join(filepath.Dir(file), "testdata", "golden"),
+			Update:    *update,
+			Out:       os.Stdout,
+		})
+		total.Tests += summary.Tests
+		total.Pass += summary.Pass
+		total.Fail += summary.Fail
+		total.Error += summary.Error
+	}
+
+	if len(files) > 1 {
+		fmt.Printf("\nTotal: %d tests, %d passed assertions, %d failures, %d errors.\n",
+			total.Tests, total.Pass, total.Fail, total.Error)
+	}
+	if recorder != nil {
+		if err := writeCoverageReport(recorder, *coverageDir); err != nil {
+			return err
+		}
+	}
+	if total.Fail+total.Error > 0 {
+		return fmt.Errorf("%d failures, %d errors", total.Fail, total.Error)
+	}
+	return nil
+}
+
+// writeCoverageReport prints per-file and total coverage of the non-test
+// sources, writing an annotated copy of each file to dir
+func writeCoverageReport(recorder *core.Coverage, dir string) error {
+	fmt.Printf("\nCoverage:\n")
+	covered, total := 0, 0
+	for _, file := range recorder.Files() {
+		if strings.HasSuffix(file, "_test.lisp") {
+			continue
+		}
+
+		fileCovered, fileTotal := recorder.FileSummary(file)
+		covered += fileCovered
+		total += fileTotal
+		fmt.Printf("  %-40s %6s  (%d/%d forms)\n", file, core.CoveragePercent(fileCovered, fileTotal), fileCovered, fileTotal)
+
+		annotated := filepath.Join(dir, strings.TrimPrefix(filepath.Clean(file), string(filepath.Separator))+".cov")
+		if err := os.MkdirAll(filepath.Dir(annotated), 0755); err != nil {
+			return err
+		}
+		out, err := os.Create(annotated)
+		if err != nil {
+			return err
+		}
+		recorder.Annotate(out, file)
+		if err := out.Close(); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("  %-40s %6s  (%d/%d forms)\n", "total", core.CoveragePercent(covered, total), covered, total)
+	fmt.Printf("Annotated sources written to %s\n", dir)
+	return nil
+}
@@ -34,7 +34,7 @@
 
 ;; Helper functions used in compiler
 (defn second [coll] (first (rest coll)))
-(defn length [coll] (count coll))
+(defn ^:no-shadow-warning length [coll] (count coll))
 
 ;; Simple concat function  
 (defn concat [coll1 coll2]
@@ -364,9 +364,9 @@
 (defn compile-do [args ctx]
   (cons 'do (map (fn [expr] (compile-expr expr ctx)) args)))
 
-(defn compile-application [fn args ctx]
+(defn compile-application [f args ctx]
   ;; Compile function call
-  (cons (compile-expr fn ctx)
+  (cons (compile-expr f ctx)
         (map (fn [arg] (compile-expr arg ctx)) args)))
 
 (defn compile-vector [vec ctx]
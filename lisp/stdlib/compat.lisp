@@ -0,0 +1,32 @@
+;; Compatibility with the legacy interpreter dialect
+;; (require 'compat) defines the names scripts written for it use, and makes
+;; code read afterwards, like files loaded next, accept define, lambda and
+;; begin. `golisp -compat` requires it before reading the script, and also
+;; reads the #t and #f booleans. length is already a builtin.
+
+;; Strings, since under -compat 'define would already read as def
+(set-reader-alias! "define" "def")
+(set-reader-alias! "lambda" "fn")
+(set-reader-alias! "begin" "do")
+
+;; (defun name (params...) body...) defines a function
+(defmacro defun [fn-name params & body]
+  (cons 'defn (cons fn-name (cons params body))))
+
+;; (modules) lists the namespaces loaded with require
+(defn modules []
+  (loaded-namespaces))
+
+;; (env) lists the functions and macros defined by user code
+(defn env []
+  (filter (fn [f] (not (or (builtin-name? f) (contains-item? f compat-names))))
+          (registered-functions "user")))
+
+;; (builtins) lists the functions implemented in Go
+(defn builtins []
+  (filter builtin-name? (registered-functions)))
+
+(defn builtin-name? [f]
+  (= :builtin (:kind (function-help f))))
+
+(def compat-names ["defun" "modules" "env" "builtins" "builtin-name?" "compat-names"])
@@ -6,7 +6,7 @@
 ;; Note: cond is implemented as a special form in the core evaluator
 
 ;; Logical operations
-(defn not [x] (if x nil true))
+(defn ^:no-shadow-warning not [x] (if x nil true))
 
 ;; Conditional helpers  
 (defmacro when [condition & body]
@@ -15,7 +15,65 @@
 (defmacro unless [condition & body]
   (list 'if condition nil (cons 'do body)))
 
-(defmacro cond [& clauses]
+;; Forward declarations, for strict mode and mutually recursive functions
+(defmacro declare [& names]
+  (cons 'do (map (fn [n] (list 'declare-var (list 'quote n))) names)))
+
+;; Calls each form with x inserted as its first argument, returning x:
+;; (doto (atom 0) (swap! inc) (add-watch :log log-change))
+(defmacro doto [x & forms]
+  (let [obj (gensym)]
+    (cons 'let
+          (cons (vector obj x)
+                (concat (map (fn [f] (if (list? f)
+                                       (cons (first f) (cons obj (rest f)))
+                                       (list f obj)))
+                             forms)
+                        (list obj))))))
+
+;; Rebinds top-level names while body runs, restoring them afterwards:
+;; (with-redefs [fetch (fn [url] "stub")] (report))
+(defmacro with-redefs [bindings & body]
+  (list 'call-with-redefs
+        (cons 'list (redef-pairs bindings))
+        (cons 'fn (cons [] body))))
+
+;; Gives top-level names new values for everything called within body,
+;; including functions passed to jobs started there; other code still
+;; sees the root values: (binding [*log-level* :debug] (run))
+(defmacro binding [bindings & body]
+  (list 'call-with-bindings
+        (cons 'list (redef-pairs bindings))
+        (cons 'fn (cons [] body))))
+
+;; Turns [f stub ...] into ((var f) stub ...) for with-redefs and binding
+(defn redef-pairs [bindings]
+  (if (empty? bindings)
+    ()
+    (cons (list 'var (first bindings))
+          (cons (second bindings) (redef-pairs (rest (rest bindings)))))))
+
+;; Fails with a timeout error if body runs longer than ms milliseconds
+(defmacro with-timeout [ms & body]
+  (list 'call-with-timeout ms (cons 'fn (cons [] body))))
+
+;; Numbers the gensyms made in body, including by macros it expands, from
+;; n, so macroexpansions come out the same on every run:
+;; (with-gensym-seed 1 (macroexpand '(my-macro x)))
+(defmacro with-gensym-seed [n & body]
+  (list 'call-with-gensym-seed n (cons 'fn (cons [] body))))
+
+;; Runs body with a fresh temporary directory, removed afterwards:
+;; (with-tmp-dir [dir] (spit (str dir "/x.txt") "..."))
+(defmacro with-tmp-dir [binding & body]
+  (list 'call-with-tmp-dir (cons 'fn (cons binding body))))
+
+;; Interfaces whose implementations Go embedders can adapt:
+;; (definterface Greeter (greet [name]) (farewell [name]))
+(defmacro definterface [iface-name & methods]
+  (list 'def iface-name (cons 'make-interface (cons (list 'quote iface-name) (map (fn [m] (list 'quote m)) methods)))))
+
+(defmacro ^:no-shadow-warning cond [& clauses]
   (if (empty? clauses)
     nil
     (let [condition (first clauses)
@@ -29,51 +87,69 @@
 ;; Note: count, empty?, nth, conj are already in core
 
 ;; Length alias for count (common in self-hosting compiler)
-(def length count)
+(def ^:no-shadow-warning length count)
 
 ;; Hash-map mutation (for self-hosting compiler)
 ;; Note: This is not truly mutable, but works with reassignment
-(defn hash-map-put [map key value]
+(defn ^:no-shadow-warning hash-map-put [map key value]
   (assoc map key value))
 
 ;; Second, third helpers
 (defn second [coll] (first (rest coll)))
 (defn third [coll] (first (rest (rest coll))))
 
-;; Map function
-(defn map [f coll]
-  (if (empty? coll)
-      ()
-      (cons (f (first coll)) (map f (rest coll)))))
+;; The sequence functions below loop with recur rather than recursing, so
+;; their length isn't limited by the maximum call depth
+
+(defn reverse [coll]
+  (loop [xs coll acc ()]
+    (if (empty? xs)
+        acc
+        (recur (rest xs) (cons (first xs) acc)))))
+
+;; Map function; given several collections, f takes one element of each
+;; and the result stops at the shortest
+(defn map [f coll & colls]
+  (if (empty? colls)
+      (loop [xs coll acc ()]
+        (if (empty? xs)
+            (reverse acc)
+            (recur (rest xs) (cons (f (first xs)) acc))))
+      (map-across f (cons coll colls))))
+
+(defn map-across [f colls]
+  (loop [xss colls acc ()]
+    (if (any? empty? xss)
+        (reverse acc)
+        (recur (map rest xss) (cons (apply f (map first xss)) acc)))))
 
 ;; Filter function
 (defn filter [pred coll]
-  (if (empty? coll)
-      ()
-      (if (pred (first coll))
-          (cons (first coll) (filter pred (rest coll)))
-          (filter pred (rest coll)))))
+  (loop [xs coll acc ()]
+    (if (empty? xs)
+        (reverse acc)
+        (recur (rest xs) (if (pred (first xs)) (cons (first xs) acc) acc)))))
 
 ;; Range function (reverse order for simplicity)
 (defn range [n]
-  (if (= n 0)
-      ()
-      (cons (- n 1) (range (- n 1)))))
+  (loop [i 0 acc ()]
+    (if (>= i n)
+        acc
+        (recur (+ i 1) (cons i acc)))))
 
-;; Reduce function (simplified)
+;; Reduce function (simplified); a step that returns (reduced v) stops
+;; the reduction with v
 (defn reduce [f init coll]
-  (if (empty? coll)
-      init
-      (reduce f (f init (first coll)) (rest coll))))
-
-;; Group-by function - group collection by key function (simplified)
-(defn group-by [f coll]
-  ;; Simplified implementation - returns list of (key value) pairs
-  (reduce (fn [acc item]
-            (let [key (f item)]
-              (cons (list key item) acc)))
-          nil
-          coll))
+  (loop [acc init xs coll]
+    (if (reduced? acc)
+        (deref acc)
+        (if (empty? xs)
+            acc
+            (recur (f acc (first xs)) (rest xs))))))
+
+;; Reduce over the entries of a map, calling (f acc key value)
+(defn reduce-kv [f init m]
+  (reduce (fn [acc k] (f acc k (get m k))) init (keys m)))
 
 ;; Improved map function with two collections support
 (defn map2 [f coll1 coll2]
@@ -82,4 +158,11 @@
       (if (empty? coll2)
           nil
           (cons (f (first coll1) (first coll2))
-                (map2 f (rest coll1) (rest coll2))))))
\ No newline at end of file
+                (map2 f (rest coll1) (rest coll2))))))
+;; Benchmarking: (bench expr :iterations 1000 :samples 10 :warmup 1000)
+;; prints timing and allocation stats for expr; use bench-fn for the raw map
+(defmacro bench [expr & opts]
+  (list 'println
+        (list 'bench-report
+              (cons 'bench-fn (cons (list 'fn [] expr)
+                                    (cons :label (cons (str expr) opts)))))))
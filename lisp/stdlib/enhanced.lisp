@@ -2,13 +2,7 @@
 ;; Advanced functions implemented in Lisp using core primitives
 
 ;; String operations (using core string primitives)
-(defn join [sep coll]
-  (if (empty? coll)
-      ""
-      (reduce (fn [acc x] (str acc sep (str x)))
-              (str (first coll))
-              (rest coll))))
-
+(def join string-join)
 (def split string-split)
 (def trim string-trim)
 (def replace string-replace)
@@ -17,20 +11,6 @@
 ;; string-contains? remains available for string operations
 
 ;; Enhanced collection operations
-(defn apply [f coll]
-  (if (empty? coll)
-      (f)
-      (if (= (count coll) 1)
-          (f (first coll))
-          (if (= (count coll) 2)
-              (f (first coll) (nth coll 1))
-              (if (= (count coll) 3)
-                  (f (first coll) (nth coll 1) (nth coll 2))
-                  (f (first coll) (nth coll 1) (nth coll 2) (nth coll 3)))))))
-
-(defn reverse [coll]
-  (reduce (fn [acc x] (cons x acc)) () coll))
-
 (defn take [n coll]
   (if (= n 0)
       ()
@@ -67,7 +47,7 @@
   (if a a b))
 
 ;; Enhanced predicates
-(defn nil? [x] (= x nil))
+(defn ^:no-shadow-warning nil? [x] (= x nil))
 (defn some? [x] (not (nil? x)))
 (defn true? [x] (= x true))
 (defn false? [x] (nil? x))
@@ -76,15 +56,6 @@
 (defn seq? [x] (or2 (list? x) (vector? x)))
 (defn coll? [x] (or2 (list? x) (vector? x)))
 
-;; Functional utilities
-(defn comp [f g]
-  (fn [x] (f (g x))))
-
-(defn constantly [x]
-  (fn [y] x))
-
-(defn identity [x] x)
-
 ;; Math utilities
 (defn min [& args]
   (reduce (fn [a b] (if (< a b) a b)) (first args) (rest args)))
@@ -177,12 +148,18 @@
 
 ;; Keep function
 (defn keep [f coll]
-  (if (empty? coll)
-      ()
-      (let [result (f (first coll))]
-        (if (nil? result)
-            (keep f (rest coll))
-            (cons result (keep f (rest coll)))))))
+  (loop [xs coll acc ()]
+    (if (empty? xs)
+        (reverse acc)
+        (let [result (f (first xs))]
+          (recur (rest xs) (if (nil? result) acc (cons result acc)))))))
+
+;; Mapcat function
+(defn mapcat [f coll]
+  (loop [xs coll acc ()]
+    (if (empty? xs)
+        (reverse acc)
+        (recur (rest xs) (reduce (fn [acc x] (cons x acc)) acc (f (first xs)))))))
 
 ;; Flatten (simple version)
 (defn flatten [coll]
@@ -192,7 +169,94 @@
           (concat (flatten (first coll)) (flatten (rest coll)))
           (cons (first coll) (flatten (rest coll))))))
 
-;; Partial function application
-(defn partial [f & partial-args]
-  (fn [& remaining-args]
-    (apply f (concat partial-args remaining-args))))
\ No newline at end of file
+;; Threading macros
+;; (-> x (f a) g) => (g (f x a)); ->> threads into the last position instead
+(defmacro -> [x & forms]
+  (if (empty? forms)
+      x
+      (let [form (first forms)
+            threaded (if (list? form)
+                         (cons (first form) (cons x (rest form)))
+                         (list form x))]
+        (cons '-> (cons threaded (rest forms))))))
+
+(defmacro ->> [x & forms]
+  (if (empty? forms)
+      x
+      (let [form (first forms)
+            threaded (if (list? form)
+                         (concat form (list x))
+                         (list form x))]
+        (cons '->> (cons threaded (rest forms))))))
+
+;; (cond-> x test form ...) threads x through each form whose test is truthy;
+;; tests see the original bindings, not the threaded value
+(defn cond-thread [threader x clauses]
+  (if (odd? (count clauses))
+      (throw (str "cond" threader " expects test/form pairs"))
+      (let [g (gensym)
+            steps (reduce (fn [acc clause]
+                            (concat acc (list g (list 'if (first clause)
+                                                      (list threader g (second clause))
+                                                      g))))
+                          ()
+                          (partition 2 clauses))]
+        (list 'let (cons g (cons x steps)) g))))
+
+(defmacro cond-> [x & clauses]
+  (cond-thread '-> x clauses))
+
+(defmacro cond->> [x & clauses]
+  (cond-thread '->> x clauses))
+
+;; (some-> x f g) threads like -> but stops with nil as soon as a step is nil
+(defn some-thread [threader some-threader x forms]
+  (if (empty? forms)
+      x
+      (let [g (gensym)]
+        (list 'let (list g x)
+              (list 'if (list 'nil? g)
+                    nil
+                    (cons some-threader
+                          (cons (list threader g (first forms)) (rest forms))))))))
+
+(defmacro some-> [x & forms]
+  (some-thread '-> 'some-> x forms))
+
+(defmacro some->> [x & forms]
+  (some-thread '->> 'some->> x forms))
+
+;; (as-> x name forms ...) binds name to each result in turn, so the value can
+;; go in any position
+(defmacro as-> [expr binding & forms]
+  (list 'let
+        (cons binding (cons expr (reduce (fn [acc form] (concat acc (list binding form)))
+                                         ()
+                                         forms)))
+        binding))
+;; (pipeline (source coll) (xf (map f) (filter p)) (sink f) :workers 4) runs
+;; the xf steps over the elements of coll on worker goroutines and hands the
+;; results to sink, or returns them in a vector without one. Steps are map,
+;; filter, remove, keep and mapcat. The clauses become run-pipeline options.
+(defmacro pipeline [& clauses]
+  (cons 'run-pipeline (pipeline-options clauses)))
+
+(defn pipeline-options [clauses]
+  (cond
+    (empty? clauses) ()
+    (list? (first clauses))
+    (let [clause (first clauses)
+          value (if (= (first clause) 'xf)
+                  (cons 'list (map (fn [step] (list 'list (keyword (first step)) (second step)))
+                                   (rest clause)))
+                  (second clause))]
+      (cons (keyword (first clause)) (cons value (pipeline-options (rest clauses)))))
+    :else (cons (first clauses) (cons (second clauses) (pipeline-options (rest (rest clauses)))))))
+
+;; (postwalk-replace {'x 'y} form) replaces every x anywhere in form with y;
+;; prewalk-replace does the same from the outside in
+(defn postwalk-replace [smap form]
+  (postwalk (fn [x] (if (contains? smap x) (get smap x) x)) form))
+
+(defn prewalk-replace [smap form]
+  (prewalk (fn [x] (if (contains? smap x) (get smap x) x)) form))
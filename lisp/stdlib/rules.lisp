@@ -0,0 +1,51 @@
+;; Declarative when/then rules
+;; (require 'rules) defines defrule, which names a condition and an action
+;; on a fact, and run-rules, which fires every rule whose condition holds
+;; for a fact and feeds what the actions return back in as derived facts
+
+;; (defrule name {:when cond :then action}) defines a rule. cond and action
+;; see the fact as event, or as the symbol given with :as. :priority orders
+;; the rules tried on a fact, highest first (default 0).
+(defmacro defrule [rule-name spec]
+  (let [binding (or (get spec :as) 'event)]
+    (list 'def rule-name
+          (list 'hash-map
+                :name (list 'quote rule-name)
+                :when (list 'fn (vector binding) (get spec :when))
+                :then (list 'fn (vector binding) (get spec :then))
+                :priority (or (get spec :priority) 0)))))
+
+;; *max-rule-firings* bounds a run-rules call, so rules that keep deriving
+;; new facts fail instead of running forever
+(def *max-rule-firings* 10000)
+
+;; The rules of a ruleset, where a symbol, as in a vector literal, names
+;; the rule defined under it
+(defn rules-of [ruleset]
+  (sort-by (fn [rule] (- (:priority rule)))
+           (map (fn [rule] (if (symbol? rule) (eval rule) rule)) ruleset)))
+
+;; (run-rules ruleset facts) tries every rule on every fact, in order, then
+;; on each fact derived along the way. A rule fires when its :when holds;
+;; what its :then returns, unless nil or already known, is a derived fact.
+;; Returns {:fired [{:rule name :fact fact}...] :facts [derived facts...]}.
+(defn run-rules [ruleset facts]
+  (let [rules (rules-of ruleset)
+        known (atom (reduce (fn [seen fact] (assoc seen fact true)) (hash-map) facts))
+        queue (atom (apply list facts))
+        fired (atom [])
+        derived (atom [])]
+    (while (not (empty? @queue))
+      (let [fact (first @queue)]
+        (swap! queue rest)
+        (doseq [rule rules]
+          (when ((:when rule) fact)
+            (when (>= (count @fired) *max-rule-firings*)
+              (throw (str "run-rules: more than " *max-rule-firings* " rules fired")))
+            (swap! fired conj (hash-map :rule (:name rule) :fact fact))
+            (let [result ((:then rule) fact)]
+              (when (and (not (nil? result)) (not (contains? @known result)))
+                (swap! known assoc result true)
+                (swap! derived conj result)
+                (swap! queue concat (list result))))))))
+    (hash-map :fired @fired :facts @derived)))
@@ -0,0 +1,29 @@
+;; Unit testing support
+;; deftest registers a test, is and is-golden record assertions, and
+;; run-tests (or `golisp test`) runs every registered test
+
+;; (deftest name body...) defines a test run later by run-tests
+(defmacro deftest [test-name & body]
+  (list 'register-test (list 'quote test-name) (cons 'fn (cons [] body))))
+
+;; (is expr) or (is expr "message") asserts that expr is truthy. A failed
+;; (is (= expected actual)) shows both values and a diff of them.
+(defmacro is [expr & msg]
+  (if (and (list? expr) (= (count expr) 3) (= (first expr) '=))
+    (list 'report-equality (list 'quote expr) (nth expr 1) (nth expr 2) (first msg))
+    (list 'report-assertion (list 'quote expr) expr (first msg))))
+
+;; (is-golden "name" value) is a Go primitive: it compares the pretty-printed
+;; value with testdata/golden/name.golden, rewritten by `golisp test --update`
+
+;; (defprop name [x (gen/int) xs (gen/vector (gen/int))] body...) defines a
+;; test that checks body against *property-runs* random cases, shrinking the
+;; first failing case before reporting it
+(defmacro defprop [prop-name bindings & body]
+  (let [pairs (partition 2 bindings)
+        names (reduce (fn [acc pair] (conj acc (first pair))) [] pairs)
+        gens (cons 'list (map second pairs))]
+    (list 'register-test (list 'quote prop-name)
+          (list 'fn []
+                (list 'check-property (list 'quote prop-name) (list 'quote names) gens
+                      (cons 'fn (cons names body)))))))
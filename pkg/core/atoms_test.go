@@ -0,0 +1,95 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAtoms(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(def a (atom 1))", "a"},
+		{"@a", "1"},
+		{"(swap! a + 10)", "11"},
+		{"(swap! a (fn [x y z] (* x y z)) 2 3)", "66"},
+		{"(reset! a :done)", ":done"},
+		{"(deref a)", ":done"},
+		{"a", "#<atom :done>"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(swap! 1 +)":                         "swap! expects an atom",
+		"(reset! a)":                          "reset! expects 2 arguments",
+		"(swap! a (fn [x] (throw \"boom\")))": "boom",
+		"(add-watch 1 :k +)":                  "add-watch expects an atom, ref or var",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestAtomWatches(t *testing.T) {
+	env := NewCoreEnvironment()
+	var events []string
+	env.Set(Intern("record"), &BuiltinFunction{
+		Name: "record",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = arg.String()
+			}
+			events = append(events, strings.Join(parts, " "))
+			return Nil{}, nil
+		},
+	})
+
+	evalAll(t, env, `
+		(def counter (atom 0))
+		(add-watch counter :a (fn [k ref old new] (record k old new)))
+		(add-watch counter :b (fn [k ref old new] (record k @ref)))
+		(swap! counter + 1)
+		(add-watch counter :b (fn [k ref old new] (record :replaced)))
+		(reset! counter 5)
+		(remove-watch counter :a)
+		(swap! counter + 1)`)
+
+	expected := []string{":a 0 1", ":b 1", ":a 1 5", ":replaced", ":replaced"}
+	if strings.Join(events, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected watch events %q, got %q", expected, events)
+	}
+}
+
+func TestAtomConcurrentSwaps(t *testing.T) {
+	env := NewCoreEnvironment()
+	a := NewAtom(NewNumber(int64(0)))
+	plus, _ := env.Get(Intern("+"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := a.Swap(plus, []Value{NewNumber(int64(1))}, env); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := a.Deref().String(); got != "1000" {
+		t.Errorf("Expected 1000 after concurrent swaps, got %s", got)
+	}
+}
@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestBenchFn(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	eval := func(input string) (core.Value, error) {
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		return core.Eval(expr, env)
+	}
+
+	result, err := eval(`(def stats (bench-fn (fn [] (+ 1 2)) :iterations 50 :samples 4 :warmup 0 :label "add"))`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(get stats :samples)`, "4"},
+		{`(get stats :iterations)`, "50"},
+		{`(get stats :label)`, `"add"`},
+		{`(> (get stats :mean) 0)`, "true"},
+		{`(<= (get stats :min) (get stats :median))`, "true"},
+		{`(<= (get stats :median) (get stats :max))`, "true"},
+		{`(>= (get stats :stddev) 0)`, "true"},
+		{`(>= (get stats :allocs) 0)`, "true"},
+	}
+	for _, test := range tests {
+		result, err = eval(test.input)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	report, err := eval(`(bench-report stats)`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"add", "4 samples x 50 iterations", "mean", "median", "stddev", "allocs/op"} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("Expected report to contain %q, got %s", want, report)
+		}
+	}
+
+	errorCases := []string{
+		`(bench-fn)`,
+		`(bench-fn 42)`,
+		`(bench-fn (fn [] 1) :iterations)`,
+		`(bench-fn (fn [] 1) :iterations 0)`,
+		`(bench-fn (fn [] 1) :bogus 1)`,
+		`(bench-fn (fn [] (throw "boom")) :iterations 1)`,
+		`(bench-report 1)`,
+	}
+	for _, input := range errorCases {
+		if _, err := eval(input); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
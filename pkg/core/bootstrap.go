@@ -4,42 +4,24 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 // LoadStandardLibrary loads the self-hosted standard library
 func LoadStandardLibrary(env *Environment) error {
-	// Find the stdlib directory relative to the current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current working directory: %v", err)
-	}
-
 	// Load standard library files
 	stdlibFiles := []string{
 		"lisp/stdlib/core.lisp",     // Re-enabled after fixing function conflicts
 		"lisp/stdlib/enhanced.lisp", // Re-enabled for testing
+		"lisp/stdlib/test.lisp",     // deftest, is, is-golden
 	}
 
 	for _, filename := range stdlibFiles {
-		// Look for the stdlib file
-		stdlibPath := filepath.Join(cwd, filename)
-
-		// Check if file exists
-		if _, err := os.Stat(stdlibPath); os.IsNotExist(err) {
-			// Try alternative paths
-			for _, path := range []string{
-				"../../" + filename,
-				"../../../" + filename,
-				"./" + filename,
-			} {
-				if _, err := os.Stat(path); err == nil {
-					stdlibPath = path
-					break
-				}
-			}
-		}
-
 		// Load the standard library file
+		stdlibPath, err := findStdlibFile(filename)
+		if err != nil {
+			return err
+		}
 		content, err := os.ReadFile(stdlibPath)
 		if err != nil {
 			// If we can't find the file, just continue to next file
@@ -48,7 +30,9 @@ func LoadStandardLibrary(env *Environment) error {
 		}
 
 		// Parse and evaluate the standard library
-		err = loadLibraryContent(string(content), env)
+		_, err = recordDefinitions(env, "stdlib", "", func() error {
+			return loadLibraryContent(string(content), env)
+		})
 		if err != nil {
 			return fmt.Errorf("failed to load %s: %v", filename, err)
 		}
@@ -57,19 +41,51 @@ func LoadStandardLibrary(env *Environment) error {
 	return nil
 }
 
-func loadLibraryContent(content string, env *Environment) error {
-	// Parse and evaluate the standard library
-	lexer := NewLexer(content)
-	tokens, err := lexer.Tokenize()
+// findStdlibFile locates a file shipped under lisp/ relative to the current
+// working directory, or to the directories tests and tools run from. The
+// path returned for a missing file is where it was expected.
+func findStdlibFile(filename string) (string, error) {
+	// Find the stdlib directory relative to the current working directory
+	cwd, err := os.Getwd()
 	if err != nil {
-		return fmt.Errorf("failed to tokenize: %v", err)
+		return "", fmt.Errorf("failed to get current working directory: %v", err)
 	}
 
-	parser := NewParser(tokens)
-	expressions, err := parser.ParseAll()
+	stdlibPath := filepath.Join(cwd, filename)
+
+	// Check if file exists
+	if _, err := os.Stat(stdlibPath); os.IsNotExist(err) {
+		// Try alternative paths
+		for _, path := range []string{
+			"../../" + filename,
+			"../../../" + filename,
+			"./" + filename,
+		} {
+			if _, err := os.Stat(path); err == nil {
+				stdlibPath = path
+				break
+			}
+		}
+	}
+	return stdlibPath, nil
+}
+
+func loadLibraryContent(content string, env *Environment) error {
+	return loadFileContent(content, "", env)
+}
+
+// loadFileContent evaluates the source of file, registering it for coverage
+// when file is named and coverage is being recorded
+func loadFileContent(content, file string, env *Environment) error {
+	reader := env.NewReader(strings.NewReader(content))
+	reader.SetFile(file)
+	coverage := env.coverage()
+	spans := coverage.track(reader)
+	expressions, err := reader.ReadAll()
 	if err != nil {
 		return fmt.Errorf("failed to parse: %v", err)
 	}
+	coverage.addFile(file, content, spans)
 
 	// Evaluate each expression in the standard library
 	for _, expr := range expressions {
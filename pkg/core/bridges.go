@@ -0,0 +1,236 @@
+package core
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Bridges are plugins exposing a vetted subset of a Go standard library
+// package, loaded with (load-plugin "go.strings") and so on. Each function
+// is called with its arguments decoded like Decode and its result encoded
+// like Encode; a trailing error result becomes a Lisp error, and several
+// results become a vector. Runes are passed and returned as one-character
+// strings.
+
+// bridgeFunc is a Go function and the name it has in Lisp
+type bridgeFunc struct {
+	name string
+	fn   any
+}
+
+// bridge is the declaration of a bridge plugin
+type bridge struct {
+	pkg         string
+	description string
+	funcs       []bridgeFunc
+}
+
+var bridges = []bridge{
+	{"strings", "Go strings package", []bridgeFunc{
+		{"contains?", strings.Contains},
+		{"contains-any?", strings.ContainsAny},
+		{"has-prefix?", strings.HasPrefix},
+		{"has-suffix?", strings.HasSuffix},
+		{"equal-fold?", strings.EqualFold},
+		{"index", strings.Index},
+		{"last-index", strings.LastIndex},
+		{"count", strings.Count},
+		{"cut", strings.Cut},
+		{"fields", strings.Fields},
+		{"split", strings.Split},
+		{"split-n", strings.SplitN},
+		{"join", strings.Join},
+		{"repeat", strings.Repeat},
+		{"replace", strings.Replace},
+		{"replace-all", strings.ReplaceAll},
+		{"to-upper", strings.ToUpper},
+		{"to-lower", strings.ToLower},
+		{"trim", strings.Trim},
+		{"trim-space", strings.TrimSpace},
+		{"trim-prefix", strings.TrimPrefix},
+		{"trim-suffix", strings.TrimSuffix},
+		{"trim-left", strings.TrimLeft},
+		{"trim-right", strings.TrimRight},
+	}},
+	{"strconv", "Go strconv package", []bridgeFunc{
+		{"atoi", strconv.Atoi},
+		{"itoa", strconv.Itoa},
+		{"parse-int", strconv.ParseInt},
+		{"parse-float", func(s string) (float64, error) { return strconv.ParseFloat(s, 64) }},
+		{"parse-bool", strconv.ParseBool},
+		{"format-int", strconv.FormatInt},
+		{"quote", strconv.Quote},
+		{"unquote", strconv.Unquote},
+	}},
+	{"filepath", "Go path/filepath package (no file system access)", []bridgeFunc{
+		{"base", filepath.Base},
+		{"dir", filepath.Dir},
+		{"ext", filepath.Ext},
+		{"clean", filepath.Clean},
+		{"join", filepath.Join},
+		{"split", filepath.Split},
+		{"rel", filepath.Rel},
+		{"match?", filepath.Match},
+		{"abs?", filepath.IsAbs},
+		{"to-slash", filepath.ToSlash},
+		{"from-slash", filepath.FromSlash},
+	}},
+	{"url", "Go net/url package", []bridgeFunc{
+		{"parse", parseURL},
+		{"query-escape", url.QueryEscape},
+		{"query-unescape", url.QueryUnescape},
+		{"path-escape", url.PathEscape},
+		{"path-unescape", url.PathUnescape},
+		{"parse-query", func(query string) (map[string][]string, error) { return url.ParseQuery(query) }},
+		{"encode-query", func(values map[string][]string) string { return url.Values(values).Encode() }},
+	}},
+	{"unicode", "Go unicode package", []bridgeFunc{
+		{"letter?", unicode.IsLetter},
+		{"digit?", unicode.IsDigit},
+		{"number?", unicode.IsNumber},
+		{"space?", unicode.IsSpace},
+		{"upper?", unicode.IsUpper},
+		{"lower?", unicode.IsLower},
+		{"punct?", unicode.IsPunct},
+		{"to-upper", unicode.ToUpper},
+		{"to-lower", unicode.ToLower},
+	}},
+}
+
+func init() {
+	for _, b := range bridges {
+		RegisterPlugin("go."+b.pkg, func() *Plugin {
+			return &Plugin{
+				Name:        "go." + b.pkg,
+				Version:     "1.0.0",
+				APIVersion:  PluginAPIVersion,
+				Description: b.description,
+				Pure:        true,
+				Register: func(env *Environment) error {
+					for _, f := range b.funcs {
+						name := b.pkg + "/" + f.name
+						env.Set(Intern(name), bridgeBuiltin(name, f.fn))
+					}
+					return nil
+				},
+			}
+		})
+	}
+}
+
+// urlParts is the result of url/parse
+type urlParts struct {
+	Scheme   string              `lisp:"scheme"`
+	User     string              `lisp:"user,omitempty"`
+	Host     string              `lisp:"host"`
+	Port     string              `lisp:"port,omitempty"`
+	Path     string              `lisp:"path"`
+	Query    map[string][]string `lisp:"query"`
+	Fragment string              `lisp:"fragment,omitempty"`
+}
+
+func parseURL(rawURL string) (urlParts, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return urlParts{}, err
+	}
+	return urlParts{
+		Scheme:   u.Scheme,
+		User:     u.User.Username(),
+		Host:     u.Hostname(),
+		Port:     u.Port(),
+		Path:     u.Path,
+		Query:    u.Query(),
+		Fragment: u.Fragment,
+	}, nil
+}
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	runeType  = reflect.TypeOf(rune(0))
+)
+
+// bridgeBuiltin wraps the Go function fn as the builtin name
+func bridgeBuiltin(name string, fn any) *BuiltinFunction {
+	f := reflect.ValueOf(fn)
+	t := f.Type()
+
+	return &BuiltinFunction{
+		Name: name,
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			fixed := t.NumIn()
+			if t.IsVariadic() {
+				fixed--
+				if len(args) < fixed {
+					return nil, NewArityError("%s expects at least %d arguments, got %d", name, fixed, len(args))
+				}
+			} else if len(args) != fixed {
+				return nil, NewArityError("%s expects %d arguments, got %d", name, fixed, len(args))
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				paramType := t.In(min(i, t.NumIn()-1))
+				if t.IsVariadic() && i >= fixed {
+					paramType = paramType.Elem()
+				}
+				param, err := bridgeArg(arg, paramType, fmt.Sprintf("%s argument %d", name, i+1))
+				if err != nil {
+					return nil, err
+				}
+				in[i] = param
+			}
+
+			out := f.Call(in)
+			if n := len(out); n > 0 && t.Out(n-1) == errorType {
+				if err, _ := out[n-1].Interface().(error); err != nil {
+					return nil, NewRuntimeError("%s: %v", name, err)
+				}
+				out = out[:n-1]
+			}
+
+			results := make([]Value, len(out))
+			for i, result := range out {
+				value, err := bridgeResult(result, name)
+				if err != nil {
+					return nil, err
+				}
+				results[i] = value
+			}
+			switch len(results) {
+			case 0:
+				return Nil{}, nil
+			case 1:
+				return results[0], nil
+			}
+			return NewVector(results...), nil
+		},
+	}
+}
+
+// bridgeArg converts a Lisp argument to the Go parameter type t
+func bridgeArg(arg Value, t reflect.Type, path string) (reflect.Value, error) {
+	param := reflect.New(t).Elem()
+	if text, ok := arg.(String); ok && t == runeType {
+		runes := []rune(string(text))
+		if len(runes) != 1 {
+			return param, NewTypeError("decoding %s: expected a single character, got %s", path, arg)
+		}
+		param.SetInt(int64(runes[0]))
+		return param, nil
+	}
+	return param, decodeValue(arg, param, path)
+}
+
+// bridgeResult converts a Go result to a Lisp value
+func bridgeResult(result reflect.Value, name string) (Value, error) {
+	if result.Type() == runeType {
+		return String(string(rune(result.Int()))), nil
+	}
+	return encodeValue(result, name)
+}
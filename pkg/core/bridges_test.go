@@ -0,0 +1,67 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestBridges(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	eval := func(input string) (core.Value, error) {
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Read error for '%s': %v", input, err)
+		}
+		return core.Eval(expr, env)
+	}
+
+	// Bridges are opt-in
+	if _, err := eval(`(strings/has-prefix? "golisp" "go")`); err == nil {
+		t.Fatal("Expected bridge functions to be undefined before loading the bridge")
+	}
+	for _, name := range []string{"go.strings", "go.strconv", "go.filepath", "go.url", "go.unicode"} {
+		if _, err := eval(`(load-plugin "` + name + `")`); err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+	}
+
+	for input, expected := range map[string]string{
+		`(strings/has-prefix? "golisp" "go")`:                "true",
+		`(strings/contains? "golisp" "x")`:                   "nil",
+		`(strings/fields " a  b ")`:                          `["a" "b"]`,
+		`(strings/join (list "a" "b") "-")`:                  `"a-b"`,
+		`(strings/cut "k=v" "=")`:                            `["k" "v" true]`,
+		`(strconv/parse-int "ff" 16 64)`:                     "255",
+		`(strconv/quote "hi")`:                               `"\"hi\""`,
+		`(filepath/join "a" "b" "c.txt")`:                    `"a/b/c.txt"`,
+		`(filepath/ext "notes.md")`:                          `".md"`,
+		"(filepath/join)":                                    `""`,
+		`(url/query-escape "a b&c")`:                         `"a+b%26c"`,
+		`(url/parse-query "x=1&x=2")`:                        `{"x" ["1" "2"]}`,
+		`(url/encode-query (hash-map "q" (list "go lisp")))`: `"q=go+lisp"`,
+		`(:host (url/parse "https://example.com:8080/a"))`:   `"example.com"`,
+		`(unicode/letter? "a")`:                              "true",
+		`(unicode/to-upper "ä")`:                             `"Ä"`,
+	} {
+		result, err := eval(input)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		if result.String() != expected {
+			t.Errorf("Expected %s for '%s', got %s", expected, input, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(strconv/atoi "x")`:       `strconv/atoi: strconv.Atoi: parsing "x": invalid syntax`,
+		`(strings/repeat "a")`:     "strings/repeat expects 2 arguments, got 1",
+		`(strings/repeat "a" "b")`: "decoding strings/repeat argument 2: expected an integer",
+		`(unicode/digit? "12")`:    "expected a single character",
+	} {
+		if _, err := eval(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for '%s', got: %v", message, input, err)
+		}
+	}
+}
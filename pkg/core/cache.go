@@ -0,0 +1,281 @@
+package core
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Cache is a thread-safe cache made with lru-cache or ttl-cache, and the
+// store behind memoize. When several goroutines miss the same key at once,
+// only the first computes the value; the others wait for its result.
+type Cache struct {
+	*memoCache
+	kind     string
+	flightMu sync.Mutex
+	inflight valueKeyed[*cacheCall]
+	hits     int64
+	misses   int64
+}
+
+// cacheCall is a value being computed for a key
+type cacheCall struct {
+	done  chan struct{}
+	value Value
+	err   error
+}
+
+func newCache(kind string, maxSize int, ttl time.Duration) *Cache {
+	return &Cache{
+		memoCache: &memoCache{maxSize: maxSize, ttl: ttl, entries: make(valueKeyed[*list.Element]), order: list.New(), now: time.Now},
+		kind:      kind,
+		inflight:  make(valueKeyed[*cacheCall]),
+	}
+}
+
+func (c *Cache) String() string {
+	return fmt.Sprintf("#<%s-cache %d entries>", c.kind, c.size())
+}
+
+// lookup returns a cached value, counting the hit or miss
+func (c *Cache) lookup(key Value) (Value, bool) {
+	value, ok := c.get(key)
+	c.mu.Lock()
+	if ok {
+		c.hits++
+	} else {
+		c.misses++
+	}
+	c.mu.Unlock()
+	return value, ok
+}
+
+// fetch returns the cached value for key, or computes and caches it. Errors
+// are returned to every waiting caller but not cached.
+func (c *Cache) fetch(key Value, compute func() (Value, error)) (Value, error) {
+	if value, ok := c.lookup(key); ok {
+		return value, nil
+	}
+
+	c.flightMu.Lock()
+	if call, ok := c.inflight.get(key); ok {
+		c.flightMu.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight.set(key, call)
+	c.flightMu.Unlock()
+
+	defer func() {
+		c.flightMu.Lock()
+		c.inflight.delete(key)
+		c.flightMu.Unlock()
+		close(call.done)
+	}()
+	call.value, call.err = compute()
+	if call.err == nil {
+		c.put(key, call.value)
+	}
+	return call.value, call.err
+}
+
+func (c *Cache) evict(key Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries.get(key); ok {
+		c.order.Remove(elem)
+		c.entries.delete(key)
+	}
+}
+
+func (c *Cache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(valueKeyed[*list.Element])
+	c.order.Init()
+}
+
+// size counts the entries, dropping any that have expired
+func (c *Cache) size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ttl > 0 {
+		for elem := c.order.Back(); elem != nil; {
+			prev := elem.Prev()
+			if entry := elem.Value.(*memoEntry); c.now().Sub(entry.created) >= c.ttl {
+				c.order.Remove(elem)
+				c.entries.delete(entry.key)
+			}
+			elem = prev
+		}
+	}
+	return c.order.Len()
+}
+
+func cacheArg(name string, value Value) (*Cache, error) {
+	cache, ok := value.(*Cache)
+	if !ok {
+		return nil, NewTypeError("%s expects a cache, got %s", name, TypeName(value))
+	}
+	return cache, nil
+}
+
+// positiveIntArg reads a positive integer argument
+func positiveIntArg(name, what string, value Value) (int64, error) {
+	n, ok := value.(Number)
+	if !ok || !n.IsInteger() || n.ToInt() < 1 {
+		return 0, NewTypeError("%s expects a positive %s, got %s", name, what, printString(value))
+	}
+	return n.ToInt(), nil
+}
+
+// setupCacheOperations adds lru-cache, ttl-cache, cache-get, cache-put!,
+// cache-evict!, cache-clear!, cache-count and cache-stats
+func setupCacheOperations(env *Environment) {
+	// (lru-cache 100) keeps the 100 most recently used entries, and
+	// (lru-cache 100 60000) also expires them after a minute
+	env.Set(Intern("lru-cache"), &BuiltinFunction{
+		Name: "lru-cache",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("lru-cache expects 1 or 2 arguments, got %d", len(args))
+			}
+			size, err := positiveIntArg("lru-cache", "size", args[0])
+			if err != nil {
+				return nil, err
+			}
+			var ttl int64
+			if len(args) == 2 {
+				if ttl, err = positiveIntArg("lru-cache", "time to live in milliseconds", args[1]); err != nil {
+					return nil, err
+				}
+			}
+			return newCache("lru", int(size), time.Duration(ttl)*time.Millisecond), nil
+		},
+	})
+
+	// (ttl-cache 60000) expires entries a minute after they were stored
+	env.Set(Intern("ttl-cache"), &BuiltinFunction{
+		Name: "ttl-cache",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("ttl-cache expects 1 argument, got %d", len(args))
+			}
+			ttl, err := positiveIntArg("ttl-cache", "time to live in milliseconds", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return newCache("ttl", 0, time.Duration(ttl)*time.Millisecond), nil
+		},
+	})
+
+	// (cache-get c k) is the cached value or nil; (cache-get c k f) calls
+	// (f k) on a miss and caches the result
+	env.Set(Intern("cache-get"), &BuiltinFunction{
+		Name: "cache-get",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return nil, NewArityError("cache-get expects 2 or 3 arguments, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-get", args[0])
+			if err != nil {
+				return nil, err
+			}
+			key := args[1]
+			if len(args) == 2 {
+				if value, ok := cache.lookup(key); ok {
+					return value, nil
+				}
+				return Nil{}, nil
+			}
+			if _, ok := args[2].(Callable); !ok {
+				return nil, NewTypeError("cache-get expects a function to compute missing values, got %s", TypeName(args[2]))
+			}
+			return cache.fetch(key, func() (Value, error) {
+				return callFunction(args[2], args[1:2], env)
+			})
+		},
+	})
+
+	env.Set(Intern("cache-put!"), &BuiltinFunction{
+		Name: "cache-put!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("cache-put! expects 3 arguments, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-put!", args[0])
+			if err != nil {
+				return nil, err
+			}
+			cache.put(args[1], args[2])
+			return args[2], nil
+		},
+	})
+
+	env.Set(Intern("cache-evict!"), &BuiltinFunction{
+		Name: "cache-evict!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("cache-evict! expects 2 arguments, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-evict!", args[0])
+			if err != nil {
+				return nil, err
+			}
+			cache.evict(args[1])
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("cache-clear!"), &BuiltinFunction{
+		Name: "cache-clear!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("cache-clear! expects 1 argument, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-clear!", args[0])
+			if err != nil {
+				return nil, err
+			}
+			cache.clear()
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("cache-count"), &BuiltinFunction{
+		Name: "cache-count",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("cache-count expects 1 argument, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-count", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(cache.size())), nil
+		},
+	})
+
+	env.Set(Intern("cache-stats"), &BuiltinFunction{
+		Name: "cache-stats",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("cache-stats expects 1 argument, got %d", len(args))
+			}
+			cache, err := cacheArg("cache-stats", args[0])
+			if err != nil {
+				return nil, err
+			}
+			size := cache.size()
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+			return NewHashMapWithPairs(
+				InternKeyword("hits"), NewNumber(cache.hits),
+				InternKeyword("misses"), NewNumber(cache.misses),
+				InternKeyword("count"), NewNumber(int64(size)),
+			), nil
+		},
+	})
+}
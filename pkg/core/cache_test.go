@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCaches(t *testing.T) {
+	env, calls := countingEnv()
+	evalAll(t, env, `
+		(def lru (lru-cache 2))
+		(cache-put! lru :a 1)
+		(cache-put! lru :b 2)
+		(cache-get lru :a)
+		(cache-put! lru :c 3)`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// :b was least recently used when :c arrived
+		{`(list (cache-get lru :a) (cache-get lru :b) (cache-get lru :c))`, `(1 nil 3)`},
+		{`(cache-count lru)`, `2`},
+		{`lru`, `#<lru-cache 2 entries>`},
+		{`(cache-get lru :d (fn [k] (tick (str k "!"))))`, `":d!"`},
+		{`(cache-get lru :d (fn [k] (tick "again")))`, `":d!"`},
+		{`(do (cache-evict! lru :d) (cache-get lru :d))`, `nil`},
+		{`(do (cache-clear! lru) (cache-count lru))`, `0`},
+		{`(cache-stats lru)`, `{:hits 4 :misses 3 :count 0}`},
+
+		// Keys are compared by value like hash-map keys, so "1" and 1 differ
+		{`(let [c (ttl-cache 60000)] (cache-put! c 1 :int) (cache-put! c "1" :str) (list (cache-get c 1) (cache-get c "1")))`, `(:int :str)`},
+
+		// and two atoms that print alike are different keys, while equal
+		// collections are the same key however they print
+		{`(let [c (lru-cache 10) a (atom 1) b (atom 1)] (cache-put! c a :a) (cache-put! c b :b) (list (cache-get c a) (cache-get c b) (cache-count c)))`, `(:a :b 2)`},
+		{`(let [c (lru-cache 10)] (cache-put! c [1 2] :v) (cache-evict! c (list 1 2)) (cache-count c))`, `0`},
+
+		// memoize can share a cache, which then also holds its results
+		{`(let [c (lru-cache 10) sq (memoize (fn [x] (tick (* x x))) :cache c)] (list (sq 3) (sq 3) (cache-count c)))`, `(9 9 1)`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 computed values, got %d", *calls)
+	}
+
+	for input, message := range map[string]string{
+		`(lru-cache 0)`:                           "positive size",
+		`(ttl-cache "1m")`:                        "positive time to live",
+		`(cache-get 1 :k)`:                        "expects a cache",
+		`(cache-get (lru-cache 1) :k 5)`:          "function to compute",
+		`(memoize + :cache 5)`:                    "expects a cache",
+		`(memoize + :cache (lru-cache 1) :ttl 5)`: "cannot be combined",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	cache := newCache("ttl", 0, 100*time.Millisecond)
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.put(String("a"), NewNumber(int64(1)))
+	now = now.Add(50 * time.Millisecond)
+	cache.put(String("b"), NewNumber(int64(2)))
+	now = now.Add(60 * time.Millisecond)
+	if size := cache.size(); size != 1 {
+		t.Errorf("Expected the older entry to have expired, got %d entries", size)
+	}
+	if _, ok := cache.get(String("b")); !ok {
+		t.Error("Expected the newer entry to remain")
+	}
+}
+
+func TestCacheStampedeProtection(t *testing.T) {
+	cache := newCache("lru", 10, 0)
+	var computed int32
+	release := make(chan struct{})
+	compute := func() (Value, error) {
+		atomic.AddInt32(&computed, 1)
+		<-release
+		return String("value"), nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]Value, 20)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = cache.fetch(String("key"), compute)
+		}(i)
+	}
+	// Let every goroutine reach the cache before the first computation ends
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if computed != 1 {
+		t.Errorf("Expected one computation for concurrent misses, got %d", computed)
+	}
+	for i, result := range results {
+		if result != String("value") {
+			t.Errorf("Caller %d got %v", i, result)
+		}
+	}
+
+	// Failed computations reach every waiter but are not cached
+	if _, err := cache.fetch(String("bad"), func() (Value, error) { return nil, fmt.Errorf("failed") }); err == nil {
+		t.Error("Expected the error to be returned")
+	}
+	if value, err := cache.fetch(String("bad"), func() (Value, error) { return String("ok"), nil }); err != nil || value != String("ok") {
+		t.Errorf("Expected a retry after an error, got %v, %v", value, err)
+	}
+}
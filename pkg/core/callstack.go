@@ -0,0 +1,180 @@
+package core
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultMaxCallDepth is how deeply user functions may call each other
+// before evaluation fails, well before the Go stack would overflow
+const DefaultMaxCallDepth = 10000
+
+// SetMaxCallDepth changes how deeply the interpreter's user functions may
+// recurse, for calls made from outside any other call from then on
+func (env *Environment) SetMaxCallDepth(depth int) {
+	atomic.StoreInt64(&env.interpreterState().maxCallDepth, int64(depth))
+}
+
+// maxCallDepth returns the interpreter's limit on the depth of calls
+func (env *Environment) maxCallDepth() int64 {
+	if env == nil {
+		return DefaultMaxCallDepth
+	}
+	if depth := atomic.LoadInt64(&env.interpreterState().maxCallDepth); depth != 0 {
+		return depth
+	}
+	return DefaultMaxCallDepth
+}
+
+// callFrame is one active user function call. Environments created while
+// the call runs point at it, so a call made from any of them knows its
+// caller
+type callFrame struct {
+	name     string
+	depth    int
+	caller   *callFrame
+	deadline *deadline        // Set under with-timeout, inherited by callees
+	dynamic  *dynamicBindings // Set under binding, inherited by callees
+	gensyms  *gensymCounter   // Set under with-gensym-seed, inherited by callees
+	forcing  *Delay           // The delay whose expression this frame evaluates
+	maxDepth int64            // Read by the outermost call, inherited by callees
+
+	transaction *transaction // Set by dosync, inherited by callees
+	coroutine   *coroutine   // Set in a generator body, inherited by callees
+}
+
+// dynamicBindings are the values given to top-level names by binding, which
+// hide the root values for everything called within it. Environments capture
+// them with their call frame, so closures run later, e.g. by scheduled jobs,
+// see the bindings in effect when they were created.
+type dynamicBindings struct {
+	values map[Symbol]Value
+	parent *dynamicBindings
+}
+
+// lookup finds the innermost binding of sym
+func (d *dynamicBindings) lookup(sym Symbol) (Value, bool) {
+	for ; d != nil; d = d.parent {
+		if value, ok := d.values[sym]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// withBindings returns a child of env in which values hide the root
+// bindings of their names
+func withBindings(env *Environment, values map[Symbol]Value) *Environment {
+	frame := nestedFrame(env, "binding")
+	frame.dynamic = &dynamicBindings{values: values, parent: frame.dynamic}
+	bound := NewEnvironment(env)
+	bound.calls = frame
+	return bound
+}
+
+// withDynamic returns a child of env that sees dynamic instead of the
+// bindings in effect in env, for bound-fn
+func withDynamic(env *Environment, dynamic *dynamicBindings) *Environment {
+	frame := nestedFrame(env, "bound-fn")
+	frame.dynamic = dynamic
+	bound := NewEnvironment(env)
+	bound.calls = frame
+	return bound
+}
+
+// nestedFrame returns a frame at the same depth as env's, inheriting its
+// deadline, dynamic bindings, gensym counter, depth limit, transaction and
+// generator
+func nestedFrame(env *Environment, name string) *callFrame {
+	frame := &callFrame{name: name}
+	if caller := env.calls; caller != nil {
+		frame.depth = caller.depth
+		frame.caller = caller
+		frame.deadline = caller.deadline
+		frame.dynamic = caller.dynamic
+		frame.gensyms = caller.gensyms
+		frame.maxDepth = caller.maxDepth
+		frame.transaction = caller.transaction
+		frame.coroutine = caller.coroutine
+	}
+	return frame
+}
+
+// deadline is when code run by with-timeout must stop. Evaluation checks it
+// at every function call and loop iteration, so a timed out body fails with
+// an error instead of being killed.
+type deadline struct {
+	at      time.Time
+	timeout time.Duration
+}
+
+// checkDeadline returns an error when env is running past its deadline
+func checkDeadline(env *Environment) error {
+	if env == nil || env.calls == nil || env.calls.deadline == nil {
+		return nil
+	}
+	if d := env.calls.deadline; time.Now().After(d.at) {
+		return NewRuntimeError("timed out after %s", d.timeout)
+	}
+	return nil
+}
+
+// withDeadline returns a child of env whose calls must finish within timeout
+// (or the deadline env is already under, if that is sooner)
+func withDeadline(env *Environment, timeout time.Duration) *Environment {
+	frame := nestedFrame(env, "with-timeout")
+	limit := &deadline{at: time.Now().Add(timeout), timeout: timeout}
+	if frame.deadline == nil || limit.at.Before(frame.deadline.at) {
+		frame.deadline = limit
+	}
+	limited := NewEnvironment(env)
+	limited.calls = frame
+	return limited
+}
+
+// withGensymSeed returns a child of env in which gensyms are numbered from
+// seed by a counter of their own, for with-gensym-seed
+func withGensymSeed(env *Environment, seed int64) *Environment {
+	frame := nestedFrame(env, "with-gensym-seed")
+	frame.gensyms = &gensymCounter{last: seed - 1}
+	seeded := NewEnvironment(env)
+	seeded.calls = frame
+	return seeded
+}
+
+// stackFramesShown is how many of the innermost calls a recursion error lists
+const stackFramesShown = 10
+
+// enterCall returns the frame for calling name from env, or an error when
+// that would exceed the maximum call depth
+func enterCall(name string, env *Environment) (*callFrame, error) {
+	var caller *callFrame
+	if env != nil {
+		caller = env.calls
+	}
+	frame := &callFrame{name: name, depth: 1, caller: caller}
+	if caller != nil {
+		frame.depth = caller.depth + 1
+		frame.deadline = caller.deadline
+		frame.dynamic = caller.dynamic
+		frame.gensyms = caller.gensyms
+		frame.maxDepth = caller.maxDepth
+		frame.transaction = caller.transaction
+		frame.coroutine = caller.coroutine
+		if err := checkDeadline(env); err != nil {
+			return nil, err
+		}
+	}
+	// Frames made outside any call, like binding's, leave the limit unread
+	if frame.maxDepth == 0 {
+		frame.maxDepth = env.maxCallDepth()
+	}
+	if limit := frame.maxDepth; int64(frame.depth) > limit {
+		err := NewRuntimeError("maximum recursion depth exceeded (%d)", limit)
+		for f := caller; f != nil && len(err.StackTrace) < stackFramesShown; f = f.caller {
+			err.StackTrace = append(err.StackTrace, StackFrame{Function: f.name})
+		}
+		return nil, err
+	}
+	return frame, nil
+}
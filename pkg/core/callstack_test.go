@@ -0,0 +1,71 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMaxCallDepth(t *testing.T) {
+	env := NewCoreEnvironment()
+	env.SetMaxCallDepth(200)
+	evalAll(t, env, `
+		(defn depth [n] (if (= n 0) 0 (+ 1 (depth (- n 1)))))
+		(defn countdown [n] (if (= n 0) :done (recur (- n 1))))
+		(def again (fn [n] (again n)))`)
+
+	if result := evalAll(t, env, "(depth 150)"); result.String() != "150" {
+		t.Errorf("Expected recursion within the limit to work, got %s", result)
+	}
+	if result := evalAll(t, env, "(countdown 5000)"); result.String() != ":done" {
+		t.Errorf("Expected recur not to count towards the limit, got %s", result)
+	}
+
+	for input, frame := range map[string]string{
+		"(depth 500)":         "at depth",
+		"(again 1)":           "at anonymous",
+		"(apply depth [300])": "at depth",
+	} {
+		expr, _ := ReadString(input)
+		_, err := Eval(expr, env)
+		if err == nil || !strings.Contains(err.Error(), "maximum recursion depth exceeded (200)") {
+			t.Fatalf("Expected a recursion error for '%s', got: %v", input, err)
+		}
+		if !strings.Contains(err.Error(), frame) || strings.Count(err.Error(), "\n  at ") > stackFramesShown {
+			t.Errorf("Expected at most %d frames including '%s' for '%s', got: %v", stackFramesShown, frame, input, err)
+		}
+	}
+
+	// The environment stays usable after the error
+	if result := evalAll(t, env, "(depth 3)"); result.String() != "3" {
+		t.Errorf("Expected evaluation to continue after a recursion error, got %s", result)
+	}
+}
+
+func TestCallWithTimeout(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn spin [n] (recur (+ n 1)))
+		(defn forever [] (forever))`)
+
+	if result := evalAll(t, env, "(call-with-timeout 1000 (fn [] (+ 1 2)))"); result.String() != "3" {
+		t.Errorf("Expected a fast body to return its value, got %s", result)
+	}
+
+	for _, input := range []string{
+		"(call-with-timeout 50 (fn [] (spin 0)))",
+		"(call-with-timeout 50 (fn [] (loop [i 0] (recur (+ i 1)))))",
+		"(call-with-timeout 50 (fn [] (while true nil)))",
+		"(call-with-timeout 1000 (fn [] (call-with-timeout 50 (fn [] (spin 0)))))",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+			t.Errorf("Expected a timeout for '%s', got %v", input, err)
+		}
+	}
+
+	// An outer deadline still applies inside a longer inner one
+	expr, _ := ReadString("(call-with-timeout 50 (fn [] (call-with-timeout 5000 (fn [] (spin 0)))))")
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+		t.Errorf("Expected the outer timeout to apply, got %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+const cliSpecs = `[["-p" "--port PORT" "Port number" :default 8080 :parse-fn int :validate [(fn [p] (< 0 p)) "Must be positive"]]
+                   ["-v" "--verbose" "Verbose output"]
+                   [nil "--name NAME" "Service name"]]`
+
+func TestParseOpts(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"defaults", `(get (parse-opts [] ` + cliSpecs + `) :options)`, "{:port 8080}"},
+		{"short-and-flag", `(get (parse-opts ["-p" "9000" "-v" "file.txt"] ` + cliSpecs + `) :options)`, "{:port 9000 :verbose true}"},
+		{"long-inline", `(get (parse-opts ["--port=81" "--name" "api"] ` + cliSpecs + `) :options)`, `{:port 81 :name "api"}`},
+		{"arguments", `(get (parse-opts ["a" "-v" "--" "-p" "b"] ` + cliSpecs + `) :arguments)`, `["a" "-p" "b"]`},
+		{"no-errors", `(get (parse-opts ["-v"] ` + cliSpecs + `) :errors)`, "nil"},
+		{"unknown", `(get (parse-opts ["-x"] ` + cliSpecs + `) :errors)`, `["Unknown option: \"-x\""]`},
+		{"missing-arg", `(get (parse-opts ["--port"] ` + cliSpecs + `) :errors)`, `["Missing required argument for \"--port PORT\""]`},
+		{"validate", `(get (parse-opts ["-p" "0"] ` + cliSpecs + `) :errors)`, `["Failed to validate \"-p 0\": Must be positive"]`},
+		{"int", `(int "42")`, "42"},
+		{"float", `(float 2)`, "2.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := core.ReadString(test.input)
+			if err != nil {
+				t.Fatalf("Parse error for '%s': %v", test.input, err)
+			}
+
+			result, err := core.Eval(expr, env)
+			if err != nil {
+				t.Fatalf("Eval error for '%s': %v", test.input, err)
+			}
+
+			if result.String() != test.expected {
+				t.Errorf("Expected '%s', got '%s'", test.expected, result.String())
+			}
+		})
+	}
+}
+
+func TestParseOptsSummary(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	expr, err := core.ReadString(`(get (parse-opts [] ` + cliSpecs + `) :summary)`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	result, err := core.Eval(expr, env)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	summary := string(result.(core.String))
+	lines := strings.Split(summary, "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 summary lines, got %q", summary)
+	}
+	if lines[0] != "  -p, --port PORT  8080  Port number" {
+		t.Errorf("Unexpected summary line: %q", lines[0])
+	}
+	if lines[1] != "  -v, --verbose          Verbose output" {
+		t.Errorf("Unexpected summary line: %q", lines[1])
+	}
+}
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"strings"
+	"unicode"
+)
+
+// collator compares strings the way people expect to find them in a sorted
+// list: letters first by their base letter ignoring case and accents, then
+// by accents, then by case with lower case first. Locales move some letters,
+// e.g. Swedish sorts å, ä and ö after z.
+type collator struct {
+	tailoring map[rune][]int // Primary weights of letters a locale moves
+}
+
+// Primary weights are code points spaced out so that tailored letters fit
+// in between, e.g. Spanish ñ right after n
+const weightSpacing = 4
+
+func weight(r rune) int {
+	return int(r) * weightSpacing
+}
+
+// afterZ returns the weights of letters a locale sorts after z, in order,
+// with each alias weighted like the letter it stands for
+func afterZ(letters []rune, aliases map[rune]rune) map[rune][]int {
+	tailoring := make(map[rune][]int)
+	for i, r := range letters {
+		tailoring[r] = []int{weight('z') + i + 1}
+	}
+	for alias, r := range aliases {
+		tailoring[alias] = tailoring[r]
+	}
+	return tailoring
+}
+
+var (
+	swedishOrder   = afterZ([]rune("åäö"), map[rune]rune{'æ': 'ä', 'ø': 'ö'})
+	norwegianOrder = afterZ([]rune("æøå"), map[rune]rune{'ä': 'æ', 'ö': 'ø'})
+)
+
+// localeTailorings are keyed by language code; nil is the default order
+var localeTailorings = map[string]map[rune][]int{
+	"en": nil,
+	"de": nil,
+	"fr": nil,
+	"sv": swedishOrder,
+	"fi": swedishOrder,
+	"da": norwegianOrder,
+	"nb": norwegianOrder,
+	"es": {'ñ': {weight('n') + 1}},
+}
+
+// newCollator returns the collator of a locale like "sv" or "sv-SE"
+func newCollator(locale string) (*collator, bool) {
+	language := strings.ToLower(locale)
+	if i := strings.IndexAny(language, "-_"); i >= 0 {
+		language = language[:i]
+	}
+	if language == "no" || language == "nn" {
+		language = "nb"
+	}
+	tailoring, ok := localeTailorings[language]
+	if !ok {
+		return nil, false
+	}
+	return &collator{tailoring: tailoring}, true
+}
+
+// primaryWeights are the base letters of s, ignoring case and accents
+func (c *collator) primaryWeights(s string) []int {
+	var weights []int
+	for _, r := range normalizeNFC(strings.ToLower(s)) {
+		if tailored, ok := c.tailoring[r]; ok {
+			weights = append(weights, tailored...)
+			continue
+		}
+		if r == 'ß' {
+			weights = append(weights, weight('s'), weight('s'))
+			continue
+		}
+		for _, part := range decomposeRune(nil, r) {
+			if !unicode.Is(unicode.Mn, part) {
+				weights = append(weights, weight(part))
+			}
+		}
+	}
+	return weights
+}
+
+// compare orders a and b by base letters, then accents, then case
+func (c *collator) compare(a, b string) int {
+	if order := c.comparePrimary(a, b); order != 0 {
+		return order
+	}
+	if order := strings.Compare(string(normalizeNFD(strings.ToLower(a))), string(normalizeNFD(strings.ToLower(b)))); order != 0 {
+		return order
+	}
+	return strings.Compare(swapCase(a), swapCase(b))
+}
+
+// comparePrimary orders a and b by base letters only
+func (c *collator) comparePrimary(a, b string) int {
+	return compareWeights(c.primaryWeights(a), c.primaryWeights(b))
+}
+
+func compareWeights(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
+
+// swapCase makes lower case letters sort before upper case ones
+func swapCase(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsUpper(r) {
+			return unicode.ToLower(r)
+		}
+		return unicode.ToUpper(r)
+	}, s)
+}
+
+// naturalCompare orders strings with runs of digits compared as numbers,
+// so "file2" comes before "file10". Text between the numbers is compared
+// by base letters with c, then accents and case decide between otherwise
+// equal strings; without a collator text compares by code point.
+func naturalCompare(a, b string, c *collator) int {
+	textCompare := strings.Compare
+	if c != nil {
+		textCompare = c.comparePrimary
+	}
+
+	chunksA, chunksB := naturalChunks(a), naturalChunks(b)
+	for i := 0; i < len(chunksA) && i < len(chunksB); i++ {
+		x, y := chunksA[i], chunksB[i]
+		xDigits, yDigits := isDigitRun(x), isDigitRun(y)
+		var order int
+		switch {
+		case xDigits && yDigits:
+			order = compareDigitRuns(x, y)
+		case xDigits:
+			order = -1 // Numbers before text
+		case yDigits:
+			order = 1
+		default:
+			order = textCompare(x, y)
+		}
+		if order != 0 {
+			return order
+		}
+	}
+	if order := len(chunksA) - len(chunksB); order != 0 {
+		return order
+	}
+	if c != nil {
+		if order := c.compare(a, b); order != 0 {
+			return order
+		}
+	}
+	// Equal apart from leading zeros, e.g. "a01" and "a1"
+	return strings.Compare(a, b)
+}
+
+// naturalChunks splits s into alternating runs of digits and other text
+func naturalChunks(s string) []string {
+	var chunks []string
+	start := 0
+	for i, r := range s {
+		if i > start && isDigit(r) != isDigitRun(s[start:i]) {
+			chunks = append(chunks, s[start:i])
+			start = i
+		}
+	}
+	if start < len(s) {
+		chunks = append(chunks, s[start:])
+	}
+	return chunks
+}
+
+func isDigitRun(s string) bool {
+	return s != "" && isDigit(rune(s[0]))
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+// compareDigitRuns compares decimal numbers of any length
+func compareDigitRuns(a, b string) int {
+	a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,59 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSortNaturalAndCollation(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def people (list (hash-map :name "Örjan") (hash-map :name "zed") (hash-map :name "Åsa") (hash-map :name "Anna") (hash-map :name "Émile")))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(sort-natural (list "file10" "file2" "File1" "file1"))`, `("file1" "File1" "file2" "file10")`},
+		{`(sort-natural (vector "v1.10" "v1.9" "v1.9.1" "v10"))`, `("v1.9" "v1.9.1" "v1.10" "v10")`},
+		{`(sort-natural (list "a2" "a02" "a1"))`, `("a1" "a02" "a2")`},
+		{`(sort-natural (list "item 99999999999999999999" "item 100000000000000000000"))`, `("item 99999999999999999999" "item 100000000000000000000")`},
+		{`(sort-by count (list "ccc" "a" "bb"))`, `("a" "bb" "ccc")`},
+		{`(sort-by (fn [x] x) (list "b" "a" "B"))`, `("B" "a" "b")`},
+		{`(map-names (sort-by :name people))`, `("Anna" "zed" "Åsa" "Émile" "Örjan")`},
+		{`(map-names (sort-by :name people :locale "en"))`, `("Anna" "Åsa" "Émile" "Örjan" "zed")`},
+		{`(map-names (sort-by :name people :locale "sv-SE"))`, `("Anna" "Émile" "zed" "Åsa" "Örjan")`},
+		{`(sort-by (fn [x] x) (list "pez" "peña" "penz") :locale "es")`, `("penz" "peña" "pez")`},
+		{`(sort-by (fn [x] x) (list "Straße" "Strasse" "Strand") :locale "de")`, `("Strand" "Strasse" "Straße")`},
+		{`(sort-by (fn [x] x) (list "b" "B" "a") :locale "en")`, `("a" "b" "B")`},
+		{`(sort-by :n (list (hash-map :n 2 :k 1) (hash-map :n 1) (hash-map :n 2 :k 2)))`, `({:n 1} {:n 2 :k 1} {:n 2 :k 2})`},
+		{`(sort-by (fn [x] x) (list "x10" "x9") :natural true)`, `("x9" "x10")`},
+	}
+	env.Set(Intern("map-names"), &BuiltinFunction{
+		Name: "map-names",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			items, _ := collectionToSlice(args[0])
+			names := make([]Value, len(items))
+			for i, item := range items {
+				names[i] = item.(*HashMap).Get(Keyword("name"))
+			}
+			return NewList(names...), nil
+		},
+	})
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(sort-by count (list "a") :locale "xx")`: `unsupported locale "xx"`,
+		`(sort-by count (list "a") :order 1)`:     "unknown option :order",
+		`(sort-by count (list "a") :locale)`:      "keyword/value pairs",
+		`(sort-by 1 (list "a"))`:                  "expects a key function",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// Comment is a ; comment kept by a lexer with KeepComments
+type Comment struct {
+	Text     string // As written, from the first ; to the end of the line
+	Position Position
+}
+
+// Content is the text of the comment without its semicolons and the space
+// after them
+func (c Comment) Content() string {
+	return strings.TrimPrefix(strings.TrimLeft(c.Text, ";"), " ")
+}
+
+// FormComments are the comments next to a list in its source: those
+// between the form before it and the list, and the comment after it on its
+// last line
+type FormComments struct {
+	Leading  []Comment
+	Trailing *Comment
+}
+
+// Comments returns the comments the parser attached to the list, or nil
+func (l *List) Comments() *FormComments {
+	if l == nil {
+		return nil
+	}
+	return l.comments
+}
+
+// KeepComments makes the lexer record the comments it skips, for Comments
+func (l *Lexer) KeepComments() {
+	l.comments = &[]Comment{}
+}
+
+// Comments returns the comments seen so far, in source order
+func (l *Lexer) Comments() []Comment {
+	if l.comments == nil {
+		return nil
+	}
+	return *l.comments
+}
+
+// AttachComments gives the parser the comments of its source, from
+// Lexer.Comments, to attach to the lists it parses. Formatters and doc tools
+// use them to keep comments that the values themselves cannot hold.
+// Comments next to other forms, like symbols or vectors, are not attached.
+func (p *Parser) AttachComments(comments []Comment) {
+	p.comments = comments
+}
+
+// commentsAround finds the comments of the list between the tokens open and
+// close. Leading comments start on a line after the token before the list,
+// since a comment on that token's line follows it rather than the list.
+func (p *Parser) commentsAround(open, close int) *FormComments {
+	if len(p.comments) == 0 {
+		return nil
+	}
+	first, last := p.tokens[open].Position, p.tokens[close].Position
+	after := func(offset int) int {
+		return sort.Search(len(p.comments), func(i int) bool { return p.comments[i].Position.Offset > offset })
+	}
+
+	var result FormComments
+	i := 0
+	if open > 0 {
+		previous := p.tokens[open-1].Position
+		i = after(previous.Offset)
+		for i < len(p.comments) && p.comments[i].Position.Line == previous.Line {
+			i++
+		}
+	}
+	for ; i < len(p.comments) && p.comments[i].Position.Offset < first.Offset; i++ {
+		result.Leading = append(result.Leading, p.comments[i])
+	}
+
+	if i = after(last.Offset); i < len(p.comments) && p.comments[i].Position.Line == last.Line {
+		if next := p.tokens[close+1]; next.Type == TokenEOF || p.comments[i].Position.Offset < next.Position.Offset {
+			result.Trailing = &p.comments[i]
+		}
+	}
+
+	if result.Leading == nil && result.Trailing == nil {
+		return nil
+	}
+	return &result
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ValueToJSON encodes a Lisp value as JSON. Keywords and symbols become
+// strings, sets and lists become arrays, and nil becomes null.
+func ValueToJSON(value Value) ([]byte, error) {
+	return json.Marshal(valueToGo(value))
+}
+
+// JSONToValue decodes JSON into Lisp data: objects become hash-maps with
+// keyword keys, arrays become vectors, true becomes true and false and null
+// become nil
+func JSONToValue(data []byte) (Value, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var v any
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after JSON value")
+	}
+	return goToValue(v), nil
+}
+
+// goToValue converts data decoded by encoding/json into Lisp data
+func goToValue(v any) Value {
+	switch v := v.(type) {
+	case nil:
+		return Nil{}
+	case bool:
+		if v {
+			return Symbol("true")
+		}
+		return Nil{}
+	case string:
+		return String(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return NewNumber(n)
+		}
+		f, _ := v.Float64()
+		return NewNumber(f)
+	case []any:
+		items := make([]Value, len(v))
+		for i, item := range v {
+			items[i] = goToValue(item)
+		}
+		return NewVector(items...)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys) // So maps print the same way every time
+		m := NewHashMap()
+		for _, key := range keys {
+			m.Set(InternKeyword(key), goToValue(v[key]))
+		}
+		return m
+	}
+	return String(fmt.Sprint(v))
+}
+
+// valueToGo converts a Lisp value into plain Go data (maps, slices, strings,
+// numbers, bools and nil) suitable for encoding/json and similar encoders
+func valueToGo(value Value) any {
+	switch v := value.(type) {
+	case nil, Nil:
+		return nil
+	case Number:
+		return v.Value
+	case String:
+		return string(v)
+	case Keyword:
+		return string(v)
+	case Symbol:
+		// Unevaluated literals may still hold the symbols true, false and nil
+		switch v {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "nil":
+			return nil
+		}
+		return string(v)
+	case *List:
+		result := make([]any, 0)
+		for _, elem := range listToSlice(v) {
+			result = append(result, valueToGo(elem))
+		}
+		return result
+	case *LazySeq:
+		items, _ := collectionToSlice(v)
+		result := make([]any, 0, len(items))
+		for _, elem := range items {
+			result = append(result, valueToGo(elem))
+		}
+		return result
+	case *Vector:
+		result := make([]any, 0, v.Count())
+		for _, elem := range v.elements {
+			result = append(result, valueToGo(elem))
+		}
+		return result
+	case *Set:
+		result := make([]any, 0, v.Count())
+		for _, elem := range v.order {
+			result = append(result, valueToGo(elem))
+		}
+		return result
+	case *HashMap:
+		result := make(map[string]any, v.Count())
+		for _, key := range v.keys {
+			result[mapKeyToString(key)] = valueToGo(v.Get(key))
+		}
+		return result
+	default:
+		return value.String()
+	}
+}
+
+// mapKeyToString renders a map key as a plain string (keywords lose their colon)
+func mapKeyToString(key Value) string {
+	switch k := key.(type) {
+	case Keyword:
+		return string(k)
+	case String:
+		return string(k)
+	case Symbol:
+		return string(k)
+	default:
+		return fmt.Sprint(valueToGo(key))
+	}
+}
@@ -0,0 +1,85 @@
+package core_test
+
+import (
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestValueToJSON(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"42", "42"},
+		{"2.5", "2.5"},
+		{`"hello"`, `"hello"`},
+		{":status", `"status"`},
+		{"nil", "null"},
+		{"true", "true"},
+		{"(list 1 2 3)", "[1,2,3]"},
+		{"[1 :a \"b\"]", `[1,"a","b"]`},
+		{"#{1}", "[1]"},
+		{"(hash-map :name \"api\" :ports (vector 80 443))", `{"name":"api","ports":[80,443]}`},
+		{"{:missing nil :flag false}", `{"flag":false,"missing":null}`},
+	}
+
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Errorf("Parse error for '%s': %v", test.input, err)
+			continue
+		}
+
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+
+		data, err := core.ValueToJSON(result)
+		if err != nil {
+			t.Errorf("ValueToJSON error for '%s': %v", test.input, err)
+			continue
+		}
+
+		if string(data) != test.expected {
+			t.Errorf("Expected %s for input '%s', got %s", test.expected, test.input, data)
+		}
+	}
+}
+
+func TestJSONToValue(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"42", "42"},
+		{"2.5", "2.5"},
+		{`"hello"`, `"hello"`},
+		{"null", "nil"},
+		{"true", "true"},
+		{"false", "nil"},
+		{`[1, "a", [2]]`, `[1 "a" [2]]`},
+		{`{"name": "api", "ports": [80, 443]}`, `{:name "api" :ports [80 443]}`},
+	}
+
+	for _, test := range tests {
+		value, err := core.JSONToValue([]byte(test.input))
+		if err != nil {
+			t.Errorf("JSONToValue(%s) failed: %v", test.input, err)
+			continue
+		}
+		if value.String() != test.expected {
+			t.Errorf("JSONToValue(%s): expected %s, got %s", test.input, test.expected, value)
+		}
+	}
+
+	for _, input := range []string{`{"a":`, `1 2`, ``} {
+		if _, err := core.JSONToValue([]byte(input)); err == nil {
+			t.Errorf("Expected JSONToValue(%q) to fail", input)
+		}
+	}
+}
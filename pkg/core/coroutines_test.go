@@ -0,0 +1,76 @@
+package core
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerators(t *testing.T) {
+	env, calls := countingEnv()
+	evalAll(t, env, `
+		(defn counting-from [n]
+		  (generator (loop [i n] (yield (tick i)) (recur (+ i 1)))))`)
+
+	tests := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		{"(generator (yield 1) (yield 2) (yield-from [3 4]) (yield 5))", "(1 2 3 4 5)", 0},
+		{"(generator)", "()", 0},
+		{"(def nums (counting-from 10))", "nums", 0},
+		{"(first nums)", "10", 1},
+		{"(nth nums 3)", "13", 4},
+		{"(nth nums 3)", "13", 4},
+		{"(first (rest nums))", "11", 4},
+		{"(for [x (generator (yield-from (generator (yield :a) (yield :b))))] (list x))", "((:a) (:b))", 4},
+		{"(count (generator (dotimes [i 4] (yield i))))", "4", 4},
+
+		// Functions called from the body yield for it
+		{"(defn emit-twice [x] (yield x) (yield x))", "emit-twice", 4},
+		{"(generator (emit-twice 1) (emit-twice 2))", "(1 1 2 2)", 4},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+		if *calls != test.calls {
+			t.Errorf("After '%s', expected the producer to have run %d steps, got %d", test.input, test.calls, *calls)
+		}
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(yield 1)":        "yield called outside of a generator",
+		"(yield-from [1])": "yield-from called outside of a generator",
+		"(emit-twice 1)":   "yield called outside of a generator",
+		"(force (first (generator (yield (delay (yield 1))))))": "yield called outside of a generator",
+		`(count (generator (yield 1) (throw "boom")))`:          "boom",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestAbandonedGeneratorStops(t *testing.T) {
+	env := NewCoreEnvironment()
+	before := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		expr, _ := ReadString("(first (generator (loop [i 0] (yield i) (recur (+ i 1)))))")
+		if _, err := Eval(expr, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected abandoned generators to stop, %d goroutines left over", after-before)
+	}
+}
@@ -0,0 +1,182 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Coverage records which source forms of loaded files were evaluated
+type Coverage struct {
+	mu    sync.Mutex
+	forms map[*List]*coveredForm
+	files map[string]*fileCoverage
+}
+
+// coveredForm is one list form in a source file and how often it ran
+type coveredForm struct {
+	span SourceSpan
+	hits int
+}
+
+// fileCoverage holds the forms of one file, keyed by start offset so that
+// loading the same file again shares the counts
+type fileCoverage struct {
+	source   string
+	byOffset map[int]*coveredForm
+}
+
+// recordingCoverage counts the interpreters recording coverage, so that
+// evaluation only looks for a recorder while some interpreter has one
+var recordingCoverage atomic.Int64
+
+// NewCoverage creates a recorder for interpreters to record coverage in
+func NewCoverage() *Coverage {
+	return &Coverage{
+		forms: make(map[*List]*coveredForm),
+		files: make(map[string]*fileCoverage),
+	}
+}
+
+// RecordCoverage makes the interpreter record the files it loads from now
+// on, and which of their forms it evaluates, in c. Several interpreters may
+// share a recorder; nil stops recording.
+func (env *Environment) RecordCoverage(c *Coverage) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	switch {
+	case state.coverage == nil && c != nil:
+		recordingCoverage.Add(1)
+	case state.coverage != nil && c == nil:
+		recordingCoverage.Add(-1)
+	}
+	state.coverage = c
+}
+
+// coverage returns the interpreter's recorder, or nil when coverage is off
+func (env *Environment) coverage() *Coverage {
+	if recordingCoverage.Load() == 0 {
+		return nil
+	}
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.coverage
+}
+
+// track asks reader to record spans if coverage is on; safe on a nil Coverage
+func (c *Coverage) track(reader *Reader) map[*List]SourceSpan {
+	if c == nil {
+		return nil
+	}
+	return reader.RecordSpans()
+}
+
+// addFile registers the forms parsed from file; safe on a nil Coverage
+func (c *Coverage) addFile(file, source string, spans map[*List]SourceSpan) {
+	if c == nil || file == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fc, exists := c.files[file]
+	if !exists {
+		fc = &fileCoverage{source: source, byOffset: make(map[int]*coveredForm)}
+		c.files[file] = fc
+	}
+	for list, span := range spans {
+		form, exists := fc.byOffset[span.Start.Offset]
+		if !exists {
+			form = &coveredForm{span: span}
+			fc.byOffset[span.Start.Offset] = form
+		}
+		c.forms[list] = form
+	}
+}
+
+// hit counts an evaluation of list if it came from a covered file
+func (c *Coverage) hit(list *List) {
+	c.mu.Lock()
+	if form, ok := c.forms[list]; ok {
+		form.hits++
+	}
+	c.mu.Unlock()
+}
+
+// Files returns the covered files in sorted order
+func (c *Coverage) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make([]string, 0, len(c.files))
+	for file := range c.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// FileSummary returns how many of file's forms were evaluated, out of all of them
+func (c *Coverage) FileSummary(file string) (covered, total int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fc, ok := c.files[file]
+	if !ok {
+		return 0, 0
+	}
+	for _, form := range fc.byOffset {
+		total++
+		if form.hits > 0 {
+			covered++
+		}
+	}
+	return covered, total
+}
+
+// Annotate writes file's source with a gcov-style prefix on each line: the
+// hit count of the forms starting there, ##### if any of them never ran,
+// or - if no form starts on the line
+func (c *Coverage) Annotate(out io.Writer, file string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fc, ok := c.files[file]
+	if !ok {
+		return
+	}
+
+	// Lowest hit count among the forms starting on each line
+	lineHits := make(map[int]int)
+	for _, form := range fc.byOffset {
+		line := form.span.Start.Line
+		if hits, seen := lineHits[line]; !seen || form.hits < hits {
+			lineHits[line] = form.hits
+		}
+	}
+
+	for i, text := range strings.Split(strings.TrimSuffix(fc.source, "\n"), "\n") {
+		hits, hasForms := lineHits[i+1]
+		switch {
+		case !hasForms:
+			fmt.Fprintf(out, "%9s: %s\n", "-", text)
+		case hits == 0:
+			fmt.Fprintf(out, "%9s: %s\n", "#####", text)
+		default:
+			fmt.Fprintf(out, "%9d: %s\n", hits, text)
+		}
+	}
+}
+
+// CoveragePercent formats covered/total as a percentage
+func CoveragePercent(covered, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(covered)/float64(total))
+}
@@ -0,0 +1,68 @@
+package core_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestCoverage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "calc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := `(defn sign [n]
+  (if (< n 0)
+      (quote (negative))
+      :non-negative))
+
+(defn unused [x]
+  (* x 2))
+`
+	file := filepath.Join(dir, "calc", "core.lisp")
+	if err := os.WriteFile(file, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := core.NewCoverage()
+	env := core.NewCoreEnvironment()
+	env.RecordCoverage(recorder)
+	env.Set(core.Intern("*load-path*"), core.NewVector(core.String(dir)))
+	expr, _ := core.ReadString(`(do (require 'calc.core) (sign 3) (sign 4))`)
+	if _, err := core.Eval(expr, env); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	if files := recorder.Files(); len(files) != 1 || files[0] != file {
+		t.Fatalf("Expected coverage for %s, got %v", file, files)
+	}
+
+	// Forms: both defns, the if, its test, the quote form and (* x 2); the
+	// quoted (negative) is data and not counted
+	covered, total := recorder.FileSummary(file)
+	if covered != 4 || total != 6 {
+		t.Errorf("Expected 4/6 forms covered, got %d/%d", covered, total)
+	}
+	if got := core.CoveragePercent(covered, total); got != "66.7%" {
+		t.Errorf("Expected 66.7%%, got %s", got)
+	}
+
+	var out bytes.Buffer
+	recorder.Annotate(&out, file)
+	expected := []string{
+		"        1: (defn sign [n]",
+		"        2:   (if (< n 0)",
+		"    #####:       (quote (negative))",
+		"        -:       :non-negative))",
+		"        -: ",
+		"        1: (defn unused [x]",
+		"    #####:   (* x 2))",
+	}
+	if got := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected annotation:\n%s", out.String())
+	}
+}
@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronSchedule is a parsed five-field cron expression: minute, hour, day of
+// month, month and day of week. Each field is a bit set of allowed values.
+type cronSchedule struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // Whether the day fields were *, for the usual OR rule
+}
+
+// A parsed schedule is also a value, made with parse-cron, that prints as
+// #<cron "expr"> and plans runs with next-run and runs-between
+func (s *cronSchedule) String() string {
+	return fmt.Sprintf("#<cron %q>", s.expr)
+}
+
+// cronField describes the range and names of one cron field
+type cronField struct {
+	name     string
+	min, max int
+	names    []string // Indexed from min
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59, nil},
+	{"hour", 0, 23, nil},
+	{"day of month", 1, 31, nil},
+	{"month", 1, 12, []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{"day of week", 0, 6, []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+var cronShorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses expressions like "*/5 * * * *", "0 9-17 * * MON-FRI" or
+// "@daily". Day of week 7 is also Sunday.
+func parseCron(expr string) (*cronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if shorthand, ok := cronShorthands[strings.ToLower(spec)]; ok {
+		spec = shorthand
+	}
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expr, len(parts))
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+	// Sunday may be written as 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] = sets[4]&^(1<<7) | 1
+	}
+
+	return &cronSchedule{
+		expr:   expr,
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domStar: parts[2] == "*" || parts[2] == "?",
+		dowStar: parts[4] == "*" || parts[4] == "?",
+	}, nil
+}
+
+// parseCronField parses a comma-separated list of *, n, a-b, with an
+// optional /step
+func parseCronField(text string, field cronField) (uint64, error) {
+	max := field.max
+	if field.name == "day of week" {
+		max = 7
+	}
+
+	var set uint64
+	for _, item := range strings.Split(text, ",") {
+		rangeText, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step %q in %s field", stepText, field.name)
+			}
+			step = n
+		}
+
+		var lo, hi int
+		switch {
+		case rangeText == "*" || rangeText == "?":
+			lo, hi = field.min, field.max
+		case strings.Contains(rangeText, "-"):
+			loText, hiText, _ := strings.Cut(rangeText, "-")
+			var err error
+			if lo, err = cronValue(loText, field, max); err != nil {
+				return 0, err
+			}
+			if hi, err = cronValue(hiText, field, max); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range %q in %s field", rangeText, field.name)
+			}
+		default:
+			n, err := cronValue(rangeText, field, max)
+			if err != nil {
+				return 0, err
+			}
+			lo, hi = n, n
+			if hasStep {
+				hi = field.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+	return set, nil
+}
+
+// cronValue parses a number or name within a field's range
+func cronValue(text string, field cronField, max int) (int, error) {
+	for i, name := range field.names {
+		if strings.EqualFold(text, name) {
+			return field.min + i, nil
+		}
+	}
+	n, err := strconv.Atoi(text)
+	if err != nil || n < field.min || n > max {
+		return 0, fmt.Errorf("invalid %s %q", field.name, text)
+	}
+	return n, nil
+}
+
+// dayMatches applies cron's rule that when both day fields are restricted,
+// a day matching either is enough
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	domOK := s.dom&(1<<t.Day()) != 0
+	dowOK := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
+
+// next returns the first time strictly after from that matches, in from's
+// location, or the zero time if there is none within five years (e.g. for
+// February 30th)
+func (s *cronSchedule) next(from time.Time) time.Time {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+	limit := from.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// maxCronRuns bounds runs-between so a wide range fails instead of filling
+// memory
+const maxCronRuns = 100000
+
+// between returns the matching times from start, inclusive, to end,
+// exclusive
+func (s *cronSchedule) between(start, end time.Time) ([]time.Time, error) {
+	var runs []time.Time
+	for t := s.next(start.Add(-time.Nanosecond)); !t.IsZero() && t.Before(end); t = s.next(t) {
+		if len(runs) == maxCronRuns {
+			return nil, fmt.Errorf("more than %d runs between %s and %s", maxCronRuns, start.Format(time.RFC3339), end.Format(time.RFC3339))
+		}
+		runs = append(runs, t)
+	}
+	return runs, nil
+}
+
+// cronArg accepts a schedule from parse-cron or a cron expression string
+func cronArg(name string, value Value) (*cronSchedule, error) {
+	switch v := value.(type) {
+	case *cronSchedule:
+		return v, nil
+	case String:
+		cron, err := parseCron(string(v))
+		if err != nil {
+			return nil, NewRuntimeError("%s: %v", name, err)
+		}
+		return cron, nil
+	}
+	return nil, NewTypeError("%s expects a cron expression string, got %s", name, TypeName(value))
+}
@@ -0,0 +1,433 @@
+package core
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Decimal is an exact decimal number for money and other quantities that
+// floats can't represent, written as a literal with an M suffix: 12.34M.
+// It is an integer with a scale, the number of digits after the point, so
+// 12.30M keeps its two decimals. +, - and * are exact; / is exact when the
+// result has a finite decimal expansion and otherwise rounds to 34
+// significant digits. Mixed with integers the result is a Decimal, mixed
+// with floats it is a float.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+func (d Decimal) String() string {
+	digits := new(big.Int).Abs(d.unscaled).String()
+	sign := ""
+	if d.unscaled.Sign() < 0 {
+		sign = "-"
+	}
+	if d.scale == 0 {
+		return sign + digits + "M"
+	}
+	if len(digits) <= d.scale {
+		digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.scale
+	return sign + digits[:point] + "." + digits[point:] + "M"
+}
+
+// decimalPrecision is the number of significant digits kept by divisions
+// without a finite result, as in IEEE 754 decimal128
+const decimalPrecision = 34
+
+var bigTen = big.NewInt(10)
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func decimalFromInt(i int64) Decimal {
+	return Decimal{unscaled: big.NewInt(i)}
+}
+
+// parseDecimal reads digits with an optional fraction and exponent, like
+// "12.34", "-5" or "1.5e3"
+func parseDecimal(text string) (Decimal, bool) {
+	mantissa, exponentText, hasExponent := strings.Cut(strings.ToLower(text), "e")
+	exponent := 0
+	if hasExponent {
+		n, err := strconv.Atoi(exponentText)
+		if err != nil {
+			return Decimal{}, false
+		}
+		exponent = n
+	}
+	whole, fraction, _ := strings.Cut(mantissa, ".")
+	digits := whole + fraction
+	if digits == "" || digits == "-" || digits == "+" || strings.ContainsAny(digits[1:], "+-") {
+		return Decimal{}, false
+	}
+	unscaled, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return Decimal{}, false
+	}
+	scale := len(fraction) - exponent
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, true
+}
+
+// decimalFromFloat converts through the shortest decimal that reads back as
+// f, so 0.1 becomes 0.1M rather than the float's exact binary value
+func decimalFromFloat(f float64) (Decimal, bool) {
+	return parseDecimal(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+func (d Decimal) rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.unscaled, pow10(d.scale))
+}
+
+func (d Decimal) float() float64 {
+	f, _ := d.rat().Float64()
+	return f
+}
+
+// withScale returns d with at least scale digits after the point
+func (d Decimal) withScale(scale int) Decimal {
+	if scale <= d.scale {
+		return d
+	}
+	return Decimal{unscaled: new(big.Int).Mul(d.unscaled, pow10(scale-d.scale)), scale: scale}
+}
+
+func (d Decimal) add(e Decimal) Decimal {
+	scale := max(d.scale, e.scale)
+	a, b := d.withScale(scale), e.withScale(scale)
+	return Decimal{unscaled: new(big.Int).Add(a.unscaled, b.unscaled), scale: scale}
+}
+
+func (d Decimal) neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(d.unscaled), scale: d.scale}
+}
+
+func (d Decimal) mul(e Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(d.unscaled, e.unscaled), scale: d.scale + e.scale}
+}
+
+func (d Decimal) cmp(e Decimal) int {
+	scale := max(d.scale, e.scale)
+	return d.withScale(scale).unscaled.Cmp(e.withScale(scale).unscaled)
+}
+
+// quo divides d by e, keeping the scale of d minus the scale of e where the exact
+// result allows, as Java's BigDecimal does
+func (d Decimal) quo(e Decimal) (Decimal, error) {
+	if e.unscaled.Sign() == 0 {
+		return Decimal{}, fmt.Errorf("division by zero")
+	}
+	q := new(big.Rat).Quo(d.rat(), e.rat())
+	preferred := max(d.scale-e.scale, 0)
+
+	// The quotient is finite when its denominator has no factors but 2 and 5
+	den := new(big.Int).Set(q.Denom())
+	twos, fives := 0, 0
+	for r := new(big.Int); ; twos++ {
+		if r.Mod(den, big.NewInt(2)).Sign() != 0 {
+			break
+		}
+		den.Quo(den, big.NewInt(2))
+	}
+	for r := new(big.Int); ; fives++ {
+		if r.Mod(den, big.NewInt(5)).Sign() != 0 {
+			break
+		}
+		den.Quo(den, big.NewInt(5))
+	}
+	if den.Cmp(big.NewInt(1)) == 0 {
+		scale := max(twos, fives, preferred)
+		unscaled := new(big.Int).Mul(q.Num(), pow10(scale))
+		return Decimal{unscaled: unscaled.Quo(unscaled, q.Denom()), scale: scale}, nil
+	}
+
+	// Otherwise keep decimalPrecision significant digits
+	scale := max(decimalPrecision-1-decimalExponent(q), 0)
+	unscaled := roundQuotient(new(big.Int).Mul(q.Num(), pow10(scale)), q.Denom(), "half-even")
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// decimalExponent returns the power of ten of the leading digit of q
+func decimalExponent(q *big.Rat) int {
+	num, den := new(big.Int).Abs(q.Num()), q.Denom()
+	e := len(num.String()) - len(den.String())
+	// Now 10^(e-1) < num/den < 10^(e+1); check which side of 10^e it is on
+	if e >= 0 {
+		den = new(big.Int).Mul(den, pow10(e))
+	} else {
+		num = new(big.Int).Mul(num, pow10(-e))
+	}
+	if num.Cmp(den) < 0 {
+		e--
+	}
+	return e
+}
+
+// roundingModes are the ways round-decimal can round a discarded part
+var roundingModes = []string{"half-up", "half-even", "half-down", "up", "down", "ceiling", "floor"}
+
+// roundQuotient divides num by den and rounds to an integer with mode
+func roundQuotient(num, den *big.Int, mode string) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	sign := num.Sign() * den.Sign() // Sign of the exact quotient
+	half := new(big.Int).Abs(new(big.Int).Mul(r, big.NewInt(2))).Cmp(new(big.Int).Abs(den))
+
+	var away bool // Whether to round away from zero
+	switch mode {
+	case "up":
+		away = true
+	case "down":
+		away = false
+	case "ceiling":
+		away = sign > 0
+	case "floor":
+		away = sign < 0
+	case "half-up":
+		away = half >= 0
+	case "half-down":
+		away = half > 0
+	case "half-even":
+		away = half > 0 || half == 0 && q.Bit(0) == 1
+	}
+	if away {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+// round returns d with exactly scale digits after the point
+func (d Decimal) round(scale int, mode string) Decimal {
+	if scale >= d.scale {
+		return d.withScale(scale)
+	}
+	return Decimal{unscaled: roundQuotient(d.unscaled, pow10(d.scale-scale), mode), scale: scale}
+}
+
+// decimalArithmetic applies an arithmetic operator to arguments of which at
+// least one is a Decimal. Integers become decimals; with a float anywhere the
+// whole calculation is done in floats.
+func decimalArithmetic(op string, args []Value) (Value, error) {
+	decimals := make([]Decimal, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case Decimal:
+			decimals[i] = v
+		case Number:
+			if v.IsFloat() {
+				return floatArithmetic(op, args)
+			}
+			decimals[i] = decimalFromInt(v.ToInt())
+		default:
+			return nil, NewTypeError("%s expects numbers, got %s", op, TypeName(arg))
+		}
+	}
+
+	if len(decimals) == 1 {
+		switch op {
+		case "-":
+			return decimals[0].neg(), nil
+		case "/":
+			return decimalFromInt(1).quo(decimals[0])
+		}
+		return decimals[0], nil
+	}
+	result := decimals[0]
+	for _, d := range decimals[1:] {
+		switch op {
+		case "+":
+			result = result.add(d)
+		case "-":
+			result = result.add(d.neg())
+		case "*":
+			result = result.mul(d)
+		case "/":
+			var err error
+			if result, err = result.quo(d); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func floatArithmetic(op string, args []Value) (Value, error) {
+	floats := make([]float64, len(args))
+	for i, arg := range args {
+		f, ok := toFloat(arg)
+		if !ok {
+			return nil, NewTypeError("%s expects numbers, got %s", op, TypeName(arg))
+		}
+		floats[i] = f
+	}
+	if len(floats) == 1 {
+		switch op {
+		case "-":
+			return NewNumber(-floats[0]), nil
+		case "/":
+			floats = append([]float64{1}, floats...)
+		default:
+			return NewNumber(floats[0]), nil
+		}
+	}
+	result := floats[0]
+	for _, f := range floats[1:] {
+		switch op {
+		case "+":
+			result += f
+		case "-":
+			result -= f
+		case "*":
+			result *= f
+		case "/":
+			if f == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			result /= f
+		}
+	}
+	return NewNumber(result), nil
+}
+
+// toFloat converts a Number or Decimal to a float
+func toFloat(value Value) (float64, bool) {
+	switch v := value.(type) {
+	case Number:
+		return v.ToFloat(), true
+	case Decimal:
+		return v.float(), true
+	}
+	return 0, false
+}
+
+// hasDecimal reports whether any argument is a Decimal, in which case the
+// arithmetic builtins hand over to decimalArithmetic
+func hasDecimal(args []Value) bool {
+	for _, arg := range args {
+		if _, ok := arg.(Decimal); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// compareNumeric orders two numbers where at least one is a Decimal, or
+// two durations or instants
+func compareNumeric(op string, a, b Value) (int, error) {
+	if hasTemporal([]Value{a, b}) {
+		return compareTemporal(op, a, b)
+	}
+	da, aDecimal := asExactDecimal(a)
+	db, bDecimal := asExactDecimal(b)
+	if aDecimal && bDecimal {
+		return da.cmp(db), nil
+	}
+	fa, aOK := toFloat(a)
+	fb, bOK := toFloat(b)
+	if !aOK || !bOK {
+		return 0, fmt.Errorf("%s expects numbers", op)
+	}
+	switch {
+	case fa < fb:
+		return -1, nil
+	case fa > fb:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// asExactDecimal converts decimals and integers to a Decimal
+func asExactDecimal(value Value) (Decimal, bool) {
+	switch v := value.(type) {
+	case Decimal:
+		return v, true
+	case Number:
+		if v.IsInteger() {
+			return decimalFromInt(v.ToInt()), true
+		}
+	}
+	return Decimal{}, false
+}
+
+// setupDecimalOperations adds decimal, decimal? and round-decimal
+func setupDecimalOperations(env *Environment) {
+	env.Set(Intern("decimal"), &BuiltinFunction{
+		Name: "decimal",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("decimal expects 1 argument, got %d", len(args))
+			}
+			switch v := args[0].(type) {
+			case Decimal:
+				return v, nil
+			case Number:
+				if v.IsInteger() {
+					return decimalFromInt(v.ToInt()), nil
+				}
+				if d, ok := decimalFromFloat(v.ToFloat()); ok {
+					return d, nil
+				}
+				return nil, NewTypeError("decimal cannot convert %s", v)
+			case String:
+				if d, ok := parseDecimal(strings.TrimSpace(string(v))); ok {
+					return d, nil
+				}
+				return nil, NewTypeError("decimal cannot parse %q as a number", string(v))
+			}
+			return nil, NewTypeError("decimal expects number or string, got %s", TypeName(args[0]))
+		},
+	})
+
+	env.Set(Intern("decimal?"), &BuiltinFunction{
+		Name: "decimal?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("decimal? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(Decimal); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	// (round-decimal d scale) rounds half up to scale decimals; a mode
+	// keyword like :half-even chooses another rounding
+	env.Set(Intern("round-decimal"), &BuiltinFunction{
+		Name: "round-decimal",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 && len(args) != 3 {
+				return nil, NewArityError("round-decimal expects 2 or 3 arguments, got %d", len(args))
+			}
+			d, ok := asExactDecimal(args[0])
+			if !ok {
+				return nil, NewTypeError("round-decimal expects a decimal, got %s", args[0])
+			}
+			scale, ok := args[1].(Number)
+			if !ok || !scale.IsInteger() || scale.ToInt() < 0 {
+				return nil, NewTypeError("round-decimal expects a non-negative scale, got %s", args[1])
+			}
+			mode := "half-up"
+			if len(args) == 3 {
+				keyword, ok := args[2].(Keyword)
+				if !ok || !slices.Contains(roundingModes, string(keyword)) {
+					return nil, NewTypeError("round-decimal expects a rounding mode (:%s), got %s", strings.Join(roundingModes, " :"), args[2])
+				}
+				mode = string(keyword)
+			}
+			return d.round(int(scale.ToInt()), mode), nil
+		},
+	})
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecimals(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Literals keep their scale and print without float artifacts
+		{`12.34M`, `12.34M`},
+		{`12.30M`, `12.30M`},
+		{`-0.005M`, `-0.005M`},
+		{`1_000.50M`, `1000.50M`},
+		{`1.5e3M`, `1500M`},
+		{`1e-3M`, `0.001M`},
+		{`(read-string "7M")`, `7M`},
+
+		{`(+ 0.1M 0.2M)`, `0.3M`},
+		{`(= (+ 0.1M 0.2M) 0.3M)`, `true`},
+		{`(* 19.99M 3)`, `59.97M`},
+		{`(- 10M 0.01M)`, `9.99M`},
+		{`(- 5M)`, `-5M`},
+		{`(/ 10.00M 4)`, `2.50M`},
+		{`(/ 1M 8)`, `0.125M`},
+		{`(/ 4)`, `0.25`},
+		{`(/ 4M)`, `0.25M`},
+		{`(/ 1M 3)`, `0.3333333333333333333333333333333333M`},
+		{`(/ 2M 3)`, `0.6666666666666666666666666666666667M`},
+		{`(/ 100M 7)`, `14.28571428571428571428571428571429M`},
+		{`(/ 0.001M 3)`, `0.0003333333333333333333333333333333333M`},
+		{`(+ 1.5M 0.5)`, `2.0`},
+
+		{`(round-decimal (/ 10M 3) 2)`, `3.33M`},
+		{`(round-decimal 2.345M 2)`, `2.35M`},
+		{`(round-decimal 2.345M 2 :half-even)`, `2.34M`},
+		{`(round-decimal 2.355M 2 :half-even)`, `2.36M`},
+		{`(round-decimal 2.345M 2 :half-down)`, `2.34M`},
+		{`(round-decimal -2.5M 0)`, `-3M`},
+		{`(round-decimal -2.1M 0 :ceiling)`, `-2M`},
+		{`(round-decimal -2.1M 0 :floor)`, `-3M`},
+		{`(round-decimal 2.01M 1 :up)`, `2.1M`},
+		{`(round-decimal 2.09M 1 :down)`, `2.0M`},
+		{`(round-decimal 1.2M 3)`, `1.200M`},
+		{`(round-decimal 5 2)`, `5.00M`},
+
+		{`(< 1.5M 2)`, `true`},
+		{`(> 1.5M 1.49M)`, `true`},
+		{`(<= 1M 1)`, `true`},
+		{`(>= 0.5M 0.75)`, `nil`},
+		{`(= 1M 1)`, `true`},
+		{`(= 1 1.00M)`, `true`},
+		{`(= 1.5M 1.5)`, `nil`},
+		{`(sort-by (fn [x] x) [2M 1.5 1.25M 3])`, `(1.25M 1.5 2M 3)`},
+
+		{`(decimal 0.1)`, `0.1M`},
+		{`(decimal "3.50")`, `3.50M`},
+		{`(decimal 42)`, `42M`},
+		{`(int 3.99M)`, `3`},
+		{`(int -3.99M)`, `-3`},
+		{`(float 2.5M)`, `2.5`},
+		{`(number? 1M)`, `true`},
+		{`(decimal? 1M)`, `true`},
+		{`(decimal? 1.0)`, `nil`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`1.2.3M`:                      "invalid decimal: 1.2.3M",
+		`1__0M`:                       "invalid decimal",
+		`(/ 1M 0)`:                    "division by zero",
+		`(+ 1M "a")`:                  "+ expects numbers",
+		`(decimal "abc")`:             "cannot parse",
+		`(round-decimal 1.5M -1)`:     "non-negative scale",
+		`(round-decimal 1.5M 0 :odd)`: "rounding mode",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func evalAll(t *testing.T, env *Environment, input string) Value {
+	t.Helper()
+	tokens, err := NewLexer(input).Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize error for '%s': %v", input, err)
+	}
+	forms, err := NewParser(tokens).ParseAll()
+	if err != nil {
+		t.Fatalf("Parse error for '%s': %v", input, err)
+	}
+	var result Value = Nil{}
+	for _, form := range forms {
+		if result, err = Eval(form, env); err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+	}
+	return result
+}
+
+func countingEnv() (*Environment, *int) {
+	env := NewCoreEnvironment()
+	calls := 0
+	env.Set(Intern("tick"), &BuiltinFunction{
+		Name: "tick",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			calls++
+			return args[0], nil
+		},
+	})
+	return env, &calls
+}
+
+func TestDelay(t *testing.T) {
+	env, calls := countingEnv()
+	tests := []struct {
+		input    string
+		expected string
+		calls    int
+	}{
+		{"(def d (delay (tick (+ 1 2))))", "d", 0},
+		{"(realized? d)", "nil", 0},
+		{"d", "#<delay:pending>", 0},
+		{"(force d)", "3", 1},
+		{"@d", "3", 1},
+		{"(list (deref d) (realized? d))", "(3 true)", 1},
+		{"d", "#<delay:3>", 1},
+		{"(force 7)", "7", 1},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+		if *calls != test.calls {
+			t.Errorf("After '%s', expected %d evaluations, got %d", test.input, test.calls, *calls)
+		}
+	}
+
+	expr, _ := ReadString("(deref 1)")
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "deref expects a delay, var, atom or ref") {
+		t.Errorf("Expected deref type error, got %v", err)
+	}
+}
+
+func TestDelayForcedWhileEvaluating(t *testing.T) {
+	env, calls := countingEnv()
+	// Forcing itself fails instead of deadlocking
+	evalAll(t, env, `(def self (delay (+ 1 (force self))))`)
+	if err := evalSource(t, env, `(force self)`); err == nil || !strings.Contains(err.Error(), "delay forced again while evaluating its own expression") {
+		t.Errorf("Expected a re-entry error, got %v", err)
+	}
+	evalAll(t, env, `(defn force-it [] @indirect)
+		(def indirect (delay (force-it)))`)
+	if err := evalSource(t, env, `@indirect`); err == nil || !strings.Contains(err.Error(), "delay forced again") {
+		t.Errorf("Expected a re-entry error through a function call, got %v", err)
+	}
+
+	// Other goroutines wait for the value; printing doesn't
+	started, release := make(chan bool), make(chan bool)
+	env.Set(Intern("block"), &BuiltinFunction{
+		Name: "block",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			started <- true
+			<-release
+			return args[0], nil
+		},
+	})
+	evalAll(t, env, `(def slow (delay (tick (block 42))))`)
+	results := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			expr, _ := ReadString(`@slow`)
+			value, err := Eval(expr, env)
+			if err != nil {
+				results <- err.Error()
+				return
+			}
+			results <- value.String()
+		}()
+	}
+	<-started
+	if got := evalAll(t, env, `(list slow (realized? slow))`).String(); got != "(#<delay:pending> nil)" {
+		t.Errorf("Expected a pending delay while it is evaluated, got %s", got)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if got := <-results; got != "42" {
+			t.Errorf("Expected both forcers to get 42, got %s", got)
+		}
+	}
+	if *calls != 1 {
+		t.Errorf("Expected the delay to be evaluated once, got %d", *calls)
+	}
+}
+
+func TestMemoize(t *testing.T) {
+	env, calls := countingEnv()
+	evalAll(t, env, "(def double (memoize (fn [x] (tick (* 2 x)))))")
+	if result := evalAll(t, env, "(list (double 2) (double 2) (double 3) (double 2))"); result.String() != "(4 4 6 4)" {
+		t.Errorf("Expected (4 4 6 4), got %s", result)
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", *calls)
+	}
+
+	// Recursion through the memoized function does not deadlock
+	evalAll(t, env, "(def fib (memoize (fn [n] (if (< n 2) n (+ (fib (- n 1)) (fib (- n 2)))))))")
+	if result := evalAll(t, env, "(fib 80)"); result.String() != "23416728348467685" {
+		t.Errorf("Expected fib 80, got %s", result)
+	}
+
+	// :max-size evicts the least recently used entry
+	*calls = 0
+	evalAll(t, env, "(def lru (memoize (fn [x] (tick x)) :max-size 2))")
+	evalAll(t, env, "(lru 1) (lru 2) (lru 1) (lru 3) (lru 1) (lru 2)")
+	if *calls != 4 {
+		t.Errorf("Expected 4 calls with :max-size 2, got %d", *calls)
+	}
+
+	// Arguments are compared by value, not by how they print
+	*calls = 0
+	evalAll(t, env, "(def *print-precision* 1) (def same (memoize (fn [x] (tick x))))")
+	if result := evalAll(t, env, "(list (same 1.21) (same 1.24) (same 1.21))"); result.String() != "(1.21 1.24 1.21)" {
+		t.Errorf("Expected (1.21 1.24 1.21), got %s", result)
+	}
+	evalAll(t, env, "(same (atom 1)) (same (atom 1))")
+	if *calls != 4 {
+		t.Errorf("Expected a call per distinct argument, got %d", *calls)
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(memoize 1)":             "memoize expects a function",
+		"(memoize + :max-size)":   "option pairs",
+		"(memoize + :max-size 0)": "positive integer",
+		"(memoize + :forever 1)":  "unknown memoize option",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestMemoCacheTTL(t *testing.T) {
+	cache, err := parseMemoOptions([]Value{InternKeyword("ttl"), NewNumber(int64(100))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+
+	cache.put(String("k"), NewNumber(int64(1)))
+	now = now.Add(99 * time.Millisecond)
+	if _, ok := cache.get(String("k")); !ok {
+		t.Error("Expected entry before the TTL")
+	}
+	now = now.Add(time.Millisecond)
+	if _, ok := cache.get(String("k")); ok {
+		t.Error("Expected entry to expire after the TTL")
+	}
+}
@@ -0,0 +1,325 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffValues compares a and b recursively like clojure.data/diff, returning
+// what is only in a, what is only in b and what they share, each nil when
+// empty. Maps are compared by key, lists and vectors by position and sets
+// by membership; other values are either equal or entirely different.
+func diffValues(a, b Value) (onlyA, onlyB, both Value) {
+	if valuesEqual(a, b) {
+		return Nil{}, Nil{}, a
+	}
+	switch va := a.(type) {
+	case *HashMap:
+		if vb, ok := b.(*HashMap); ok {
+			return diffMaps(va, vb)
+		}
+	case *Set:
+		if vb, ok := b.(*Set); ok {
+			return diffSets(va, vb)
+		}
+	case *List, *Vector:
+		switch b.(type) {
+		case *List, *Vector:
+			return diffSequences(a, b)
+		}
+	}
+	return a, b, Nil{}
+}
+
+func diffMaps(a, b *HashMap) (Value, Value, Value) {
+	onlyA, onlyB, both := a.empty(), b.empty(), a.empty()
+	for _, key := range a.keys {
+		if !b.ContainsKey(key) {
+			onlyA.Set(key, a.Get(key))
+			continue
+		}
+		x, y, shared := diffValues(a.Get(key), b.Get(key))
+		setUnlessNil(onlyA, key, x)
+		setUnlessNil(onlyB, key, y)
+		setUnlessNil(both, key, shared)
+	}
+	for _, key := range b.keys {
+		if !a.ContainsKey(key) {
+			onlyB.Set(key, b.Get(key))
+		}
+	}
+	return nilIfEmpty(onlyA), nilIfEmpty(onlyB), nilIfEmpty(both)
+}
+
+func setUnlessNil(m *HashMap, key, value Value) {
+	if _, isNil := value.(Nil); !isNil {
+		m.Set(key, value)
+	}
+}
+
+func diffSets(a, b *Set) (Value, Value, Value) {
+	onlyA, onlyB, both := NewSet(), NewSet(), NewSet()
+	for _, elem := range a.order {
+		if b.Contains(elem) {
+			both.Add(elem)
+		} else {
+			onlyA.Add(elem)
+		}
+	}
+	for _, elem := range b.order {
+		if !a.Contains(elem) {
+			onlyB.Add(elem)
+		}
+	}
+	return nilIfEmpty(onlyA), nilIfEmpty(onlyB), nilIfEmpty(both)
+}
+
+// diffSequences compares position by position, returning vectors with nil
+// at the positions a side has nothing to report
+func diffSequences(a, b Value) (Value, Value, Value) {
+	xs, _ := collectionToSlice(a)
+	ys, _ := collectionToSlice(b)
+	n := max(len(xs), len(ys))
+	onlyA, onlyB, both := make([]Value, n), make([]Value, n), make([]Value, n)
+	for i := 0; i < n; i++ {
+		switch {
+		case i >= len(ys):
+			onlyA[i], onlyB[i], both[i] = xs[i], Nil{}, Nil{}
+		case i >= len(xs):
+			onlyA[i], onlyB[i], both[i] = Nil{}, ys[i], Nil{}
+		default:
+			onlyA[i], onlyB[i], both[i] = diffValues(xs[i], ys[i])
+		}
+	}
+	return positions(onlyA), positions(onlyB), positions(both)
+}
+
+// positions drops trailing nils, returning nil if nothing is left
+func positions(values []Value) Value {
+	end := len(values)
+	for end > 0 {
+		if _, isNil := values[end-1].(Nil); !isNil {
+			break
+		}
+		end--
+	}
+	if end == 0 {
+		return Nil{}
+	}
+	return NewVector(values[:end]...)
+}
+
+func nilIfEmpty(coll Value) Value {
+	switch c := coll.(type) {
+	case *HashMap:
+		if c.Count() == 0 {
+			return Nil{}
+		}
+	case *Set:
+		if c.Count() == 0 {
+			return Nil{}
+		}
+	}
+	return coll
+}
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffLine is one line of an edit script: ' ' kept, '-' removed or '+' added,
+// with the number of lines of each text before it
+type diffLine struct {
+	kind   byte
+	text   string
+	aIndex int
+	bIndex int
+}
+
+// textDiff returns the unified diff turning a into b, labelled with the
+// names given, or "" if they are equal
+func textDiff(a, b, labelA, labelB string) string {
+	if a == b {
+		return ""
+	}
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", labelA, labelB)
+	for start := 0; start < len(lines); {
+		// Find the next change and extend the hunk while changes are close
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+		last := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].kind != ' ' {
+				if i-last-1 > 2*diffContext {
+					break
+				}
+				last = i
+			}
+		}
+		from := max(first-diffContext, start)
+		to := min(last+diffContext+1, len(lines))
+		writeHunk(&out, lines[from:to])
+		start = to
+	}
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, hunk []diffLine) {
+	aCount, bCount := 0, 0
+	for _, line := range hunk {
+		if line.kind != '+' {
+			aCount++
+		}
+		if line.kind != '-' {
+			bCount++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(hunk[0].aIndex, aCount), hunkRange(hunk[0].bIndex, bCount))
+	for _, line := range hunk {
+		out.WriteByte(line.kind)
+		out.WriteString(line.text)
+		out.WriteByte('\n')
+	}
+}
+
+// hunkRange formats the lines after the first skipped ones the way diff -u
+// does, where an empty range names the line before it
+func hunkRange(skipped, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", skipped)
+	case 1:
+		return fmt.Sprintf("%d", skipped+1)
+	}
+	return fmt.Sprintf("%d,%d", skipped+1, count)
+}
+
+// splitLines splits text into lines, ignoring a final newline
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines finds a shortest edit script from a to b through their longest
+// common subsequence, after setting aside the lines they start and end with
+func diffLines(a, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// common[i][j] is the length of the LCS of midA[i:] and midB[j:]
+	common := make([][]int, len(midA)+1)
+	for i := range common {
+		common[i] = make([]int, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	emit := func(kind byte, text string) {
+		lines = append(lines, diffLine{kind: kind, text: text, aIndex: i, bIndex: j})
+	}
+	for ; i < prefix; i, j = i+1, j+1 {
+		emit(' ', a[i])
+	}
+	x, y := 0, 0
+	for x < len(midA) || y < len(midB) {
+		switch {
+		case x < len(midA) && y < len(midB) && midA[x] == midB[y]:
+			emit(' ', midA[x])
+			x, y, i, j = x+1, y+1, i+1, j+1
+		case y == len(midB) || x < len(midA) && common[x+1][y] >= common[x][y+1]:
+			emit('-', midA[x])
+			x, i = x+1, i+1
+		default:
+			emit('+', midB[y])
+			y, j = y+1, j+1
+		}
+	}
+	for ; i < len(a); i, j = i+1, j+1 {
+		emit(' ', a[i])
+	}
+	return lines
+}
+
+// describeMismatch explains how actual differs from expected for a failed
+// equality assertion: a unified diff for multi-line strings and what is
+// only on each side for collections. It returns "" for other values.
+func describeMismatch(expected, actual Value) string {
+	if x, ok := expected.(String); ok {
+		if y, ok := actual.(String); ok && (strings.Contains(string(x), "\n") || strings.Contains(string(y), "\n")) {
+			return textDiff(string(x), string(y), "expected", "actual")
+		}
+		return ""
+	}
+	isCollection := func(v Value) bool {
+		switch v.(type) {
+		case *HashMap, *Set, *List, *Vector:
+			return true
+		}
+		return false
+	}
+	if !isCollection(expected) || !isCollection(actual) {
+		return ""
+	}
+	onlyExpected, onlyActual, _ := diffValues(expected, actual)
+	return fmt.Sprintf("    diff: - %s\n          + %s\n", onlyExpected, onlyActual)
+}
+
+// setupDiffOperations adds diff and text-diff
+func setupDiffOperations(env *Environment) {
+	env.Set(Intern("diff"), &BuiltinFunction{
+		Name: "diff",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("diff expects 2 arguments, got %d", len(args))
+			}
+			onlyA, onlyB, both := diffValues(args[0], args[1])
+			return NewVector(onlyA, onlyB, both), nil
+		},
+	})
+
+	env.Set(Intern("text-diff"), &BuiltinFunction{
+		Name: "text-diff",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 && len(args) != 4 {
+				return nil, NewArityError("text-diff expects 2 or 4 arguments, got %d", len(args))
+			}
+			texts := make([]string, len(args))
+			for i, arg := range args {
+				text, ok := arg.(String)
+				if !ok {
+					return nil, NewTypeError("text-diff expects strings, got %s", TypeName(arg))
+				}
+				texts[i] = string(text)
+			}
+			if len(texts) == 2 {
+				texts = append(texts, "a", "b")
+			}
+			return String(textDiff(texts[0], texts[1], texts[2], texts[3])), nil
+		},
+	})
+}
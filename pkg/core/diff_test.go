@@ -0,0 +1,66 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(diff 1 1)`, `[nil nil 1]`},
+		{`(diff "x" 2)`, `["x" 2 nil]`},
+		{`(diff {:a 1 :b {:c 2 :d 3}} {:a 1 :b {:c 5 :d 3} :e 4})`, `[{:b {:c 2}} {:b {:c 5} :e 4} {:a 1 :b {:d 3}}]`},
+		{`(diff [1 2 3] [1 5 3 4])`, `[[nil 2] [nil 5 nil 4] [1 nil 3]]`},
+		{`(diff '(1 2) [1 2])`, `[nil nil (1 2)]`},
+		{`(diff [1 2] [1 2 3])`, `[nil [nil nil 3] [1 2]]`},
+		{`(diff #{1 2} #{2 3})`, `[#{1} #{3} #{2}]`},
+		{`(diff {:a [1 2]} {:a [1 3]})`, `[{:a [nil 2]} {:a [nil 3]} {:a [1]}]`},
+		{`(diff {:a 1} [1])`, `[{:a 1} [1] nil]`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+}
+
+func TestTextDiff(t *testing.T) {
+	lines := func(text ...string) string { return strings.Join(text, "\n") + "\n" }
+	tests := []struct {
+		name     string
+		a, b     string
+		expected string
+	}{
+		{"equal", "a\nb\n", "a\nb\n", ""},
+		{"changed line", lines("a", "b", "c"), lines("a", "B", "c"),
+			lines("--- a", "+++ b", "@@ -1,3 +1,3 @@", " a", "-b", "+B", " c")},
+		{"added to empty", "", lines("x"),
+			lines("--- a", "+++ b", "@@ -0,0 +1 @@", "+x")},
+		{"removed all", lines("x", "y"), "",
+			lines("--- a", "+++ b", "@@ -1,2 +0,0 @@", "-x", "-y")},
+		{"separate hunks",
+			lines("1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12"),
+			lines("1", "two", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13"),
+			lines("--- a", "+++ b", "@@ -1,5 +1,5 @@", " 1", "-2", "+two", " 3", " 4", " 5",
+				"@@ -10,3 +10,4 @@", " 10", " 11", " 12", "+13")},
+		{"close changes share a hunk",
+			lines("1", "2", "3", "4", "5", "6", "7", "8"),
+			lines("1", "x", "3", "4", "5", "6", "7", "y"),
+			lines("--- a", "+++ b", "@@ -1,8 +1,8 @@", " 1", "-2", "+x", " 3", " 4", " 5", " 6", " 7", "-8", "+y")},
+	}
+	for _, test := range tests {
+		if result := textDiff(test.a, test.b, "a", "b"); result != test.expected {
+			t.Errorf("%s: expected\n%s\ngot\n%s", test.name, test.expected, result)
+		}
+	}
+
+	env := NewCoreEnvironment()
+	result := evalAll(t, env, `(text-diff "a\nb" "a\nc" "old.txt" "new.txt")`)
+	if !strings.HasPrefix(string(result.(String)), "--- old.txt\n+++ new.txt\n@@ -1,2 +1,2 @@") {
+		t.Errorf("Unexpected text-diff result %q", result)
+	}
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// DocEntry describes a single documented top-level definition
+type DocEntry struct {
+	Name     string
+	Kind     string // "function", "macro", "var", or "builtin"
+	Arglists []string
+	Doc      string
+	Examples []string
+	File     string
+}
+
+// CollectDocs extracts documentation entries from the top-level definitions
+// in source without evaluating it. A definition without a docstring is
+// documented by the comment lines just above it.
+func CollectDocs(source, file string) ([]DocEntry, error) {
+	lexer := NewLexer(source)
+	lexer.KeepComments()
+	tokens, err := lexer.Tokenize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenize %s: %v", file, err)
+	}
+
+	parser := NewParserWithSource(tokens, source)
+	parser.AttachComments(lexer.Comments())
+	expressions, err := parser.ParseAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+	}
+
+	var entries []DocEntry
+	for _, expr := range expressions {
+		list, ok := expr.(*List)
+		if !ok || list.IsEmpty() {
+			continue
+		}
+
+		head, ok := list.First().(Symbol)
+		if !ok {
+			continue
+		}
+
+		argSlice := listToSlice(list.Rest())
+		if len(argSlice) < 2 {
+			continue
+		}
+
+		name, ok := argSlice[0].(Symbol)
+		if !ok {
+			continue
+		}
+
+		entry := DocEntry{Name: string(name), File: file}
+
+		switch head {
+		case "defn", "defmacro":
+			entry.Kind = "function"
+			if head == "defmacro" {
+				entry.Kind = "macro"
+			}
+
+			doc, attrs, forms := splitDocAndAttrs(argSlice[1:])
+			entry.Doc = doc
+			entry.Examples = docExamples(attrs)
+			if len(forms) > 0 {
+				entry.Arglists = []string{forms[0].String()}
+			}
+		case "def":
+			entry.Kind = "var"
+			if len(argSlice) == 3 {
+				if str, ok := argSlice[1].(String); ok {
+					entry.Doc = string(str)
+				}
+			}
+		default:
+			continue
+		}
+		if entry.Doc == "" {
+			entry.Doc = commentDoc(list)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// commentDoc joins the comment lines directly above a definition into its
+// doc, leaving out comments further up, like a file header
+func commentDoc(list *List) string {
+	comments := list.Comments()
+	if comments == nil {
+		return ""
+	}
+	var lines []string
+	line := list.GetPosition().Line - 1
+	for i := len(comments.Leading) - 1; i >= 0 && comments.Leading[i].Position.Line == line; i-- {
+		lines = append([]string{comments.Leading[i].Content()}, lines...)
+		line--
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// CollectBuiltinDocs lists the Go-implemented primitives bound in env
+func CollectBuiltinDocs(env *Environment) []DocEntry {
+	var entries []DocEntry
+	for _, name := range env.GetAllSymbols() {
+		value, err := env.Get(Symbol(name))
+		if err != nil {
+			continue
+		}
+		if _, ok := value.(*BuiltinFunction); ok {
+			entries = append(entries, DocEntry{Name: name, Kind: "builtin"})
+		}
+	}
+	return entries
+}
+
+// docExamples reads the :examples (or :example) entry of an attribute map
+func docExamples(attrs *HashMap) []string {
+	if attrs == nil {
+		return nil
+	}
+
+	value := attrs.Get(InternKeyword("examples"))
+	if _, isNil := value.(Nil); isNil {
+		value = attrs.Get(InternKeyword("example"))
+	}
+
+	var examples []string
+	switch v := value.(type) {
+	case String:
+		examples = append(examples, string(v))
+	case *Vector, *List:
+		items, _ := collectionToSlice(v)
+		for _, item := range items {
+			if str, ok := item.(String); ok {
+				examples = append(examples, string(str))
+			} else {
+				examples = append(examples, item.String())
+			}
+		}
+	}
+	return examples
+}
+
+// groupDocsByFile groups entries by source file, preserving file order
+func groupDocsByFile(entries []DocEntry) ([]string, map[string][]DocEntry) {
+	var files []string
+	groups := make(map[string][]DocEntry)
+	for _, entry := range entries {
+		file := entry.File
+		if file == "" {
+			file = "builtins"
+		}
+		if _, exists := groups[file]; !exists {
+			files = append(files, file)
+		}
+		groups[file] = append(groups[file], entry)
+	}
+
+	for _, file := range files {
+		group := groups[file]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Name < group[j].Name })
+	}
+	return files, groups
+}
+
+// RenderMarkdown renders documentation entries as a Markdown reference
+func RenderMarkdown(title string, entries []DocEntry) string {
+	var out strings.Builder
+	files, groups := groupDocsByFile(entries)
+
+	out.WriteString(fmt.Sprintf("# %s\n\n", title))
+
+	// Table of contents
+	for _, file := range files {
+		out.WriteString(fmt.Sprintf("- [%s](#%s)\n", file, docAnchor(file)))
+	}
+
+	for _, file := range files {
+		out.WriteString(fmt.Sprintf("\n## %s\n", file))
+		for _, entry := range groups[file] {
+			out.WriteString(fmt.Sprintf("\n### `%s`\n\n", entry.Name))
+			out.WriteString(fmt.Sprintf("*%s*", entry.Kind))
+			for _, arglist := range entry.Arglists {
+				out.WriteString(fmt.Sprintf(" `%s`", arglist))
+			}
+			out.WriteString("\n")
+			if entry.Doc != "" {
+				out.WriteString(fmt.Sprintf("\n%s\n", entry.Doc))
+			}
+			if len(entry.Examples) > 0 {
+				out.WriteString("\n```lisp\n")
+				for _, example := range entry.Examples {
+					out.WriteString(example + "\n")
+				}
+				out.WriteString("```\n")
+			}
+		}
+	}
+
+	return out.String()
+}
+
+// RenderHTML renders documentation entries as a standalone HTML page
+func RenderHTML(title string, entries []DocEntry) string {
+	var out strings.Builder
+	files, groups := groupDocsByFile(entries)
+
+	out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	out.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(title)))
+	out.WriteString("</head>\n<body>\n")
+	out.WriteString(fmt.Sprintf("<h1>%s</h1>\n<ul>\n", html.EscapeString(title)))
+	for _, file := range files {
+		out.WriteString(fmt.Sprintf("<li><a href=\"#%s\">%s</a></li>\n", docAnchor(file), html.EscapeString(file)))
+	}
+	out.WriteString("</ul>\n")
+
+	for _, file := range files {
+		out.WriteString(fmt.Sprintf("<h2 id=\"%s\">%s</h2>\n", docAnchor(file), html.EscapeString(file)))
+		for _, entry := range groups[file] {
+			out.WriteString(fmt.Sprintf("<h3><code>%s</code></h3>\n", html.EscapeString(entry.Name)))
+			out.WriteString(fmt.Sprintf("<p><em>%s</em>", entry.Kind))
+			for _, arglist := range entry.Arglists {
+				out.WriteString(fmt.Sprintf(" <code>%s</code>", html.EscapeString(arglist)))
+			}
+			out.WriteString("</p>\n")
+			if entry.Doc != "" {
+				out.WriteString(fmt.Sprintf("<p>%s</p>\n", html.EscapeString(entry.Doc)))
+			}
+			if len(entry.Examples) > 0 {
+				out.WriteString(fmt.Sprintf("<pre>%s</pre>\n", html.EscapeString(strings.Join(entry.Examples, "\n"))))
+			}
+		}
+	}
+
+	out.WriteString("</body>\n</html>\n")
+	return out.String()
+}
+
+// docAnchor turns a file name into an HTML/Markdown anchor
+func docAnchor(name string) string {
+	var out strings.Builder
+	for _, char := range strings.ToLower(name) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '-' {
+			out.WriteRune(char)
+		}
+	}
+	return out.String()
+}
@@ -0,0 +1,108 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestDefnDocstring(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(do (defn square "Squares x." [x] (* x x)) (square 4))`, "16"},
+		{`(do (defn cube "Cubes x." {:examples ["(cube 2)"]} [x] (* x x x)) (cube 2))`, "8"},
+		{`(do (defn greeting [] "hello") (greeting))`, `"hello"`},
+		{`(do (defmacro my-when "Like when." [c & body] (list 'if c (cons 'do body) nil)) (my-when true 42))`, "42"},
+	}
+
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Errorf("Parse error for '%s': %v", test.input, err)
+			continue
+		}
+
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+
+		if result.String() != test.expected {
+			t.Errorf("Expected '%s' for input '%s', got '%s'", test.expected, test.input, result.String())
+		}
+	}
+
+	fn, err := env.Get(core.Intern("square"))
+	if err != nil {
+		t.Fatalf("square not defined: %v", err)
+	}
+	if uf, ok := fn.(*core.UserFunction); !ok || uf.Doc != "Squares x." || uf.Name != "square" {
+		t.Errorf("Expected docstring to be recorded on square, got %#v", fn)
+	}
+}
+
+func TestCollectDocs(t *testing.T) {
+	source := `
+(defn inc "Adds one." {:examples ["(inc 1) ; => 2"]} [x] (+ x 1))
+(defmacro unless [c & body] (list 'if c nil (cons 'do body)))
+(def answer "The answer." 42)
+(println "not a definition")
+`
+	entries, err := core.CollectDocs(source, "lib.lisp")
+	if err != nil {
+		t.Fatalf("CollectDocs failed: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+
+	inc := entries[0]
+	if inc.Name != "inc" || inc.Kind != "function" || inc.Doc != "Adds one." {
+		t.Errorf("Unexpected entry for inc: %#v", inc)
+	}
+	if len(inc.Arglists) != 1 || inc.Arglists[0] != "[x]" {
+		t.Errorf("Expected arglist [x], got %v", inc.Arglists)
+	}
+	if len(inc.Examples) != 1 || inc.Examples[0] != "(inc 1) ; => 2" {
+		t.Errorf("Unexpected examples: %v", inc.Examples)
+	}
+
+	if entries[1].Kind != "macro" || entries[2].Kind != "var" || entries[2].Doc != "The answer." {
+		t.Errorf("Unexpected entries: %#v", entries[1:])
+	}
+
+	markdown := core.RenderMarkdown("Reference", entries)
+	for _, want := range []string{"# Reference", "## lib.lisp", "### `inc`", "Adds one.", "(inc 1) ; => 2"} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("Expected markdown to contain %q", want)
+		}
+	}
+
+	page := core.RenderHTML("Reference", entries)
+	if !strings.Contains(page, "<code>inc</code>") || !strings.Contains(page, "&amp;") {
+		t.Errorf("Expected escaped HTML reference, got:\n%s", page)
+	}
+
+	commented := `;; lib.lisp - helpers
+
+;; Doubles x,
+;; for any number.
+(defn double [x] (* x 2))
+
+(defn half "Halves x." [x] (/ x 2)) ; not a doc
+`
+	entries, err = core.CollectDocs(commented, "lib.lisp")
+	if err != nil {
+		t.Fatalf("CollectDocs failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Doc != "Doubles x,\nfor any number." || entries[1].Doc != "Halves x." {
+		t.Errorf("Expected docs from comments above definitions, got %#v", entries)
+	}
+}
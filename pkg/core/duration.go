@@ -0,0 +1,392 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is a length of time, made with (hours 2), (minutes 30) and so on
+// or parsed from "2h30m" or ISO 8601 "PT2H30M". It prints as
+// #duration "2h30m", which reads back. Durations add to and subtract from
+// each other and instants with + and -, scale with * and /, and compare
+// with < and >; subtracting two instants gives the duration between them.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) String() string {
+	return fmt.Sprintf("#duration %q", humanizeDuration(d.Duration))
+}
+
+// durationUnits are the units of humanized durations, largest first
+var durationUnits = []struct {
+	suffix string
+	size   time.Duration
+}{
+	{"d", 24 * time.Hour},
+	{"h", time.Hour},
+	{"m", time.Minute},
+	{"s", time.Second},
+	{"ms", time.Millisecond},
+	{"us", time.Microsecond},
+	{"ns", time.Nanosecond},
+}
+
+// humanizeDuration writes d with the units it needs, like "2h30m", "1d6h"
+// or "250ms"
+func humanizeDuration(d time.Duration) string {
+	if d == 0 {
+		return "0s"
+	}
+	var out strings.Builder
+	if d < 0 {
+		out.WriteString("-")
+		d = -d
+	}
+	for _, unit := range durationUnits {
+		if n := d / unit.size; n > 0 {
+			fmt.Fprintf(&out, "%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+	return out.String()
+}
+
+var humanDurationPattern = regexp.MustCompile(`^(\d+(\.\d+)?(d|h|m|s|ms|us|µs|ns))+$`)
+
+// parseDuration reads humanized durations like "2h30m" or "1.5d" and ISO
+// 8601 durations like "PT2H30M" or "P1DT12H", where a day is 24 hours and
+// years and months, which vary in length, are not allowed
+func parseDuration(text string) (time.Duration, error) {
+	s, negative := strings.CutPrefix(strings.TrimSpace(text), "-")
+	var d time.Duration
+	var err error
+	if strings.HasPrefix(strings.ToUpper(s), "P") {
+		d, err = parseISODuration(strings.ToUpper(s))
+	} else if humanDurationPattern.MatchString(s) {
+		d, err = parseHumanDuration(s)
+	} else {
+		err = fmt.Errorf("invalid duration %q, expected like \"2h30m\" or \"PT2H30M\"", text)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		d = -d
+	}
+	return d, nil
+}
+
+func parseHumanDuration(s string) (time.Duration, error) {
+	// Go's parser knows every unit but days
+	var total time.Duration
+	if days, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		total = time.Duration(n * float64(24*time.Hour))
+		if s = rest; s == "" {
+			return total, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return total + d, nil
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)Y)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)W)?(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:[.,]\d+)?)S)?)?$`)
+
+func parseISODuration(s string) (time.Duration, error) {
+	match := isoDurationPattern.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid ISO 8601 duration %q", s)
+	}
+	if match[1] != "" || match[2] != "" {
+		return 0, fmt.Errorf("ISO 8601 duration %q has years or months, which vary in length", s)
+	}
+	sizes := []time.Duration{0, 0, 7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var total float64
+	for i, part := range match[1:] {
+		if part == "" {
+			continue
+		}
+		n, _ := strconv.ParseFloat(strings.Replace(part, ",", ".", 1), 64)
+		total += n * float64(sizes[i])
+	}
+	if total > math.MaxInt64 {
+		return 0, fmt.Errorf("ISO 8601 duration %q is too long", s)
+	}
+	return time.Duration(total), nil
+}
+
+// isoDuration formats d as an ISO 8601 duration with days and time parts
+func isoDuration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+	var out strings.Builder
+	if d < 0 {
+		out.WriteString("-")
+		d = -d
+	}
+	out.WriteString("P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&out, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d == 0 {
+		return out.String()
+	}
+	out.WriteString("T")
+	if hours := d / time.Hour; hours > 0 {
+		fmt.Fprintf(&out, "%dH", hours)
+		d -= hours * time.Hour
+	}
+	if minutes := d / time.Minute; minutes > 0 {
+		fmt.Fprintf(&out, "%dM", minutes)
+		d -= minutes * time.Minute
+	}
+	if d > 0 {
+		seconds := strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+		fmt.Fprintf(&out, "%sS", seconds)
+	}
+	return out.String()
+}
+
+func readDuration(form Value) (Value, error) {
+	text, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("expected a duration string, got %s", form)
+	}
+	d, err := parseDuration(string(text))
+	if err != nil {
+		return nil, err
+	}
+	return Duration{d}, nil
+}
+
+// hasTemporal reports whether any argument is a Duration or Inst, in which
+// case the arithmetic builtins hand over to temporalArithmetic
+func hasTemporal(args []Value) bool {
+	for _, arg := range args {
+		switch arg.(type) {
+		case Duration, Inst:
+			return true
+		}
+	}
+	return false
+}
+
+// temporalArithmetic adds and subtracts durations and instants, and scales
+// durations by numbers
+func temporalArithmetic(op string, args []Value) (Value, error) {
+	if len(args) == 1 {
+		if d, ok := args[0].(Duration); ok && op == "-" {
+			return Duration{-d.Duration}, nil
+		}
+		return nil, NewTypeError("%s cannot apply to %s alone", op, args[0])
+	}
+	result := args[0]
+	for _, arg := range args[1:] {
+		var err error
+		if result, err = temporalStep(op, result, arg); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func temporalStep(op string, a, b Value) (Value, error) {
+	switch op {
+	case "+":
+		switch va := a.(type) {
+		case Duration:
+			switch vb := b.(type) {
+			case Duration:
+				return Duration{va.Duration + vb.Duration}, nil
+			case Inst:
+				return Inst{Time: vb.Time.Add(va.Duration)}, nil
+			}
+		case Inst:
+			if vb, ok := b.(Duration); ok {
+				return Inst{Time: va.Time.Add(vb.Duration)}, nil
+			}
+		}
+	case "-":
+		switch va := a.(type) {
+		case Duration:
+			if vb, ok := b.(Duration); ok {
+				return Duration{va.Duration - vb.Duration}, nil
+			}
+		case Inst:
+			switch vb := b.(type) {
+			case Duration:
+				return Inst{Time: va.Time.Add(-vb.Duration)}, nil
+			case Inst:
+				return Duration{va.Time.Sub(vb.Time)}, nil
+			}
+		}
+	case "*":
+		if va, ok := a.(Duration); ok {
+			if n, ok := b.(Number); ok {
+				return Duration{time.Duration(float64(va.Duration) * n.ToFloat())}, nil
+			}
+		}
+		if n, ok := a.(Number); ok {
+			if vb, ok := b.(Duration); ok {
+				return Duration{time.Duration(n.ToFloat() * float64(vb.Duration))}, nil
+			}
+		}
+	case "/":
+		if va, ok := a.(Duration); ok {
+			switch vb := b.(type) {
+			case Number:
+				if vb.ToFloat() == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				return Duration{time.Duration(float64(va.Duration) / vb.ToFloat())}, nil
+			case Duration:
+				if vb.Duration == 0 {
+					return nil, fmt.Errorf("division by zero")
+				}
+				return NewNumber(float64(va.Duration) / float64(vb.Duration)), nil
+			}
+		}
+	}
+	return nil, NewTypeError("%s cannot combine %s and %s", op, a, b)
+}
+
+// compareTemporal orders two durations or two instants
+func compareTemporal(op string, a, b Value) (int, error) {
+	switch va := a.(type) {
+	case Duration:
+		if vb, ok := b.(Duration); ok {
+			return cmpInt64(int64(va.Duration), int64(vb.Duration)), nil
+		}
+	case Inst:
+		if vb, ok := b.(Inst); ok {
+			return va.Time.Compare(vb.Time), nil
+		}
+	}
+	return 0, NewTypeError("%s cannot compare %s and %s", op, a, b)
+}
+
+func cmpInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func durationArg(name string, value Value) (time.Duration, error) {
+	d, ok := value.(Duration)
+	if !ok {
+		return 0, NewTypeError("%s expects a duration, got %s", name, TypeName(value))
+	}
+	return d.Duration, nil
+}
+
+// setupDurationOperations adds the duration constructors, parse-duration,
+// format-duration, iso-duration, duration-millis, duration? and now
+func setupDurationOperations(env *Environment) {
+	units := []struct {
+		name string
+		size time.Duration
+	}{
+		{"weeks", 7 * 24 * time.Hour},
+		{"days", 24 * time.Hour},
+		{"hours", time.Hour},
+		{"minutes", time.Minute},
+		{"seconds", time.Second},
+		{"millis", time.Millisecond},
+	}
+	for _, unit := range units {
+		unit := unit
+		env.Set(Intern(unit.name), &BuiltinFunction{
+			Name: unit.name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", unit.name, len(args))
+				}
+				n, ok := args[0].(Number)
+				if !ok {
+					return nil, NewTypeError("%s expects a number, got %s", unit.name, TypeName(args[0]))
+				}
+				return Duration{time.Duration(n.ToFloat() * float64(unit.size))}, nil
+			},
+		})
+	}
+
+	env.Set(Intern("parse-duration"), &BuiltinFunction{
+		Name: "parse-duration",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("parse-duration expects 1 argument, got %d", len(args))
+			}
+			text, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("parse-duration expects a string, got %s", TypeName(args[0]))
+			}
+			d, err := parseDuration(string(text))
+			if err != nil {
+				return nil, NewRuntimeError("parse-duration: %v", err)
+			}
+			return Duration{d}, nil
+		},
+	})
+
+	formats := map[string]func(time.Duration) Value{
+		"format-duration": func(d time.Duration) Value { return String(humanizeDuration(d)) },
+		"iso-duration":    func(d time.Duration) Value { return String(isoDuration(d)) },
+		"duration-millis": func(d time.Duration) Value { return NewNumber(d.Milliseconds()) },
+	}
+	for name, format := range formats {
+		name, format := name, format
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				d, err := durationArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				return format(d), nil
+			},
+		})
+	}
+
+	env.Set(Intern("duration?"), &BuiltinFunction{
+		Name: "duration?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("duration? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(Duration); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("now"), &BuiltinFunction{
+		Name: "now",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("now expects 0 arguments, got %d", len(args))
+			}
+			return Inst{Time: time.Now().UTC()}, nil
+		},
+	})
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDurations(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(hours 2)`, `#duration "2h"`},
+		{`(+ (hours 2) (minutes 30))`, `#duration "2h30m"`},
+		{`(hours 1.5)`, `#duration "1h30m"`},
+		{`(days 1)`, `#duration "1d"`},
+		{`(weeks 2)`, `#duration "14d"`},
+		{`(millis 1500)`, `#duration "1s500ms"`},
+		{`(seconds 0)`, `#duration "0s"`},
+		{`(- (minutes 5))`, `#duration "-5m"`},
+		{`(- (hours 1) (minutes 90))`, `#duration "-30m"`},
+		{`(* (minutes 20) 3)`, `#duration "1h"`},
+		{`(* 2 (hours 1))`, `#duration "2h"`},
+		{`(/ (hours 1) 4)`, `#duration "15m"`},
+		{`(/ (hours 1) (minutes 20))`, `3.0`},
+
+		// Durations read back from how they print
+		{`#duration "2h30m"`, `#duration "2h30m"`},
+		{`(= #duration "PT2H30M" (+ (hours 2) (minutes 30)))`, `true`},
+		{`(read-string (str (minutes 45)))`, `#duration "45m"`},
+
+		{`(format-duration (+ (hours 2) (minutes 30)))`, `"2h30m"`},
+		{`(format-duration (seconds 90))`, `"1m30s"`},
+		{`(iso-duration (+ (hours 2) (minutes 30)))`, `"PT2H30M"`},
+		{`(iso-duration (+ (days 1) (hours 12)))`, `"P1DT12H"`},
+		{`(iso-duration (days 3))`, `"P3D"`},
+		{`(iso-duration (millis 1500))`, `"PT1.5S"`},
+		{`(iso-duration (seconds 0))`, `"PT0S"`},
+		{`(iso-duration (- (minutes 1)))`, `"-PT1M"`},
+		{`(duration-millis (minutes 1))`, `60000`},
+
+		{`(parse-duration "PT2H30M")`, `#duration "2h30m"`},
+		{`(parse-duration "P1DT2H")`, `#duration "1d2h"`},
+		{`(parse-duration "P2W")`, `#duration "14d"`},
+		{`(parse-duration "PT0.5S")`, `#duration "500ms"`},
+		{`(parse-duration "pt15m")`, `#duration "15m"`},
+		{`(parse-duration "-PT1H")`, `#duration "-1h"`},
+		{`(parse-duration "1d12h")`, `#duration "1d12h"`},
+		{`(parse-duration "1.5h")`, `#duration "1h30m"`},
+		{`(parse-duration "250ms")`, `#duration "250ms"`},
+
+		// Arithmetic against instants
+		{`(+ #inst "2024-01-01T00:00:00Z" (hours 2) (minutes 30))`, `#inst "2024-01-01T02:30:00Z"`},
+		{`(+ (days 1) #inst "2024-02-28T12:00:00Z")`, `#inst "2024-02-29T12:00:00Z"`},
+		{`(- #inst "2024-01-01T00:00:00Z" (minutes 1))`, `#inst "2023-12-31T23:59:00Z"`},
+		{`(- #inst "2024-01-02T06:00:00Z" #inst "2024-01-01T00:00:00Z")`, `#duration "1d6h"`},
+
+		{`(< (minutes 59) (hours 1))`, `true`},
+		{`(>= (minutes 60) (hours 1))`, `true`},
+		{`(> #inst "2024-01-01T00:00:00Z" #inst "2025-01-01T00:00:00Z")`, `nil`},
+		{`(= (minutes 60) (hours 1))`, `true`},
+		{`(duration? (hours 1))`, `true`},
+		{`(duration? 3600)`, `nil`},
+		{`(inst? (now))`, `true`},
+		{`(< (- (now) (now)) (seconds 1))`, `true`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(parse-duration "P1M")`:  "years or months",
+		`(parse-duration "PT")`:   "invalid ISO 8601 duration",
+		`(parse-duration "soon")`: "invalid duration",
+		`#duration "2x"`:          "invalid duration",
+		`(+ (hours 1) 5)`:         "+ cannot combine",
+		`(+ (now) (now))`:         "+ cannot combine",
+		`(/ (hours 1) 0)`:         "division by zero",
+		`(< (hours 1) 60)`:        "< cannot compare",
+		`(hours "2")`:             "hours expects a number",
+		`(format-duration 5)`:     "format-duration expects a duration",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
 
 // setupArithmeticOperations adds arithmetic and comparison operations to the environment
 func setupArithmeticOperations(env *Environment) {
@@ -11,6 +15,12 @@ func setupArithmeticOperations(env *Environment) {
 			if len(args) == 0 {
 				return NewNumber(int64(0)), nil
 			}
+			if hasTemporal(args) {
+				return temporalArithmetic("+", args)
+			}
+			if hasDecimal(args) {
+				return decimalArithmetic("+", args)
+			}
 
 			result := int64(0)
 			isFloat := false
@@ -28,7 +38,7 @@ func setupArithmeticOperations(env *Environment) {
 						result += num.ToInt()
 					}
 				} else {
-					return nil, NewTypeError("+ expects numbers, got %T", arg)
+					return nil, NewTypeError("+ expects numbers, got %s", TypeName(arg))
 				}
 			}
 
@@ -45,10 +55,16 @@ func setupArithmeticOperations(env *Environment) {
 			if len(args) == 0 {
 				return nil, NewArityError("- expects at least 1 argument")
 			}
+			if hasTemporal(args) {
+				return temporalArithmetic("-", args)
+			}
+			if hasDecimal(args) {
+				return decimalArithmetic("-", args)
+			}
 
 			first, ok := args[0].(Number)
 			if !ok {
-				return nil, NewTypeError("- expects numbers, got %T", args[0])
+				return nil, NewTypeError("- expects numbers, got %s", TypeName(args[0]))
 			}
 
 			if len(args) == 1 {
@@ -76,7 +92,7 @@ func setupArithmeticOperations(env *Environment) {
 						result -= num.ToInt()
 					}
 				} else {
-					return nil, fmt.Errorf("- expects numbers, got %T", arg)
+					return nil, fmt.Errorf("- expects numbers, got %s", TypeName(arg))
 				}
 			}
 
@@ -93,6 +109,12 @@ func setupArithmeticOperations(env *Environment) {
 			if len(args) == 0 {
 				return NewNumber(int64(1)), nil
 			}
+			if hasTemporal(args) {
+				return temporalArithmetic("*", args)
+			}
+			if hasDecimal(args) {
+				return decimalArithmetic("*", args)
+			}
 
 			result := int64(1)
 			isFloat := false
@@ -110,7 +132,7 @@ func setupArithmeticOperations(env *Environment) {
 						result *= num.ToInt()
 					}
 				} else {
-					return nil, fmt.Errorf("* expects numbers, got %T", arg)
+					return nil, fmt.Errorf("* expects numbers, got %s", TypeName(arg))
 				}
 			}
 
@@ -125,12 +147,18 @@ func setupArithmeticOperations(env *Environment) {
 		Name: "/",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) == 0 {
-				return nil, fmt.Errorf("/ expects at least 1 argument")
+				return nil, NewArityError("/ expects at least 1 argument, got %d", len(args))
+			}
+			if hasTemporal(args) {
+				return temporalArithmetic("/", args)
+			}
+			if hasDecimal(args) {
+				return decimalArithmetic("/", args)
 			}
 
 			first, ok := args[0].(Number)
 			if !ok {
-				return nil, fmt.Errorf("/ expects numbers, got %T", args[0])
+				return nil, fmt.Errorf("/ expects numbers, got %s", TypeName(args[0]))
 			}
 
 			if len(args) == 1 {
@@ -151,7 +179,7 @@ func setupArithmeticOperations(env *Environment) {
 					}
 					result /= num.ToFloat()
 				} else {
-					return nil, fmt.Errorf("/ expects numbers, got %T", arg)
+					return nil, fmt.Errorf("/ expects numbers, got %s", TypeName(arg))
 				}
 			}
 
@@ -163,7 +191,7 @@ func setupArithmeticOperations(env *Environment) {
 		Name: "%",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("%% expects 2 arguments")
+				return nil, NewArityError("%% expects 2 arguments, got %d", len(args))
 			}
 
 			n1, ok1 := args[0].(Number)
@@ -192,7 +220,7 @@ func setupArithmeticOperations(env *Environment) {
 		Name: "=",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("= expects at least 2 arguments")
+				return nil, NewArityError("= expects at least 2 arguments, got %d", len(args))
 			}
 
 			first := args[0]
@@ -209,7 +237,17 @@ func setupArithmeticOperations(env *Environment) {
 		Name: "<",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("< expects 2 arguments")
+				return nil, NewArityError("< expects 2 arguments, got %d", len(args))
+			}
+			if hasDecimal(args) || hasTemporal(args) {
+				order, err := compareNumeric("<", args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				if order < 0 {
+					return Symbol("true"), nil
+				}
+				return Nil{}, nil
 			}
 
 			n1, ok1 := args[0].(Number)
@@ -230,7 +268,17 @@ func setupArithmeticOperations(env *Environment) {
 		Name: ">",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("> expects 2 arguments")
+				return nil, NewArityError("> expects 2 arguments, got %d", len(args))
+			}
+			if hasDecimal(args) || hasTemporal(args) {
+				order, err := compareNumeric(">", args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				if order > 0 {
+					return Symbol("true"), nil
+				}
+				return Nil{}, nil
 			}
 
 			n1, ok1 := args[0].(Number)
@@ -251,7 +299,17 @@ func setupArithmeticOperations(env *Environment) {
 		Name: ">=",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf(">= expects 2 arguments")
+				return nil, NewArityError(">= expects 2 arguments, got %d", len(args))
+			}
+			if hasDecimal(args) || hasTemporal(args) {
+				order, err := compareNumeric(">=", args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				if order >= 0 {
+					return Symbol("true"), nil
+				}
+				return Nil{}, nil
 			}
 
 			n1, ok1 := args[0].(Number)
@@ -272,7 +330,17 @@ func setupArithmeticOperations(env *Environment) {
 		Name: "<=",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("<= expects 2 arguments")
+				return nil, NewArityError("<= expects 2 arguments, got %d", len(args))
+			}
+			if hasDecimal(args) || hasTemporal(args) {
+				order, err := compareNumeric("<=", args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				if order <= 0 {
+					return Symbol("true"), nil
+				}
+				return Nil{}, nil
 			}
 
 			n1, ok1 := args[0].(Number)
@@ -289,12 +357,61 @@ func setupArithmeticOperations(env *Environment) {
 		},
 	})
 
+	// Numeric coercion
+	env.Set(Intern("int"), &BuiltinFunction{
+		Name: "int",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("int expects 1 argument, got %d", len(args))
+			}
+
+			switch v := args[0].(type) {
+			case Number:
+				return NewNumber(v.ToInt()), nil
+			case Decimal:
+				return NewNumber(v.round(0, "down").unscaled.Int64()), nil
+			case String:
+				i, err := strconv.ParseInt(strings.TrimSpace(string(v)), 10, 64)
+				if err != nil {
+					return nil, NewTypeError("int cannot parse %q as an integer", string(v))
+				}
+				return NewNumber(i), nil
+			default:
+				return nil, NewTypeError("int expects number or string, got %s", TypeName(args[0]))
+			}
+		},
+	})
+
+	env.Set(Intern("float"), &BuiltinFunction{
+		Name: "float",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("float expects 1 argument, got %d", len(args))
+			}
+
+			switch v := args[0].(type) {
+			case Number:
+				return NewNumber(v.ToFloat()), nil
+			case Decimal:
+				return NewNumber(v.float()), nil
+			case String:
+				f, err := strconv.ParseFloat(strings.TrimSpace(string(v)), 64)
+				if err != nil {
+					return nil, NewTypeError("float cannot parse %q as a number", string(v))
+				}
+				return NewNumber(f), nil
+			default:
+				return nil, NewTypeError("float expects number or string, got %s", TypeName(args[0]))
+			}
+		},
+	})
+
 	// Logical operations
 	env.Set(Intern("not"), &BuiltinFunction{
 		Name: "not",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("not expects 1 argument")
+				return nil, NewArityError("not expects 1 argument, got %d", len(args))
 			}
 
 			// In Lisp, anything that's not nil or false is truthy
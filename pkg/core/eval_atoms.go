@@ -0,0 +1,126 @@
+package core
+
+import "sync"
+
+// Atom is a mutable reference whose value is replaced atomically by swap!
+// and reset!
+type Atom struct {
+	mu       sync.Mutex
+	value    Value
+	version  uint64 // Bumped on every change, so swap! can detect races
+	watchers watchers
+}
+
+// NewAtom creates an atom holding value
+func NewAtom(value Value) *Atom {
+	return &Atom{value: value}
+}
+
+func (a *Atom) String() string {
+	return printString(a)
+}
+
+// Deref returns the current value
+func (a *Atom) Deref() Value {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.value
+}
+
+func (a *Atom) watchList() *watchers {
+	return &a.watchers
+}
+
+// Swap sets the value to f(old, args...), retrying if another swap changed
+// the atom while f ran, then notifies watches
+func (a *Atom) Swap(f Value, args []Value, env *Environment) (Value, error) {
+	for {
+		a.mu.Lock()
+		old, version := a.value, a.version
+		a.mu.Unlock()
+
+		value, err := callFunction(f, append([]Value{old}, args...), env)
+		if err != nil {
+			return nil, err
+		}
+
+		a.mu.Lock()
+		if a.version != version {
+			a.mu.Unlock()
+			continue
+		}
+		a.value = value
+		a.version++
+		a.mu.Unlock()
+		return value, a.watchers.notify(a, old, value, env)
+	}
+}
+
+// modify calls f with the atom locked, for Go code that changes the value in
+// place rather than replacing it. If f reports a change, the atom counts as
+// changed and watches are notified, with the same value as old and new. f
+// must not run Lisp code, which could use the atom.
+func (a *Atom) modify(f func(value Value) (changed bool, err error), env *Environment) error {
+	a.mu.Lock()
+	changed, err := f(a.value)
+	if changed {
+		a.version++
+	}
+	value := a.value
+	a.mu.Unlock()
+	if err != nil || !changed {
+		return err
+	}
+	return a.watchers.notify(a, value, value, env)
+}
+
+// Reset sets the value, then notifies watches
+func (a *Atom) Reset(value Value, env *Environment) (Value, error) {
+	a.mu.Lock()
+	old := a.value
+	a.value = value
+	a.version++
+	a.mu.Unlock()
+	return value, a.watchers.notify(a, old, value, env)
+}
+
+// setupAtomOperations adds atom, swap! and reset!
+func setupAtomOperations(env *Environment) {
+	env.Set(Intern("atom"), &BuiltinFunction{
+		Name: "atom",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("atom expects 1 argument, got %d", len(args))
+			}
+			return NewAtom(args[0]), nil
+		},
+	})
+
+	env.Set(Intern("swap!"), &BuiltinFunction{
+		Name: "swap!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("swap! expects at least 2 arguments, got %d", len(args))
+			}
+			a, ok := args[0].(*Atom)
+			if !ok {
+				return nil, NewTypeError("swap! expects an atom, got %s", TypeName(args[0]))
+			}
+			return a.Swap(args[1], args[2:], env)
+		},
+	})
+
+	env.Set(Intern("reset!"), &BuiltinFunction{
+		Name: "reset!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("reset! expects 2 arguments, got %d", len(args))
+			}
+			a, ok := args[0].(*Atom)
+			if !ok {
+				return nil, NewTypeError("reset! expects an atom, got %s", TypeName(args[0]))
+			}
+			return a.Reset(args[1], env)
+		},
+	})
+}
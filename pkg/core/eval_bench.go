@@ -0,0 +1,191 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"time"
+)
+
+// benchOptions controls how bench-fn measures a function
+type benchOptions struct {
+	label      string
+	iterations int64 // Calls per sample
+	samples    int64
+	warmup     int64 // Calls before the first sample
+}
+
+// setupBenchOperations adds the benchmark harness primitives
+func setupBenchOperations(env *Environment) {
+	env.Set(Intern("bench-fn"), &BuiltinFunction{
+		Name: "bench-fn",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) == 0 {
+				return nil, NewArityError("bench-fn expects at least 1 argument, got 0")
+			}
+			if _, ok := args[0].(Callable); !ok {
+				return nil, NewTypeError("bench-fn expects a function, got %s", TypeName(args[0]))
+			}
+
+			opts, err := parseBenchOptions(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return runBenchmark(args[0], opts, env)
+		},
+	})
+
+	env.Set(Intern("bench-report"), &BuiltinFunction{
+		Name: "bench-report",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("bench-report expects 1 argument, got %d", len(args))
+			}
+			stats, ok := args[0].(*HashMap)
+			if !ok {
+				return nil, NewTypeError("bench-report expects a hash-map, got %s", TypeName(args[0]))
+			}
+			return String(formatBenchReport(stats)), nil
+		},
+	})
+}
+
+// parseBenchOptions reads :iterations, :samples, :warmup and :label
+func parseBenchOptions(args []Value) (benchOptions, error) {
+	opts := benchOptions{iterations: 1000, samples: 10, warmup: -1}
+	if len(args)%2 != 0 {
+		return opts, NewArityError("bench-fn expects keyword/value option pairs")
+	}
+
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(Keyword)
+		if !ok {
+			return opts, NewTypeError("bench-fn option must be a keyword, got %s", TypeName(args[i]))
+		}
+
+		if key == "label" {
+			opts.label = args[i+1].String()
+			if str, ok := args[i+1].(String); ok {
+				opts.label = string(str)
+			}
+			continue
+		}
+
+		num, ok := args[i+1].(Number)
+		if !ok || num.ToInt() < 0 || (key != "warmup" && num.ToInt() < 1) {
+			return opts, NewTypeError("bench-fn option :%s expects a positive integer, got %s", key, args[i+1])
+		}
+		switch key {
+		case "iterations":
+			opts.iterations = num.ToInt()
+		case "samples":
+			opts.samples = num.ToInt()
+		case "warmup":
+			opts.warmup = num.ToInt()
+		default:
+			return opts, NewRuntimeError("unknown bench-fn option: :%s", key)
+		}
+	}
+
+	// Warm up for one sample's worth of calls unless told otherwise
+	if opts.warmup < 0 {
+		opts.warmup = opts.iterations
+	}
+	return opts, nil
+}
+
+// runBenchmark times samples of fn and summarizes them as a hash-map with
+// per-call times in nanoseconds
+func runBenchmark(fn Value, opts benchOptions, env *Environment) (Value, error) {
+	for i := int64(0); i < opts.warmup; i++ {
+		if _, err := callFunction(fn, nil, env); err != nil {
+			return nil, err
+		}
+	}
+
+	times := make([]float64, opts.samples)
+	var mallocs, bytes uint64
+	var before, after runtime.MemStats
+
+	for s := range times {
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		for i := int64(0); i < opts.iterations; i++ {
+			if _, err := callFunction(fn, nil, env); err != nil {
+				return nil, err
+			}
+		}
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		times[s] = float64(elapsed.Nanoseconds()) / float64(opts.iterations)
+		mallocs += after.Mallocs - before.Mallocs
+		bytes += after.TotalAlloc - before.TotalAlloc
+	}
+
+	calls := float64(opts.samples * opts.iterations)
+	mean, median, stddev := sampleStats(times)
+
+	stats := NewHashMap()
+	stats.Set(InternKeyword("label"), String(opts.label))
+	stats.Set(InternKeyword("samples"), NewNumber(opts.samples))
+	stats.Set(InternKeyword("iterations"), NewNumber(opts.iterations))
+	stats.Set(InternKeyword("mean"), NewNumber(mean))
+	stats.Set(InternKeyword("median"), NewNumber(median))
+	stats.Set(InternKeyword("stddev"), NewNumber(stddev))
+	stats.Set(InternKeyword("min"), NewNumber(times[0]))
+	stats.Set(InternKeyword("max"), NewNumber(times[len(times)-1]))
+	stats.Set(InternKeyword("allocs"), NewNumber(float64(mallocs)/calls))
+	stats.Set(InternKeyword("bytes"), NewNumber(float64(bytes)/calls))
+	return stats, nil
+}
+
+// sampleStats sorts times in place and returns their mean, median and
+// sample standard deviation
+func sampleStats(times []float64) (mean, median, stddev float64) {
+	sort.Float64s(times)
+
+	for _, t := range times {
+		mean += t
+	}
+	mean /= float64(len(times))
+
+	if n := len(times); n%2 == 1 {
+		median = times[n/2]
+	} else {
+		median = (times[n/2-1] + times[n/2]) / 2
+	}
+
+	if len(times) > 1 {
+		var sum float64
+		for _, t := range times {
+			sum += (t - mean) * (t - mean)
+		}
+		stddev = math.Sqrt(sum / float64(len(times)-1))
+	}
+	return mean, median, stddev
+}
+
+// formatBenchReport renders bench-fn results as a short human-readable summary
+func formatBenchReport(stats *HashMap) string {
+	number := func(key string) float64 {
+		if n, ok := stats.Get(InternKeyword(key)).(Number); ok {
+			return n.ToFloat()
+		}
+		return 0
+	}
+	duration := func(key string) string {
+		return time.Duration(number(key)).String()
+	}
+
+	label := ""
+	if str, ok := stats.Get(InternKeyword("label")).(String); ok && str != "" {
+		label = string(str) + "\n"
+	}
+
+	return fmt.Sprintf("%s  %d samples x %d iterations\n  mean %s  median %s  stddev %s  (min %s, max %s)\n  %.1f allocs/op  %.0f B/op",
+		label, int64(number("samples")), int64(number("iterations")),
+		duration("mean"), duration("median"), duration("stddev"), duration("min"), duration("max"),
+		number("allocs"), number("bytes"))
+}
@@ -0,0 +1,321 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// cliOption is a parsed option spec from parse-opts
+type cliOption struct {
+	id          Keyword
+	short       string
+	long        string
+	argName     string // Empty for boolean flags
+	description string
+	defaultVal  Value
+	parseFn     Value
+	validateFn  Value
+	validateMsg string
+}
+
+// setupCLIOperations adds command-line argument parsing to the environment
+func setupCLIOperations(env *Environment) {
+	// Arguments passed to the running script (set by the golisp command)
+	env.Set(Intern("*command-line-args*"), NewVector())
+
+	env.Set(Intern("parse-opts"), &BuiltinFunction{
+		Name: "parse-opts",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("parse-opts expects 2 arguments, got %d", len(args))
+			}
+
+			argv, err := collectionToSlice(args[0])
+			if err != nil {
+				return nil, NewTypeError("parse-opts expects a collection of arguments, got %s", TypeName(args[0]))
+			}
+
+			specs, err := collectionToSlice(args[1])
+			if err != nil {
+				return nil, NewTypeError("parse-opts expects a collection of option specs, got %s", TypeName(args[1]))
+			}
+
+			var options []*cliOption
+			for _, spec := range specs {
+				option, err := parseCLIOptionSpec(spec, env)
+				if err != nil {
+					return nil, err
+				}
+				options = append(options, option)
+			}
+
+			return parseCommandLine(argv, options, env)
+		},
+	})
+}
+
+// parseCLIOptionSpec parses ["-p" "--port PORT" "Port number" :default 80 ...]
+func parseCLIOptionSpec(spec Value, env *Environment) (*cliOption, error) {
+	parts, err := evalSpecElements(spec, env)
+	if err != nil {
+		return nil, err
+	}
+
+	option := &cliOption{defaultVal: Nil{}}
+
+	// Leading strings (or nil for a missing short name) are positional
+	var positional []string
+	i := 0
+	for ; i < len(parts) && len(positional) < 3; i++ {
+		if _, isNil := parts[i].(Nil); isNil && i == 0 {
+			positional = append(positional, "")
+			continue
+		}
+		str, ok := parts[i].(String)
+		if !ok {
+			break
+		}
+		positional = append(positional, string(str))
+	}
+
+	for _, part := range positional {
+		switch {
+		case strings.HasPrefix(part, "--"):
+			fields := strings.Fields(part)
+			option.long = fields[0]
+			if len(fields) > 1 {
+				option.argName = fields[1]
+			}
+		case strings.HasPrefix(part, "-") && option.short == "":
+			fields := strings.Fields(part)
+			option.short = fields[0]
+			if len(fields) > 1 && option.argName == "" {
+				option.argName = fields[1]
+			}
+		case part != "":
+			option.description = part
+		}
+	}
+
+	if option.short == "" && option.long == "" {
+		return nil, NewRuntimeError("option spec %s has no short or long name", spec)
+	}
+
+	if (len(parts)-i)%2 != 0 {
+		return nil, NewRuntimeError("option spec %s has an odd number of keyword arguments", spec)
+	}
+
+	for ; i < len(parts); i += 2 {
+		key, ok := parts[i].(Keyword)
+		if !ok {
+			return nil, NewTypeError("option spec %s expects keyword, got %s", spec, TypeName(parts[i]))
+		}
+
+		value := parts[i+1]
+		switch key {
+		case "id":
+			id, ok := value.(Keyword)
+			if !ok {
+				return nil, NewTypeError("option :id must be a keyword, got %s", TypeName(value))
+			}
+			option.id = id
+		case "default":
+			option.defaultVal = value
+		case "parse-fn":
+			option.parseFn = value
+		case "validate":
+			validate, err := evalSpecElements(value, env)
+			if err != nil || len(validate) != 2 {
+				return nil, NewRuntimeError("option :validate expects [pred message]")
+			}
+			option.validateFn = validate[0]
+			if msg, ok := validate[1].(String); ok {
+				option.validateMsg = string(msg)
+			}
+		case "desc":
+			if desc, ok := value.(String); ok {
+				option.description = string(desc)
+			}
+		default:
+			return nil, NewRuntimeError("unknown option spec key: %s", key)
+		}
+	}
+
+	if option.id == "" {
+		name := strings.TrimLeft(option.long, "-")
+		if name == "" {
+			name = strings.TrimLeft(option.short, "-")
+		}
+		option.id = InternKeyword(name)
+	}
+
+	return option, nil
+}
+
+// evalSpecElements evaluates the elements of a literal spec vector, since
+// vector literals are not evaluated and specs usually reference functions
+func evalSpecElements(spec Value, env *Environment) ([]Value, error) {
+	parts, err := collectionToSlice(spec)
+	if err != nil {
+		return nil, NewTypeError("option spec must be a vector, got %s", TypeName(spec))
+	}
+
+	values := make([]Value, len(parts))
+	for i, part := range parts {
+		value, err := Eval(part, env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// parseCommandLine walks argv, collecting options, positional arguments and errors
+func parseCommandLine(argv []Value, options []*cliOption, env *Environment) (Value, error) {
+	result := NewHashMap()
+	for _, option := range options {
+		if _, isNil := option.defaultVal.(Nil); !isNil {
+			result.Set(option.id, option.defaultVal)
+		}
+	}
+
+	var arguments []Value
+	var errors []Value
+
+	for i := 0; i < len(argv); i++ {
+		arg, ok := argv[i].(String)
+		if !ok {
+			return nil, NewTypeError("parse-opts expects string arguments, got %s", TypeName(argv[i]))
+		}
+		token := string(arg)
+
+		if token == "--" {
+			arguments = append(arguments, argv[i+1:]...)
+			break
+		}
+
+		if !strings.HasPrefix(token, "-") || token == "-" {
+			arguments = append(arguments, arg)
+			continue
+		}
+
+		// Support --name=value
+		name, inlineValue, hasInline := strings.Cut(token, "=")
+		if !strings.HasPrefix(token, "--") {
+			name, hasInline = token, false
+		}
+
+		option := findCLIOption(options, name)
+		if option == nil {
+			errors = append(errors, String(fmt.Sprintf("Unknown option: %q", name)))
+			continue
+		}
+
+		if option.argName == "" {
+			result.Set(option.id, Symbol("true"))
+			continue
+		}
+
+		var raw string
+		switch {
+		case hasInline:
+			raw = inlineValue
+		case i+1 < len(argv):
+			i++
+			next, ok := argv[i].(String)
+			if !ok {
+				return nil, NewTypeError("parse-opts expects string arguments, got %s", TypeName(argv[i]))
+			}
+			raw = string(next)
+		default:
+			errors = append(errors, String(fmt.Sprintf("Missing required argument for %q", name+" "+option.argName)))
+			continue
+		}
+
+		var value Value = String(raw)
+		if option.parseFn != nil {
+			parsed, err := callFunction(option.parseFn, []Value{value}, env)
+			if err != nil {
+				errors = append(errors, String(fmt.Sprintf("Error while parsing option %q: %v", name+" "+raw, err)))
+				continue
+			}
+			value = parsed
+		}
+
+		if option.validateFn != nil {
+			valid, err := callFunction(option.validateFn, []Value{value}, env)
+			if err != nil || !isTruthy(valid) {
+				msg := fmt.Sprintf("Failed to validate %q", name+" "+raw)
+				if option.validateMsg != "" {
+					msg += ": " + option.validateMsg
+				}
+				errors = append(errors, String(msg))
+				continue
+			}
+		}
+
+		result.Set(option.id, value)
+	}
+
+	var errorsValue Value = Nil{}
+	if len(errors) > 0 {
+		errorsValue = NewVector(errors...)
+	}
+
+	return NewHashMapWithPairs(
+		InternKeyword("options"), result,
+		InternKeyword("arguments"), NewVector(arguments...),
+		InternKeyword("errors"), errorsValue,
+		InternKeyword("summary"), String(cliSummary(options)),
+	), nil
+}
+
+// findCLIOption looks up an option by its short or long name
+func findCLIOption(options []*cliOption, name string) *cliOption {
+	for _, option := range options {
+		if option.short == name || option.long == name {
+			return option
+		}
+	}
+	return nil
+}
+
+// cliSummary renders aligned usage text for the option specs
+func cliSummary(options []*cliOption) string {
+	rows := make([][3]string, len(options))
+	widths := [3]int{}
+
+	for i, option := range options {
+		var names []string
+		if option.short != "" {
+			names = append(names, option.short)
+		}
+		if option.long != "" {
+			names = append(names, option.long)
+		}
+		flag := strings.Join(names, ", ")
+		if option.argName != "" {
+			flag += " " + option.argName
+		}
+
+		defaultText := ""
+		if _, isNil := option.defaultVal.(Nil); !isNil {
+			defaultText = option.defaultVal.String()
+		}
+
+		rows[i] = [3]string{flag, defaultText, option.description}
+		for j, cell := range rows[i] {
+			if len(cell) > widths[j] {
+				widths[j] = len(cell)
+			}
+		}
+	}
+
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		line := fmt.Sprintf("  %-*s  %-*s  %s", widths[0], row[0], widths[1], row[1], row[2])
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -1,6 +1,9 @@
 package core
 
-import "fmt"
+import (
+	"fmt"
+	"unicode/utf8"
+)
 
 // setupCollectionOperations adds collection operations and type predicates to the environment
 func setupCollectionOperations(env *Environment) {
@@ -9,7 +12,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "count",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("count expects 1 argument")
+				return nil, NewArityError("count expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -28,11 +31,17 @@ func setupCollectionOperations(env *Environment) {
 			case *Set:
 				return NewNumber(int64(coll.Count())), nil
 			case String:
-				return NewNumber(int64(len(string(coll)))), nil
+				return NewNumber(int64(utf8.RuneCountInString(string(coll)))), nil
+			case *LazySeq:
+				items, err := collectionToSlice(coll)
+				if err != nil {
+					return nil, err
+				}
+				return NewNumber(int64(len(items))), nil
 			case Nil:
 				return NewNumber(int64(0)), nil
 			default:
-				return nil, fmt.Errorf("count expects collection, got %T", args[0])
+				return nil, fmt.Errorf("count expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -42,7 +51,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "length",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("length expects 1 argument")
+				return nil, NewArityError("length expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -61,11 +70,17 @@ func setupCollectionOperations(env *Environment) {
 			case *Set:
 				return NewNumber(int64(coll.Count())), nil
 			case String:
-				return NewNumber(int64(len(string(coll)))), nil
+				return NewNumber(int64(utf8.RuneCountInString(string(coll)))), nil
+			case *LazySeq:
+				items, err := collectionToSlice(coll)
+				if err != nil {
+					return nil, err
+				}
+				return NewNumber(int64(len(items))), nil
 			case Nil:
 				return NewNumber(int64(0)), nil
 			default:
-				return nil, fmt.Errorf("length expects collection, got %T", args[0])
+				return nil, fmt.Errorf("length expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -74,7 +89,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "empty?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("empty? expects 1 argument")
+				return nil, NewArityError("empty? expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -103,10 +118,19 @@ func setupCollectionOperations(env *Environment) {
 					return Symbol("true"), nil
 				}
 				return Nil{}, nil
+			case *LazySeq:
+				_, ok, err := seqFirst(coll)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					return Nil{}, nil
+				}
+				return Symbol("true"), nil
 			case Nil:
 				return Symbol("true"), nil
 			default:
-				return nil, fmt.Errorf("empty? expects collection, got %T", args[0])
+				return nil, fmt.Errorf("empty? expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -115,7 +139,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "nth",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 || len(args) > 3 {
-				return nil, fmt.Errorf("nth expects 2-3 arguments")
+				return nil, NewArityError("nth expects 2-3 arguments, got %d", len(args))
 			}
 
 			n, ok := args[1].(Number)
@@ -147,7 +171,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return coll.Get(index), nil
 			case String:
-				s := string(coll)
+				s := []rune(string(coll))
 				if index < 0 || index >= len(s) {
 					if len(args) == 3 {
 						return args[2], nil // Return default value
@@ -155,8 +179,29 @@ func setupCollectionOperations(env *Environment) {
 					return nil, fmt.Errorf("index %d out of bounds", index)
 				}
 				return String(string(s[index])), nil
+			case *LazySeq:
+				var current Value = coll
+				for i := 0; index >= 0; i++ {
+					item, ok, err := seqFirst(current)
+					if err != nil {
+						return nil, err
+					}
+					if !ok {
+						break
+					}
+					if i == index {
+						return item, nil
+					}
+					if current, err = seqRest(current); err != nil {
+						return nil, err
+					}
+				}
+				if len(args) == 3 {
+					return args[2], nil // Return default value
+				}
+				return nil, fmt.Errorf("index %d out of bounds", index)
 			default:
-				return nil, fmt.Errorf("nth expects collection, got %T", args[0])
+				return nil, fmt.Errorf("nth expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -165,7 +210,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "conj",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("conj expects at least 2 arguments")
+				return nil, NewArityError("conj expects at least 2 arguments, got %d", len(args))
 			}
 
 			coll := args[0]
@@ -197,7 +242,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return result, nil
 			default:
-				return nil, fmt.Errorf("conj expects collection, got %T", coll)
+				return nil, fmt.Errorf("conj expects collection, got %s", TypeName(coll))
 			}
 		},
 	})
@@ -207,7 +252,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "cons",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("cons expects 2 arguments")
+				return nil, NewArityError("cons expects 2 arguments, got %d", len(args))
 			}
 
 			// If the second argument is nil, create a list with nil as the second element
@@ -223,7 +268,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "first",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("first expects 1 argument")
+				return nil, NewArityError("first expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -237,10 +282,16 @@ func setupCollectionOperations(env *Environment) {
 					return Nil{}, nil
 				}
 				return coll.Get(0), nil
+			case *LazySeq:
+				item, ok, err := seqFirst(coll)
+				if err != nil || !ok {
+					return Nil{}, err
+				}
+				return item, nil
 			case Nil:
 				return Nil{}, nil
 			default:
-				return nil, fmt.Errorf("first expects collection, got %T", args[0])
+				return nil, fmt.Errorf("first expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -249,7 +300,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "rest",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("rest expects 1 argument")
+				return nil, NewArityError("rest expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -267,10 +318,12 @@ func setupCollectionOperations(env *Environment) {
 					elements[i-1] = coll.Get(i)
 				}
 				return NewList(elements...), nil
+			case *LazySeq:
+				return seqRest(coll)
 			case Nil:
 				return (*List)(nil), nil
 			default:
-				return nil, fmt.Errorf("rest expects collection, got %T", args[0])
+				return nil, fmt.Errorf("rest expects collection, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -287,7 +340,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "list?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("list? expects 1 argument")
+				return nil, NewArityError("list? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(*List); ok {
@@ -301,7 +354,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "vector?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("vector? expects 1 argument")
+				return nil, NewArityError("vector? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(*Vector); ok {
@@ -328,11 +381,40 @@ func setupCollectionOperations(env *Environment) {
 		},
 	})
 
+	// sorted-map keeps its keys sorted, so it prints the same however it was built
+	env.Set(Intern("sorted-map"), &BuiltinFunction{
+		Name: "sorted-map",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args)%2 != 0 {
+				return nil, fmt.Errorf("sorted-map expects even number of arguments")
+			}
+			m := NewSortedMap()
+			for i := 0; i < len(args); i += 2 {
+				m.Set(args[i], args[i+1])
+			}
+			return m, nil
+		},
+	})
+
+	env.Set(Intern("sorted?"), &BuiltinFunction{
+		Name: "sorted?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("sorted? expects 1 argument, got %d", len(args))
+			}
+
+			if hm, ok := args[0].(*HashMap); ok && hm.sorted {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
 	env.Set(Intern("hash-map?"), &BuiltinFunction{
 		Name: "hash-map?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("hash-map? expects 1 argument")
+				return nil, NewArityError("hash-map? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(*HashMap); ok {
@@ -353,7 +435,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "set?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("set? expects 1 argument")
+				return nil, NewArityError("set? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(*Set); ok {
@@ -367,7 +449,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "get",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 || len(args) > 3 {
-				return nil, fmt.Errorf("get expects 2-3 arguments")
+				return nil, NewArityError("get expects 2-3 arguments, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -390,7 +472,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return nil, fmt.Errorf("get expects number index for vector")
 			default:
-				return nil, fmt.Errorf("get expects hash-map or vector, got %T", args[0])
+				return nil, fmt.Errorf("get expects hash-map or vector, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -404,7 +486,7 @@ func setupCollectionOperations(env *Environment) {
 
 			if hm, ok := args[0].(*HashMap); ok {
 				// Create a new hash-map with the same pairs
-				newHM := NewHashMap()
+				newHM := hm.empty()
 				for _, key := range hm.keys {
 					newHM.Set(key, hm.Get(key))
 				}
@@ -422,17 +504,14 @@ func setupCollectionOperations(env *Environment) {
 		Name: "dissoc",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("dissoc expects at least 2 arguments")
+				return nil, NewArityError("dissoc expects at least 2 arguments, got %d", len(args))
 			}
 
 			if hm, ok := args[0].(*HashMap); ok {
-				newHM := NewHashMap()
-				keysToRemove := make(map[string]bool)
-				for i := 1; i < len(args); i++ {
-					keysToRemove[hm.keyToString(args[i])] = true
-				}
+				newHM := hm.empty()
+				keysToRemove := NewSetWithElements(args[1:]...)
 				for _, key := range hm.keys {
-					if !keysToRemove[hm.keyToString(key)] {
+					if !keysToRemove.Contains(key) {
 						newHM.Set(key, hm.Get(key))
 					}
 				}
@@ -446,7 +525,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "contains?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("contains? expects 2 arguments")
+				return nil, NewArityError("contains? expects 2 arguments, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -461,7 +540,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return Nil{}, nil
 			default:
-				return nil, fmt.Errorf("contains? expects hash-map or set, got %T", args[0])
+				return nil, fmt.Errorf("contains? expects hash-map or set, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -476,11 +555,11 @@ func setupCollectionOperations(env *Environment) {
 
 			hm, ok := args[0].(*HashMap)
 			if !ok {
-				return nil, fmt.Errorf("hash-map-put expects hash-map as first argument, got %T", args[0])
+				return nil, fmt.Errorf("hash-map-put expects hash-map as first argument, got %s", TypeName(args[0]))
 			}
 
 			// Create new hash-map with all existing mappings
-			newHM := NewHashMap()
+			newHM := hm.empty()
 			for _, key := range hm.keys {
 				newHM.Set(key, hm.Get(key))
 			}
@@ -505,7 +584,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "keys",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("keys expects 1 argument")
+				return nil, NewArityError("keys expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -515,7 +594,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return NewList(coll.keys...), nil
 			default:
-				return nil, fmt.Errorf("keys expects hash-map, got %T", args[0])
+				return nil, fmt.Errorf("keys expects hash-map, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -524,7 +603,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "vals",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("vals expects 1 argument")
+				return nil, NewArityError("vals expects 1 argument, got %d", len(args))
 			}
 
 			switch coll := args[0].(type) {
@@ -538,7 +617,7 @@ func setupCollectionOperations(env *Environment) {
 				}
 				return NewList(values...), nil
 			default:
-				return nil, fmt.Errorf("vals expects hash-map, got %T", args[0])
+				return nil, fmt.Errorf("vals expects hash-map, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -547,7 +626,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "zipmap",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("zipmap expects 2 arguments")
+				return nil, NewArityError("zipmap expects 2 arguments, got %d", len(args))
 			}
 
 			// Convert both arguments to slices of values
@@ -581,13 +660,13 @@ func setupCollectionOperations(env *Environment) {
 		Name: "union",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("union expects at least 2 arguments")
+				return nil, NewArityError("union expects at least 2 arguments, got %d", len(args))
 			}
 
 			// All arguments must be sets
 			for i, arg := range args {
 				if _, ok := arg.(*Set); !ok {
-					return nil, fmt.Errorf("union expects set as argument %d, got %T", i+1, arg)
+					return nil, fmt.Errorf("union expects set as argument %d, got %s", i+1, TypeName(arg))
 				}
 			}
 
@@ -609,13 +688,13 @@ func setupCollectionOperations(env *Environment) {
 		Name: "intersection",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("intersection expects at least 2 arguments")
+				return nil, NewArityError("intersection expects at least 2 arguments, got %d", len(args))
 			}
 
 			// All arguments must be sets
 			for i, arg := range args {
 				if _, ok := arg.(*Set); !ok {
-					return nil, fmt.Errorf("intersection expects set as argument %d, got %T", i+1, arg)
+					return nil, fmt.Errorf("intersection expects set as argument %d, got %s", i+1, TypeName(arg))
 				}
 			}
 
@@ -647,13 +726,13 @@ func setupCollectionOperations(env *Environment) {
 		Name: "difference",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 {
-				return nil, fmt.Errorf("difference expects at least 2 arguments")
+				return nil, NewArityError("difference expects at least 2 arguments, got %d", len(args))
 			}
 
 			// All arguments must be sets
 			for i, arg := range args {
 				if _, ok := arg.(*Set); !ok {
-					return nil, fmt.Errorf("difference expects set as argument %d, got %T", i+1, arg)
+					return nil, fmt.Errorf("difference expects set as argument %d, got %s", i+1, TypeName(arg))
 				}
 			}
 
@@ -685,7 +764,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "subset?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("subset? expects 2 arguments")
+				return nil, NewArityError("subset? expects 2 arguments, got %d", len(args))
 			}
 
 			set1, ok1 := args[0].(*Set)
@@ -708,7 +787,7 @@ func setupCollectionOperations(env *Environment) {
 		Name: "superset?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("superset? expects 2 arguments")
+				return nil, NewArityError("superset? expects 2 arguments, got %d", len(args))
 			}
 
 			set1, ok1 := args[0].(*Set)
@@ -726,6 +805,75 @@ func setupCollectionOperations(env *Environment) {
 			return Symbol("true"), nil
 		},
 	})
+
+	// Counting and grouping key on the values themselves, so 1 and "1"
+	// stay apart
+	env.Set(Intern("frequencies"), &BuiltinFunction{
+		Name: "frequencies",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("frequencies expects 1 argument, got %d", len(args))
+			}
+			items, err := collectionToSlice(args[0])
+			if err != nil {
+				return nil, NewTypeError("frequencies expects a collection, got %s", TypeName(args[0]))
+			}
+			counts := NewHashMap()
+			for _, item := range items {
+				n, _ := counts.Get(item).(Number)
+				counts.Set(item, NewNumber(n.ToInt()+1))
+			}
+			return counts, nil
+		},
+	})
+
+	env.Set(Intern("group-by"), &BuiltinFunction{
+		Name: "group-by",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("group-by expects 2 arguments, got %d", len(args))
+			}
+			items, err := collectionToSlice(args[1])
+			if err != nil {
+				return nil, NewTypeError("group-by expects a collection, got %s", TypeName(args[1]))
+			}
+			groups := NewHashMap()
+			for _, item := range items {
+				key, err := callFunction(args[0], []Value{item}, env)
+				if err != nil {
+					return nil, err
+				}
+				// The group vectors are new, so they can grow in place
+				group, ok := groups.Get(key).(*Vector)
+				if !ok {
+					group = NewVector()
+					groups.Set(key, group)
+				}
+				group.elements = append(group.elements, item)
+			}
+			return groups, nil
+		},
+	})
+
+	env.Set(Intern("map-indexed"), &BuiltinFunction{
+		Name: "map-indexed",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("map-indexed expects 2 arguments, got %d", len(args))
+			}
+			items, err := collectionToSlice(args[1])
+			if err != nil {
+				return nil, NewTypeError("map-indexed expects a collection, got %s", TypeName(args[1]))
+			}
+			results := make([]Value, len(items))
+			for i, item := range items {
+				if results[i], err = callFunction(args[0], []Value{NewNumber(i), item}, env); err != nil {
+					return nil, err
+				}
+			}
+			return NewList(results...), nil
+		},
+	})
 }
 
 // Helper function to convert a value to a list
@@ -733,6 +881,10 @@ func toList(v Value) *List {
 	if list, ok := v.(*List); ok {
 		return list
 	}
+	if seq, ok := v.(*LazySeq); ok {
+		items, _ := collectionToSlice(seq)
+		return NewList(items...)
+	}
 	if v == nil {
 		return nil
 	}
@@ -767,9 +919,25 @@ func collectionToSlice(coll Value) ([]Value, error) {
 			result = append(result, elem)
 		}
 		return result, nil
+	case *LazySeq:
+		var result []Value
+		var current Value = c
+		for {
+			item, ok, err := seqFirst(current)
+			if err != nil {
+				return result, err
+			}
+			if !ok {
+				return result, nil
+			}
+			result = append(result, item)
+			if current, err = seqRest(current); err != nil {
+				return result, err
+			}
+		}
 	case Nil:
 		return []Value{}, nil
 	default:
-		return nil, fmt.Errorf("expected collection, got %T", coll)
+		return nil, fmt.Errorf("expected collection, got %s", TypeName(coll))
 	}
 }
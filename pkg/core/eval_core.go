@@ -4,11 +4,20 @@ import (
 	"fmt"
 )
 
-// Function interface for callable values
-type Function interface {
+// Callable is the protocol for every value that can be applied to
+// arguments: builtins, user functions, vars, keywords, and vectors, hash-maps
+// and sets as functions of their indices, keys and elements. All call sites,
+// from the evaluator to builtins taking function arguments, go through it
+type Callable interface {
 	Call(args []Value, env *Environment) (Value, error)
 }
 
+// Function is the former name of Callable.
+//
+// Deprecated: use Callable. Note that fn? is now only true for builtin and
+// user functions; use ifn? for anything Callable, like keywords and vars.
+type Function = Callable
+
 // BuiltinFunction represents a built-in function
 type BuiltinFunction struct {
 	Name string
@@ -28,6 +37,9 @@ type UserFunction struct {
 	Params *List
 	Body   Value
 	Env    *Environment
+	Name   Symbol   // Set by defn, empty for anonymous functions
+	Doc    string   // Optional docstring
+	Meta   *HashMap // Optional attribute map
 }
 
 // Macro represents a macro
@@ -36,6 +48,8 @@ type Macro struct {
 	Params *List
 	Body   Value
 	Env    *Environment
+	Doc    string   // Optional docstring
+	Meta   *HashMap // Optional attribute map
 }
 
 func (uf *UserFunction) Call(args []Value, env *Environment) (Value, error) {
@@ -51,14 +65,31 @@ func (uf *UserFunction) Call(args []Value, env *Environment) (Value, error) {
 		paramCount++
 	}
 
+	name := string(uf.Name)
+	if name == "" {
+		name = "anonymous"
+	}
+	frame, err := enterCall(name, env)
+	if uf.Name == "" {
+		name = "anonymous function"
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	// Function execution with recur support
 	currentArgs := args
 	for {
+		if err := checkDeadline(env); err != nil {
+			return nil, err
+		}
+
 		// Create new environment for function execution
 		fnEnv := NewEnvironment(uf.Env)
+		fnEnv.calls = frame
 
 		// Bind parameters to arguments
-		err := bindParams(uf.Params, currentArgs, fnEnv)
+		err := bindParams(name, uf.Params, currentArgs, fnEnv)
 		if err != nil {
 			return nil, err
 		}
@@ -76,12 +107,12 @@ func (uf *UserFunction) Call(args []Value, env *Environment) (Value, error) {
 				// Variadic function - check minimum args
 				minArgs := paramCount
 				if len(recurVal.Values) < minArgs {
-					return nil, fmt.Errorf("recur expects at least %d arguments, got %d", minArgs, len(recurVal.Values))
+					return nil, NewArityError("recur expects at least %d arguments, got %d", minArgs, len(recurVal.Values))
 				}
 			} else {
 				// Regular function - exact arity
 				if len(recurVal.Values) != paramCount {
-					return nil, fmt.Errorf("recur expects %d arguments, got %d", paramCount, len(recurVal.Values))
+					return nil, NewArityError("recur expects %d arguments, got %d", paramCount, len(recurVal.Values))
 				}
 			}
 			
@@ -103,8 +134,10 @@ func (m *Macro) String() string {
 	return fmt.Sprintf("#<macro:%s>", m.Name)
 }
 
-// bindParams binds function parameters to arguments, supporting variadic parameters
-func bindParams(params *List, args []Value, env *Environment) error {
+// bindParams binds args to params in env, a parameter after & collecting
+// the rest; name describes the function in arity errors, which also show the
+// expected parameters
+func bindParams(name string, params *List, args []Value, env *Environment) error {
 	paramList := listToSlice(params)
 
 	// Check for variadic parameters (& rest-param)
@@ -126,7 +159,7 @@ func bindParams(params *List, args []Value, env *Environment) error {
 		// Variadic function
 		minArgs := restParamIndex
 		if len(args) < minArgs {
-			return fmt.Errorf("function expects at least %d arguments, got %d", minArgs, len(args))
+			return NewArityError("%s expects at least %d arguments, got %d (arglists: %s)", name, minArgs, len(args), NewVector(paramList...))
 		}
 
 		// Bind regular parameters
@@ -134,14 +167,14 @@ func bindParams(params *List, args []Value, env *Environment) error {
 			if sym, ok := paramList[i].(Symbol); ok {
 				env.Set(sym, args[i])
 			} else {
-				return fmt.Errorf("parameter must be a symbol, got %T", paramList[i])
+				return fmt.Errorf("parameter must be a symbol, got %s", TypeName(paramList[i]))
 			}
 		}
 
 		// Bind rest parameter as a list
 		restParamName, ok := paramList[restParamIndex+1].(Symbol)
 		if !ok {
-			return NewTypeError("rest parameter must be a symbol, got %T", paramList[restParamIndex+1])
+			return NewTypeError("rest parameter must be a symbol, got %s", TypeName(paramList[restParamIndex+1]))
 		}
 
 		// Collect remaining arguments into a list
@@ -154,14 +187,14 @@ func bindParams(params *List, args []Value, env *Environment) error {
 	} else {
 		// Non-variadic function - exact parameter count required
 		if len(paramList) != len(args) {
-			return NewArityError("function expects %d arguments, got %d", len(paramList), len(args))
+			return NewArityError("%s expects %d arguments, got %d (arglists: %s)", name, len(paramList), len(args), NewVector(paramList...))
 		}
 
 		for i, param := range paramList {
 			if sym, ok := param.(Symbol); ok {
 				env.Set(sym, args[i])
 			} else {
-				return NewTypeError("parameter must be a symbol, got %T", param)
+				return NewTypeError("parameter must be a symbol, got %s", TypeName(param))
 			}
 		}
 	}
@@ -169,6 +202,15 @@ func bindParams(params *List, args []Value, env *Environment) error {
 	return nil
 }
 
+// callFunction invokes a callable Lisp value from Go code
+func callFunction(fn Value, args []Value, env *Environment) (Value, error) {
+	callable, ok := fn.(Callable)
+	if !ok {
+		return nil, NewTypeError("cannot call non-function: %s", TypeName(fn))
+	}
+	return callable.Call(args, env)
+}
+
 // listToSlice converts a List to a slice of Values
 func listToSlice(list *List) []Value {
 	var result []Value
@@ -198,10 +240,13 @@ func evalWithContext(expr Value, env *Environment, ctx *EvaluationContext) (Valu
 	switch v := expr.(type) {
 	case Symbol:
 		// Look up symbol in environment
-		result, err := env.Get(v)
+		result, err := lookupSymbol(v, env)
 		if err != nil {
 			return nil, ctx.EnhanceError(err)
 		}
+		if err := checkDeprecated(v, result, Position{}, env); err != nil {
+			return nil, ctx.EnhanceError(err)
+		}
 		return result, nil
 
 	case *List:
@@ -209,8 +254,15 @@ func evalWithContext(expr Value, env *Environment, ctx *EvaluationContext) (Valu
 			return v, nil // Empty list evaluates to itself
 		}
 
+		if coverage := env.coverage(); coverage != nil {
+			coverage.hit(v)
+		}
+
 		// Check if first element is a special form
 		if sym, ok := v.First().(Symbol); ok {
+			if err := checkShadowing(v, env); err != nil {
+				return nil, ctx.EnhanceError(err)
+			}
 			ctx.PushFrame(string(sym), Position{})
 			result, err := evalSpecialFormWithContext(sym, v.Rest(), env, ctx)
 			ctx.PopFrame()
@@ -230,6 +282,10 @@ func evalWithContext(expr Value, env *Environment, ctx *EvaluationContext) (Valu
 		// These evaluate to themselves
 		return expr, nil
 
+	case *TaggedLiteral:
+		// Read before its tag had a reader
+		return v.resolve()
+
 	default:
 		return expr, nil
 	}
@@ -237,9 +293,18 @@ func evalWithContext(expr Value, env *Environment, ctx *EvaluationContext) (Valu
 
 // evalFunctionCallWithContext evaluates a function call with context tracking
 func evalFunctionCallWithContext(list *List, env *Environment, ctx *EvaluationContext) (Value, error) {
-	// Evaluate the function
-	fn, err := evalWithContext(list.First(), env, ctx)
-	if err != nil {
+	// Evaluate the function; a named one is resolved here so that a
+	// deprecation warning can point at the call
+	var fn Value
+	var err error
+	if sym, ok := list.First().(Symbol); ok {
+		if fn, err = lookupSymbol(sym, env); err != nil {
+			return nil, ctx.EnhanceError(err)
+		}
+		if err := checkDeprecated(sym, fn, list.GetPosition(), env); err != nil {
+			return nil, ctx.EnhanceError(err)
+		}
+	} else if fn, err = evalWithContext(list.First(), env, ctx); err != nil {
 		return nil, err
 	}
 
@@ -252,7 +317,7 @@ func evalFunctionCallWithContext(list *List, env *Environment, ctx *EvaluationCo
 	// Check if it's a macro - macros are expanded without evaluating arguments
 	if macro, ok := fn.(*Macro); ok {
 		ctx.PushFrame(fmt.Sprintf("macro %s", fnName), Position{})
-		result, err := expandMacroWithContext(macro, list.Rest(), env, ctx)
+		result, err := expandMacroWithContext(macro, list, env, ctx)
 		ctx.PopFrame()
 		if err != nil {
 			return nil, ctx.EnhanceError(err)
@@ -261,9 +326,9 @@ func evalFunctionCallWithContext(list *List, env *Environment, ctx *EvaluationCo
 	}
 
 	// Check if it's callable
-	callable, ok := fn.(Function)
+	callable, ok := fn.(Callable)
 	if !ok {
-		return nil, ctx.EnhanceError(NewTypeError("cannot call non-function: %T", fn))
+		return nil, ctx.EnhanceError(NewTypeError("cannot call non-function: %s", TypeName(fn)))
 	}
 
 	// Evaluate arguments
@@ -285,6 +350,10 @@ func evalFunctionCallWithContext(list *List, env *Environment, ctx *EvaluationCo
 	ctx.PopFrame()
 	
 	if err != nil {
+		// Point arity errors at the call that passed the wrong arguments
+		if lispErr, ok := err.(*LispError); ok && lispErr.Type == ArityError && lispErr.Position == (Position{}) {
+			lispErr.Position = list.GetPosition()
+		}
 		return nil, ctx.EnhanceError(err)
 	}
 	
@@ -301,11 +370,11 @@ func evalSpecialFormWithContext(sym Symbol, args *List, env *Environment, ctx *E
 }
 
 // expandMacroWithContext expands a macro with context tracking  
-func expandMacroWithContext(macro *Macro, args *List, env *Environment, ctx *EvaluationContext) (Value, error) {
+func expandMacroWithContext(macro *Macro, form *List, env *Environment, ctx *EvaluationContext) (Value, error) {
 	// For now, just use the regular expandMacro
 	// TODO: Enhance macro expansion to use context for better error reporting
 	_ = ctx // Suppress unused parameter warning
-	return expandMacro(macro, args, env)
+	return expandMacro(macro, form, env)
 }
 
 // isTruthy determines if a value is truthy
@@ -325,39 +394,58 @@ func isTruthy(v Value) bool {
 	}
 }
 
-// valuesEqual compares two values for equality
+// valuesEqual compares two values for equality. Atoms, refs, functions and
+// other references are equal only to themselves, so comparing structures
+// that contain themselves through a reference terminates.
 func valuesEqual(a, b Value) bool {
-	switch va := a.(type) {
-	case Symbol:
-		if vb, ok := b.(Symbol); ok {
-			return va == vb
+	if h, ok := a.(Hasher); ok {
+		return h.Equals(b)
+	}
+	return sameValue(a, b)
+}
+
+// sequencesEqual compares lists, vectors and lazy sequences element by element
+func sequencesEqual(a, b Value) bool {
+	_, lazyA := a.(*LazySeq)
+	_, lazyB := b.(*LazySeq)
+	if !lazyA && !lazyB {
+		itemsA, _ := collectionToSlice(a)
+		itemsB, _ := collectionToSlice(b)
+		if len(itemsA) != len(itemsB) {
+			return false
 		}
-	case String:
-		if vb, ok := b.(String); ok {
-			return va == vb
+		for i := range itemsA {
+			if !valuesEqual(itemsA[i], itemsB[i]) {
+				return false
+			}
 		}
-	case Number:
-		if vb, ok := b.(Number); ok {
-			return va.ToFloat() == vb.ToFloat()
+		return true
+	}
+	for {
+		firstA, okA, errA := seqFirst(a)
+		firstB, okB, errB := seqFirst(b)
+		if errA != nil || errB != nil || okA != okB {
+			return false
 		}
-	case Keyword:
-		if vb, ok := b.(Keyword); ok {
-			return va == vb
+		if !okA {
+			return true
+		}
+		if !valuesEqual(firstA, firstB) {
+			return false
+		}
+		var err error
+		if a, err = seqRest(a); err != nil {
+			return false
+		}
+		if b, err = seqRest(b); err != nil {
+			return false
 		}
-	case Nil:
-		_, ok := b.(Nil)
-		return ok
 	}
-	return false
 }
 
-// expandMacro expands a macro call
-func expandMacro(macro *Macro, args *List, env *Environment) (Value, error) {
-	// Create new environment for macro expansion
-	macroEnv := NewEnvironment(macro.Env)
-
-	// Bind macro parameters to arguments (unevaluated)
-	err := bindParams(macro.Params, listToSlice(args), macroEnv)
+// expandMacro expands the macro call form
+func expandMacro(macro *Macro, form *List, env *Environment) (Value, error) {
+	macroEnv, err := macroEnvironment(macro, form, env)
 	if err != nil {
 		return nil, err
 	}
@@ -372,17 +460,105 @@ func expandMacro(macro *Macro, args *List, env *Environment) (Value, error) {
 	return Eval(expansion, env)
 }
 
+// macroEnvironment creates the environment a macro body is evaluated in: the
+// parameters bound to the unevaluated arguments, &form to the whole call
+// form and &env to a map of the local bindings at the call site
+func macroEnvironment(macro *Macro, form *List, env *Environment) (*Environment, error) {
+	macroEnv := NewEnvironment(macro.Env)
+	// The expansion runs as part of the call at the expansion site, under
+	// its bindings and gensym counter
+	macroEnv.calls = env.calls
+	macroEnv.Set(Intern("&form"), form)
+	macroEnv.Set(Intern("&env"), localBindings(env))
+
+	// Bind macro parameters to arguments (unevaluated)
+	err := bindParams("macro "+string(macro.Name), macro.Params, listToSlice(form.Rest()), macroEnv)
+	if err != nil {
+		return nil, err
+	}
+	return macroEnv, nil
+}
+
+// localBindings maps each symbol bound in env below the root environment,
+// by let, fn parameters and the like, to its value. Inner bindings shadow
+// outer ones.
+func localBindings(env *Environment) *HashMap {
+	locals := NewHashMap()
+	for scope := env; scope != nil && scope.parent != nil; scope = scope.parent {
+		for name, value := range scope.snapshot() {
+			if !locals.ContainsKey(name) {
+				locals.Set(name, value)
+			}
+		}
+	}
+	return locals
+}
+
 // NewCoreEnvironment creates an environment with core primitives
 // This function coordinates the setup from all specialized modules
 func NewCoreEnvironment() *Environment {
 	env := NewEnvironment(nil)
 
-	// Set up different categories of operations
-	setupArithmeticOperations(env) // +, -, *, /, %, =, <, >, >=, <=
-	setupCollectionOperations(env) // count, empty?, nth, conj, cons, first, rest, list, list?, vector?
-	setupStringOperations(env)     // str, substring, string-split, string-replace, string-contains?, string-trim, string?
-	setupIOOperations(env)         // println, prn, slurp, spit, file-exists?, list-dir
-	setupMetaProgramming(env)      // eval, read-string, symbol?, number?, keyword?, nil?, fn?
+	// Set up different categories of operations, recording the category
+	// of each binding for registered-functions and function-help
+	for _, category := range []struct {
+		name  string
+		setup func(*Environment)
+	}{
+		{"arithmetic", setupArithmeticOperations},  // +, -, *, /, %, =, <, >, >=, <=
+		{"collections", setupCollectionOperations}, // count, empty?, nth, conj, cons, first, rest, list, list?, vector?
+		{"strings", setupStringOperations},         // str, string-join, string-builder, substring, string-split, string-replace, string-contains?, string-trim, string?
+		{"io", setupIOOperations},                  // println, prn, slurp, line-seq, spit, file-exists?, list-dir
+		{"meta", setupMetaProgramming},             // eval, read-string, type, instance?, symbol?, number?, keyword?, nil?, fn?
+		{"logging", setupLoggingOperations},        // log/debug, log/info, log/warn, log/error, log/set-sinks!
+		{"cli", setupCLIOperations},                // parse-opts, *command-line-args*
+		{"inspector", setupInspectorOperations},    // inspect
+		{"heap", setupHeapOperations},              // env-tree, value-size
+		{"modules", setupModuleOperations},         // require, reload, *load-path*
+		{"images", setupImageOperations},           // save-image, load-image
+		{"process", setupProcessOperations},        // exit, on-exit, runtime-stats, gc!
+		{"bench", setupBenchOperations},            // bench-fn, bench-report
+		{"printing", setupPrettyPrinter},           // pprint
+		{"printing", setupPrinterOperations},       // *print-precision*, format-radix
+		{"testing", setupTestingOperations},        // register-test, report-assertion, is-golden, check-property, run-tests
+		{"generators", setupGeneratorOperations},   // gen/int, gen/vector, gen/map, gen/sample, ...
+		{"warnings", setupWarningOperations},       // warn
+		{"delay", setupDelayOperations},            // force, deref, realized?, memoize
+		{"weak", setupWeakOperations},              // weak-ref, deref-weak, add-finalizer!
+		{"caches", setupCacheOperations},           // lru-cache, ttl-cache, cache-get, cache-put!, cache-evict!, cache-stats, ...
+		{"vars", setupVarOperations},               // alter-var-root
+		{"atoms", setupAtomOperations},             // atom, swap!, reset!
+		{"watches", setupWatchOperations},          // add-watch, remove-watch
+		{"stm", setupSTMOperations},                // ref, alter, ref-set
+		{"interfaces", setupInterfaceOperations},   // make-interface, implement, implements?, satisfies?, invoke
+		{"structs", setupStructOperations},         // decode-as, from-struct
+		{"tagged", setupTaggedLiteralOperations},   // set-tag-reader!, inst?, uuid?, random-uuid
+		{"reader", setupReaderOperations},          // set-reader-alias!, remove-reader-alias!, reader-aliases
+		{"mq", setupMQOperations},                  // mq-connect, mq-publish, mq-subscribe, mq-dispatch
+		{"http", setupHTTPOperations},              // http-serve, http-stop, router, wrap-logging, wrap-json, wrap-static
+		{"sessions", setupSessionOperations},       // wrap-form, wrap-cookies, wrap-session, form-decode, sign-cookie, unsign-cookie
+		{"scheduler", setupSchedulerOperations},    // schedule, every-ms, cancel-job, wait-jobs, parse-cron, next-run, runs-between
+		{"coroutines", setupCoroutineOperations},   // yield, yield-from
+		{"pipelines", setupPipelineOperations},     // run-pipeline
+		{"functional", setupFunctionalOperations},  // apply, identity, constantly, fnil, comp, partial, complement, reduced
+		{"sorting", setupSortOperations},           // sort-by, sort-natural
+		{"diff", setupDiffOperations},              // diff, text-diff
+		{"zippers", setupZipperOperations},         // zip/vector-zip, zip/zipper, zip/down, zip/right, zip/edit, zip/root, ...
+		{"walking", setupWalkOperations},           // walk, prewalk, postwalk, keywordize-keys, stringify-keys, deep-merge
+		{"graphs", setupGraphOperations},           // graph/topo-sort, graph/shortest-path, graph/components, graph/find-cycle, ...
+		{"statistics", setupStatsOperations},       // mean, median, mode, variance, stddev, percentile, histogram, linear-regression
+		{"matrices", setupMatrixOperations},        // matrix, matmul, transpose, inverse, determinant, m+, m-, m*, m/
+		{"decimals", setupDecimalOperations},       // decimal, decimal?, round-decimal
+		{"durations", setupDurationOperations},     // hours, minutes, parse-duration, format-duration, iso-duration, now, ...
+		{"plugins", setupPluginOperations},         // plugins, available-plugins, load-plugin
+		{"registry", setupRegistryOperations},      // registered-functions, function-help, function-category, plugin-info
+		{"strict", setupStrictOperations},          // set-strict!, strict?, declare-var
+	} {
+		recordDefinitions(env, category.name, "", func() error {
+			category.setup(env)
+			return nil
+		})
+	}
 
 	return env
 }
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+)
+
+// coroutine runs a generator body on its own goroutine, handing over one
+// yielded value each time the consumer asks for the next element
+type coroutine struct {
+	next chan struct{} // Consumer asks for the next value
+	out  chan yielded  // Producer answers with a value or the end
+	stop chan struct{} // Closed when the consumer abandons the sequence
+
+	// running is set while the consumer waits for the body, the only time
+	// yield may be called: code captured by the body and run elsewhere,
+	// once the body has handed over a value, must not yield for it
+	running atomic.Bool
+}
+
+type yielded struct {
+	value Value
+	done  bool
+	err   error
+}
+
+// coroutineHandle is referenced only by the sequence cells, so once they are
+// all garbage its finalizer can stop the blocked producer
+type coroutineHandle struct {
+	co *coroutine
+}
+
+// errGeneratorStopped unwinds a producer whose sequence was abandoned
+var errGeneratorStopped = fmt.Errorf("generator stopped")
+
+// evalGenerator implements (generator body...), a lazy sequence of the values
+// the body passes to yield
+func evalGenerator(args *List, env *Environment) (Value, error) {
+	body := listToSlice(args)
+	co := &coroutine{next: make(chan struct{}), out: make(chan yielded), stop: make(chan struct{})}
+	handle := &coroutineHandle{co: co}
+	runtime.SetFinalizer(handle, func(h *coroutineHandle) { close(h.co.stop) })
+
+	started := false
+	var step func() (Value, Value, bool, error)
+	step = func() (Value, Value, bool, error) {
+		if !started {
+			started = true
+			go co.run(body, env)
+		}
+		co.next <- struct{}{}
+		item := <-co.out
+		if item.done {
+			runtime.KeepAlive(handle)
+			return nil, nil, false, item.err
+		}
+		return item.value, NewLazySeq(step), true, nil
+	}
+	return NewLazySeq(step), nil
+}
+
+// run evaluates the body on the producer goroutine, in frames that carry
+// the coroutine for yield
+func (co *coroutine) run(body []Value, env *Environment) {
+	frame := nestedFrame(env, "generator")
+	frame.coroutine = co
+	env = NewEnvironment(env)
+	env.calls = frame
+
+	// Wait for the first request before running any of the body
+	select {
+	case <-co.next:
+		co.running.Store(true)
+	case <-co.stop:
+		return
+	}
+
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = NewRuntimeError("generator panicked: %v", r)
+			}
+		}()
+		for _, expr := range body {
+			if _, err := Eval(expr, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	co.running.Store(false)
+	select {
+	case <-co.stop:
+	default:
+		co.out <- yielded{done: true, err: err}
+	}
+}
+
+// yield hands value to the consumer and blocks until the next one is wanted
+func (co *coroutine) yield(value Value) error {
+	co.running.Store(false)
+	co.out <- yielded{value: value}
+	select {
+	case <-co.next:
+		co.running.Store(true)
+		return nil
+	case <-co.stop:
+		return errGeneratorStopped
+	}
+}
+
+// currentCoroutine returns the generator whose body env runs in
+func currentCoroutine(fnName string, env *Environment) (*coroutine, error) {
+	if env != nil && env.calls != nil {
+		if co := env.calls.coroutine; co != nil && co.running.Load() {
+			return co, nil
+		}
+	}
+	return nil, NewRuntimeError("%s called outside of a generator", fnName)
+}
+
+// setupCoroutineOperations adds yield and yield-from
+func setupCoroutineOperations(env *Environment) {
+	env.Set(Intern("yield"), &BuiltinFunction{
+		Name: "yield",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("yield expects 1 argument, got %d", len(args))
+			}
+			co, err := currentCoroutine("yield", env)
+			if err != nil {
+				return nil, err
+			}
+			return Nil{}, co.yield(args[0])
+		},
+	})
+
+	env.Set(Intern("yield-from"), &BuiltinFunction{
+		Name: "yield-from",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("yield-from expects 1 argument, got %d", len(args))
+			}
+			co, err := currentCoroutine("yield-from", env)
+			if err != nil {
+				return nil, err
+			}
+			coll := args[0]
+			for {
+				item, ok, err := seqFirst(coll)
+				if err != nil || !ok {
+					return Nil{}, err
+				}
+				if err := co.yield(item); err != nil {
+					return nil, err
+				}
+				if coll, err = seqRest(coll); err != nil {
+					return nil, err
+				}
+			}
+		},
+	})
+}
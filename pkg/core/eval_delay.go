@@ -0,0 +1,303 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Delay is an expression evaluated at most once, the first time it is forced
+type Delay struct {
+	mu         sync.Mutex
+	expr       Value
+	env        *Environment
+	evaluating bool          // Being forced; others wait on done
+	done       chan struct{} // Closed once realized
+	realized   bool
+	value      Value
+	err        error
+}
+
+func (d *Delay) String() string {
+	return printString(d)
+}
+
+// Force evaluates the delayed expression the first time and returns the
+// cached value, or error, after that
+func (d *Delay) Force() (Value, error) {
+	return d.force(nil)
+}
+
+// force is Force called from code running in env. A delay forced again by
+// its own expression is an error; forced from elsewhere while it is being
+// evaluated, it waits for the value. The lock isn't held during evaluation,
+// so printing the delay or asking if it's realized doesn't wait.
+func (d *Delay) force(env *Environment) (Value, error) {
+	d.mu.Lock()
+	if d.realized {
+		defer d.mu.Unlock()
+		return d.value, d.err
+	}
+	if d.evaluating {
+		done := d.done
+		d.mu.Unlock()
+		if env != nil && forcing(env.calls, d) {
+			return nil, NewRuntimeError("delay forced again while evaluating its own expression")
+		}
+		<-done
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return d.value, d.err
+	}
+	d.evaluating, d.done = true, make(chan struct{})
+	expr, scope := d.expr, NewEnvironment(d.env)
+	d.mu.Unlock()
+
+	// Calls made by the expression find this frame among their callers
+	frame := nestedFrame(scope, "delay")
+	frame.forcing = d
+	scope.calls = frame
+	value, err := Eval(expr, scope)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.value, d.err = value, err
+	d.realized, d.evaluating = true, false
+	d.expr, d.env = nil, nil
+	close(d.done)
+	return value, err
+}
+
+// forcing reports whether d is being forced by frame or one of its callers
+func forcing(frame *callFrame, d *Delay) bool {
+	for ; frame != nil; frame = frame.caller {
+		if frame.forcing == d {
+			return true
+		}
+	}
+	return false
+}
+
+// evalDelay implements (delay expr)
+func evalDelay(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) != 1 {
+		return nil, NewArityError("delay expects 1 argument, got %d", len(argSlice))
+	}
+	return &Delay{expr: argSlice[0], env: env}, nil
+}
+
+// setupDelayOperations adds force, deref, realized? and memoize to the environment
+func setupDelayOperations(env *Environment) {
+	env.Set(Intern("force"), &BuiltinFunction{
+		Name: "force",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("force expects 1 argument, got %d", len(args))
+			}
+			// Like Clojure, forcing anything other than a delay returns it
+			if d, ok := args[0].(*Delay); ok {
+				return d.force(env)
+			}
+			return args[0], nil
+		},
+	})
+
+	// @x reads as (deref x)
+	env.Set(Intern("deref"), &BuiltinFunction{
+		Name: "deref",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("deref expects 1 argument, got %d", len(args))
+			}
+			switch ref := args[0].(type) {
+			case *Delay:
+				return ref.force(env)
+			case *Var:
+				return ref.Deref()
+			case *Atom:
+				return ref.Deref(), nil
+			case *Ref:
+				return derefRef(ref, env)
+			case *Reduced:
+				return ref.Deref(), nil
+			}
+			return nil, NewTypeError("deref expects a delay, var, atom or ref, got %s", TypeName(args[0]))
+		},
+	})
+
+	env.Set(Intern("realized?"), &BuiltinFunction{
+		Name: "realized?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("realized? expects 1 argument, got %d", len(args))
+			}
+			d, ok := args[0].(*Delay)
+			if !ok {
+				return nil, NewTypeError("realized? expects a delay, got %s", TypeName(args[0]))
+			}
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.realized {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("memoize"), &BuiltinFunction{
+		Name: "memoize",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("memoize expects at least 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(Callable); !ok {
+				return nil, NewTypeError("memoize expects a function, got %s", TypeName(args[0]))
+			}
+			cache, err := parseMemoOptions(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			fn := args[0]
+			return &BuiltinFunction{
+				Name: "memoized",
+				Fn: func(args []Value, env *Environment) (Value, error) {
+					// Called without the lock so that f may recurse through itself
+					return cache.fetch(memoKey(args), func() (Value, error) {
+						return callFunction(fn, args, env)
+					})
+				},
+			}, nil
+		},
+	})
+}
+
+// memoCache is a thread-safe result cache, optionally bounded in size
+// (evicting the least recently used entry) and in entry age
+type memoCache struct {
+	mu      sync.Mutex
+	maxSize int           // 0 for unbounded
+	ttl     time.Duration // 0 for no expiry
+	entries valueKeyed[*list.Element]
+	order   *list.List // Most recently used at the front
+	now     func() time.Time
+}
+
+type memoEntry struct {
+	key     Value
+	value   Value
+	created time.Time
+}
+
+// valueKeyed maps keys compared like a hash-map's, by Hash and Equals, to
+// values of type T, so that keys don't depend on how they print
+type valueKeyed[T any] map[uint64][]keyedEntry[T]
+
+type keyedEntry[T any] struct {
+	key   Value
+	value T
+}
+
+func (m valueKeyed[T]) get(key Value) (T, bool) {
+	for _, entry := range m[Hash(key)] {
+		if valuesEqual(entry.key, key) {
+			return entry.value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+func (m valueKeyed[T]) set(key Value, value T) {
+	hash := Hash(key)
+	for i, entry := range m[hash] {
+		if valuesEqual(entry.key, key) {
+			m[hash][i].value = value
+			return
+		}
+	}
+	m[hash] = append(m[hash], keyedEntry[T]{key, value})
+}
+
+func (m valueKeyed[T]) delete(key Value) {
+	hash := Hash(key)
+	for i, entry := range m[hash] {
+		if valuesEqual(entry.key, key) {
+			if m[hash] = append(m[hash][:i:i], m[hash][i+1:]...); len(m[hash]) == 0 {
+				delete(m, hash)
+			}
+			return
+		}
+	}
+}
+
+// parseMemoOptions reads :max-size n and :ttl milliseconds, or :cache with
+// a cache from lru-cache or ttl-cache
+func parseMemoOptions(args []Value) (*Cache, error) {
+	cache := newCache("memo", 0, 0)
+	if len(args)%2 != 0 {
+		return nil, NewArityError("memoize expects keyword/value option pairs")
+	}
+	for i := 0; i < len(args); i += 2 {
+		key, ok := args[i].(Keyword)
+		if !ok {
+			return nil, NewTypeError("memoize option must be a keyword, got %s", TypeName(args[i]))
+		}
+		if key == "cache" {
+			if len(args) != 2 {
+				return nil, NewRuntimeError("memoize option :cache cannot be combined with other options")
+			}
+			return cacheArg("memoize", args[i+1])
+		}
+		num, ok := args[i+1].(Number)
+		if !ok || num.ToInt() < 1 {
+			return nil, NewTypeError("memoize option :%s expects a positive integer, got %s", key, args[i+1])
+		}
+		switch key {
+		case "max-size":
+			cache.maxSize = int(num.ToInt())
+		case "ttl":
+			cache.ttl = time.Duration(num.ToInt()) * time.Millisecond
+		default:
+			return nil, NewRuntimeError("unknown memoize option: :%s", key)
+		}
+	}
+	return cache, nil
+}
+
+func (c *memoCache) get(key Value) (Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries.get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*memoEntry)
+	if c.ttl > 0 && c.now().Sub(entry.created) >= c.ttl {
+		c.order.Remove(elem)
+		c.entries.delete(key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+func (c *memoCache) put(key Value, value Value) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries.get(key); ok {
+		c.order.Remove(elem)
+	}
+	c.entries.set(key, c.order.PushFront(&memoEntry{key: key, value: value, created: c.now()}))
+	if c.maxSize > 0 && c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		c.entries.delete(oldest.Value.(*memoEntry).key)
+	}
+}
+
+// memoKey identifies an argument list by the values in it, so that calls
+// with equal arguments share a result
+func memoKey(args []Value) Value {
+	return NewVector(append([]Value(nil), args...)...)
+}
@@ -0,0 +1,204 @@
+package core
+
+import "time"
+
+// Reduced wraps the result of a reduce step to stop the reduction there
+type Reduced struct {
+	value Value
+}
+
+func (r *Reduced) String() string {
+	return "#<reduced " + printString(r.value) + ">"
+}
+
+// Deref returns the wrapped value
+func (r *Reduced) Deref() Value {
+	return r.value
+}
+
+// setupFunctionalOperations adds apply, identity, constantly, fnil, comp,
+// partial, complement, call-with-timeout, reduced, reduced? and unreduced
+func setupFunctionalOperations(env *Environment) {
+	env.Set(Intern("apply"), &BuiltinFunction{
+		Name: "apply",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("apply expects at least 2 arguments, got %d", len(args))
+			}
+			spread, err := collectionToSlice(args[len(args)-1])
+			if err != nil {
+				return nil, err
+			}
+			callArgs := append(append([]Value{}, args[1:len(args)-1]...), spread...)
+			return callFunction(args[0], callArgs, env)
+		},
+	})
+
+	env.Set(Intern("identity"), &BuiltinFunction{
+		Name: "identity",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("identity expects 1 argument, got %d", len(args))
+			}
+			return args[0], nil
+		},
+	})
+
+	env.Set(Intern("constantly"), &BuiltinFunction{
+		Name: "constantly",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("constantly expects 1 argument, got %d", len(args))
+			}
+			value := args[0]
+			return &BuiltinFunction{
+				Name: "constantly",
+				Fn: func(_ []Value, _ *Environment) (Value, error) {
+					return value, nil
+				},
+			}, nil
+		},
+	})
+
+	env.Set(Intern("fnil"), &BuiltinFunction{
+		Name: "fnil",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 || len(args) > 4 {
+				return nil, NewArityError("fnil expects 2 to 4 arguments, got %d", len(args))
+			}
+			f, defaults := args[0], args[1:]
+			return &BuiltinFunction{
+				Name: "fnil",
+				Fn: func(callArgs []Value, env *Environment) (Value, error) {
+					patched := append([]Value{}, callArgs...)
+					for i, d := range defaults {
+						if i >= len(patched) {
+							break
+						}
+						if _, isNil := patched[i].(Nil); isNil || patched[i] == nil {
+							patched[i] = d
+						}
+					}
+					return callFunction(f, patched, env)
+				},
+			}, nil
+		},
+	})
+
+	env.Set(Intern("comp"), &BuiltinFunction{
+		Name: "comp",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			fns := append([]Value{}, args...)
+			return &BuiltinFunction{
+				Name: "comp",
+				Fn: func(callArgs []Value, env *Environment) (Value, error) {
+					if len(fns) == 0 {
+						if len(callArgs) != 1 {
+							return nil, NewArityError("(comp) expects 1 argument, got %d", len(callArgs))
+						}
+						return callArgs[0], nil
+					}
+					// The rightmost function takes all the arguments, each
+					// one to its left takes the previous result
+					result, err := callFunction(fns[len(fns)-1], callArgs, env)
+					for i := len(fns) - 2; i >= 0 && err == nil; i-- {
+						result, err = callFunction(fns[i], []Value{result}, env)
+					}
+					return result, err
+				},
+			}, nil
+		},
+	})
+
+	env.Set(Intern("partial"), &BuiltinFunction{
+		Name: "partial",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("partial expects at least 1 argument, got %d", len(args))
+			}
+			f, bound := args[0], append([]Value{}, args[1:]...)
+			return &BuiltinFunction{
+				Name: "partial",
+				Fn: func(callArgs []Value, env *Environment) (Value, error) {
+					return callFunction(f, append(append([]Value{}, bound...), callArgs...), env)
+				},
+			}, nil
+		},
+	})
+
+	env.Set(Intern("complement"), &BuiltinFunction{
+		Name: "complement",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("complement expects 1 argument, got %d", len(args))
+			}
+			f := args[0]
+			return &BuiltinFunction{
+				Name: "complement",
+				Fn: func(callArgs []Value, env *Environment) (Value, error) {
+					result, err := callFunction(f, callArgs, env)
+					if err != nil {
+						return nil, err
+					}
+					if isTruthy(result) {
+						return Nil{}, nil
+					}
+					return Symbol("true"), nil
+				},
+			}, nil
+		},
+	})
+
+	// The with-timeout macro expands to call-with-timeout
+	env.Set(Intern("call-with-timeout"), &BuiltinFunction{
+		Name: "call-with-timeout",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("call-with-timeout expects 2 arguments, got %d", len(args))
+			}
+			ms, ok := args[0].(Number)
+			if !ok || ms.ToFloat() < 0 {
+				return nil, NewTypeError("call-with-timeout expects a timeout in milliseconds, got %s", args[0])
+			}
+			timeout := time.Duration(ms.ToFloat() * float64(time.Millisecond))
+			return callFunction(args[1], nil, withDeadline(env, timeout))
+		},
+	})
+
+	env.Set(Intern("reduced"), &BuiltinFunction{
+		Name: "reduced",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("reduced expects 1 argument, got %d", len(args))
+			}
+			return &Reduced{value: args[0]}, nil
+		},
+	})
+
+	env.Set(Intern("reduced?"), &BuiltinFunction{
+		Name: "reduced?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("reduced? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(*Reduced); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	// unreduced returns anything other than a reduced value as it is
+	env.Set(Intern("unreduced"), &BuiltinFunction{
+		Name: "unreduced",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("unreduced expects 1 argument, got %d", len(args))
+			}
+			if r, ok := args[0].(*Reduced); ok {
+				return r.value, nil
+			}
+			return args[0], nil
+		},
+	})
+}
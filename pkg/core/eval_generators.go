@@ -0,0 +1,343 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Generator produces random values for property-based tests, along with
+// smaller candidates for shrinking a failing value
+type Generator struct {
+	Name     string
+	Generate func(r *rand.Rand, size int) Value
+	Shrink   func(value Value) []Value // Simpler candidates, simplest first
+}
+
+func (g *Generator) String() string {
+	return fmt.Sprintf("#<generator:%s>", g.Name)
+}
+
+// setupGeneratorOperations adds the gen/* generator constructors
+func setupGeneratorOperations(env *Environment) {
+	simple := map[string]*Generator{
+		"gen/int":     intGenerator(false),
+		"gen/nat":     intGenerator(true),
+		"gen/boolean": booleanGenerator(),
+		"gen/string":  stringGenerator(),
+		"gen/keyword": keywordGenerator(),
+	}
+	for name, gen := range simple {
+		gen := gen
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 0 {
+					return nil, NewArityError("%s expects 0 arguments, got %d", gen.Name, len(args))
+				}
+				return gen, nil
+			},
+		})
+	}
+
+	env.Set(Intern("gen/elements"), &BuiltinFunction{
+		Name: "gen/elements",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("gen/elements expects 1 argument, got %d", len(args))
+			}
+			items, err := collectionToSlice(args[0])
+			if err != nil || len(items) == 0 {
+				return nil, NewTypeError("gen/elements expects a non-empty collection, got %s", args[0])
+			}
+			return elementsGenerator(items), nil
+		},
+	})
+
+	env.Set(Intern("gen/vector"), &BuiltinFunction{
+		Name: "gen/vector",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("gen/vector expects 1 argument, got %d", len(args))
+			}
+			elem, err := generatorArg("gen/vector", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return sequenceGenerator("vector", elem, func(items []Value) Value { return NewVector(items...) }), nil
+		},
+	})
+
+	env.Set(Intern("gen/list"), &BuiltinFunction{
+		Name: "gen/list",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("gen/list expects 1 argument, got %d", len(args))
+			}
+			elem, err := generatorArg("gen/list", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return sequenceGenerator("list", elem, func(items []Value) Value { return NewList(items...) }), nil
+		},
+	})
+
+	env.Set(Intern("gen/map"), &BuiltinFunction{
+		Name: "gen/map",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("gen/map expects 2 arguments, got %d", len(args))
+			}
+			keyGen, err := generatorArg("gen/map", args[0])
+			if err != nil {
+				return nil, err
+			}
+			valGen, err := generatorArg("gen/map", args[1])
+			if err != nil {
+				return nil, err
+			}
+			return mapGenerator(keyGen, valGen), nil
+		},
+	})
+
+	env.Set(Intern("gen/sample"), &BuiltinFunction{
+		Name: "gen/sample",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, NewArityError("gen/sample expects 1 or 2 arguments, got %d", len(args))
+			}
+			gen, err := generatorArg("gen/sample", args[0])
+			if err != nil {
+				return nil, err
+			}
+			n := 10
+			if len(args) == 2 {
+				num, ok := args[1].(Number)
+				if !ok {
+					return nil, NewTypeError("gen/sample expects a number of samples, got %s", TypeName(args[1]))
+				}
+				n = int(num.ToInt())
+			}
+
+			r := rand.New(rand.NewSource(env.randomSeed()))
+			samples := make([]Value, n)
+			for i := range samples {
+				samples[i] = gen.Generate(r, i)
+			}
+			return NewList(samples...), nil
+		},
+	})
+}
+
+func generatorArg(fnName string, arg Value) (*Generator, error) {
+	gen, ok := arg.(*Generator)
+	if !ok {
+		return nil, NewTypeError("%s expects a generator, got %s", fnName, TypeName(arg))
+	}
+	return gen, nil
+}
+
+// intGenerator generates integers in [-size, size] (or [0, size]) and shrinks
+// them towards zero
+func intGenerator(natural bool) *Generator {
+	name := "int"
+	if natural {
+		name = "nat"
+	}
+	return &Generator{
+		Name: name,
+		Generate: func(r *rand.Rand, size int) Value {
+			n := int64(r.Intn(size + 1))
+			if !natural && r.Intn(2) == 0 {
+				n = -n
+			}
+			return NewNumber(n)
+		},
+		Shrink: func(value Value) []Value {
+			n := value.(Number).ToInt()
+			if n == 0 {
+				return nil
+			}
+			candidates := []Value{NewNumber(int64(0))}
+			if half := n / 2; half != 0 {
+				candidates = append(candidates, NewNumber(half))
+			}
+			if n < 0 {
+				candidates = append(candidates, NewNumber(-n), NewNumber(n+1))
+			} else if n > 1 {
+				candidates = append(candidates, NewNumber(n-1))
+			}
+			return candidates
+		},
+	}
+}
+
+func booleanGenerator() *Generator {
+	return &Generator{
+		Name: "boolean",
+		Generate: func(r *rand.Rand, size int) Value {
+			if r.Intn(2) == 0 {
+				return Nil{}
+			}
+			return Symbol("true")
+		},
+		Shrink: func(value Value) []Value {
+			if isTruthy(value) {
+				return []Value{Nil{}}
+			}
+			return nil
+		},
+	}
+}
+
+const generatorAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func randomText(r *rand.Rand, length int) string {
+	text := make([]byte, length)
+	for i := range text {
+		text[i] = generatorAlphabet[r.Intn(len(generatorAlphabet))]
+	}
+	return string(text)
+}
+
+// shrinkText proposes the empty string, then each string with one character removed
+func shrinkText(text string) []string {
+	if text == "" {
+		return nil
+	}
+	candidates := []string{""}
+	for i := range text {
+		candidates = append(candidates, text[:i]+text[i+1:])
+	}
+	return candidates
+}
+
+func stringGenerator() *Generator {
+	return &Generator{
+		Name: "string",
+		Generate: func(r *rand.Rand, size int) Value {
+			return String(randomText(r, r.Intn(size+1)))
+		},
+		Shrink: func(value Value) []Value {
+			var candidates []Value
+			for _, text := range shrinkText(string(value.(String))) {
+				candidates = append(candidates, String(text))
+			}
+			return candidates
+		},
+	}
+}
+
+func keywordGenerator() *Generator {
+	isLetter := func(c byte) bool { return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
+	return &Generator{
+		Name: "keyword",
+		Generate: func(r *rand.Rand, size int) Value {
+			// Keywords start with a letter and are never empty
+			first := generatorAlphabet[r.Intn(52)]
+			return InternKeyword(string(first) + randomText(r, r.Intn(size/4+1)))
+		},
+		Shrink: func(value Value) []Value {
+			var candidates []Value
+			for _, text := range shrinkText(string(value.(Keyword))) {
+				if text != "" && isLetter(text[0]) {
+					candidates = append(candidates, InternKeyword(text))
+				}
+			}
+			return candidates
+		},
+	}
+}
+
+// elementsGenerator picks from a fixed collection, shrinking towards its first item
+func elementsGenerator(items []Value) *Generator {
+	return &Generator{
+		Name: "elements",
+		Generate: func(r *rand.Rand, size int) Value {
+			return items[r.Intn(len(items))]
+		},
+		Shrink: func(value Value) []Value {
+			for i, item := range items {
+				if item.String() == value.String() {
+					return append([]Value(nil), items[:i]...)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// sequenceGenerator generates up to size elements, shrinking by dropping
+// elements and then by shrinking each element
+func sequenceGenerator(name string, elem *Generator, build func([]Value) Value) *Generator {
+	return &Generator{
+		Name: name,
+		Generate: func(r *rand.Rand, size int) Value {
+			items := make([]Value, r.Intn(size+1))
+			for i := range items {
+				items[i] = elem.Generate(r, size)
+			}
+			return build(items)
+		},
+		Shrink: func(value Value) []Value {
+			items, _ := collectionToSlice(value)
+			var candidates []Value
+			if len(items) > 0 {
+				candidates = append(candidates, build(nil))
+			}
+			for i := range items {
+				without := append(append([]Value(nil), items[:i]...), items[i+1:]...)
+				candidates = append(candidates, build(without))
+			}
+			for i, item := range items {
+				for _, smaller := range elem.Shrink(item) {
+					replaced := append([]Value(nil), items...)
+					replaced[i] = smaller
+					candidates = append(candidates, build(replaced))
+				}
+			}
+			return candidates
+		},
+	}
+}
+
+// mapGenerator generates hash-maps of up to size entries, shrinking by
+// dropping entries and then by shrinking values
+func mapGenerator(keyGen, valGen *Generator) *Generator {
+	return &Generator{
+		Name: "map",
+		Generate: func(r *rand.Rand, size int) Value {
+			m := NewHashMap()
+			for i := r.Intn(size + 1); i > 0; i-- {
+				m.Set(keyGen.Generate(r, size), valGen.Generate(r, size))
+			}
+			return m
+		},
+		Shrink: func(value Value) []Value {
+			m := value.(*HashMap)
+			rebuild := func(skip int, replaceAt int, replacement Value) Value {
+				result := NewHashMap()
+				for i, key := range m.keys {
+					switch {
+					case i == skip:
+					case i == replaceAt:
+						result.Set(key, replacement)
+					default:
+						result.Set(key, m.Get(key))
+					}
+				}
+				return result
+			}
+
+			var candidates []Value
+			for i := range m.keys {
+				candidates = append(candidates, rebuild(i, -1, nil))
+			}
+			for i, key := range m.keys {
+				for _, smaller := range valGen.Shrink(m.Get(key)) {
+					candidates = append(candidates, rebuild(-1, i, smaller))
+				}
+			}
+			return candidates
+		},
+	}
+}
@@ -0,0 +1,269 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Interface is a named set of methods, declared in Lisp with definterface,
+// that Lisp code implements with implement. Go embedders turn
+// implementations into values of their own Go interfaces with Adapt.
+type Interface struct {
+	Name    Symbol
+	Methods []InterfaceMethod
+}
+
+// InterfaceMethod is one method of an Interface
+type InterfaceMethod struct {
+	Name   Symbol
+	Params []Symbol
+}
+
+func (i *Interface) String() string {
+	return fmt.Sprintf("#<interface %s>", i.Name)
+}
+
+// method returns the declaration of the named method
+func (i *Interface) method(name Symbol) (InterfaceMethod, bool) {
+	for _, method := range i.Methods {
+		if method.Name == name {
+			return method, true
+		}
+	}
+	return InterfaceMethod{}, false
+}
+
+// Implementation is a Lisp value providing every method of an Interface
+type Implementation struct {
+	Interface *Interface
+	methods   map[Symbol]Value
+	env       *Environment
+}
+
+func (impl *Implementation) String() string {
+	return fmt.Sprintf("#<%s implementation>", impl.Interface.Name)
+}
+
+// TypeName names implementations by their interface, so (type x) is
+// :Greeter for an implementation of Greeter
+func (impl *Implementation) TypeName() string {
+	return string(impl.Interface.Name)
+}
+
+// Invoke calls the named method with args, checking them against the
+// method's declared parameters
+func (impl *Implementation) Invoke(method string, args ...Value) (Value, error) {
+	decl, ok := impl.Interface.method(Symbol(method))
+	if !ok {
+		return nil, NewNameError("%s has no method %s", impl.Interface.Name, method)
+	}
+	if len(args) != len(decl.Params) {
+		return nil, NewArityError("%s.%s expects %d arguments, got %d", impl.Interface.Name, method, len(decl.Params), len(args))
+	}
+	return callFunction(impl.methods[decl.Name], args, impl.env)
+}
+
+// Adapter wraps an implementation in a Go value, usually a struct whose
+// methods call impl.Invoke and convert the results
+type Adapter func(impl *Implementation) any
+
+var adapters = struct {
+	sync.RWMutex
+	byName map[Symbol]Adapter
+}{byName: make(map[Symbol]Adapter)}
+
+// RegisterAdapter makes Adapt use adapter for implementations of the Lisp
+// interface with the given name
+func RegisterAdapter(name string, adapter Adapter) {
+	adapters.Lock()
+	defer adapters.Unlock()
+	adapters.byName[Symbol(name)] = adapter
+}
+
+// Adapt returns the Go value registered for value's interface, for example
+// a strategy object evaluated from Lisp code:
+//
+//	core.RegisterAdapter("Greeter", func(impl *core.Implementation) any {
+//		return greeterAdapter{impl}
+//	})
+//	greeter, err := core.Adapt[Greeter](value)
+func Adapt[T any](value Value) (T, error) {
+	var zero T
+	impl, ok := value.(*Implementation)
+	if !ok {
+		return zero, NewTypeError("expected an interface implementation, got %s", TypeName(value))
+	}
+
+	adapters.RLock()
+	adapter, ok := adapters.byName[impl.Interface.Name]
+	adapters.RUnlock()
+	if !ok {
+		return zero, NewRuntimeError("no Go adapter registered for interface %s", impl.Interface.Name)
+	}
+
+	result := adapter(impl)
+	adapted, ok := result.(T)
+	if !ok {
+		return zero, NewTypeError("adapter for %s returns %T, which is not a %T", impl.Interface.Name, result, &zero)
+	}
+	return adapted, nil
+}
+
+// parseInterfaceMethods reads method declarations like (greet [name])
+func parseInterfaceMethods(name Symbol, specs []Value) ([]InterfaceMethod, error) {
+	var methods []InterfaceMethod
+	seen := make(map[Symbol]bool)
+	for _, spec := range specs {
+		parts, err := collectionToSlice(spec)
+		if err != nil || len(parts) != 2 {
+			return nil, NewTypeError("%s: method declarations look like (name [params]), got %s", name, spec)
+		}
+		methodName, ok := parts[0].(Symbol)
+		if !ok {
+			return nil, NewTypeError("%s: method name must be a symbol, got %s", name, parts[0])
+		}
+		if seen[methodName] {
+			return nil, NewRuntimeError("%s: method %s is declared twice", name, methodName)
+		}
+		seen[methodName] = true
+
+		paramValues, err := collectionToSlice(parts[1])
+		if err != nil {
+			return nil, NewTypeError("%s: parameters of %s must be a vector", name, methodName)
+		}
+		method := InterfaceMethod{Name: methodName}
+		for _, param := range paramValues {
+			sym, ok := param.(Symbol)
+			if !ok {
+				return nil, NewTypeError("%s: parameters of %s must be symbols, got %s", name, methodName, param)
+			}
+			method.Params = append(method.Params, sym)
+		}
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+// setupInterfaceOperations adds make-interface, implement, implements?,
+// satisfies? and invoke. The definterface macro in the standard library expands to
+// make-interface.
+func setupInterfaceOperations(env *Environment) {
+	env.Set(Intern("make-interface"), &BuiltinFunction{
+		Name: "make-interface",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("make-interface expects at least 1 argument, got %d", len(args))
+			}
+			name, ok := args[0].(Symbol)
+			if !ok {
+				return nil, NewTypeError("make-interface expects a symbol name, got %s", TypeName(args[0]))
+			}
+			methods, err := parseInterfaceMethods(name, args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return &Interface{Name: name, Methods: methods}, nil
+		},
+	})
+
+	env.Set(Intern("implement"), &BuiltinFunction{
+		Name: "implement",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 || len(args)%2 != 1 {
+				return nil, NewArityError("implement expects an interface and keyword/function pairs, got %d arguments", len(args))
+			}
+			iface, ok := args[0].(*Interface)
+			if !ok {
+				return nil, NewTypeError("implement expects an interface, got %s", TypeName(args[0]))
+			}
+
+			impl := &Implementation{Interface: iface, methods: make(map[Symbol]Value), env: env}
+			for i := 1; i < len(args); i += 2 {
+				key, ok := args[i].(Keyword)
+				if !ok {
+					return nil, NewTypeError("implement expects keyword method names, got %s", args[i])
+				}
+				if _, declared := iface.method(Symbol(key)); !declared {
+					return nil, NewNameError("%s has no method %s", iface.Name, key)
+				}
+				if _, callable := args[i+1].(Callable); !callable {
+					return nil, NewTypeError("method %s of %s must be a function, got %s", key, iface.Name, TypeName(args[i+1]))
+				}
+				impl.methods[Symbol(key)] = args[i+1]
+			}
+
+			var missing []string
+			for _, method := range iface.Methods {
+				if _, ok := impl.methods[method.Name]; !ok {
+					missing = append(missing, string(method.Name))
+				}
+			}
+			if len(missing) > 0 {
+				sort.Strings(missing)
+				return nil, NewRuntimeError("implementation of %s is missing %s", iface.Name, strings.Join(missing, ", "))
+			}
+			return impl, nil
+		},
+	})
+
+	env.Set(Intern("implements?"), &BuiltinFunction{
+		Name: "implements?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("implements? expects 2 arguments, got %d", len(args))
+			}
+			iface, ok := args[0].(*Interface)
+			if !ok {
+				return nil, NewTypeError("implements? expects an interface, got %s", TypeName(args[0]))
+			}
+			if impl, ok := args[1].(*Implementation); ok && impl.Interface == iface {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("satisfies?"), &BuiltinFunction{
+		Name: "satisfies?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("satisfies? expects 2 arguments, got %d", len(args))
+			}
+			iface, ok := args[0].(*Interface)
+			if name, isName := args[0].(Symbol); isName {
+				value, err := env.Get(name)
+				if err != nil {
+					return nil, err
+				}
+				iface, ok = value.(*Interface)
+			}
+			if !ok {
+				return nil, NewTypeError("satisfies? expects an interface or its name, got %s", args[0])
+			}
+			if impl, ok := args[1].(*Implementation); ok && impl.Interface == iface {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("invoke"), &BuiltinFunction{
+		Name: "invoke",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("invoke expects at least 2 arguments, got %d", len(args))
+			}
+			impl, ok := args[0].(*Implementation)
+			if !ok {
+				return nil, NewTypeError("invoke expects an interface implementation, got %s", TypeName(args[0]))
+			}
+			method, ok := args[1].(Keyword)
+			if !ok {
+				return nil, NewTypeError("invoke expects a keyword method name, got %s", args[1])
+			}
+			return impl.Invoke(string(method), args[2:]...)
+		},
+	})
+}
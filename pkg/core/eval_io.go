@@ -1,36 +1,25 @@
 package core
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 // setupIOOperations adds I/O and file operations to the environment
 func setupIOOperations(env *Environment) {
-	// Console I/O
+	// Console I/O, each call written to the interpreter's output at once
 	env.Set(Intern("println"), &BuiltinFunction{
 		Name: "println",
 		Fn: func(args []Value, env *Environment) (Value, error) {
-			for i, arg := range args {
-				if i > 0 {
-					fmt.Print(" ")
-				}
-				switch v := arg.(type) {
-				case String:
-					fmt.Print(string(v))
-				case Symbol:
-					fmt.Print(string(v))
-				case Keyword:
-					fmt.Print(v.String())
-				case Number:
-					fmt.Print(v.String())
-				case Nil:
-					fmt.Print("nil")
-				default:
-					fmt.Print(arg.String())
-				}
-			}
-			fmt.Println()
+			text, err := joinPrinted(args, env, displayText)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintln(env.output(), text)
 			return Nil{}, nil
 		},
 	})
@@ -38,13 +27,11 @@ func setupIOOperations(env *Environment) {
 	env.Set(Intern("prn"), &BuiltinFunction{
 		Name: "prn",
 		Fn: func(args []Value, env *Environment) (Value, error) {
-			for i, arg := range args {
-				if i > 0 {
-					fmt.Print(" ")
-				}
-				fmt.Print(arg.String())
+			text, err := joinPrinted(args, env, PrintString)
+			if err != nil {
+				return nil, err
 			}
-			fmt.Println()
+			fmt.Fprintln(env.output(), text)
 			return Nil{}, nil
 		},
 	})
@@ -53,25 +40,11 @@ func setupIOOperations(env *Environment) {
 	env.Set(Intern("print"), &BuiltinFunction{
 		Name: "print",
 		Fn: func(args []Value, env *Environment) (Value, error) {
-			for i, arg := range args {
-				if i > 0 {
-					fmt.Print(" ")
-				}
-				switch v := arg.(type) {
-				case String:
-					fmt.Print(string(v))
-				case Symbol:
-					fmt.Print(string(v))
-				case Keyword:
-					fmt.Print(v.String())
-				case Number:
-					fmt.Print(v.String())
-				case Nil:
-					fmt.Print("nil")
-				default:
-					fmt.Print(arg.String())
-				}
+			text, err := joinPrinted(args, env, displayText)
+			if err != nil {
+				return nil, err
 			}
+			fmt.Fprint(env.output(), text)
 			return Nil{}, nil
 		},
 	})
@@ -81,12 +54,12 @@ func setupIOOperations(env *Environment) {
 		Name: "slurp",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("slurp expects 1 argument")
+				return nil, NewArityError("slurp expects 1 argument, got %d", len(args))
 			}
 
 			filename, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("slurp expects string, got %T", args[0])
+				return nil, fmt.Errorf("slurp expects string, got %s", TypeName(args[0]))
 			}
 
 			content, err := os.ReadFile(string(filename))
@@ -98,21 +71,40 @@ func setupIOOperations(env *Environment) {
 		},
 	})
 
+	// Lines read as the sequence is walked; the file is closed at its end
+	env.Set(Intern("line-seq"), &BuiltinFunction{
+		Name: "line-seq",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("line-seq expects 1 argument, got %d", len(args))
+			}
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("line-seq expects a string filename, got %s", TypeName(args[0]))
+			}
+			file, err := os.Open(string(filename))
+			if err != nil {
+				return nil, NewIOError("line-seq error: %v", err)
+			}
+			return lineSeq(bufio.NewReader(file), file), nil
+		},
+	})
+
 	env.Set(Intern("spit"), &BuiltinFunction{
 		Name: "spit",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("spit expects 2 arguments")
+				return nil, NewArityError("spit expects 2 arguments, got %d", len(args))
 			}
 
 			filename, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("spit expects string as first argument, got %T", args[0])
+				return nil, fmt.Errorf("spit expects string as first argument, got %s", TypeName(args[0]))
 			}
 
 			content, ok := args[1].(String)
 			if !ok {
-				return nil, fmt.Errorf("spit expects string as second argument, got %T", args[1])
+				return nil, fmt.Errorf("spit expects string as second argument, got %s", TypeName(args[1]))
 			}
 
 			err := os.WriteFile(string(filename), []byte(content), 0644)
@@ -129,12 +121,12 @@ func setupIOOperations(env *Environment) {
 		Name: "file-exists?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("file-exists? expects 1 argument")
+				return nil, NewArityError("file-exists? expects 1 argument, got %d", len(args))
 			}
 
 			filename, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("file-exists? expects string, got %T", args[0])
+				return nil, fmt.Errorf("file-exists? expects string, got %s", TypeName(args[0]))
 			}
 
 			if _, err := os.Stat(string(filename)); err == nil {
@@ -148,12 +140,12 @@ func setupIOOperations(env *Environment) {
 		Name: "list-dir",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("list-dir expects 1 argument")
+				return nil, NewArityError("list-dir expects 1 argument, got %d", len(args))
 			}
 
 			dirname, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("list-dir expects string, got %T", args[0])
+				return nil, fmt.Errorf("list-dir expects string, got %s", TypeName(args[0]))
 			}
 
 			entries, err := os.ReadDir(string(dirname))
@@ -170,48 +162,189 @@ func setupIOOperations(env *Environment) {
 		},
 	})
 
-	env.Set(Intern("load-file"), &BuiltinFunction{
-		Name: "load-file",
+	// Temporary files and directories
+	env.Set(Intern("tmp-file"), &BuiltinFunction{
+		Name: "tmp-file",
 		Fn: func(args []Value, env *Environment) (Value, error) {
-			if len(args) != 1 {
-				return nil, fmt.Errorf("load-file expects 1 argument")
+			prefix, err := tmpPrefix("tmp-file", args)
+			if err != nil {
+				return nil, err
 			}
-
-			filename, ok := args[0].(String)
-			if !ok {
-				return nil, fmt.Errorf("load-file expects string filename, got %T", args[0])
+			file, err := os.CreateTemp("", prefix+"*")
+			if err != nil {
+				return nil, NewIOError("tmp-file error: %v", err)
 			}
+			file.Close()
+			return String(file.Name()), nil
+		},
+	})
 
-			// Read file content
-			content, err := os.ReadFile(string(filename))
+	env.Set(Intern("tmp-dir"), &BuiltinFunction{
+		Name: "tmp-dir",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			prefix, err := tmpPrefix("tmp-dir", args)
 			if err != nil {
-				return nil, fmt.Errorf("failed to read file %s: %v", filename, err)
+				return nil, err
 			}
-
-			// Parse all expressions
-			lexer := NewLexer(string(content))
-			tokens, err := lexer.Tokenize()
+			dir, err := os.MkdirTemp("", prefix+"*")
 			if err != nil {
-				return nil, fmt.Errorf("failed to tokenize file %s: %v", filename, err)
+				return nil, NewIOError("tmp-dir error: %v", err)
 			}
+			return String(dir), nil
+		},
+	})
 
-			parser := NewParser(tokens)
-			expressions, err := parser.ParseAll()
+	// The with-tmp-dir macro expands to call-with-tmp-dir
+	env.Set(Intern("call-with-tmp-dir"), &BuiltinFunction{
+		Name: "call-with-tmp-dir",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("call-with-tmp-dir expects 1 argument, got %d", len(args))
+			}
+			dir, err := os.MkdirTemp("", "golisp-*")
 			if err != nil {
-				return nil, fmt.Errorf("failed to parse file %s: %v", filename, err)
+				return nil, NewIOError("call-with-tmp-dir error: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			return callFunction(args[0], []Value{String(dir)}, env)
+		},
+	})
+
+	env.Set(Intern("spit-atomic"), &BuiltinFunction{
+		Name: "spit-atomic",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("spit-atomic expects 2 arguments, got %d", len(args))
+			}
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("spit-atomic expects string as first argument, got %s", TypeName(args[0]))
+			}
+			content, ok := args[1].(String)
+			if !ok {
+				return nil, NewTypeError("spit-atomic expects string as second argument, got %s", TypeName(args[1]))
+			}
+			if err := writeFileAtomic(string(filename), []byte(content)); err != nil {
+				return nil, NewIOError("spit-atomic error: %v", err)
 			}
+			return filename, nil
+		},
+	})
 
-			// Evaluate all expressions in the current environment
-			var result Value = Nil{}
-			for _, expr := range expressions {
-				result, err = Eval(expr, env)
-				if err != nil {
-					return nil, fmt.Errorf("failed to evaluate expression in file %s: %v", filename, err)
-				}
+	env.Set(Intern("load-file"), &BuiltinFunction{
+		Name: "load-file",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("load-file expects 1 argument, got %d", len(args))
 			}
 
-			// Return the result of the last expression
-			return result, nil
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, fmt.Errorf("load-file expects string filename, got %s", TypeName(args[0]))
+			}
+
+			return loadFile(string(filename), env)
 		},
 	})
+
+	guardBuiltins(env, []Capability{CapabilityFS}, "slurp", "line-seq", "spit", "file-exists?", "list-dir", "load-file",
+		"tmp-file", "tmp-dir", "call-with-tmp-dir", "spit-atomic")
+}
+
+// lineSeq yields the lines of reader without their line endings, closing
+// file after the last one
+func lineSeq(reader *bufio.Reader, file *os.File) *LazySeq {
+	return NewLazySeq(func() (Value, Value, bool, error) {
+		text, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			file.Close()
+			return nil, nil, false, NewIOError("line-seq error: %v", err)
+		}
+		if text == "" {
+			file.Close()
+			return nil, nil, false, nil
+		}
+		line := strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		return String(line), lineSeq(reader, file), true, nil
+	})
+}
+
+// tmpPrefix reads the optional name prefix of tmp-file and tmp-dir
+func tmpPrefix(name string, args []Value) (string, error) {
+	switch len(args) {
+	case 0:
+		return "golisp-", nil
+	case 1:
+		prefix, ok := args[0].(String)
+		if !ok {
+			return "", NewTypeError("%s expects a string prefix, got %s", name, TypeName(args[0]))
+		}
+		return string(prefix), nil
+	}
+	return "", NewArityError("%s expects 0-1 arguments, got %d", name, len(args))
+}
+
+// writeFileAtomic writes data to a temporary file next to filename and
+// renames it into place, so readers see the old content or the new, never a
+// partial write. An existing file keeps its permissions.
+func writeFileAtomic(filename string, data []byte) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(filename); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // Fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// joinPrinted shows each of args with show, separated by spaces
+func joinPrinted(args []Value, env *Environment, show func(Value, *Environment) (string, error)) (string, error) {
+	var out strings.Builder
+	for i, arg := range args {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		text, err := show(arg, env)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(text)
+	}
+	return out.String(), nil
+}
+
+// displayText is how print and println show a value: strings and symbols
+// without quotes, and collections as prn would
+func displayText(arg Value, env *Environment) (string, error) {
+	switch v := arg.(type) {
+	case String:
+		return string(v), nil
+	case Symbol:
+		return string(v), nil
+	case Keyword:
+		return v.String(), nil
+	case Nil:
+		return "nil", nil
+	default:
+		return PrintString(arg, env)
+	}
 }
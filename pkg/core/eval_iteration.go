@@ -0,0 +1,280 @@
+package core
+
+import "fmt"
+
+// iterClause is one step of a for or doseq binding vector: a binding
+// (name coll), :let [bindings] or :when test
+type iterClause struct {
+	modifier Keyword // "" for a binding, otherwise let or when
+	name     Symbol
+	expr     Value
+}
+
+// parseIterClauses reads [x xs y ys :let [...] :when test ...]
+func parseIterClauses(form string, bindings Value) ([]iterClause, error) {
+	items, err := collectionToSlice(bindings)
+	if err != nil {
+		return nil, fmt.Errorf("%s expects a binding vector", form)
+	}
+	if len(items)%2 != 0 {
+		return nil, fmt.Errorf("%s bindings must be even number of forms", form)
+	}
+
+	var clauses []iterClause
+	for i := 0; i < len(items); i += 2 {
+		switch key := items[i].(type) {
+		case Symbol:
+			clauses = append(clauses, iterClause{name: key, expr: items[i+1]})
+		case Keyword:
+			if key != InternKeyword("let") && key != InternKeyword("when") {
+				return nil, fmt.Errorf("%s: unsupported modifier %s", form, key)
+			}
+			clauses = append(clauses, iterClause{modifier: key, expr: items[i+1]})
+		default:
+			return nil, fmt.Errorf("%s binding names must be symbols", form)
+		}
+	}
+	if len(clauses) == 0 || clauses[0].modifier != "" {
+		return nil, fmt.Errorf("%s expects a binding before any modifier", form)
+	}
+	return clauses, nil
+}
+
+// bindLet evaluates :let bindings into a new environment
+func bindLet(bindings Value, env *Environment) (*Environment, error) {
+	items, err := collectionToSlice(bindings)
+	if err != nil || len(items)%2 != 0 {
+		return nil, fmt.Errorf(":let expects a vector of bindings")
+	}
+	letEnv := NewEnvironment(env)
+	for i := 0; i < len(items); i += 2 {
+		name, ok := items[i].(Symbol)
+		if !ok {
+			return nil, fmt.Errorf("let binding names must be symbols")
+		}
+		value, err := Eval(items[i+1], letEnv)
+		if err != nil {
+			return nil, err
+		}
+		letEnv.Set(name, value)
+	}
+	return letEnv, nil
+}
+
+// iterationSeq evaluates a binding's collection as a sequence that can be
+// walked from the front cheaply
+func iterationSeq(expr Value, env *Environment) (Value, error) {
+	coll, err := Eval(expr, env)
+	if err != nil {
+		return nil, err
+	}
+	switch coll.(type) {
+	case *LazySeq, *List:
+		return coll, nil
+	}
+	items, err := collectionToSlice(coll)
+	if err != nil {
+		return nil, err
+	}
+	return NewList(items...), nil
+}
+
+// lazyDelay is the sequence returned by f, which runs on first use
+func lazyDelay(f func() (Value, error)) *LazySeq {
+	return NewLazySeq(func() (Value, Value, bool, error) {
+		seq, err := f()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		first, ok, err := seqFirst(seq)
+		if err != nil || !ok {
+			return nil, nil, false, err
+		}
+		rest, err := seqRest(seq)
+		return first, rest, true, err
+	})
+}
+
+// forSeq lazily yields body for every combination of clauses[i:]
+func forSeq(clauses []iterClause, i int, env *Environment, body Value) Value {
+	if i == len(clauses) {
+		return NewLazySeq(func() (Value, Value, bool, error) {
+			value, err := Eval(body, env)
+			return value, (*List)(nil), err == nil, err
+		})
+	}
+
+	clause := clauses[i]
+	return lazyDelay(func() (Value, error) {
+		switch clause.modifier {
+		case InternKeyword("let"):
+			letEnv, err := bindLet(clause.expr, env)
+			if err != nil {
+				return nil, err
+			}
+			return forSeq(clauses, i+1, letEnv, body), nil
+		case InternKeyword("when"):
+			test, err := Eval(clause.expr, env)
+			if err != nil {
+				return nil, err
+			}
+			if !isTruthy(test) {
+				return (*List)(nil), nil
+			}
+			return forSeq(clauses, i+1, env, body), nil
+		}
+
+		coll, err := iterationSeq(clause.expr, env)
+		if err != nil {
+			return nil, err
+		}
+		return lazyMapcat(coll, func(x Value) (Value, error) {
+			itemEnv := NewEnvironment(env)
+			itemEnv.Set(clause.name, x)
+			return forSeq(clauses, i+1, itemEnv, body), nil
+		}), nil
+	})
+}
+
+// doseqWalk eagerly evaluates body for every combination of clauses[i:]
+func doseqWalk(clauses []iterClause, i int, env *Environment, body []Value) error {
+	if i == len(clauses) {
+		for _, expr := range body {
+			if _, err := Eval(expr, env); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	clause := clauses[i]
+	switch clause.modifier {
+	case InternKeyword("let"):
+		letEnv, err := bindLet(clause.expr, env)
+		if err != nil {
+			return err
+		}
+		return doseqWalk(clauses, i+1, letEnv, body)
+	case InternKeyword("when"):
+		test, err := Eval(clause.expr, env)
+		if err != nil {
+			return err
+		}
+		if !isTruthy(test) {
+			return nil
+		}
+		return doseqWalk(clauses, i+1, env, body)
+	}
+
+	coll, err := iterationSeq(clause.expr, env)
+	if err != nil {
+		return err
+	}
+	for {
+		if err := checkDeadline(env); err != nil {
+			return err
+		}
+		x, ok, err := seqFirst(coll)
+		if err != nil || !ok {
+			return err
+		}
+		itemEnv := NewEnvironment(env)
+		itemEnv.Set(clause.name, x)
+		if err := doseqWalk(clauses, i+1, itemEnv, body); err != nil {
+			return err
+		}
+		if coll, err = seqRest(coll); err != nil {
+			return err
+		}
+	}
+}
+
+// evalFor implements (for [bindings] expr), returning a lazy sequence
+func evalFor(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) != 2 {
+		return nil, fmt.Errorf("for expects a binding vector and one body expression")
+	}
+	clauses, err := parseIterClauses("for", argSlice[0])
+	if err != nil {
+		return nil, err
+	}
+	return forSeq(clauses, 0, env, argSlice[1]), nil
+}
+
+// evalDoseq implements (doseq [bindings] body...) for side effects
+func evalDoseq(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) < 1 {
+		return nil, fmt.Errorf("doseq expects a binding vector")
+	}
+	clauses, err := parseIterClauses("doseq", argSlice[0])
+	if err != nil {
+		return nil, err
+	}
+	return Nil{}, doseqWalk(clauses, 0, env, argSlice[1:])
+}
+
+// evalDotimes implements (dotimes [i n] body...), binding i from 0 to n-1
+func evalDotimes(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) < 1 {
+		return nil, fmt.Errorf("dotimes expects a binding vector")
+	}
+	binding, err := collectionToSlice(argSlice[0])
+	if err != nil || len(binding) != 2 {
+		return nil, fmt.Errorf("dotimes expects [name count]")
+	}
+	name, ok := binding[0].(Symbol)
+	if !ok {
+		return nil, fmt.Errorf("dotimes binding name must be a symbol")
+	}
+	countValue, err := Eval(binding[1], env)
+	if err != nil {
+		return nil, err
+	}
+	count, ok := countValue.(Number)
+	if !ok {
+		return nil, fmt.Errorf("dotimes expects a number, got %s", TypeName(countValue))
+	}
+
+	for i := int64(0); i < count.ToInt(); i++ {
+		if err := checkDeadline(env); err != nil {
+			return nil, err
+		}
+		loopEnv := NewEnvironment(env)
+		loopEnv.Set(name, NewNumber(i))
+		for _, expr := range argSlice[1:] {
+			if _, err := Eval(expr, loopEnv); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return Nil{}, nil
+}
+
+// evalWhile implements (while test body...), evaluating body in the current
+// environment so that it can change what test sees
+func evalWhile(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) < 1 {
+		return nil, fmt.Errorf("while expects a condition")
+	}
+	for {
+		if err := checkDeadline(env); err != nil {
+			return nil, err
+		}
+		test, err := Eval(argSlice[0], env)
+		if err != nil {
+			return nil, err
+		}
+		if !isTruthy(test) {
+			return Nil{}, nil
+		}
+		for _, expr := range argSlice[1:] {
+			if _, err := Eval(expr, env); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Log levels in increasing order of severity
+var logLevels = map[Keyword]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+// logSink is a single log destination
+type logSink struct {
+	writer io.Writer
+	closer io.Closer
+	json   bool
+}
+
+// setupLoggingOperations adds the log/* structured logging functions to the environment
+func setupLoggingOperations(env *Environment) {
+	// Minimum level that gets emitted, looked up at call time so it can be rebound
+	env.Set(Intern("*log-level*"), InternKeyword("info"))
+
+	for _, level := range []Keyword{"debug", "info", "warn", "error"} {
+		level := level
+		name := "log/" + string(level)
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) < 1 || len(args) > 2 {
+					return nil, NewArityError("%s expects 1-2 arguments, got %d", name, len(args))
+				}
+				return logMessage(env, level, args)
+			},
+		})
+	}
+
+	env.Set(Intern("log/log"), &BuiltinFunction{
+		Name: "log/log",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, NewArityError("log/log expects 2-3 arguments, got %d", len(args))
+			}
+
+			level, ok := args[0].(Keyword)
+			if !ok {
+				return nil, NewTypeError("log/log expects keyword level, got %s", TypeName(args[0]))
+			}
+			if _, known := logLevels[level]; !known {
+				return nil, NewRuntimeError("unknown log level: %s", level)
+			}
+
+			return logMessage(env, level, args[1:])
+		},
+	})
+
+	env.Set(Intern("log/set-sinks!"), &BuiltinFunction{
+		Name: "log/set-sinks!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("log/set-sinks! expects 1 argument, got %d", len(args))
+			}
+
+			specs, err := collectionToSlice(args[0])
+			if err != nil {
+				return nil, NewTypeError("log/set-sinks! expects a collection of sink specs, got %s", TypeName(args[0]))
+			}
+
+			sinks := []*logSink{}
+			for _, spec := range specs {
+				sink, err := openLogSink(spec, env)
+				if err != nil {
+					closeLogSinks(sinks)
+					return nil, err
+				}
+				sinks = append(sinks, sink)
+			}
+
+			state := env.interpreterState()
+			state.mu.Lock()
+			closeLogSinks(state.logSinks)
+			state.logSinks = sinks
+			state.mu.Unlock()
+
+			return Nil{}, nil
+		},
+	})
+}
+
+// openLogSink creates a sink from a spec like {:type :file :path "app.log" :format :json}
+func openLogSink(spec Value, env *Environment) (*logSink, error) {
+	hm, ok := spec.(*HashMap)
+	if !ok {
+		return nil, NewTypeError("log sink spec must be a hash-map, got %s", TypeName(spec))
+	}
+
+	sink := &logSink{}
+
+	switch format := hm.Get(InternKeyword("format")).(type) {
+	case Nil:
+	case Keyword:
+		switch format {
+		case "json":
+			sink.json = true
+		case "text":
+		default:
+			return nil, NewRuntimeError("unknown log format: %s", format)
+		}
+	default:
+		return nil, NewTypeError("log sink :format must be a keyword, got %s", TypeName(format))
+	}
+
+	sinkType, _ := hm.Get(InternKeyword("type")).(Keyword)
+	switch sinkType {
+	case "stderr", "":
+		sink.writer = env.errorOutput()
+	case "stdout":
+		sink.writer = env.output()
+	case "file":
+		path, ok := hm.Get(InternKeyword("path")).(String)
+		if !ok {
+			return nil, NewTypeError("file log sink requires a string :path")
+		}
+		if err := checkCapabilities("file log sink", []Capability{CapabilityFS}, env); err != nil {
+			return nil, err
+		}
+		file, err := os.OpenFile(string(path), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, NewIOError("failed to open log file %s: %v", path, err)
+		}
+		sink.writer = file
+		sink.closer = file
+	default:
+		return nil, NewRuntimeError("unknown log sink type: %s", sinkType)
+	}
+
+	return sink, nil
+}
+
+// closeLogSinks closes any sinks that own a file
+func closeLogSinks(sinks []*logSink) {
+	for _, sink := range sinks {
+		if sink.closer != nil {
+			sink.closer.Close()
+		}
+	}
+}
+
+// logMessage writes a log record to every sink if level passes *log-level*
+func logMessage(env *Environment, level Keyword, args []Value) (Value, error) {
+	threshold := InternKeyword("info")
+	if current, err := env.Get(Intern("*log-level*")); err == nil {
+		if kw, ok := current.(Keyword); ok {
+			threshold = kw
+		}
+	}
+	if logLevels[level] < logLevels[threshold] {
+		return Nil{}, nil
+	}
+
+	var msg string
+	if str, ok := args[0].(String); ok {
+		msg = string(str)
+	} else {
+		msg = args[0].String()
+	}
+
+	var fields *HashMap
+	if len(args) == 2 {
+		hm, ok := args[1].(*HashMap)
+		if !ok {
+			return nil, NewTypeError("log context must be a hash-map, got %s", TypeName(args[1]))
+		}
+		fields = hm
+	}
+
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	sinks := state.logSinks
+	if sinks == nil {
+		sinks = []*logSink{{writer: state.reports.output()}}
+	}
+	for _, sink := range sinks {
+		var line string
+		if sink.json {
+			line = formatLogJSON(timestamp, level, msg, fields)
+		} else {
+			line = formatLogText(timestamp, level, msg, fields)
+		}
+		if _, err := io.WriteString(sink.writer, line+"\n"); err != nil {
+			return nil, NewIOError("failed to write log record: %v", err)
+		}
+	}
+
+	return Nil{}, nil
+}
+
+// formatLogText renders a record as "time LEVEL msg key=value ..."
+func formatLogText(timestamp string, level Keyword, msg string, fields *HashMap) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s %-5s %s", timestamp, strings.ToUpper(string(level)), msg))
+	if fields != nil {
+		for _, key := range fields.keys {
+			out.WriteString(fmt.Sprintf(" %s=%s", mapKeyToString(key), fields.Get(key).String()))
+		}
+	}
+	return out.String()
+}
+
+// formatLogJSON renders a record as a single JSON line
+func formatLogJSON(timestamp string, level Keyword, msg string, fields *HashMap) string {
+	record := map[string]any{}
+	if fields != nil {
+		for _, key := range fields.keys {
+			record[mapKeyToString(key)] = valueToGo(fields.Get(key))
+		}
+	}
+	record["time"] = timestamp
+	record["level"] = string(level)
+	record["msg"] = msg
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Sprintf(`{"time":%q,"level":%q,"msg":%q,"error":%q}`, timestamp, level, msg, err.Error())
+	}
+	return string(data)
+}
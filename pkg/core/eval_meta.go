@@ -2,12 +2,9 @@ package core
 
 import (
 	"fmt"
-	"sync/atomic"
+	"strings"
 )
 
-// Global counter for gensym
-var gensymCounter int64
-
 // setupMetaProgramming adds meta-programming functions and type predicates to the environment
 func setupMetaProgramming(env *Environment) {
 	// Basic language literals
@@ -20,7 +17,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "eval",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("eval expects 1 argument")
+				return nil, NewArityError("eval expects 1 argument, got %d", len(args))
 			}
 
 			return Eval(args[0], env)
@@ -31,15 +28,15 @@ func setupMetaProgramming(env *Environment) {
 		Name: "read-string",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("read-string expects 1 argument")
+				return nil, NewArityError("read-string expects 1 argument, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("read-string expects string, got %T", args[0])
+				return nil, fmt.Errorf("read-string expects string, got %s", TypeName(args[0]))
 			}
 
-			return ReadString(string(str))
+			return env.ReadString(string(str))
 		},
 	})
 
@@ -47,22 +44,15 @@ func setupMetaProgramming(env *Environment) {
 		Name: "read-all-string",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("read-all-string expects 1 argument")
+				return nil, NewArityError("read-all-string expects 1 argument, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
 			if !ok {
-				return nil, fmt.Errorf("read-all-string expects string, got %T", args[0])
-			}
-
-			lexer := NewLexer(string(str))
-			tokens, err := lexer.Tokenize()
-			if err != nil {
-				return nil, fmt.Errorf("failed to tokenize: %v", err)
+				return nil, fmt.Errorf("read-all-string expects string, got %s", TypeName(args[0]))
 			}
 
-			parser := NewParser(tokens)
-			expressions, err := parser.ParseAll()
+			expressions, err := env.NewReader(strings.NewReader(string(str))).ReadAll()
 			if err != nil {
 				return nil, fmt.Errorf("failed to parse: %v", err)
 			}
@@ -76,7 +66,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "throw",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("throw expects 1 argument")
+				return nil, NewArityError("throw expects 1 argument, got %d", len(args))
 			}
 
 			// Convert the argument to a string for the error message
@@ -103,15 +93,39 @@ func setupMetaProgramming(env *Environment) {
 				} else if sym, ok := args[0].(Symbol); ok {
 					prefix = string(sym)
 				} else {
-					return nil, fmt.Errorf("gensym expects string or symbol as prefix, got %T", args[0])
+					return nil, fmt.Errorf("gensym expects string or symbol as prefix, got %s", TypeName(args[0]))
 				}
 			} else {
-				return nil, fmt.Errorf("gensym expects 0 or 1 arguments, got %d", len(args))
+				return nil, NewArityError("gensym expects 0 or 1 arguments, got %d", len(args))
 			}
 
-			// Atomically increment the counter to ensure uniqueness
-			id := atomic.AddInt64(&gensymCounter, 1)
-			return Symbol(fmt.Sprintf("%s%d", prefix, id)), nil
+			return Symbol(fmt.Sprintf("%s%d", prefix, nextGensym(env))), nil
+		},
+	})
+
+	// The with-gensym-seed macro expands to call-with-gensym-seed
+	env.Set(Intern("call-with-gensym-seed"), &BuiltinFunction{
+		Name: "call-with-gensym-seed",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("call-with-gensym-seed expects 2 arguments, got %d", len(args))
+			}
+			seed, ok := args[0].(Number)
+			if !ok || !seed.IsInteger() {
+				return nil, NewTypeError("call-with-gensym-seed expects an integer seed, got %s", TypeName(args[0]))
+			}
+			return callFunction(args[1], nil, withGensymSeed(env, seed.ToInt()))
+		},
+	})
+
+	env.Set(Intern("reset-gensym!"), &BuiltinFunction{
+		Name: "reset-gensym!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("reset-gensym! expects no arguments, got %d", len(args))
+			}
+			env.ResetGensym()
+			return Nil{}, nil
 		},
 	})
 
@@ -119,19 +133,68 @@ func setupMetaProgramming(env *Environment) {
 		Name: "macroexpand",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("macroexpand expects 1 argument")
+				return nil, NewArityError("macroexpand expects 1 argument, got %d", len(args))
 			}
 
 			return macroExpand(args[0], env)
 		},
 	})
 
+	env.Set(Intern("form-position"), &BuiltinFunction{
+		Name: "form-position",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("form-position expects 1 argument, got %d", len(args))
+			}
+			list, ok := args[0].(*List)
+			if !ok || list.GetPosition().Line == 0 {
+				return Nil{}, nil
+			}
+			pos := list.GetPosition()
+			position := NewHashMapWithPairs(
+				InternKeyword("line"), NewNumber(int64(pos.Line)),
+				InternKeyword("column"), NewNumber(int64(pos.Column)),
+			)
+			if pos.File != "" {
+				position.Set(InternKeyword("file"), String(pos.File))
+			}
+			return position, nil
+		},
+	})
+
+	env.Set(Intern("type"), &BuiltinFunction{
+		Name: "type",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("type expects 1 argument, got %d", len(args))
+			}
+			return InternKeyword(TypeName(args[0])), nil
+		},
+	})
+
+	env.Set(Intern("instance?"), &BuiltinFunction{
+		Name: "instance?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("instance? expects 2 arguments, got %d", len(args))
+			}
+			kind, ok := args[0].(Keyword)
+			if !ok {
+				return nil, NewTypeError("instance? expects a type keyword like :vector, got %s", TypeName(args[0]))
+			}
+			if isInstance(kind, args[1]) {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
 	// Basic type predicates
 	env.Set(Intern("symbol?"), &BuiltinFunction{
 		Name: "symbol?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("symbol? expects 1 argument")
+				return nil, NewArityError("symbol? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(Symbol); ok {
@@ -145,10 +208,11 @@ func setupMetaProgramming(env *Environment) {
 		Name: "number?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("number? expects 1 argument")
+				return nil, NewArityError("number? expects 1 argument, got %d", len(args))
 			}
 
-			if _, ok := args[0].(Number); ok {
+			switch args[0].(type) {
+			case Number, Decimal:
 				return Symbol("true"), nil
 			}
 			return Nil{}, nil
@@ -159,7 +223,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "keyword?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("keyword? expects 1 argument")
+				return nil, NewArityError("keyword? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(Keyword); ok {
@@ -173,7 +237,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "nil?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("nil? expects 1 argument")
+				return nil, NewArityError("nil? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(Nil); ok {
@@ -187,10 +251,26 @@ func setupMetaProgramming(env *Environment) {
 		Name: "fn?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("fn? expects 1 argument")
+				return nil, NewArityError("fn? expects 1 argument, got %d", len(args))
 			}
 
-			if _, ok := args[0].(Function); ok {
+			switch args[0].(type) {
+			case *BuiltinFunction, *UserFunction:
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	// ifn? is true for anything that can be called, including keywords and vars
+	env.Set(Intern("ifn?"), &BuiltinFunction{
+		Name: "ifn?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("ifn? expects 1 argument, got %d", len(args))
+			}
+
+			if _, ok := args[0].(Callable); ok {
 				return Symbol("true"), nil
 			}
 			return Nil{}, nil
@@ -202,7 +282,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "symbol",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("symbol expects 1 argument")
+				return nil, NewArityError("symbol expects 1 argument, got %d", len(args))
 			}
 
 			switch arg := args[0].(type) {
@@ -211,7 +291,7 @@ func setupMetaProgramming(env *Environment) {
 			case Symbol:
 				return arg, nil
 			default:
-				return nil, fmt.Errorf("symbol expects string or symbol, got %T", args[0])
+				return nil, fmt.Errorf("symbol expects string or symbol, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -220,7 +300,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "keyword",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("keyword expects 1 argument")
+				return nil, NewArityError("keyword expects 1 argument, got %d", len(args))
 			}
 
 			switch arg := args[0].(type) {
@@ -235,7 +315,7 @@ func setupMetaProgramming(env *Environment) {
 			case Keyword:
 				return arg, nil
 			default:
-				return nil, fmt.Errorf("keyword expects string, symbol, or keyword, got %T", args[0])
+				return nil, fmt.Errorf("keyword expects string, symbol, or keyword, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -244,7 +324,7 @@ func setupMetaProgramming(env *Environment) {
 		Name: "name",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("name expects 1 argument")
+				return nil, NewArityError("name expects 1 argument, got %d", len(args))
 			}
 
 			switch arg := args[0].(type) {
@@ -260,7 +340,7 @@ func setupMetaProgramming(env *Environment) {
 				}
 				return arg, nil
 			default:
-				return nil, fmt.Errorf("name expects symbol, keyword, or string, got %T", args[0])
+				return nil, fmt.Errorf("name expects symbol, keyword, or string, got %s", TypeName(args[0]))
 			}
 		},
 	})
@@ -293,14 +373,8 @@ func macroExpand(expr Value, env *Environment) (Value, error) {
 		return expr, nil
 	}
 
-	// Collect arguments for macro expansion
-	args := listToSlice(list.Rest())
-
 	// Create environment for macro expansion
-	macroEnv := NewEnvironment(macro.Env)
-
-	// Bind macro parameters to arguments
-	err = bindParams(macro.Params, args, macroEnv)
+	macroEnv, err := macroEnvironment(macro, list, env)
 	if err != nil {
 		return nil, fmt.Errorf("macro expansion error: %v", err)
 	}
@@ -313,3 +387,25 @@ func macroExpand(expr Value, env *Environment) (Value, error) {
 
 	return expanded, nil
 }
+
+// typeGroups are the type keywords instance? accepts for several types
+var typeGroups = map[Keyword][]string{
+	"number":     {"integer", "float", "decimal"},
+	"sequential": {"list", "vector", "lazy-seq"},
+	"collection": {"list", "vector", "lazy-seq", "hash-map", "set"},
+}
+
+// isInstance reports whether value is of the type named by kind, as
+// returned by type, or of one of the types in its group
+func isInstance(kind Keyword, value Value) bool {
+	name := TypeName(value)
+	if string(kind) == name {
+		return true
+	}
+	for _, member := range typeGroups[kind] {
+		if member == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,393 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// moduleInfo records where a namespace was loaded from
+type moduleInfo struct {
+	path    string
+	modTime time.Time
+}
+
+// loadedFile records a file evaluated by load-file, to skip loading it again
+// while it is unchanged
+type loadedFile struct {
+	modTime time.Time
+	result  Value
+}
+
+// loadingFile is a file being evaluated, named as it was given
+type loadingFile struct {
+	path string // Canonical path
+	name string
+}
+
+// moduleRegistry tracks namespaces loaded with require and files loaded with
+// load-file, per root environment
+type moduleRegistry struct {
+	sync.Mutex
+	modules map[Symbol]*moduleInfo
+	order   []Symbol
+	files   map[string]*loadedFile
+	loading []loadingFile // Files being loaded, outermost first
+}
+
+func newModuleRegistry() *moduleRegistry {
+	return &moduleRegistry{modules: make(map[Symbol]*moduleInfo), files: make(map[string]*loadedFile)}
+}
+
+// canonicalPath makes path absolute and resolves symlinks, so one file
+// reached through different paths is recognised
+func canonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	return path
+}
+
+// trackLoad runs load for the file at path, failing with the chain of files
+// involved if the file is already being loaded, so that files which load
+// each other report the cycle instead of recursing. *file* and *dir* are
+// bound to the file and its directory while it loads.
+func trackLoad(path string, env *Environment, load func() (Value, error)) (Value, error) {
+	registry := env.moduleRegistry()
+	file := loadingFile{path: canonicalPath(path), name: path}
+
+	registry.Lock()
+	for i, loading := range registry.loading {
+		if loading.path == file.path {
+			var chain []string
+			for _, f := range registry.loading[i:] {
+				chain = append(chain, f.name)
+			}
+			registry.Unlock()
+			return nil, NewRuntimeError("circular load: %s", strings.Join(append(chain, path), " -> "))
+		}
+	}
+	registry.loading = append(registry.loading, file)
+	registry.Unlock()
+
+	defer func() {
+		registry.Lock()
+		defer registry.Unlock()
+		for i := len(registry.loading) - 1; i >= 0; i-- {
+			if registry.loading[i] == file {
+				registry.loading = append(registry.loading[:i], registry.loading[i+1:]...)
+				break
+			}
+		}
+	}()
+	return withFileBindings(path, env, load)
+}
+
+// withFileBindings binds *file* to the absolute path and *dir* to its
+// directory in env's root while load runs, restoring the previous values
+// afterwards so that nested loads see their own file
+func withFileBindings(path string, env *Environment, load func() (Value, error)) (Value, error) {
+	root := env.root()
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	file, dir := Intern("*file*"), Intern("*dir*")
+	previousFile, hadFile := root.binding(file)
+	previousDir, hadDir := root.binding(dir)
+	root.Set(file, String(path))
+	root.Set(dir, String(filepath.Dir(path)))
+	defer func() {
+		if !hadFile {
+			previousFile = Nil{}
+		}
+		if !hadDir {
+			previousDir = Nil{}
+		}
+		root.Set(file, previousFile)
+		root.Set(dir, previousDir)
+	}()
+	return load()
+}
+
+// resolveLoadPath resolves a relative path against *dir*, the directory of
+// the file being loaded, so files can load their siblings wherever the
+// process runs from. Outside a file it is left relative to the working
+// directory.
+func resolveLoadPath(path string, env *Environment) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	if dir, err := env.Get(Intern("*dir*")); err == nil {
+		if dir, ok := dir.(String); ok && dir != "" {
+			return filepath.Join(string(dir), path)
+		}
+	}
+	return path
+}
+
+// loadFile evaluates the file at path in env, unless it was loaded before
+// and has not changed since, in which case the value it returned then is
+// returned again. A relative path is resolved with resolveLoadPath.
+func loadFile(path string, env *Environment) (Value, error) {
+	path = resolveLoadPath(path, env)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", path, err)
+	}
+
+	registry := env.moduleRegistry()
+	canonical := canonicalPath(path)
+	registry.Lock()
+	previous, loaded := registry.files[canonical]
+	registry.Unlock()
+	if loaded && previous.modTime.Equal(info.ModTime()) {
+		return previous.result, nil
+	}
+
+	result, err := trackLoad(path, env, func() (Value, error) {
+		return evalFile(path, env)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	registry.Lock()
+	registry.files[canonical] = &loadedFile{modTime: info.ModTime(), result: result}
+	registry.Unlock()
+	return result, nil
+}
+
+// evalFile evaluates the forms of a file in env as they are read, returning
+// the value of the last one
+func evalFile(filename string, env *Environment) (Value, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	reader := env.NewReader(file)
+	reader.SetFile(filename)
+	var result Value = Nil{}
+	for {
+		expr, _, err := reader.Next()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %v", filename, err)
+		}
+		result, err = Eval(expr, env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate expression in file %s: %v", filename, err)
+		}
+	}
+}
+
+// root returns the outermost environment
+func (env *Environment) root() *Environment {
+	for env.parent != nil {
+		env = env.parent
+	}
+	return env
+}
+
+// moduleRegistry returns the registry of the root environment
+func (env *Environment) moduleRegistry() *moduleRegistry {
+	root := env.root()
+	if root.modules == nil {
+		root.modules = newModuleRegistry()
+	}
+	return root.modules
+}
+
+// setupModuleOperations adds require, reload and the namespace search path
+func setupModuleOperations(env *Environment) {
+	// Directories searched when resolving namespaces to files
+	env.Set(Intern("*load-path*"), NewVector(String("."), String("src")))
+	// The file being loaded and its directory, nil outside of one
+	env.Set(Intern("*file*"), Nil{})
+	env.Set(Intern("*dir*"), Nil{})
+
+	env.Set(Intern("require"), &BuiltinFunction{
+		Name: "require",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) == 0 {
+				return nil, NewArityError("require expects at least 1 argument, got 0")
+			}
+
+			for _, arg := range args {
+				ns, err := namespaceSymbol("require", arg)
+				if err != nil {
+					return nil, err
+				}
+
+				registry := env.moduleRegistry()
+				registry.Lock()
+				_, loaded := registry.modules[ns]
+				registry.Unlock()
+
+				if !loaded {
+					if err := loadNamespace(ns, env); err != nil {
+						return nil, err
+					}
+				}
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("reload"), &BuiltinFunction{
+		Name: "reload",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("reload expects 1 argument, got %d", len(args))
+			}
+
+			ns, err := namespaceSymbol("reload", args[0])
+			if err != nil {
+				return nil, err
+			}
+
+			if err := loadNamespace(ns, env); err != nil {
+				return nil, err
+			}
+			return ns, nil
+		},
+	})
+
+	env.Set(Intern("loaded-namespaces"), &BuiltinFunction{
+		Name: "loaded-namespaces",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("loaded-namespaces expects 0 arguments, got %d", len(args))
+			}
+
+			registry := env.moduleRegistry()
+			registry.Lock()
+			defer registry.Unlock()
+
+			names := make([]Value, len(registry.order))
+			for i, ns := range registry.order {
+				names[i] = ns
+			}
+			return NewVector(names...), nil
+		},
+	})
+
+	guardBuiltins(env, []Capability{CapabilityFS}, "require", "reload")
+}
+
+// namespaceSymbol accepts 'my.ns or "my.ns"
+func namespaceSymbol(fnName string, arg Value) (Symbol, error) {
+	switch v := arg.(type) {
+	case Symbol:
+		return v, nil
+	case String:
+		return Symbol(v), nil
+	default:
+		return "", NewTypeError("%s expects namespace symbol, got %s", fnName, TypeName(arg))
+	}
+}
+
+// shippedNamespaces are the optional namespaces in lisp/stdlib, which
+// require finds when *load-path* has no file of the same name
+var shippedNamespaces = map[Symbol]bool{
+	"compat": true, // Names from the legacy interpreter dialect
+	"rules":  true, // defrule and run-rules
+}
+
+// resolveNamespace finds the file for ns (my.ns -> my/ns.lisp) on *load-path*,
+// then among the shippedNamespaces
+func resolveNamespace(ns Symbol, env *Environment) (string, error) {
+	relative := filepath.Join(strings.Split(string(ns), ".")...) + ".lisp"
+
+	var dirs []Value
+	if loadPath, err := env.Get(Intern("*load-path*")); err == nil {
+		dirs, _ = collectionToSlice(loadPath)
+	}
+
+	for _, dir := range dirs {
+		dirName, ok := dir.(String)
+		if !ok {
+			continue
+		}
+		candidate := filepath.Join(string(dirName), relative)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	if shippedNamespaces[ns] {
+		if candidate, err := findStdlibFile(filepath.Join("lisp", "stdlib", relative)); err == nil {
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate, nil
+			}
+		}
+	}
+
+	return "", NewIOError("could not find namespace %s (%s) on *load-path*", ns, relative)
+}
+
+// loadNamespace (re)evaluates the file for ns in the global environment
+func loadNamespace(ns Symbol, env *Environment) error {
+	path, err := resolveNamespace(ns, env)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return NewIOError("failed to stat %s: %v", path, err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return NewIOError("failed to read file %s: %v", path, err)
+	}
+
+	_, err = trackLoad(path, env, func() (Value, error) {
+		return nil, loadFileContent(string(content), path, env.root())
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load namespace %s from %s: %v", ns, path, err)
+	}
+
+	registry := env.moduleRegistry()
+	registry.Lock()
+	defer registry.Unlock()
+	if _, exists := registry.modules[ns]; !exists {
+		registry.order = append(registry.order, ns)
+	}
+	registry.modules[ns] = &moduleInfo{path: path, modTime: info.ModTime()}
+	return nil
+}
+
+// ChangedNamespaces returns loaded namespaces whose files changed on disk
+func ChangedNamespaces(env *Environment) []Symbol {
+	registry := env.moduleRegistry()
+	registry.Lock()
+	defer registry.Unlock()
+
+	var changed []Symbol
+	for _, ns := range registry.order {
+		module := registry.modules[ns]
+		info, err := os.Stat(module.path)
+		if err == nil && !info.ModTime().Equal(module.modTime) {
+			changed = append(changed, ns)
+		}
+	}
+	return changed
+}
+
+// ReloadNamespace re-evaluates a namespace's file in env
+func ReloadNamespace(ns Symbol, env *Environment) error {
+	return loadNamespace(ns, env)
+}
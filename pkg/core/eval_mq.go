@@ -0,0 +1,300 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mqQueueSize bounds the messages waiting for mq-dispatch; when it is full
+// the connection stops reading until handlers catch up
+const mqQueueSize = 1024
+
+// MQConnection is a message queue connection made with mq-connect.
+// Subscription handlers never run on the network goroutine: messages queue
+// up until mq-dispatch calls the handlers on the interpreter's goroutine.
+// The network goroutine only holds the queue, not the connection or the
+// handlers, which may refer to it, so a forgotten connection is collected.
+type MQConnection struct {
+	conn     *natsConn
+	pending  chan natsMsg // Received, waiting for the handler of their subscription
+	closed   chan struct{}
+	mu       sync.Mutex
+	handlers map[int64]Value // By subscription id
+}
+
+func (c *MQConnection) String() string {
+	return fmt.Sprintf("#<mq %s>", c.conn.url)
+}
+
+func (c *MQConnection) open() bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// close stops the connection; closing it again does nothing
+func (c *MQConnection) close() {
+	c.closer()()
+}
+
+func (c *MQConnection) closer() func() bool {
+	conn, closed := c.conn, c.closed
+	return func() bool {
+		select {
+		case <-closed:
+			return false
+		default:
+			close(closed)
+			conn.close()
+			return true
+		}
+	}
+}
+
+// mqMessage is the hash-map a handler receives
+func mqMessage(msg natsMsg) *HashMap {
+	m := NewHashMapWithPairs(
+		Keyword("subject"), String(msg.subject),
+		Keyword("data"), String(msg.data),
+	)
+	if msg.reply != "" {
+		m.Set(Keyword("reply"), String(msg.reply))
+	}
+	return m
+}
+
+// validSubject reports whether s can be sent as a subject
+func validSubject(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n")
+}
+
+// dispatch calls the handlers of queued messages, waiting up to wait for the
+// first one, and returns how many ran
+func (c *MQConnection) dispatch(wait time.Duration, env *Environment) (int, error) {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	count := 0
+	for {
+		// A server error under --werror fails dispatch like a handler would
+		if err := c.conn.serverError(); err != nil {
+			return count, err
+		}
+		var msg natsMsg
+		if count == 0 && timeout != nil {
+			select {
+			case msg = <-c.pending:
+			case <-timeout:
+				return 0, c.conn.serverError()
+			}
+		} else {
+			select {
+			case msg = <-c.pending:
+			default:
+				return count, nil
+			}
+		}
+		c.mu.Lock()
+		handler, ok := c.handlers[msg.sid]
+		c.mu.Unlock()
+		if !ok {
+			continue // Unsubscribed since the message arrived
+		}
+		if _, err := callFunction(handler, []Value{mqMessage(msg)}, env); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
+
+func mqArgs(name string, args []Value, n int) (*MQConnection, error) {
+	if len(args) != n {
+		return nil, NewArityError("%s expects %d arguments, got %d", name, n, len(args))
+	}
+	conn, ok := args[0].(*MQConnection)
+	if !ok {
+		return nil, NewTypeError("%s expects an mq connection, got %s", name, TypeName(args[0]))
+	}
+	return conn, nil
+}
+
+func mqSubject(name string, value Value) (string, error) {
+	subject, ok := value.(String)
+	if !ok || !validSubject(string(subject)) {
+		return "", NewTypeError("%s expects a subject string without spaces, got %s", name, value)
+	}
+	return string(subject), nil
+}
+
+// setupMQOperations adds the NATS client: mq-connect, mq-publish,
+// mq-subscribe, mq-unsubscribe, mq-dispatch, mq-flush and mq-close
+func setupMQOperations(env *Environment) {
+	env.Set(Intern("mq-connect"), &BuiltinFunction{
+		Name: "mq-connect",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("mq-connect expects 1 argument, got %d", len(args))
+			}
+			address, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("mq-connect expects a URL string, got %s", TypeName(args[0]))
+			}
+			conn, err := dialNATS(string(address), env.interpreterState().reports)
+			if err != nil {
+				return nil, NewIOError("mq-connect: %v", err)
+			}
+			c := &MQConnection{
+				conn:     conn,
+				pending:  make(chan natsMsg, mqQueueSize),
+				closed:   make(chan struct{}),
+				handlers: make(map[int64]Value),
+			}
+			closeWhenForgotten(c, env)
+			return c, nil
+		},
+	})
+
+	env.Set(Intern("mq-publish"), &BuiltinFunction{
+		Name: "mq-publish",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			conn, err := mqArgs("mq-publish", args, 3)
+			if err != nil {
+				return nil, err
+			}
+			subject, err := mqSubject("mq-publish", args[1])
+			if err != nil {
+				return nil, err
+			}
+
+			// Strings are sent as is, anything else in its printed form
+			payload, ok := args[2].(String)
+			if !ok {
+				payload = String(printString(args[2]))
+			}
+			if err := conn.conn.publish(subject, []byte(payload)); err != nil {
+				return nil, NewIOError("mq-publish: %v", err)
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("mq-subscribe"), &BuiltinFunction{
+		Name: "mq-subscribe",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			conn, err := mqArgs("mq-subscribe", args, 3)
+			if err != nil {
+				return nil, err
+			}
+			subject, err := mqSubject("mq-subscribe", args[1])
+			if err != nil {
+				return nil, err
+			}
+			handler := args[2]
+			if _, ok := handler.(Callable); !ok {
+				return nil, NewTypeError("mq-subscribe expects a handler function, got %s", TypeName(handler))
+			}
+
+			// The delivery function must not refer to conn or handler
+			pending, closed := conn.pending, conn.closed
+			deliver := func(msg natsMsg) {
+				select {
+				case pending <- msg:
+				case <-closed:
+				}
+			}
+			// Held until the handler is recorded, so mq-dispatch finds it
+			conn.mu.Lock()
+			defer conn.mu.Unlock()
+			sid, err := conn.conn.subscribe(subject, deliver)
+			if err != nil {
+				return nil, NewIOError("mq-subscribe: %v", err)
+			}
+			conn.handlers[sid] = handler
+			return NewNumber(sid), nil
+		},
+	})
+
+	env.Set(Intern("mq-unsubscribe"), &BuiltinFunction{
+		Name: "mq-unsubscribe",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			conn, err := mqArgs("mq-unsubscribe", args, 2)
+			if err != nil {
+				return nil, err
+			}
+			sid, ok := args[1].(Number)
+			if !ok || !sid.IsInteger() {
+				return nil, NewTypeError("mq-unsubscribe expects a subscription id, got %s", args[1])
+			}
+			if err := conn.conn.unsubscribe(sid.ToInt()); err != nil {
+				return nil, NewRuntimeError("mq-unsubscribe: %v", err)
+			}
+			conn.mu.Lock()
+			delete(conn.handlers, sid.ToInt())
+			conn.mu.Unlock()
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("mq-dispatch"), &BuiltinFunction{
+		Name: "mq-dispatch",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("mq-dispatch expects 1-2 arguments, got %d", len(args))
+			}
+			conn, err := mqArgs("mq-dispatch", args[:1], 1)
+			if err != nil {
+				return nil, err
+			}
+			var wait time.Duration
+			if len(args) == 2 {
+				ms, ok := args[1].(Number)
+				if !ok || ms.ToFloat() < 0 {
+					return nil, NewTypeError("mq-dispatch expects a timeout in milliseconds, got %s", args[1])
+				}
+				wait = time.Duration(ms.ToFloat() * float64(time.Millisecond))
+			}
+			count, err := conn.dispatch(wait, env)
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(count)), nil
+		},
+	})
+
+	env.Set(Intern("mq-flush"), &BuiltinFunction{
+		Name: "mq-flush",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			conn, err := mqArgs("mq-flush", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			if err := conn.conn.flush(); err != nil {
+				return nil, NewIOError("mq-flush: %v", err)
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("mq-close"), &BuiltinFunction{
+		Name: "mq-close",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			conn, err := mqArgs("mq-close", args, 1)
+			if err != nil {
+				return nil, err
+			}
+			conn.close()
+			return Nil{}, nil
+		},
+	})
+
+	guardBuiltins(env, []Capability{CapabilityNet}, "mq-connect")
+}
@@ -0,0 +1,316 @@
+package core
+
+import (
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PluginAPIVersion is the plugin API implemented by this interpreter; a
+// plugin built against another version is refused
+const PluginAPIVersion = 1
+
+// PluginSymbol is the symbol a Go plugin (.so) exports: a
+// func() *core.Plugin returning its description
+const PluginSymbol = "GoLispPlugin"
+
+// Capability is a class of side effect that builtins may need, which an
+// embedder or --sandbox can deny as a whole
+type Capability string
+
+const (
+	CapabilityFS   Capability = "fs"   // Reading and writing files
+	CapabilityNet  Capability = "net"  // Network access
+	CapabilityExec Capability = "exec" // Running other programs
+)
+
+// allCapabilities is what native code of unknown intent may do
+var allCapabilities = []Capability{CapabilityFS, CapabilityNet, CapabilityExec}
+
+// Plugin is a bundle of builtins contributed by an embedder or by a Go
+// plugin loaded at runtime
+type Plugin struct {
+	Name         string
+	Version      string
+	APIVersion   int // Must equal PluginAPIVersion
+	Description  string
+	Requires     []string     // Plugins that must be loaded first, by name
+	Capabilities []Capability // Side effects its builtins need
+	Pure         bool         // Its builtins have no side effects
+	Register     func(env *Environment) error
+}
+
+// needs returns the capabilities p needs: a plugin that declares none and
+// isn't Pure is assumed to need them all
+func (p *Plugin) needs() []Capability {
+	if len(p.Capabilities) == 0 && !p.Pure {
+		return allCapabilities
+	}
+	return p.Capabilities
+}
+
+// PluginFactory creates a plugin on demand, so each environment that loads
+// it gets fresh state
+type PluginFactory func() *Plugin
+
+// pluginFactories are the plugins embedders made available by name
+var pluginFactories = struct {
+	sync.Mutex
+	factories map[string]PluginFactory
+}{factories: make(map[string]PluginFactory)}
+
+// RegisterPlugin makes a plugin available to (load-plugin "name") and to
+// the dependency resolution of other plugins
+func RegisterPlugin(name string, factory PluginFactory) {
+	pluginFactories.Lock()
+	defer pluginFactories.Unlock()
+	pluginFactories.factories[name] = factory
+}
+
+// pluginRegistry tracks the plugins loaded into a root environment
+type pluginRegistry struct {
+	sync.Mutex
+	loaded  map[string]*Plugin
+	order   []string
+	loading map[string]bool // Plugins whose requirements are being resolved
+	denied  map[Capability]bool
+}
+
+// pluginRegistry returns the registry of the root environment
+func (env *Environment) pluginRegistry() *pluginRegistry {
+	root := env.root()
+	if root.plugins == nil {
+		root.plugins = &pluginRegistry{
+			loaded:  make(map[string]*Plugin),
+			loading: make(map[string]bool),
+			denied:  make(map[Capability]bool),
+		}
+	}
+	return root.plugins
+}
+
+// DenyCapabilities forbids plugins needing any of caps from loading, and
+// builtins needing them from running, in env and everything sharing its root
+func (env *Environment) DenyCapabilities(caps ...Capability) {
+	registry := env.pluginRegistry()
+	registry.Lock()
+	defer registry.Unlock()
+	for _, c := range caps {
+		registry.denied[c] = true
+	}
+}
+
+// checkCapabilities returns an error naming the first of caps that is denied
+func checkCapabilities(name string, caps []Capability, env *Environment) error {
+	registry := env.pluginRegistry()
+	registry.Lock()
+	defer registry.Unlock()
+	for _, c := range caps {
+		if registry.denied[c] {
+			return NewRuntimeError("%s needs the %s capability, which is denied", name, c)
+		}
+	}
+	return nil
+}
+
+// guardBuiltins makes the named builtins of env check caps on every call, so
+// denying a capability later also stops calls through saved references
+func guardBuiltins(env *Environment, caps []Capability, names ...Symbol) {
+	for _, name := range names {
+		bound, _ := env.binding(name)
+		builtin, ok := bound.(*BuiltinFunction)
+		if !ok {
+			continue
+		}
+		fn := builtin.Fn
+		builtin.Fn = func(args []Value, env *Environment) (Value, error) {
+			if err := checkCapabilities(builtin.Name, caps, env); err != nil {
+				return nil, err
+			}
+			return fn(args, env)
+		}
+	}
+}
+
+// LoadPlugin registers p into env after loading the plugins it requires.
+// Loading a plugin that is already loaded does nothing
+func LoadPlugin(p *Plugin, env *Environment) error {
+	if p == nil || p.Name == "" {
+		return NewRuntimeError("plugin has no name")
+	}
+	if p.APIVersion != PluginAPIVersion {
+		return NewRuntimeError("plugin %s uses API version %d, this interpreter supports %d", p.Name, p.APIVersion, PluginAPIVersion)
+	}
+
+	if err := checkCapabilities("plugin "+p.Name, p.needs(), env); err != nil {
+		return err
+	}
+
+	registry := env.pluginRegistry()
+	registry.Lock()
+	_, loaded := registry.loaded[p.Name]
+	cycle := registry.loading[p.Name]
+	if !loaded && !cycle {
+		registry.loading[p.Name] = true
+	}
+	registry.Unlock()
+	if loaded {
+		return nil
+	}
+	if cycle {
+		return NewRuntimeError("plugin %s depends on itself", p.Name)
+	}
+	defer func() {
+		registry.Lock()
+		delete(registry.loading, p.Name)
+		registry.Unlock()
+	}()
+
+	for _, name := range p.Requires {
+		if err := loadPluginByName(name, env); err != nil {
+			return NewRuntimeError("plugin %s requires %s: %v", p.Name, name, err)
+		}
+	}
+	if p.Register != nil {
+		root := env.root()
+		added, err := recordDefinitions(root, p.Name, p.Name, func() error { return p.Register(root) })
+		if err != nil {
+			return err
+		}
+
+		// Builtins the plugin defined check its capabilities when called
+		guardBuiltins(root, p.needs(), added...)
+	}
+
+	registry.Lock()
+	registry.loaded[p.Name] = p
+	registry.order = append(registry.order, p.Name)
+	registry.Unlock()
+	return nil
+}
+
+// loadPluginByName loads a plugin made available with RegisterPlugin
+func loadPluginByName(name string, env *Environment) error {
+	pluginFactories.Lock()
+	factory, ok := pluginFactories.factories[name]
+	pluginFactories.Unlock()
+	if !ok {
+		return NewNameError("unknown plugin: %s", name)
+	}
+	return LoadPlugin(factory(), env)
+}
+
+// LoadPluginFile opens a Go plugin built with -buildmode=plugin and loads
+// the plugin described by its GoLispPlugin function. Opening the file runs
+// its native code, whatever it declares, so it needs every capability.
+func LoadPluginFile(path string, env *Environment) (*Plugin, error) {
+	if err := checkCapabilities("plugin file "+path, allCapabilities, env); err != nil {
+		return nil, err
+	}
+	so, err := plugin.Open(path)
+	if err != nil {
+		return nil, NewIOError("cannot open plugin %s: %v", path, err)
+	}
+	sym, err := so.Lookup(PluginSymbol)
+	if err != nil {
+		return nil, NewRuntimeError("%s does not export %s", path, PluginSymbol)
+	}
+	factory, ok := sym.(func() *Plugin)
+	if !ok {
+		return nil, NewTypeError("%s.%s must be a func() *core.Plugin, got %T", path, PluginSymbol, sym)
+	}
+	p := factory()
+	return p, LoadPlugin(p, env)
+}
+
+// pluginInfo describes a plugin to Lisp code
+func pluginInfo(p *Plugin) *HashMap {
+	requires := make([]Value, len(p.Requires))
+	for i, name := range p.Requires {
+		requires[i] = String(name)
+	}
+	info := NewHashMap()
+	info.Set(InternKeyword("name"), String(p.Name))
+	info.Set(InternKeyword("version"), String(p.Version))
+	info.Set(InternKeyword("api-version"), NewNumber(int64(p.APIVersion)))
+	info.Set(InternKeyword("description"), String(p.Description))
+	info.Set(InternKeyword("requires"), NewVector(requires...))
+	capabilities := make([]Value, len(p.needs()))
+	for i, c := range p.needs() {
+		capabilities[i] = InternKeyword(string(c))
+	}
+	info.Set(InternKeyword("capabilities"), NewVector(capabilities...))
+	return info
+}
+
+// setupPluginOperations adds plugins, available-plugins and load-plugin
+func setupPluginOperations(env *Environment) {
+	env.Set(Intern("plugins"), &BuiltinFunction{
+		Name: "plugins",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("plugins expects 0 arguments, got %d", len(args))
+			}
+			registry := env.pluginRegistry()
+			registry.Lock()
+			defer registry.Unlock()
+			infos := make([]Value, len(registry.order))
+			for i, name := range registry.order {
+				infos[i] = pluginInfo(registry.loaded[name])
+			}
+			return NewVector(infos...), nil
+		},
+	})
+
+	env.Set(Intern("available-plugins"), &BuiltinFunction{
+		Name: "available-plugins",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("available-plugins expects 0 arguments, got %d", len(args))
+			}
+			pluginFactories.Lock()
+			names := make([]string, 0, len(pluginFactories.factories))
+			for name := range pluginFactories.factories {
+				names = append(names, name)
+			}
+			pluginFactories.Unlock()
+			sort.Strings(names)
+			values := make([]Value, len(names))
+			for i, name := range names {
+				values[i] = String(name)
+			}
+			return NewVector(values...), nil
+		},
+	})
+
+	env.Set(Intern("load-plugin"), &BuiltinFunction{
+		Name: "load-plugin",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("load-plugin expects 1 argument, got %d", len(args))
+			}
+			name, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("load-plugin expects a plugin name or path, got %s", TypeName(args[0]))
+			}
+
+			// Paths name Go plugin files, anything else a registered plugin
+			if strings.HasSuffix(string(name), ".so") || strings.ContainsRune(string(name), filepath.Separator) {
+				p, err := LoadPluginFile(string(name), env)
+				if err != nil {
+					return nil, err
+				}
+				return pluginInfo(p), nil
+			}
+			if err := loadPluginByName(string(name), env); err != nil {
+				return nil, err
+			}
+			registry := env.pluginRegistry()
+			registry.Lock()
+			defer registry.Unlock()
+			return pluginInfo(registry.loaded[string(name)]), nil
+		},
+	})
+}
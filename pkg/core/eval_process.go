@@ -0,0 +1,130 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+// exitHook is a function registered with on-exit
+type exitHook struct {
+	fn  Value
+	env *Environment
+}
+
+// osExit terminates the process; replaced in tests
+var osExit = os.Exit
+
+// processStart is when the process started, for the uptime runtime-stats
+// reports
+var processStart = time.Now()
+
+// setupProcessOperations adds exit and on-exit, and runtime-stats and gc!
+// for looking into a long-running process
+func setupProcessOperations(env *Environment) {
+	env.Set(Intern("exit"), &BuiltinFunction{
+		Name: "exit",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) > 1 {
+				return nil, NewArityError("exit expects 0-1 arguments, got %d", len(args))
+			}
+			if env.pooled() {
+				return nil, NewRuntimeError("exit is not available to pooled scripts")
+			}
+
+			code := 0
+			if len(args) == 1 {
+				n, ok := args[0].(Number)
+				if !ok || !n.IsInteger() {
+					return nil, NewTypeError("exit expects integer status code, got %s", TypeName(args[0]))
+				}
+				code = int(n.ToInt())
+			}
+
+			env.Exit(code)
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("on-exit"), &BuiltinFunction{
+		Name: "on-exit",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("on-exit expects 1 argument, got %d", len(args))
+			}
+			// Hooks would pile up for the life of the pool
+			if env.pooled() {
+				return nil, NewRuntimeError("on-exit is not available to pooled scripts")
+			}
+
+			if _, ok := args[0].(Callable); !ok {
+				return nil, NewTypeError("on-exit expects a function, got %s", TypeName(args[0]))
+			}
+
+			state := env.interpreterState()
+			state.mu.Lock()
+			state.exitHooks = append(state.exitHooks, exitHook{fn: args[0], env: env})
+			state.mu.Unlock()
+			return Nil{}, nil
+		},
+	})
+
+	// The process, not just this interpreter: memory and goroutines are
+	// shared by every interpreter in it
+	env.Set(Intern("runtime-stats"), &BuiltinFunction{
+		Name: "runtime-stats",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("runtime-stats expects 0 arguments, got %d", len(args))
+			}
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			return NewHashMapWithPairs(
+				InternKeyword("heap-alloc"), NewNumber(int64(mem.HeapAlloc)),
+				InternKeyword("num-gc"), NewNumber(int64(mem.NumGC)),
+				InternKeyword("goroutines"), NewNumber(int64(runtime.NumGoroutine())),
+				InternKeyword("uptime"), Duration{time.Since(processStart).Truncate(time.Millisecond)},
+			), nil
+		},
+	})
+
+	env.Set(Intern("gc!"), &BuiltinFunction{
+		Name: "gc!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("gc! expects 0 arguments, got %d", len(args))
+			}
+			runtime.GC()
+			return Nil{}, nil
+		},
+	})
+}
+
+// RunExitHooks calls the functions the interpreter registered with
+// on-exit, most recent first. Hooks run at most once; errors are reported
+// on the interpreter's error output.
+func (env *Environment) RunExitHooks() {
+	state := env.interpreterState()
+	state.mu.Lock()
+	if state.exitHooksRan {
+		state.mu.Unlock()
+		return
+	}
+	state.exitHooksRan = true
+	hooks := state.exitHooks
+	state.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if _, err := callFunction(hooks[i].fn, nil, hooks[i].env); err != nil {
+			fmt.Fprintf(hooks[i].env.errorOutput(), "Error in on-exit hook: %v\n", err)
+		}
+	}
+}
+
+// Exit runs the interpreter's on-exit hooks and terminates the process with
+// code
+func (env *Environment) Exit(code int) {
+	env.RunExitHooks()
+	osExit(code)
+}
@@ -0,0 +1,262 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// registeredFunction records where a top-level binding came from
+type registeredFunction struct {
+	category string // Core category, "stdlib" or the plugin name
+	plugin   string // Set for bindings a plugin defined
+	value    Value  // The binding as defined, to notice user redefinitions
+}
+
+// functionRegistry tracks the origin of the bindings of a root environment
+type functionRegistry struct {
+	sync.Mutex
+	entries map[Symbol]registeredFunction
+}
+
+// functionRegistry returns the registry of the root environment
+func (env *Environment) functionRegistry() *functionRegistry {
+	root := env.root()
+	if root.functions == nil {
+		root.functions = &functionRegistry{entries: make(map[Symbol]registeredFunction)}
+	}
+	return root.functions
+}
+
+// recordDefinitions runs define and files every binding it adds or replaces
+// in env's root under category, returning their names
+func recordDefinitions(env *Environment, category, plugin string, define func() error) ([]Symbol, error) {
+	root := env.root()
+	before := root.snapshot()
+	err := define()
+
+	var added []Symbol
+	registry := env.functionRegistry()
+	registry.Lock()
+	defer registry.Unlock()
+	for name, value := range root.snapshot() {
+		if old, existed := before[name]; existed && sameValue(old, value) {
+			continue
+		}
+		added = append(added, name)
+		registry.entries[name] = registeredFunction{category: category, plugin: plugin, value: value}
+	}
+	return added, err
+}
+
+// sameValue reports whether a and b are the identical value
+func sameValue(a, b Value) bool {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// lookupFunction returns the registry entry of name, unless user code has
+// since rebound it
+func lookupFunction(name Symbol, env *Environment) (registeredFunction, Value, bool) {
+	value, err := env.root().Get(name)
+	if err != nil {
+		return registeredFunction{}, nil, false
+	}
+	registry := env.functionRegistry()
+	registry.Lock()
+	entry, ok := registry.entries[name]
+	registry.Unlock()
+	if !ok || !sameValue(entry.value, value) {
+		entry = registeredFunction{category: "user"}
+	}
+	return entry, value, true
+}
+
+// isFunctionValue reports whether value is something registered-functions lists
+func isFunctionValue(value Value) bool {
+	switch value.(type) {
+	case *BuiltinFunction, *UserFunction, *Macro:
+		return true
+	}
+	return false
+}
+
+// functionHelp describes the function bound to name
+func functionHelp(name Symbol, entry registeredFunction, value Value) *HashMap {
+	help := NewHashMap()
+	help.Set(InternKeyword("name"), String(name))
+	help.Set(InternKeyword("category"), String(entry.category))
+	if entry.plugin != "" {
+		help.Set(InternKeyword("plugin"), String(entry.plugin))
+	}
+
+	var kind, doc string
+	var params *List
+	switch fn := value.(type) {
+	case *BuiltinFunction:
+		kind = "builtin"
+	case *UserFunction:
+		kind, doc, params = "function", fn.Doc, fn.Params
+	case *Macro:
+		kind, doc, params = "macro", fn.Doc, fn.Params
+	}
+	help.Set(InternKeyword("kind"), InternKeyword(kind))
+	if doc != "" {
+		help.Set(InternKeyword("doc"), String(doc))
+	}
+	if kind != "builtin" {
+		help.Set(InternKeyword("arglists"), NewVector(NewVector(listToSlice(params)...)))
+	}
+	return help
+}
+
+// functionName accepts "map" or 'map
+func functionName(fnName string, arg Value) (Symbol, error) {
+	switch v := arg.(type) {
+	case String:
+		return Symbol(v), nil
+	case Symbol:
+		return v, nil
+	default:
+		return "", NewTypeError("%s expects a function name, got %s", fnName, TypeName(arg))
+	}
+}
+
+// setupRegistryOperations adds registered-functions, function-help,
+// function-category and plugin-info
+func setupRegistryOperations(env *Environment) {
+	env.Set(Intern("registered-functions"), &BuiltinFunction{
+		Name: "registered-functions",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) > 1 {
+				return nil, NewArityError("registered-functions expects 0-1 arguments, got %d", len(args))
+			}
+			var category String
+			if len(args) == 1 {
+				var ok bool
+				if category, ok = args[0].(String); !ok {
+					return nil, NewTypeError("registered-functions expects a category string, got %s", TypeName(args[0]))
+				}
+			}
+
+			var names []Value
+			for _, name := range env.root().GetAllSymbols() {
+				entry, value, ok := lookupFunction(Symbol(name), env)
+				if !ok || !isFunctionValue(value) {
+					continue
+				}
+				if category == "" || entry.category == string(category) {
+					names = append(names, String(name))
+				}
+			}
+			return NewVector(names...), nil
+		},
+	})
+
+	env.Set(Intern("function-categories"), &BuiltinFunction{
+		Name: "function-categories",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("function-categories expects 0 arguments, got %d", len(args))
+			}
+			seen := make(map[string]bool)
+			for _, name := range env.root().GetAllSymbols() {
+				if entry, value, ok := lookupFunction(Symbol(name), env); ok && isFunctionValue(value) {
+					seen[entry.category] = true
+				}
+			}
+			categories := make([]string, 0, len(seen))
+			for category := range seen {
+				categories = append(categories, category)
+			}
+			sort.Strings(categories)
+			values := make([]Value, len(categories))
+			for i, category := range categories {
+				values[i] = String(category)
+			}
+			return NewVector(values...), nil
+		},
+	})
+
+	env.Set(Intern("function-help"), &BuiltinFunction{
+		Name: "function-help",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("function-help expects 1 argument, got %d", len(args))
+			}
+			name, err := functionName("function-help", args[0])
+			if err != nil {
+				return nil, err
+			}
+			entry, value, ok := lookupFunction(name, env)
+			if !ok || !isFunctionValue(value) {
+				return Nil{}, nil
+			}
+			return functionHelp(name, entry, value), nil
+		},
+	})
+
+	env.Set(Intern("function-category"), &BuiltinFunction{
+		Name: "function-category",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("function-category expects 1 argument, got %d", len(args))
+			}
+			name, err := functionName("function-category", args[0])
+			if err != nil {
+				return nil, err
+			}
+			entry, value, ok := lookupFunction(name, env)
+			if !ok || !isFunctionValue(value) {
+				return Nil{}, nil
+			}
+			return String(entry.category), nil
+		},
+	})
+
+	env.Set(Intern("plugin-info"), &BuiltinFunction{
+		Name: "plugin-info",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("plugin-info expects 1 argument, got %d", len(args))
+			}
+			name, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("plugin-info expects a plugin name, got %s", TypeName(args[0]))
+			}
+
+			plugins := env.pluginRegistry()
+			plugins.Lock()
+			p, loaded := plugins.loaded[string(name)]
+			plugins.Unlock()
+
+			// Core categories describe themselves like built-in plugins
+			var functions []Value
+			for _, sym := range env.root().GetAllSymbols() {
+				entry, value, ok := lookupFunction(Symbol(sym), env)
+				if !ok || !isFunctionValue(value) {
+					continue
+				}
+				if (loaded && entry.plugin == p.Name) || (!loaded && entry.plugin == "" && entry.category == string(name)) {
+					functions = append(functions, String(sym))
+				}
+			}
+
+			var info *HashMap
+			switch {
+			case loaded:
+				info = pluginInfo(p)
+			case len(functions) > 0:
+				info = NewHashMap()
+				info.Set(InternKeyword("name"), name)
+				info.Set(InternKeyword("builtin"), Symbol("true"))
+			default:
+				return Nil{}, nil
+			}
+			info.Set(InternKeyword("functions"), NewVector(functions...))
+			return info, nil
+		},
+	})
+}
@@ -0,0 +1,290 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Job is a function run on a schedule by schedule or every-ms. Each job
+// runs on its own goroutine, one run at a time; a run that fails or panics
+// is reported to the job's error handler and the job carries on.
+type Job struct {
+	description string
+	fn          Value
+	onError     Value // Called with the error message, or nil to print it
+	env         *Environment
+	next        func(time.Time) time.Time // When to run after a given time
+	done        chan struct{}
+	once        sync.Once
+}
+
+func (j *Job) String() string {
+	state := "active"
+	if !j.Active() {
+		state = "cancelled"
+	}
+	return fmt.Sprintf("#<job %s %s>", j.description, state)
+}
+
+// Active reports whether the job has not been cancelled
+func (j *Job) Active() bool {
+	select {
+	case <-j.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Cancel stops the job; a run in progress finishes
+func (j *Job) Cancel() {
+	j.once.Do(func() {
+		close(j.done)
+		jobs.Done()
+	})
+}
+
+// jobs counts the active jobs, for wait-jobs
+var jobs sync.WaitGroup
+
+func startJob(job *Job) *Job {
+	job.done = make(chan struct{})
+	jobs.Add(1)
+	go func() {
+		timer := time.NewTimer(0)
+		timer.Stop()
+		for {
+			at := job.next(time.Now())
+			if at.IsZero() {
+				job.Cancel()
+				return
+			}
+			timer.Reset(time.Until(at))
+			select {
+			case <-job.done:
+				timer.Stop()
+				return
+			case <-timer.C:
+				// Both may be ready at once; a cancelled job never starts a run
+				if job.Active() {
+					job.run()
+				}
+			}
+		}
+	}()
+	return job
+}
+
+// run calls the job's function once, turning errors and panics into calls
+// of the error handler
+func (j *Job) run() {
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = NewRuntimeError("panic: %v", r)
+			}
+		}()
+		_, err = callFunction(j.fn, nil, j.env)
+		return err
+	}()
+	if err == nil {
+		return
+	}
+
+	if j.onError != nil {
+		if _, handlerErr := callFunction(j.onError, []Value{String(err.Error())}, j.env); handlerErr == nil {
+			return
+		}
+	}
+	fmt.Fprintf(j.env.errorOutput(), "Error in job %s: %v\n", j.description, err)
+}
+
+// jobOptions reads the optional :on-error handler after a job's function
+func jobOptions(name string, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return nil, nil
+	}
+	if len(args) != 2 || args[0] != Keyword("on-error") {
+		return nil, NewArityError("%s expects :on-error handler as its only option", name)
+	}
+	if _, ok := args[1].(Callable); !ok {
+		return nil, NewTypeError("%s expects an :on-error function, got %s", name, TypeName(args[1]))
+	}
+	return args[1], nil
+}
+
+// setupSchedulerOperations adds schedule, every-ms, cancel-job, job-active?
+// and wait-jobs, and parse-cron, next-run and runs-between for planning
+// when schedules run
+func setupSchedulerOperations(env *Environment) {
+	env.Set(Intern("schedule"), &BuiltinFunction{
+		Name: "schedule",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("schedule expects at least 2 arguments, got %d", len(args))
+			}
+			cron, err := cronArg("schedule", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := args[1].(Callable); !ok {
+				return nil, NewTypeError("schedule expects a function, got %s", TypeName(args[1]))
+			}
+			onError, err := jobOptions("schedule", args[2:])
+			if err != nil {
+				return nil, err
+			}
+
+			return startJob(&Job{
+				description: fmt.Sprintf("%q", cron.expr),
+				fn:          args[1],
+				onError:     onError,
+				env:         env,
+				next:        cron.next,
+			}), nil
+		},
+	})
+
+	env.Set(Intern("every-ms"), &BuiltinFunction{
+		Name: "every-ms",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("every-ms expects at least 2 arguments, got %d", len(args))
+			}
+			ms, ok := args[0].(Number)
+			if !ok || ms.ToFloat() <= 0 {
+				return nil, NewTypeError("every-ms expects a positive interval in milliseconds, got %s", args[0])
+			}
+			if _, ok := args[1].(Callable); !ok {
+				return nil, NewTypeError("every-ms expects a function, got %s", TypeName(args[1]))
+			}
+			onError, err := jobOptions("every-ms", args[2:])
+			if err != nil {
+				return nil, err
+			}
+
+			interval := time.Duration(ms.ToFloat() * float64(time.Millisecond))
+			return startJob(&Job{
+				description: fmt.Sprintf("every %s", interval),
+				fn:          args[1],
+				onError:     onError,
+				env:         env,
+				next:        func(t time.Time) time.Time { return t.Add(interval) },
+			}), nil
+		},
+	})
+
+	env.Set(Intern("cancel-job"), &BuiltinFunction{
+		Name: "cancel-job",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("cancel-job expects 1 argument, got %d", len(args))
+			}
+			job, ok := args[0].(*Job)
+			if !ok {
+				return nil, NewTypeError("cancel-job expects a job, got %s", TypeName(args[0]))
+			}
+			job.Cancel()
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("job-active?"), &BuiltinFunction{
+		Name: "job-active?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("job-active? expects 1 argument, got %d", len(args))
+			}
+			job, ok := args[0].(*Job)
+			if !ok {
+				return nil, NewTypeError("job-active? expects a job, got %s", TypeName(args[0]))
+			}
+			if job.Active() {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	// Daemon-style scripts end with (wait-jobs) to keep running
+	env.Set(Intern("wait-jobs"), &BuiltinFunction{
+		Name: "wait-jobs",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("wait-jobs expects 0 arguments, got %d", len(args))
+			}
+			jobs.Wait()
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("parse-cron"), &BuiltinFunction{
+		Name: "parse-cron",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("parse-cron expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(String); !ok {
+				return nil, NewTypeError("parse-cron expects a cron expression string, got %s", TypeName(args[0]))
+			}
+			return cronArg("parse-cron", args[0])
+		},
+	})
+
+	// (next-run sched) is the next run from now, (next-run sched from) the
+	// first after an instant, or nil if there is none within five years
+	env.Set(Intern("next-run"), &BuiltinFunction{
+		Name: "next-run",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("next-run expects 1 or 2 arguments, got %d", len(args))
+			}
+			cron, err := cronArg("next-run", args[0])
+			if err != nil {
+				return nil, err
+			}
+			from := time.Now()
+			if len(args) == 2 {
+				inst, ok := args[1].(Inst)
+				if !ok {
+					return nil, NewTypeError("next-run expects an instant, got %s", TypeName(args[1]))
+				}
+				from = inst.Time
+			}
+			next := cron.next(from)
+			if next.IsZero() {
+				return Nil{}, nil
+			}
+			return Inst{Time: next}, nil
+		},
+	})
+
+	// Runs from the first instant, inclusive, up to the second
+	env.Set(Intern("runs-between"), &BuiltinFunction{
+		Name: "runs-between",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("runs-between expects 3 arguments, got %d", len(args))
+			}
+			cron, err := cronArg("runs-between", args[0])
+			if err != nil {
+				return nil, err
+			}
+			start, ok1 := args[1].(Inst)
+			end, ok2 := args[2].(Inst)
+			if !ok1 || !ok2 {
+				return nil, NewTypeError("runs-between expects two instants, got %s and %s", TypeName(args[1]), TypeName(args[2]))
+			}
+			runs, err := cron.between(start.Time, end.Time)
+			if err != nil {
+				return nil, NewRuntimeError("runs-between: %v", err)
+			}
+			result := make([]Value, len(runs))
+			for i, run := range runs {
+				result[i] = Inst{Time: run}
+			}
+			return NewVector(result...), nil
+		},
+	})
+}
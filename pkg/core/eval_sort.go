@@ -0,0 +1,121 @@
+package core
+
+import "sort"
+
+// sortOptions are the trailing :locale and :natural options of sort-by and
+// sort-natural, which change how strings compare
+type sortOptions struct {
+	collator *collator // nil compares strings by code point
+	natural  bool
+}
+
+func parseSortOptions(name string, args []Value) (sortOptions, error) {
+	var opts sortOptions
+	if len(args)%2 != 0 {
+		return opts, NewArityError("%s expects options as keyword/value pairs", name)
+	}
+	for i := 0; i < len(args); i += 2 {
+		switch args[i] {
+		case Keyword("locale"):
+			locale, ok := args[i+1].(String)
+			if !ok {
+				return opts, NewTypeError("%s expects a locale string like \"sv\", got %s", name, args[i+1])
+			}
+			c, ok := newCollator(string(locale))
+			if !ok {
+				return opts, NewRuntimeError("%s: unsupported locale %q", name, string(locale))
+			}
+			opts.collator = c
+		case Keyword("natural"):
+			opts.natural = isTruthy(args[i+1])
+		default:
+			return opts, NewRuntimeError("%s: unknown option %s, expected :locale or :natural", name, args[i])
+		}
+	}
+	return opts, nil
+}
+
+// compare orders two sort keys; strings follow the options, anything else
+// orders like sorted-map keys
+func (opts sortOptions) compare(a, b Value) int {
+	sa, aString := a.(String)
+	sb, bString := b.(String)
+	switch {
+	case !aString || !bString:
+		return compareKeys(a, b)
+	case opts.natural:
+		return naturalCompare(string(sa), string(sb), opts.collator)
+	case opts.collator != nil:
+		return opts.collator.compare(string(sa), string(sb))
+	}
+	return compareKeys(a, b)
+}
+
+// sortBy stably sorts coll by the key keyFn returns for each element,
+// calling it once per element
+func sortBy(keyFn Value, coll Value, opts sortOptions, env *Environment) (Value, error) {
+	items, err := collectionToSlice(coll)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]Value, len(items))
+	for i, item := range items {
+		if keyFn == nil {
+			keys[i] = item
+		} else if keys[i], err = callFunction(keyFn, []Value{item}, env); err != nil {
+			return nil, err
+		}
+	}
+
+	order := make([]int, len(items))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return opts.compare(keys[order[i]], keys[order[j]]) < 0
+	})
+	sorted := make([]Value, len(items))
+	for i, index := range order {
+		sorted[i] = items[index]
+	}
+	return NewList(sorted...), nil
+}
+
+// setupSortOperations adds sort-by and sort-natural
+func setupSortOperations(env *Environment) {
+	env.Set(Intern("sort-by"), &BuiltinFunction{
+		Name: "sort-by",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("sort-by expects at least 2 arguments, got %d", len(args))
+			}
+			if _, ok := args[0].(Callable); !ok {
+				return nil, NewTypeError("sort-by expects a key function, got %s", TypeName(args[0]))
+			}
+			opts, err := parseSortOptions("sort-by", args[2:])
+			if err != nil {
+				return nil, err
+			}
+			return sortBy(args[0], args[1], opts, env)
+		},
+	})
+
+	// Text compares ignoring case and accents unless :locale says otherwise
+	env.Set(Intern("sort-natural"), &BuiltinFunction{
+		Name: "sort-natural",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("sort-natural expects at least 1 argument, got %d", len(args))
+			}
+			opts, err := parseSortOptions("sort-natural", args[1:])
+			if err != nil {
+				return nil, err
+			}
+			opts.natural = true
+			if opts.collator == nil {
+				opts.collator, _ = newCollator("en")
+			}
+			return sortBy(nil, args[0], opts, env)
+		},
+	})
+}
@@ -2,6 +2,10 @@ package core
 
 import (
 	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"weak"
 )
 
 // evalSpecialForm handles special forms
@@ -17,7 +21,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 	case "quasiquote":
 		argSlice := listToSlice(args)
 		if len(argSlice) != 1 {
-			return nil, fmt.Errorf("quasiquote expects 1 argument, got %d", len(argSlice))
+			return nil, NewArityError("quasiquote expects 1 argument, got %d", len(argSlice))
 		}
 		return evalQuasiquote(argSlice[0], env)
 
@@ -47,7 +51,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 
 		sym, ok := argSlice[0].(Symbol)
 		if !ok {
-			return nil, fmt.Errorf("def expects symbol as first argument, got %T", argSlice[0])
+			return nil, fmt.Errorf("def expects symbol as first argument, got %s", TypeName(argSlice[0]))
 		}
 
 		value, err := Eval(argSlice[1], env)
@@ -55,13 +59,15 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 			return nil, err
 		}
 
-		env.Set(sym, value)
+		if err := env.define(sym, value); err != nil {
+			return nil, err
+		}
 		return sym, nil
 
 	case "fn":
 		argSlice := listToSlice(args)
 		if len(argSlice) < 2 {
-			return nil, fmt.Errorf("fn expects at least 2 arguments, got %d", len(argSlice))
+			return nil, NewArityError("fn expects at least 2 arguments, got %d", len(argSlice))
 		}
 
 		// Handle both lists and vectors for parameters
@@ -77,7 +83,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 			}
 			params = NewList(elements...)
 		default:
-			return nil, fmt.Errorf("fn expects list or vector as first argument, got %T", argSlice[0])
+			return nil, fmt.Errorf("fn expects list or vector as first argument, got %s", TypeName(argSlice[0]))
 		}
 
 		// Handle multiple body expressions by wrapping in 'do'
@@ -93,6 +99,10 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 			body = NewList(doList...)
 		}
 
+		if err := checkStrictFunction(args, "", params, body, env); err != nil {
+			return nil, err
+		}
+
 		return &UserFunction{
 			Params: params,
 			Body:   body,
@@ -116,7 +126,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 	case "let":
 		argSlice := listToSlice(args)
 		if len(argSlice) < 2 {
-			return nil, fmt.Errorf("let expects at least 2 arguments")
+			return nil, NewArityError("let expects at least 2 arguments, got %d", len(argSlice))
 		}
 
 		// Create new environment for let bindings
@@ -170,18 +180,24 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 
 	case "defmacro":
 		argSlice := listToSlice(args)
-		if len(argSlice) != 3 {
-			return nil, fmt.Errorf("defmacro expects 3 arguments (name params body), got %d", len(argSlice))
+		if len(argSlice) < 1 {
+			return nil, NewArityError("defmacro expects 3 arguments (name params body), got %d", len(argSlice))
 		}
 
 		sym, ok := argSlice[0].(Symbol)
 		if !ok {
-			return nil, fmt.Errorf("defmacro expects symbol as first argument, got %T", argSlice[0])
+			return nil, fmt.Errorf("defmacro expects symbol as first argument, got %s", TypeName(argSlice[0]))
+		}
+
+		// Strip optional docstring and attribute map
+		doc, attrs, forms := splitDocAndAttrs(argSlice[1:])
+		if len(forms) != 2 {
+			return nil, NewArityError("defmacro expects 3 arguments (name params body), got %d", len(argSlice))
 		}
 
 		// Handle both lists and vectors for parameters
 		var params *List
-		switch p := argSlice[1].(type) {
+		switch p := forms[0].(type) {
 		case *List:
 			params = p
 		case *Vector:
@@ -192,33 +208,43 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 			}
 			params = NewList(elements...)
 		default:
-			return nil, fmt.Errorf("defmacro expects list or vector as second argument, got %T", argSlice[1])
+			return nil, fmt.Errorf("defmacro expects list or vector as second argument, got %s", TypeName(forms[0]))
 		}
 
 		macro := &Macro{
 			Name:   sym,
 			Params: params,
-			Body:   argSlice[2],
+			Body:   forms[1],
 			Env:    env,
+			Doc:    doc,
+			Meta:   attrs,
 		}
 
-		env.Set(sym, macro)
+		if err := env.define(sym, macro); err != nil {
+			return nil, err
+		}
 		return sym, nil
 
 	case "defn":
 		argSlice := listToSlice(args)
 		if len(argSlice) < 3 {
-			return nil, fmt.Errorf("defn expects at least 3 arguments (name params body...), got %d", len(argSlice))
+			return nil, NewArityError("defn expects at least 3 arguments (name params body...), got %d", len(argSlice))
 		}
 
 		sym, ok := argSlice[0].(Symbol)
 		if !ok {
-			return nil, fmt.Errorf("defn expects symbol as first argument, got %T", argSlice[0])
+			return nil, fmt.Errorf("defn expects symbol as first argument, got %s", TypeName(argSlice[0]))
+		}
+
+		// Strip optional docstring and attribute map
+		doc, attrs, forms := splitDocAndAttrs(argSlice[1:])
+		if len(forms) < 2 {
+			return nil, NewArityError("defn expects at least 3 arguments (name params body...), got %d", len(argSlice))
 		}
 
 		// Handle both lists and vectors for parameters
 		var params *List
-		switch p := argSlice[1].(type) {
+		switch p := forms[0].(type) {
 		case *List:
 			params = p
 		case *Vector:
@@ -229,29 +255,38 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 			}
 			params = NewList(elements...)
 		default:
-			return nil, fmt.Errorf("defn expects list or vector as second argument, got %T", argSlice[1])
+			return nil, fmt.Errorf("defn expects list or vector as second argument, got %s", TypeName(forms[0]))
 		}
 
 		// Handle multiple body expressions by wrapping in 'do'
 		var body Value
-		if len(argSlice) == 3 {
-			body = argSlice[2]
+		if len(forms) == 2 {
+			body = forms[1]
 		} else {
 			// Multiple body expressions - wrap in do
-			bodyExprs := argSlice[2:]
+			bodyExprs := forms[1:]
 			doList := make([]Value, len(bodyExprs)+1)
 			doList[0] = Symbol("do")
 			copy(doList[1:], bodyExprs)
 			body = NewList(doList...)
 		}
 
+		if err := checkStrictFunction(args, sym, params, body, env); err != nil {
+			return nil, err
+		}
+
 		function := &UserFunction{
 			Params: params,
 			Body:   body,
 			Env:    env,
+			Name:   sym,
+			Doc:    doc,
+			Meta:   attrs,
 		}
 
-		env.Set(sym, function)
+		if err := env.define(sym, function); err != nil {
+			return nil, err
+		}
 		return sym, nil
 
 	case "cond":
@@ -293,6 +328,42 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 		// No condition matched
 		return Nil{}, nil
 
+	case "case":
+		return evalCase(args, env)
+
+	case "for":
+		return evalFor(args, env)
+
+	case "doseq":
+		return evalDoseq(args, env)
+
+	case "dotimes":
+		return evalDotimes(args, env)
+
+	case "while":
+		return evalWhile(args, env)
+
+	case "delay":
+		return evalDelay(args, env)
+
+	case "var":
+		return evalVar(args, env)
+
+	case "dosync":
+		return evalDosync(args, env)
+
+	case "generator":
+		return evalGenerator(args, env)
+
+	case "module":
+		return evalModule(args, env)
+
+	case "import":
+		return evalImport(args, env)
+
+	case "export":
+		return nil, NewRuntimeError("export can only be used at the top level of a module")
+
 	case "and":
 		argSlice := listToSlice(args)
 		if len(argSlice) == 0 {
@@ -352,7 +423,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 	case "loop":
 		argSlice := listToSlice(args)
 		if len(argSlice) < 2 {
-			return nil, fmt.Errorf("loop expects at least 2 arguments (bindings body...)")
+			return nil, NewArityError("loop expects at least 2 arguments (bindings body...), got %d", len(argSlice))
 		}
 
 		// Create new environment for loop bindings
@@ -400,6 +471,10 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 		// Loop execution with recur handling
 		currentValues := initialValues
 		for {
+			if err := checkDeadline(env); err != nil {
+				return nil, err
+			}
+
 			// Bind current values
 			for i, sym := range paramNames {
 				loopEnv.Set(sym, currentValues[i])
@@ -418,7 +493,7 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 				if recurVal, ok := result.(*RecurValue); ok {
 					// Validate recur arity
 					if len(recurVal.Values) != len(paramNames) {
-						return nil, fmt.Errorf("recur expects %d arguments, got %d", len(paramNames), len(recurVal.Values))
+						return nil, NewArityError("recur expects %d arguments, got %d", len(paramNames), len(recurVal.Values))
 					}
 					// Update values for next iteration
 					currentValues = recurVal.Values
@@ -453,24 +528,65 @@ func evalSpecialForm(sym Symbol, args *List, env *Environment) (Value, error) {
 	return nil, fmt.Errorf("unknown special form: %s", sym)
 }
 
+// splitDocAndAttrs strips the optional docstring and attribute map that may
+// follow the name in defn and defmacro forms, returning the remaining forms
+func splitDocAndAttrs(forms []Value) (string, *HashMap, []Value) {
+	var doc string
+	var attrs *HashMap
+
+	// A lone string is a body, not a docstring
+	if len(forms) > 2 {
+		if str, ok := forms[0].(String); ok {
+			doc = string(str)
+			forms = forms[1:]
+		}
+	}
+
+	if len(forms) > 2 {
+		if hm, ok := forms[0].(*HashMap); ok {
+			attrs = hm
+			forms = forms[1:]
+		}
+	}
+
+	return doc, attrs, forms
+}
+
 // isSpecialForm checks if a symbol is a special form
+// specialForms are the names evalSpecialForm handles
+var specialForms = map[Symbol]bool{
+	"quote": true, "quasiquote": true, "if": true, "def": true, "fn": true, "do": true, "let": true,
+	"defmacro": true, "defn": true, "cond": true, "case": true, "and": true, "or": true, "loop": true,
+	"recur": true, "for": true, "doseq": true, "dotimes": true, "while": true, "delay": true,
+	"var": true, "dosync": true, "generator": true, "module": true, "import": true, "export": true,
+}
+
 func isSpecialForm(sym Symbol) bool {
-	switch sym {
-	case "quote", "quasiquote", "if", "def", "fn", "do", "let", "defmacro", "defn", "cond", "and", "or", "loop", "recur":
-		return true
-	default:
-		return false
-	}
+	return specialForms[sym]
 }
 
-// evalQuasiquote handles quasiquote evaluation
+// evalQuasiquote handles quasiquote evaluation. Symbols ending in #, like
+// x#, become the same fresh symbol everywhere in one expansion of the
+// template, so macros can bind names that cannot capture the caller's.
 func evalQuasiquote(expr Value, env *Environment) (Value, error) {
-	return quasiQuoteExpand(expr, env)
+	return quasiQuoteExpand(expr, env, make(map[Symbol]Symbol))
 }
 
-// quasiQuoteExpand recursively expands quasiquoted expressions
-func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
+// quasiQuoteExpand recursively expands quasiquoted expressions, replacing
+// auto-gensym symbols with the ones in gensyms
+func quasiQuoteExpand(expr Value, env *Environment, gensyms map[Symbol]Symbol) (Value, error) {
 	switch v := expr.(type) {
+	case Symbol:
+		if len(v) < 2 || !strings.HasSuffix(string(v), "#") {
+			return v, nil
+		}
+		gensym, ok := gensyms[v]
+		if !ok {
+			gensym = Intern(fmt.Sprintf("%s__%d__auto__", strings.TrimSuffix(string(v), "#"), nextGensym(env)))
+			gensyms[v] = gensym
+		}
+		return gensym, nil
+
 	case *List:
 		if v.IsEmpty() {
 			return v, nil
@@ -482,7 +598,7 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 				rest := v.Rest()
 				args := listToSlice(rest)
 				if len(args) != 1 {
-					return nil, fmt.Errorf("unquote expects 1 argument, got %d", len(args))
+					return nil, NewArityError("unquote expects 1 argument, got %d", len(args))
 				}
 				return Eval(args[0], env)
 			}
@@ -500,7 +616,7 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 					rest := elemList.Rest()
 					args := listToSlice(rest)
 					if len(args) != 1 {
-						return nil, fmt.Errorf("unquote-splicing expects 1 argument, got %d", len(args))
+						return nil, NewArityError("unquote-splicing expects 1 argument, got %d", len(args))
 					}
 
 					// Evaluate the spliced expression
@@ -519,11 +635,11 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 							result = append(result, s.Get(i))
 						}
 					default:
-						return nil, fmt.Errorf("unquote-splicing can only splice sequences, got %T", spliced)
+						return nil, fmt.Errorf("unquote-splicing can only splice sequences, got %s", TypeName(spliced))
 					}
 				} else {
 					// Regular element, expand recursively
-					expanded, err := quasiQuoteExpand(elem, env)
+					expanded, err := quasiQuoteExpand(elem, env, gensyms)
 					if err != nil {
 						return nil, err
 					}
@@ -531,7 +647,7 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 				}
 			} else {
 				// Regular element, expand recursively
-				expanded, err := quasiQuoteExpand(elem, env)
+				expanded, err := quasiQuoteExpand(elem, env, gensyms)
 				if err != nil {
 					return nil, err
 				}
@@ -555,7 +671,7 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 					rest := elemList.Rest()
 					args := listToSlice(rest)
 					if len(args) != 1 {
-						return nil, fmt.Errorf("unquote-splicing expects 1 argument, got %d", len(args))
+						return nil, NewArityError("unquote-splicing expects 1 argument, got %d", len(args))
 					}
 
 					// Evaluate the spliced expression
@@ -574,11 +690,11 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 							result = append(result, s.Get(j))
 						}
 					default:
-						return nil, fmt.Errorf("unquote-splicing can only splice sequences, got %T", spliced)
+						return nil, fmt.Errorf("unquote-splicing can only splice sequences, got %s", TypeName(spliced))
 					}
 				} else {
 					// Regular element, expand recursively
-					expanded, err := quasiQuoteExpand(elem, env)
+					expanded, err := quasiQuoteExpand(elem, env, gensyms)
 					if err != nil {
 						return nil, err
 					}
@@ -586,7 +702,7 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 				}
 			} else {
 				// Regular element, expand recursively
-				expanded, err := quasiQuoteExpand(elem, env)
+				expanded, err := quasiQuoteExpand(elem, env, gensyms)
 				if err != nil {
 					return nil, err
 				}
@@ -605,13 +721,13 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 			value := v.Get(key)
 
 			// Expand key
-			expandedKey, err := quasiQuoteExpand(key, env)
+			expandedKey, err := quasiQuoteExpand(key, env, gensyms)
 			if err != nil {
 				return nil, err
 			}
 
 			// Expand value
-			expandedValue, err := quasiQuoteExpand(value, env)
+			expandedValue, err := quasiQuoteExpand(value, env, gensyms)
 			if err != nil {
 				return nil, err
 			}
@@ -626,3 +742,93 @@ func quasiQuoteExpand(expr Value, env *Environment) (Value, error) {
 		return expr, nil
 	}
 }
+
+// caseTable is the dispatch table of a case form, built once per form
+type caseTable struct {
+	branches    map[string]Value // Constant key to result expression
+	defaultExpr Value            // nil if the form has no default
+}
+
+// caseTables caches tables by the case form's argument list, which the
+// reader never mutates. Keys are weak, and an entry is dropped once its form
+// has been collected, so forms made by eval don't pile up.
+var caseTables sync.Map // weak.Pointer[List] -> *caseTable
+
+// cachedCaseTable returns the table for the case form with the given
+// arguments, building it the first time
+func cachedCaseTable(args *List) (*caseTable, error) {
+	key := weak.Make(args)
+	if cached, ok := caseTables.Load(key); ok {
+		return cached.(*caseTable), nil
+	}
+	table, err := buildCaseTable(listToSlice(args.Rest()))
+	if err != nil {
+		return nil, err
+	}
+	if _, loaded := caseTables.LoadOrStore(key, table); !loaded {
+		runtime.AddCleanup(args, func(key weak.Pointer[List]) { caseTables.Delete(key) }, key)
+	}
+	return table, nil
+}
+
+// evalCase evaluates (case expr key result ... default?) by looking the value
+// of expr up in a hash table of the unevaluated keys. A list key matches any
+// of its elements.
+func evalCase(args *List, env *Environment) (Value, error) {
+	if args.IsEmpty() {
+		return nil, NewArityError("case expects an expression to dispatch on")
+	}
+
+	table, err := cachedCaseTable(args)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := Eval(args.First(), env)
+	if err != nil {
+		return nil, err
+	}
+	if expr, ok := table.branches[caseKey(value)]; ok {
+		return Eval(expr, env)
+	}
+	if table.defaultExpr != nil {
+		return Eval(table.defaultExpr, env)
+	}
+	return nil, NewRuntimeError("case: no matching clause for %s", value)
+}
+
+func buildCaseTable(clauses []Value) (*caseTable, error) {
+	table := &caseTable{branches: make(map[string]Value)}
+	if len(clauses)%2 == 1 {
+		table.defaultExpr = clauses[len(clauses)-1]
+		clauses = clauses[:len(clauses)-1]
+	}
+
+	for i := 0; i < len(clauses); i += 2 {
+		keys := []Value{clauses[i]}
+		if list, ok := clauses[i].(*List); ok && !list.IsEmpty() {
+			keys = listToSlice(list)
+		}
+		for _, key := range keys {
+			// Keys are not evaluated, but nil and false read as symbols
+			if key == Intern("nil") || key == Intern("false") {
+				key = Nil{}
+			}
+			k := caseKey(key)
+			if _, duplicate := table.branches[k]; duplicate {
+				return nil, NewRuntimeError("case: duplicate key %s", key)
+			}
+			table.branches[k] = clauses[i+1]
+		}
+	}
+	return table, nil
+}
+
+// caseKey identifies a value for case dispatch, agreeing with = for the
+// constants case accepts: 1 and 1.0 match, "a" and a symbol a do not
+func caseKey(value Value) string {
+	if num, ok := value.(Number); ok {
+		return fmt.Sprintf("core.Number:%v", num.ToFloat())
+	}
+	return fmt.Sprintf("%s:%s", TypeName(value), value)
+}
@@ -0,0 +1,289 @@
+package core
+
+import (
+	"math"
+	"sort"
+)
+
+// numbersArg converts a collection of numbers to floats, rejecting empty
+// collections since no statistic is defined for them
+func numbersArg(name string, coll Value) ([]float64, error) {
+	items, err := collectionToSlice(coll)
+	if err != nil {
+		return nil, NewTypeError("%s expects a collection of numbers, got %s", name, TypeName(coll))
+	}
+	if len(items) == 0 {
+		return nil, NewRuntimeError("%s of an empty collection", name)
+	}
+	xs := make([]float64, len(items))
+	for i, item := range items {
+		n, ok := item.(Number)
+		if !ok {
+			return nil, NewTypeError("%s expects numbers, got %s", name, printString(item))
+		}
+		xs[i] = n.ToFloat()
+	}
+	return xs, nil
+}
+
+// populationOption reads the optional :population flag of variance and
+// stddev, which divide by n instead of n - 1
+func populationOption(name string, args []Value) (bool, error) {
+	switch {
+	case len(args) == 0:
+		return false, nil
+	case len(args) == 2 && args[0] == Keyword("population"):
+		return isTruthy(args[1]), nil
+	}
+	return false, NewArityError("%s expects :population flag as its only option", name)
+}
+
+func mean(xs []float64) float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// variance returns the sample variance of xs, or the population variance
+func variance(xs []float64, population bool) float64 {
+	if len(xs) < 2 && !population {
+		return 0
+	}
+	m := mean(xs)
+	var sum float64
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	if population {
+		return sum / float64(len(xs))
+	}
+	return sum / float64(len(xs)-1)
+}
+
+// percentile interpolates linearly between the closest ranks of sorted, so
+// the 50th percentile is the median
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+func sortedCopy(xs []float64) []float64 {
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	return sorted
+}
+
+// histogram splits the range of xs into equal-width bins, the last of which
+// includes the maximum. If all values are equal they go in the first bin.
+func histogram(xs []float64, bins int) Value {
+	sorted := sortedCopy(xs)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	width := (hi - lo) / float64(bins)
+	counts := make([]int64, bins)
+	for _, x := range xs {
+		bin := 0
+		if width > 0 {
+			bin = min(int((x-lo)/width), bins-1)
+		}
+		counts[bin]++
+	}
+	result := make([]Value, bins)
+	for i, count := range counts {
+		result[i] = NewHashMapWithPairs(
+			InternKeyword("from"), NewNumber(lo+float64(i)*width),
+			InternKeyword("to"), NewNumber(lo+float64(i+1)*width),
+			InternKeyword("count"), NewNumber(count),
+		)
+	}
+	return NewVector(result...)
+}
+
+// linearRegression fits y = slope * x + intercept by least squares
+func linearRegression(xs, ys []float64) (slope, intercept, r2 float64, ok bool) {
+	mx, my := mean(xs), mean(ys)
+	var sxx, sxy, syy float64
+	for i := range xs {
+		dx, dy := xs[i]-mx, ys[i]-my
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, false
+	}
+	slope = sxy / sxx
+	intercept = my - slope*mx
+	r2 = 1
+	if syy != 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, intercept, r2, true
+}
+
+// setupStatsOperations adds mean, median, mode, variance, stddev,
+// percentile, histogram and linear-regression
+func setupStatsOperations(env *Environment) {
+	env.Set(Intern("mean"), &BuiltinFunction{
+		Name: "mean",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("mean expects 1 argument, got %d", len(args))
+			}
+			xs, err := numbersArg("mean", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(mean(xs)), nil
+		},
+	})
+
+	env.Set(Intern("median"), &BuiltinFunction{
+		Name: "median",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("median expects 1 argument, got %d", len(args))
+			}
+			xs, err := numbersArg("median", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(percentile(sortedCopy(xs), 50)), nil
+		},
+	})
+
+	// The most frequent value, the first seen among equally frequent ones
+	env.Set(Intern("mode"), &BuiltinFunction{
+		Name: "mode",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("mode expects 1 argument, got %d", len(args))
+			}
+			items, err := collectionToSlice(args[0])
+			if err != nil {
+				return nil, NewTypeError("mode expects a collection, got %s", TypeName(args[0]))
+			}
+			if len(items) == 0 {
+				return nil, NewRuntimeError("mode of an empty collection")
+			}
+			counts := make(map[string]int)
+			firsts := make(map[string]Value)
+			var best Value
+			bestCount := 0
+			for _, item := range items {
+				key := item.String()
+				if n, ok := item.(Number); ok {
+					key = NewNumber(n.ToFloat()).String() // 2 and 2.0 count together
+				}
+				if _, seen := firsts[key]; !seen {
+					firsts[key] = item
+				}
+				counts[key]++
+				if counts[key] > bestCount {
+					best, bestCount = firsts[key], counts[key]
+				}
+			}
+			return best, nil
+		},
+	})
+
+	for _, name := range []string{"variance", "stddev"} {
+		name := name
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) < 1 {
+					return nil, NewArityError("%s expects at least 1 argument, got %d", name, len(args))
+				}
+				xs, err := numbersArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				population, err := populationOption(name, args[1:])
+				if err != nil {
+					return nil, err
+				}
+				v := variance(xs, population)
+				if name == "stddev" {
+					v = math.Sqrt(v)
+				}
+				return NewNumber(v), nil
+			},
+		})
+	}
+
+	env.Set(Intern("percentile"), &BuiltinFunction{
+		Name: "percentile",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("percentile expects 2 arguments, got %d", len(args))
+			}
+			xs, err := numbersArg("percentile", args[0])
+			if err != nil {
+				return nil, err
+			}
+			p, ok := args[1].(Number)
+			if !ok || p.ToFloat() < 0 || p.ToFloat() > 100 {
+				return nil, NewTypeError("percentile expects a percentile from 0 to 100, got %s", args[1])
+			}
+			return NewNumber(percentile(sortedCopy(xs), p.ToFloat())), nil
+		},
+	})
+
+	env.Set(Intern("histogram"), &BuiltinFunction{
+		Name: "histogram",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("histogram expects 1 or 2 arguments, got %d", len(args))
+			}
+			xs, err := numbersArg("histogram", args[0])
+			if err != nil {
+				return nil, err
+			}
+			bins := int64(10)
+			if len(args) == 2 {
+				n, ok := args[1].(Number)
+				if !ok || !n.IsInteger() || n.ToInt() < 1 {
+					return nil, NewTypeError("histogram expects a positive number of bins, got %s", args[1])
+				}
+				bins = n.ToInt()
+			}
+			return histogram(xs, int(bins)), nil
+		},
+	})
+
+	// (linear-regression xs ys) fits ys = slope * xs + intercept
+	env.Set(Intern("linear-regression"), &BuiltinFunction{
+		Name: "linear-regression",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("linear-regression expects 2 arguments, got %d", len(args))
+			}
+			xs, err := numbersArg("linear-regression", args[0])
+			if err != nil {
+				return nil, err
+			}
+			ys, err := numbersArg("linear-regression", args[1])
+			if err != nil {
+				return nil, err
+			}
+			if len(xs) != len(ys) {
+				return nil, NewRuntimeError("linear-regression expects as many ys as xs, got %d and %d", len(ys), len(xs))
+			}
+			slope, intercept, r2, ok := linearRegression(xs, ys)
+			if !ok {
+				return nil, NewRuntimeError("linear-regression needs at least two distinct xs")
+			}
+			return NewHashMapWithPairs(
+				InternKeyword("slope"), NewNumber(slope),
+				InternKeyword("intercept"), NewNumber(intercept),
+				InternKeyword("r2"), NewNumber(r2),
+			), nil
+		},
+	})
+}
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"errors"
+	"sync"
+)
+
+// Ref is a reference that can only be changed inside a dosync transaction,
+// so that updates to several refs happen together or not at all
+type Ref struct {
+	mu       sync.Mutex
+	value    Value
+	version  uint64 // Bumped by every commit that writes the ref
+	watchers watchers
+}
+
+func (r *Ref) String() string {
+	return printString(r)
+}
+
+func (r *Ref) watchList() *watchers {
+	return &r.watchers
+}
+
+func (r *Ref) committed() (Value, uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.value, r.version
+}
+
+// transaction is a dosync in progress: the version of each ref when it was
+// first used, and the values written so far. It is carried by the call
+// frames of its body, so futures and generators started there join it while
+// it runs; once it has finished, code holding on to those frames runs
+// outside any transaction.
+type transaction struct {
+	mu       sync.Mutex
+	versions map[*Ref]uint64
+	writes   map[*Ref]Value
+	order    []*Ref // Written refs in order, for notifying watches
+	conflict bool   // Set when a ref changed under the transaction
+	finished bool   // Set once the body has returned
+}
+
+// errRetry aborts a transaction that saw a conflicting commit. Errors are
+// wrapped on their way out of Eval, so dosync checks tx.conflict instead.
+var errRetry = errors.New("transaction conflict, retrying")
+
+// maxTransactionRetries bounds dosync under heavy contention
+const maxTransactionRetries = 10000
+
+// commitLock serializes validating and applying transactions
+var commitLock sync.Mutex
+
+func newTransaction() *transaction {
+	return &transaction{versions: make(map[*Ref]uint64), writes: make(map[*Ref]Value)}
+}
+
+// currentTransaction returns the transaction env runs in, if it is still
+// running
+func currentTransaction(env *Environment) *transaction {
+	if env == nil || env.calls == nil || env.calls.transaction == nil {
+		return nil
+	}
+	tx := env.calls.transaction
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.finished {
+		return nil
+	}
+	return tx
+}
+
+// withTransaction returns a child of env whose calls run in tx
+func withTransaction(env *Environment, tx *transaction) *Environment {
+	frame := nestedFrame(env, "dosync")
+	frame.transaction = tx
+	inside := NewEnvironment(env)
+	inside.calls = frame
+	return inside
+}
+
+// finish ends the transaction's body, reporting whether a ref changed under
+// it
+func (tx *transaction) finish() (conflict bool) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.finished = true
+	return tx.conflict
+}
+
+// read returns the ref's value as seen by the transaction
+func (tx *transaction) read(r *Ref) (Value, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.readLocked(r)
+}
+
+func (tx *transaction) readLocked(r *Ref) (Value, error) {
+	if value, ok := tx.writes[r]; ok {
+		return value, nil
+	}
+	value, version := r.committed()
+	if seen, ok := tx.versions[r]; ok && seen != version {
+		tx.conflict = true // Changed since we first looked
+		return nil, errRetry
+	}
+	tx.versions[r] = version
+	return value, nil
+}
+
+func (tx *transaction) write(r *Ref, value Value) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if _, err := tx.readLocked(r); err != nil {
+		return err
+	}
+	if _, ok := tx.writes[r]; !ok {
+		tx.order = append(tx.order, r)
+	}
+	tx.writes[r] = value
+	return nil
+}
+
+// commit applies the writes if no ref used by the transaction has changed,
+// returning the values they replaced
+func (tx *transaction) commit() ([]Value, bool) {
+	commitLock.Lock()
+	defer commitLock.Unlock()
+	for r, seen := range tx.versions {
+		if _, version := r.committed(); version != seen {
+			return nil, false
+		}
+	}
+	old := make([]Value, len(tx.order))
+	for i, r := range tx.order {
+		r.mu.Lock()
+		old[i] = r.value
+		r.value = tx.writes[r]
+		r.version++
+		r.mu.Unlock()
+	}
+	return old, true
+}
+
+// evalDosync implements (dosync body...), running body as a transaction and
+// retrying it from the start if another transaction commits a ref it used,
+// so body should be free of side effects. A nested dosync joins the
+// enclosing transaction.
+func evalDosync(args *List, env *Environment) (Value, error) {
+	body := listToSlice(args)
+	run := func(env *Environment) (Value, error) {
+		var result Value = Nil{}
+		for _, expr := range body {
+			var err error
+			if result, err = Eval(expr, env); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	if currentTransaction(env) != nil {
+		return run(env)
+	}
+
+	for attempt := 0; attempt < maxTransactionRetries; attempt++ {
+		tx := newTransaction()
+		result, err := run(withTransaction(env, tx))
+		if tx.finish() {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		old, ok := tx.commit()
+		if !ok {
+			continue
+		}
+
+		// Watches run after the commit, outside the transaction
+		for i, r := range tx.order {
+			if err := r.watchers.notify(r, old[i], tx.writes[r], env); err != nil {
+				return nil, err
+			}
+		}
+		return result, nil
+	}
+	return nil, NewRuntimeError("dosync: transaction retried %d times without committing", maxTransactionRetries)
+}
+
+// transactionArg returns the transaction env runs in and the ref argument
+// of a function that may only be used inside dosync
+func transactionArg(fnName string, arg Value, env *Environment) (*transaction, *Ref, error) {
+	r, ok := arg.(*Ref)
+	if !ok {
+		return nil, nil, NewTypeError("%s expects a ref, got %s", fnName, TypeName(arg))
+	}
+	tx := currentTransaction(env)
+	if tx == nil {
+		return nil, nil, NewRuntimeError("%s called outside of dosync", fnName)
+	}
+	return tx, r, nil
+}
+
+// setupSTMOperations adds ref, alter and ref-set
+func setupSTMOperations(env *Environment) {
+	env.Set(Intern("ref"), &BuiltinFunction{
+		Name: "ref",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("ref expects 1 argument, got %d", len(args))
+			}
+			return &Ref{value: args[0]}, nil
+		},
+	})
+
+	env.Set(Intern("alter"), &BuiltinFunction{
+		Name: "alter",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("alter expects at least 2 arguments, got %d", len(args))
+			}
+			tx, r, err := transactionArg("alter", args[0], env)
+			if err != nil {
+				return nil, err
+			}
+			current, err := tx.read(r)
+			if err != nil {
+				return nil, err
+			}
+			value, err := callFunction(args[1], append([]Value{current}, args[2:]...), env)
+			if err != nil {
+				return nil, err
+			}
+			return value, tx.write(r, value)
+		},
+	})
+
+	env.Set(Intern("ref-set"), &BuiltinFunction{
+		Name: "ref-set",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("ref-set expects 2 arguments, got %d", len(args))
+			}
+			tx, r, err := transactionArg("ref-set", args[0], env)
+			if err != nil {
+				return nil, err
+			}
+			return args[1], tx.write(r, args[1])
+		},
+	})
+}
+
+// derefRef reads a ref inside the transaction env runs in, if any
+func derefRef(r *Ref, env *Environment) (Value, error) {
+	if tx := currentTransaction(env); tx != nil {
+		return tx.read(r)
+	}
+	value, _ := r.committed()
+	return value, nil
+}
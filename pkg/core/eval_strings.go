@@ -3,32 +3,133 @@ package core
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
+// StringBuilder is a mutable string buffer, for building a string in a loop
+// without copying it on every step
+type StringBuilder struct {
+	mu      sync.Mutex
+	builder strings.Builder
+}
+
+func (sb *StringBuilder) String() string {
+	return "#<string-builder>"
+}
+
+// Append adds the str text of each of values, as printed in env, to the
+// buffer, failing if a lazy sequence among them can't be realized
+func (sb *StringBuilder) Append(env *Environment, values ...Value) error {
+	var text strings.Builder
+	if err := writeStr(&text, values, env); err != nil {
+		return err
+	}
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	sb.builder.WriteString(text.String())
+	return nil
+}
+
+// Text returns the contents of the buffer
+func (sb *StringBuilder) Text() string {
+	sb.mu.Lock()
+	defer sb.mu.Unlock()
+	return sb.builder.String()
+}
+
 // setupStringOperations adds string operations and predicates to the environment
 func setupStringOperations(env *Environment) {
 	// String operations
 	env.Set(Intern("str"), &BuiltinFunction{
 		Name: "str",
 		Fn: func(args []Value, env *Environment) (Value, error) {
-			result := ""
-			for _, arg := range args {
-				switch v := arg.(type) {
-				case String:
-					result += string(v)
-				case Symbol:
-					result += string(v)
-				case Keyword:
-					result += v.String()
-				case Number:
-					result += v.String()
-				case Nil:
-					result += ""
-				default:
-					result += arg.String()
+			var result strings.Builder
+			if err := writeStr(&result, args, env); err != nil {
+				return nil, err
+			}
+			return String(result.String()), nil
+		},
+	})
+
+	env.Set(Intern("string-join"), &BuiltinFunction{
+		Name: "string-join",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("string-join expects 1-2 arguments (sep coll), got %d", len(args))
+			}
+			sep := ""
+			if len(args) == 2 {
+				var err error
+				if sep, err = strText(args[0], env); err != nil {
+					return nil, err
 				}
 			}
-			return String(result), nil
+			items, err := collectionToSlice(args[len(args)-1])
+			if err != nil {
+				return nil, NewTypeError("string-join expects a collection, got %s", TypeName(args[len(args)-1]))
+			}
+			size := len(sep) * len(items)
+			for _, item := range items {
+				if s, ok := item.(String); ok {
+					size += len(s)
+				}
+			}
+			var result strings.Builder
+			result.Grow(size)
+			for i, item := range items {
+				if i > 0 {
+					result.WriteString(sep)
+				}
+				text, err := strText(item, env)
+				if err != nil {
+					return nil, err
+				}
+				result.WriteString(text)
+			}
+			return String(result.String()), nil
+		},
+	})
+
+	env.Set(Intern("string-builder"), &BuiltinFunction{
+		Name: "string-builder",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			sb := &StringBuilder{}
+			if err := sb.Append(env, args...); err != nil {
+				return nil, err
+			}
+			return sb, nil
+		},
+	})
+
+	env.Set(Intern("sb-append!"), &BuiltinFunction{
+		Name: "sb-append!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("sb-append! expects at least 1 argument, got %d", len(args))
+			}
+			sb, ok := args[0].(*StringBuilder)
+			if !ok {
+				return nil, NewTypeError("sb-append! expects a string-builder, got %s", TypeName(args[0]))
+			}
+			if err := sb.Append(env, args[1:]...); err != nil {
+				return nil, err
+			}
+			return sb, nil
+		},
+	})
+
+	env.Set(Intern("sb-str"), &BuiltinFunction{
+		Name: "sb-str",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("sb-str expects 1 argument, got %d", len(args))
+			}
+			sb, ok := args[0].(*StringBuilder)
+			if !ok {
+				return nil, NewTypeError("sb-str expects a string-builder, got %s", TypeName(args[0]))
+			}
+			return String(sb.Text()), nil
 		},
 	})
 
@@ -36,7 +137,7 @@ func setupStringOperations(env *Environment) {
 		Name: "substring",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) < 2 || len(args) > 3 {
-				return nil, fmt.Errorf("substring expects 2-3 arguments")
+				return nil, NewArityError("substring expects 2-3 arguments, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
@@ -49,7 +150,7 @@ func setupStringOperations(env *Environment) {
 				return nil, fmt.Errorf("substring expects number as second argument")
 			}
 
-			s := string(str)
+			s := []rune(string(str))
 			startIdx := int(start.ToInt())
 
 			if startIdx < 0 || startIdx > len(s) {
@@ -57,7 +158,7 @@ func setupStringOperations(env *Environment) {
 			}
 
 			if len(args) == 2 {
-				return String(s[startIdx:]), nil
+				return String(string(s[startIdx:])), nil
 			}
 
 			end, ok := args[2].(Number)
@@ -70,7 +171,7 @@ func setupStringOperations(env *Environment) {
 				endIdx = len(s)
 			}
 
-			return String(s[startIdx:endIdx]), nil
+			return String(string(s[startIdx:endIdx])), nil
 		},
 	})
 
@@ -78,7 +179,7 @@ func setupStringOperations(env *Environment) {
 		Name: "string-split",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("string-split expects 2 arguments")
+				return nil, NewArityError("string-split expects 2 arguments, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
@@ -105,7 +206,7 @@ func setupStringOperations(env *Environment) {
 		Name: "string-replace",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 3 {
-				return nil, fmt.Errorf("string-replace expects 3 arguments")
+				return nil, NewArityError("string-replace expects 3 arguments, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
@@ -132,7 +233,7 @@ func setupStringOperations(env *Environment) {
 		Name: "string-contains?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 2 {
-				return nil, fmt.Errorf("string-contains? expects 2 arguments")
+				return nil, NewArityError("string-contains? expects 2 arguments, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
@@ -156,7 +257,7 @@ func setupStringOperations(env *Environment) {
 		Name: "string-trim",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("string-trim expects 1 argument")
+				return nil, NewArityError("string-trim expects 1 argument, got %d", len(args))
 			}
 
 			str, ok := args[0].(String)
@@ -169,12 +270,29 @@ func setupStringOperations(env *Environment) {
 		},
 	})
 
+	for name, convert := range map[string]func(string) string{
+		"upper-case": strings.ToUpper,
+		"lower-case": strings.ToLower,
+	} {
+		name, convert := name, convert
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				s, err := stringArg(name, args)
+				if err != nil {
+					return nil, err
+				}
+				return String(convert(s)), nil
+			},
+		})
+	}
+
 	// String predicate
 	env.Set(Intern("string?"), &BuiltinFunction{
 		Name: "string?",
 		Fn: func(args []Value, env *Environment) (Value, error) {
 			if len(args) != 1 {
-				return nil, fmt.Errorf("string? expects 1 argument")
+				return nil, NewArityError("string? expects 1 argument, got %d", len(args))
 			}
 
 			if _, ok := args[0].(String); ok {
@@ -183,4 +301,117 @@ func setupStringOperations(env *Environment) {
 			return Nil{}, nil
 		},
 	})
+
+	// Unicode-aware operations
+	env.Set(Intern("string-length"), &BuiltinFunction{
+		Name: "string-length",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			s, err := stringArg("string-length", args)
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(utf8.RuneCountInString(s))), nil
+		},
+	})
+
+	env.Set(Intern("graphemes"), &BuiltinFunction{
+		Name: "graphemes",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			s, err := stringArg("graphemes", args)
+			if err != nil {
+				return nil, err
+			}
+			clusters := graphemes(s)
+			result := make([]Value, len(clusters))
+			for i, cluster := range clusters {
+				result[i] = String(cluster)
+			}
+			return NewList(result...), nil
+		},
+	})
+
+	env.Set(Intern("string-reverse"), &BuiltinFunction{
+		Name: "string-reverse",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			s, err := stringArg("string-reverse", args)
+			if err != nil {
+				return nil, err
+			}
+			return String(reverseGraphemes(s)), nil
+		},
+	})
+
+	env.Set(Intern("string-normalize"), &BuiltinFunction{
+		Name: "string-normalize",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, NewArityError("string-normalize expects 1-2 arguments, got %d", len(args))
+			}
+			s, err := stringArg("string-normalize", args[:1])
+			if err != nil {
+				return nil, err
+			}
+			form := Keyword("nfc")
+			if len(args) == 2 {
+				keyword, ok := args[1].(Keyword)
+				if !ok {
+					return nil, NewTypeError("string-normalize expects :nfc or :nfd, got %s", args[1])
+				}
+				form = keyword
+			}
+			normalized, err := normalizeString(s, form)
+			if err != nil {
+				return nil, err
+			}
+			return String(normalized), nil
+		},
+	})
+}
+
+// strText returns the text str gives value in env: strings and symbols
+// without quotes, nil as nothing and anything else as it prints, with floats
+// to *print-precision* digits
+func strText(value Value, env *Environment) (string, error) {
+	switch v := value.(type) {
+	case String:
+		return string(v), nil
+	case Symbol:
+		return string(v), nil
+	case Nil:
+		return "", nil
+	default:
+		return precisePrintString(value, env)
+	}
+}
+
+// writeStr writes the str text of values in env to b, growing it once up
+// front by the length of the strings among them
+func writeStr(b *strings.Builder, values []Value, env *Environment) error {
+	size := 0
+	for _, value := range values {
+		if s, ok := value.(String); ok {
+			size += len(s)
+		}
+	}
+	b.Grow(size)
+	for _, value := range values {
+		text, err := strText(value, env)
+		if err != nil {
+			return err
+		}
+		b.WriteString(text)
+	}
+	return nil
+}
+
+// stringArg returns the only argument of name, which must be a string
+func stringArg(name string, args []Value) (string, error) {
+	if len(args) != 1 {
+		return "", NewArityError("%s expects 1 argument, got %d", name, len(args))
+	}
+	s, ok := args[0].(String)
+	if !ok {
+		return "", NewTypeError("%s expects a string, got %s", name, TypeName(args[0]))
+	}
+	return string(s), nil
 }
@@ -58,10 +58,10 @@ func TestEvalArithmetic(t *testing.T) {
 		{"(* 2 3)", "6"},
 		{"(* 2 3 4)", "24"},
 		{"(*)", "1"},
-		{"(/ 6 2)", "3"},
+		{"(/ 6 2)", "3.0"},
 		{"(/ 10 2 2)", "2.5"},
-		{"(+ 1.5 2.5)", "4"},
-		{"(* 2.5 4)", "10"},
+		{"(+ 1.5 2.5)", "4.0"},
+		{"(* 2.5 4)", "10.0"},
 	}
 
 	for _, test := range tests {
@@ -100,6 +100,13 @@ func TestEvalComparison(t *testing.T) {
 		{"(> 1 2)", "nil"},
 		{"(= \"hello\" \"hello\")", "true"},
 		{"(= \"hello\" \"world\")", "nil"},
+		{"(= [1 [2 3]] [1 [2 3]])", "true"},
+		{"(= [1 2] '(1 2))", "true"},
+		{"(= [1 2] [1 2 3])", "nil"},
+		{"(= {:a 1 :b [2]} {:b [2] :a 1})", "true"},
+		{"(= {:a 1} {:a 2})", "nil"},
+		{"(= #{1 2} #{2 1})", "true"},
+		{"(= [1] #{1})", "nil"},
 	}
 
 	for _, test := range tests {
@@ -457,6 +464,54 @@ func TestEvalMetaProgramming(t *testing.T) {
 	}
 }
 
+func TestGensymSeed(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	for _, macro := range []string{
+		"(defmacro swap-args [f a b] `(let [x# ~a] (~f ~b x#)))",
+		"(defmacro quoted-gensym [] (list 'quote (gensym)))",
+	} {
+		expr, _ := core.ReadString(macro)
+		if _, err := core.Eval(expr, env); err != nil {
+			t.Fatalf("Error defining macro: %v", err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(with-gensym-seed 1 (macroexpand '(swap-args - 1 2)))", "(let [x__1__auto__ 1] (- 2 x__1__auto__))"},
+		{"(with-gensym-seed 1 (macroexpand '(swap-args - 1 2)))", "(let [x__1__auto__ 1] (- 2 x__1__auto__))"},
+		{"(with-gensym-seed 10 (list (gensym) (gensym \"p\")))", "(G__10 p11)"},
+		// Macros expanded while the body runs count on the same counter
+		{"(with-gensym-seed 5 (list (gensym) (quoted-gensym)))", "(G__5 G__6)"},
+		{"(do (reset-gensym!) (gensym))", "G__1"},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", test.input, err)
+		}
+		if result.String() != test.expected {
+			t.Errorf("Expected %s for '%s', got %s", test.expected, test.input, result)
+		}
+	}
+
+	// Seeded counters leave the global one alone
+	env.ResetGensym()
+	expr, _ := core.ReadString("(do (with-gensym-seed 100 (gensym)) (gensym))")
+	if result, err := core.Eval(expr, env); err != nil || result.String() != "G__1" {
+		t.Errorf("Expected G__1 after a seeded gensym, got %v (%v)", result, err)
+	}
+}
+
 func TestEvalMacroExpand(t *testing.T) {
 	env := core.NewCoreEnvironment()
 
@@ -489,6 +544,83 @@ func TestEvalMacroExpand(t *testing.T) {
 	}
 }
 
+func TestMacroFormAndEnv(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	eval := func(input string) string {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for %q: %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for %q: %v", input, err)
+		}
+		return result.String()
+	}
+
+	eval("(defmacro call-name [& args] (list 'quote (first &form)))")
+	eval("(defmacro call-line [] (get (form-position &form) :line))")
+	eval("(defmacro local? [sym] (contains? &env sym))")
+	eval("(defmacro local-value [sym] (get &env sym))")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(call-name 1 2)", "call-name"},
+		{"\n\n  (call-line)", "3"},
+		{"(let [x 1] (local? x))", "true"},
+		{"(local? x)", "nil"},
+		{"(local? +)", "nil"},
+		{"((fn [y] (local? y)) 5)", "true"},
+		{"(let [x 1] (let [x 2] (local-value x)))", "2"},
+		{"(macroexpand '(call-name))", "(quote call-name)"},
+		{"(form-position 'x)", "nil"},
+	}
+	for _, test := range tests {
+		if got := eval(test.input); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestTypeIntrospection(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(type 1)", ":integer"},
+		{"(type 1.5)", ":float"},
+		{`(type "s")`, ":string"},
+		{"(type (list 1 [2]))", ":list"},
+		{"(type {:a 1})", ":hash-map"},
+		{"(type +)", ":function"},
+		{"(type (fn [x] x))", ":function"},
+		{"(type nil)", ":nil"},
+		{"(type (atom 1))", ":atom"},
+		{"(instance? :vector [1])", "true"},
+		{"(instance? :vector (list 1))", "nil"},
+		{"(instance? :number 2.5)", "true"},
+		{"(instance? :sequential (list 1))", "true"},
+		{"(instance? :collection #{1})", "true"},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for %q: %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for %q: %v", test.input, err)
+		}
+		if result.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+}
+
 func TestEvalVariadicFunctions(t *testing.T) {
 	env := core.NewCoreEnvironment()
 
@@ -614,6 +746,49 @@ func TestEvalErrors(t *testing.T) {
 	}
 }
 
+func TestArityErrors(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	for _, def := range []string{
+		"(defn add [a b] (+ a b))",
+		"(defn log-all [level & msgs] msgs)",
+		"(defmacro unless2 [test body] (list 'if test nil body))",
+	} {
+		expr, _ := core.ReadString(def)
+		if _, err := core.Eval(expr, env); err != nil {
+			t.Fatalf("Failed to evaluate '%s': %v", def, err)
+		}
+	}
+
+	tests := []struct {
+		input    string
+		contains []string
+	}{
+		{"(add 1 2 3)", []string{"ArityError: add expects 2 arguments, got 3 (arglists: [a b])", "line 1, column 1"}},
+		{"(log-all)", []string{"log-all expects at least 1 arguments, got 0 (arglists: [level & msgs])"}},
+		{"((fn [x] x))", []string{"anonymous function expects 1 arguments, got 0 (arglists: [x])"}},
+		{"(unless2 true)", []string{"macro unless2 expects 2 arguments, got 1"}},
+		{"(+ 1 (count 1 2))", []string{"ArityError: count expects 1 argument, got 2", "column 6"}},
+		{"(cons 1)", []string{"ArityError: cons expects 2 arguments, got 1"}},
+		{"(= 1)", []string{"ArityError: = expects at least 2 arguments, got 1"}},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		_, err = core.Eval(expr, env)
+		if err == nil {
+			t.Errorf("Expected an error for '%s'", test.input)
+			continue
+		}
+		for _, substr := range test.contains {
+			if !strings.Contains(err.Error(), substr) {
+				t.Errorf("Expected error for '%s' to contain '%s', got: %v", test.input, substr, err)
+			}
+		}
+	}
+}
+
 func TestUserFunctionInterface(t *testing.T) {
 	env := core.NewCoreEnvironment()
 
@@ -1022,9 +1197,18 @@ func TestHashMapOperations(t *testing.T) {
 		{"(contains? {:name \"Alice\"} :name)", "true"},
 		{"(contains? {:name \"Alice\"} :age)", "nil"},
 
+		// Test sorted-map keeps keys sorted through assoc and dissoc
+		{"(sorted-map :c 1 :a 2 :b 3)", "{:a 2 :b 3 :c 1}"},
+		{"(assoc (sorted-map 10 :x 2 :y) 5 :z)", "{2 :y 5 :z 10 :x}"},
+		{"(dissoc (sorted-map :b 1 :a 2 :c 3) :b)", "{:a 2 :c 3}"},
+		{"(hash-map-put (sorted-map :b 1) :a 2)", "{:a 2 :b 1}"},
+		{"(sorted? (sorted-map :a 1))", "true"},
+		{"(sorted? {:a 1})", "nil"},
+
 		// Test hash-map? predicate
 		{"(hash-map? {})", "true"},
 		{"(hash-map? {:a 1})", "true"},
+		{"(hash-map? (sorted-map))", "true"},
 		{"(hash-map? [])", "nil"},
 		{"(hash-map? \"test\")", "nil"},
 
@@ -1176,6 +1360,68 @@ func TestKeywordAsFunction(t *testing.T) {
 	}
 }
 
+func TestCollectionsAsFunctions(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Vectors are functions of their indices
+		{"([10 20 30] 1)", "20"},
+		{"([10 20 30] 0)", "10"},
+
+		// Hash-maps are functions of their keys, with an optional default
+		{"({:a 1} :a)", "1"},
+		{"({:a 1} :b)", "nil"},
+		{"({:a 1} :b 0)", "0"},
+		{"({:a nil} :a 0)", "nil"},
+		{"({\"k\" 1} \"k\")", "1"},
+
+		// Sets are functions of their elements
+		{"(#{1 2} 1)", "1"},
+		{"(#{1 2} 3)", "nil"},
+		{"(#{:a :b} :b)", ":b"},
+
+		// Collections work wherever functions are expected
+		{"((comp #{2 3} first) (list 2 9))", "2"},
+		{"(apply {:a 1} (list :a))", "1"},
+		{"(ifn? [1])", "true"},
+		{"(fn? {:a 1})", "nil"},
+	}
+
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Errorf("Parse error for '%s': %v", test.input, err)
+			continue
+		}
+
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+
+		if result.String() != test.expected {
+			t.Errorf("Expected '%s' for input '%s', got '%s'", test.expected, test.input, result.String())
+		}
+	}
+
+	for input, errorMatch := range map[string]string{
+		"([10 20] 2)":     "out of bounds",
+		"([10 20] :a)":    "vector index must be an integer",
+		"([10 20])":       "vector expects 1 argument",
+		"({:a 1} :a 1 2)": "hash-map expects 1-2 arguments",
+		"(#{1} 1 2)":      "set expects 1 argument",
+	} {
+		expr, _ := core.ReadString(input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
 func TestReadAllString(t *testing.T) {
 	env := core.NewCoreEnvironment()
 
@@ -1814,3 +2060,127 @@ func TestNewFunctionErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestEvalCase(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	setup := `(defn describe [x] (case x 1 "one" 2 "two" (3 4) "few" :k "keyword" "s" "string" nil "nothing" "many"))`
+	expr, _ := core.ReadString(setup)
+	if _, err := core.Eval(expr, env); err != nil {
+		t.Fatalf("Eval error for setup: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(describe 1)", `"one"`},
+		{"(describe 2)", `"two"`},
+		{"(describe 3)", `"few"`},
+		{"(describe 4)", `"few"`},
+		{"(describe 1.0)", `"one"`},
+		{"(describe :k)", `"keyword"`},
+		{`(describe "s")`, `"string"`},
+		{"(describe 's)", `"many"`},
+		{"(describe nil)", `"nothing"`},
+		{"(describe 99)", `"many"`},
+		{"(case 'b a 1 b 2)", "2"},
+		{"(case (+ 1 1) 2 (str \"t\" \"wo\"))", `"two"`},
+	}
+
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result.String())
+		}
+	}
+
+	errorTests := []struct {
+		input      string
+		errorMatch string
+	}{
+		{"(case 5 1 2)", "no matching clause for 5"},
+		{"(case 1 1 :a (1 2) :b)", "duplicate key 1"},
+		{"(case)", "case expects an expression"},
+	}
+	for _, test := range errorTests {
+		expr, _ := core.ReadString(test.input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), test.errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", test.errorMatch, test.input, err)
+		}
+	}
+}
+
+func TestEvalIterationForms(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	var recorded []string
+	env.Set(core.Intern("record"), &core.BuiltinFunction{
+		Name: "record",
+		Fn: func(args []core.Value, env *core.Environment) (core.Value, error) {
+			recorded = append(recorded, args[0].String())
+			return core.Nil{}, nil
+		},
+	})
+
+	tests := []struct {
+		input    string
+		expected string
+		recorded string
+	}{
+		{"(for [x [1 2 3]] (* x x))", "(1 4 9)", ""},
+		{"(for [x [1 2] y (list :a :b)] (vector x y))", "([1 :a] [1 :b] [2 :a] [2 :b])", ""},
+		{"(for [x [1 2 3 4] :let [y (* x 10)] :when (> y 20)] y)", "(30 40)", ""},
+		{"(for [x [] y [1 2]] y)", "()", ""},
+		{"(count (for [x [1 2] y [3 4 5]] x))", "6", ""},
+		{"(first (for [x [1 2 3]] (do (record x) x)))", "1", "1"},
+		{"(nth (for [x [1 2 3]] (* 2 x)) 2)", "6", ""},
+		{"(doseq [x [1 2 3 4] :when (> x 2)] (record x))", "nil", "3 4"},
+		{"(doseq [x [1 2] :let [y (* x x)]] (record y))", "nil", "1 4"},
+		{"(dotimes [i 3] (record i))", "nil", "0 1 2"},
+		{"(dotimes [i 0] (record i))", "nil", ""},
+		{"(do (def n 0) (while (< n 3) (record n) (def n (+ n 1))))", "nil", "0 1 2"},
+	}
+
+	for _, test := range tests {
+		recorded = nil
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result.String())
+		}
+		if got := strings.Join(recorded, " "); got != test.recorded {
+			t.Errorf("For '%s', expected side effects %q, got %q", test.input, test.recorded, got)
+		}
+	}
+
+	errorTests := []struct {
+		input      string
+		errorMatch string
+	}{
+		{"(for [x] x)", "even number of forms"},
+		{"(for [:when true x [1]] x)", "binding before any modifier"},
+		{"(doseq [x [1] :until true] x)", "unsupported modifier"},
+		{"(dotimes [i :a] i)", "dotimes expects a number"},
+		{"(count (for [x 5] x))", "expected collection"},
+	}
+	for _, test := range errorTests {
+		expr, _ := core.ReadString(test.input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), test.errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", test.errorMatch, test.input, err)
+		}
+	}
+}
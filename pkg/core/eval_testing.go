@@ -0,0 +1,381 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// registeredTest is a test defined with deftest
+type registeredTest struct {
+	name Symbol
+	fn   Value
+}
+
+// testRegistry holds the tests of a root environment and the state of the
+// run in progress
+type testRegistry struct {
+	tests   []registeredTest
+	current *testRun
+	options TestOptions
+}
+
+// testRun collects the assertions made by the running test
+type testRun struct {
+	name     Symbol
+	out      io.Writer
+	pass     int
+	failures int
+}
+
+// TestOptions controls RunTests
+type TestOptions struct {
+	GoldenDir string    // Where is-golden reads and writes NAME.golden files
+	Update    bool      // Rewrite golden files instead of comparing
+	Out       io.Writer // Failure reports and the summary
+}
+
+// TestSummary counts the outcome of RunTests
+type TestSummary struct {
+	Tests int
+	Pass  int
+	Fail  int
+	Error int
+}
+
+// DefaultGoldenDir is used by is-golden outside of golisp test
+const DefaultGoldenDir = "testdata/golden"
+
+// testRegistry returns the registry of the root environment
+func (env *Environment) testRegistry() *testRegistry {
+	root := env.root()
+	if root.tests == nil {
+		root.tests = &testRegistry{options: TestOptions{GoldenDir: DefaultGoldenDir, Out: os.Stdout}}
+	}
+	return root.tests
+}
+
+// setupTestingOperations adds the primitives behind deftest, is and is-golden
+func setupTestingOperations(env *Environment) {
+	env.Set(Intern("register-test"), &BuiltinFunction{
+		Name: "register-test",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("register-test expects 2 arguments, got %d", len(args))
+			}
+			name, ok := args[0].(Symbol)
+			if !ok {
+				return nil, NewTypeError("register-test expects a symbol name, got %s", TypeName(args[0]))
+			}
+			if _, ok := args[1].(Callable); !ok {
+				return nil, NewTypeError("register-test expects a function, got %s", TypeName(args[1]))
+			}
+
+			// Re-registering a name (e.g. when reloading a file) replaces the test
+			registry := env.testRegistry()
+			for i, test := range registry.tests {
+				if test.name == name {
+					registry.tests[i].fn = args[1]
+					return name, nil
+				}
+			}
+			registry.tests = append(registry.tests, registeredTest{name: name, fn: args[1]})
+			return name, nil
+		},
+	})
+
+	env.Set(Intern("report-assertion"), &BuiltinFunction{
+		Name: "report-assertion",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("report-assertion expects 3 arguments, got %d", len(args))
+			}
+			form, result, msg := args[0], args[1], args[2]
+			if isTruthy(result) {
+				return recordPass(env), nil
+			}
+
+			var report strings.Builder
+			if str, ok := msg.(String); ok {
+				report.WriteString(string(str) + "\n")
+			}
+			report.WriteString(fmt.Sprintf("expected: %s\n  actual: %s", form, result))
+			return recordFailure(env, report.String())
+		},
+	})
+
+	// (is (= expected actual)) reports both values and how they differ
+	env.Set(Intern("report-equality"), &BuiltinFunction{
+		Name: "report-equality",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 4 {
+				return nil, NewArityError("report-equality expects 4 arguments, got %d", len(args))
+			}
+			form, expected, actual, msg := args[0], args[1], args[2], args[3]
+			if valuesEqual(expected, actual) {
+				return recordPass(env), nil
+			}
+
+			var report strings.Builder
+			if str, ok := msg.(String); ok {
+				report.WriteString(string(str) + "\n")
+			}
+			report.WriteString(fmt.Sprintf("expected: %s\n  actual: (not (= %s %s))", form, printString(expected), printString(actual)))
+			if mismatch := describeMismatch(expected, actual); mismatch != "" {
+				report.WriteString("\n" + strings.TrimSuffix(mismatch, "\n"))
+			}
+			return recordFailure(env, report.String())
+		},
+	})
+
+	env.Set(Intern("is-golden"), &BuiltinFunction{
+		Name: "is-golden",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("is-golden expects 2 arguments, got %d", len(args))
+			}
+			name, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("is-golden expects a string name, got %s", TypeName(args[0]))
+			}
+			return checkGolden(string(name), args[1], env)
+		},
+	})
+
+	guardBuiltins(env, []Capability{CapabilityFS}, "is-golden")
+
+	// Number of random cases each defprop runs, and an optional fixed seed
+	env.Set(Intern("*property-runs*"), NewNumber(int64(100)))
+	env.Set(Intern("*property-seed*"), Nil{})
+
+	env.Set(Intern("check-property"), &BuiltinFunction{
+		Name: "check-property",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 4 {
+				return nil, NewArityError("check-property expects 4 arguments, got %d", len(args))
+			}
+			names, err := collectionToSlice(args[1])
+			if err != nil {
+				return nil, NewTypeError("check-property expects a vector of names, got %s", TypeName(args[1]))
+			}
+			genValues, err := collectionToSlice(args[2])
+			if err != nil || len(genValues) != len(names) {
+				return nil, NewTypeError("check-property expects one generator per name")
+			}
+			gens := make([]*Generator, len(genValues))
+			for i, value := range genValues {
+				if gens[i], err = generatorArg("check-property", value); err != nil {
+					return nil, err
+				}
+			}
+			if _, ok := args[3].(Callable); !ok {
+				return nil, NewTypeError("check-property expects a function, got %s", TypeName(args[3]))
+			}
+			return checkProperty(args[0], names, gens, args[3], env)
+		},
+	})
+
+	env.Set(Intern("run-tests"), &BuiltinFunction{
+		Name: "run-tests",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("run-tests expects 0 arguments, got %d", len(args))
+			}
+			summary := RunTests(env, TestOptions{GoldenDir: DefaultGoldenDir, Out: os.Stdout})
+			return NewHashMapWithPairs(
+				InternKeyword("test"), NewNumber(int64(summary.Tests)),
+				InternKeyword("pass"), NewNumber(int64(summary.Pass)),
+				InternKeyword("fail"), NewNumber(int64(summary.Fail)),
+				InternKeyword("error"), NewNumber(int64(summary.Error)),
+			), nil
+		},
+	})
+}
+
+// recordPass counts a passing assertion in the running test
+func recordPass(env *Environment) Value {
+	if run := env.testRegistry().current; run != nil {
+		run.pass++
+	}
+	return Symbol("true")
+}
+
+// recordFailure reports a failed assertion. Inside a test run the failure is
+// printed and counted; outside one it is raised as an error.
+func recordFailure(env *Environment, report string) (Value, error) {
+	run := env.testRegistry().current
+	if run == nil {
+		return nil, NewRuntimeError("assertion failed\n%s", report)
+	}
+	run.failures++
+	fmt.Fprintf(run.out, "\nFAIL in (%s)\n%s\n", run.name, report)
+	return Nil{}, nil
+}
+
+// checkProperty runs prop against *property-runs* random cases, shrinking
+// and reporting the first failing case as a single assertion
+func checkProperty(name Value, names []Value, gens []*Generator, prop Value, env *Environment) (Value, error) {
+	runs := int64(100)
+	if value, err := env.Get(Intern("*property-runs*")); err == nil {
+		if num, ok := value.(Number); ok {
+			runs = num.ToInt()
+		}
+	}
+	seed := env.randomSeed()
+	if value, err := env.Get(Intern("*property-seed*")); err == nil {
+		if num, ok := value.(Number); ok {
+			seed = num.ToInt()
+		}
+	}
+
+	// Assertions made by the property body are collected here rather than
+	// reported, so that each attempted case counts as one pass or failure
+	registry := env.testRegistry()
+	outer := registry.current
+	defer func() { registry.current = outer }()
+
+	fails := func(args []Value) (bool, string) {
+		scratch := &testRun{name: Symbol(name.String()), out: io.Discard}
+		registry.current = scratch
+		result, err := callFunction(prop, args, env)
+		registry.current = outer
+		switch {
+		case err != nil:
+			return true, err.Error()
+		case scratch.failures > 0:
+			return true, "assertion failed"
+		case !isTruthy(result):
+			return true, fmt.Sprintf("returned %s", result)
+		}
+		return false, ""
+	}
+
+	r := rand.New(rand.NewSource(seed))
+	for i := int64(0); i < runs; i++ {
+		args := make([]Value, len(gens))
+		for j, gen := range gens {
+			args[j] = gen.Generate(r, int(i))
+		}
+
+		failed, reason := fails(args)
+		if !failed {
+			continue
+		}
+
+		shrunk, steps := shrinkCase(args, gens, fails)
+		_, reason = fails(shrunk)
+		report := fmt.Sprintf("property %s failed after %d runs (seed %d): %s\n  original: %s\n    shrunk: %s (%d steps)",
+			name, i+1, seed, reason, propertyBindings(names, args), propertyBindings(names, shrunk), steps)
+		return recordFailure(env, report)
+	}
+	return recordPass(env), nil
+}
+
+// shrinkCase repeatedly replaces one argument with a simpler candidate that
+// still fails, until no candidate fails
+func shrinkCase(args []Value, gens []*Generator, fails func([]Value) (bool, string)) ([]Value, int) {
+	const maxSteps = 1000
+	steps := 0
+	for steps < maxSteps {
+		improved := false
+		for i, gen := range gens {
+			for _, candidate := range gen.Shrink(args[i]) {
+				trial := append([]Value(nil), args...)
+				trial[i] = candidate
+				if failed, _ := fails(trial); failed {
+					args = trial
+					improved = true
+					break
+				}
+			}
+			if improved {
+				break
+			}
+		}
+		if !improved {
+			break
+		}
+		steps++
+	}
+	return args, steps
+}
+
+// propertyBindings renders a case as [name value ...]
+func propertyBindings(names, args []Value) string {
+	parts := make([]string, len(names))
+	for i := range names {
+		parts[i] = names[i].String() + " " + args[i].String()
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+// checkGolden compares the pretty-printed value against GOLDEN_DIR/name.golden,
+// or rewrites the file when updating
+func checkGolden(name string, value Value, env *Environment) (Value, error) {
+	options := env.testRegistry().options
+	path := filepath.Join(options.GoldenDir, name+".golden")
+	actual := PrettyString(value, 80) + "\n"
+
+	if options.Update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, NewIOError("failed to create %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0644); err != nil {
+			return nil, NewIOError("failed to write golden file %s: %v", path, err)
+		}
+		return recordPass(env), nil
+	}
+
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		return recordFailure(env, fmt.Sprintf("missing golden file %s (run golisp test --update to create it)", path))
+	}
+	if string(expected) == actual {
+		return recordPass(env), nil
+	}
+	return recordFailure(env, fmt.Sprintf("golden file %s differs\n%s", path, strings.TrimSuffix(textDiff(string(expected), actual, path, "actual"), "\n")))
+}
+
+// RunTests runs every test registered in env, reporting failures and a
+// summary to options.Out
+func RunTests(env *Environment, options TestOptions) TestSummary {
+	if options.Out == nil {
+		options.Out = os.Stdout
+	}
+	if options.GoldenDir == "" {
+		options.GoldenDir = DefaultGoldenDir
+	}
+
+	registry := env.testRegistry()
+	saved := registry.options
+	registry.options = options
+	defer func() {
+		registry.options = saved
+		registry.current = nil
+	}()
+
+	var summary TestSummary
+	assertions := 0
+	for _, test := range registry.tests {
+		run := &testRun{name: test.name, out: options.Out}
+		registry.current = run
+
+		summary.Tests++
+		// Each test numbers its gensyms from 1, so golden files of
+		// macroexpansions don't depend on what ran before
+		if _, err := callFunction(test.fn, nil, withGensymSeed(env, 1)); err != nil {
+			summary.Error++
+			fmt.Fprintf(options.Out, "\nERROR in (%s)\n%v\n", test.name, err)
+		}
+		summary.Pass += run.pass
+		summary.Fail += run.failures
+		assertions += run.pass + run.failures
+	}
+
+	fmt.Fprintf(options.Out, "\nRan %d tests containing %d assertions.\n", summary.Tests, assertions)
+	fmt.Fprintf(options.Out, "%d failures, %d errors.\n", summary.Fail, summary.Error)
+	return summary
+}
@@ -0,0 +1,252 @@
+package core
+
+// Var is a reference to a top-level definition. It always yields the current
+// binding, so code holding a Var (or calling through one) sees redefinitions.
+type Var struct {
+	Name     Symbol
+	env      *Environment // The root environment holding the binding
+	watchers watchers
+}
+
+func (v *Var) String() string {
+	return "#'" + string(v.Name)
+}
+
+func (v *Var) watchList() *watchers {
+	return &v.watchers
+}
+
+// Deref returns the current value of the var
+func (v *Var) Deref() (Value, error) {
+	value, exists := v.env.binding(v.Name)
+	if !exists {
+		return nil, NewNameError("var %s is unbound", v.Name)
+	}
+	return value, nil
+}
+
+// Call calls the var's current value
+func (v *Var) Call(args []Value, env *Environment) (Value, error) {
+	value, err := v.Deref()
+	if err != nil {
+		return nil, err
+	}
+	return callFunction(value, args, env)
+}
+
+// RedefinitionHook is told when a top-level name is bound to a new value
+type RedefinitionHook func(name Symbol, old, new Value)
+
+// varRegistry holds the vars and redefinition hooks of a root environment
+type varRegistry struct {
+	vars     map[Symbol]*Var
+	hooks    []RedefinitionHook
+	strict   bool            // Set by SetStrict
+	declared map[Symbol]bool // Names bound by declare and not yet defined
+}
+
+// varRegistry returns the registry of the root environment
+func (env *Environment) varRegistry() *varRegistry {
+	root := env.root()
+	if root.vars == nil {
+		root.vars = &varRegistry{vars: make(map[Symbol]*Var), declared: make(map[Symbol]bool)}
+	}
+	return root.vars
+}
+
+// OnRedefine registers hook to run whenever a top-level name that is already
+// bound is redefined, by def, defn, defmacro or alter-var-root
+func (env *Environment) OnRedefine(hook RedefinitionHook) {
+	registry := env.varRegistry()
+	registry.hooks = append(registry.hooks, hook)
+}
+
+// define binds sym for def, defn and defmacro, notifying watchers when a
+// top-level binding is replaced
+func (env *Environment) define(sym Symbol, value Value) error {
+	old, existed := env.binding(sym)
+	if existed && env.parent == nil {
+		if err := env.checkRedefinition(sym); err != nil {
+			return err
+		}
+	}
+	env.Set(sym, value)
+	if existed && env.parent == nil {
+		return env.redefined(sym, old, value)
+	}
+	return nil
+}
+
+// redefined runs the watches of sym's var, if any, and the redefinition hooks
+func (env *Environment) redefined(sym Symbol, old, value Value) error {
+	registry := env.varRegistry()
+	if v, ok := registry.vars[sym]; ok {
+		if err := v.watchers.notify(v, old, value, env); err != nil {
+			return err
+		}
+	}
+	for _, hook := range registry.hooks {
+		hook(sym, old, value)
+	}
+	return nil
+}
+
+// evalVar implements (var name), also written #'name
+func evalVar(args *List, env *Environment) (Value, error) {
+	argSlice := listToSlice(args)
+	if len(argSlice) != 1 {
+		return nil, NewArityError("var expects 1 argument, got %d", len(argSlice))
+	}
+	sym, ok := argSlice[0].(Symbol)
+	if !ok {
+		return nil, NewTypeError("var expects a symbol, got %s", TypeName(argSlice[0]))
+	}
+	root := env.root()
+	if _, exists := root.binding(sym); !exists {
+		return nil, NewNameError("unable to resolve var: %s", sym)
+	}
+
+	registry := root.varRegistry()
+	v, ok := registry.vars[sym]
+	if !ok {
+		v = &Var{Name: sym, env: root}
+		registry.vars[sym] = v
+	}
+	return v, nil
+}
+
+func varArg(fnName string, arg Value) (*Var, error) {
+	v, ok := arg.(*Var)
+	if !ok {
+		return nil, NewTypeError("%s expects a var, got %s", fnName, TypeName(arg))
+	}
+	return v, nil
+}
+
+// setupVarOperations adds alter-var-root, call-with-redefs,
+// call-with-bindings and bound-fn
+func setupVarOperations(env *Environment) {
+	env.Set(Intern("alter-var-root"), &BuiltinFunction{
+		Name: "alter-var-root",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("alter-var-root expects at least 2 arguments, got %d", len(args))
+			}
+			v, err := varArg("alter-var-root", args[0])
+			if err != nil {
+				return nil, err
+			}
+			old, err := v.Deref()
+			if err != nil {
+				return nil, err
+			}
+			value, err := callFunction(args[1], append([]Value{old}, args[2:]...), env)
+			if err != nil {
+				return nil, err
+			}
+			v.env.Set(v.Name, value)
+			if err := v.env.redefined(v.Name, old, value); err != nil {
+				return nil, err
+			}
+			return value, nil
+		},
+	})
+
+	// The with-redefs macro expands to call-with-redefs
+	env.Set(Intern("call-with-redefs"), &BuiltinFunction{
+		Name: "call-with-redefs",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("call-with-redefs expects 2 arguments, got %d", len(args))
+			}
+			pairs, err := collectionToSlice(args[0])
+			if err != nil || len(pairs)%2 != 0 {
+				return nil, NewTypeError("call-with-redefs expects var/value pairs")
+			}
+			return withRedefs(pairs, args[1], env)
+		},
+	})
+
+	// The binding macro expands to call-with-bindings
+	env.Set(Intern("call-with-bindings"), &BuiltinFunction{
+		Name: "call-with-bindings",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("call-with-bindings expects 2 arguments, got %d", len(args))
+			}
+			pairs, err := collectionToSlice(args[0])
+			if err != nil || len(pairs)%2 != 0 {
+				return nil, NewTypeError("call-with-bindings expects var/value pairs")
+			}
+			values := make(map[Symbol]Value, len(pairs)/2)
+			for i := 0; i < len(pairs); i += 2 {
+				v, err := varArg("binding", pairs[i])
+				if err != nil {
+					return nil, err
+				}
+				values[v.Name] = pairs[i+1]
+			}
+			return callFunction(args[1], nil, withBindings(env, values))
+		},
+	})
+
+	env.Set(Intern("bound-fn"), &BuiltinFunction{
+		Name: "bound-fn",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("bound-fn expects 1 argument, got %d", len(args))
+			}
+			f := args[0]
+			if _, ok := f.(Callable); !ok {
+				return nil, NewTypeError("bound-fn expects a function, got %s", TypeName(f))
+			}
+			var dynamic *dynamicBindings
+			if env.calls != nil {
+				dynamic = env.calls.dynamic
+			}
+			return &BuiltinFunction{
+				Name: "bound-fn",
+				Fn: func(args []Value, env *Environment) (Value, error) {
+					return callFunction(f, args, withDynamic(env, dynamic))
+				},
+			}, nil
+		},
+	})
+}
+
+// withRedefs rebinds each var in pairs to the value after it while calling
+// fn, restoring the old values afterwards even if fn fails
+func withRedefs(pairs []Value, fn Value, env *Environment) (result Value, err error) {
+	type saved struct {
+		v   *Var
+		old Value
+	}
+	var restore []saved
+	defer func() {
+		for i := len(restore) - 1; i >= 0; i-- {
+			r := restore[i]
+			current, _ := r.v.Deref()
+			r.v.env.Set(r.v.Name, r.old)
+			if restoreErr := r.v.env.redefined(r.v.Name, current, r.old); restoreErr != nil && err == nil {
+				result, err = nil, restoreErr
+			}
+		}
+	}()
+
+	for i := 0; i < len(pairs); i += 2 {
+		v, err := varArg("with-redefs", pairs[i])
+		if err != nil {
+			return nil, err
+		}
+		old, err := v.Deref()
+		if err != nil {
+			return nil, err
+		}
+		restore = append(restore, saved{v, old})
+		v.env.Set(v.Name, pairs[i+1])
+		if err := v.env.redefined(v.Name, old, pairs[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	return callFunction(fn, nil, env)
+}
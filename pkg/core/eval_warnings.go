@@ -0,0 +1,129 @@
+package core
+
+import (
+	"fmt"
+	"os"
+)
+
+// SetWarningsAsErrors makes every warning of the interpreter fail with an
+// error instead of being printed, as golisp --werror does
+func (env *Environment) SetWarningsAsErrors(enabled bool) {
+	reports := env.interpreterState().reports
+	reports.mu.Lock()
+	defer reports.mu.Unlock()
+	reports.asErrors = enabled
+}
+
+// warningsAsErrors reports whether env's warnings fail instead of printing
+func (env *Environment) warningsAsErrors() bool {
+	return env.interpreterState().reports.failsWarnings()
+}
+
+func (r *reporter) failsWarnings() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.asErrors
+}
+
+// Warn prints "WARNING: msg" with the location, if known, to the
+// interpreter's error output, or returns it as an error when warnings are
+// errors
+func (env *Environment) Warn(msg string, pos Position) error {
+	return env.interpreterState().reports.warn(msg, pos, false)
+}
+
+// warnIn is Warn for code evaluated in env, whose strict mode also makes
+// warnings errors
+func warnIn(env *Environment, msg string, pos Position) error {
+	return env.interpreterState().reports.warn(msg, pos, env.Strict())
+}
+
+func (r *reporter) warn(msg string, pos Position, asError bool) error {
+	if location := formatLocation(pos); location != "" {
+		msg += " (" + location + ")"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if asError || r.asErrors {
+		return NewRuntimeError("warning treated as error: %s", msg)
+	}
+	out := r.out
+	if out == nil {
+		out = os.Stderr
+	}
+	fmt.Fprintf(out, "WARNING: %s\n", msg)
+	return nil
+}
+
+// formatLocation renders file:line:col, line:col or nothing
+func formatLocation(pos Position) string {
+	switch {
+	case pos.Line == 0:
+		return ""
+	case pos.File == "":
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	default:
+		return fmt.Sprintf("%s:%d:%d", pos.File, pos.Line, pos.Column)
+	}
+}
+
+// checkDeprecated warns the first time a function or macro whose attribute
+// map has :deprecated is resolved. :deprecated may be true or a message.
+func checkDeprecated(name Symbol, value Value, pos Position, env *Environment) error {
+	var meta *HashMap
+	switch fn := value.(type) {
+	case *UserFunction:
+		meta = fn.Meta
+	case *Macro:
+		meta = fn.Meta
+	}
+	if meta == nil {
+		return nil
+	}
+	reason := meta.Get(InternKeyword("deprecated"))
+	if !isTruthy(reason) {
+		return nil
+	}
+
+	remember := !env.warningsAsErrors() && !env.Strict()
+	state := env.interpreterState()
+	state.mu.Lock()
+	seen := state.deprecatedWarned[name]
+	if remember {
+		if state.deprecatedWarned == nil {
+			state.deprecatedWarned = make(map[Symbol]bool)
+		}
+		state.deprecatedWarned[name] = true
+	}
+	state.mu.Unlock()
+	if seen {
+		return nil
+	}
+
+	msg := fmt.Sprintf("%s is deprecated", name)
+	if text, ok := reason.(String); ok {
+		msg += ": " + string(text)
+	}
+	return warnIn(env, msg, pos)
+}
+
+// setupWarningOperations adds warn to the environment
+func setupWarningOperations(env *Environment) {
+	env.Set(Intern("warn"), &BuiltinFunction{
+		Name: "warn",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("warn expects 1 argument, got %d", len(args))
+			}
+			msg, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("warn expects a string, got %s", TypeName(args[0]))
+			}
+			if err := warnIn(env, string(msg), Position{}); err != nil {
+				return nil, err
+			}
+			return Nil{}, nil
+		},
+	})
+}
@@ -0,0 +1,103 @@
+package core
+
+import "sync"
+
+// watchers are the functions added to a reference with add-watch, each called
+// as (f key ref old new) after the reference changes
+type watchers struct {
+	mu      sync.Mutex
+	watches []watch
+}
+
+type watch struct {
+	key Value
+	fn  Value
+}
+
+// watchable is a reference that supports add-watch: a var, atom or ref
+type watchable interface {
+	Value
+	watchList() *watchers
+}
+
+// add registers fn under key, replacing any watch with the same key
+func (w *watchers) add(key, fn Value) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, existing := range w.watches {
+		if valuesEqual(existing.key, key) {
+			w.watches[i].fn = fn
+			return
+		}
+	}
+	w.watches = append(w.watches, watch{key: key, fn: fn})
+}
+
+func (w *watchers) remove(key Value) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, existing := range w.watches {
+		if valuesEqual(existing.key, key) {
+			w.watches = append(w.watches[:i], w.watches[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify calls every watch in the order they were added; the list is copied
+// so that watches may add or remove watches
+func (w *watchers) notify(ref, old, value Value, env *Environment) error {
+	w.mu.Lock()
+	watches := append([]watch(nil), w.watches...)
+	w.mu.Unlock()
+	for _, watch := range watches {
+		if _, err := callFunction(watch.fn, []Value{watch.key, ref, old, value}, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func watchableArg(fnName string, arg Value) (watchable, error) {
+	ref, ok := arg.(watchable)
+	if !ok {
+		return nil, NewTypeError("%s expects an atom, ref or var, got %s", fnName, TypeName(arg))
+	}
+	return ref, nil
+}
+
+// setupWatchOperations adds add-watch and remove-watch
+func setupWatchOperations(env *Environment) {
+	env.Set(Intern("add-watch"), &BuiltinFunction{
+		Name: "add-watch",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("add-watch expects 3 arguments, got %d", len(args))
+			}
+			ref, err := watchableArg("add-watch", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := args[2].(Callable); !ok {
+				return nil, NewTypeError("add-watch expects a function, got %s", TypeName(args[2]))
+			}
+			ref.watchList().add(args[1], args[2])
+			return ref, nil
+		},
+	})
+
+	env.Set(Intern("remove-watch"), &BuiltinFunction{
+		Name: "remove-watch",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("remove-watch expects 2 arguments, got %d", len(args))
+			}
+			ref, err := watchableArg("remove-watch", args[0])
+			if err != nil {
+				return nil, err
+			}
+			ref.watchList().remove(args[1])
+			return ref, nil
+		},
+	})
+}
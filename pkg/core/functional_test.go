@@ -0,0 +1,94 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunctionalBuiltins(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn add3 [a b c] (+ a b c))
+		(defn sum [& xs] (if (empty? xs) 0 (+ (first xs) (apply sum (rest xs)))))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(apply + [1 2 3])", "6"},
+		{"(apply + 1 2 '(3 4 5 6 7))", "28"},
+		{"(apply add3 1 [2 3])", "6"},
+		{"(apply sum (list))", "0"},
+		{"(apply str \"a\" nil)", "\"a\""},
+		{"(identity :x)", ":x"},
+		{"((constantly 42))", "42"},
+		{"((constantly 42) 1 2 3)", "42"},
+		{"((fnil + 0) nil 5)", "5"},
+		{"((fnil add3 1 2 3) nil nil nil)", "6"},
+		{"((fnil add3 1 2 3) 10 nil 20)", "32"},
+		{"((comp) :same)", ":same"},
+		{"((comp str +) 1 2)", "\"3\""},
+		{"((comp (fn [x] (* x 2)) (fn [x] (+ x 1)) add3) 1 2 3)", "14"},
+		{"((partial add3 1) 2 3)", "6"},
+		{"((partial sum 1 2) 3 4)", "10"},
+		{"((comp (partial * 10) (fnil + 0)) nil 1)", "10"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(apply +)":          "apply expects at least 2 arguments",
+		"(apply 1 [2])":      "cannot call non-function",
+		"(identity)":         "identity expects 1 argument",
+		"(fnil +)":           "fnil expects 2 to 4 arguments",
+		"(partial)":          "partial expects at least 1 argument",
+		"((comp) 1 2)":       "(comp) expects 1 argument",
+		"((partial add3) 1)": "expects 3 arguments, got 1",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestCallableProtocol(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn pos [x] (> x 0))
+		(def user {:name "ada" :langs [:lisp]})`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"((complement pos) 1)", "nil"},
+		{"((complement pos) -1)", "true"},
+		{"((complement :admin) user)", "true"},
+		{"((comp :name) user)", "\"ada\""},
+		{"((partial :missing) user :default)", ":default"},
+		{"(apply :name (list user))", "\"ada\""},
+		{"((comp count :langs) user)", "1"},
+		{"((complement (partial #'pos)) 5)", "nil"},
+		{"(fn? pos)", "true"},
+		{"(fn? (comp pos))", "true"},
+		{"(fn? :name)", "nil"},
+		{"(fn? #'pos)", "nil"},
+		{"(ifn? :name)", "true"},
+		{"(ifn? #'pos)", "true"},
+		{"(ifn? 1)", "nil"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	expr, _ := ReadString("((complement 1) 2)")
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "cannot call non-function") {
+		t.Errorf("Expected a non-function error for complement of a number, got: %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+package core
+
+import (
+	"container/heap"
+	"strings"
+)
+
+// Graphs are adjacency maps from each node to the nodes it points to, as a
+// collection {:app [:db :log] :db [:log]} or, for weighted edges, as a map
+// of weights {:a {:b 1 :c 4}}. Nodes that only appear as targets are part
+// of the graph too. Results list nodes in the order they first appear, so
+// they are the same from run to run.
+
+// graph is an adjacency map indexed for the algorithms
+type graph struct {
+	nodes []Value
+	index map[string]int // Printed node to its position in nodes
+	edges [][]graphEdge
+}
+
+type graphEdge struct {
+	to     int
+	weight float64
+}
+
+// parseGraph reads an adjacency map
+func parseGraph(name string, value Value) (*graph, error) {
+	adjacency, ok := value.(*HashMap)
+	if !ok {
+		return nil, NewTypeError("%s expects an adjacency map, got %s", name, TypeName(value))
+	}
+	g := &graph{index: make(map[string]int)}
+	for _, node := range adjacency.keys {
+		g.node(node)
+	}
+	for _, node := range adjacency.keys {
+		from := g.node(node)
+		switch targets := adjacency.Get(node).(type) {
+		case Nil:
+		case *HashMap:
+			for _, target := range targets.keys {
+				weight, ok := targets.Get(target).(Number)
+				if !ok || weight.ToFloat() < 0 {
+					return nil, NewTypeError("%s expects non-negative edge weights, got %s for %s -> %s", name, targets.Get(target), node, target)
+				}
+				g.edges[from] = append(g.edges[from], graphEdge{to: g.node(target), weight: weight.ToFloat()})
+			}
+		default:
+			items, err := collectionToSlice(targets)
+			if err != nil {
+				return nil, NewTypeError("%s expects the neighbours of %s as a collection or map of weights, got %s", name, node, targets)
+			}
+			for _, target := range items {
+				g.edges[from] = append(g.edges[from], graphEdge{to: g.node(target), weight: 1})
+			}
+		}
+	}
+	return g, nil
+}
+
+// node returns the position of a node, adding it if it is new
+func (g *graph) node(value Value) int {
+	key := value.String()
+	if i, ok := g.index[key]; ok {
+		return i
+	}
+	g.index[key] = len(g.nodes)
+	g.nodes = append(g.nodes, value)
+	g.edges = append(g.edges, nil)
+	return len(g.nodes) - 1
+}
+
+func (g *graph) values(positions []int) []Value {
+	values := make([]Value, len(positions))
+	for i, p := range positions {
+		values[i] = g.nodes[p]
+	}
+	return values
+}
+
+// topoSort orders the nodes so each comes before the nodes it points to,
+// returning a cycle instead if there is one
+func (g *graph) topoSort() (order, cycle []int) {
+	indegree := make([]int, len(g.nodes))
+	for _, edges := range g.edges {
+		for _, edge := range edges {
+			indegree[edge.to]++
+		}
+	}
+	var ready []int
+	for n := range g.nodes {
+		if indegree[n] == 0 {
+			ready = append(ready, n)
+		}
+	}
+	for len(ready) > 0 {
+		n := ready[0]
+		ready = ready[1:]
+		order = append(order, n)
+		for _, edge := range g.edges[n] {
+			if indegree[edge.to]--; indegree[edge.to] == 0 {
+				ready = append(ready, edge.to)
+			}
+		}
+	}
+	if len(order) < len(g.nodes) {
+		return nil, g.findCycle()
+	}
+	return order, nil
+}
+
+// findCycle returns the nodes of a cycle with the first repeated at the
+// end, or nil if the graph is acyclic
+func (g *graph) findCycle() []int {
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+	state := make([]int, len(g.nodes))
+	var path []int
+	var visit func(n int) []int
+	visit = func(n int) []int {
+		state[n] = onPath
+		path = append(path, n)
+		for _, edge := range g.edges[n] {
+			switch state[edge.to] {
+			case onPath:
+				for i, p := range path {
+					if p == edge.to {
+						return append(append([]int(nil), path[i:]...), edge.to)
+					}
+				}
+			case unvisited:
+				if cycle := visit(edge.to); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[n] = done
+		return nil
+	}
+	for n := range g.nodes {
+		if state[n] == unvisited {
+			if cycle := visit(n); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// shortestPath finds the path from one node to another with the least total
+// weight, where unweighted edges weigh 1, or returns nil if there is none
+func (g *graph) shortestPath(from, to int) ([]int, float64) {
+	dist := make([]float64, len(g.nodes))
+	prev := make([]int, len(g.nodes))
+	for n := range dist {
+		dist[n], prev[n] = -1, -1
+	}
+	dist[from] = 0
+	queue := &graphQueue{{node: from}}
+	queued := 1
+	for queue.Len() > 0 {
+		item := heap.Pop(queue).(graphQueueItem)
+		if item.dist > dist[item.node] {
+			continue // A shorter way here was already found
+		}
+		if item.node == to {
+			break
+		}
+		for _, edge := range g.edges[item.node] {
+			d := item.dist + edge.weight
+			if dist[edge.to] < 0 || d < dist[edge.to] {
+				dist[edge.to], prev[edge.to] = d, item.node
+				heap.Push(queue, graphQueueItem{node: edge.to, dist: d, seq: queued})
+				queued++
+			}
+		}
+	}
+	if dist[to] < 0 {
+		return nil, 0
+	}
+	var path []int
+	for n := to; n != -1; n = prev[n] {
+		path = append([]int{n}, path...)
+	}
+	return path, dist[to]
+}
+
+// graphQueueItem is a node to visit, ordered by distance and then by when it
+// was queued so that equal paths are found the same way every time
+type graphQueueItem struct {
+	node int
+	dist float64
+	seq  int
+}
+
+type graphQueue []graphQueueItem
+
+func (q graphQueue) Len() int { return len(q) }
+func (q graphQueue) Less(i, j int) bool {
+	if q[i].dist != q[j].dist {
+		return q[i].dist < q[j].dist
+	}
+	return q[i].seq < q[j].seq
+}
+func (q graphQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *graphQueue) Push(x any)   { *q = append(*q, x.(graphQueueItem)) }
+func (q *graphQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// components groups nodes connected by edges in either direction
+func (g *graph) components() [][]int {
+	neighbours := make([][]int, len(g.nodes))
+	for from, edges := range g.edges {
+		for _, edge := range edges {
+			neighbours[from] = append(neighbours[from], edge.to)
+			neighbours[edge.to] = append(neighbours[edge.to], from)
+		}
+	}
+	seen := make([]bool, len(g.nodes))
+	var components [][]int
+	for start := range g.nodes {
+		if seen[start] {
+			continue
+		}
+		seen[start] = true
+		component := []int{start}
+		for i := 0; i < len(component); i++ {
+			for _, n := range neighbours[component[i]] {
+				if !seen[n] {
+					seen[n] = true
+					component = append(component, n)
+				}
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// describeCycle renders a cycle as "a -> b -> a"
+func (g *graph) describeCycle(cycle []int) string {
+	parts := make([]string, len(cycle))
+	for i, n := range cycle {
+		parts[i] = g.nodes[n].String()
+	}
+	return strings.Join(parts, " -> ")
+}
+
+// setupGraphOperations adds the graph/* functions
+func setupGraphOperations(env *Environment) {
+	env.Set(Intern("graph/nodes"), &BuiltinFunction{
+		Name: "graph/nodes",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("graph/nodes expects 1 argument, got %d", len(args))
+			}
+			g, err := parseGraph("graph/nodes", args[0])
+			if err != nil {
+				return nil, err
+			}
+			return NewVector(g.nodes...), nil
+		},
+	})
+
+	// Dependency maps like {:app [:db]} list what each node needs, so
+	// (reverse (graph/topo-sort deps)) is the order to build them in
+	env.Set(Intern("graph/topo-sort"), &BuiltinFunction{
+		Name: "graph/topo-sort",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("graph/topo-sort expects 1 argument, got %d", len(args))
+			}
+			g, err := parseGraph("graph/topo-sort", args[0])
+			if err != nil {
+				return nil, err
+			}
+			order, cycle := g.topoSort()
+			if cycle != nil {
+				return nil, NewRuntimeError("graph/topo-sort: graph has a cycle: %s", g.describeCycle(cycle))
+			}
+			return NewVector(g.values(order)...), nil
+		},
+	})
+
+	env.Set(Intern("graph/find-cycle"), &BuiltinFunction{
+		Name: "graph/find-cycle",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("graph/find-cycle expects 1 argument, got %d", len(args))
+			}
+			g, err := parseGraph("graph/find-cycle", args[0])
+			if err != nil {
+				return nil, err
+			}
+			if cycle := g.findCycle(); cycle != nil {
+				return NewVector(g.values(cycle)...), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("graph/shortest-path"), &BuiltinFunction{
+		Name: "graph/shortest-path",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("graph/shortest-path expects 3 arguments, got %d", len(args))
+			}
+			g, err := parseGraph("graph/shortest-path", args[0])
+			if err != nil {
+				return nil, err
+			}
+			from, ok := g.index[args[1].String()]
+			if !ok {
+				return Nil{}, nil
+			}
+			to, ok := g.index[args[2].String()]
+			if !ok {
+				return Nil{}, nil
+			}
+			path, _ := g.shortestPath(from, to)
+			if path == nil {
+				return Nil{}, nil
+			}
+			return NewVector(g.values(path)...), nil
+		},
+	})
+
+	env.Set(Intern("graph/distance"), &BuiltinFunction{
+		Name: "graph/distance",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("graph/distance expects 3 arguments, got %d", len(args))
+			}
+			g, err := parseGraph("graph/distance", args[0])
+			if err != nil {
+				return nil, err
+			}
+			from, fromOK := g.index[args[1].String()]
+			to, toOK := g.index[args[2].String()]
+			if !fromOK || !toOK {
+				return Nil{}, nil
+			}
+			path, dist := g.shortestPath(from, to)
+			if path == nil {
+				return Nil{}, nil
+			}
+			if dist == float64(int64(dist)) {
+				return NewNumber(int64(dist)), nil
+			}
+			return NewNumber(dist), nil
+		},
+	})
+
+	env.Set(Intern("graph/components"), &BuiltinFunction{
+		Name: "graph/components",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("graph/components expects 1 argument, got %d", len(args))
+			}
+			g, err := parseGraph("graph/components", args[0])
+			if err != nil {
+				return nil, err
+			}
+			var components []Value
+			for _, component := range g.components() {
+				components = append(components, NewVector(g.values(component)...))
+			}
+			return NewVector(components...), nil
+		},
+	})
+}
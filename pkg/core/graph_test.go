@@ -0,0 +1,51 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphAlgorithms(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def deps {:app [:db :log] :db [:log :config] :log [:config]})`)
+	evalAll(t, env, `(def roads {:a {:b 1 :c 4} :b {:c 1 :d 5} :c {:d 1}})`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(graph/nodes deps)`, `[:app :db :log :config]`},
+		{`(graph/topo-sort deps)`, `[:app :db :log :config]`},
+		{`(graph/topo-sort {:b [:c] :a [:c] :c []})`, `[:b :a :c]`},
+		{`(graph/find-cycle deps)`, `nil`},
+		{`(graph/find-cycle {:a [:b] :b [:c] :c [:b]})`, `[:b :c :b]`},
+		{`(graph/find-cycle {:a [:a]})`, `[:a :a]`},
+		{`(graph/shortest-path {:a [:b :c] :b [:d] :c [:d] :d [:e]} :a :e)`, `[:a :b :d :e]`},
+		{`(graph/shortest-path roads :a :d)`, `[:a :b :c :d]`},
+		{`(graph/shortest-path roads :a :a)`, `[:a]`},
+		{`(graph/shortest-path roads :d :a)`, `nil`},
+		{`(graph/shortest-path roads :a :missing)`, `nil`},
+		{`(graph/distance roads :a :d)`, `3`},
+		{`(graph/distance {:a {:b 1.5}} :a :b)`, `1.5`},
+		{`(graph/distance {"x" ["y"] "y" ["z"]} "x" "z")`, `2`},
+		{`(graph/components {:a [:b] :c [:d] :e []})`, `[[:a :b] [:c :d] [:e]]`},
+		{`(graph/components {:a [:b] :c [:b]})`, `[[:a :b :c]]`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(graph/topo-sort {:a [:b] :b [:c] :c [:a]})`: "graph has a cycle: :a -> :b -> :c -> :a",
+		`(graph/topo-sort [1 2])`:                     "expects an adjacency map",
+		`(graph/distance {:a {:b -1}} :a :b)`:         "non-negative edge weights",
+		`(graph/nodes {:a 1})`:                        "neighbours of :a",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
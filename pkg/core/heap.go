@@ -0,0 +1,244 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// largestShown is how many of the biggest global bindings env-tree lists
+const largestShown = 10
+
+// sizeEstimator adds up the approximate bytes a value keeps reachable, each
+// shared structure counted once. Closures count the local scopes they
+// captured, which is how a forgotten closure keeps large data alive; the
+// globals every function sees are not counted.
+type sizeEstimator struct {
+	root *Environment
+	seen map[any]bool
+}
+
+func newSizeEstimator(env *Environment) *sizeEstimator {
+	return &sizeEstimator{root: env.root(), seen: make(map[any]bool)}
+}
+
+// first reports whether ptr has not been counted yet, marking it counted
+func (s *sizeEstimator) first(ptr any) bool {
+	if s.seen[ptr] {
+		return false
+	}
+	s.seen[ptr] = true
+	return true
+}
+
+// size estimates v, using the sizes of the Go structures behind it on a
+// 64-bit platform
+func (s *sizeEstimator) size(v Value) int64 {
+	switch v := v.(type) {
+	case nil, Nil, Symbol, Keyword:
+		return 0 // Interned or free
+	case Number:
+		return 16
+	case String:
+		return 16 + int64(len(v))
+	case *List:
+		var total int64
+		for cell := v; cell != nil && !cell.IsEmpty() && s.first(cell); cell = cell.Rest() {
+			total += 48 + s.size(cell.First())
+		}
+		return total
+	case *Vector:
+		if !s.first(v) {
+			return 0
+		}
+		total := 48 + 16*int64(len(v.elements))
+		for _, elem := range v.elements {
+			total += s.size(elem)
+		}
+		return total
+	case *HashMap:
+		if !s.first(v) {
+			return 0
+		}
+		total := int64(64)
+		for _, key := range v.keys {
+			total += 64 + s.size(key) + s.size(v.Get(key))
+		}
+		return total
+	case *Set:
+		if !s.first(v) {
+			return 0
+		}
+		total := int64(64)
+		for _, elem := range v.order {
+			total += 48 + s.size(elem)
+		}
+		return total
+	case *Atom:
+		if !s.first(v) {
+			return 0
+		}
+		v.mu.Lock()
+		value := v.value
+		v.mu.Unlock()
+		return 48 + s.size(value)
+	case *LazySeq:
+		if !s.first(v) {
+			return 0
+		}
+		v.mu.Lock()
+		realized, first, rest := v.realized && !v.empty, v.first, v.rest
+		v.mu.Unlock()
+		if !realized {
+			return 64 // The unrealized step may hold more
+		}
+		return 64 + s.size(first) + s.size(rest)
+	case *UserFunction:
+		if !s.first(v) {
+			return 0
+		}
+		return 96 + s.size(v.Params) + s.size(v.Body) + s.scopes(v.Env)
+	case *Macro:
+		if !s.first(v) {
+			return 0
+		}
+		return 96 + s.size(v.Params) + s.size(v.Body) + s.scopes(v.Env)
+	}
+	return 32
+}
+
+// scopes estimates the local scopes from env up to, not including, the root
+func (s *sizeEstimator) scopes(env *Environment) int64 {
+	var total int64
+	for ; env != nil && env != s.root && s.first(env); env = env.parent {
+		total += s.scope(env)
+	}
+	return total
+}
+
+// scope estimates the bindings of env itself
+func (s *sizeEstimator) scope(env *Environment) int64 {
+	total := int64(64)
+	for name, value := range env.snapshot() {
+		total += 48 + int64(len(name)) + s.size(value)
+	}
+	return total
+}
+
+// bindingNamespace is the namespace a global binding is counted under:
+// the prefix of a qualified name like graph/topo-sort, "core" for the
+// builtins and standard library, and "user" for the rest
+func bindingNamespace(name Symbol, value Value, registry map[Symbol]registeredFunction) string {
+	if i := strings.Index(string(name), "/"); i > 0 && i < len(name)-1 {
+		return string(name[:i])
+	}
+	if entry, ok := registry[name]; ok && sameValue(entry.value, value) {
+		return "core"
+	}
+	return "user"
+}
+
+// envTree describes the scopes from env out to the root, innermost first.
+// Local scopes list their names; the root counts its bindings per
+// namespace and names the largest.
+func envTree(env *Environment) *Vector {
+	var scopes []*Environment
+	for scope := env; scope != nil; scope = scope.parent {
+		scopes = append(scopes, scope)
+	}
+
+	var tree []Value
+	for depth, scope := range scopes {
+		bindings := scope.snapshot()
+		node := NewHashMapWithPairs(
+			InternKeyword("depth"), NewNumber(int64(depth)),
+			InternKeyword("bindings"), NewNumber(int64(len(bindings))),
+		)
+		if scope.parent != nil {
+			names := make([]Value, 0, len(bindings))
+			for name := range bindings {
+				names = append(names, name)
+			}
+			sort.Slice(names, func(i, j int) bool { return names[i].(Symbol) < names[j].(Symbol) })
+			node.Set(InternKeyword("names"), NewVector(names...))
+			node.Set(InternKeyword("size"), NewNumber(newSizeEstimator(env).scope(scope)))
+		} else {
+			node.Set(InternKeyword("namespaces"), namespaceCounts(scope, bindings))
+			node.Set(InternKeyword("largest"), largestBindings(scope, bindings))
+		}
+		tree = append(tree, node)
+	}
+	return NewVector(tree...)
+}
+
+// namespaceCounts counts the root bindings in each namespace
+func namespaceCounts(root *Environment, bindings map[Symbol]Value) *HashMap {
+	registry := root.functionRegistry()
+	registry.Lock()
+	counts := make(map[string]int64)
+	for name, value := range bindings {
+		counts[bindingNamespace(name, value, registry.entries)]++
+	}
+	registry.Unlock()
+
+	namespaces := make([]string, 0, len(counts))
+	for ns := range counts {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	result := NewHashMap()
+	for _, ns := range namespaces {
+		result.Set(String(ns), NewNumber(counts[ns]))
+	}
+	return result
+}
+
+// largestBindings lists the root bindings keeping the most data reachable,
+// biggest first
+func largestBindings(root *Environment, bindings map[Symbol]Value) *Vector {
+	type sized struct {
+		name Symbol
+		size int64
+	}
+	all := make([]sized, 0, len(bindings))
+	for name, value := range bindings {
+		all = append(all, sized{name, newSizeEstimator(root).size(value)})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].size != all[j].size {
+			return all[i].size > all[j].size
+		}
+		return all[i].name < all[j].name
+	})
+	if len(all) > largestShown {
+		all = all[:largestShown]
+	}
+	largest := make([]Value, len(all))
+	for i, b := range all {
+		largest[i] = NewHashMapWithPairs(InternKeyword("name"), b.name, InternKeyword("size"), NewNumber(b.size))
+	}
+	return NewVector(largest...)
+}
+
+// setupHeapOperations adds env-tree and value-size, for finding what keeps
+// memory alive
+func setupHeapOperations(env *Environment) {
+	env.Set(Intern("env-tree"), &BuiltinFunction{
+		Name: "env-tree",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("env-tree expects 0 arguments, got %d", len(args))
+			}
+			return envTree(env), nil
+		},
+	})
+
+	env.Set(Intern("value-size"), &BuiltinFunction{
+		Name: "value-size",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("value-size expects 1 argument, got %d", len(args))
+			}
+			return NewNumber(newSizeEstimator(env).size(args[0])), nil
+		},
+	})
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// numbers returns a vector of 0 to n-1
+func numbers(n int) *Vector {
+	items := make([]Value, n)
+	for i := range items {
+		items[i] = NewNumber(int64(i))
+	}
+	return NewVector(items...)
+}
+
+func TestValueSize(t *testing.T) {
+	env := NewCoreEnvironment()
+	env.Set(Intern("data"), numbers(100))
+
+	size := func(input string) int64 {
+		t.Helper()
+		return evalAll(t, env, `(value-size `+input+`)`).(Number).ToInt()
+	}
+
+	if got := size(`"abcd"`); got != 20 {
+		t.Errorf("Expected a 4-byte string to take 20 bytes, got %d", got)
+	}
+	if got := size(`:k`); got != 0 {
+		t.Errorf("Expected interned keywords to take nothing, got %d", got)
+	}
+	if small, large := size(`(list 1 2)`), size(`data`); small >= large {
+		t.Errorf("Expected 100 numbers to outweigh 2, got %d and %d", large, small)
+	}
+	// Shared structure counts once
+	if once, twice := size(`data`), size(`(vector data data)`); twice > once+100 {
+		t.Errorf("Expected a vector holding data twice to count it once, got %d for %d", twice, once)
+	}
+	// A closure retains the scope it captured
+	evalAll(t, env, `(defn make-adder [n] (let [unused data] (fn [x] (+ x n))))
+		(def add (make-adder 1))`)
+	if got, captured := size(`add`), size(`data`); got < captured {
+		t.Errorf("Expected the closure to retain the captured data (%d bytes), got %d", captured, got)
+	}
+	// Globals are not
+	evalAll(t, env, `(defn use-data [] (count data))`)
+	if got := size(`use-data`); got > 1000 {
+		t.Errorf("Expected a global function not to count the globals it uses, got %d", got)
+	}
+}
+
+func TestEnvTree(t *testing.T) {
+	env := NewCoreEnvironment()
+	userBindings := func() int64 {
+		tree := evalAll(t, env, `(env-tree)`).(*Vector).elements
+		namespaces := tree[len(tree)-1].(*HashMap).Get(InternKeyword("namespaces")).(*HashMap)
+		if n, ok := namespaces.Get(String("user")).(Number); ok {
+			return n.ToInt()
+		}
+		return 0
+	}
+	before := userBindings()
+	env.Set(Intern("data"), numbers(1000))
+	evalAll(t, env, `(def log/level :info)
+		(defn scopes [a b] (let [c 3] (env-tree)))`)
+
+	tree := evalAll(t, env, `(scopes 1 2)`).(*Vector).elements
+	if len(tree) != 3 {
+		t.Fatalf("Expected the let, call and root scopes, got %v", tree)
+	}
+	local := tree[0].(*HashMap)
+	if got := local.Get(InternKeyword("names")).String(); got != "[c]" {
+		t.Errorf("Expected the innermost scope to bind c, got %s", got)
+	}
+	if got := tree[1].(*HashMap).Get(InternKeyword("names")).String(); got != "[a b]" {
+		t.Errorf("Expected the call scope to bind a and b, got %s", got)
+	}
+
+	root := tree[len(tree)-1].(*HashMap)
+	namespaces := root.Get(InternKeyword("namespaces")).(*HashMap)
+	if got := userBindings() - before; got != 2 {
+		t.Errorf("Expected data and scopes to add 2 user bindings, got %d in %s", got, namespaces)
+	}
+	if got := namespaces.Get(String("log")); got == nil || got.(Number).ToInt() < 2 {
+		t.Errorf("Expected log/level under the log namespace, got %s", namespaces)
+	}
+	largest := root.Get(InternKeyword("largest")).(*Vector).elements
+	if len(largest) == 0 || largest[0].(*HashMap).Get(InternKeyword("name")) != Symbol("data") {
+		t.Errorf("Expected data to be the largest binding, got %v", largest)
+	}
+
+	if err := evalSource(t, env, `(env-tree 1)`); err == nil || !strings.Contains(err.Error(), "env-tree expects 0 arguments") {
+		t.Errorf("Expected an arity error, got %v", err)
+	}
+}
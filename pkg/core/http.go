@@ -0,0 +1,586 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Handlers are ring-style functions from a request map to a response map.
+// A request has :method (a keyword like :get), :uri, :path, :query-string,
+// :query-params, :params, :headers (lower-case names), :body and
+// :remote-addr. A response is {:status 200 :headers {...} :body "..."}, or
+// just a string for a 200 text response, or nil for a 404. Middleware like
+// wrap-json takes a handler and returns a new one, so a stack reads
+// (-> (router routes) wrap-json wrap-logging).
+
+// maxRequestBody bounds the request bodies read into :body
+const maxRequestBody = 10 << 20
+
+// HTTPServer is a server started with http-serve. Like scheduled jobs, each
+// request runs its handler on its own goroutine.
+type HTTPServer struct {
+	server *http.Server
+	addr   net.Addr
+}
+
+func (s *HTTPServer) String() string {
+	return fmt.Sprintf("#<http-server %s>", s.addr)
+}
+
+// requestValue is the request map handlers receive
+func requestValue(r *http.Request) (*HashMap, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxRequestBody))
+	if err != nil {
+		return nil, err
+	}
+	headers := NewHashMap()
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		headers.Set(String(strings.ToLower(name)), String(strings.Join(r.Header.Values(name), ", ")))
+	}
+	query := queryParams(r.URL.Query())
+	return NewHashMapWithPairs(
+		InternKeyword("method"), InternKeyword(strings.ToLower(r.Method)),
+		InternKeyword("uri"), String(r.URL.RequestURI()),
+		InternKeyword("path"), String(r.URL.Path),
+		InternKeyword("query-string"), String(r.URL.RawQuery),
+		InternKeyword("query-params"), query,
+		InternKeyword("params"), query,
+		InternKeyword("headers"), headers,
+		InternKeyword("body"), String(body),
+		InternKeyword("remote-addr"), String(r.RemoteAddr),
+	), nil
+}
+
+// queryParams turns a parsed query into a map with keyword keys, where a
+// parameter given more than once has a vector of its values
+func queryParams(values url.Values) *HashMap {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	params := NewHashMap()
+	for _, name := range names {
+		if vs := values[name]; len(vs) == 1 {
+			params.Set(InternKeyword(name), String(vs[0]))
+		} else {
+			items := make([]Value, len(vs))
+			for i, v := range vs {
+				items[i] = String(v)
+			}
+			params.Set(InternKeyword(name), NewVector(items...))
+		}
+	}
+	return params
+}
+
+// normalizeResponse turns what a handler returned into a response map
+func normalizeResponse(value Value) (*HashMap, error) {
+	switch v := value.(type) {
+	case Nil:
+		return NewHashMapWithPairs(InternKeyword("status"), NewNumber(int64(404)), InternKeyword("body"), String("Not Found")), nil
+	case String:
+		return NewHashMapWithPairs(
+			InternKeyword("status"), NewNumber(int64(200)),
+			InternKeyword("headers"), NewHashMapWithPairs(String("Content-Type"), String("text/plain; charset=utf-8")),
+			InternKeyword("body"), v,
+		), nil
+	case *HashMap:
+		return v, nil
+	}
+	return nil, NewTypeError("handler must return a response map, a string or nil, got %s", printString(value))
+}
+
+// writeResponse sends a response map. Bodies other than strings are printed.
+func writeResponse(w http.ResponseWriter, response *HashMap) {
+	if headers, ok := response.Get(InternKeyword("headers")).(*HashMap); ok {
+		for _, name := range headers.keys {
+			// A vector of values, like several Set-Cookie headers, sends each
+			if values, ok := headers.Get(name).(*Vector); ok {
+				for _, value := range values.elements {
+					w.Header().Add(mapKeyToString(name), mapKeyToString(value))
+				}
+				continue
+			}
+			w.Header().Set(mapKeyToString(name), mapKeyToString(headers.Get(name)))
+		}
+	}
+	status := 200
+	if n, ok := response.Get(InternKeyword("status")).(Number); ok {
+		status = int(n.ToInt())
+	}
+	w.WriteHeader(status)
+	switch body := response.Get(InternKeyword("body")).(type) {
+	case Nil:
+	case String:
+		io.WriteString(w, string(body))
+	default:
+		io.WriteString(w, printString(body))
+	}
+}
+
+// serveHTTP runs a Lisp handler for one request, answering 500 if it fails
+func serveHTTP(handler Value, env *Environment, w http.ResponseWriter, r *http.Request) {
+	request, err := requestValue(r)
+	if err != nil {
+		http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	response, err := callHandler(handler, request, env)
+	if err != nil {
+		fmt.Fprintf(env.errorOutput(), "Error handling %s %s: %v\n", r.Method, r.URL.Path, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, response)
+}
+
+// callHandler calls a handler, turning panics into errors as jobs do
+func callHandler(handler Value, request *HashMap, env *Environment) (response *HashMap, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	result, err := callFunction(handler, []Value{request}, env)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeResponse(result)
+}
+
+// assoc returns a copy of m with the given keys set
+func assoc(m *HashMap, pairs ...Value) *HashMap {
+	result := NewHashMap()
+	for _, key := range m.keys {
+		result.Set(key, m.Get(key))
+	}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		result.Set(pairs[i], pairs[i+1])
+	}
+	return result
+}
+
+// route is one entry of a router: a method, or :any, and a path pattern
+// whose :name segments capture path parameters and whose last segment may
+// be *name to capture the rest of the path
+type route struct {
+	method   Keyword
+	segments []string
+	handler  Value
+}
+
+func parseRoute(value Value) (route, error) {
+	items, err := collectionToSlice(value)
+	if err != nil || len(items) != 3 {
+		return route{}, NewTypeError("router expects routes like [:get \"/users/:id\" handler], got %s", printString(value))
+	}
+	method, ok := items[0].(Keyword)
+	if !ok {
+		return route{}, NewTypeError("router expects a method keyword like :get or :any, got %s", printString(items[0]))
+	}
+	pattern, ok := items[1].(String)
+	if !ok || !strings.HasPrefix(string(pattern), "/") {
+		return route{}, NewTypeError("router expects a path pattern starting with /, got %s", printString(items[1]))
+	}
+	segments := splitPath(string(pattern))
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "*") && i != len(segments)-1 {
+			return route{}, NewTypeError("router pattern %s may only have * at the end", pattern)
+		}
+	}
+	if _, ok := items[2].(Callable); !ok {
+		return route{}, NewTypeError("router expects a handler function for %s, got %s", pattern, printString(items[2]))
+	}
+	return route{method: method, segments: segments, handler: items[2]}, nil
+}
+
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// match returns the path parameters if path fits the route's pattern
+func (rt route) match(path string) (*HashMap, bool) {
+	segments := splitPath(path)
+	params := NewHashMap()
+	for i, pattern := range rt.segments {
+		if name, ok := strings.CutPrefix(pattern, "*"); ok {
+			params.Set(InternKeyword(name), String(strings.Join(segments[min(i, len(segments)):], "/")))
+			return params, true
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		segment := segments[i]
+		if name, ok := strings.CutPrefix(pattern, ":"); ok {
+			params.Set(InternKeyword(name), String(segment))
+		} else if pattern != segment {
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(rt.segments)
+}
+
+// router dispatches to the first route matching the request, adding the
+// path parameters as :path-params and to :params. A path that matches
+// only with other methods gets a 405, and no match a 404.
+func router(routes []route) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "router",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("router handler expects 1 argument, got %d", len(args))
+			}
+			request, ok := args[0].(*HashMap)
+			if !ok {
+				return nil, NewTypeError("router handler expects a request map, got %s", TypeName(args[0]))
+			}
+			path, _ := request.Get(InternKeyword("path")).(String)
+			method, _ := request.Get(InternKeyword("method")).(Keyword)
+			var allowed []string
+			for _, rt := range routes {
+				pathParams, ok := rt.match(string(path))
+				if !ok {
+					continue
+				}
+				if rt.method != "any" && rt.method != method {
+					allowed = append(allowed, strings.ToUpper(string(rt.method)))
+					continue
+				}
+				params := NewHashMap()
+				if query, ok := request.Get(InternKeyword("params")).(*HashMap); ok {
+					params = assoc(query)
+				}
+				for _, key := range pathParams.keys {
+					params.Set(key, pathParams.Get(key))
+				}
+				return callFunction(rt.handler, []Value{assoc(request,
+					InternKeyword("path-params"), pathParams,
+					InternKeyword("params"), params,
+				)}, env)
+			}
+			if allowed != nil {
+				return NewHashMapWithPairs(
+					InternKeyword("status"), NewNumber(int64(405)),
+					InternKeyword("headers"), NewHashMapWithPairs(String("Allow"), String(strings.Join(allowed, ", "))),
+					InternKeyword("body"), String("Method Not Allowed"),
+				), nil
+			}
+			return Nil{}, nil
+		},
+	}
+}
+
+// wrapJSON decodes JSON request bodies into :json-body and encodes
+// collection response bodies as JSON
+func wrapJSON(handler Value) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-json",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-json", args)
+			if err != nil {
+				return nil, err
+			}
+			headers, _ := request.Get(InternKeyword("headers")).(*HashMap)
+			body, _ := request.Get(InternKeyword("body")).(String)
+			if headers != nil && strings.HasPrefix(mapKeyToString(headers.Get(String("content-type"))), "application/json") && body != "" {
+				data, err := JSONToValue([]byte(body))
+				if err != nil {
+					return NewHashMapWithPairs(
+						InternKeyword("status"), NewNumber(int64(400)),
+						InternKeyword("body"), String(fmt.Sprintf("Malformed JSON: %v", err)),
+					), nil
+				}
+				request = assoc(request, InternKeyword("json-body"), data)
+			}
+			result, err := callFunction(handler, []Value{request}, env)
+			if err != nil {
+				return nil, err
+			}
+			response, ok := result.(*HashMap)
+			if !ok {
+				return result, nil
+			}
+			switch response.Get(InternKeyword("body")).(type) {
+			case *HashMap, *Vector, *List, *Set, *LazySeq:
+			default:
+				return response, nil
+			}
+			data, err := ValueToJSON(response.Get(InternKeyword("body")))
+			if err != nil {
+				return nil, NewRuntimeError("wrap-json: %v", err)
+			}
+			headers, _ = response.Get(InternKeyword("headers")).(*HashMap)
+			if headers == nil {
+				headers = NewHashMap()
+			}
+			return assoc(response,
+				InternKeyword("headers"), assoc(headers, String("Content-Type"), String("application/json")),
+				InternKeyword("body"), String(data),
+			), nil
+		},
+	}
+}
+
+// wrapLogging logs each request at info level with its status and time
+func wrapLogging(handler Value) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-logging",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-logging", args)
+			if err != nil {
+				return nil, err
+			}
+			start := time.Now()
+			result, err := callFunction(handler, []Value{request}, env)
+			if err != nil {
+				return nil, err
+			}
+			status := NewNumber(int64(200))
+			switch v := result.(type) {
+			case Nil:
+				status = NewNumber(int64(404))
+			case *HashMap:
+				if n, ok := v.Get(InternKeyword("status")).(Number); ok {
+					status = n
+				}
+			}
+			method, _ := request.Get(InternKeyword("method")).(Keyword)
+			path, _ := request.Get(InternKeyword("path")).(String)
+			message := fmt.Sprintf("%s %s %s", strings.ToUpper(string(method)), string(path), status)
+			if _, err := logMessage(env, "info", []Value{String(message), NewHashMapWithPairs(
+				InternKeyword("method"), method,
+				InternKeyword("path"), path,
+				InternKeyword("status"), status,
+				InternKeyword("ms"), NewNumber(float64(time.Since(start).Microseconds())/1000),
+			)}); err != nil {
+				return nil, err
+			}
+			return result, nil
+		},
+	}
+}
+
+// wrapStatic serves GET and HEAD requests for files under dir, passing
+// anything else, and paths that are not files there, on to the handler
+func wrapStatic(handler Value, dir string) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-static",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-static", args)
+			if err != nil {
+				return nil, err
+			}
+			method, _ := request.Get(InternKeyword("method")).(Keyword)
+			requestPath, _ := request.Get(InternKeyword("path")).(String)
+			if method == "get" || method == "head" {
+				// Cleaning the path as absolute keeps it inside dir
+				file := filepath.Join(dir, filepath.FromSlash(path.Clean("/"+string(requestPath))))
+				if info, err := os.Stat(file); err == nil && info.IsDir() {
+					file = filepath.Join(file, "index.html")
+				}
+				if content, err := os.ReadFile(file); err == nil {
+					contentType := mime.TypeByExtension(filepath.Ext(file))
+					if contentType == "" {
+						contentType = http.DetectContentType(content)
+					}
+					return NewHashMapWithPairs(
+						InternKeyword("status"), NewNumber(int64(200)),
+						InternKeyword("headers"), NewHashMapWithPairs(String("Content-Type"), String(contentType)),
+						InternKeyword("body"), String(content),
+					), nil
+				}
+			}
+			return callFunction(handler, []Value{request}, env)
+		},
+	}
+}
+
+func requestArg(name string, args []Value) (*HashMap, error) {
+	if len(args) != 1 {
+		return nil, NewArityError("%s handler expects 1 argument, got %d", name, len(args))
+	}
+	request, ok := args[0].(*HashMap)
+	if !ok {
+		return nil, NewTypeError("%s handler expects a request map, got %s", name, TypeName(args[0]))
+	}
+	return request, nil
+}
+
+func handlerArg(name string, value Value) error {
+	if _, ok := value.(Callable); !ok {
+		return NewTypeError("%s expects a handler function, got %s", name, TypeName(value))
+	}
+	return nil
+}
+
+// setupHTTPOperations adds http-serve, http-stop, http-address, router and
+// the wrap-logging, wrap-json and wrap-static middleware
+func setupHTTPOperations(env *Environment) {
+	// (http-serve port handler) listens on localhost, or on all interfaces
+	// with (http-serve port handler :host "0.0.0.0"); port 0 picks a free one
+	env.Set(Intern("http-serve"), &BuiltinFunction{
+		Name: "http-serve",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 && len(args) != 4 {
+				return nil, NewArityError("http-serve expects 2 arguments and an optional :host, got %d", len(args))
+			}
+			port, ok := args[0].(Number)
+			if !ok || !port.IsInteger() || port.ToInt() < 0 || port.ToInt() > 65535 {
+				return nil, NewTypeError("http-serve expects a port number, got %s", printString(args[0]))
+			}
+			if err := handlerArg("http-serve", args[1]); err != nil {
+				return nil, err
+			}
+			host := "127.0.0.1"
+			if len(args) == 4 {
+				h, ok := args[3].(String)
+				if args[2] != InternKeyword("host") || !ok {
+					return nil, NewArityError("http-serve expects :host \"address\" as its only option")
+				}
+				host = string(h)
+			}
+			if err := checkCapabilities("http-serve", []Capability{CapabilityNet}, env); err != nil {
+				return nil, err
+			}
+			listener, err := net.Listen("tcp", net.JoinHostPort(host, fmt.Sprint(port.ToInt())))
+			if err != nil {
+				return nil, NewIOError("http-serve: %v", err)
+			}
+			handler := args[1]
+			server := &HTTPServer{addr: listener.Addr()}
+			server.server = &http.Server{
+				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					serveHTTP(handler, env, w, r)
+				}),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			go server.server.Serve(listener)
+			return server, nil
+		},
+	})
+
+	env.Set(Intern("http-stop"), &BuiltinFunction{
+		Name: "http-stop",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("http-stop expects 1 argument, got %d", len(args))
+			}
+			server, ok := args[0].(*HTTPServer)
+			if !ok {
+				return nil, NewTypeError("http-stop expects a server, got %s", TypeName(args[0]))
+			}
+			// Requests in progress get a few seconds to finish
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := server.server.Shutdown(ctx); err != nil {
+				return nil, NewIOError("http-stop: %v", err)
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("http-address"), &BuiltinFunction{
+		Name: "http-address",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("http-address expects 1 argument, got %d", len(args))
+			}
+			server, ok := args[0].(*HTTPServer)
+			if !ok {
+				return nil, NewTypeError("http-address expects a server, got %s", TypeName(args[0]))
+			}
+			return String(server.addr.String()), nil
+		},
+	})
+
+	// (router :get "/users/:id" show-user :post "/users" create-user), or
+	// (router routes) with a collection of [method pattern handler] routes
+	env.Set(Intern("router"), &BuiltinFunction{
+		Name: "router",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			var items []Value
+			switch {
+			case len(args) == 1:
+				var err error
+				if items, err = collectionToSlice(args[0]); err != nil {
+					return nil, NewTypeError("router expects a collection of routes, got %s", TypeName(args[0]))
+				}
+			case len(args) > 0 && len(args)%3 == 0:
+				for i := 0; i < len(args); i += 3 {
+					items = append(items, NewList(args[i:i+3]...))
+				}
+			default:
+				return nil, NewArityError("router expects a method, pattern and handler for each route, got %d arguments", len(args))
+			}
+			routes := make([]route, len(items))
+			for i, item := range items {
+				var err error
+				if routes[i], err = parseRoute(item); err != nil {
+					return nil, err
+				}
+			}
+			return router(routes), nil
+		},
+	})
+
+	middleware := map[string]func(Value) *BuiltinFunction{
+		"wrap-json":    wrapJSON,
+		"wrap-logging": wrapLogging,
+	}
+	for name, wrap := range middleware {
+		name, wrap := name, wrap
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				if err := handlerArg(name, args[0]); err != nil {
+					return nil, err
+				}
+				return wrap(args[0]), nil
+			},
+		})
+	}
+
+	env.Set(Intern("wrap-static"), &BuiltinFunction{
+		Name: "wrap-static",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("wrap-static expects 2 arguments, got %d", len(args))
+			}
+			if err := handlerArg("wrap-static", args[0]); err != nil {
+				return nil, err
+			}
+			dir, ok := args[1].(String)
+			if !ok {
+				return nil, NewTypeError("wrap-static expects a directory string, got %s", TypeName(args[1]))
+			}
+			if err := checkCapabilities("wrap-static", []Capability{CapabilityFS}, env); err != nil {
+				return nil, err
+			}
+			return wrapStatic(args[0], string(dir)), nil
+		},
+	})
+}
@@ -0,0 +1,619 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Forms, cookies and sessions are middleware over the request and response
+// maps of http.go. wrap-form decodes form bodies into :form-params,
+// wrap-cookies reads cookies into :cookies and writes the :cookies of a
+// response, and wrap-session keeps a :session map per browser in an atom.
+
+const (
+	// defaultSessionMaxAge is how long an unused session is kept when
+	// wrap-session has no :max-age
+	defaultSessionMaxAge = 24 * time.Hour
+
+	// sessionSweepInterval is how often the store is checked for expired
+	// sessions
+	sessionSweepInterval = time.Minute
+)
+
+// formParams decodes a URL-encoded or multipart request body, or returns
+// nil for other content types. Uploaded files become maps of :filename,
+// :content-type, :size and :content.
+func formParams(request *HashMap) (*HashMap, error) {
+	body, _ := request.Get(InternKeyword("body")).(String)
+	mediaType, params, err := mime.ParseMediaType(requestHeader(request, "content-type"))
+	if err != nil {
+		return nil, nil
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		return queryParams(values), nil
+	case "multipart/form-data":
+		form, err := multipart.NewReader(strings.NewReader(string(body)), params["boundary"]).ReadForm(maxRequestBody)
+		if err != nil {
+			return nil, err
+		}
+		defer form.RemoveAll()
+		fields := queryParams(form.Value)
+		for name, headers := range form.File {
+			files := make([]Value, 0, len(headers))
+			for _, header := range headers {
+				file, err := uploadedFile(header)
+				if err != nil {
+					return nil, err
+				}
+				files = append(files, file)
+			}
+			if len(files) == 1 {
+				fields.Set(InternKeyword(name), files[0])
+			} else {
+				fields.Set(InternKeyword(name), NewVector(files...))
+			}
+		}
+		return fields, nil
+	}
+	return nil, nil
+}
+
+func uploadedFile(header *multipart.FileHeader) (Value, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	return NewHashMapWithPairs(
+		InternKeyword("filename"), String(header.Filename),
+		InternKeyword("content-type"), String(header.Header.Get("Content-Type")),
+		InternKeyword("size"), NewNumber(header.Size),
+		InternKeyword("content"), String(content),
+	), nil
+}
+
+// requestHeader looks up a header of a request map by its lower-case name
+func requestHeader(request *HashMap, name string) string {
+	headers, ok := request.Get(InternKeyword("headers")).(*HashMap)
+	if !ok {
+		return ""
+	}
+	value, _ := headers.Get(String(name)).(String)
+	return string(value)
+}
+
+// requestCookies parses the Cookie header of a request map
+func requestCookies(request *HashMap) *HashMap {
+	cookies := NewHashMap()
+	header := requestHeader(request, "cookie")
+	if header == "" {
+		return cookies
+	}
+	for _, cookie := range (&http.Request{Header: http.Header{"Cookie": {header}}}).Cookies() {
+		cookies.Set(String(cookie.Name), String(cookie.Value))
+	}
+	return cookies
+}
+
+// responseCookie turns a cookie value from a response's :cookies, a string
+// or a map of :value and attributes, into an http.Cookie
+func responseCookie(name string, value Value) (*http.Cookie, error) {
+	cookie := &http.Cookie{Name: name}
+	attributes, ok := value.(*HashMap)
+	if !ok {
+		cookie.Value = mapKeyToString(value)
+		return cookie, nil
+	}
+	cookie.Value = mapKeyToString(attributes.Get(InternKeyword("value")))
+	for _, key := range attributes.keys {
+		v := attributes.Get(key)
+		switch key {
+		case InternKeyword("value"):
+		case InternKeyword("path"):
+			cookie.Path = mapKeyToString(v)
+		case InternKeyword("domain"):
+			cookie.Domain = mapKeyToString(v)
+		case InternKeyword("max-age"):
+			n, ok := v.(Number)
+			if !ok {
+				return nil, NewTypeError("cookie %s expects :max-age in seconds, got %s", name, printString(v))
+			}
+			cookie.MaxAge = int(n.ToInt())
+			if cookie.MaxAge == 0 {
+				cookie.MaxAge = -1 // :max-age 0 deletes the cookie, which http.Cookie spells -1
+			}
+		case InternKeyword("expires"):
+			inst, ok := v.(Inst)
+			if !ok {
+				return nil, NewTypeError("cookie %s expects :expires as an instant, got %s", name, printString(v))
+			}
+			cookie.Expires = inst.Time
+		case InternKeyword("http-only"):
+			cookie.HttpOnly = isTruthy(v)
+		case InternKeyword("secure"):
+			cookie.Secure = isTruthy(v)
+		case InternKeyword("same-site"):
+			switch v {
+			case InternKeyword("lax"):
+				cookie.SameSite = http.SameSiteLaxMode
+			case InternKeyword("strict"):
+				cookie.SameSite = http.SameSiteStrictMode
+			case InternKeyword("none"):
+				cookie.SameSite = http.SameSiteNoneMode
+			default:
+				return nil, NewTypeError("cookie %s expects :same-site :lax, :strict or :none, got %s", name, printString(v))
+			}
+		default:
+			return nil, NewTypeError("cookie %s has unknown attribute %s", name, printString(key))
+		}
+	}
+	return cookie, nil
+}
+
+// withSetCookie returns a copy of response with one more Set-Cookie header
+func withSetCookie(response *HashMap, cookie *http.Cookie) *HashMap {
+	headers, _ := response.Get(InternKeyword("headers")).(*HashMap)
+	if headers == nil {
+		headers = NewHashMap()
+	}
+	var cookies []Value
+	switch existing := headers.Get(String("Set-Cookie")).(type) {
+	case String:
+		cookies = append(cookies, existing)
+	case *Vector:
+		cookies = append(cookies, existing.elements...)
+	}
+	cookies = append(cookies, String(cookie.String()))
+	return assoc(response, InternKeyword("headers"), assoc(headers, String("Set-Cookie"), NewVector(cookies...)))
+}
+
+// signCookie appends an HMAC of value so unsignCookie can tell whether the
+// browser changed it
+func signCookie(secret, value string) string {
+	return value + "." + cookieSignature(secret, value)
+}
+
+func cookieSignature(secret, value string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unsignCookie returns the value of a signed cookie, or false if the
+// signature does not match
+func unsignCookie(secret, signed string) (string, bool) {
+	i := strings.LastIndex(signed, ".")
+	if i < 0 {
+		return "", false
+	}
+	value := signed[:i]
+	if !hmac.Equal([]byte(signed[i+1:]), []byte(cookieSignature(secret, value))) {
+		return "", false
+	}
+	return value, true
+}
+
+// sessionOptions configures wrap-session, and tracks when its sessions were
+// last used
+type sessionOptions struct {
+	store      *Atom // Map of session id to session map, changed in place
+	cookieName string
+	secret     string // Signs the session id cookie when set
+	maxAge     int
+
+	// Guarded by the store's lock
+	lastSeen map[string]time.Time
+	swept    time.Time
+	now      func() time.Time
+}
+
+func parseSessionOptions(args []Value) (*sessionOptions, error) {
+	opts := &sessionOptions{
+		store:      NewAtom(NewHashMap()),
+		cookieName: "session",
+		lastSeen:   make(map[string]time.Time),
+		now:        time.Now,
+	}
+	if len(args)%2 != 0 {
+		return nil, NewArityError("wrap-session expects options as keyword-value pairs")
+	}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch args[i] {
+		case InternKeyword("store"):
+			store, ok := value.(*Atom)
+			if !ok {
+				return nil, NewTypeError("wrap-session expects :store to be an atom, got %s", TypeName(value))
+			}
+			opts.store = store
+		case InternKeyword("cookie-name"):
+			name, ok := value.(String)
+			if !ok || name == "" {
+				return nil, NewTypeError("wrap-session expects :cookie-name to be a string, got %s", printString(value))
+			}
+			opts.cookieName = string(name)
+		case InternKeyword("secret"):
+			secret, ok := value.(String)
+			if !ok || secret == "" {
+				return nil, NewTypeError("wrap-session expects :secret to be a string, got %s", printString(value))
+			}
+			opts.secret = string(secret)
+		case InternKeyword("max-age"):
+			n, ok := value.(Number)
+			if !ok || !n.IsInteger() || n.ToInt() < 1 {
+				return nil, NewTypeError("wrap-session expects :max-age in seconds, got %s", printString(value))
+			}
+			opts.maxAge = int(n.ToInt())
+		default:
+			return nil, NewTypeError("wrap-session has no option %s", printString(args[i]))
+		}
+	}
+	return opts, nil
+}
+
+// sessionID reads the session id from the request's cookie, if it is valid
+func (opts *sessionOptions) sessionID(request *HashMap) string {
+	cookie, ok := requestCookies(request).Get(String(opts.cookieName)).(String)
+	if !ok {
+		return ""
+	}
+	if opts.secret == "" {
+		return string(cookie)
+	}
+	id, ok := unsignCookie(opts.secret, string(cookie))
+	if !ok {
+		return ""
+	}
+	return id
+}
+
+func (opts *sessionOptions) cookie(id string) *http.Cookie {
+	value := id
+	if opts.secret != "" {
+		value = signCookie(opts.secret, id)
+	}
+	return &http.Cookie{Name: opts.cookieName, Value: value, Path: "/", HttpOnly: true, SameSite: http.SameSiteLaxMode, MaxAge: opts.maxAge}
+}
+
+// lifetime is how long a session is kept after it was last used
+func (opts *sessionOptions) lifetime() time.Duration {
+	if opts.maxAge > 0 {
+		return time.Duration(opts.maxAge) * time.Second
+	}
+	return defaultSessionMaxAge
+}
+
+// withSessions calls f with the store's map, locked, after removing the
+// sessions that expired; f reports whether it changed the map
+func (opts *sessionOptions) withSessions(env *Environment, f func(sessions *HashMap, now time.Time) bool) error {
+	return opts.store.modify(func(value Value) (bool, error) {
+		sessions, ok := value.(*HashMap)
+		if !ok {
+			return false, NewTypeError("wrap-session expects its store to hold a map, got %s", printString(value))
+		}
+		now := opts.now()
+		swept := opts.sweep(sessions, now)
+		return f(sessions, now) || swept, nil
+	}, env)
+}
+
+// sweep removes the sessions unused for longer than their lifetime, at most
+// once per sessionSweepInterval. Sessions put in the store by other code
+// count as used when first seen.
+func (opts *sessionOptions) sweep(sessions *HashMap, now time.Time) bool {
+	if now.Sub(opts.swept) < sessionSweepInterval {
+		return false
+	}
+	opts.swept = now
+	live := make(map[string]time.Time, sessions.Count())
+	for _, key := range sessions.keys {
+		id, _ := key.(String)
+		seen, ok := opts.lastSeen[string(id)]
+		if !ok {
+			seen = now
+		}
+		if now.Sub(seen) <= opts.lifetime() {
+			live[string(id)] = seen
+		}
+	}
+	opts.lastSeen = live
+	if len(live) == sessions.Count() {
+		return false
+	}
+	sessions.removeIf(func(key Value) bool {
+		id, _ := key.(String)
+		_, ok := live[string(id)]
+		return !ok
+	})
+	return true
+}
+
+// load returns the session stored under id, marking it used, or false if
+// there is none or it expired
+func (opts *sessionOptions) load(id string, env *Environment) (session Value, found bool, err error) {
+	err = opts.withSessions(env, func(sessions *HashMap, now time.Time) bool {
+		if !sessions.ContainsKey(String(id)) {
+			return false
+		}
+		if seen, ok := opts.lastSeen[id]; ok && now.Sub(seen) > opts.lifetime() {
+			delete(opts.lastSeen, id)
+			sessions.removeIf(func(key Value) bool { return key == String(id) })
+			return true
+		}
+		opts.lastSeen[id] = now
+		session, found = sessions.Get(String(id)), true
+		return false
+	})
+	return session, found, err
+}
+
+// update changes the store's entry for id, removing it when session is nil
+func (opts *sessionOptions) update(id string, session Value, env *Environment) error {
+	return opts.withSessions(env, func(sessions *HashMap, now time.Time) bool {
+		if _, ok := session.(Nil); ok {
+			delete(opts.lastSeen, id)
+			sessions.removeIf(func(key Value) bool { return key == String(id) })
+		} else {
+			opts.lastSeen[id] = now
+			sessions.Set(String(id), session)
+		}
+		return true
+	})
+}
+
+func newSessionID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// wrapSession gives the handler the request's :session map. A response with
+// a :session map saves it, creating the session and its cookie if needed;
+// a response with :session nil ends the session.
+func wrapSession(handler Value, opts *sessionOptions) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-session",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-session", args)
+			if err != nil {
+				return nil, err
+			}
+			id := opts.sessionID(request)
+			var session Value = NewHashMap()
+			if id != "" {
+				stored, found, err := opts.load(id, env)
+				if err != nil {
+					return nil, err
+				}
+				if found {
+					session = stored
+				} else {
+					id = "" // Expired or unknown, so a new session gets a new id
+				}
+			}
+			request = assoc(request, InternKeyword("session"), session)
+			if id != "" {
+				request.Set(InternKeyword("session-id"), String(id))
+			}
+
+			result, err := callFunction(handler, []Value{request}, env)
+			if err != nil {
+				return nil, err
+			}
+			response, ok := result.(*HashMap)
+			if !ok || !response.ContainsKey(InternKeyword("session")) {
+				return result, nil
+			}
+			updated := response.Get(InternKeyword("session"))
+			switch updated.(type) {
+			case Nil:
+				if id == "" {
+					return result, nil
+				}
+				if err := opts.update(id, updated, env); err != nil {
+					return nil, err
+				}
+				expired := opts.cookie("")
+				expired.MaxAge = -1
+				return withSetCookie(response, expired), nil
+			case *HashMap:
+			default:
+				return nil, NewTypeError("wrap-session expects the response :session to be a map or nil, got %s", printString(updated))
+			}
+			if id == "" {
+				id = newSessionID()
+			}
+			if err := opts.update(id, updated, env); err != nil {
+				return nil, err
+			}
+			return withSetCookie(response, opts.cookie(id)), nil
+		},
+	}
+}
+
+// wrapForm adds the decoded form of URL-encoded and multipart requests as
+// :form-params, and to :params
+func wrapForm(handler Value) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-form",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-form", args)
+			if err != nil {
+				return nil, err
+			}
+			form, err := formParams(request)
+			if err != nil {
+				return NewHashMapWithPairs(
+					InternKeyword("status"), NewNumber(int64(400)),
+					InternKeyword("body"), String("Malformed form: "+err.Error()),
+				), nil
+			}
+			if form != nil {
+				params := NewHashMap()
+				if existing, ok := request.Get(InternKeyword("params")).(*HashMap); ok {
+					params = assoc(existing)
+				}
+				for _, key := range form.keys {
+					params.Set(key, form.Get(key))
+				}
+				request = assoc(request, InternKeyword("form-params"), form, InternKeyword("params"), params)
+			}
+			return callFunction(handler, []Value{request}, env)
+		},
+	}
+}
+
+// wrapCookies adds the request's cookies as :cookies, a map of name to
+// value, and sets the cookies in a response's :cookies
+func wrapCookies(handler Value) *BuiltinFunction {
+	return &BuiltinFunction{
+		Name: "wrap-cookies",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			request, err := requestArg("wrap-cookies", args)
+			if err != nil {
+				return nil, err
+			}
+			result, err := callFunction(handler, []Value{assoc(request, InternKeyword("cookies"), requestCookies(request))}, env)
+			if err != nil {
+				return nil, err
+			}
+			response, ok := result.(*HashMap)
+			if !ok {
+				return result, nil
+			}
+			cookies, ok := response.Get(InternKeyword("cookies")).(*HashMap)
+			if !ok {
+				return response, nil
+			}
+			for _, name := range cookies.keys {
+				cookie, err := responseCookie(mapKeyToString(name), cookies.Get(name))
+				if err != nil {
+					return nil, err
+				}
+				if err := cookie.Valid(); err != nil {
+					return nil, NewTypeError("wrap-cookies: %v", err)
+				}
+				response = withSetCookie(response, cookie)
+			}
+			return response, nil
+		},
+	}
+}
+
+// setupSessionOperations adds wrap-form, wrap-cookies, wrap-session,
+// form-decode, sign-cookie and unsign-cookie
+func setupSessionOperations(env *Environment) {
+	middleware := map[string]func(Value) *BuiltinFunction{
+		"wrap-form":    wrapForm,
+		"wrap-cookies": wrapCookies,
+	}
+	for name, wrap := range middleware {
+		name, wrap := name, wrap
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				if err := handlerArg(name, args[0]); err != nil {
+					return nil, err
+				}
+				return wrap(args[0]), nil
+			},
+		})
+	}
+
+	// (wrap-session handler :store sessions-atom :secret "..." :cookie-name
+	// "sid" :max-age 3600), where every option may be left out
+	env.Set(Intern("wrap-session"), &BuiltinFunction{
+		Name: "wrap-session",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 1 {
+				return nil, NewArityError("wrap-session expects at least 1 argument, got %d", len(args))
+			}
+			if err := handlerArg("wrap-session", args[0]); err != nil {
+				return nil, err
+			}
+			opts, err := parseSessionOptions(args[1:])
+			if err != nil {
+				return nil, err
+			}
+			return wrapSession(args[0], opts), nil
+		},
+	})
+
+	env.Set(Intern("form-decode"), &BuiltinFunction{
+		Name: "form-decode",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("form-decode expects 1 argument, got %d", len(args))
+			}
+			text, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("form-decode expects a string, got %s", TypeName(args[0]))
+			}
+			values, err := url.ParseQuery(string(text))
+			if err != nil {
+				return nil, NewRuntimeError("form-decode: %v", err)
+			}
+			return queryParams(values), nil
+		},
+	})
+
+	env.Set(Intern("sign-cookie"), &BuiltinFunction{
+		Name: "sign-cookie",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			secret, value, err := cookieSigningArgs("sign-cookie", args)
+			if err != nil {
+				return nil, err
+			}
+			return String(signCookie(secret, value)), nil
+		},
+	})
+
+	// The value of a cookie made with sign-cookie, or nil if it was changed
+	env.Set(Intern("unsign-cookie"), &BuiltinFunction{
+		Name: "unsign-cookie",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			secret, signed, err := cookieSigningArgs("unsign-cookie", args)
+			if err != nil {
+				return nil, err
+			}
+			if value, ok := unsignCookie(secret, signed); ok {
+				return String(value), nil
+			}
+			return Nil{}, nil
+		},
+	})
+}
+
+func cookieSigningArgs(name string, args []Value) (string, string, error) {
+	if len(args) != 2 {
+		return "", "", NewArityError("%s expects 2 arguments, got %d", name, len(args))
+	}
+	secret, ok1 := args[0].(String)
+	value, ok2 := args[1].(String)
+	if !ok1 || !ok2 || secret == "" {
+		return "", "", NewTypeError("%s expects a secret and a value string", name)
+	}
+	return string(secret), string(value), nil
+}
@@ -0,0 +1,191 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPFormsAndCookies(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn req [headers body] (hash-map :method :post :path "/" :params (hash-map :page "1") :headers headers :body body))
+		(def show-form (wrap-form (fn [r] (hash-map :form (:form-params r) :params (:params r)))))
+		(def show-cookies (wrap-cookies (fn [r] (hash-map :body (:cookies r)
+		                                                  :cookies (hash-map "theme" "dark"
+		                                                                     "token" (hash-map :value "abc" :http-only true :max-age 60 :same-site :strict))))))
+		(def bad-session (wrap-session (constantly (hash-map :session 5))))
+		(defn set-cookie [attrs] ((wrap-cookies (fn [r] (hash-map :cookies (hash-map "a" attrs)))) (req (hash-map) "")))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(form-decode "name=Ada+L&tags=a&tags=b&empty=")`, `{:empty "" :name "Ada L" :tags ["a" "b"]}`},
+		{`(show-form (req (hash-map "content-type" "application/x-www-form-urlencoded") "name=Ada&page=2"))`,
+			`{:form {:name "Ada" :page "2"} :params {:page "2" :name "Ada"}}`},
+		{`(:form (show-form (req (hash-map "content-type" "text/plain") "name=Ada")))`, `nil`},
+		{`(:status ((wrap-form identity) (req (hash-map "content-type" "application/x-www-form-urlencoded") "%zz")))`, `400`},
+
+		{`(:body (show-cookies (req (hash-map "cookie" "a=1; b=two") "")))`, `{"a" "1" "b" "two"}`},
+		{`(get (:headers (show-cookies (req (hash-map) ""))) "Set-Cookie")`,
+			`["theme=dark" "token=abc; Max-Age=60; HttpOnly; SameSite=Strict"]`},
+
+		{`(sign-cookie "s3cret" "user-7")`, `"user-7.GVUammUSgxIAnQ2xLUuPO9irE7VQ5SSweH9L98KjYSY"`},
+		{`(unsign-cookie "s3cret" (sign-cookie "s3cret" "user-7"))`, `"user-7"`},
+		{`(unsign-cookie "s3cret" "user-8.GVUammUSgxIAnQ2xLUuPO9irE7VQ5SSweH9L98KjYSY")`, `nil`},
+		{`(unsign-cookie "other" (sign-cookie "s3cret" "user-7"))`, `nil`},
+		{`(unsign-cookie "s3cret" "unsigned")`, `nil`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(set-cookie (hash-map :same-site :sometimes))`: ":same-site :lax",
+		`(set-cookie (hash-map :colour 1))`:             "unknown attribute :colour",
+		`(wrap-session identity :store 5)`:              ":store to be an atom",
+		`(wrap-session identity :ttl 5)`:                "no option :ttl",
+		`(wrap-session identity :secret)`:               "keyword-value pairs",
+		`(sign-cookie "" "value")`:                      "a secret and a value",
+		`(bad-session (req (hash-map) ""))`:             "map or nil",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
+
+func TestHTTPSessionsAndUploads(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def sessions (atom (hash-map)))
+		(defn visit [req]
+		  (let [n (+ 1 (get (:session req) :visits 0))]
+		    (hash-map :body (str "visit " n) :session (assoc (:session req) :visits n))))
+		(def app (router :get "/visit" visit
+		                 :post "/logout" (fn [req] (hash-map :body "bye" :session nil))
+		                 :post "/upload" (fn [req] (let [file (:file (:params req))]
+		                                             (str (:title (:params req)) ": " (:filename file) " " (:size file) " " (:content file))))))
+		(def server (http-serve 0 (wrap-session (wrap-form app) :store sessions :secret "s3cret")))`)
+	defer evalAll(t, env, "(http-stop server)")
+	base := "http://" + string(evalAll(t, env, "(http-address server)").(String))
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	send := func(method, path, contentType string, body io.Reader) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, base+path, body)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return string(data)
+	}
+
+	for _, expected := range []string{"visit 1", "visit 2", "visit 3"} {
+		if body := send("GET", "/visit", "", nil); body != expected {
+			t.Errorf("Expected %q, got %q", expected, body)
+		}
+	}
+	if count := evalAll(t, env, "(count @sessions)"); count.String() != "1" {
+		t.Errorf("Expected one stored session, got %s", count)
+	}
+
+	send("POST", "/logout", "", nil)
+	if count := evalAll(t, env, "(count @sessions)"); count.String() != "0" {
+		t.Errorf("Expected logout to remove the session, got %s", count)
+	}
+	if body := send("GET", "/visit", "", nil); body != "visit 1" {
+		t.Errorf("Expected a new session after logout, got %q", body)
+	}
+
+	// A cookie whose signature does not match starts a new session
+	forged, _ := http.NewRequest("GET", base+"/visit", nil)
+	forged.AddCookie(&http.Cookie{Name: "session", Value: "guessed.c2lnbmF0dXJl"})
+	resp, err := http.DefaultClient.Do(forged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(data) != "visit 1" {
+		t.Errorf("Expected a forged cookie to be ignored, got %q", data)
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("title", "Notes")
+	part, _ := writer.CreateFormFile("file", "notes.txt")
+	part.Write([]byte("hello"))
+	writer.Close()
+	if body := send("POST", "/upload", writer.FormDataContentType(), &form); body != "Notes: notes.txt 5 hello" {
+		t.Errorf("Unexpected upload response %q", body)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def sessions (atom (hash-map)))
+		(def changes (atom 0))
+		(add-watch sessions :count (fn [k r old new] (swap! changes + 1)))`)
+	opts, err := parseSessionOptions([]Value{InternKeyword("store"), evalAll(t, env, "sessions"), InternKeyword("max-age"), NewNumber(int64(60))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Unix(0, 0)
+	opts.now = func() time.Time { return clock }
+
+	visit := NewHashMapWithPairs(InternKeyword("n"), NewNumber(int64(1)))
+	for _, id := range []string{"a", "b"} {
+		if err := opts.update(id, visit, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stored := evalAll(t, env, "@sessions")
+	if got := evalAll(t, env, "(list (count @sessions) @changes)").String(); got != "(2 2)" {
+		t.Errorf("Expected two sessions, each saved with a notification, got %s", got)
+	}
+
+	// a stays in use; b is left alone past its max-age
+	clock = clock.Add(50 * time.Second)
+	if _, found, _ := opts.load("a", env); !found {
+		t.Error("Expected session a to be found")
+	}
+	clock = clock.Add(50 * time.Second)
+	if _, found, _ := opts.load("b", env); found {
+		t.Error("Expected session b to have expired")
+	}
+	if _, found, _ := opts.load("a", env); !found {
+		t.Error("Expected session a, used 50 seconds ago, to be kept")
+	}
+
+	// Sweeps remove expired sessions nobody asks for
+	clock = clock.Add(2 * time.Minute)
+	if err := opts.update("c", visit, env); err != nil {
+		t.Fatal(err)
+	}
+	if got := evalAll(t, env, "(keys @sessions)").String(); got != `("c")` {
+		t.Errorf("Expected only the new session to remain, got %s", got)
+	}
+	// The map is changed in place rather than copied per request
+	if evalAll(t, env, "@sessions") != stored {
+		t.Error("Expected the store to keep the same map")
+	}
+}
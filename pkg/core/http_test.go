@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHTTPRouter(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def app (router :get "/users/:id" (fn [req] (str "user " (get (:params req) :id)))
+		                 :post "/users" (fn [req] (hash-map :status 201 :body (:body req)))
+		                 :get "/files/*path" (fn [req] (get (:path-params req) :path))
+		                 :any "/health" (fn [req] "ok")))
+		(defn req [method path] (hash-map :method method :path path :params (hash-map :q "x") :headers (hash-map)))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(app (req :get "/users/42"))`, `"user 42"`},
+		{`(app (req :get "/users/42/"))`, `"user 42"`},
+		{`(:status (app (assoc (req :post "/users") :body "new")))`, `201`},
+		{`(app (req :get "/files/css/site.css"))`, `"css/site.css"`},
+		{`(app (req :get "/files"))`, `""`},
+		{`(app (req :delete "/health"))`, `"ok"`},
+		{`(app (req :get "/nowhere"))`, `nil`},
+		{`(app (req :get "/users/42/posts"))`, `nil`},
+		{`(:status (app (req :delete "/users/42")))`, `405`},
+		{`(get (:headers (app (req :delete "/users/42"))) "Allow")`, `"GET"`},
+
+		// Path parameters join the query parameters in :params
+		{`((router :get "/a/:id" (fn [r] (:params r))) (req :get "/a/1"))`, `{:q "x" :id "1"}`},
+
+		// Routes can also be built up as a collection
+		{`((router (list (list :get "/b" (constantly "b")))) (req :get "/b"))`, `"b"`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(router :get "users" identity)`:      "starting with /",
+		`(router :get "/a/*rest/b" identity)`: "only have * at the end",
+		`(router "GET" "/a" identity)`:        "method keyword",
+		`(router :get "/a" 5)`:                "expects a handler function",
+		`(router :get "/a")`:                  "a method, pattern and handler",
+		`(router (list (list :get "/a")))`:    "routes like",
+		`(wrap-json 5)`:                       "expects a handler function",
+		`(http-serve 70000 identity)`:         "expects a port number",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
+
+func TestHTTPServe(t *testing.T) {
+	dir := t.TempDir()
+	public := filepath.Join(dir, "public")
+	os.Mkdir(public, 0755)
+	os.WriteFile(filepath.Join(public, "index.html"), []byte("<h1>home</h1>"), 0644)
+	os.WriteFile(filepath.Join(public, "site.css"), []byte("body {}"), 0644)
+	logFile := filepath.Join(dir, "access.log")
+
+	env := NewCoreEnvironment()
+	evalAll(t, env, fmt.Sprintf(`
+		(log/set-sinks! (list (hash-map :type :file :path %q)))
+		(def app (router :get "/users/:id" (fn [req] (hash-map :body (hash-map :id (:id (:params req)) :sort (:sort (:params req)))))
+		                 :post "/echo" (fn [req] (hash-map :status 200 :body (:json-body req)))
+		                 :get "/tags" (fn [req] (str (get (:query-params req) :tag)))
+		                 :get "/boom" (fn [req] (throw "boom"))))
+		(def server (http-serve 0 (wrap-logging (wrap-json (wrap-static app %q)))))`, logFile, public))
+	defer evalAll(t, env, "(http-stop server) (log/set-sinks! (list (hash-map :type :stderr)))")
+	base := "http://" + evalAll(t, env, "(http-address server)").(String)
+
+	fetch := func(method, path, contentType, body string) (int, string, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, string(base)+path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, resp.Header.Get("Content-Type"), string(data)
+	}
+
+	tests := []struct {
+		method, path, contentType, body string
+		status                          int
+		responseType, response          string
+	}{
+		{"GET", "/users/7?sort=name", "", "", 200, "application/json", `{"id":"7","sort":"name"}`},
+		{"POST", "/echo", "application/json", `{"name":"ada","langs":["lisp"]}`, 200, "application/json", `{"langs":["lisp"],"name":"ada"}`},
+		{"POST", "/echo", "application/json", `{"name":`, 400, "", "Malformed JSON"},
+		{"GET", "/tags?tag=a&tag=b", "", "", 200, "text/plain; charset=utf-8", `["a" "b"]`},
+		{"GET", "/site.css", "", "", 200, "text/css; charset=utf-8", "body {}"},
+		{"GET", "/", "", "", 200, "text/html; charset=utf-8", "<h1>home</h1>"},
+		{"GET", "/../access.log", "", "", 404, "", "Not Found"},
+		{"GET", "/missing", "", "", 404, "", "Not Found"},
+		{"GET", "/boom", "", "", 500, "", "Internal Server Error"},
+	}
+	for _, test := range tests {
+		status, contentType, body := fetch(test.method, test.path, test.contentType, test.body)
+		if status != test.status || !strings.Contains(body, test.response) {
+			t.Errorf("%s %s: expected %d %q, got %d %q", test.method, test.path, test.status, test.response, status, body)
+		}
+		if test.responseType != "" && contentType != test.responseType {
+			t.Errorf("%s %s: expected Content-Type %q, got %q", test.method, test.path, test.responseType, contentType)
+		}
+	}
+
+	log, _ := os.ReadFile(logFile)
+	if !strings.Contains(string(log), "GET /users/7 200") || !strings.Contains(string(log), "GET /missing 404") {
+		t.Errorf("Expected requests in the access log, got:\n%s", log)
+	}
+}
@@ -0,0 +1,278 @@
+package core
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// imageMagic starts every image file, ahead of the gzipped gob entries
+const imageMagic = "GOLISP-IMAGE 1\n"
+
+// imageEntry is one user definition in an image, kept as a form that
+// recreates it when evaluated
+type imageEntry struct {
+	Name   string
+	Source string
+}
+
+// imageSkipped are bindings the CLI and REPL set up for each run, which an
+// image should not override
+var imageSkipped = map[Symbol]bool{
+	"*1": true, "*2": true, "*3": true, "*e": true,
+	"*command-line-args*": true, "*load-path*": true, "*file*": true, "*dir*": true,
+	"*print-length*": true, "*print-level*": true,
+}
+
+// SaveImage writes the definitions made on top of the core environment and
+// standard library to filename: functions and macros as source forms, and
+// data and atoms holding data as literals. Loading an image evaluates these
+// forms without reading, resolving or running the files that made them.
+// It returns the names of bindings that could not be written, such as
+// channels, servers or closures over them.
+func SaveImage(env *Environment, filename string) (saved int, skipped []string, err error) {
+	root := env.root()
+	var macros, data, functions []imageEntry
+	for name := range root.snapshot() {
+		entry, value, ok := lookupFunction(name, root)
+		if !ok || entry.category != "user" || imageSkipped[name] {
+			continue
+		}
+		source, ok := imageSource(name, value, root)
+		switch {
+		case !ok:
+			skipped = append(skipped, string(name))
+		case source == "":
+		default:
+			item := imageEntry{Name: string(name), Source: source}
+			switch value.(type) {
+			case *Macro:
+				macros = append(macros, item)
+			case *UserFunction:
+				functions = append(functions, item)
+			default:
+				data = append(data, item)
+			}
+		}
+	}
+	// Macros and data go first, so strict checks of function bodies find them
+	var entries []imageEntry
+	for _, group := range [][]imageEntry{macros, data, functions} {
+		sort.Slice(group, func(i, j int) bool { return group[i].Name < group[j].Name })
+		entries = append(entries, group...)
+	}
+	sort.Strings(skipped)
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return 0, nil, NewIOError("failed to save image %s: %v", filename, err)
+	}
+	defer file.Close()
+	out := bufio.NewWriter(file)
+	out.WriteString(imageMagic)
+	zw := gzip.NewWriter(out)
+	if err := gob.NewEncoder(zw).Encode(entries); err != nil {
+		return 0, nil, NewIOError("failed to save image %s: %v", filename, err)
+	}
+	if err := zw.Close(); err != nil {
+		return 0, nil, NewIOError("failed to save image %s: %v", filename, err)
+	}
+	if err := out.Flush(); err != nil {
+		return 0, nil, NewIOError("failed to save image %s: %v", filename, err)
+	}
+	return len(entries), skipped, nil
+}
+
+// LoadImage evaluates the definitions saved by SaveImage in env's root,
+// returning how many there were
+func LoadImage(env *Environment, filename string) (int, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, NewIOError("failed to read image %s: %v", filename, err)
+	}
+	defer file.Close()
+
+	in := bufio.NewReader(file)
+	magic := make([]byte, len(imageMagic))
+	if _, err := io.ReadFull(in, magic); err != nil || string(magic) != imageMagic {
+		return 0, NewIOError("%s is not a GoLisp image", filename)
+	}
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return 0, NewIOError("failed to read image %s: %v", filename, err)
+	}
+	var entries []imageEntry
+	if err := gob.NewDecoder(zr).Decode(&entries); err != nil {
+		return 0, NewIOError("failed to read image %s: %v", filename, err)
+	}
+
+	root := env.root()
+	for _, entry := range entries {
+		form, err := root.ReadString(entry.Source)
+		if err == nil {
+			_, err = Eval(form, root)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to load %s from image %s: %v", entry.Name, filename, err)
+		}
+	}
+	return len(entries), nil
+}
+
+// imageSource renders the binding of name as a form that recreates it. It
+// returns "" for builtins that are already bound under their own name, and
+// false for values that cannot be written.
+func imageSource(name Symbol, value Value, root *Environment) (string, bool) {
+	switch v := value.(type) {
+	case *BuiltinFunction:
+		// Aliases like (def plus +) can refer to the builtin by name
+		bound, err := root.Get(Intern(v.Name))
+		if err != nil || !sameValue(bound, v) {
+			return "", false
+		}
+		if Symbol(v.Name) == name {
+			return "", true
+		}
+		return fmt.Sprintf("(def %s %s)", name, v.Name), true
+
+	case *UserFunction:
+		if v.Env == root {
+			if v.Name == name {
+				return definitionSource("defn", name, v.Doc, v.Meta, v.Params, v.Body), true
+			}
+			return fmt.Sprintf("(def %s (fn %s %s))", name, printString(v.Params), printString(v.Body)), true
+		}
+		// A closure is rebuilt inside a let of the data it closed over
+		fn := fmt.Sprintf("(fn %s %s)", printString(v.Params), printString(v.Body))
+		closure, ok := closureSource(v.Env, root, fn)
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("(def %s %s)", name, closure), true
+
+	case *Macro:
+		if v.Env != root || v.Name != name {
+			return "", false
+		}
+		return definitionSource("defmacro", name, v.Doc, v.Meta, v.Params, v.Body), true
+
+	case *Atom:
+		literal, ok := dataSource(v.Deref())
+		if !ok {
+			return "", false
+		}
+		return fmt.Sprintf("(def %s (atom %s))", name, literal), true
+	}
+
+	literal, ok := dataSource(value)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("(def %s %s)", name, literal), true
+}
+
+// definitionSource renders a defn or defmacro form
+func definitionSource(special string, name Symbol, doc string, meta *HashMap, params *List, body Value) string {
+	parts := []string{special, string(name)}
+	if doc != "" {
+		parts = append(parts, String(doc).String())
+	}
+	if meta != nil {
+		parts = append(parts, printString(meta))
+	}
+	parts = append(parts, printString(params), printString(body))
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// closureSource wraps fn in a let binding the locals of every environment
+// between env and root, outermost first
+func closureSource(env, root *Environment, fn string) (string, bool) {
+	var scopes []*Environment
+	for scope := env; scope != root; scope = scope.parent {
+		if scope == nil {
+			return "", false
+		}
+		scopes = append([]*Environment{scope}, scopes...)
+	}
+
+	var bindings []string
+	for _, scope := range scopes {
+		locals := scope.snapshot()
+		names := make([]string, 0, len(locals))
+		for name := range locals {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			literal, ok := dataSource(locals[Symbol(name)])
+			if !ok {
+				return "", false
+			}
+			bindings = append(bindings, name, literal)
+		}
+	}
+	return fmt.Sprintf("(let [%s] %s)", strings.Join(bindings, " "), fn), true
+}
+
+// dataSource renders value as a quoted literal, if reading it back gives
+// an equal value
+func dataSource(value Value) (string, bool) {
+	printed := printString(value)
+	read, err := ReadString(printed)
+	if err != nil || !valuesEqual(read, value) {
+		return "", false
+	}
+	return "(quote " + printed + ")", true
+}
+
+// setupImageOperations adds save-image and load-image
+func setupImageOperations(env *Environment) {
+	// (save-image "app.img") returns the number of definitions written,
+	// warning about any that could not be
+	env.Set(Intern("save-image"), &BuiltinFunction{
+		Name: "save-image",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			filename, err := stringArg("save-image", args)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkCapabilities("save-image", []Capability{CapabilityFS}, env); err != nil {
+				return nil, err
+			}
+			saved, skipped, err := SaveImage(env, filename)
+			if err != nil {
+				return nil, err
+			}
+			if len(skipped) > 0 {
+				msg := fmt.Sprintf("save-image left out %s, which cannot be written to an image", strings.Join(skipped, ", "))
+				if err := warnIn(env, msg, Position{}); err != nil {
+					return nil, err
+				}
+			}
+			return NewNumber(int64(saved)), nil
+		},
+	})
+
+	env.Set(Intern("load-image"), &BuiltinFunction{
+		Name: "load-image",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			filename, err := stringArg("load-image", args)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkCapabilities("load-image", []Capability{CapabilityFS}, env); err != nil {
+				return nil, err
+			}
+			loaded, err := LoadImage(env, filename)
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(loaded)), nil
+		},
+	})
+}
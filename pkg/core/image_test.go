@@ -0,0 +1,62 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImageSaveAndLoad(t *testing.T) {
+	env := NewCoreEnvironment()
+	image := filepath.Join(t.TempDir(), "app.img")
+	evalAll(t, env, `
+		(def config (hash-map :port 8080 :tags (list "a" "b")))
+		(def counter (atom 3))
+		(def plus +)
+		(defn greet "Greets someone" [who] (str "hello " who))
+		(defmacro unless-zero [x body] (list 'if (list '= x 0) nil body))
+		(def add-rate (let [rate 2] (fn [x] (* x rate))))
+		(def greet-first (fn [names] (greet (first names))))
+		(def cache (lru-cache 2))`)
+
+	env.SetErrorOutput(&strings.Builder{})
+	saved, skipped, err := SaveImage(env, image)
+	if err != nil {
+		t.Fatalf("SaveImage failed: %v", err)
+	}
+	if saved != 7 || strings.Join(skipped, ",") != "cache" {
+		t.Errorf("Expected 7 saved definitions and cache skipped, got %d and %v", saved, skipped)
+	}
+
+	loaded := NewCoreEnvironment()
+	if count, err := LoadImage(loaded, image); err != nil || count != 7 {
+		t.Fatalf("LoadImage returned %d, %v", count, err)
+	}
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(:port config)`, `8080`},
+		{`(:tags config)`, `("a" "b")`},
+		{`@counter`, `3`},
+		{`(plus 1 2)`, `3`},
+		{`(greet "Ada")`, `"hello Ada"`},
+		{`(:doc (function-help "greet"))`, `"Greets someone"`},
+		{`(unless-zero 1 :ok)`, `:ok`},
+		{`(add-rate 5)`, `10`},
+		{`(greet-first (list "Bo"))`, `"hello Bo"`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, loaded, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	if err := os.WriteFile(image, []byte("(def x 1)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadImage(loaded, image); err == nil || !strings.Contains(err.Error(), "not a GoLisp image") {
+		t.Errorf("Expected an error for a file that is not an image, got %v", err)
+	}
+}
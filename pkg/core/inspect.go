@@ -0,0 +1,233 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readInteractiveLine reads a line of user input for env's interactive
+// builtins. A running REPL reads it through readline instead, so as not to
+// race it for stdin.
+func (env *Environment) readInteractiveLine(prompt string) (string, error) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	readLine := state.readLine
+	state.mu.Unlock()
+	if readLine != nil {
+		return readLine(prompt)
+	}
+
+	fmt.Print(prompt)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// inspectFrame is one level of the inspector's navigation stack
+type inspectFrame struct {
+	label string
+	value Value
+	page  int
+}
+
+// inspectEntry is a single navigable child of a collection
+type inspectEntry struct {
+	key   Value
+	value Value
+}
+
+// Inspector is a navigable view of nested data structures
+type Inspector struct {
+	stack    []inspectFrame
+	PageSize int
+}
+
+// NewInspector creates an inspector focused on value
+func NewInspector(value Value) *Inspector {
+	return &Inspector{
+		stack:    []inspectFrame{{label: "root", value: value}},
+		PageSize: 20,
+	}
+}
+
+// Current returns the value currently in focus
+func (in *Inspector) Current() Value {
+	return in.stack[len(in.stack)-1].value
+}
+
+// Render describes the focused value and the current page of its children
+func (in *Inspector) Render() string {
+	var out strings.Builder
+	frame := in.stack[len(in.stack)-1]
+
+	labels := make([]string, len(in.stack))
+	for i, f := range in.stack {
+		labels[i] = f.label
+	}
+	out.WriteString(fmt.Sprintf("Path:  %s\n", strings.Join(labels, " > ")))
+
+	entries, isColl := inspectEntries(frame.value)
+	if !isColl {
+		out.WriteString(fmt.Sprintf("Type:  %s\n", TypeName(frame.value)))
+		out.WriteString(fmt.Sprintf("Value: %s\n", frame.value.String()))
+		return out.String()
+	}
+
+	out.WriteString(fmt.Sprintf("Type:  %s  Count: %d\n", TypeName(frame.value), len(entries)))
+
+	start, end := in.pageBounds(len(entries))
+	for i := start; i < end; i++ {
+		entry := entries[i]
+		out.WriteString(fmt.Sprintf("  %3d. %s %s\n", i, inspectKeyLabel(entry.key), inspectSummary(entry.value)))
+	}
+
+	if len(entries) > in.PageSize {
+		pages := (len(entries) + in.PageSize - 1) / in.PageSize
+		out.WriteString(fmt.Sprintf("Page %d/%d\n", frame.page+1, pages))
+	}
+
+	return out.String()
+}
+
+// Command applies a navigation command and reports whether the session ended
+func (in *Inspector) Command(cmd string) (bool, error) {
+	cmd = strings.TrimSpace(cmd)
+	frame := &in.stack[len(in.stack)-1]
+	entries, _ := inspectEntries(frame.value)
+
+	switch cmd {
+	case "q", "quit", "exit":
+		return true, nil
+	case "", "h", "help", "?":
+		return false, nil
+	case "u", "up", "..":
+		if len(in.stack) > 1 {
+			in.stack = in.stack[:len(in.stack)-1]
+		}
+		return false, nil
+	case "n", "next":
+		if (frame.page+1)*in.PageSize < len(entries) {
+			frame.page++
+		}
+		return false, nil
+	case "p", "prev":
+		if frame.page > 0 {
+			frame.page--
+		}
+		return false, nil
+	case "t", "top":
+		in.stack = in.stack[:1]
+		return false, nil
+	}
+
+	index, err := strconv.Atoi(cmd)
+	if err != nil {
+		return false, fmt.Errorf("unknown inspector command: %s", cmd)
+	}
+	if index < 0 || index >= len(entries) {
+		return false, fmt.Errorf("no entry %d", index)
+	}
+
+	entry := entries[index]
+	in.stack = append(in.stack, inspectFrame{label: inspectKeyLabel(entry.key), value: entry.value})
+	return false, nil
+}
+
+// pageBounds returns the entry range shown on the focused frame's page
+func (in *Inspector) pageBounds(total int) (int, int) {
+	start := in.stack[len(in.stack)-1].page * in.PageSize
+	end := start + in.PageSize
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+const inspectHelp = "Commands: <n> open entry, u up, t top, n/p next/prev page, q quit"
+
+// runInspector drives an interactive inspector session
+func runInspector(value Value, env *Environment) (Value, error) {
+	inspector := NewInspector(value)
+	fmt.Println(inspectHelp)
+
+	for {
+		fmt.Print(inspector.Render())
+		line, err := env.readInteractiveLine("inspect> ")
+		if err != nil {
+			return inspector.Current(), nil
+		}
+
+		if cmd := strings.TrimSpace(line); cmd == "h" || cmd == "help" || cmd == "?" {
+			fmt.Println(inspectHelp)
+		}
+
+		done, err := inspector.Command(line)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+		}
+		if done {
+			return inspector.Current(), nil
+		}
+	}
+}
+
+// inspectEntries lists the children of a collection value
+func inspectEntries(value Value) ([]inspectEntry, bool) {
+	var entries []inspectEntry
+	switch v := value.(type) {
+	case *HashMap:
+		for _, key := range v.keys {
+			entries = append(entries, inspectEntry{key: key, value: v.Get(key)})
+		}
+	case *Vector, *List, *Set:
+		elements, _ := collectionToSlice(v)
+		for i, elem := range elements {
+			entries = append(entries, inspectEntry{key: NewNumber(int64(i)), value: elem})
+		}
+	default:
+		return nil, false
+	}
+	return entries, true
+}
+
+// inspectKeyLabel renders an entry key
+func inspectKeyLabel(key Value) string {
+	if n, ok := key.(Number); ok {
+		return fmt.Sprintf("[%s]", n.String())
+	}
+	return key.String()
+}
+
+// inspectSummary renders a value collapsed to a single line
+func inspectSummary(value Value) string {
+	if entries, isColl := inspectEntries(value); isColl {
+		return fmt.Sprintf("<%s of %d>", TypeName(value), len(entries))
+	}
+
+	text := value.String()
+	if len(text) > 60 {
+		text = text[:57] + "..."
+	}
+	return fmt.Sprintf("%s (%s)", text, TypeName(value))
+}
+
+// setupInspectorOperations adds the interactive data inspector to the environment
+func setupInspectorOperations(env *Environment) {
+	env.Set(Intern("inspect"), &BuiltinFunction{
+		Name: "inspect",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("inspect expects 1 argument, got %d", len(args))
+			}
+			return runInspector(args[0], env)
+		},
+	})
+}
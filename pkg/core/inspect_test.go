@@ -0,0 +1,74 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestInspectorNavigation(t *testing.T) {
+	value, err := core.ReadString(`{:name "api" :users [{:id 1} {:id 2} {:id 3}] :tags #{:a :b}}`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	inspector := core.NewInspector(value)
+	view := inspector.Render()
+	for _, want := range []string{"Path:  root", "Type:  hash-map  Count: 3", `:name "api" (string)`, ":users <vector of 3>", ":tags <set of 2>"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected root view to contain %q, got:\n%s", want, view)
+		}
+	}
+
+	steps := []struct {
+		command  string
+		contains string
+	}{
+		{"1", "Path:  root > :users"},
+		{"2", "Path:  root > :users > [2]"},
+		{"0", "Value: 3"},
+		{"u", "Type:  hash-map  Count: 1"},
+		{"t", "Path:  root\n"},
+	}
+
+	for _, step := range steps {
+		if done, err := inspector.Command(step.command); err != nil || done {
+			t.Fatalf("Command %q failed: done=%v err=%v", step.command, done, err)
+		}
+		if view := inspector.Render(); !strings.Contains(view, step.contains) {
+			t.Errorf("After %q expected %q, got:\n%s", step.command, step.contains, view)
+		}
+	}
+
+	if _, err := inspector.Command("9"); err == nil {
+		t.Errorf("Expected error for out-of-range entry")
+	}
+	if done, _ := inspector.Command("q"); !done {
+		t.Errorf("Expected q to end the session")
+	}
+}
+
+func TestInspectorPaging(t *testing.T) {
+	elements := make([]core.Value, 45)
+	for i := range elements {
+		elements[i] = core.NewNumber(int64(i))
+	}
+
+	inspector := core.NewInspector(core.NewVector(elements...))
+	if view := inspector.Render(); !strings.Contains(view, "Page 1/3") || strings.Contains(view, "[20]") {
+		t.Errorf("Unexpected first page:\n%s", inspector.Render())
+	}
+
+	inspector.Command("n")
+	inspector.Command("n")
+	inspector.Command("n")
+	if view := inspector.Render(); !strings.Contains(view, "Page 3/3") || !strings.Contains(view, "[44]") {
+		t.Errorf("Unexpected last page:\n%s", view)
+	}
+
+	inspector.Command("p")
+	if view := inspector.Render(); !strings.Contains(view, "Page 2/3") {
+		t.Errorf("Unexpected page after prev:\n%s", view)
+	}
+}
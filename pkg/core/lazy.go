@@ -0,0 +1,143 @@
+package core
+
+import "sync"
+
+// LazySeq is a sequence whose elements are computed when first needed and
+// then cached. Each cell is realized by step, which returns the first element
+// and the remaining sequence, or ok false at the end.
+type LazySeq struct {
+	mu       sync.Mutex
+	step     func() (first Value, rest Value, ok bool, err error)
+	realized bool
+	empty    bool
+	first    Value
+	rest     Value
+	err      error
+}
+
+// NewLazySeq creates a sequence realized by step
+func NewLazySeq(step func() (first Value, rest Value, ok bool, err error)) *LazySeq {
+	return &LazySeq{step: step}
+}
+
+// realize runs step once; later calls return the cached result
+func (s *LazySeq) realize() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.realized {
+		first, rest, ok, err := s.step()
+		s.realized, s.step = true, nil
+		s.first, s.rest, s.empty, s.err = first, rest, !ok, err
+	}
+	return s.err
+}
+
+func (s *LazySeq) String() string {
+	return printString(s)
+}
+
+// seqFirst returns the first element of a list, vector or lazy sequence;
+// ok is false when it is empty
+func seqFirst(coll Value) (Value, bool, error) {
+	switch c := coll.(type) {
+	case *LazySeq:
+		if err := c.realize(); err != nil {
+			return nil, false, err
+		}
+		return c.first, !c.empty, nil
+	case *List:
+		if c.IsEmpty() {
+			return nil, false, nil
+		}
+		return c.First(), true, nil
+	case Nil:
+		return nil, false, nil
+	default:
+		items, err := collectionToSlice(coll)
+		if err != nil || len(items) == 0 {
+			return nil, false, err
+		}
+		return items[0], true, nil
+	}
+}
+
+// seqRest returns everything after the first element
+func seqRest(coll Value) (Value, error) {
+	switch c := coll.(type) {
+	case *LazySeq:
+		if err := c.realize(); err != nil {
+			return nil, err
+		}
+		if c.empty {
+			return (*List)(nil), nil
+		}
+		return c.rest, nil
+	case *List:
+		if c.IsEmpty() {
+			return (*List)(nil), nil
+		}
+		return c.Rest(), nil
+	case Nil:
+		return (*List)(nil), nil
+	default:
+		items, err := collectionToSlice(coll)
+		if err != nil || len(items) == 0 {
+			return (*List)(nil), err
+		}
+		return NewList(items[1:]...), nil
+	}
+}
+
+// lazyConcat yields the elements of a and then those of b
+func lazyConcat(a, b Value) *LazySeq {
+	return NewLazySeq(func() (Value, Value, bool, error) {
+		first, ok, err := seqFirst(a)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		if !ok {
+			first, ok, err = seqFirst(b)
+			if err != nil || !ok {
+				return nil, nil, false, err
+			}
+			rest, err := seqRest(b)
+			return first, rest, true, err
+		}
+		rest, err := seqRest(a)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return first, lazyConcat(rest, b), true, nil
+	})
+}
+
+// lazyMapcat yields the elements of f(x) for each x in coll
+func lazyMapcat(coll Value, f func(x Value) (Value, error)) *LazySeq {
+	return NewLazySeq(func() (Value, Value, bool, error) {
+		for {
+			x, ok, err := seqFirst(coll)
+			if err != nil || !ok {
+				return nil, nil, false, err
+			}
+			if coll, err = seqRest(coll); err != nil {
+				return nil, nil, false, err
+			}
+
+			inner, err := f(x)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			first, ok, err := seqFirst(inner)
+			if err != nil {
+				return nil, nil, false, err
+			}
+			if ok {
+				rest, err := seqRest(inner)
+				if err != nil {
+					return nil, nil, false, err
+				}
+				return first, lazyConcat(rest, lazyMapcat(coll, f)), true, nil
+			}
+		}
+	})
+}
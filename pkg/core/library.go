@@ -0,0 +1,295 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LibraryDir is the user-level directory libraries are installed into:
+// $GOLISP_HOME/lib, or ~/.golisp/lib
+func LibraryDir() string {
+	if home := os.Getenv("GOLISP_HOME"); home != "" {
+		return filepath.Join(home, "lib")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".golisp", "lib")
+	}
+	return filepath.Join(home, ".golisp", "lib")
+}
+
+// libraryPath maps a repository URL and the ref it is checked out at to its
+// checkout under LibraryDir, e.g. https://github.com/user/lib.git at v1 ->
+// <LibraryDir>/github.com/user/lib@v1, so projects pinning different
+// versions don't share a checkout. Since the checkout is fetched into,
+// renamed and removed, a repository or ref that would put it anywhere but
+// strictly under LibraryDir is an error.
+func libraryPath(repo, ref string) (string, error) {
+	location := repo
+	if i := strings.Index(location, "://"); i >= 0 {
+		location = location[i+3:]
+	}
+	location = strings.TrimSuffix(strings.TrimSuffix(location, "/"), ".git")
+	if ref != "" {
+		location += "@" + ref
+	}
+	for _, segment := range strings.Split(filepath.ToSlash(location), "/") {
+		if segment == ".." {
+			return "", NewRuntimeError("library %s: repository and ref must not contain .., got %q", repo, location)
+		}
+	}
+
+	rel := filepath.Clean(filepath.FromSlash(strings.TrimLeft(location, "/")))
+	if rel == "." || filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return "", NewRuntimeError("library %s: repository does not name a path under the library directory", repo)
+	}
+	dir := LibraryDir()
+	path := filepath.Join(dir, rel)
+	if inside, err := filepath.Rel(dir, path); err != nil || inside == "." || inside == ".." || strings.HasPrefix(inside, ".."+string(filepath.Separator)) {
+		return "", NewRuntimeError("library %s: %s is not under the library directory %s", repo, path, dir)
+	}
+	return path, nil
+}
+
+// InstallLibrary clones spec ("github.com/user/lib" or "github.com/user/lib@v1.0")
+// into LibraryDir and returns the dependency pinned to the installed commit,
+// whose checkout is kept under that commit
+func InstallLibrary(spec string, out io.Writer) (Dependency, error) {
+	repo, tag, _ := strings.Cut(spec, "@")
+	if repo == "" {
+		return Dependency{}, NewRuntimeError("install expects a repository such as github.com/user/lib")
+	}
+
+	url := repo
+	if !strings.Contains(repo, "://") && !filepath.IsAbs(repo) && !strings.HasPrefix(repo, ".") {
+		url = "https://" + repo
+	}
+
+	name := filepath.Base(strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git"))
+	dep := Dependency{Name: Symbol(name), Git: url, Tag: tag}
+
+	staging, err := libraryPath(url, tag)
+	if err != nil {
+		return Dependency{}, err
+	}
+	fmt.Fprintf(out, "Installing %s\n", spec)
+	if err := fetchGit(dep, staging); err != nil {
+		return Dependency{}, fmt.Errorf("failed to install %s: %v", spec, err)
+	}
+
+	sha, err := exec.Command("git", "-C", staging, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return Dependency{}, fmt.Errorf("failed to resolve installed version of %s: %v", spec, err)
+	}
+	dep.Sha = strings.TrimSpace(string(sha))
+
+	dir, err := libraryPath(url, dep.Sha)
+	if err != nil {
+		return Dependency{}, err
+	}
+	if dirExists(dir) {
+		err = os.RemoveAll(staging)
+	} else {
+		err = os.Rename(staging, dir)
+	}
+	if err != nil {
+		return Dependency{}, fmt.Errorf("failed to install %s: %v", spec, err)
+	}
+	fmt.Fprintf(out, "Installed %s into %s\n", spec, dir)
+	return dep, nil
+}
+
+// InstalledLibraryPaths returns the source directories of the libraries
+// under LibraryDir, from the most recently installed checkout of each
+func InstalledLibraryPaths() []string {
+	type checkout struct {
+		path    string
+		modTime time.Time
+	}
+	latest := make(map[string]checkout)
+	filepath.WalkDir(LibraryDir(), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.IsDir() {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return filepath.SkipDir
+		}
+		library, _, _ := strings.Cut(path, "@")
+		if current, ok := latest[library]; !ok || info.ModTime().After(current.modTime) {
+			latest[library] = checkout{path, info.ModTime()}
+		}
+		return filepath.SkipDir
+	})
+
+	libraries := make([]string, 0, len(latest))
+	for library := range latest {
+		libraries = append(libraries, library)
+	}
+	sort.Strings(libraries)
+
+	var dirs []string
+	for _, library := range libraries {
+		path := latest[library].path
+		paths := []string{"."}
+		if lib, err := LoadProject(path); err == nil {
+			paths = lib.Paths
+		}
+		for _, p := range paths {
+			dirs = append(dirs, filepath.Join(path, p))
+		}
+	}
+	return dirs
+}
+
+// UseLibraries appends the installed libraries to *load-path* in env, for
+// code run outside a project; a project's load path has just its own
+// dependencies
+func UseLibraries(env *Environment) {
+	var dirs []Value
+	if current, err := env.Get(Intern("*load-path*")); err == nil {
+		dirs, _ = collectionToSlice(current)
+	}
+	for _, dir := range InstalledLibraryPaths() {
+		dirs = append(dirs, String(dir))
+	}
+	env.root().Set(Intern("*load-path*"), NewVector(dirs...))
+}
+
+// AddDependency adds dep to the project, replacing any dependency of the same name
+func (p *Project) AddDependency(dep Dependency) {
+	for i, existing := range p.Deps {
+		if existing.Name == dep.Name {
+			p.Deps[i] = dep
+			return
+		}
+	}
+	p.Deps = append(p.Deps, dep)
+}
+
+// Save writes the project back to its golisp.edn. An existing file keeps
+// its other keys and comments; only its :deps map is rewritten.
+func (p *Project) Save() error {
+	path := filepath.Join(p.Root, ProjectFile)
+	source := p.String()
+	if existing, err := os.ReadFile(path); err == nil {
+		if spliced, ok := p.spliceDeps(string(existing)); ok {
+			source = spliced
+		}
+	}
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		return NewIOError("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// spliceDeps replaces the :deps map in the golisp.edn source, or adds one
+// before the closing brace, leaving the rest of the source as written. It
+// reports false if the source isn't a map it can edit.
+func (p *Project) spliceDeps(source string) (string, bool) {
+	tokens, err := NewLexer(source).Tokenize()
+	if err != nil || len(tokens) == 0 || tokens[0].Type != TokenLeftBrace {
+		return "", false
+	}
+	depth := 0
+	for i, token := range tokens {
+		switch token.Type {
+		case TokenLeftParen, TokenLeftBracket, TokenLeftBrace:
+			depth++
+		case TokenRightParen, TokenRightBracket, TokenRightBrace:
+			if depth--; depth == 0 {
+				if len(p.Deps) == 0 {
+					return source, true
+				}
+				at := token.Position.Offset
+				return source[:at] + "\n :deps " + p.depsSource(len(" :deps ")) + source[at:], true
+			}
+		case TokenKeyword:
+			if depth != 1 || token.Value != "deps" {
+				continue
+			}
+			if i+1 >= len(tokens) || tokens[i+1].Type != TokenLeftBrace {
+				return "", false
+			}
+			start := tokens[i+1].Position.Offset
+			end, ok := closingOffset(tokens[i+1:])
+			if !ok {
+				return "", false
+			}
+			column := start - strings.LastIndex(source[:start], "\n") - 1
+			return source[:start] + p.depsSource(column) + source[end:], true
+		}
+	}
+	return "", false
+}
+
+// closingOffset returns the offset just past the bracket closing the one
+// tokens start with
+func closingOffset(tokens []Token) (int, bool) {
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case TokenLeftParen, TokenLeftBracket, TokenLeftBrace:
+			depth++
+		case TokenRightParen, TokenRightBracket, TokenRightBrace:
+			if depth--; depth == 0 {
+				return token.End, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// String renders the project as golisp.edn source
+func (p *Project) String() string {
+	var out strings.Builder
+	out.WriteString("{:paths " + manifestVector(p.Paths))
+	if len(p.Deps) > 0 {
+		out.WriteString("\n :deps " + p.depsSource(len(" :deps ")))
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// depsSource renders the :deps map, one dependency per line, lined up
+// under a map starting at column
+func (p *Project) depsSource(column int) string {
+	var out strings.Builder
+	out.WriteString("{")
+	for i, dep := range p.Deps {
+		if i > 0 {
+			out.WriteString("\n" + strings.Repeat(" ", column+1))
+		}
+		out.WriteString(string(dep.Name) + " {")
+		var fields []string
+		for _, field := range [][2]string{{"git", dep.Git}, {"url", dep.URL}, {"tag", dep.Tag}, {"sha", dep.Sha}} {
+			if field[1] != "" {
+				fields = append(fields, ":"+field[0]+" "+String(field[1]).String())
+			}
+		}
+		if dep.Paths != nil {
+			fields = append(fields, ":paths "+manifestVector(dep.Paths))
+		}
+		out.WriteString(strings.Join(fields, " ") + "}")
+	}
+	out.WriteString("}")
+	return out.String()
+}
+
+func manifestVector(items []string) string {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		quoted[i] = String(item).String()
+	}
+	return "[" + strings.Join(quoted, " ") + "]"
+}
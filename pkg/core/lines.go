@@ -0,0 +1,78 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// LineMode is what `golisp map` and `golisp filter` do with each line
+type LineMode int
+
+const (
+	// LineMap prints the value of the expression for each line, skipping
+	// lines where it is nil
+	LineMap LineMode = iota
+	// LineFilter prints the lines for which the expression is truthy
+	LineFilter
+)
+
+// ProcessLines evaluates code once for every line of in, with line bound to
+// the line without its newline and n to its 1-based number. Output is
+// flushed whenever the input has no more lines ready, so results stream as
+// lines arrive on a pipe.
+func ProcessLines(env *Environment, mode LineMode, code string, in io.Reader, out io.Writer) error {
+	forms, err := env.NewReader(strings.NewReader(code)).ReadAll()
+	if err != nil {
+		return err
+	}
+	scope := NewEnvironment(env)
+	reader := bufio.NewReader(in)
+	writer := bufio.NewWriter(out)
+	defer writer.Flush()
+
+	for n := int64(1); ; n++ {
+		text, readErr := reader.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if text == "" && readErr == io.EOF {
+			return nil
+		}
+		line := strings.TrimSuffix(strings.TrimSuffix(text, "\n"), "\r")
+		scope.Set(Intern("line"), String(line))
+		scope.Set(Intern("n"), NewNumber(n))
+
+		var result Value = Nil{}
+		for _, form := range forms {
+			if result, err = Eval(form, scope); err != nil {
+				writer.Flush()
+				return fmt.Errorf("line %d: %v", n, err)
+			}
+		}
+
+		switch {
+		case mode == LineFilter:
+			if isTruthy(result) {
+				fmt.Fprintln(writer, line)
+			}
+		case result == Nil{}:
+		default:
+			if s, ok := result.(String); ok {
+				fmt.Fprintln(writer, string(s))
+			} else {
+				fmt.Fprintln(writer, result)
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+		if reader.Buffered() == 0 {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProcessLines(t *testing.T) {
+	input := "alpha\r\nERR beta\n\ngamma ERR"
+	tests := []struct {
+		mode     LineMode
+		code     string
+		expected string
+	}{
+		{LineMap, `(upper-case line)`, "ALPHA\nERR BETA\n\nGAMMA ERR\n"},
+		{LineMap, `(count line)`, "5\n8\n0\n9\n"},
+		// nil results are skipped, so map can also filter and transform at once
+		{LineMap, `(if (string-contains? line "ERR") (str n ": " (lower-case line)))`, "2: err beta\n4: gamma err\n"},
+		{LineFilter, `(string-contains? line "ERR")`, "ERR beta\ngamma ERR\n"},
+		{LineFilter, `(= n 1)`, "alpha\n"},
+		{LineFilter, `nil`, ""},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := ProcessLines(NewCoreEnvironment(), test.mode, test.code, strings.NewReader(input), &out); err != nil {
+			t.Errorf("For '%s', unexpected error: %v", test.code, err)
+			continue
+		}
+		if out.String() != test.expected {
+			t.Errorf("For '%s', expected %q, got %q", test.code, test.expected, out.String())
+		}
+	}
+
+	// Output before a failing line is kept, and the error names the line
+	var out bytes.Buffer
+	err := ProcessLines(NewCoreEnvironment(), LineMap, `(upper-case (if (= n 2) n line))`, strings.NewReader("ab\ncd\nef"), &out)
+	if err == nil || !strings.Contains(err.Error(), "line 2:") {
+		t.Errorf("Expected an error for line 2, got: %v", err)
+	}
+	if out.String() != "AB\n" {
+		t.Errorf("Expected output from the first line, got %q", out.String())
+	}
+	if err := ProcessLines(NewCoreEnvironment(), LineMap, `(upper-case line`, strings.NewReader("a"), &out); err == nil {
+		t.Error("Expected a parse error for an unbalanced expression")
+	}
+}
@@ -0,0 +1,432 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LintIssue is a problem found by the linter
+type LintIssue struct {
+	File    string
+	Pos     Position
+	Rule    string
+	Message string
+}
+
+func (i LintIssue) String() string {
+	return fmt.Sprintf("%s:%d:%d: [%s] %s", i.File, i.Pos.Line, i.Pos.Column, i.Rule, i.Message)
+}
+
+// Linter statically checks Lisp sources. Definitions and references are
+// collected across every added file, so unused-def only reports names that
+// none of them use.
+type Linter struct {
+	env   *Environment // Known builtins and library functions
+	files []lintFile
+}
+
+type lintFile struct {
+	name   string
+	forms  []Value
+	spans  map[*List]SourceSpan
+	syntax []*SyntaxError
+}
+
+// arity is what a known function accepts
+type arity struct {
+	required int
+	variadic bool
+}
+
+// lintDef is a top-level definition seen while linting
+type lintDef struct {
+	file string
+	pos  Position
+}
+
+// lintPass holds the state of a single Run
+type lintPass struct {
+	linter     *Linter
+	file       *lintFile
+	issues     []LintIssue
+	arities    map[Symbol]arity
+	defs       map[Symbol]lintDef
+	defOrder   []Symbol
+	references map[Symbol]bool
+}
+
+// NewLinter creates a linter that knows the functions bound in env
+func NewLinter(env *Environment) *Linter {
+	return &Linter{env: env}
+}
+
+// AddSource parses a file for linting. Syntax errors do not stop it: Run
+// reports each of them as a syntax-error issue, and lints the forms around
+// them.
+func (l *Linter) AddSource(source, file string) error {
+	tokens, syntax := NewLexer(source).TokenizeRecover()
+	parser := NewParserWithSource(tokens, source)
+	spans := parser.RecordSpans()
+	forms, parseErrs := parser.ParseAllRecover()
+	syntax = append(syntax, parseErrs...)
+	l.files = append(l.files, lintFile{name: file, forms: forms, spans: spans, syntax: syntax})
+	return nil
+}
+
+// Run checks every added file and returns the issues sorted by location
+func (l *Linter) Run() []LintIssue {
+	pass := &lintPass{
+		linter:     l,
+		arities:    make(map[Symbol]arity),
+		defs:       make(map[Symbol]lintDef),
+		references: make(map[Symbol]bool),
+	}
+
+	// Arities of functions defined in the linted files win over the environment
+	for i := range l.files {
+		for _, form := range l.files[i].forms {
+			if name, params, ok := defnSignature(form); ok {
+				pass.arities[name] = paramsArity(params)
+			}
+		}
+	}
+
+	for i := range l.files {
+		pass.file = &l.files[i]
+		for _, err := range pass.file.syntax {
+			pass.issues = append(pass.issues, LintIssue{File: pass.file.name, Pos: err.Position, Rule: "syntax-error", Message: err.Message})
+		}
+		for _, form := range pass.file.forms {
+			pass.topLevel(form)
+		}
+	}
+
+	for _, name := range pass.defOrder {
+		if !pass.references[name] && !strings.HasPrefix(string(name), "_") && name != "-main" {
+			def := pass.defs[name]
+			pass.issues = append(pass.issues, LintIssue{File: def.file, Pos: def.pos, Rule: "unused-def",
+				Message: fmt.Sprintf("%s is defined but never used", name)})
+		}
+	}
+
+	sort.SliceStable(pass.issues, func(i, j int) bool {
+		a, b := pass.issues[i], pass.issues[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Pos.Line != b.Pos.Line {
+			return a.Pos.Line < b.Pos.Line
+		}
+		return a.Pos.Column < b.Pos.Column
+	})
+	return pass.issues
+}
+
+// defnSignature returns the name and parameter vector of a (defn name ...) form
+func defnSignature(form Value) (Symbol, Value, bool) {
+	list, ok := form.(*List)
+	if !ok || list.IsEmpty() || list.First() != Intern("defn") {
+		return "", nil, false
+	}
+	parts := listToSlice(list.Rest())
+	if len(parts) < 2 {
+		return "", nil, false
+	}
+	name, ok := parts[0].(Symbol)
+	if !ok {
+		return "", nil, false
+	}
+	_, _, rest := splitDocAndAttrs(parts[1:])
+	if len(rest) == 0 {
+		return "", nil, false
+	}
+	return name, rest[0], true
+}
+
+// paramsArity reads the arity of a parameter vector such as [a b & more]
+func paramsArity(params Value) arity {
+	items, _ := collectionToSlice(params)
+	var result arity
+	for _, item := range items {
+		if item == Intern("&") {
+			result.variadic = true
+			break
+		}
+		result.required++
+	}
+	return result
+}
+
+func (p *lintPass) report(form Value, rule, format string, args ...any) {
+	var pos Position
+	if list, ok := form.(*List); ok {
+		pos = p.file.spans[list].Start
+	}
+	p.issues = append(p.issues, LintIssue{File: p.file.name, Pos: pos, Rule: rule, Message: fmt.Sprintf(format, args...)})
+}
+
+// topLevel records definitions and then checks the form
+func (p *lintPass) topLevel(form Value) {
+	if list, ok := form.(*List); ok && !list.IsEmpty() {
+		head := list.First()
+		if head == Intern("def") || head == Intern("defn") {
+			parts := listToSlice(list.Rest())
+			if len(parts) > 0 {
+				if name, ok := parts[0].(Symbol); ok {
+					if _, seen := p.defs[name]; !seen {
+						p.defOrder = append(p.defOrder, name)
+					}
+					p.defs[name] = lintDef{file: p.file.name, pos: p.file.spans[list].Start}
+				}
+			}
+		}
+	}
+	p.check(form, nil)
+}
+
+// check walks a form in evaluation position; locals are the names bound by
+// enclosing fn, let and loop forms
+func (p *lintPass) check(form Value, locals map[Symbol]bool) {
+	switch v := form.(type) {
+	case Symbol:
+		p.references[v] = true
+		return
+	case *Vector, *HashMap, *Set:
+		items, _ := collectionToSlice(form)
+		if m, ok := form.(*HashMap); ok {
+			for _, key := range m.keys {
+				items = append(items, key, m.Get(key))
+			}
+		}
+		for _, item := range items {
+			p.check(item, locals)
+		}
+		return
+	case *List:
+		if v.IsEmpty() {
+			return
+		}
+	default:
+		return
+	}
+
+	list := form.(*List)
+	args := listToSlice(list.Rest())
+	head, _ := list.First().(Symbol)
+
+	if !locals[head] {
+		switch head {
+		case "quote":
+			return
+		case "def":
+			if len(args) > 0 {
+				p.checkShadowing(list, args[0])
+			}
+			p.checkAll(args[1:], locals)
+			return
+		case "defn", "defmacro":
+			if len(args) < 2 {
+				return
+			}
+			p.checkShadowing(list, args[0])
+			_, _, rest := splitDocAndAttrs(args[1:])
+			if len(rest) > 0 {
+				p.checkFn(list, rest[0], rest[1:], locals)
+			}
+			return
+		case "fn":
+			if len(args) > 0 {
+				p.checkFn(list, args[0], args[1:], locals)
+			}
+			return
+		case "case":
+			// Keys are constants; only the dispatch value and results are code
+			for i, arg := range args {
+				if i%2 == 0 || i == len(args)-1 {
+					p.check(arg, locals)
+				}
+			}
+			return
+		case "let", "loop":
+			if len(args) > 0 {
+				p.checkLet(list, head, args[0], args[1:], locals)
+			}
+			return
+		case "if", "when", "unless":
+			p.checkConstantCondition(list, head, args)
+		case "=", "not=", "<", ">", "<=", ">=":
+			if len(args) == 1 {
+				p.report(list, "single-arg-comparison", "(%s %s) with one argument is always true", head, args[0])
+			}
+		}
+		p.checkArity(list, head, len(args))
+	}
+
+	p.check(list.First(), locals)
+	p.checkAll(args, locals)
+}
+
+func (p *lintPass) checkAll(forms []Value, locals map[Symbol]bool) {
+	for _, form := range forms {
+		p.check(form, locals)
+	}
+}
+
+// checkFn checks a function body with its parameters in scope
+func (p *lintPass) checkFn(form Value, params Value, body []Value, locals map[Symbol]bool) {
+	names, _ := collectionToSlice(params)
+	inner := withLocals(locals)
+	for _, name := range names {
+		if sym, ok := name.(Symbol); ok && sym != "&" {
+			p.checkShadowing(form, sym)
+			inner[sym] = true
+		}
+	}
+	p.checkAll(body, inner)
+}
+
+// checkLet checks bindings in order, flagging those never referenced by
+// later bindings or the body
+func (p *lintPass) checkLet(form Value, head Symbol, bindings Value, body []Value, locals map[Symbol]bool) {
+	pairs, _ := collectionToSlice(bindings)
+	inner := withLocals(locals)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		p.check(pairs[i+1], inner)
+		name, ok := pairs[i].(Symbol)
+		if !ok {
+			continue
+		}
+		p.checkShadowing(form, name)
+		inner[name] = true
+
+		// loop bindings are used by recur even if not referenced by name
+		if head == "let" && !strings.HasPrefix(string(name), "_") {
+			rest := append(append([]Value(nil), pairs[i+2:]...), body...)
+			if !referencesSymbol(rest, name) {
+				p.report(form, "unused-binding", "let binding %s is never used", name)
+			}
+		}
+	}
+	p.checkAll(body, inner)
+}
+
+// checkShadowing flags local or global names that hide a builtin
+func (p *lintPass) checkShadowing(form Value, name Value) {
+	sym, ok := name.(Symbol)
+	if !ok || p.linter.env == nil {
+		return
+	}
+	if value, err := p.linter.env.Get(sym); err == nil {
+		if _, builtin := value.(*BuiltinFunction); builtin {
+			p.report(form, "shadowed-builtin", "%s shadows the builtin %s", sym, sym)
+		}
+	}
+}
+
+// checkConstantCondition flags if/when/unless on a literal condition
+func (p *lintPass) checkConstantCondition(form *List, head Symbol, args []Value) {
+	if len(args) == 0 {
+		return
+	}
+	truthy, constant := constantTruthiness(args[0])
+	if !constant {
+		return
+	}
+	branch := "else branch"
+	if !truthy {
+		branch = "then branch"
+	}
+	if head == "unless" {
+		if truthy {
+			branch = "body"
+		} else {
+			return
+		}
+	} else if head == "when" {
+		if !truthy {
+			branch = "body"
+		} else {
+			return
+		}
+	} else if truthy && len(args) < 3 {
+		return
+	}
+	p.report(form, "unreachable-branch", "condition %s is constant, so the %s never runs", args[0], branch)
+}
+
+// constantTruthiness reports whether a literal condition is always truthy or falsy
+func constantTruthiness(value Value) (truthy, constant bool) {
+	switch v := value.(type) {
+	case Nil:
+		return false, true
+	case Symbol:
+		switch v {
+		case "nil", "false":
+			return false, true
+		case "true":
+			return true, true
+		}
+	case Number, String, Keyword:
+		return isTruthy(v), true // 0 and "" are falsy
+	}
+	return false, false
+}
+
+// checkArity compares a call against the known arity of its function
+func (p *lintPass) checkArity(form *List, head Symbol, argc int) {
+	if head == "" {
+		return
+	}
+	expected, known := p.arities[head]
+	if !known && p.linter.env != nil {
+		if value, err := p.linter.env.Get(head); err == nil {
+			if fn, ok := value.(*UserFunction); ok {
+				expected, known = paramsArity(fn.Params), true
+			}
+		}
+	}
+	if !known {
+		return
+	}
+
+	if argc < expected.required || (!expected.variadic && argc > expected.required) {
+		want := fmt.Sprintf("%d", expected.required)
+		if expected.variadic {
+			want = fmt.Sprintf("at least %d", expected.required)
+		}
+		p.report(form, "arity", "%s called with %d arguments, expects %s", head, argc, want)
+	}
+}
+
+func withLocals(locals map[Symbol]bool) map[Symbol]bool {
+	inner := make(map[Symbol]bool, len(locals))
+	for name := range locals {
+		inner[name] = true
+	}
+	return inner
+}
+
+// referencesSymbol reports whether name occurs anywhere in forms
+func referencesSymbol(forms []Value, name Symbol) bool {
+	for _, form := range forms {
+		if form == name {
+			return true
+		}
+		var items []Value
+		switch v := form.(type) {
+		case *List:
+			items = listToSlice(v)
+		case *Vector, *Set:
+			items, _ = collectionToSlice(v)
+		case *HashMap:
+			for _, key := range v.keys {
+				items = append(items, key, v.Get(key))
+			}
+		}
+		if referencesSymbol(items, name) {
+			return true
+		}
+	}
+	return false
+}
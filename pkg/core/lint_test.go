@@ -0,0 +1,123 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestLinter(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected []string
+	}{
+		{
+			"unused let binding",
+			`(defn f [] (let [a 1 b 2 _c 3] (+ b 1))) (f)`,
+			[]string{"test.lisp:1:12: [unused-binding] let binding a is never used"},
+		},
+		{
+			"binding used by a later binding",
+			`(defn f [] (let [a 1 b (+ a 1)] b)) (f)`,
+			nil,
+		},
+		{
+			"unused def",
+			`(def limit 10) (defn helper [] 1) (defn -main [] (helper))`,
+			[]string{"test.lisp:1:1: [unused-def] limit is defined but never used"},
+		},
+		{
+			"shadowed builtin",
+			`(defn f [count] count) (f 1)`,
+			[]string{"test.lisp:1:1: [shadowed-builtin] count shadows the builtin count"},
+		},
+		{
+			"arity mismatch",
+			`(defn add [a b] (+ a b)) (add 1) (add 1 2) (add 1 2 3)`,
+			[]string{
+				"test.lisp:1:26: [arity] add called with 1 arguments, expects 2",
+				"test.lisp:1:44: [arity] add called with 3 arguments, expects 2",
+			},
+		},
+		{
+			"variadic arity",
+			`(defn log [level & parts] parts) (log) (log :info "a" "b")`,
+			[]string{"test.lisp:1:34: [arity] log called with 0 arguments, expects at least 1"},
+		},
+		{
+			"local shadows known function",
+			`(defn add [a b] (+ a b)) (defn f [add] (add 1)) (f add)`,
+			nil,
+		},
+		{
+			"case keys are not calls",
+			`(defn add [a b] (+ a b)) (case x (add) 1 2 (add 1) (add 2 3))`,
+			[]string{
+				"test.lisp:1:44: [arity] add called with 1 arguments, expects 2",
+			},
+		},
+		{
+			"unreachable branches",
+			`(if true 1 2) (if nil 1 2) (when false 1) (if true 1) (if x 1 2)`,
+			[]string{
+				"test.lisp:1:1: [unreachable-branch] condition true is constant, so the else branch never runs",
+				"test.lisp:1:15: [unreachable-branch] condition nil is constant, so the then branch never runs",
+				"test.lisp:1:28: [unreachable-branch] condition false is constant, so the body never runs",
+			},
+		},
+		{
+			"zero and empty string are falsy",
+			`(if 0 1 2) (when "" 1) (if 0.0 1 2) (if 1 1 2)`,
+			[]string{
+				"test.lisp:1:1: [unreachable-branch] condition 0 is constant, so the then branch never runs",
+				"test.lisp:1:12: [unreachable-branch] condition \"\" is constant, so the body never runs",
+				"test.lisp:1:24: [unreachable-branch] condition 0.0 is constant, so the then branch never runs",
+				"test.lisp:1:37: [unreachable-branch] condition 1 is constant, so the else branch never runs",
+			},
+		},
+		{
+			"single argument comparison",
+			`(= x) (= x 1) (< y)`,
+			[]string{
+				"test.lisp:1:1: [single-arg-comparison] (= x) with one argument is always true",
+				"test.lisp:1:15: [single-arg-comparison] (< y) with one argument is always true",
+			},
+		},
+		{
+			"quoted data is ignored",
+			`(quote (if true (= x) 2))`,
+			nil,
+		},
+		{
+			"syntax errors",
+			"(g 1) ) $ (g #inst 5)\n(defn f [x]\n  (+ x 1)\n(if true 1 2)",
+			[]string{
+				"test.lisp:1:7: [syntax-error] unexpected token: )",
+				"test.lisp:1:9: [syntax-error] unexpected character: $",
+				"test.lisp:1:14: [syntax-error] #inst: expected a time string, got 5",
+				"test.lisp:2:1: [syntax-error] unclosed (",
+				"test.lisp:4:1: [unreachable-branch] condition true is constant, so the else branch never runs",
+			},
+		},
+	}
+
+	env := core.NewCoreEnvironment()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			linter := core.NewLinter(env)
+			if err := linter.AddSource(test.source, "test.lisp"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, issue := range linter.Run() {
+				got = append(got, issue.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("Expected:\n%s\nGot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
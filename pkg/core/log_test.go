@@ -0,0 +1,92 @@
+package core_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestStructuredLogging(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	dir := t.TempDir()
+	jsonPath := filepath.Join(dir, "app.jsonl")
+	textPath := filepath.Join(dir, "app.log")
+
+	eval := func(input string) {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		if _, err := core.Eval(expr, env); err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+	}
+
+	eval(`(log/set-sinks! [{:type :file :path "` + jsonPath + `" :format :json}
+	                        {:type :file :path "` + textPath + `"}])`)
+	defer eval(`(log/set-sinks! [{:type :stderr}])`)
+
+	eval(`(log/debug "hidden")`)
+	eval(`(log/info "user logged in" {:user 42 :tags [:a :b]})`)
+	eval(`(def *log-level* :error)`)
+	eval(`(log/warn "suppressed")`)
+	eval(`(log/error "boom")`)
+
+	content, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatalf("Failed to read JSON log: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON log lines, got %d: %q", len(lines), content)
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Invalid JSON log line %q: %v", lines[0], err)
+	}
+	if record["msg"] != "user logged in" || record["level"] != "info" || record["user"] != float64(42) {
+		t.Errorf("Unexpected JSON record: %v", record)
+	}
+	if _, ok := record["time"].(string); !ok {
+		t.Errorf("Expected timestamp in JSON record: %v", record)
+	}
+
+	text, err := os.ReadFile(textPath)
+	if err != nil {
+		t.Fatalf("Failed to read text log: %v", err)
+	}
+	if !strings.Contains(string(text), "INFO  user logged in user=42 tags=[:a :b]") ||
+		!strings.Contains(string(text), "ERROR boom") {
+		t.Errorf("Unexpected text log:\n%s", text)
+	}
+}
+
+func TestStructuredLoggingErrors(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []string{
+		`(log/info)`,
+		`(log/info "msg" 42)`,
+		`(log/log :verbose "msg")`,
+		`(log/set-sinks! [{:type :carrier-pigeon}])`,
+		`(log/set-sinks! [{:type :file}])`,
+	}
+
+	for _, input := range tests {
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Errorf("Parse error for '%s': %v", input, err)
+			continue
+		}
+		if _, err := core.Eval(expr, env); err == nil {
+			t.Errorf("Expected error for '%s'", input)
+		}
+	}
+}
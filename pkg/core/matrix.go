@@ -0,0 +1,380 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Matrix is an immutable rows x cols matrix of floats stored row by row in
+// one slice. It prints as #matrix [[1.0 2.0] [3.0 4.0]], which reads back.
+type Matrix struct {
+	rows, cols int
+	data       []float64
+}
+
+func newMatrix(rows, cols int) *Matrix {
+	return &Matrix{rows: rows, cols: cols, data: make([]float64, rows*cols)}
+}
+
+func (m *Matrix) at(i, j int) float64 {
+	return m.data[i*m.cols+j]
+}
+
+func (m *Matrix) String() string {
+	var out strings.Builder
+	out.WriteString("#matrix [")
+	for i := 0; i < m.rows; i++ {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString("[")
+		for j := 0; j < m.cols; j++ {
+			if j > 0 {
+				out.WriteString(" ")
+			}
+			out.WriteString(formatFloat(m.at(i, j)))
+		}
+		out.WriteString("]")
+	}
+	out.WriteString("]")
+	return out.String()
+}
+
+// rowValues returns the rows as vectors of numbers
+func (m *Matrix) rowValues() Value {
+	rows := make([]Value, m.rows)
+	for i := range rows {
+		row := make([]Value, m.cols)
+		for j := range row {
+			row[j] = NewNumber(m.at(i, j))
+		}
+		rows[i] = NewVector(row...)
+	}
+	return NewVector(rows...)
+}
+
+// matrixFromRows builds a matrix from a collection of equally long rows of
+// numbers
+func matrixFromRows(value Value) (*Matrix, error) {
+	rows, err := collectionToSlice(value)
+	if err != nil || len(rows) == 0 {
+		return nil, fmt.Errorf("expected a non-empty collection of rows, got %s", printString(value))
+	}
+	var m *Matrix
+	for i, rowValue := range rows {
+		row, err := collectionToSlice(rowValue)
+		if err != nil || len(row) == 0 {
+			return nil, fmt.Errorf("expected row %d to be a non-empty collection of numbers, got %s", i, printString(rowValue))
+		}
+		if m == nil {
+			m = newMatrix(len(rows), len(row))
+		} else if len(row) != m.cols {
+			return nil, fmt.Errorf("expected rows of %d numbers, row %d has %d", m.cols, i, len(row))
+		}
+		for j, item := range row {
+			n, ok := item.(Number)
+			if !ok {
+				return nil, fmt.Errorf("expected numbers, got %s in row %d", printString(item), i)
+			}
+			m.data[i*m.cols+j] = n.ToFloat()
+		}
+	}
+	return m, nil
+}
+
+func readMatrix(form Value) (Value, error) {
+	return matrixFromRows(form)
+}
+
+func identityMatrix(n int) *Matrix {
+	m := newMatrix(n, n)
+	for i := 0; i < n; i++ {
+		m.data[i*n+i] = 1
+	}
+	return m
+}
+
+func (m *Matrix) transpose() *Matrix {
+	t := newMatrix(m.cols, m.rows)
+	for i := 0; i < m.rows; i++ {
+		for j := 0; j < m.cols; j++ {
+			t.data[j*t.cols+i] = m.at(i, j)
+		}
+	}
+	return t
+}
+
+func matmul(a, b *Matrix) (*Matrix, error) {
+	if a.cols != b.rows {
+		return nil, NewRuntimeError("matmul cannot multiply %dx%d by %dx%d", a.rows, a.cols, b.rows, b.cols)
+	}
+	product := newMatrix(a.rows, b.cols)
+	for i := 0; i < a.rows; i++ {
+		for k := 0; k < a.cols; k++ {
+			x := a.at(i, k)
+			for j := 0; j < b.cols; j++ {
+				product.data[i*b.cols+j] += x * b.at(k, j)
+			}
+		}
+	}
+	return product, nil
+}
+
+// singularTolerance is the pivot size below which a matrix counts as
+// singular
+const singularTolerance = 1e-12
+
+// eliminate runs Gauss-Jordan elimination with partial pivoting on m,
+// applying the same row operations to inverse when it is not nil, and
+// returns the determinant of m
+func (m *Matrix) eliminate(inverse *Matrix) float64 {
+	a := &Matrix{rows: m.rows, cols: m.cols, data: append([]float64(nil), m.data...)}
+	n := a.rows
+	swapRows := func(x *Matrix, i, j int) {
+		for k := 0; k < x.cols; k++ {
+			x.data[i*x.cols+k], x.data[j*x.cols+k] = x.data[j*x.cols+k], x.data[i*x.cols+k]
+		}
+	}
+	det := 1.0
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a.at(row, col)) > math.Abs(a.at(pivot, col)) {
+				pivot = row
+			}
+		}
+		if math.Abs(a.at(pivot, col)) < singularTolerance {
+			return 0
+		}
+		if pivot != col {
+			swapRows(a, pivot, col)
+			if inverse != nil {
+				swapRows(inverse, pivot, col)
+			}
+			det = -det
+		}
+		p := a.at(col, col)
+		det *= p
+		for k := 0; k < n; k++ {
+			a.data[col*n+k] /= p
+			if inverse != nil {
+				inverse.data[col*n+k] /= p
+			}
+		}
+		for row := 0; row < n; row++ {
+			if row == col {
+				continue
+			}
+			factor := a.at(row, col)
+			for k := 0; k < n; k++ {
+				a.data[row*n+k] -= factor * a.at(col, k)
+				if inverse != nil {
+					inverse.data[row*n+k] -= factor * inverse.at(col, k)
+				}
+			}
+		}
+	}
+	return det
+}
+
+func (m *Matrix) inverse() (*Matrix, error) {
+	if m.rows != m.cols {
+		return nil, NewRuntimeError("inverse expects a square matrix, got %dx%d", m.rows, m.cols)
+	}
+	inverse := identityMatrix(m.rows)
+	if m.eliminate(inverse) == 0 {
+		return nil, NewRuntimeError("inverse: matrix is singular")
+	}
+	return inverse, nil
+}
+
+// elementwise applies op to matching elements of two matrices of the same
+// size, or of a matrix and a number
+func elementwise(name string, a, b Value, op func(x, y float64) float64) (Value, error) {
+	ma, aMatrix := a.(*Matrix)
+	mb, bMatrix := b.(*Matrix)
+	na, aNumber := a.(Number)
+	nb, bNumber := b.(Number)
+	switch {
+	case aMatrix && bMatrix:
+		if ma.rows != mb.rows || ma.cols != mb.cols {
+			return nil, NewRuntimeError("%s expects matrices of the same size, got %dx%d and %dx%d", name, ma.rows, ma.cols, mb.rows, mb.cols)
+		}
+		result := newMatrix(ma.rows, ma.cols)
+		for i := range result.data {
+			result.data[i] = op(ma.data[i], mb.data[i])
+		}
+		return result, nil
+	case aMatrix && bNumber:
+		result := newMatrix(ma.rows, ma.cols)
+		for i := range result.data {
+			result.data[i] = op(ma.data[i], nb.ToFloat())
+		}
+		return result, nil
+	case aNumber && bMatrix:
+		result := newMatrix(mb.rows, mb.cols)
+		for i := range result.data {
+			result.data[i] = op(na.ToFloat(), mb.data[i])
+		}
+		return result, nil
+	case aNumber && bNumber:
+		return NewNumber(op(na.ToFloat(), nb.ToFloat())), nil
+	}
+	return nil, NewTypeError("%s expects matrices or numbers, got %s and %s", name, TypeName(a), TypeName(b))
+}
+
+func matrixArg(name string, value Value) (*Matrix, error) {
+	m, ok := value.(*Matrix)
+	if !ok {
+		return nil, NewTypeError("%s expects a matrix, got %s", name, TypeName(value))
+	}
+	return m, nil
+}
+
+// setupMatrixOperations adds matrix, matmul, transpose, inverse and the
+// elementwise m+, m-, m* and m/
+func setupMatrixOperations(env *Environment) {
+	env.Set(Intern("matrix"), &BuiltinFunction{
+		Name: "matrix",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("matrix expects 1 argument, got %d", len(args))
+			}
+			m, err := matrixFromRows(args[0])
+			if err != nil {
+				return nil, NewTypeError("matrix %v", err)
+			}
+			return m, nil
+		},
+	})
+
+	env.Set(Intern("identity-matrix"), &BuiltinFunction{
+		Name: "identity-matrix",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("identity-matrix expects 1 argument, got %d", len(args))
+			}
+			n, ok := args[0].(Number)
+			if !ok || !n.IsInteger() || n.ToInt() < 1 {
+				return nil, NewTypeError("identity-matrix expects a positive size, got %s", args[0])
+			}
+			return identityMatrix(int(n.ToInt())), nil
+		},
+	})
+
+	env.Set(Intern("matrix?"), &BuiltinFunction{
+		Name: "matrix?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("matrix? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(*Matrix); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	unary := map[string]func(m *Matrix) (Value, error){
+		"shape":       func(m *Matrix) (Value, error) { return NewVector(NewNumber(m.rows), NewNumber(m.cols)), nil },
+		"matrix-rows": func(m *Matrix) (Value, error) { return m.rowValues(), nil },
+		"transpose":   func(m *Matrix) (Value, error) { return m.transpose(), nil },
+		"inverse":     func(m *Matrix) (Value, error) { return m.inverse() },
+		"determinant": func(m *Matrix) (Value, error) {
+			if m.rows != m.cols {
+				return nil, NewRuntimeError("determinant expects a square matrix, got %dx%d", m.rows, m.cols)
+			}
+			return NewNumber(m.eliminate(nil)), nil
+		},
+	}
+	for name, fn := range unary {
+		name, fn := name, fn
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				m, err := matrixArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				return fn(m)
+			},
+		})
+	}
+
+	env.Set(Intern("mget"), &BuiltinFunction{
+		Name: "mget",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("mget expects 3 arguments, got %d", len(args))
+			}
+			m, err := matrixArg("mget", args[0])
+			if err != nil {
+				return nil, err
+			}
+			i, iOK := args[1].(Number)
+			j, jOK := args[2].(Number)
+			if !iOK || !jOK || !i.IsInteger() || !j.IsInteger() {
+				return nil, NewTypeError("mget expects integer row and column indices")
+			}
+			row, col := int(i.ToInt()), int(j.ToInt())
+			if row < 0 || row >= m.rows || col < 0 || col >= m.cols {
+				return nil, NewRuntimeError("mget index [%d %d] out of bounds for %dx%d matrix", row, col, m.rows, m.cols)
+			}
+			return NewNumber(m.at(row, col)), nil
+		},
+	})
+
+	// (matmul a b c ...) multiplies left to right
+	env.Set(Intern("matmul"), &BuiltinFunction{
+		Name: "matmul",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("matmul expects at least 2 arguments, got %d", len(args))
+			}
+			product, err := matrixArg("matmul", args[0])
+			if err != nil {
+				return nil, err
+			}
+			for _, arg := range args[1:] {
+				m, err := matrixArg("matmul", arg)
+				if err != nil {
+					return nil, err
+				}
+				if product, err = matmul(product, m); err != nil {
+					return nil, err
+				}
+			}
+			return product, nil
+		},
+	})
+
+	ops := map[string]func(x, y float64) float64{
+		"m+": func(x, y float64) float64 { return x + y },
+		"m-": func(x, y float64) float64 { return x - y },
+		"m*": func(x, y float64) float64 { return x * y },
+		"m/": func(x, y float64) float64 { return x / y },
+	}
+	for name, op := range ops {
+		name, op := name, op
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) < 2 {
+					return nil, NewArityError("%s expects at least 2 arguments, got %d", name, len(args))
+				}
+				result := args[0]
+				for _, arg := range args[1:] {
+					var err error
+					if result, err = elementwise(name, result, arg, op); err != nil {
+						return nil, err
+					}
+				}
+				return result, nil
+			},
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMatrixOperations(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def a (matrix [[1 2] [3 4]]))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`a`, `#matrix [[1.0 2.0] [3.0 4.0]]`},
+		{`#matrix [[1 2] [3 4]]`, `#matrix [[1.0 2.0] [3.0 4.0]]`},
+		{`(= a #matrix [[1 2] [3 4]])`, `true`},
+		{`(= a (matrix [[1 2] [3 5]]))`, `nil`},
+		{`(matrix (list (vector 1 2)))`, `#matrix [[1.0 2.0]]`},
+		{`(shape (matrix [[1 2 3] [4 5 6]]))`, `[2 3]`},
+		{`(matrix-rows a)`, `[[1.0 2.0] [3.0 4.0]]`},
+		{`(mget a 1 0)`, `3.0`},
+		{`(matrix? a)`, `true`},
+		{`(matrix? [[1]])`, `nil`},
+		{`(identity-matrix 2)`, `#matrix [[1.0 0.0] [0.0 1.0]]`},
+		{`(transpose (matrix [[1 2 3] [4 5 6]]))`, `#matrix [[1.0 4.0] [2.0 5.0] [3.0 6.0]]`},
+		{`(matmul a a)`, `#matrix [[7.0 10.0] [15.0 22.0]]`},
+		{`(matmul (matrix [[1 2 3]]) (matrix [[1] [2] [3]]))`, `#matrix [[14.0]]`},
+		{`(matmul a (identity-matrix 2) a)`, `#matrix [[7.0 10.0] [15.0 22.0]]`},
+		{`(inverse (matrix [[2 0] [0 4]]))`, `#matrix [[0.5 0.0] [0.0 0.25]]`},
+		{`(determinant a)`, `-2.0`},
+		{`(determinant (matrix [[0 1] [1 0]]))`, `-1.0`},
+		{`(determinant (matrix [[1 2] [2 4]]))`, `0.0`},
+		{`(m+ a 1)`, `#matrix [[2.0 3.0] [4.0 5.0]]`},
+		{`(m* 2 a)`, `#matrix [[2.0 4.0] [6.0 8.0]]`},
+		{`(m- a a)`, `#matrix [[0.0 0.0] [0.0 0.0]]`},
+		{`(m/ a a 2)`, `#matrix [[0.5 0.5] [0.5 0.5]]`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// A matrix times its inverse is the identity, up to rounding
+	m := evalAll(t, env, `(matmul (matrix [[4 7 2] [3 6 1] [2 5 3]]) (inverse (matrix [[4 7 2] [3 6 1] [2 5 3]])))`).(*Matrix)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			want := 0.0
+			if i == j {
+				want = 1
+			}
+			if math.Abs(m.at(i, j)-want) > 1e-9 {
+				t.Errorf("Expected identity, got %s", m)
+			}
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(matrix [[1 2] [3]])`:                "expected rows of 2 numbers, row 1 has 1",
+		`(matrix [])`:                         "non-empty collection of rows",
+		`(matrix [["a"]])`:                    `expected numbers, got "a"`,
+		`(matmul a (matrix [[1 2 3]]))`:       "cannot multiply 2x2 by 1x3",
+		`(inverse (matrix [[1 2] [2 4]]))`:    "matrix is singular",
+		`(inverse (matrix [[1 2]]))`:          "square matrix",
+		`(m+ a (matrix [[1]]))`:               "same size",
+		`(mget a 2 0)`:                        "out of bounds",
+		`(read-string "#matrix [[1] [1 2]]")`: "#matrix",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Module is a named set of definitions made with (module name body...).
+// Other code reaches the names it exports as name.symbol, or brings them
+// into scope with (import name) or (import name symbol...).
+type Module struct {
+	Name    Symbol
+	env     *Environment
+	exports []Symbol
+}
+
+func (m *Module) String() string {
+	names := make([]string, len(m.exports))
+	for i, name := range m.exports {
+		names[i] = string(name)
+	}
+	return fmt.Sprintf("#<module %s [%s]>", m.Name, strings.Join(names, " "))
+}
+
+// exported returns the current value of an exported name
+func (m *Module) exported(name Symbol) (Value, error) {
+	for _, export := range m.exports {
+		if export == name {
+			return m.env.Get(name)
+		}
+	}
+	return nil, NewNameError("module %s does not export %s", m.Name, name)
+}
+
+// moduleTable holds the modules defined in a root environment
+type moduleTable struct {
+	sync.Mutex
+	byName map[Symbol]*Module
+}
+
+// moduleTable returns the table of the root environment
+func (env *Environment) moduleTable() *moduleTable {
+	root := env.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.moduleDefs == nil {
+		root.moduleDefs = &moduleTable{byName: make(map[Symbol]*Module)}
+	}
+	return root.moduleDefs
+}
+
+func (env *Environment) lookupModule(name Symbol) (*Module, bool) {
+	table := env.moduleTable()
+	table.Lock()
+	defer table.Unlock()
+	module, ok := table.byName[name]
+	return module, ok
+}
+
+// evalModule evaluates (module name body...) in a fresh environment below
+// the root, collecting the names listed by (export ...) forms in the body.
+// Defining a module again replaces it.
+func evalModule(args *List, env *Environment) (Value, error) {
+	forms := listToSlice(args)
+	if len(forms) < 1 {
+		return nil, NewArityError("module expects a name and a body, got no arguments")
+	}
+	name, ok := forms[0].(Symbol)
+	if !ok {
+		return nil, NewTypeError("module expects a symbol name, got %s", printString(forms[0]))
+	}
+
+	module := &Module{Name: name, env: NewEnvironment(env.root())}
+	for _, form := range forms[1:] {
+		if list, ok := form.(*List); ok && !list.IsEmpty() && list.First() == Intern("export") {
+			for _, export := range listToSlice(list.Rest()) {
+				sym, ok := export.(Symbol)
+				if !ok {
+					return nil, NewTypeError("export expects symbols, got %s", printString(export))
+				}
+				module.exports = append(module.exports, sym)
+			}
+			continue
+		}
+		if _, err := Eval(form, module.env); err != nil {
+			return nil, fmt.Errorf("in module %s: %v", name, err)
+		}
+	}
+	for _, export := range module.exports {
+		if _, ok := module.env.binding(export); !ok {
+			return nil, NewNameError("module %s exports %s, which it does not define", name, export)
+		}
+	}
+
+	table := env.moduleTable()
+	table.Lock()
+	table.byName[name] = module
+	table.Unlock()
+	return module, nil
+}
+
+// evalImport binds the exports of a module, or the listed ones, in env. A
+// module that is not defined yet is loaded like a namespace first, so
+// (import utils) finds a (module utils ...) in utils.lisp on *load-path*.
+func evalImport(args *List, env *Environment) (Value, error) {
+	forms := listToSlice(args)
+	if len(forms) < 1 {
+		return nil, NewArityError("import expects a module name, got no arguments")
+	}
+	name, ok := forms[0].(Symbol)
+	if !ok {
+		return nil, NewTypeError("import expects a module name, got %s", printString(forms[0]))
+	}
+
+	module, ok := env.lookupModule(name)
+	if !ok {
+		if err := checkCapabilities("import", []Capability{CapabilityFS}, env); err != nil {
+			return nil, err
+		}
+		if err := loadNamespace(name, env); err != nil {
+			return nil, NewNameError("no module %s: %v", name, err)
+		}
+		if module, ok = env.lookupModule(name); !ok {
+			return nil, NewNameError("loading %s did not define module %s", name, name)
+		}
+	}
+
+	names := module.exports
+	if len(forms) > 1 {
+		names = nil
+		for _, form := range forms[1:] {
+			sym, ok := form.(Symbol)
+			if !ok {
+				return nil, NewTypeError("import expects symbols to import, got %s", printString(form))
+			}
+			names = append(names, sym)
+		}
+	}
+
+	imported := make([]Value, len(names))
+	for i, sym := range names {
+		value, err := module.exported(sym)
+		if err != nil {
+			return nil, err
+		}
+		if err := env.define(sym, value); err != nil {
+			return nil, err
+		}
+		imported[i] = sym
+	}
+	return NewVector(imported...), nil
+}
+
+// lookupSymbol resolves sym in env, falling back to the modules: a module by
+// its name, an export of a module as module.name, or a builtin by its
+// registry category as category.name, like functional.identity
+func lookupSymbol(sym Symbol, env *Environment) (Value, error) {
+	value, err := env.Get(sym)
+	if err == nil {
+		return value, nil
+	}
+	if module, ok := env.lookupModule(sym); ok {
+		return module, nil
+	}
+
+	i := strings.LastIndex(string(sym), ".")
+	if i <= 0 || i == len(sym)-1 {
+		return nil, undefinedSymbol(sym, env)
+	}
+	prefix, name := sym[:i], sym[i+1:]
+
+	if module, ok := env.lookupModule(prefix); ok {
+		return module.exported(name)
+	}
+	if entry, value, ok := lookupFunction(name, env); ok && entry.category == string(prefix) {
+		return value, nil
+	}
+	return nil, undefinedSymbol(sym, env)
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestModules(t *testing.T) {
+	env := NewCoreEnvironment()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "geometry.lisp"), []byte(`
+		(module geometry
+		  (export area)
+		  (def pi-ish 3)
+		  (defn area [r] (* pi-ish r r)))`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.Set(Intern("*load-path*"), NewVector(String(dir)))
+	evalAll(t, env, `
+		(module math-utils
+		  (export square cube)
+		  (defn helper [x] (* x x))
+		  (defn square [x] (helper x))
+		  (defn cube [x] (* x (square x))))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`math-utils`, `#<module math-utils [square cube]>`},
+		{`(math-utils.square 5)`, `25`},
+		{`(apply math-utils.cube (list 2))`, `8`},
+		{`(functional.identity 7)`, `7`},
+		{`(import math-utils cube)`, `[cube]`},
+		{`(cube 3)`, `27`},
+		{`(import geometry)`, `[area]`},
+		{`(area 2)`, `12`},
+		// Redefining a module replaces it
+		{`(do (module math-utils (export square) (defn square [x] 0)) (math-utils.square 5))`, `0`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`helper`:                     "undefined symbol: helper",
+		`(math-utils.helper 1)`:      "does not export helper",
+		`(import math-utils helper)`: "does not export helper",
+		`(import missing)`:           "no module missing",
+		`(module m (export f))`:      "does not define",
+		`(module m (export 1))`:      "export expects symbols",
+		`(export f)`:                 "top level of a module",
+		`(collections.identity 1)`:   "undefined symbol",
+	} {
+		expr, err := ReadString(input)
+		if err == nil {
+			_, err = Eval(expr, env)
+		}
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
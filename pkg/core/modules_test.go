@@ -0,0 +1,321 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestRequireAndReload(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	dir := t.TempDir()
+
+	if err := os.MkdirAll(filepath.Join(dir, "my"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	nsFile := filepath.Join(dir, "my", "ns.lisp")
+	if err := os.WriteFile(nsFile, []byte(`(def loads 1) (defn greet [] "v1")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	eval := func(input string) string {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		return result.String()
+	}
+
+	env.Set(core.Intern("*load-path*"), core.NewVector(core.String(dir)))
+	eval(`(require 'my.ns)`)
+	eval(`(def loads 2)`)
+	eval(`(require 'my.ns)`)
+
+	if got := eval(`loads`); got != "2" {
+		t.Errorf("Expected require to be idempotent, loads = %s", got)
+	}
+	if got := eval(`(loaded-namespaces)`); got != "[my.ns]" {
+		t.Errorf("Expected [my.ns], got %s", got)
+	}
+	if changed := core.ChangedNamespaces(env); len(changed) != 0 {
+		t.Errorf("Expected no changed namespaces, got %v", changed)
+	}
+
+	if err := os.WriteFile(nsFile, []byte(`(defn greet [] "v2")`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(nsFile, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := core.ChangedNamespaces(env)
+	if len(changed) != 1 || changed[0] != "my.ns" {
+		t.Fatalf("Expected my.ns to be changed, got %v", changed)
+	}
+
+	eval(`(reload 'my.ns)`)
+	if got := eval(`(greet)`); got != `"v2"` {
+		t.Errorf("Expected reloaded definition, got %s", got)
+	}
+	if got := eval(`loads`); got != "2" {
+		t.Errorf("Expected other state to be preserved, loads = %s", got)
+	}
+	if changed := core.ChangedNamespaces(env); len(changed) != 0 {
+		t.Errorf("Expected no changes after reload, got %v", changed)
+	}
+
+	expr, _ := core.ReadString(`(require 'missing.ns)`)
+	if _, err := core.Eval(expr, env); err == nil {
+		t.Errorf("Expected error requiring a missing namespace")
+	}
+}
+
+func TestCompatNamespace(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.SetReaderConfig(core.ReaderConfig{LegacyAliases: true})
+
+	eval := func(input string) string {
+		t.Helper()
+		expr, err := env.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		return result.String()
+	}
+
+	// compat is found without being on *load-path*
+	env.Set(core.Intern("*load-path*"), core.NewVector())
+	eval(`(require 'compat)`)
+	eval(`(define double (lambda (x) (begin (* 2 x))))`)
+	eval(`(defun twice (f x) (f (f x)))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(twice double 3)`, `12`},
+		{`(length (list 1 2 3))`, `3`},
+		{`(list #t #f)`, `(true nil)`},
+		{`(env)`, `("double" "twice")`},
+		{`(modules)`, `[compat]`},
+		{`(contains-item? "+" (builtins))`, `true`},
+	}
+	for _, test := range tests {
+		if got := eval(test.input); got != test.expected {
+			t.Errorf("For %s, expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestRulesModule(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(input string) (string, error) {
+		t.Helper()
+		forms, err := core.NewReader(strings.NewReader(input)).ReadAll()
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		var result core.Value = core.Nil{}
+		for _, form := range forms {
+			if result, err = core.Eval(form, env); err != nil {
+				return "", err
+			}
+		}
+		return result.String(), nil
+	}
+
+	if _, err := run(`
+		(require 'rules)
+		(defn flag [e] (assoc e :flagged true))
+		(defrule high-value {:when (> (:amount event) 1000) :then (flag event)})
+		(defrule review {:as tx :when (and (:flagged tx) (not (:review tx)))
+		                 :then (assoc tx :review true) :priority 5})
+		(defrule note {:when (:flagged event) :then nil})
+		(def facts (list (hash-map :id 1 :amount 5000) (hash-map :id 2 :amount 10)))`); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(:facts (run-rules [high-value] facts))`, `[{:id 1 :amount 5000 :flagged true}]`},
+		{`(map :rule (:fired (run-rules [high-value] facts)))`, `(high-value high-value)`},
+		// review goes before the rules of default priority on the flagged
+		// fact, and derives a fact they fire on again
+		{`(map :rule (:fired (run-rules (list high-value note review) facts)))`, `(high-value review high-value note high-value note)`},
+		{`(count (:facts (run-rules [high-value review note] facts)))`, `2`},
+		{`(run-rules [high-value] [])`, `{:fired [] :facts []}`},
+		{`(run-rules [high-value] (list (hash-map :amount 1)))`, `{:fired [] :facts []}`},
+	}
+	for _, test := range tests {
+		got, err := run(test.input)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+		} else if got != test.expected {
+			t.Errorf("For %s, expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	_, err = run(`(def *max-rule-firings* 50)
+		(defrule count-up {:as n :when true :then (+ n 1)})
+		(run-rules [count-up] (list 0))`)
+	if err == nil || !strings.Contains(err.Error(), "more than 50 rules fired") {
+		t.Errorf("Expected run-rules to stop at *max-rule-firings*, got %v", err)
+	}
+}
+
+func TestLoadCycles(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	eval := func(input string) (string, error) {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			return "", err
+		}
+		return result.String(), nil
+	}
+
+	// Loading an unchanged file again returns its result without re-evaluating it
+	counter := write("counter.lisp", `(def loads (+ loads 1)) :counted`)
+	eval(`(def loads 0)`)
+	for i := 0; i < 2; i++ {
+		if got, err := eval(`(load-file "` + counter + `")`); err != nil || got != ":counted" {
+			t.Fatalf("Expected :counted, got %s, %v", got, err)
+		}
+	}
+	if got, _ := eval(`loads`); got != "1" {
+		t.Errorf("Expected the file to be evaluated once, loads = %s", got)
+	}
+
+	// A changed file is loaded again
+	write("counter.lisp", `(def loads (+ loads 10)) :changed`)
+	later := time.Now().Add(time.Second)
+	if err := os.Chtimes(counter, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := eval(`(load-file "` + counter + `")`); err != nil || got != ":changed" {
+		t.Fatalf("Expected :changed, got %s, %v", got, err)
+	}
+	if got, _ := eval(`loads`); got != "11" {
+		t.Errorf("Expected the changed file to be evaluated, loads = %s", got)
+	}
+
+	// Files that load each other report the chain
+	a := filepath.Join(dir, "a.lisp")
+	b := write("b.lisp", `(load-file "`+a+`")`)
+	write("a.lisp", `(load-file "`+b+`")`)
+	_, err := eval(`(load-file "` + a + `")`)
+	want := "circular load: " + a + " -> " + b + " -> " + a
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("Expected error containing %q, got %v", want, err)
+	}
+
+	// A file that loads itself
+	self := filepath.Join(dir, "self.lisp")
+	write("self.lisp", `(load-file "`+self+`")`)
+	if _, err := eval(`(load-file "` + self + `")`); err == nil || !strings.Contains(err.Error(), "circular load") {
+		t.Errorf("Expected circular load error, got %v", err)
+	}
+
+	// Namespaces that require each other
+	write("ping.lisp", `(require 'pong)`)
+	write("pong.lisp", `(require 'ping)`)
+	env.Set(core.Intern("*load-path*"), core.NewVector(core.String(dir)))
+	_, err = eval(`(require 'ping)`)
+	if err == nil || !strings.Contains(err.Error(), "circular load") || !strings.Contains(err.Error(), "pong.lisp") {
+		t.Errorf("Expected circular require error, got %v", err)
+	}
+
+	// Nothing is left marked as loading after the errors
+	if got, err := eval(`(load-file "` + counter + `")`); err != nil || got != ":changed" {
+		t.Errorf("Expected :changed, got %s, %v", got, err)
+	}
+}
+
+func TestLoadFileRelative(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"app/main.lisp":     `(load-file "lib/util.lisp") (def main-file *file*) (def main-dir *dir*)`,
+		"app/lib/util.lisp": `(load-file "../config.lisp") (def util-dir *dir*)`,
+		"app/config.lisp":   `(def config-file *file*)`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eval := func(input string) string {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		return result.String()
+	}
+
+	app := filepath.Join(dir, "app")
+	eval(`(load-file "` + filepath.Join(app, "main.lisp") + `")`)
+
+	tests := map[string]string{
+		"main-file":   filepath.Join(app, "main.lisp"),
+		"main-dir":    app,
+		"util-dir":    filepath.Join(app, "lib"),
+		"config-file": filepath.Join(app, "config.lisp"),
+	}
+	for name, want := range tests {
+		if got := eval(name); got != `"`+want+`"` {
+			t.Errorf("Expected %s to be %q, got %s", name, want, got)
+		}
+	}
+
+	// Outside of a file they are nil again
+	if got := eval(`(list *file* *dir*)`); got != "(nil nil)" {
+		t.Errorf("Expected (nil nil) after loading, got %s", got)
+	}
+}
@@ -0,0 +1,192 @@
+package core_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// fakeNATS is a single-connection NATS server that delivers each PUB to the
+// subscriptions with exactly that subject
+func fakeNATS(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO {\"server_id\":\"fake\"}\r\n")
+
+		subs := make(map[string][]string) // subject -> sids
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "PING":
+				fmt.Fprintf(conn, "PONG\r\n")
+			case "SUB":
+				subs[fields[1]] = append(subs[fields[1]], fields[2])
+			case "UNSUB":
+				for subject, sids := range subs {
+					for i, sid := range sids {
+						if sid == fields[1] {
+							subs[subject] = append(sids[:i], sids[i+1:]...)
+							break
+						}
+					}
+				}
+			case "PUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				payload := make([]byte, size+2)
+				if _, err := io.ReadFull(r, payload); err != nil {
+					return
+				}
+				for _, sid := range subs[fields[1]] {
+					fmt.Fprintf(conn, "MSG %s %s %d\r\n%s", fields[1], sid, size, payload)
+				}
+			}
+		}
+	}()
+	return "nats://" + listener.Addr().String()
+}
+
+func TestMessageQueue(t *testing.T) {
+	address := fakeNATS(t)
+	env := core.NewCoreEnvironment()
+	eval := func(input string) core.Value {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Read error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		return result
+	}
+
+	eval(`(def conn (mq-connect "` + address + `"))`)
+	eval(`(def received (atom (list)))`)
+	eval(`(def sid (mq-subscribe conn "orders" (fn [msg] (swap! received (fn [xs] (cons (:data msg) xs))))))`)
+	eval(`(mq-publish conn "orders" "first")`)
+	eval(`(mq-publish conn "orders" (hash-map :id 2))`)
+	eval(`(mq-publish conn "other" "ignored")`)
+	eval(`(mq-flush conn)`)
+
+	// Handlers only run when the interpreter dispatches
+	if result := eval("@received"); result.String() != "()" {
+		t.Errorf("Expected no handler calls before mq-dispatch, got %s", result)
+	}
+	if result := eval("(mq-dispatch conn 1000)"); result.String() != "2" {
+		t.Errorf("Expected 2 handler calls, got %s", result)
+	}
+	if result := eval("@received"); result.String() != `("{:id 2}" "first")` {
+		t.Errorf("Unexpected messages: %s", result)
+	}
+
+	eval("(mq-unsubscribe conn sid)")
+	eval(`(mq-publish conn "orders" "late")`)
+	eval("(mq-flush conn)")
+	if result := eval("(mq-dispatch conn 50)"); result.String() != "0" {
+		t.Errorf("Expected no handler calls after unsubscribing, got %s", result)
+	}
+
+	eval("(mq-close conn)")
+	for input, message := range map[string]string{
+		`(mq-publish conn "orders" "closed")`: "connection closed",
+		`(mq-publish conn "bad subject" "x")`: "subject string without spaces",
+		`(mq-connect "http://localhost")`:     "unsupported scheme",
+	} {
+		expr, _ := core.ReadString(input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+
+	sandboxed := core.NewCoreEnvironment()
+	sandboxed.DenyCapabilities(core.CapabilityNet)
+	expr, _ := core.ReadString(`(mq-connect "` + address + `")`)
+	if _, err := core.Eval(expr, sandboxed); err == nil || !strings.Contains(err.Error(), "net") {
+		t.Errorf("Expected mq-connect to need the net capability, got: %v", err)
+	}
+}
+
+// syncBuffer collects warnings printed from the finalizer goroutine
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestForgottenConnectionIsClosed(t *testing.T) {
+	for name, source := range map[string]string{
+		"idle": `(mq-connect "%s")`,
+		// The handler refers to the connection it is subscribed on
+		"subscribed": `(let [conn (mq-connect "%s")]
+		                 (mq-subscribe conn "orders" (fn [msg] (mq-publish conn "seen" (:data msg))))
+		                 conn)`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			address := fakeNATS(t)
+			var warnings syncBuffer
+
+			// The connection is dropped as soon as it is described
+			description := func() string {
+				expr, _ := core.ReadString(fmt.Sprintf(source, address))
+				env := core.NewCoreEnvironment()
+				env.SetErrorOutput(&warnings)
+				conn, err := core.Eval(expr, env)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return conn.String()
+			}()
+
+			for deadline := time.Now().Add(5 * time.Second); !strings.Contains(warnings.String(), "never closed"); {
+				if time.Now().After(deadline) {
+					t.Fatal("Expected a forgotten connection to be closed when collected")
+				}
+				runtime.GC()
+				time.Sleep(10 * time.Millisecond)
+			}
+			if got := warnings.String(); got != "WARNING: "+description+" was never closed; closing it now\n" {
+				t.Errorf("Unexpected warning: %q", got)
+			}
+		})
+	}
+}
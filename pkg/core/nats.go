@@ -0,0 +1,319 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsConn is a minimal client for the NATS text protocol: CONNECT, PUB,
+// SUB, UNSUB and PING/PONG, with messages read on a background goroutine
+
+const (
+	natsDefaultPort       = "4222"
+	natsTimeout           = 5 * time.Second
+	natsDefaultMaxPayload = 1 << 20 // The server default, if INFO doesn't say
+)
+
+var errNATSClosed = errors.New("connection closed")
+
+type natsMsg struct {
+	sid     int64 // The subscription it was delivered to
+	subject string
+	reply   string
+	data    []byte
+}
+
+type natsConn struct {
+	url        string
+	conn       net.Conn
+	maxPayload int       // Largest message the server accepts or sends
+	reports    *reporter // Warned about server errors that leave the connection open
+
+	writeMu sync.Mutex
+	w       *bufio.Writer
+
+	mu      sync.Mutex
+	subs    map[int64]func(natsMsg)
+	nextSID int64
+	pongs   []chan struct{} // Waiting flushes, answered in order
+	err     error           // Why the connection closed
+	failed  error           // A server error for the next operation, under --werror
+}
+
+// dialNATS connects to a nats:// URL, sending any user and password in it,
+// reporting to reports
+func dialNATS(rawURL string, reports *reporter) (*natsConn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid NATS URL %q", rawURL)
+	}
+	if u.Scheme != "nats" && u.Scheme != "" {
+		return nil, fmt.Errorf("unsupported scheme %q, expected nats://", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), natsDefaultPort)
+	}
+
+	conn, err := net.DialTimeout("tcp", host, natsTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{
+		url:        "nats://" + host,
+		conn:       conn,
+		maxPayload: natsDefaultMaxPayload,
+		reports:    reports,
+		w:          bufio.NewWriter(conn),
+		subs:       make(map[int64]func(natsMsg)),
+	}
+
+	// The server greets with INFO before accepting CONNECT
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(natsTimeout))
+	line, err := r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, fmt.Errorf("no INFO from NATS server at %s", host)
+	}
+	conn.SetReadDeadline(time.Time{})
+	var info struct {
+		MaxPayload int `json:"max_payload"`
+	}
+	if json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info) == nil && info.MaxPayload > 0 {
+		c.maxPayload = info.MaxPayload
+	}
+
+	options := map[string]any{"verbose": false, "pedantic": false, "name": "golisp", "lang": "go", "protocol": 0}
+	if u.User != nil {
+		options["user"] = u.User.Username()
+		if password, ok := u.User.Password(); ok {
+			options["pass"] = password
+		}
+	}
+	connect, _ := json.Marshal(options)
+	if err := c.send("CONNECT " + string(connect) + "\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	go c.readLoop(r)
+	if err := c.flush(); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *natsConn) send(command string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.w.WriteString(command); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *natsConn) publish(subject string, data []byte) error {
+	if err := c.usable(); err != nil {
+		return err
+	}
+	if len(data) > c.maxPayload {
+		return fmt.Errorf("message of %d bytes exceeds the server's maximum payload of %d", len(data), c.maxPayload)
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	fmt.Fprintf(c.w, "PUB %s %d\r\n", subject, len(data))
+	c.w.Write(data)
+	c.w.WriteString("\r\n")
+	return c.w.Flush()
+}
+
+func (c *natsConn) subscribe(subject string, deliver func(natsMsg)) (int64, error) {
+	if err := c.usable(); err != nil {
+		return 0, err
+	}
+	c.mu.Lock()
+	c.nextSID++
+	sid := c.nextSID
+	c.subs[sid] = deliver
+	c.mu.Unlock()
+	return sid, c.send(fmt.Sprintf("SUB %s %d\r\n", subject, sid))
+}
+
+func (c *natsConn) unsubscribe(sid int64) error {
+	c.mu.Lock()
+	_, ok := c.subs[sid]
+	delete(c.subs, sid)
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no subscription %d", sid)
+	}
+	return c.send(fmt.Sprintf("UNSUB %d\r\n", sid))
+}
+
+// flush waits until the server has processed everything sent so far
+func (c *natsConn) flush() error {
+	pong := make(chan struct{})
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	c.pongs = append(c.pongs, pong)
+	c.mu.Unlock()
+
+	if err := c.send("PING\r\n"); err != nil {
+		return err
+	}
+	select {
+	case <-pong:
+		// The server answers in order, so errors about what was sent
+		// before the PING have arrived
+		return c.usable()
+	case <-time.After(natsTimeout):
+		return fmt.Errorf("timed out waiting for the NATS server")
+	}
+}
+
+func (c *natsConn) closedErr() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// usable returns why the connection closed, or else the server error
+// waiting for the next operation
+func (c *natsConn) usable() error {
+	if err := c.closedErr(); err != nil {
+		return err
+	}
+	return c.serverError()
+}
+
+// serverError returns the server error waiting for the next operation,
+// once
+func (c *natsConn) serverError() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	err := c.failed
+	c.failed = nil
+	return err
+}
+
+func (c *natsConn) close() {
+	c.abort(errNATSClosed)
+}
+
+// abort ends the connection for the reason given
+func (c *natsConn) abort(err error) {
+	c.fail(err)
+	c.conn.Close()
+}
+
+// fail records why the connection ended and releases waiting flushes
+func (c *natsConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	for _, pong := range c.pongs {
+		close(pong)
+	}
+	c.pongs = nil
+}
+
+func (c *natsConn) readLoop(r *bufio.Reader) {
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				err = errNATSClosed
+			}
+			c.fail(err)
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		op, args, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(op) {
+		case "MSG":
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(args)
+			if len(fields) < 3 || len(fields) > 4 {
+				c.abort(fmt.Errorf("malformed MSG: %s", line))
+				return
+			}
+			size, err1 := strconv.Atoi(fields[len(fields)-1])
+			sid, err2 := strconv.ParseInt(fields[1], 10, 64)
+			if err1 != nil || err2 != nil {
+				c.abort(fmt.Errorf("malformed MSG: %s", line))
+				return
+			}
+			// The size is checked before allocating, so a bad server can't
+			// make us panic or exhaust memory
+			if size < 0 || size > c.maxPayload {
+				c.abort(fmt.Errorf("MSG size %d outside 0 to the maximum payload of %d", size, c.maxPayload))
+				return
+			}
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				c.abort(err)
+				return
+			}
+			msg := natsMsg{sid: sid, subject: fields[0], data: data[:size]}
+			if len(fields) == 4 {
+				msg.reply = fields[2]
+			}
+			c.mu.Lock()
+			deliver := c.subs[sid]
+			c.mu.Unlock()
+			if deliver != nil {
+				deliver(msg)
+			}
+		case "PING":
+			c.send("PONG\r\n")
+		case "PONG":
+			c.mu.Lock()
+			if len(c.pongs) > 0 {
+				close(c.pongs[0])
+				c.pongs = c.pongs[1:]
+			}
+			c.mu.Unlock()
+		case "-ERR":
+			message := strings.Trim(args, "'")
+			if !natsFatal(message) {
+				// When warnings are errors, the next operation fails instead
+				if err := c.reports.warn("NATS server error: "+message, Position{}, false); err != nil {
+					c.mu.Lock()
+					if c.failed == nil {
+						c.failed = err
+					}
+					c.mu.Unlock()
+				}
+				continue
+			}
+			c.abort(fmt.Errorf("NATS server error: %s", message))
+			return
+		}
+	}
+}
+
+// natsFatal reports whether the server closes the connection after the
+// -ERR message; it keeps it open after a bad subject or a permissions
+// violation on one subject
+func natsFatal(message string) bool {
+	message = strings.ToLower(message)
+	return !strings.HasPrefix(message, "invalid subject") && !strings.HasPrefix(message, "permissions violation")
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedNATS is a NATS server that answers PINGs, sending the given lines
+// before answering the second: the first flush after connecting
+func scriptedNATS(t *testing.T, info string, lines ...string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprintf(conn, "INFO %s\r\n", info)
+		r := bufio.NewReader(conn)
+		pings := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.HasPrefix(line, "PING") {
+				if pings++; pings == 2 {
+					for _, l := range lines {
+						fmt.Fprintf(conn, "%s\r\n", l)
+					}
+				}
+				fmt.Fprintf(conn, "PONG\r\n")
+			}
+		}
+	}()
+	return "nats://" + listener.Addr().String()
+}
+
+// waitClosed has the server send its script, then waits for the
+// connection to fail and returns why
+func waitClosed(t *testing.T, c *natsConn) error {
+	t.Helper()
+	c.flush()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if err := c.closedErr(); err != nil {
+			return err
+		}
+	}
+	t.Fatal("Expected the connection to close")
+	return nil
+}
+
+func TestNATSMessageSize(t *testing.T) {
+	for _, test := range []struct {
+		msg     string
+		message string
+	}{
+		{"MSG a 1 -5", "MSG size -5 outside 0 to the maximum payload of 16"},
+		{"MSG a 1 1000000000000", "MSG size 1000000000000 outside"},
+		{"MSG a 1 17", "MSG size 17 outside"},
+	} {
+		c, err := dialNATS(scriptedNATS(t, `{"server_id":"fake","max_payload":16}`, test.msg), NewCoreEnvironment().interpreterState().reports)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := waitClosed(t, c); !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.msg, test.message, err)
+		}
+	}
+
+	c, err := dialNATS(scriptedNATS(t, `{"server_id":"fake","max_payload":16}`), NewCoreEnvironment().interpreterState().reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	if err := c.publish("a", make([]byte, 17)); err == nil || !strings.Contains(err.Error(), "maximum payload of 16") {
+		t.Errorf("Expected an oversized publish to be refused, got %v", err)
+	}
+}
+
+func TestNATSServerErrors(t *testing.T) {
+	// Warnings are written by the read loop before it records the fatal
+	// error, which waitClosed waits for
+	var warnings strings.Builder
+	env := NewCoreEnvironment()
+	env.SetErrorOutput(&warnings)
+
+	// Errors about one subject leave the connection open
+	c, err := dialNATS(scriptedNATS(t, `{"server_id":"fake"}`,
+		"-ERR 'Permissions Violation for Publish to secret'",
+		"-ERR 'Invalid Subject'",
+		"-ERR 'Authorization Violation'"), env.interpreterState().reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waitClosed(t, c); err.Error() != "NATS server error: Authorization Violation" {
+		t.Errorf("Expected the fatal error to close the connection, got %v", err)
+	}
+	for _, expected := range []string{"Permissions Violation for Publish to secret", "Invalid Subject"} {
+		if !strings.Contains(warnings.String(), expected) {
+			t.Errorf("Expected a warning about %q, got %q", expected, warnings.String())
+		}
+	}
+}
+
+func TestNATSServerErrorsAsErrors(t *testing.T) {
+	var warnings strings.Builder
+	env := NewCoreEnvironment()
+	env.SetErrorOutput(&warnings)
+	env.SetWarningsAsErrors(true)
+	script := []string{"-ERR 'Invalid Subject'"}
+
+	// The flush waiting when the error arrives fails with it, once
+	c, err := dialNATS(scriptedNATS(t, `{"server_id":"fake"}`, script...), env.interpreterState().reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.close()
+	if err := c.flush(); err == nil || err.Error() != "RuntimeError: warning treated as error: NATS server error: Invalid Subject" {
+		t.Errorf("Expected the server error to fail the flush, got %v", err)
+	}
+	if err := c.flush(); err != nil {
+		t.Errorf("Expected the error to be reported once, got %v", err)
+	}
+
+	// So does dispatching to subscribers
+	c2, err := dialNATS(scriptedNATS(t, `{"server_id":"fake"}`, script...), env.interpreterState().reports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := &MQConnection{conn: c2, pending: make(chan natsMsg), closed: make(chan struct{}), handlers: make(map[int64]Value)}
+	defer conn.close()
+	c2.send("PING\r\n")
+	if _, err := conn.dispatch(500*time.Millisecond, env); err == nil || !strings.Contains(err.Error(), "Invalid Subject") {
+		t.Errorf("Expected the server error to fail dispatch, got %v", err)
+	}
+	if warnings.Len() != 0 {
+		t.Errorf("Expected errors instead of warnings, got %q", warnings.String())
+	}
+}
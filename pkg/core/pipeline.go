@@ -0,0 +1,272 @@
+package core
+
+import "sync"
+
+// pipelineStage is one step of a pipeline's :xf, such as (map parse)
+type pipelineStage struct {
+	kind Keyword
+	fn   Value
+}
+
+// pipelineStageKinds are the steps :xf accepts
+var pipelineStageKinds = map[Keyword]bool{
+	"map": true, "filter": true, "remove": true, "keep": true, "mapcat": true,
+}
+
+// pipeline is a parsed run-pipeline call. Items flow from source through
+// bounded channels to workers running the stages, and from them to sink
+// on the calling goroutine, so a slow sink holds back the workers and they
+// hold back reading the source.
+type pipeline struct {
+	source  Value
+	stages  []pipelineStage
+	sink    Value // nil collects the results into a vector
+	workers int
+	buffer  int
+	env     *Environment
+
+	done chan struct{} // Closed when the pipeline fails
+	once sync.Once
+	err  error
+}
+
+// parsePipeline reads the keyword/value arguments of run-pipeline
+func parsePipeline(args []Value, env *Environment) (*pipeline, error) {
+	if len(args)%2 != 0 {
+		return nil, NewArityError("run-pipeline expects keyword/value pairs")
+	}
+	p := &pipeline{workers: 1, buffer: -1, env: env, done: make(chan struct{})}
+	for i := 0; i < len(args); i += 2 {
+		value := args[i+1]
+		switch args[i] {
+		case Keyword("source"):
+			p.source = value
+		case Keyword("xf"):
+			steps, err := collectionToSlice(value)
+			if err != nil {
+				return nil, NewTypeError("run-pipeline expects :xf steps as a list, got %s", TypeName(value))
+			}
+			for _, step := range steps {
+				stage, err := parsePipelineStage(step)
+				if err != nil {
+					return nil, err
+				}
+				p.stages = append(p.stages, stage)
+			}
+		case Keyword("sink"):
+			if _, ok := value.(Callable); !ok {
+				return nil, NewTypeError("run-pipeline expects a :sink function, got %s", TypeName(value))
+			}
+			p.sink = value
+		case Keyword("workers"):
+			n, ok := value.(Number)
+			if !ok || !n.IsInteger() || n.ToInt() < 1 {
+				return nil, NewTypeError("run-pipeline expects :workers to be a positive integer, got %s", value)
+			}
+			p.workers = int(n.ToInt())
+		case Keyword("buffer"):
+			n, ok := value.(Number)
+			if !ok || !n.IsInteger() || n.ToInt() < 0 {
+				return nil, NewTypeError("run-pipeline expects :buffer to be a non-negative integer, got %s", value)
+			}
+			p.buffer = int(n.ToInt())
+		default:
+			return nil, NewRuntimeError("run-pipeline: unknown option %s, expected :source, :xf, :sink, :workers or :buffer", args[i])
+		}
+	}
+	if p.source == nil {
+		return nil, NewRuntimeError("run-pipeline expects a :source")
+	}
+	if p.buffer < 0 {
+		p.buffer = p.workers
+	}
+	return p, nil
+}
+
+// parsePipelineStage reads a step like (:map parse)
+func parsePipelineStage(step Value) (pipelineStage, error) {
+	parts, err := collectionToSlice(step)
+	if err != nil || len(parts) != 2 {
+		return pipelineStage{}, NewRuntimeError("run-pipeline expects steps like (map f), got %s", step)
+	}
+	kind, ok := parts[0].(Keyword)
+	if !ok || !pipelineStageKinds[kind] {
+		return pipelineStage{}, NewRuntimeError("run-pipeline: unknown step %s, expected map, filter, remove, keep or mapcat", parts[0])
+	}
+	if _, ok := parts[1].(Callable); !ok {
+		return pipelineStage{}, NewTypeError("run-pipeline expects a function for %s, got %s", kind, TypeName(parts[1]))
+	}
+	return pipelineStage{kind: kind, fn: parts[1]}, nil
+}
+
+// fail records the first error and stops the pipeline
+func (p *pipeline) fail(err error) {
+	p.once.Do(func() {
+		p.err = err
+		close(p.done)
+	})
+}
+
+// failed reports whether the pipeline has stopped on an error
+func (p *pipeline) failed() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// send passes x on unless the pipeline has failed
+func (p *pipeline) send(ch chan<- Value, x Value) bool {
+	select {
+	case ch <- x:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// run wires the source, workers and sink together and waits for all of
+// them, returning the collected results or the number of items sunk
+func (p *pipeline) run() (Value, error) {
+	in := make(chan Value, p.buffer)
+	out := make(chan Value, p.buffer)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(in)
+		p.guard(func() error {
+			for coll := p.source; ; {
+				x, ok, err := seqFirst(coll)
+				if err != nil || !ok {
+					return err
+				}
+				if !p.send(in, x) {
+					return nil
+				}
+				if coll, err = seqRest(coll); err != nil {
+					return err
+				}
+			}
+		})
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			p.guard(func() error {
+				for x := range in {
+					if p.failed() {
+						return nil
+					}
+					if err := p.push(0, x, out); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+		}()
+	}
+	go func() {
+		workers.Wait()
+		close(out)
+	}()
+
+	var results []Value
+	sunk := 0
+	for y := range out {
+		if p.failed() {
+			continue // Drain, so the workers can finish
+		}
+		if p.sink == nil {
+			results = append(results, y)
+			continue
+		}
+		if _, err := callFunction(p.sink, []Value{y}, p.env); err != nil {
+			p.fail(err)
+			continue
+		}
+		sunk++
+	}
+	wg.Wait()
+
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.sink == nil {
+		return NewVector(results...), nil
+	}
+	return NewNumber(sunk), nil
+}
+
+// guard runs a goroutine's work, failing the pipeline on an error or panic
+// instead of crashing the process
+func (p *pipeline) guard(work func() error) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.fail(NewRuntimeError("panic in pipeline: %v", r))
+		}
+	}()
+	if err := work(); err != nil {
+		p.fail(err)
+	}
+}
+
+// push runs x through the stages from i on, sending what comes out to out
+func (p *pipeline) push(i int, x Value, out chan<- Value) error {
+	if i == len(p.stages) {
+		p.send(out, x)
+		return nil
+	}
+	stage := p.stages[i]
+	y, err := callFunction(stage.fn, []Value{x}, p.env)
+	if err != nil {
+		return err
+	}
+	switch stage.kind {
+	case "map":
+		return p.push(i+1, y, out)
+	case "filter", "remove":
+		if isTruthy(y) == (stage.kind == "filter") {
+			return p.push(i+1, x, out)
+		}
+	case "keep":
+		if _, isNil := y.(Nil); !isNil {
+			return p.push(i+1, y, out)
+		}
+	case "mapcat":
+		for coll := y; ; {
+			z, ok, err := seqFirst(coll)
+			if err != nil || !ok {
+				return err
+			}
+			if err := p.push(i+1, z, out); err != nil {
+				return err
+			}
+			if coll, err = seqRest(coll); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setupPipelineOperations adds run-pipeline, which the pipeline macro
+// expands to
+func setupPipelineOperations(env *Environment) {
+	env.Set(Intern("run-pipeline"), &BuiltinFunction{
+		Name: "run-pipeline",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			p, err := parsePipeline(args, env)
+			if err != nil {
+				return nil, err
+			}
+			return p.run()
+		},
+	})
+}
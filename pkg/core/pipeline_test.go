@@ -0,0 +1,102 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	env, err := CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(path, []byte("1\nx\r\n22\n\n3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	env.Set(Intern("path"), String(path))
+	evalAll(t, env, `(defn parse [s] (if (= s "") nil (read-string s)))
+		(def seen (atom 0))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(line-seq path)`, `("1" "x" "22" "" "3")`},
+		{`(pipeline (source (line-seq path)) (xf (keep parse) (filter number?) (map inc)))`, `[2 23 4]`},
+		{`(pipeline (source (list 1 2 3 4)) (xf (remove even?) (mapcat (fn [x] (list x x)))))`, `[1 1 3 3]`},
+		{`(pipeline (source (vector 1 2 3)))`, `[1 2 3]`},
+		{`(pipeline (source nil) (xf (map inc)))`, `[]`},
+		{`(pipeline (source (range 100)) (sink (fn [x] (swap! seen + x))) :workers 8 :buffer 0)`, `100`},
+		{`@seen`, `4950`},
+		{`(sort (pipeline (source (range 50)) (xf (map inc)) :workers 4))`, `(1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25 26 27 28 29 30 31 32 33 34 35 36 37 38 39 40 41 42 43 44 45 46 47 48 49 50)`},
+	}
+	for _, test := range tests {
+		if got := evalAll(t, env, test.input).String(); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	failures := []struct {
+		input   string
+		message string
+	}{
+		{`(pipeline (source (range 100)) (xf (map (fn [x] (if (= x 50) (throw "bad item") x)))) :workers 4)`, "bad item"},
+		{`(pipeline (source (range 100)) (sink (fn [x] (if (= x 7) (throw "sink failed") x))))`, "sink failed"},
+		{`(pipeline (source (range 3)) (xf (take inc)))`, "unknown step :take"},
+		{`(pipeline (source (range 3)) :workers 0)`, ":workers to be a positive integer"},
+		{`(pipeline (sorce (range 3)))`, "unknown option :sorce"},
+		{`(pipeline (xf (map inc)))`, "expects a :source"},
+		{`(line-seq "/no/such/file")`, "line-seq error"},
+	}
+	for _, test := range failures {
+		if err := evalSource(t, env, test.input); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.input, test.message, err)
+		}
+	}
+}
+
+func TestPipelineBackpressure(t *testing.T) {
+	env, err := CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A source that counts how far it has been read
+	var read int64
+	var numbers func(n int64) *LazySeq
+	numbers = func(n int64) *LazySeq {
+		return NewLazySeq(func() (Value, Value, bool, error) {
+			if n == 1000 {
+				return nil, nil, false, nil
+			}
+			atomic.StoreInt64(&read, n+1)
+			return NewNumber(n), numbers(n + 1), true, nil
+		})
+	}
+	env.Set(Intern("numbers"), numbers(0))
+
+	// How many items were read but not yet sunk, at worst
+	var sunk, ahead int64
+	env.Set(Intern("record-lag"), &BuiltinFunction{
+		Name: "record-lag",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			sunk++
+			if lag := atomic.LoadInt64(&read) - sunk; lag > ahead {
+				ahead = lag
+			}
+			return Nil{}, nil
+		},
+	})
+
+	if got := evalAll(t, env, `(pipeline (source numbers) (xf (map identity)) (sink record-lag) :workers 2 :buffer 2)`).String(); got != "1000" {
+		t.Fatalf("Expected 1000 items sunk, got %s", got)
+	}
+	// Two buffers, two workers and the source each hold at most a few items
+	if ahead > 10 {
+		t.Errorf("Expected the source to stay close to the sink, it got %d items ahead", ahead)
+	}
+}
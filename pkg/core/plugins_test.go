@@ -0,0 +1,158 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+// constantPlugin defines name as a builtin returning value
+func constantPlugin(pluginName, fnName string, value Value, requires ...string) PluginFactory {
+	return func() *Plugin {
+		return &Plugin{
+			Name:       pluginName,
+			Version:    "1.0.0",
+			APIVersion: PluginAPIVersion,
+			Requires:   requires,
+			Register: func(env *Environment) error {
+				env.Set(Intern(fnName), &BuiltinFunction{
+					Name: fnName,
+					Fn: func(args []Value, env *Environment) (Value, error) {
+						return value, nil
+					},
+				})
+				return nil
+			},
+		}
+	}
+}
+
+func TestLoadPlugin(t *testing.T) {
+	RegisterPlugin("test-base", constantPlugin("test-base", "base-value", NewNumber(int64(1))))
+	RegisterPlugin("test-extra", constantPlugin("test-extra", "extra-value", NewNumber(int64(2)), "test-base"))
+
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(plugins)", "[]"},
+		{`(:name (load-plugin "test-extra"))`, `"test-extra"`},
+		{"(+ (base-value) (extra-value))", "3"},
+		{"(:name (nth (plugins) 0))", `"test-base"`},
+		{`(:requires (load-plugin "test-extra"))`, `["test-base"]`},
+		{"(count (plugins))", "2"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// Plugins are loaded per environment
+	if _, err := NewCoreEnvironment().Get(Intern("base-value")); err == nil {
+		t.Error("Expected a fresh environment not to see plugin builtins")
+	}
+}
+
+func TestLoadPluginErrors(t *testing.T) {
+	RegisterPlugin("test-cycle-a", constantPlugin("test-cycle-a", "cycle-a", Nil{}, "test-cycle-b"))
+	RegisterPlugin("test-cycle-b", constantPlugin("test-cycle-b", "cycle-b", Nil{}, "test-cycle-a"))
+	RegisterPlugin("test-missing-dep", constantPlugin("test-missing-dep", "missing-dep", Nil{}, "test-nowhere"))
+
+	env := NewCoreEnvironment()
+	for input, errorMatch := range map[string]string{
+		`(load-plugin "test-unknown")`:          "unknown plugin: test-unknown",
+		`(load-plugin "test-cycle-a")`:          "plugin test-cycle-a depends on itself",
+		`(load-plugin "test-missing-dep")`:      "plugin test-missing-dep requires test-nowhere",
+		`(load-plugin "/nonexistent/thing.so")`: "cannot open plugin /nonexistent/thing.so",
+		`(load-plugin :name)`:                   "load-plugin expects a plugin name or path",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+
+	old := &Plugin{Name: "test-old", APIVersion: PluginAPIVersion + 1}
+	if err := LoadPlugin(old, env); err == nil || !strings.Contains(err.Error(), "uses API version") {
+		t.Errorf("Expected an API version error, got: %v", err)
+	}
+	if count := evalAll(t, env, "(count (plugins))"); count.String() != "0" {
+		t.Errorf("Expected failed plugins not to be recorded, got %s loaded", count)
+	}
+}
+
+func TestPluginCapabilities(t *testing.T) {
+	netPlugin := func() *Plugin {
+		p := constantPlugin("test-net", "fetch", String("page"))()
+		p.Capabilities = []Capability{CapabilityNet}
+		return p
+	}
+	RegisterPlugin("test-net", netPlugin)
+
+	// Denied before loading: the plugin is refused
+	env := NewCoreEnvironment()
+	env.DenyCapabilities(CapabilityNet)
+	expr, _ := ReadString(`(load-plugin "test-net")`)
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "plugin test-net needs the net capability, which is denied") {
+		t.Errorf("Expected the plugin to be refused, got: %v", err)
+	}
+
+	// Denied after loading: its builtins refuse to run, even through saved references
+	env = NewCoreEnvironment()
+	evalAll(t, env, `(load-plugin "test-net") (def saved fetch)`)
+	if result := evalAll(t, env, "(fetch)"); result.String() != `"page"` {
+		t.Errorf("Expected the plugin builtin to run before denial, got %s", result)
+	}
+	if result := evalAll(t, env, `(:capabilities (nth (plugins) 0))`); result.String() != "[:net]" {
+		t.Errorf("Expected plugin info to list its capabilities, got %s", result)
+	}
+	env.DenyCapabilities(CapabilityNet)
+	for _, input := range []string{"(fetch)", "(saved)"} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "fetch needs the net capability, which is denied") {
+			t.Errorf("Expected '%s' to be denied, got: %v", input, err)
+		}
+	}
+
+	// Core file builtins are in the fs class
+	env.DenyCapabilities(CapabilityFS)
+	for input, errorMatch := range map[string]string{
+		`(slurp "go.mod")`:                               "slurp needs the fs capability",
+		`(spit "out.txt" "x")`:                           "spit needs the fs capability",
+		`(require 'some.ns)`:                             "require needs the fs capability",
+		`(log/set-sinks! [{:type :file :path "x.log"}])`: "file log sink needs the fs capability",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+	if result := evalAll(t, env, "(+ 1 2)"); result.String() != "3" {
+		t.Errorf("Expected pure code to keep working, got %s", result)
+	}
+}
+
+func TestPluginsUnderDenial(t *testing.T) {
+	RegisterPlugin("test-undeclared", constantPlugin("test-undeclared", "undeclared", Nil{}))
+	env := NewCoreEnvironment()
+	env.DenyCapabilities(CapabilityExec)
+
+	for input, errorMatch := range map[string]string{
+		// Plugins declaring no capabilities may do anything
+		`(load-plugin "test-undeclared")`: "plugin test-undeclared needs the exec capability",
+		// Plugin files are refused before their code runs
+		`(load-plugin "./missing.so")`: "plugin file ./missing.so needs the exec capability",
+		`(load-plugin "plugins/geo")`:  "plugin file plugins/geo needs the exec capability",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+
+	// Pure plugins still load
+	if result := evalAll(t, env, `(load-plugin "go.strings") (:capabilities (nth (plugins) 0))`); result.String() != "[]" {
+		t.Errorf("Expected a pure plugin to load needing nothing, got %s", result)
+	}
+}
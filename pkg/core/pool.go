@@ -0,0 +1,148 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPoolTimeout is how long a pooled script may run, unless changed
+// with SetTimeout
+const DefaultPoolTimeout = 30 * time.Second
+
+// Pool evaluates scripts on a fixed number of interpreters bootstrapped up
+// front, for servers that run many small scripts and can't pay for loading
+// the standard library each time. Each script runs in a fresh scope under an
+// idle interpreter's globals, so its top-level definitions are gone when it
+// finishes; changes to shared state, such as atoms defined by setup, vars
+// changed with alter-var-root and namespaces loaded with require, stay with
+// that interpreter. Scripts run under a timeout and can't call exit or
+// on-exit.
+type Pool struct {
+	idle    chan *Environment
+	mu      sync.RWMutex // Guards closed and timeout against Submit
+	closed  bool
+	timeout time.Duration
+	active  sync.WaitGroup
+}
+
+// PoolResult is the outcome of a script run by a Pool: the value of its last
+// form, or the error that stopped it, and what it printed
+type PoolResult struct {
+	Value  Value
+	Output string
+	Err    error
+}
+
+// NewPool bootstraps size interpreters and runs setup, if not nil, on each,
+// to deny capabilities or load code every script shares
+func NewPool(size int, setup func(env *Environment) error) (*Pool, error) {
+	if size < 1 {
+		return nil, NewRuntimeError("pool size must be at least 1, got %d", size)
+	}
+	envs := make([]*Environment, size)
+	errs := make([]error, size)
+	var wg sync.WaitGroup
+	for i := range envs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if envs[i], errs[i] = CreateBootstrappedEnvironment(); errs[i] != nil {
+				return
+			}
+			state := envs[i].interpreterState()
+			state.mu.Lock()
+			state.pooled = true
+			state.mu.Unlock()
+			if setup != nil {
+				errs[i] = setup(envs[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	pool := &Pool{idle: make(chan *Environment, size), timeout: DefaultPoolTimeout}
+	for i, env := range envs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		pool.idle <- env
+	}
+	return pool, nil
+}
+
+// SetTimeout changes how long each script submitted from now on may run
+func (p *Pool) SetTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timeout = timeout
+}
+
+// Submit queues source to run on the next idle interpreter and returns a
+// channel that receives its result
+func (p *Pool) Submit(source string) <-chan PoolResult {
+	return p.SubmitContext(context.Background(), source)
+}
+
+// SubmitContext is Submit for a script that must give up if ctx is done
+// while it waits for an interpreter, and must finish by ctx's deadline if
+// that is sooner than the pool's timeout
+func (p *Pool) SubmitContext(ctx context.Context, source string) <-chan PoolResult {
+	results := make(chan PoolResult, 1)
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		results <- PoolResult{Err: NewRuntimeError("pool is closed")}
+		return results
+	}
+	timeout := p.timeout
+	p.active.Add(1)
+	go func() {
+		defer p.active.Done()
+		var env *Environment
+		select {
+		case env = <-p.idle:
+		case <-ctx.Done():
+			results <- PoolResult{Err: NewRuntimeError("script not started: %v", ctx.Err())}
+			return
+		}
+		defer func() { p.idle <- env }()
+		if at, ok := ctx.Deadline(); ok && time.Until(at) < timeout {
+			timeout = time.Until(at)
+		}
+		results <- runPooled(source, env, timeout)
+	}()
+	return results
+}
+
+// Close waits for the submitted scripts to finish. Scripts submitted after
+// Close fail.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+	p.active.Wait()
+}
+
+// runPooled evaluates the forms of source in a child of env, within timeout,
+// capturing what they print
+func runPooled(source string, env *Environment, timeout time.Duration) PoolResult {
+	var output bytes.Buffer
+	env.SetOutput(&output)
+	defer env.SetOutput(nil)
+
+	forms, err := env.NewReader(strings.NewReader(source)).ReadAll()
+	if err != nil {
+		return PoolResult{Err: err}
+	}
+	scope := withDeadline(env, timeout)
+	var result Value = Nil{}
+	for _, form := range forms {
+		if result, err = Eval(form, scope); err != nil {
+			return PoolResult{Output: output.String(), Err: err}
+		}
+	}
+	return PoolResult{Value: result, Output: output.String()}
+}
@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPool(t *testing.T) {
+	pool, err := NewPool(4, func(env *Environment) error {
+		return evalSource(t, env, `(defn transform [event] (assoc event :seen true))`)
+	})
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+
+	// Many scripts share the interpreters, each seeing the setup code
+	// but not what the others defined
+	results := make([]<-chan PoolResult, 50)
+	for i := range results {
+		results[i] = pool.Submit(fmt.Sprintf(`
+			(def n %d)
+			(println "event" n)
+			(transform (hash-map :id n))`, i))
+	}
+	for i, ch := range results {
+		result := <-ch
+		if result.Err != nil {
+			t.Fatalf("Script %d failed: %v", i, result.Err)
+		}
+		if expected := fmt.Sprintf("{:id %d :seen true}", i); result.Value.String() != expected {
+			t.Errorf("Script %d: expected %s, got %s", i, expected, result.Value)
+		}
+		if expected := fmt.Sprintf("event %d\n", i); result.Output != expected {
+			t.Errorf("Script %d: expected output %q, got %q", i, expected, result.Output)
+		}
+	}
+
+	if result := <-pool.Submit("n"); result.Err == nil || !strings.Contains(result.Err.Error(), "undefined") {
+		t.Errorf("Expected definitions not to outlive their script, got %v (%v)", result.Value, result.Err)
+	}
+	if result := <-pool.Submit("(println 1) (+ 1"); result.Err == nil {
+		t.Error("Expected a parse error")
+	}
+	if result := <-pool.Submit(`(println "before") (throw "boom")`); result.Err == nil || result.Output != "before\n" {
+		t.Errorf("Expected the error and the output before it, got %q (%v)", result.Output, result.Err)
+	}
+
+	pool.Close()
+	if result := <-pool.Submit("1"); result.Err == nil || !strings.Contains(result.Err.Error(), "pool is closed") {
+		t.Errorf("Expected a closed pool error, got %v", result.Err)
+	}
+
+	if _, err := NewPool(0, nil); err == nil {
+		t.Error("Expected an error for an empty pool")
+	}
+}
+
+func TestPoolLimits(t *testing.T) {
+	pool, err := NewPool(1, nil)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer pool.Close()
+	pool.SetTimeout(100 * time.Millisecond)
+
+	for source, message := range map[string]string{
+		`(exit 0)`:              "exit is not available to pooled scripts",
+		`(on-exit (fn [] nil))`: "on-exit is not available to pooled scripts",
+		`(loop [] (recur))`:     "timed out after 100ms",
+	} {
+		if result := <-pool.Submit(source); result.Err == nil || !strings.Contains(result.Err.Error(), message) {
+			t.Errorf("%s: expected an error containing %q, got %v", source, message, result.Err)
+		}
+	}
+	// The interpreter a runaway script used is back in the pool
+	if result := <-pool.Submit("(+ 1 2)"); result.Err != nil || result.Value.String() != "3" {
+		t.Errorf("Expected the pool to keep working, got %v (%v)", result.Value, result.Err)
+	}
+
+	// A context's deadline shortens the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if result := <-pool.SubmitContext(ctx, "(loop [] (recur))"); result.Err == nil || !strings.Contains(result.Err.Error(), "timed out") {
+		t.Errorf("Expected the context deadline to stop the script, got %v", result.Err)
+	}
+	if elapsed := time.Since(start); elapsed > 90*time.Millisecond {
+		t.Errorf("Expected the script to stop at the context deadline, took %s", elapsed)
+	}
+
+	// A script still waiting for an interpreter gives up when its context is done
+	running := make(chan bool, 1)
+	pool, err = NewPool(1, func(env *Environment) error {
+		env.Set(Intern("running"), &BuiltinFunction{
+			Name: "running",
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				running <- true
+				return Nil{}, nil
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer pool.Close()
+	pool.SetTimeout(100 * time.Millisecond)
+	busy := pool.Submit("(running) (loop [] (recur))")
+	<-running
+	waiting, stop := context.WithCancel(context.Background())
+	queued := pool.SubmitContext(waiting, "1")
+	stop()
+	if result := <-queued; result.Err == nil || !strings.Contains(result.Err.Error(), "script not started") {
+		t.Errorf("Expected the queued script to give up, got %v (%v)", result.Value, result.Err)
+	}
+	<-busy
+}
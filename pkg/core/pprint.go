@@ -0,0 +1,70 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PrettyString renders value like String, but collections that don't fit in
+// width columns are broken across lines, one element (or map entry) per line
+func PrettyString(value Value, width int) string {
+	var out strings.Builder
+	prettyPrint(&out, value, 0, width)
+	return out.String()
+}
+
+func prettyPrint(out *strings.Builder, value Value, indent, width int) {
+	flat := value.String()
+	if indent+len(flat) <= width {
+		out.WriteString(flat)
+		return
+	}
+
+	switch v := value.(type) {
+	case *List:
+		prettyElements(out, "(", ")", listToSlice(v), indent, width)
+	case *Vector:
+		items, _ := collectionToSlice(v)
+		prettyElements(out, "[", "]", items, indent, width)
+	case *Set:
+		prettyElements(out, "#{", "}", v.order, indent, width)
+	case *HashMap:
+		out.WriteString("{")
+		for i, key := range v.keys {
+			if i > 0 {
+				out.WriteString("\n" + strings.Repeat(" ", indent+1))
+			}
+			keyText := key.String()
+			out.WriteString(keyText + " ")
+			prettyPrint(out, v.Get(key), indent+1+len(keyText)+1, width)
+		}
+		out.WriteString("}")
+	default:
+		out.WriteString(flat)
+	}
+}
+
+func prettyElements(out *strings.Builder, open, close string, items []Value, indent, width int) {
+	out.WriteString(open)
+	for i, item := range items {
+		if i > 0 {
+			out.WriteString("\n" + strings.Repeat(" ", indent+len(open)))
+		}
+		prettyPrint(out, item, indent+len(open), width)
+	}
+	out.WriteString(close)
+}
+
+// setupPrettyPrinter adds pprint
+func setupPrettyPrinter(env *Environment) {
+	env.Set(Intern("pprint"), &BuiltinFunction{
+		Name: "pprint",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("pprint expects 1 argument, got %d", len(args))
+			}
+			fmt.Fprintln(env.output(), PrettyString(args[0], 80))
+			return Nil{}, nil
+		},
+	})
+}
@@ -0,0 +1,295 @@
+package core
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// formatFloat prints f so that it always reads back as a float: 4.0 rather
+// than 4, with an exponent only for very large or small magnitudes
+func formatFloat(f float64) string {
+	return formatFloatDigits(f, -1)
+}
+
+// formatFloatDigits prints f like formatFloat, but with precision digits
+// after the decimal point unless precision is negative
+func formatFloatDigits(f float64, precision int) string {
+	switch {
+	case math.IsNaN(f):
+		return "##NaN"
+	case math.IsInf(f, 1):
+		return "##Inf"
+	case math.IsInf(f, -1):
+		return "##-Inf"
+	}
+
+	var text string
+	if abs := math.Abs(f); precision < 0 && abs != 0 && (abs < 1e-4 || abs >= 1e21) {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	} else if precision < 0 {
+		text = strconv.FormatFloat(f, 'f', -1, 64)
+	} else {
+		text = strconv.FormatFloat(f, 'f', precision, 64)
+	}
+	if !strings.ContainsRune(text, '.') {
+		text += ".0"
+	}
+	return text
+}
+
+// printSettings are the *print-precision*, *print-length* and *print-level*
+// in effect; -1 means the default shortest float form, or unlimited
+type printSettings struct {
+	precision int // Digits after the decimal point of floats
+	length    int // Elements printed per collection
+	level     int // Collections printed inside one another
+}
+
+// noPrintSettings prints values the way String does
+var noPrintSettings = printSettings{precision: -1, length: -1, level: -1}
+
+// currentPrintSettings reads the printer variables from env, including any
+// binding of them; any value but a non-negative integer leaves that setting
+// at its default
+func currentPrintSettings(env *Environment) printSettings {
+	setting := func(name string) int {
+		if env == nil {
+			return -1
+		}
+		if value, err := env.Get(Intern(name)); err == nil {
+			if n, ok := value.(Number); ok && n.IsInteger() && n.ToInt() >= 0 {
+				return int(n.ToInt())
+			}
+		}
+		return -1
+	}
+	return printSettings{
+		precision: setting("*print-precision*"),
+		length:    setting("*print-length*"),
+		level:     setting("*print-level*"),
+	}
+}
+
+// PrintString renders value like String, but with floats printed to
+// *print-precision* digits, collections longer than *print-length* ending in
+// "..." and those nested deeper than *print-level* printed as "...", so huge
+// or infinite values can't flood the output. Realizing a lazy sequence in
+// value can fail, which is returned as the error.
+func PrintString(value Value, env *Environment) (string, error) {
+	return newPrintWalk(currentPrintSettings(env)).print(value)
+}
+
+// precisePrintString renders value like String but with floats printed to
+// *print-precision* digits, for str, which ignores the print limits
+func precisePrintString(value Value, env *Environment) (string, error) {
+	settings := noPrintSettings
+	settings.precision = currentPrintSettings(env).precision
+	return newPrintWalk(settings).print(value)
+}
+
+// printString renders value without any of the printer settings;
+// collections and references use it for String, which has no way to fail,
+// so a lazy sequence that can't be realized prints as #<lazy-seq:failed>
+func printString(value Value) string {
+	text, _ := newPrintWalk(noPrintSettings).print(value)
+	return text
+}
+
+// printWalk prints one value, tracking the references whose contents are
+// being printed so that a structure containing itself prints #cycle there
+// instead of recursing forever
+type printWalk struct {
+	printSettings
+	visiting map[Value]bool
+	err      error // The first lazy sequence that failed to realize
+}
+
+func newPrintWalk(settings printSettings) *printWalk {
+	return &printWalk{printSettings: settings, visiting: make(map[Value]bool)}
+}
+
+func (p *printWalk) print(value Value) (string, error) {
+	var out strings.Builder
+	p.write(&out, value, 0)
+	return out.String(), p.err
+}
+
+// write prints value at nesting depth into out
+func (p *printWalk) write(out *strings.Builder, value Value, depth int) {
+	var open, close string
+	var items []Value
+	switch v := value.(type) {
+	case Number:
+		if f, ok := v.Value.(float64); ok {
+			out.WriteString(formatFloatDigits(f, p.precision))
+		} else {
+			out.WriteString(v.String())
+		}
+		return
+	case *List:
+		if v == nil {
+			out.WriteString("()")
+			return
+		}
+		open, close = "(", ")"
+		for current := v; current != nil; current = current.tail {
+			items = append(items, current.head)
+		}
+	case *Vector:
+		open, close, items = "[", "]", v.elements
+	case *Set:
+		open, close, items = "#{", "}", v.order
+	case *HashMap:
+		open, close = "{", "}"
+		for _, key := range v.keys {
+			items = append(items, mapEntry{key, v.Get(key)})
+		}
+	case *LazySeq:
+		var err error
+		if items, err = p.realizePrinted(v); err != nil {
+			if p.err == nil {
+				p.err = err
+			}
+			out.WriteString("#<lazy-seq:failed>")
+			return
+		}
+		open, close = "(", ")"
+	case *Atom:
+		p.writeReference(out, v, "#<atom ", v.Deref(), ">", depth)
+		return
+	case *Ref:
+		committed, _ := v.committed()
+		p.writeReference(out, v, "#<ref ", committed, ">", depth)
+		return
+	case *Delay:
+		v.mu.Lock()
+		realized, failed, result := v.realized, v.err != nil, v.value
+		v.mu.Unlock()
+		switch {
+		case !realized:
+			out.WriteString("#<delay:pending>")
+		case failed:
+			out.WriteString("#<delay:failed>")
+		default:
+			p.writeReference(out, v, "#<delay:", result, ">", depth)
+		}
+		return
+	default:
+		out.WriteString(value.String())
+		return
+	}
+	if p.level >= 0 && depth >= p.level {
+		out.WriteString("...")
+		return
+	}
+
+	out.WriteString(open)
+	for i, item := range items {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		if p.length >= 0 && i >= p.length {
+			out.WriteString("...")
+			break
+		}
+		if entry, ok := item.(mapEntry); ok {
+			p.write(out, entry.key, depth+1)
+			out.WriteString(" ")
+			p.write(out, entry.value, depth+1)
+		} else if item == nil {
+			out.WriteString("nil")
+		} else {
+			p.write(out, item, depth+1)
+		}
+	}
+	out.WriteString(close)
+}
+
+// writeReference prints the contents of an atom, ref or delay between
+// prefix and suffix, or #cycle if they are already being printed
+func (p *printWalk) writeReference(out *strings.Builder, ref Value, prefix string, contents Value, suffix string, depth int) {
+	if p.visiting[ref] {
+		out.WriteString("#cycle")
+		return
+	}
+	p.visiting[ref] = true
+	defer delete(p.visiting, ref)
+	out.WriteString(prefix)
+	p.write(out, contents, depth)
+	out.WriteString(suffix)
+}
+
+// realizePrinted realizes at most one element more than *print-length*
+// allows, so printing an infinite sequence terminates
+func (p *printWalk) realizePrinted(seq *LazySeq) ([]Value, error) {
+	var items []Value
+	var coll Value = seq
+	for p.length < 0 || len(items) <= p.length {
+		first, ok, err := seqFirst(coll)
+		if err != nil || !ok {
+			return items, err
+		}
+		items = append(items, first)
+		if coll, err = seqRest(coll); err != nil {
+			return items, err
+		}
+	}
+	return items, nil
+}
+
+// mapEntry is a key and value that write prints without brackets
+type mapEntry struct {
+	key, value Value
+}
+
+func (e mapEntry) String() string {
+	return e.key.String() + " " + e.value.String()
+}
+
+// radixPrefixes are the literal prefixes format-radix writes, which the
+// reader accepts besides the general 16rFF form
+var radixPrefixes = map[int]string{2: "0b", 8: "0o", 16: "0x"}
+
+// formatRadix prints n as an integer literal in radix that reads back as n
+func formatRadix(n int64, radix int) string {
+	sign, digits := "", strconv.FormatInt(n, radix)
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if radix == 10 {
+		return sign + digits
+	}
+	if prefix, ok := radixPrefixes[radix]; ok {
+		return sign + prefix + digits
+	}
+	return sign + strconv.Itoa(radix) + "r" + digits
+}
+
+// setupPrinterOperations adds *print-precision*, *print-length*,
+// *print-level* and format-radix. The printer variables are read, like any
+// other, each time print, println, prn, str or the REPL prints, so def and
+// binding both change them.
+func setupPrinterOperations(env *Environment) {
+	env.Set(Intern("*print-precision*"), Nil{})
+	env.Set(Intern("*print-length*"), Nil{})
+	env.Set(Intern("*print-level*"), Nil{})
+
+	env.Set(Intern("format-radix"), &BuiltinFunction{
+		Name: "format-radix",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("format-radix expects 2 arguments, got %d", len(args))
+			}
+			n, ok := args[0].(Number)
+			if !ok || !n.IsInteger() {
+				return nil, NewTypeError("format-radix expects an integer, got %s", args[0])
+			}
+			radix, ok := args[1].(Number)
+			if !ok || !radix.IsInteger() || radix.ToInt() < 2 || radix.ToInt() > 36 {
+				return nil, NewTypeError("format-radix expects a radix from 2 to 36, got %s", args[1])
+			}
+			return String(formatRadix(n.ToInt(), int(radix.ToInt()))), nil
+		},
+	})
+}
@@ -0,0 +1,194 @@
+package core
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestNumberPrinting(t *testing.T) {
+	tests := []struct {
+		value    Value
+		expected string
+	}{
+		{NewNumber(int64(4)), "4"},
+		{NewNumber(int64(-12)), "-12"},
+		{NewNumber(4.0), "4.0"},
+		{NewNumber(-0.5), "-0.5"},
+		{NewNumber(0.0), "0.0"},
+		{NewNumber(1.0 / 3), "0.3333333333333333"},
+		{NewNumber(1e8), "100000000.0"},
+		{NewNumber(1e21), "1e+21"},
+		{NewNumber(0.00001), "1e-05"},
+		{NewNumber(math.NaN()), "##NaN"},
+		{NewNumber(math.Inf(-1)), "##-Inf"},
+	}
+	for _, test := range tests {
+		if got := test.value.String(); got != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, got)
+		}
+		// Everything printed reads back as the same number
+		if read, err := ReadString(test.expected); err != nil || read.String() != test.expected {
+			t.Errorf("Expected %s to read back, got %v (%v)", test.expected, read, err)
+		}
+	}
+}
+
+func TestFormatRadix(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(format-radix 255 16)", `"0xff"`},
+		{"(format-radix -10 2)", `"-0b1010"`},
+		{"(format-radix 15 8)", `"0o17"`},
+		{"(format-radix 1295 36)", `"36rzz"`},
+		{"(format-radix 42 10)", `"42"`},
+		{"(read-string (format-radix -1295 36))", "-1295"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		"(format-radix 1.5 16)": "expects an integer",
+		"(format-radix 10 37)":  "radix from 2 to 36",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
+
+// printed evaluates input in env and prints the result as the REPL would
+func printed(t *testing.T, env *Environment, input string) string {
+	t.Helper()
+	text, err := PrintString(evalAll(t, env, input), env)
+	if err != nil {
+		t.Fatalf("Failed to print the result of '%s': %v", input, err)
+	}
+	return text
+}
+
+func TestPrintPrecision(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(/ 1 3)", "0.3333333333333333"},
+		{"(def *print-precision* 2) (/ 1 3)", "0.33"},
+		{"(str (/ 2 3))", `"0.67"`},
+		{"(str [1.234 {:a 5.678}])", `"[1.23 {:a 5.68}]"`},
+		{"(* 2.0 2)", "4.00"},
+		{"(+ 1 2)", "3"},
+		{"(call-with-bindings (list (var *print-precision*) 4) (fn [] (str 1.23456)))", `"1.2346"`},
+		{"(call-with-bindings (list (var *print-precision*) nil) (fn [] (str 1.5 \" \" (/ 1 3))))", `"1.5 0.3333333333333333"`},
+		{"(def *print-precision* 0) 2.5", "2.0"},
+		{"(def *print-precision* :two) 0.25", "0.25"},
+		{"(def *print-precision* nil) (/ 1 4)", "0.25"},
+	}
+	for _, test := range tests {
+		if got := printed(t, env, test.input); got != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	// The setting belongs to the interpreter, and never changes String
+	evalAll(t, env, "(def *print-precision* 1)")
+	other := NewCoreEnvironment()
+	if got := printed(t, other, "(/ 1 4)"); got != "0.25" {
+		t.Errorf("Expected another interpreter to keep the default precision, got %s", got)
+	}
+	if got := evalAll(t, env, "1.25").String(); got != "1.25" {
+		t.Errorf("Expected String to ignore *print-precision*, got %s", got)
+	}
+}
+
+func TestPrintFailingLazySeq(t *testing.T) {
+	env := NewCoreEnvironment()
+	failing := evalAll(t, env, "(def failing (for [x [1 2]] (nope x))) failing")
+	if _, err := PrintString(failing, env); err == nil || !strings.Contains(err.Error(), "undefined symbol: nope") {
+		t.Errorf("Expected printing to report the NameError, got %v", err)
+	}
+	for _, input := range []string{"(str failing)", "(str (vector failing))", "(prn failing)", "(println :x failing)", "(string-builder failing)"} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "undefined symbol: nope") {
+			t.Errorf("Expected %s to report the NameError, got %v", input, err)
+		}
+	}
+	if got := failing.String(); got != "#<lazy-seq:failed>" {
+		t.Errorf("Expected String to mark the failed sequence, got %s", got)
+	}
+}
+
+func TestPrintLimits(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn naturals [n] (generator (loop [i n] (yield i) (recur (+ i 1)))))
+		(def nested [1 [2 [3 [4]]]])
+		(def long (list 1 2 3 4 5 6))`)
+
+	print := func(input string) string {
+		return printed(t, env, input)
+	}
+	if got := print("long"); got != "(1 2 3 4 5 6)" {
+		t.Errorf("Expected no limits by default, got %s", got)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(def *print-length* 3) long", "(1 2 3 ...)"},
+		{"[1 2 3]", "[1 2 3]"},
+		{"(hash-map :a 1 :b 2 :c 3 :d 4)", "{:a 1 :b 2 :c 3 ...}"},
+		{"(naturals 0)", "(0 1 2 ...)"},
+		{"(def *print-length* nil) (def *print-level* 2) nested", "[1 [2 ...]]"},
+		{"{:a {:b {:c 1}}}", "{:a {:b ...}}"},
+		{"(def *print-level* 0) nested", "..."},
+		{"42", "42"},
+	}
+	for _, test := range tests {
+		if got := print(test.input); got != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	// Limits only affect printing, not str or map keys
+	if result := evalAll(t, env, "(str nested)"); result.String() != `"[1 [2 [3 [4]]]]"` {
+		t.Errorf("Expected str to ignore print limits, got %s", result)
+	}
+}
+
+func TestCyclicValues(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def a (atom nil))
+		(reset! a (vector 1 a))
+		(def r (ref nil))
+		(dosync (ref-set r (hash-map :self r :a a)))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a", "#<atom [1 #cycle]>"},
+		{"(vector a a)", "[#<atom [1 #cycle]> #<atom [1 #cycle]>]"},
+		{"r", "#<ref {:self #cycle :a #<atom [1 #cycle]>}>"},
+		{"(str (list a))", `"(#<atom [1 #cycle]>)"`},
+		{"(= a a)", "true"},
+		{"(= (vector a) (list a))", "true"},
+		{"(= a (atom (vector 1 a)))", "nil"},
+		{"(= @a (list 1 a))", "true"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExitRunsHooks(t *testing.T) {
+	exitCode := -1
+	osExit = func(code int) { exitCode = code }
+	defer func() { osExit = nil }()
+
+	env := NewCoreEnvironment()
+	var calls []string
+	env.Set(Intern("record"), &BuiltinFunction{
+		Name: "record",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			calls = append(calls, args[0].String())
+			return Nil{}, nil
+		},
+	})
+
+	inputs := []string{
+		`(on-exit (fn [] (record :first)))`,
+		`(on-exit (fn [] (record :second)))`,
+		`(exit 3)`,
+	}
+	for _, input := range inputs {
+		expr, err := ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		if _, err := Eval(expr, env); err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+	}
+
+	if exitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", exitCode)
+	}
+
+	// Hooks run most recent first
+	if strings.Join(calls, " ") != ":second :first" {
+		t.Errorf("Expected hooks to run in reverse order, got %v", calls)
+	}
+
+	// Hooks only run once
+	env.RunExitHooks()
+	if len(calls) != 2 {
+		t.Errorf("Expected hooks to run once, got %v", calls)
+	}
+}
+
+func TestExitArguments(t *testing.T) {
+	env := NewCoreEnvironment()
+
+	for _, input := range []string{`(exit "x")`, `(exit 1 2)`, `(on-exit 42)`} {
+		expr, err := ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		if _, err := Eval(expr, env); err == nil {
+			t.Errorf("Expected error for '%s'", input)
+		}
+	}
+}
+
+func TestRuntimeStats(t *testing.T) {
+	env := NewCoreEnvironment()
+
+	before := evalAll(t, env, `(runtime-stats)`).(*HashMap)
+	evalAll(t, env, `(gc!)`)
+	after := evalAll(t, env, `(runtime-stats)`).(*HashMap)
+
+	for _, key := range []string{"heap-alloc", "num-gc", "goroutines"} {
+		if n, ok := after.Get(InternKeyword(key)).(Number); !ok || n.ToInt() < 1 {
+			t.Errorf("Expected a positive :%s, got %v", key, after.Get(InternKeyword(key)))
+		}
+	}
+	if after.Get(InternKeyword("num-gc")).(Number).ToInt() <= before.Get(InternKeyword("num-gc")).(Number).ToInt() {
+		t.Errorf("Expected gc! to run a collection, got %v then %v", before, after)
+	}
+	if _, ok := after.Get(InternKeyword("uptime")).(Duration); !ok {
+		t.Errorf("Expected :uptime to be a duration, got %v", after.Get(InternKeyword("uptime")))
+	}
+
+	if err := evalSource(t, env, `(gc! 1)`); err == nil {
+		t.Error("Expected an arity error for (gc! 1)")
+	}
+}
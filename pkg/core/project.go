@@ -0,0 +1,335 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ProjectFile is the name of the project manifest
+const ProjectFile = "golisp.edn"
+
+// dependencyNamePattern is what dependency names may look like: dotted
+// namespace segments, which become directories, so none can be ".." or
+// contain a path separator
+var dependencyNamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_-]*(\.[A-Za-z0-9_][A-Za-z0-9_-]*)*$`)
+
+// Dependency is a library listed under :deps in golisp.edn
+type Dependency struct {
+	Name  Symbol
+	Git   string   // repository cloned into the cache
+	Tag   string   // optional tag or branch for git dependencies
+	Sha   string   // optional commit, pinned in preference to Tag
+	URL   string   // single source file downloaded into the cache
+	Paths []string // source paths inside the dependency, relative to its root
+}
+
+// Project is a parsed golisp.edn manifest:
+//
+//	{:paths ["src"]
+//	 :deps {my.lib {:git "https://example.com/my-lib.git" :tag "v1.0"}
+//	        util.str {:url "https://example.com/util/str.lisp"}}}
+type Project struct {
+	Root  string
+	Paths []string
+	Deps  []Dependency
+}
+
+// LoadProject reads the golisp.edn manifest in dir
+func LoadProject(dir string) (*Project, error) {
+	path := filepath.Join(dir, ProjectFile)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewIOError("failed to read %s: %v", path, err)
+	}
+	project, err := ParseProject(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	project.Root = dir
+	return project, nil
+}
+
+// FindProject looks for golisp.edn in dir and its parents, returning nil if
+// there is none
+func FindProject(dir string) (*Project, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ProjectFile)); err == nil {
+			return LoadProject(dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// ParseProject parses manifest source
+func ParseProject(source string) (*Project, error) {
+	value, err := ReadString(source)
+	if err != nil {
+		return nil, err
+	}
+	manifest, ok := value.(*HashMap)
+	if !ok {
+		return nil, NewTypeError("project manifest must be a hash-map, got %s", TypeName(value))
+	}
+
+	project := &Project{Paths: []string{"src"}}
+	if manifest.ContainsKey(InternKeyword("paths")) {
+		project.Paths, err = manifestStrings("paths", manifest.Get(InternKeyword("paths")))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deps := manifest.Get(InternKeyword("deps"))
+	if _, isNil := deps.(Nil); isNil {
+		return project, nil
+	}
+	depMap, ok := deps.(*HashMap)
+	if !ok {
+		return nil, NewTypeError(":deps must be a hash-map, got %s", TypeName(deps))
+	}
+
+	for _, key := range depMap.keys {
+		dep, err := parseDependency(key, depMap.Get(key))
+		if err != nil {
+			return nil, err
+		}
+		project.Deps = append(project.Deps, dep)
+	}
+	return project, nil
+}
+
+// parseDependency reads one name -> coordinate entry of :deps
+func parseDependency(key, value Value) (Dependency, error) {
+	name, err := namespaceSymbol("deps", key)
+	if err != nil {
+		return Dependency{}, err
+	}
+	coord, ok := value.(*HashMap)
+	if !ok {
+		return Dependency{}, NewTypeError("dependency %s must be a hash-map, got %s", name, TypeName(value))
+	}
+
+	dep := Dependency{Name: name}
+	dep.Git = manifestString(coord, "git")
+	dep.URL = manifestString(coord, "url")
+	dep.Tag = manifestString(coord, "tag")
+	dep.Sha = manifestString(coord, "sha")
+
+	if (dep.Git == "") == (dep.URL == "") {
+		return Dependency{}, NewRuntimeError("dependency %s needs exactly one of :git or :url", name)
+	}
+	if err := dep.validate(); err != nil {
+		return Dependency{}, err
+	}
+
+	if coord.ContainsKey(InternKeyword("paths")) {
+		dep.Paths, err = manifestStrings("paths", coord.Get(InternKeyword("paths")))
+		if err != nil {
+			return Dependency{}, err
+		}
+	}
+	return dep, nil
+}
+
+// validate rejects names, repositories and refs that would escape the
+// dependency and library directories, and ones git would take for options
+func (dep Dependency) validate() error {
+	if !dependencyNamePattern.MatchString(string(dep.Name)) {
+		return NewRuntimeError("invalid dependency name %q: use letters, digits, _ and - in dot-separated parts", string(dep.Name))
+	}
+	for _, field := range [][2]string{{"git", dep.Git}, {"tag", dep.Tag}, {"sha", dep.Sha}} {
+		if strings.HasPrefix(field[1], "-") {
+			return NewRuntimeError("dependency %s: :%s must not start with -, got %q", dep.Name, field[0], field[1])
+		}
+	}
+	if dep.Git != "" {
+		if _, err := libraryPath(dep.Git, dep.ref()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ref is the git revision a dependency is pinned to, if any
+func (dep Dependency) ref() string {
+	if dep.Sha != "" {
+		return dep.Sha
+	}
+	return dep.Tag
+}
+
+func manifestString(m *HashMap, key string) string {
+	if str, ok := m.Get(InternKeyword(key)).(String); ok {
+		return string(str)
+	}
+	return ""
+}
+
+func manifestStrings(key string, value Value) ([]string, error) {
+	items, err := collectionToSlice(value)
+	if err != nil {
+		return nil, NewTypeError(":%s must be a vector of strings, got %s", key, TypeName(value))
+	}
+	var result []string
+	for _, item := range items {
+		str, ok := item.(String)
+		if !ok {
+			return nil, NewTypeError(":%s must be a vector of strings, got %s", key, item)
+		}
+		result = append(result, string(str))
+	}
+	return result, nil
+}
+
+// CacheDir is where dependencies are fetched to
+func (p *Project) CacheDir() string {
+	return filepath.Join(p.Root, ".golisp", "deps")
+}
+
+// depDir is the directory of a single dependency: the installed library
+// checkout of the ref it pins if there is one, otherwise the project cache
+func (p *Project) depDir(dep Dependency) string {
+	if dep.Git != "" {
+		if dir, err := libraryPath(dep.Git, dep.ref()); err == nil && dirExists(dir) {
+			return dir
+		}
+	}
+	return filepath.Join(p.CacheDir(), string(dep.Name))
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// FetchDeps downloads every dependency into the cache, reporting progress to out
+func (p *Project) FetchDeps(out io.Writer) error {
+	for _, dep := range p.Deps {
+		dir := p.depDir(dep)
+		var err error
+		if dep.Git != "" {
+			fmt.Fprintf(out, "Fetching %s from %s\n", dep.Name, dep.Git)
+			err = fetchGit(dep, dir)
+		} else {
+			fmt.Fprintf(out, "Fetching %s from %s\n", dep.Name, dep.URL)
+			err = fetchURL(dep, dir)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch %s: %v", dep.Name, err)
+		}
+	}
+	return nil
+}
+
+// fetchGit clones (or updates) a git dependency and checks out its ref
+func fetchGit(dep Dependency, dir string) error {
+	if err := dep.validate(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		if err := runGit("-C", dir, "fetch", "--tags", "origin"); err != nil {
+			return err
+		}
+		if dep.ref() == "" {
+			return runGit("-C", dir, "pull", "--ff-only")
+		}
+	} else {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return err
+		}
+		if err := runGit("clone", "--quiet", "--", dep.Git, dir); err != nil {
+			return err
+		}
+	}
+
+	if ref := dep.ref(); ref != "" {
+		return runGit("-C", dir, "checkout", "--quiet", ref, "--")
+	}
+	return nil
+}
+
+func runGit(args ...string) error {
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// fetchURL downloads a single-file dependency to the path its namespace
+// resolves to, so (require 'util.str) finds util/str.lisp in the cache
+func fetchURL(dep Dependency, dir string) error {
+	resp, err := http.Get(dep.URL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", dep.URL, resp.Status)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(dir, filepath.Join(strings.Split(string(dep.Name), ".")...)+".lisp")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, content, 0644)
+}
+
+// LoadPath returns the directories to search for namespaces: the project's
+// own paths followed by the source paths of each fetched dependency
+func (p *Project) LoadPath() []string {
+	var dirs []string
+	for _, path := range p.Paths {
+		dirs = append(dirs, filepath.Join(p.Root, path))
+	}
+
+	for _, dep := range p.Deps {
+		dir := p.depDir(dep)
+		paths := dep.Paths
+		if paths == nil && dep.Git != "" {
+			// A library's own manifest decides its source paths
+			if lib, err := LoadProject(dir); err == nil {
+				paths = lib.Paths
+			}
+		}
+		if paths == nil {
+			paths = []string{"."}
+		}
+		for _, path := range paths {
+			dirs = append(dirs, filepath.Join(dir, path))
+		}
+	}
+	return dirs
+}
+
+// Apply puts the project's load path in front of *load-path* in env
+func (p *Project) Apply(env *Environment) {
+	var dirs []Value
+	for _, dir := range p.LoadPath() {
+		dirs = append(dirs, String(dir))
+	}
+	if current, err := env.Get(Intern("*load-path*")); err == nil {
+		existing, _ := collectionToSlice(current)
+		dirs = append(dirs, existing...)
+	}
+	env.root().Set(Intern("*load-path*"), NewVector(dirs...))
+}
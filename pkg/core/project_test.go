@@ -0,0 +1,239 @@
+package core_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestParseProject(t *testing.T) {
+	project, err := core.ParseProject(`{:paths ["src" "lib"]
+	  :deps {my.lib {:git "https://example.com/my-lib.git" :tag "v1.0"}
+	         util.str {:url "https://example.com/str.lisp" :paths ["."]}}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(project.Paths) != 2 || project.Paths[1] != "lib" {
+		t.Errorf("Expected paths [src lib], got %v", project.Paths)
+	}
+	if len(project.Deps) != 2 {
+		t.Fatalf("Expected 2 deps, got %d", len(project.Deps))
+	}
+	if dep := project.Deps[0]; dep.Name != "my.lib" || dep.Git == "" || dep.Tag != "v1.0" {
+		t.Errorf("Unexpected git dependency: %+v", dep)
+	}
+	if dep := project.Deps[1]; dep.Name != "util.str" || dep.URL == "" {
+		t.Errorf("Unexpected url dependency: %+v", dep)
+	}
+
+	errorCases := []string{
+		`[1 2]`,
+		`{:deps {a {}}}`,
+		`{:deps {a {:git "x" :url "y"}}}`,
+		`{:paths "src"}`,
+		`{:deps {"../../x" {:url "https://example.com/x.lisp"}}}`,
+		`{:deps {a..b {:url "https://example.com/x.lisp"}}}`,
+		`{:deps {a {:git "--upload-pack=touch /tmp/pwned"}}}`,
+		`{:deps {a {:git "https://example.com/a.git" :tag "-b"}}}`,
+	}
+	for _, input := range errorCases {
+		if _, err := core.ParseProject(input); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
+
+func TestProjectFetchAndRequire(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`(defn shout [s] (str s "!"))`))
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	manifest := `{:paths ["src"] :deps {util.str {:url "` + server.URL + `/str.lisp"}}}`
+	if err := os.WriteFile(filepath.Join(dir, core.ProjectFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := core.FindProject(filepath.Join(dir, "src"))
+	if err != nil || project == nil {
+		t.Fatalf("Expected to find project, got %v, %v", project, err)
+	}
+	if err := project.FetchDeps(io.Discard); err != nil {
+		t.Fatalf("Fetch failed: %v", err)
+	}
+
+	env := core.NewCoreEnvironment()
+	project.Apply(env)
+
+	expr, _ := core.ReadString(`(do (require 'util.str) (shout "hi"))`)
+	result, err := core.Eval(expr, env)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if result.String() != `"hi!"` {
+		t.Errorf("Expected \"hi!\", got %s", result)
+	}
+}
+
+func TestInstallLibrary(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+	t.Setenv("GOLISP_HOME", t.TempDir())
+
+	// A library repository with one namespace and two tagged versions
+	repo := filepath.Join(t.TempDir(), "strlib")
+	if err := os.MkdirAll(filepath.Join(repo, "str"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	git := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, output)
+		}
+	}
+	writeLib := func(version string) {
+		t.Helper()
+		src := `(defn version [] "` + version + `")`
+		if err := os.WriteFile(filepath.Join(repo, "str", "core.lisp"), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	git("init", "--quiet")
+	writeLib("v1")
+	git("add", "-A")
+	git("commit", "--quiet", "-m", "v1")
+	git("tag", "v1")
+	writeLib("v2")
+	git("commit", "--quiet", "-am", "v2")
+
+	dep, err := core.InstallLibrary(repo+"@v1", io.Discard)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if dep.Name != "strlib" || dep.Tag != "v1" || len(dep.Sha) != 40 {
+		t.Errorf("Unexpected dependency: %+v", dep)
+	}
+
+	env := core.NewCoreEnvironment()
+	core.UseLibraries(env)
+	expr, _ := core.ReadString(`(do (require 'str.core) (version))`)
+	result, err := core.Eval(expr, env)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if result.String() != `"v1"` {
+		t.Errorf("Expected pinned version \"v1\", got %s", result)
+	}
+
+	// The pin round-trips through the manifest
+	project := &core.Project{Paths: []string{"src"}}
+	project.AddDependency(dep)
+	parsed, err := core.ParseProject(project.String())
+	if err != nil {
+		t.Fatalf("Failed to parse saved manifest %q: %v", project.String(), err)
+	}
+	if len(parsed.Deps) != 1 || parsed.Deps[0].Sha != dep.Sha || parsed.Deps[0].Git != repo {
+		t.Errorf("Manifest did not round-trip: %+v", parsed.Deps)
+	}
+
+	// Installing the latest version keeps the v1 checkout a project pins
+	latest, err := core.InstallLibrary(repo, io.Discard)
+	if err != nil {
+		t.Fatalf("Install failed: %v", err)
+	}
+	if latest.Sha == dep.Sha {
+		t.Fatalf("Expected the latest install to be a different commit than v1")
+	}
+	root := t.TempDir()
+	manifest := "; project settings\n{:paths [\"src\"]\n :main app.core ; entry point\n}\n"
+	if err := os.WriteFile(filepath.Join(root, core.ProjectFile), []byte(manifest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pinned, err := core.LoadProject(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pinned.AddDependency(dep)
+	env = core.NewCoreEnvironment()
+	pinned.Apply(env)
+	expr, _ = core.ReadString(`(do (require 'str.core) (version))`)
+	if result, err := core.Eval(expr, env); err != nil || result.String() != `"v1"` {
+		t.Errorf("Expected the project to load its pinned v1, got %v, %v", result, err)
+	}
+	for _, dir := range pinned.LoadPath() {
+		if strings.Contains(dir, latest.Sha) {
+			t.Errorf("Expected only the project's own dependencies on the load path, got %v", pinned.LoadPath())
+		}
+	}
+
+	// Saving keeps the keys and comments it doesn't manage
+	if err := pinned.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filepath.Join(root, core.ProjectFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, kept := range []string{"; project settings", ":main app.core ; entry point", dep.Sha} {
+		if !strings.Contains(string(saved), kept) {
+			t.Errorf("Expected the saved manifest to contain %q, got:\n%s", kept, saved)
+		}
+	}
+	pinned.AddDependency(latest)
+	if err := pinned.Save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := core.LoadProject(root)
+	if err != nil {
+		t.Fatalf("Failed to reload the saved manifest: %v", err)
+	}
+	if len(reloaded.Deps) != 1 || reloaded.Deps[0].Sha != latest.Sha {
+		t.Errorf("Expected the saved :deps to be replaced, got %+v", reloaded.Deps)
+	}
+}
+
+func TestLibraryPathsStayInLibraryDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("GOLISP_HOME", filepath.Join(home, "golisp"))
+	// Directories a library must not be fetched into, renamed or removed
+	for _, dir := range []string{"proj", "etc", filepath.Join("golisp", "lib")} {
+		if err := os.MkdirAll(filepath.Join(home, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, spec := range []string{
+		"../../proj",
+		"https://h/../../../etc",
+		"../lib",
+		"github.com/user/lib/../../..",
+		"github.com/user/lib@../../../../proj",
+		"https://",
+		"https:///",
+		"/",
+	} {
+		if _, err := core.InstallLibrary(spec, io.Discard); err == nil {
+			t.Errorf("%s: expected the install to be refused", spec)
+		}
+		manifest := `{:deps {lib {:git "` + spec + `"}}}`
+		if _, err := core.ParseProject(manifest); err == nil {
+			t.Errorf("%s: expected the dependency to be refused", spec)
+		}
+	}
+	for _, dir := range []string{"proj", "etc", filepath.Join("golisp", "lib")} {
+		if entries, err := os.ReadDir(filepath.Join(home, dir)); err != nil || len(entries) != 0 {
+			t.Errorf("Expected %s to be left alone, got %v, %v", dir, entries, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package core_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestDefprop(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	source := `(do
+		(def *property-seed* 42)
+		(defprop addition-commutes [a (gen/int) b (gen/int)]
+		  (= (+ a b) (+ b a)))
+		(defprop small-numbers [n (gen/nat)]
+		  (< n 10))
+		(defprop short-vectors [xs (gen/vector (gen/int))]
+		  (is (< (count xs) 3))))`
+	expr, err := core.ReadString(source)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if _, err := core.Eval(expr, env); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	var out bytes.Buffer
+	summary := core.RunTests(env, core.TestOptions{Out: &out})
+	if summary.Tests != 3 || summary.Pass != 1 || summary.Fail != 2 || summary.Error != 0 {
+		t.Errorf("Unexpected summary %+v\n%s", summary, out.String())
+	}
+
+	// Failing cases are shrunk to the smallest counterexample
+	for _, want := range []string{
+		"property small-numbers failed",
+		"(seed 42)",
+		"shrunk: [n 10]",
+		"shrunk: [xs [0 0 0]]",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestGenerators(t *testing.T) {
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(count (gen/sample (gen/int) 5))`, "5"},
+		{`(count (gen/sample (gen/int)))`, "10"},
+		{`(first (gen/sample (gen/vector (gen/int)) 1))`, "[]"},
+		{`(first (gen/sample (gen/map (gen/keyword) (gen/int)) 1))`, "{}"},
+		{`(first (gen/sample (gen/elements [:only]) 1))`, ":only"},
+		{`(gen/int)`, "#<generator:int>"},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	errorCases := []string{
+		`(gen/int 1)`,
+		`(gen/vector 1)`,
+		`(gen/elements [])`,
+		`(gen/map (gen/int))`,
+		`(gen/sample 1)`,
+	}
+	for _, input := range errorCases {
+		expr, _ := core.ReadString(input)
+		if _, err := core.Eval(expr, env); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+}
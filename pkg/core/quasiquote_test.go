@@ -1,6 +1,7 @@
 package core_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/leinonen/go-lisp/pkg/core"
@@ -85,3 +86,48 @@ func TestQuasiquoteReader(t *testing.T) {
 		}
 	}
 }
+
+func TestAutoGensym(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	eval := func(input string) core.Value {
+		t.Helper()
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		return result
+	}
+
+	// One expansion gives each x# the same symbol, and y# another
+	expanded := eval("`(let [x# 1 y# 2] [x# y#])").(*core.List)
+	bindings := expanded.Rest().First().(*core.Vector)
+	body := expanded.Rest().Rest().First().(*core.Vector)
+	x, y := bindings.Get(0), bindings.Get(2)
+	if x != body.Get(0) || y != body.Get(1) || x == y {
+		t.Errorf("Expected consistent gensyms, got %s", expanded)
+	}
+	if name := string(x.(core.Symbol)); !strings.HasPrefix(name, "x__") || !strings.HasSuffix(name, "__auto__") {
+		t.Errorf("Expected an x__N__auto__ symbol, got %s", name)
+	}
+
+	// Each expansion gives new symbols
+	if again := eval("`x#"); again == x {
+		t.Errorf("Expected a new gensym per expansion, got %s twice", again)
+	}
+
+	// A macro's local cannot capture the caller's name
+	eval("(defmacro or2 [a b] `(let [v# ~a] (if v# v# ~b)))")
+	eval("(def v 10)")
+	if got := eval("(or2 nil v)"); got.String() != "10" {
+		t.Errorf("Expected 10, got %s", got)
+	}
+
+	// # only ends a symbol before a delimiter, so sets still read
+	if got := eval("`(a# #{1})"); !strings.HasSuffix(got.(*core.List).First().String(), "__auto__") {
+		t.Errorf("Expected an auto-gensym before a set, got %s", got)
+	}
+}
@@ -1,7 +1,9 @@
 package core
 
 import (
+	"errors"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"unicode"
@@ -13,6 +15,7 @@ type Token struct {
 	Type     TokenType
 	Value    string
 	Position Position
+	End      int // Offset just past the token in the source
 }
 
 // TokenType represents the type of a token
@@ -34,15 +37,26 @@ const (
 	TokenQuasiquote
 	TokenUnquote
 	TokenUnquoteSplicing
+	TokenCaret
+	TokenDeref
+	TokenBarSymbol // A symbol written |like this|, read as written
 	TokenEOF
 )
 
+// errUnterminatedString is the lexer error for input that ends in a string
+var errUnterminatedString = errors.New("unterminated string")
+
+// errUnterminatedSymbol is the lexer error for input that ends in a |symbol|
+var errUnterminatedSymbol = errors.New("unterminated |symbol|")
+
 // Lexer tokenizes input
 type Lexer struct {
 	input    string
 	position int
 	line     int
 	column   int
+	offset   int // Offset of input in the whole source, for a Reader
+	comments *[]Comment // Comments seen, if kept
 }
 
 // NewLexer creates a new lexer
@@ -76,12 +90,13 @@ func (l *Lexer) Tokenize() ([]Token, error) {
 		if err != nil {
 			return nil, err
 		}
+		token.End = l.offset + l.position
 
 		tokens = append(tokens, token)
 	}
 
 	// Add EOF token
-	tokens = append(tokens, Token{Type: TokenEOF, Position: l.currentPosition()})
+	tokens = append(tokens, Token{Type: TokenEOF, Position: l.currentPosition(), End: l.offset + l.position})
 	return tokens, nil
 }
 
@@ -106,14 +121,19 @@ func (l *Lexer) currentPosition() Position {
 	return Position{
 		Line:   l.line,
 		Column: l.column,
-		Offset: l.position,
+		Offset: l.offset + l.position,
 	}
 }
 
 func (l *Lexer) skipComment() {
+	pos := l.currentPosition()
+	start := l.position
 	for l.position < len(l.input) && l.current() != '\n' {
 		l.advance()
 	}
+	if l.comments != nil {
+		*l.comments = append(*l.comments, Comment{Text: l.input[start:l.position], Position: pos})
+	}
 }
 
 func (l *Lexer) nextToken() (Token, error) {
@@ -148,6 +168,12 @@ func (l *Lexer) nextToken() (Token, error) {
 	case '`':
 		l.advance()
 		return Token{Type: TokenQuasiquote, Value: "`", Position: pos}, nil
+	case '^':
+		l.advance()
+		return Token{Type: TokenCaret, Value: "^", Position: pos}, nil
+	case '@':
+		l.advance()
+		return Token{Type: TokenDeref, Value: "@", Position: pos}, nil
 	case '~':
 		l.advance()
 		// Check for unquote-splicing (~@)
@@ -158,6 +184,8 @@ func (l *Lexer) nextToken() (Token, error) {
 		return Token{Type: TokenUnquote, Value: "~", Position: pos}, nil
 	case '"':
 		return l.readString()
+	case '|':
+		return l.readBarSymbol()
 	case ':':
 		return l.readKeyword()
 	default:
@@ -191,7 +219,7 @@ func (l *Lexer) readString() (Token, error) {
 	}
 
 	if l.position >= len(l.input) {
-		return Token{}, fmt.Errorf("unterminated string at line %d, column %d", pos.Line, pos.Column)
+		return Token{}, fmt.Errorf("%w at line %d, column %d", errUnterminatedString, pos.Line, pos.Column)
 	}
 
 	value := l.input[start:l.position]
@@ -219,6 +247,8 @@ func (l *Lexer) readKeyword() (Token, error) {
 	return Token{Type: TokenKeyword, Value: value, Position: pos}, nil
 }
 
+// readNumber reads everything that may be part of a number literal, so
+// that parseNumber can reject malformed ones like 12abc as a whole
 func (l *Lexer) readNumber() (Token, error) {
 	pos := l.currentPosition()
 	start := l.position
@@ -227,16 +257,21 @@ func (l *Lexer) readNumber() (Token, error) {
 		l.advance()
 	}
 
-	for l.position < len(l.input) && unicode.IsDigit(l.current()) {
-		l.advance()
-	}
-
-	// Handle decimal point
-	if l.position < len(l.input) && l.current() == '.' {
-		l.advance()
-		for l.position < len(l.input) && unicode.IsDigit(l.current()) {
-			l.advance()
+	// An exponent sign belongs to the number, except in hex or radix
+	// literals where e is a digit
+	digitsStart := l.position
+	for l.position < len(l.input) {
+		char := l.current()
+		if (char == '+' || char == '-') && l.position > digitsStart {
+			prev := l.input[l.position-1]
+			literal := strings.ToLower(l.input[digitsStart:l.position])
+			if (prev != 'e' && prev != 'E') || strings.HasPrefix(literal, "0x") || strings.Contains(literal, "r") {
+				break
+			}
+		} else if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != '_' && char != '.' {
+			break
 		}
+		l.advance()
 	}
 
 	value := l.input[start:l.position]
@@ -250,11 +285,65 @@ func (l *Lexer) readSymbol() (Token, error) {
 	for l.position < len(l.input) && isSymbolChar(l.current()) {
 		l.advance()
 	}
+	// A trailing # makes an auto-gensym symbol, x#, for syntax-quote
+	if l.current() == '#' {
+		if next := l.peek(); next == 0 || unicode.IsSpace(next) || strings.ContainsRune(")]}", next) {
+			l.advance()
+		}
+	}
 
 	value := l.input[start:l.position]
 	return Token{Type: TokenSymbol, Value: value, Position: pos}, nil
 }
 
+// readBarSymbol reads a symbol between bars, which may hold any character;
+// \| and \\ stand for a bar and a backslash
+func (l *Lexer) readBarSymbol() (Token, error) {
+	pos := l.currentPosition()
+	l.advance() // Skip opening bar
+
+	var name strings.Builder
+	for l.position < len(l.input) && l.current() != '|' {
+		if l.current() == '\\' && l.position+1 < len(l.input) {
+			l.advance()
+		}
+		name.WriteByte(l.input[l.position])
+		l.advance()
+	}
+
+	if l.position >= len(l.input) {
+		return Token{}, fmt.Errorf("%w at line %d, column %d", errUnterminatedSymbol, pos.Line, pos.Column)
+	}
+	l.advance() // Skip closing bar
+
+	return Token{Type: TokenBarSymbol, Value: name.String(), Position: pos}, nil
+}
+
+// isPlainSymbol reports whether the symbol name reads back as itself
+// without bars
+func isPlainSymbol(name string) bool {
+	if name == "" || !isSymbolStart(rune(name[0])) {
+		return false
+	}
+	if name[0] == '-' && len(name) > 1 && unicode.IsDigit(rune(name[1])) {
+		return false // Reads as a number
+	}
+	body := strings.TrimSuffix(name, "#") // An auto-gensym x#
+	for i := 1; i < len(body); i++ {
+		if !isSymbolChar(rune(body[i])) {
+			return false
+		}
+	}
+	return true
+}
+
+// barSymbol writes the symbol name between bars, escaping bars and
+// backslashes
+func barSymbol(name string) string {
+	escaped := strings.NewReplacer("\\", "\\\\", "|", "\\|").Replace(name)
+	return "|" + escaped + "|"
+}
+
 func isSymbolStart(char rune) bool {
 	return unicode.IsLetter(char) || char == '_' || char == '+' || char == '-' ||
 		char == '*' || char == '/' || char == '=' || char == '<' || char == '>' ||
@@ -264,14 +353,49 @@ func isSymbolStart(char rune) bool {
 func isSymbolChar(char rune) bool {
 	return unicode.IsLetter(char) || unicode.IsDigit(char) || char == '_' ||
 		char == '-' || char == '+' || char == '*' || char == '/' || char == '=' ||
-		char == '<' || char == '>' || char == '!' || char == '?' || char == '%' || char == '&'
+		char == '<' || char == '>' || char == '!' || char == '?' || char == '%' || char == '&' ||
+		char == '.'
 }
 
 // Parser converts tokens to AST
 type Parser struct {
-	tokens   []Token
-	position int
-	source   string // Original source code for error reporting
+	tokens     []Token
+	position   int
+	source     string               // Original source code for error reporting
+	file       string               // File name attached to list positions
+	spans      map[*List]SourceSpan // Where parsed lists start and end, if recording
+	quoteDepth int                  // Lists inside quoted data are never evaluated
+	config     ReaderConfig         // Case folding and aliases for symbols
+	comments   []Comment            // Comments to attach to lists, in source order
+}
+
+// SetFile names the file being parsed, for the positions of parsed lists
+func (p *Parser) SetFile(file string) {
+	p.file = file
+}
+
+// readerMeta is ^meta form as read; it only survives parsing as the name of
+// a defn or defmacro, where the metadata joins the attribute map
+type readerMeta struct {
+	meta   *HashMap
+	target Value
+}
+
+func (m *readerMeta) String() string {
+	return "^" + m.meta.String() + " " + m.target.String()
+}
+
+// SourceSpan is the extent of a parsed form in its source
+type SourceSpan struct {
+	Start Position
+	End   Position
+}
+
+// RecordSpans makes the parser remember where each evaluable list it parses
+// starts and ends, returning the map that ParseAll fills in
+func (p *Parser) RecordSpans() map[*List]SourceSpan {
+	p.spans = make(map[*List]SourceSpan)
+	return p.spans
 }
 
 
@@ -323,6 +447,15 @@ func (p *Parser) ParseAll() ([]Value, error) {
 }
 
 func (p *Parser) parseExpression() (Value, error) {
+	expr, err := p.parseForm()
+	if m, ok := expr.(*readerMeta); ok {
+		return m.target, err
+	}
+	return expr, err
+}
+
+// parseForm is parseExpression but keeps ^meta for parseList to attach
+func (p *Parser) parseForm() (Value, error) {
 	token := p.tokens[p.position]
 
 	switch token.Type {
@@ -333,36 +466,79 @@ func (p *Parser) parseExpression() (Value, error) {
 	case TokenLeftBrace:
 		return p.parseHashMap()
 	case TokenHash:
+		// #'name is (var name)
+		if p.position+1 < len(p.tokens) && p.tokens[p.position+1].Type == TokenQuote {
+			p.position += 2
+			expr, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			return NewList(Intern("var"), expr), nil
+		}
+		if p.position+1 < len(p.tokens) && p.tokens[p.position+1].Type == TokenSymbol {
+			if value, ok := p.legacyBoolean(p.tokens[p.position+1].Value); ok {
+				p.position += 2
+				return value, nil
+			}
+			return p.parseTagged()
+		}
+		// ##NaN, ##Inf and ##-Inf, as floats print
+		if p.position+2 < len(p.tokens) && p.tokens[p.position+1].Type == TokenHash {
+			if f, ok := symbolicFloats[p.tokens[p.position+2].Value]; ok && p.tokens[p.position+2].Type == TokenSymbol {
+				p.position += 3
+				return NewNumber(f), nil
+			}
+		}
 		return p.parseSet()
 	case TokenQuote:
 		p.position++
+		p.quoteDepth++
 		expr, err := p.parseExpression()
+		p.quoteDepth--
 		if err != nil {
 			return nil, err
 		}
 		return NewList(Intern("quote"), expr), nil
 	case TokenQuasiquote:
 		p.position++
+		p.quoteDepth++
 		expr, err := p.parseExpression()
+		p.quoteDepth--
 		if err != nil {
 			return nil, err
 		}
 		return NewList(Intern("quasiquote"), expr), nil
 	case TokenUnquote:
 		p.position++
+		p.quoteDepth--
 		expr, err := p.parseExpression()
+		p.quoteDepth++
 		if err != nil {
 			return nil, err
 		}
 		return NewList(Intern("unquote"), expr), nil
 	case TokenUnquoteSplicing:
 		p.position++
+		p.quoteDepth--
 		expr, err := p.parseExpression()
+		p.quoteDepth++
 		if err != nil {
 			return nil, err
 		}
 		return NewList(Intern("unquote-splicing"), expr), nil
+	case TokenCaret:
+		return p.parseMeta()
+	case TokenDeref:
+		p.position++
+		expr, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		return NewList(Intern("deref"), expr), nil
 	case TokenSymbol:
+		p.position++
+		return p.symbol(token.Value), nil
+	case TokenBarSymbol:
 		p.position++
 		return Intern(token.Value), nil
 	case TokenKeyword:
@@ -386,12 +562,14 @@ func (p *Parser) parseExpression() (Value, error) {
 }
 
 func (p *Parser) parseList() (Value, error) {
+	open := p.position
+	start := p.tokens[p.position].Position
 	p.position++ // Skip '('
 
 	var elements []Value
 
 	for p.position < len(p.tokens) && p.tokens[p.position].Type != TokenRightParen {
-		expr, err := p.parseExpression()
+		expr, err := p.parseForm()
 		if err != nil {
 			return nil, err
 		}
@@ -409,8 +587,114 @@ func (p *Parser) parseList() (Value, error) {
 			WithSource(p.source)
 	}
 
+	end := p.tokens[p.position].Position
 	p.position++ // Skip ')'
-	return NewList(elements...), nil
+	quiet := quietNames(elements)
+	elements = attachDefinitionMeta(elements)
+	list := NewList(elements...)
+	if list != nil {
+		start.File = p.file
+		list.SetPosition(start)
+		list.quiet = quiet
+		list.comments = p.commentsAround(open, p.position-1)
+	}
+	if p.spans != nil && p.quoteDepth <= 0 && len(elements) > 0 {
+		p.spans[list] = SourceSpan{Start: start, End: end}
+		if elements[0] == Intern("quote") {
+			for _, quoted := range elements[1:] {
+				p.forgetSpans(quoted)
+			}
+		}
+	}
+	return list, nil
+}
+
+// parseMeta reads ^:keyword form or ^{...} form
+func (p *Parser) parseMeta() (Value, error) {
+	token := p.tokens[p.position]
+	p.position++ // Skip '^'
+	if p.position >= len(p.tokens) {
+		return nil, NewLispError(ParseError, "metadata must be followed by a form").
+			WithPosition(token.Position).
+			WithSource(p.source)
+	}
+
+	metaForm, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	meta, ok := metaForm.(*HashMap)
+	if keyword, isKeyword := metaForm.(Keyword); isKeyword {
+		meta, ok = NewHashMapWithPairs(keyword, Symbol("true")), true
+	}
+	if !ok {
+		return nil, NewLispErrorf(ParseError, "metadata must be a keyword or map, got %s", metaForm).
+			WithPosition(token.Position).
+			WithSource(p.source)
+	}
+
+	if p.position >= len(p.tokens) || p.tokens[p.position].Type == TokenEOF {
+		return nil, NewLispError(ParseError, "metadata must be followed by a form").
+			WithPosition(token.Position).
+			WithSource(p.source)
+	}
+	target, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	return &readerMeta{meta: meta, target: target}, nil
+}
+
+// attachDefinitionMeta moves ^meta on a defn or defmacro name into its
+// attribute map and unwraps ^meta anywhere else, where it has no effect
+func attachDefinitionMeta(elements []Value) []Value {
+	var meta *HashMap
+	for i, element := range elements {
+		if m, ok := element.(*readerMeta); ok {
+			elements[i] = m.target
+			if i == 1 {
+				meta = m.meta
+			}
+		}
+	}
+
+	if meta == nil || (elements[0] != Intern("defn") && elements[0] != Intern("defmacro")) {
+		return elements
+	}
+
+	// After the name: optional docstring, optional attribute map, params, body
+	at := 2
+	if len(elements) > 4 {
+		if _, isDoc := elements[2].(String); isDoc {
+			at = 3
+		}
+	}
+	if at < len(elements) {
+		if attrs, ok := elements[at].(*HashMap); ok && len(elements)-at > 2 {
+			for _, key := range meta.keys {
+				attrs.Set(key, meta.Get(key))
+			}
+			return elements
+		}
+	}
+	if len(elements)-at < 2 {
+		return elements // No body to keep the attribute map apart from the params
+	}
+	result := append([]Value(nil), elements[:at]...)
+	result = append(result, meta)
+	return append(result, elements[at:]...)
+}
+
+// forgetSpans drops the spans recorded for lists nested in value
+func (p *Parser) forgetSpans(value Value) {
+	if list, ok := value.(*List); ok {
+		delete(p.spans, list)
+	}
+	if items, err := collectionToSlice(value); err == nil {
+		for _, item := range items {
+			p.forgetSpans(item)
+		}
+	}
 }
 
 func (p *Parser) parseVector() (Value, error) {
@@ -419,7 +703,7 @@ func (p *Parser) parseVector() (Value, error) {
 	var elements []Value
 
 	for p.position < len(p.tokens) && p.tokens[p.position].Type != TokenRightBracket {
-		expr, err := p.parseExpression()
+		expr, err := p.parseForm()
 		if err != nil {
 			return nil, err
 		}
@@ -431,7 +715,15 @@ func (p *Parser) parseVector() (Value, error) {
 	}
 
 	p.position++ // Skip ']'
-	return NewVector(elements...), nil
+	quiet := quietNames(elements)
+	for i, element := range elements {
+		if m, ok := element.(*readerMeta); ok {
+			elements[i] = m.target
+		}
+	}
+	vector := NewVector(elements...)
+	vector.quiet = quiet
+	return vector, nil
 }
 
 func (p *Parser) parseHashMap() (Value, error) {
@@ -486,22 +778,115 @@ func (p *Parser) parseSet() (Value, error) {
 	return NewSetWithElements(elements...), nil
 }
 
+// symbolicFloats are the values read after ##
+var symbolicFloats = map[string]float64{"NaN": math.NaN(), "Inf": math.Inf(1), "-Inf": math.Inf(-1)}
+
+// parseTagged reads #tag form, applying the tag's reader
+func (p *Parser) parseTagged() (Value, error) {
+	start := p.tokens[p.position].Position
+	tag := Symbol(p.tokens[p.position+1].Value)
+	p.position += 2 // Skip '#' and the tag
+	if p.position >= len(p.tokens) || p.tokens[p.position].Type == TokenEOF {
+		return nil, NewLispErrorf(ParseError, "#%s must be followed by a form", tag).
+			WithPosition(start).
+			WithSource(p.source)
+	}
+
+	form, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	value, err := readTagged(tag, form)
+	if err != nil {
+		return nil, NewLispErrorf(ParseError, "%v", err).
+			WithPosition(start).
+			WithSource(p.source)
+	}
+	return value, nil
+}
+
+// parseNumber reads integers in decimal, hex (0xFF), octal (0o17), binary
+// (0b1010) or any radix from 2 to 36 (36rZZ), and floats with an optional
+// exponent (1.5e-9), and decimals with an M suffix (12.34M). Underscores may
+// separate digits: 1_000_000.
 func (p *Parser) parseNumber(value string) (Value, error) {
-	if strings.Contains(value, ".") {
-		f, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid float: %s", value)
+	text, negative := strings.CutPrefix(value, "-")
+	lower := strings.ToLower(text)
+
+	radix, digits := 10, text
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		radix, digits = 16, text[2:]
+	case strings.HasPrefix(lower, "0o"):
+		radix, digits = 8, text[2:]
+	case strings.HasPrefix(lower, "0b"):
+		radix, digits = 2, text[2:]
+	case strings.HasSuffix(text, "M"):
+		d, ok := parseDecimal(withoutSeparators(strings.TrimSuffix(text, "M")))
+		if !ok || !validSeparators(strings.TrimSuffix(text, "M")) {
+			return nil, fmt.Errorf("invalid decimal: %s", value)
+		}
+		if negative {
+			d = d.neg()
+		}
+		return d, nil
+	default:
+		if prefix, rest, ok := strings.Cut(lower, "r"); ok {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n < 2 || n > 36 {
+				return nil, fmt.Errorf("invalid number: %s (radix must be 2-36)", value)
+			}
+			radix, digits = n, rest
+		} else if strings.ContainsAny(lower, ".e") {
+			f, err := strconv.ParseFloat(withoutSeparators(text), 64)
+			if err != nil || !validSeparators(text) {
+				return nil, fmt.Errorf("invalid float: %s", value)
+			}
+			if negative {
+				f = -f
+			}
+			return NewNumber(f), nil
 		}
-		return NewNumber(f), nil
 	}
 
-	i, err := strconv.ParseInt(value, 10, 64)
+	if !validSeparators(digits) {
+		return nil, fmt.Errorf("invalid integer: %s", value)
+	}
+	i, err := strconv.ParseInt(withoutSeparators(digits), radix, 64)
 	if err != nil {
+		if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+			return nil, fmt.Errorf("integer out of range: %s", value)
+		}
 		return nil, fmt.Errorf("invalid integer: %s", value)
 	}
+	if negative {
+		i = -i
+	}
 	return NewNumber(i), nil
 }
 
+// validSeparators reports whether every underscore in digits sits between
+// two digits
+func validSeparators(digits string) bool {
+	for i := 0; i < len(digits); i++ {
+		if digits[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(digits)-1 || !isDigitChar(digits[i-1]) || !isDigitChar(digits[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigitChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func withoutSeparators(digits string) string {
+	return strings.ReplaceAll(digits, "_", "")
+}
+
 // ReadString parses a string into a Lisp value
 func ReadString(input string) (Value, error) {
 	lexer := NewLexer(input)
@@ -0,0 +1,198 @@
+package core
+
+import (
+	"io"
+	"strings"
+)
+
+// ReaderConfig holds options for how the reader reads symbols
+type ReaderConfig struct {
+	// FoldCase reads symbols in lower case, so DEFN and defn are the same
+	FoldCase bool
+	// LegacyAliases reads the names of older dialects as their core
+	// equivalents: define as def, lambda as fn, begin as do, and the
+	// booleans #t and #f as true and nil
+	LegacyAliases bool
+
+	aliases map[Symbol]Symbol // Added with set-reader-alias!, never changed once set
+}
+
+// legacyAliases are the names LegacyAliases reads differently
+var legacyAliases = map[Symbol]Symbol{
+	"define": "def",
+	"lambda": "fn",
+	"begin":  "do",
+}
+
+// SetReaderConfig sets how the interpreter reads the code it is given from
+// now on, keeping the aliases added with RegisterReaderAlias
+func (env *Environment) SetReaderConfig(config ReaderConfig) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	config.aliases = state.reader.aliases
+	state.reader = config
+}
+
+// CurrentReaderConfig returns how the interpreter reads code
+func (env *Environment) CurrentReaderConfig() ReaderConfig {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.reader
+}
+
+// RegisterReaderAlias makes the interpreter read the symbol from as to.
+// Aliases apply to code read afterwards, whatever the ReaderConfig.
+func (env *Environment) RegisterReaderAlias(from, to Symbol) {
+	env.updateReaderAliases(func(aliases map[Symbol]Symbol) { aliases[from] = to })
+}
+
+// RemoveReaderAlias undoes RegisterReaderAlias
+func (env *Environment) RemoveReaderAlias(from Symbol) {
+	env.updateReaderAliases(func(aliases map[Symbol]Symbol) { delete(aliases, from) })
+}
+
+// updateReaderAliases replaces the interpreter's aliases with a changed
+// copy, so that readers already holding the old ones aren't affected
+func (env *Environment) updateReaderAliases(change func(map[Symbol]Symbol)) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	aliases := make(map[Symbol]Symbol, len(state.reader.aliases)+1)
+	for from, to := range state.reader.aliases {
+		aliases[from] = to
+	}
+	change(aliases)
+	state.reader.aliases = aliases
+}
+
+// NewReader creates a reader of the forms in in that reads them the way
+// the interpreter does
+func (env *Environment) NewReader(in io.Reader) *Reader {
+	reader := NewReader(in)
+	reader.SetConfig(env.CurrentReaderConfig())
+	return reader
+}
+
+// ReadString parses input into a Lisp value the way the interpreter reads
+// code
+func (env *Environment) ReadString(input string) (Value, error) {
+	tokens, err := NewLexer(input).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+	parser := NewParserWithSource(tokens, input)
+	parser.SetConfig(env.CurrentReaderConfig())
+	return parser.Parse()
+}
+
+// SetConfig overrides the configuration the parser was created with
+func (p *Parser) SetConfig(config ReaderConfig) {
+	p.config = config
+}
+
+// symbol reads the text of a symbol token under the parser's configuration
+func (p *Parser) symbol(text string) Symbol {
+	if p.config.FoldCase {
+		text = strings.ToLower(text)
+	}
+	name := Symbol(text)
+
+	if alias, ok := p.config.aliases[name]; ok {
+		return Intern(string(alias))
+	}
+	if p.config.LegacyAliases {
+		if alias, ok := legacyAliases[name]; ok {
+			return Intern(string(alias))
+		}
+	}
+	return Intern(text)
+}
+
+// legacyBoolean reads #t and #f, the booleans of older dialects, when
+// LegacyAliases is set. tag is the symbol after the #.
+func (p *Parser) legacyBoolean(tag string) (Value, bool) {
+	if !p.config.LegacyAliases {
+		return nil, false
+	}
+	switch tag {
+	case "t":
+		return Intern("true"), true
+	case "f":
+		return Nil{}, true
+	}
+	return nil, false
+}
+
+// aliasName accepts 'name or "name". Once an alias is set, 'name reads as
+// its target, so removing it takes the name as a string.
+func aliasName(value Value) (Symbol, bool) {
+	switch v := value.(type) {
+	case Symbol:
+		return v, true
+	case String:
+		return Symbol(v), v != ""
+	}
+	return "", false
+}
+
+// setupReaderOperations adds set-reader-alias!, remove-reader-alias! and
+// reader-aliases
+func setupReaderOperations(env *Environment) {
+	// (set-reader-alias! 'define 'def) reads define as def in code the
+	// interpreter reads from then on, such as files loaded afterwards
+	env.Set(Intern("set-reader-alias!"), &BuiltinFunction{
+		Name: "set-reader-alias!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("set-reader-alias! expects 2 arguments, got %d", len(args))
+			}
+			from, ok := aliasName(args[0])
+			to, ok2 := aliasName(args[1])
+			if !ok || !ok2 {
+				return nil, NewTypeError("set-reader-alias! expects two symbols or strings, got %s and %s", printString(args[0]), printString(args[1]))
+			}
+			env.RegisterReaderAlias(from, to)
+			return from, nil
+		},
+	})
+
+	// (remove-reader-alias! "define") stops reading define as an alias
+	env.Set(Intern("remove-reader-alias!"), &BuiltinFunction{
+		Name: "remove-reader-alias!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("remove-reader-alias! expects 1 argument, got %d", len(args))
+			}
+			from, ok := aliasName(args[0])
+			if !ok {
+				return nil, NewTypeError("remove-reader-alias! expects a symbol or string, got %s", printString(args[0]))
+			}
+			env.RemoveReaderAlias(from)
+			return Nil{}, nil
+		},
+	})
+
+	// (reader-aliases) is a map of every alias in effect, including the
+	// legacy ones when the reader configuration enables them
+	env.Set(Intern("reader-aliases"), &BuiltinFunction{
+		Name: "reader-aliases",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("reader-aliases expects 0 arguments, got %d", len(args))
+			}
+			result := NewHashMap()
+			config := env.CurrentReaderConfig()
+			if config.LegacyAliases {
+				for from, to := range legacyAliases {
+					result.Set(from, to)
+				}
+			}
+			for from, to := range config.aliases {
+				result.Set(from, to)
+			}
+			return result, nil
+		},
+	})
+}
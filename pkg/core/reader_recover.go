@@ -0,0 +1,101 @@
+package core
+
+import "fmt"
+
+// SyntaxError is a ParseError found in recovery mode, with the extent of
+// the source skipped because of it
+type SyntaxError struct {
+	*LispError
+	Span SourceSpan
+}
+
+// TokenizeRecover is Tokenize for tooling: an unexpected character is
+// reported and skipped, and an unterminated string ends the input, so the
+// tokens around the errors can still be parsed
+func (l *Lexer) TokenizeRecover() ([]Token, []*SyntaxError) {
+	var tokens []Token
+	var errs []*SyntaxError
+
+	for {
+		token, err := l.NextToken()
+		switch {
+		case err != nil:
+			errs = append(errs, err.(*SyntaxError))
+		case token.Type == TokenEOF:
+			return append(tokens, token), errs
+		case token.Type != TokenWhitespace && token.Type != TokenComment:
+			tokens = append(tokens, token)
+		}
+	}
+}
+
+// ParseAllRecover is ParseAll for tooling like the linter: after a syntax
+// error it resumes at the next top-level form instead of stopping, and
+// returns every form it could parse along with the errors
+func (p *Parser) ParseAllRecover() ([]Value, []*SyntaxError) {
+	var forms []Value
+	var errs []*SyntaxError
+
+	for p.position < len(p.tokens) && p.tokens[p.position].Type != TokenEOF {
+		start := p.position
+		form, err := p.parseExpression()
+		if err == nil {
+			forms = append(forms, form)
+			continue
+		}
+
+		failed := p.position
+		p.position = p.nextTopLevel(start)
+		p.quoteDepth = 0
+
+		first := p.tokens[start]
+		lispErr, ok := err.(*LispError)
+		if !ok {
+			lispErr = NewLispError(ParseError, err.Error()).WithPosition(first.Position)
+		}
+		if failed >= p.position {
+			// The form was still open where the next one starts
+			lispErr = NewLispError(ParseError, fmt.Sprintf("unclosed %s", first.Value)).WithPosition(first.Position)
+		}
+		errs = append(errs, &SyntaxError{
+			LispError: lispErr,
+			Span:      SourceSpan{Start: first.Position, End: p.tokens[p.position-1].Position},
+		})
+	}
+	return forms, errs
+}
+
+// nextTopLevel finds where parsing resumes after a syntax error in the form
+// starting at the token start: just after the form's brackets balance, or
+// at the next token that starts a line in the first column, whichever comes
+// first
+func (p *Parser) nextTopLevel(start int) int {
+	depth := 0
+	line := p.tokens[start].Position.Line
+	last := len(p.tokens) - 1 // The EOF token
+
+	for i := start; i < last; i++ {
+		token := p.tokens[i]
+		if i > start && token.Position.Column == 1 && token.Position.Line > line {
+			return i
+		}
+		switch token.Type {
+		case TokenLeftParen, TokenLeftBracket, TokenLeftBrace:
+			depth++
+		case TokenRightParen, TokenRightBracket, TokenRightBrace:
+			depth--
+		case TokenHash:
+			// A tag belongs to the form after it
+			if i+1 < last && p.tokens[i+1].Type == TokenSymbol {
+				i++
+			}
+			continue
+		case TokenQuote, TokenQuasiquote, TokenUnquote, TokenUnquoteSplicing, TokenCaret, TokenDeref:
+			continue
+		}
+		if depth <= 0 {
+			return i + 1
+		}
+	}
+	return last
+}
@@ -0,0 +1,155 @@
+package core
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Reader parses forms one at a time from an io.Reader, reading only as much
+// input as the next form needs, so large files and network streams can be
+// evaluated as they arrive:
+//
+//	reader := core.NewReader(conn)
+//	for {
+//		form, pos, err := reader.Next()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+type Reader struct {
+	in     *bufio.Reader
+	text   string   // Input read but not yet parsed into forms
+	start  Position // Where text starts in the input
+	parser *Parser  // Parses the tokens of text; nil until text is lexed
+	done   bool     // The input is exhausted
+	file   string
+	config ReaderConfig
+	spans  map[*List]SourceSpan
+}
+
+// NewReader creates a reader of the forms in in
+func NewReader(in io.Reader) *Reader {
+	return &Reader{
+		in:    bufio.NewReader(in),
+		start: Position{Line: 1, Column: 1},
+	}
+}
+
+// SetFile names the file being read, for the positions of forms
+func (r *Reader) SetFile(file string) {
+	r.file = file
+}
+
+// SetConfig overrides the configuration the reader was created with
+func (r *Reader) SetConfig(config ReaderConfig) {
+	r.config = config
+}
+
+// RecordSpans makes the reader remember where each evaluable list it reads
+// starts and ends, returning the map that Next fills in
+func (r *Reader) RecordSpans() map[*List]SourceSpan {
+	r.spans = make(map[*List]SourceSpan)
+	return r.spans
+}
+
+// Next returns the next form and where it starts, or io.EOF when there are
+// no more. A form split across lines is read once all of it has arrived.
+// After a syntax error Next can be called again, and continues after the
+// token that caused it.
+func (r *Reader) Next() (Value, Position, error) {
+	for {
+		if r.parser == nil {
+			tokens, err := newLexerAt(r.text, r.start).Tokenize()
+			if (errors.Is(err, errUnterminatedString) || errors.Is(err, errUnterminatedSymbol)) && !r.done {
+				if err := r.readLine(); err != nil {
+					return nil, Position{}, err
+				}
+				continue
+			}
+			if err != nil {
+				// The rest of the text cannot be lexed, so it is dropped
+				r.text, r.start = "", r.endOfText()
+				return nil, Position{}, err
+			}
+			r.parser = NewParser(tokens)
+			r.parser.SetFile(r.file)
+			r.parser.SetConfig(r.config)
+			r.parser.spans = r.spans
+		}
+
+		p := r.parser
+		start := p.position
+		token := p.tokens[start]
+		if token.Type != TokenEOF {
+			form, err := p.parseExpression()
+			if err == nil {
+				pos := token.Position
+				pos.File = r.file
+				return form, pos, nil
+			}
+			// Running out of tokens means the form continues on the next line
+			if p.position < len(p.tokens)-1 || r.done {
+				if p.position < len(p.tokens)-1 {
+					p.position++
+				}
+				return nil, Position{}, err
+			}
+			p.position = start
+		} else if r.done {
+			return nil, Position{}, io.EOF
+		}
+
+		if err := r.readLine(); err != nil {
+			return nil, Position{}, err
+		}
+	}
+}
+
+// ReadAll returns every remaining form
+func (r *Reader) ReadAll() ([]Value, error) {
+	var forms []Value
+	for {
+		form, _, err := r.Next()
+		if err == io.EOF {
+			return forms, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		forms = append(forms, form)
+	}
+}
+
+// readLine drops the text already parsed and appends the next line of input
+func (r *Reader) readLine() error {
+	if p := r.parser; p != nil {
+		rest := p.tokens[p.position].Position
+		r.text = r.text[rest.Offset-r.start.Offset:]
+		r.start = rest
+		r.parser = nil
+	}
+
+	line, err := r.in.ReadString('\n')
+	r.text += line
+	if err == io.EOF {
+		r.done = true
+		return nil
+	}
+	return err
+}
+
+// endOfText is the position just past the text read so far
+func (r *Reader) endOfText() Position {
+	lexer := newLexerAt(r.text, r.start)
+	for lexer.position < len(lexer.input) {
+		lexer.advance()
+	}
+	return lexer.currentPosition()
+}
+
+// newLexerAt creates a lexer for input that starts at pos in a larger source
+func newLexerAt(input string, pos Position) *Lexer {
+	return &Lexer{input: input, line: pos.Line, column: pos.Column, offset: pos.Offset}
+}
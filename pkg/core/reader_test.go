@@ -1,6 +1,9 @@
 package core_test
 
 import (
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/leinonen/go-lisp/pkg/core"
@@ -239,6 +242,93 @@ func TestParserErrors(t *testing.T) {
 	}
 }
 
+func TestInvalidNumbers(t *testing.T) {
+	for input, message := range map[string]string{
+		"12abc":              "invalid integer: 12abc",
+		"1__000":             "invalid integer: 1__000",
+		"1_":                 "invalid integer: 1_",
+		"0x":                 "invalid integer: 0x",
+		"0b102":              "invalid integer: 0b102",
+		"1e":                 "invalid float: 1e",
+		"1.5_":               "invalid float: 1.5_",
+		"37r1":               "radix must be 2-36",
+		"0x8000000000000000": "integer out of range",
+	} {
+		if _, err := core.ReadString(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+
+	// Hex digits are not exponents, so the minus starts a new token
+	values, err := core.ReadString("[0x1e-5]")
+	if err != nil || values.String() != "[30 -5]" {
+		t.Errorf("Expected [30 -5], got %v (%v)", values, err)
+	}
+}
+
+func TestTaggedLiterals(t *testing.T) {
+	core.RegisterStruct("Address", address{})
+	env := core.NewCoreEnvironment()
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`#inst "2024-01-01T00:00:00Z"`, `#inst "2024-01-01T00:00:00Z"`},
+		{`#inst "2024-03-05"`, `#inst "2024-03-05T00:00:00Z"`},
+		{`(= #inst "2024-01-01T00:00:00Z" #inst "2024-01-01T02:00:00+02:00")`, "true"},
+		{`#uuid "123E4567-E89B-12D3-A456-426614174000"`, `#uuid "123e4567-e89b-12d3-a456-426614174000"`},
+		{`(= #uuid "123e4567-e89b-12d3-a456-426614174000" #uuid "123E4567-e89b-12d3-a456-426614174000")`, "true"},
+		{`(list (inst? #inst "2024") (uuid? (random-uuid)) (uuid? "x"))`, "(true true nil)"},
+		{`#Address{:city "Oslo"}`, `#Address{:city "Oslo" :zip nil}`},
+		{`(from-struct #Address{:city "Oslo" :zip 150})`, `{:city "Oslo" :zip 150}`},
+		{`(set-tag-reader! 'test/point (fn [v] (hash-map :x (first v) :y (nth v 1))))`, "test/point"},
+		{`#test/point [1 2]`, `{:x 1 :y 2}`},
+		{`(read-string "#test/point [3 4]")`, `{:x 3 :y 4}`},
+		{`'#test/unknown {:a 1}`, `#test/unknown {:a 1}`},
+		{`(read-string "[##NaN ##Inf ##-Inf]")`, `[##NaN ##Inf ##-Inf]`},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Errorf("Parse error for '%s': %v", test.input, err)
+			continue
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", test.input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("Expected %s for '%s', got %s", test.expected, test.input, result)
+		}
+	}
+
+	// Printed values read back as equal values
+	for _, input := range []string{`#inst "2024-01-01T10:30:00.5+01:00"`, `(random-uuid)`, `#Address{:city "Rome" :zip 100}`} {
+		expr, _ := core.ReadString(input)
+		value, _ := core.Eval(expr, env)
+		reread, err := core.ReadString(value.String())
+		if err != nil || reread.String() != value.String() {
+			t.Errorf("Expected %s to read back, got %v (%v)", value, reread, err)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`#inst "yesterday"`: `#inst: invalid time "yesterday"`,
+		`#uuid "1234"`:      "#uuid: expected a UUID string",
+		`#inst`:             "#inst must be followed by a form",
+	} {
+		if _, err := core.ReadString(input); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected a read error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+	expr, _ := core.ReadString(`#test/missing 1`)
+	if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), "no reader for tag #test/missing") {
+		t.Errorf("Expected evaluating an unknown tag to fail, got: %v", err)
+	}
+}
+
 func TestNumberParsing(t *testing.T) {
 	tests := []struct {
 		input      string
@@ -252,6 +342,18 @@ func TestNumberParsing(t *testing.T) {
 		{"3.14", false, 3, 3.14},
 		{"-3.14", false, -3, -3.14},
 		{"0.0", false, 0, 0.0},
+		{"0xFF", true, 255, 255.0},
+		{"-0x10", true, -16, -16.0},
+		{"0o17", true, 15, 15.0},
+		{"0b1010", true, 10, 10.0},
+		{"2r1010", true, 10, 10.0},
+		{"36rZZ", true, 1295, 1295.0},
+		{"1_000_000", true, 1000000, 1000000.0},
+		{"0xFF_FF", true, 65535, 65535.0},
+		{"1e3", false, 1000, 1000.0},
+		{"1.5E-3", false, 0, 0.0015},
+		{"-2.5e+2", false, -250, -250.0},
+		{"1_000.5", false, 1000, 1000.5},
 	}
 
 	for _, test := range tests {
@@ -280,3 +382,326 @@ func TestNumberParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestReaderConfig(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	eval := func(input string) string {
+		t.Helper()
+		expr, err := env.ReadString(input)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Failed to evaluate %s: %v", input, err)
+		}
+		return result.String()
+	}
+
+	if _, err := env.ReadString("(define x 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if result, _ := env.ReadString("(define x 1)"); result.String() != "(define x 1)" {
+		t.Errorf("Expected no aliases by default, got %s", result)
+	}
+
+	env.SetReaderConfig(core.ReaderConfig{LegacyAliases: true})
+	eval("(define square (lambda (x) (begin (* x x))))")
+	if result := eval("(square 4)"); result != "16" {
+		t.Errorf("Expected legacy forms to work, got %s", result)
+	}
+
+	env.SetReaderConfig(core.ReaderConfig{FoldCase: true})
+	if result, _ := env.ReadString(`(DEF Answer "Mixed Case")`); result.String() != `(def answer "Mixed Case")` {
+		t.Errorf("Expected symbols in lower case and strings unchanged, got %s", result)
+	}
+	// A parser can use its own configuration
+	tokens, _ := core.NewLexer("(DEF X)").Tokenize()
+	parser := core.NewParser(tokens)
+	parser.SetConfig(core.ReaderConfig{})
+	if result, _ := parser.Parse(); result.String() != "(DEF X)" {
+		t.Errorf("Expected the parser configuration to apply, got %s", result)
+	}
+	env.SetReaderConfig(core.ReaderConfig{})
+
+	eval("(set-reader-alias! 'fun 'fn)")
+	if result := eval("((fun [x] (+ x 1)) 2)"); result != "3" {
+		t.Errorf("Expected a user alias to work, got %s", result)
+	}
+	if result := eval("(reader-aliases)"); result != "{fun fn}" {
+		t.Errorf("Unexpected aliases %s", result)
+	}
+	eval(`(remove-reader-alias! "fun")`)
+	if result, _ := env.ReadString("(fun)"); result.String() != "(fun)" {
+		t.Errorf("Expected the alias to be removed, got %s", result)
+	}
+}
+
+func TestReaderNext(t *testing.T) {
+	source := "(def x 1) ; one\n" +
+		"(def s \"two\nlines\")\n" +
+		"'\n" +
+		"quoted\n" +
+		"[1\n 2]"
+	reader := core.NewReader(strings.NewReader(source))
+	reader.SetFile("forms.lisp")
+
+	expected := []struct {
+		form string
+		pos  core.Position
+	}{
+		{`(def x 1)`, core.Position{Line: 1, Column: 1, Offset: 0, File: "forms.lisp"}},
+		{`(def s "two\nlines")`, core.Position{Line: 2, Column: 1, Offset: 16, File: "forms.lisp"}},
+		{`(quote quoted)`, core.Position{Line: 4, Column: 1, Offset: 36, File: "forms.lisp"}},
+		{`[1 2]`, core.Position{Line: 6, Column: 1, Offset: 45, File: "forms.lisp"}},
+	}
+	for _, want := range expected {
+		form, pos, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Unexpected error reading %s: %v", want.form, err)
+		}
+		if form.String() != want.form {
+			t.Errorf("Expected %s, got %s", want.form, form.String())
+		}
+		if pos != want.pos {
+			t.Errorf("Expected %s at %+v, got %+v", want.form, want.pos, pos)
+		}
+	}
+	if _, _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReaderStreams(t *testing.T) {
+	in, out := io.Pipe()
+	reader := core.NewReader(in)
+
+	// Each form is returned as soon as it is complete, before the input ends
+	go func() {
+		out.Write([]byte("(+ 1\n"))
+		out.Write([]byte("2) :next\n"))
+	}()
+	form, _, err := reader.Next()
+	if err != nil || form.String() != "(+ 1 2)" {
+		t.Fatalf("Expected (+ 1 2), got %v, %v", form, err)
+	}
+	form, _, err = reader.Next()
+	if err != nil || form.String() != ":next" {
+		t.Fatalf("Expected :next, got %v, %v", form, err)
+	}
+	out.Close()
+	if _, _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	// Reading continues after a syntax error
+	reader := core.NewReader(strings.NewReader(") (a)\n(b"))
+	if _, _, err := reader.Next(); err == nil {
+		t.Errorf("Expected an error for the stray )")
+	}
+	if form, _, err := reader.Next(); err != nil || form.String() != "(a)" {
+		t.Errorf("Expected (a) after the error, got %v, %v", form, err)
+	}
+	if _, _, err := reader.Next(); err == nil || !strings.Contains(err.Error(), "unexpected end of input") {
+		t.Errorf("Expected the unfinished list to fail, got %v", err)
+	}
+	if _, _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF, got %v", err)
+	}
+
+	forms, err := core.NewReader(strings.NewReader(`(a) "unterminated`)).ReadAll()
+	if err == nil || !strings.Contains(err.Error(), "unterminated string") {
+		t.Errorf("Expected unterminated string error, got %v, %v", forms, err)
+	}
+}
+
+func TestParseAllRecover(t *testing.T) {
+	// The mismatched ] leaves (b) inside the broken form, which ends at the
+	// next line starting in the first column
+	source := "(a [1 2) (b)\n(c\n(d) }\n(e)"
+	tokens, lexErrs := core.NewLexer(source).TokenizeRecover()
+	if len(lexErrs) != 0 {
+		t.Fatalf("Unexpected lexer errors: %v", lexErrs)
+	}
+	forms, errs := core.NewParser(tokens).ParseAllRecover()
+
+	var got []string
+	for _, form := range forms {
+		got = append(got, form.String())
+	}
+	if strings.Join(got, " ") != "(d) (e)" {
+		t.Errorf("Expected (d) (e), got %s", strings.Join(got, " "))
+	}
+
+	expected := []struct {
+		message    string
+		start, end string
+	}{
+		{"unexpected token: )", "1:1", "1:12"},
+		{"unclosed (", "2:1", "2:2"},
+		{"unexpected token: }", "3:5", "3:5"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d errors, got %v", len(expected), errs)
+	}
+	at := func(pos core.Position) string {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	for i, want := range expected {
+		err := errs[i]
+		if err.Message != want.message || at(err.Span.Start) != want.start || at(err.Span.End) != want.end {
+			t.Errorf("Expected %s from %s to %s, got %s from %s to %s", want.message, want.start, want.end,
+				err.Message, at(err.Span.Start), at(err.Span.End))
+		}
+	}
+
+	_, lexErrs = core.NewLexer("(a) $ (b \"open").TokenizeRecover()
+	if len(lexErrs) != 2 || lexErrs[0].Message != "unexpected character: $" || lexErrs[1].Message != "unterminated string" {
+		t.Errorf("Expected an unexpected character and an unterminated string, got %v", lexErrs)
+	}
+}
+
+func TestParserComments(t *testing.T) {
+	source := `;; header
+
+; first
+(def a 1) ; after a
+(defn f [x]
+  ;; inside
+  (g x)) ; after f
+(h) ; after h
+`
+	lexer := core.NewLexer(source)
+	lexer.KeepComments()
+	tokens, err := lexer.Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+	if len(lexer.Comments()) != 6 {
+		t.Fatalf("Expected 6 comments, got %v", lexer.Comments())
+	}
+	parser := core.NewParser(tokens)
+	parser.AttachComments(lexer.Comments())
+	forms, err := parser.ParseAll()
+	if err != nil {
+		t.Fatalf("ParseAll failed: %v", err)
+	}
+
+	describe := func(form core.Value) string {
+		comments := form.(*core.List).Comments()
+		if comments == nil {
+			return "none"
+		}
+		var parts []string
+		for _, comment := range comments.Leading {
+			parts = append(parts, comment.Text)
+		}
+		if comments.Trailing != nil {
+			parts = append(parts, "trailing "+comments.Trailing.Content())
+		}
+		return strings.Join(parts, " | ")
+	}
+
+	inner := forms[1].(*core.List).Rest().Rest().Rest().First()
+	tests := []struct {
+		form     core.Value
+		expected string
+	}{
+		{forms[0], ";; header | ; first | trailing after a"},
+		{forms[1], "trailing after f"},
+		{inner, ";; inside"},
+		{forms[2], "trailing after h"},
+	}
+	for _, test := range tests {
+		if got := describe(test.form); got != test.expected {
+			t.Errorf("Expected comments of %s to be %q, got %q", test.form, test.expected, got)
+		}
+	}
+
+	// Without AttachComments lists have none
+	forms, _ = core.NewParser(tokens).ParseAll()
+	if forms[0].(*core.List).Comments() != nil {
+		t.Errorf("Expected no comments without AttachComments")
+	}
+}
+
+func TestLexerNextToken(t *testing.T) {
+	source := "(def s \"a\") ; note\n  @x \x01 \"open"
+	lexer := core.NewLexer(source)
+
+	var kinds, texts []string
+	var errs []string
+	for {
+		token, err := lexer.NextToken()
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		if token.Type == core.TokenEOF {
+			break
+		}
+		kinds = append(kinds, token.Type.String())
+		texts = append(texts, source[token.Position.Offset:token.End])
+	}
+
+	expectedKinds := []string{
+		"left-paren", "symbol", "whitespace", "symbol", "whitespace", "string", "right-paren",
+		"whitespace", "comment", "whitespace", "deref", "symbol", "whitespace", "error", "whitespace", "error",
+	}
+	if strings.Join(kinds, " ") != strings.Join(expectedKinds, " ") {
+		t.Errorf("Expected kinds %v, got %v", expectedKinds, kinds)
+	}
+	if got := strings.Join(texts, ""); got != source {
+		t.Errorf("Expected the tokens to cover the source, got %q", got)
+	}
+	if len(texts) > 8 && texts[8] != "; note" {
+		t.Errorf("Expected the comment token to be %q, got %q", "; note", texts[8])
+	}
+
+	expectedErrs := []string{"unexpected character", "unterminated string"}
+	if len(errs) != len(expectedErrs) {
+		t.Fatalf("Expected errors %v, got %v", expectedErrs, errs)
+	}
+	for i, want := range expectedErrs {
+		if !strings.Contains(errs[i], want) {
+			t.Errorf("Expected error containing %q, got %q", want, errs[i])
+		}
+	}
+}
+
+func TestBarSymbols(t *testing.T) {
+	value, err := core.ReadString(`(|two words| |a\|b| plain)`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	list := value.(*core.List)
+	if list.First() != core.Symbol("two words") {
+		t.Errorf("Expected the symbol two words, got %#v", list.First())
+	}
+	if got := value.String(); got != `(|two words| |a\|b| plain)` {
+		t.Errorf("Expected the list to print as read, got %s", got)
+	}
+
+	names := []string{"", "12", "-1", "a b", ":x", "a;b", "(", `back\slash`, "x#", "log/info", "->", "nil", "é"}
+	for _, name := range names {
+		printed := core.Symbol(name).String()
+		read, err := core.ReadString(printed)
+		if err != nil {
+			t.Errorf("%q printed as %s, which does not read: %v", name, printed, err)
+			continue
+		}
+		if read != core.Symbol(name) {
+			t.Errorf("%q printed as %s, which reads as %#v", name, printed, read)
+		}
+	}
+	for _, name := range []string{"x#", "log/info", "->", "nil"} {
+		if printed := core.Symbol(name).String(); printed != name {
+			t.Errorf("Expected %s to print without bars, got %s", name, printed)
+		}
+	}
+
+	if _, err := core.ReadString("|open"); err == nil || !strings.Contains(err.Error(), "unterminated |symbol|") {
+		t.Errorf("Expected an unterminated symbol error, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"errors"
+	"unicode"
+)
+
+// Token types that only NextToken returns, for tooling that needs every
+// byte of the source, like syntax highlighting
+const (
+	TokenWhitespace TokenType = iota + TokenEOF + 1
+	TokenComment
+	TokenError // Text the lexer could not read, returned with a SyntaxError
+)
+
+var tokenTypeNames = map[TokenType]string{
+	TokenSymbol:          "symbol",
+	TokenNumber:          "number",
+	TokenString:          "string",
+	TokenKeyword:         "keyword",
+	TokenLeftParen:       "left-paren",
+	TokenRightParen:      "right-paren",
+	TokenLeftBracket:     "left-bracket",
+	TokenRightBracket:    "right-bracket",
+	TokenLeftBrace:       "left-brace",
+	TokenRightBrace:      "right-brace",
+	TokenHash:            "hash",
+	TokenQuote:           "quote",
+	TokenQuasiquote:      "quasiquote",
+	TokenUnquote:         "unquote",
+	TokenUnquoteSplicing: "unquote-splicing",
+	TokenCaret:           "caret",
+	TokenDeref:           "deref",
+	TokenBarSymbol:       "bar-symbol",
+	TokenEOF:             "eof",
+	TokenWhitespace:      "whitespace",
+	TokenComment:         "comment",
+	TokenError:           "error",
+}
+
+// String returns the name of the token type, like "left-paren"
+func (t TokenType) String() string {
+	if name, ok := tokenTypeNames[t]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// NextToken returns the next token of the input, ending with TokenEOF.
+// Unlike Tokenize it covers the whole source: whitespace and comments are
+// TokenWhitespace and TokenComment tokens, and text that cannot be read is
+// a TokenError token returned with a *SyntaxError, after which scanning
+// goes on. The source text of any token is input[Position.Offset:End].
+func (l *Lexer) NextToken() (Token, error) {
+	start := l.position
+	pos := l.currentPosition()
+
+	var token Token
+	switch {
+	case l.position >= len(l.input):
+		return Token{Type: TokenEOF, Position: pos, End: l.offset + l.position}, nil
+	case unicode.IsSpace(l.current()):
+		for l.position < len(l.input) && unicode.IsSpace(l.current()) {
+			l.advance()
+		}
+		token = Token{Type: TokenWhitespace, Value: l.input[start:l.position], Position: pos}
+	case l.current() == ';':
+		l.skipComment()
+		token = Token{Type: TokenComment, Value: l.input[start:l.position], Position: pos}
+	default:
+		var err error
+		if token, err = l.nextToken(); err != nil {
+			// An unterminated string or symbol runs to the end of the
+			// input; any other error skips the character
+			message := err.Error()
+			switch {
+			case errors.Is(err, errUnterminatedString):
+				message = errUnterminatedString.Error()
+			case errors.Is(err, errUnterminatedSymbol):
+				message = errUnterminatedSymbol.Error()
+			default:
+				l.advance()
+			}
+			token = Token{Type: TokenError, Value: l.input[start:l.position], Position: pos, End: l.offset + l.position}
+			return token, &SyntaxError{
+				LispError: NewLispError(ParseError, message).WithPosition(pos),
+				Span:      SourceSpan{Start: pos, End: l.currentPosition()},
+			}
+		}
+	}
+	token.End = l.offset + l.position
+	return token, nil
+}
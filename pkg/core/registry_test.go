@@ -0,0 +1,69 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFunctionRegistry(t *testing.T) {
+	RegisterPlugin("test-greeter", constantPlugin("test-greeter", "greet", String("hi")))
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(load-plugin "test-greeter")
+		(defn shout "Upper-cases nothing, loudly." [s & more] s)
+		(def count-copy count)`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(function-category "str")`, `"strings"`},
+		{`(function-category 'atom)`, `"atoms"`},
+		{`(function-category "greet")`, `"test-greeter"`},
+		{`(function-category "shout")`, `"user"`},
+		{`(function-category "no-such-fn")`, "nil"},
+		{`(function-help "str")`, `{:name "str" :category "strings" :kind :builtin}`},
+		{`(function-help "shout")`, `{:name "shout" :category "user" :kind :function :doc "Upper-cases nothing, loudly." :arglists [[s & more]]}`},
+		{`(function-help "greet")`, `{:name "greet" :category "test-greeter" :plugin "test-greeter" :kind :builtin}`},
+		{`(registered-functions "atoms")`, `["atom" "reset!" "swap!"]`},
+		{`(registered-functions "test-greeter")`, `["greet"]`},
+		{`(:functions (plugin-info "test-greeter"))`, `["greet"]`},
+		{`(:version (plugin-info "test-greeter"))`, `"1.0.0"`},
+		{`(plugin-info "atoms")`, `{:name "atoms" :builtin true :functions ["atom" "reset!" "swap!"]}`},
+		{`(plugin-info "nothing")`, "nil"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// Redefining a builtin makes it a user function
+	evalAll(t, env, "(defn str [& xs] \"\")")
+	if result := evalAll(t, env, `(function-category "str")`); result.String() != `"user"` {
+		t.Errorf("Expected a redefined builtin to be categorized as user, got %s", result)
+	}
+
+	categories := evalAll(t, env, "(function-categories)").String()
+	for _, category := range []string{`"arithmetic"`, `"collections"`, `"registry"`, `"test-greeter"`, `"user"`} {
+		if !strings.Contains(categories, category) {
+			t.Errorf("Expected function-categories to include %s, got %s", category, categories)
+		}
+	}
+}
+
+func TestStandardLibraryCategory(t *testing.T) {
+	env, err := CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for input, expected := range map[string]string{
+		`(function-category "map")`:    `"stdlib"`,
+		`(function-category "apply")`:  `"functional"`,
+		`(:kind (function-help "->"))`: ":macro",
+	} {
+		if result := evalAll(t, env, input); result.String() != expected {
+			t.Errorf("For '%s', expected %s, got %s", input, expected, result)
+		}
+	}
+}
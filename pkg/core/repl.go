@@ -1,10 +1,13 @@
 package core
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/chzyer/readline"
 )
@@ -17,8 +20,9 @@ func (r *REPL) createDynamicCompleter() readline.AutoCompleter {
 	// Static special forms that always need parentheses
 	specialForms := []string{
 		"def", "defn", "if", "fn", "let", "do", "loop", "recur",
-		"when", "unless", "cond", "quote", "quasiquote", "unquote",
-		"unquote-splicing", "defmacro", "macroexpand",
+		"for", "doseq", "dotimes", "while", "delay", "var", "dosync", "generator",
+		"when", "unless", "cond", "case", "quote", "quasiquote", "unquote",
+		"unquote-splicing", "defmacro", "macroexpand", "module", "import",
 	}
 	
 	// Static literals that don't need parentheses
@@ -195,9 +199,24 @@ func hasNonWhitespaceContent(input string) bool {
 
 // REPL represents a Read-Eval-Print-Loop
 type REPL struct {
-	env *Environment
-	ctx *EvaluationContext
-	rl  *readline.Instance
+	env        *Environment
+	ctx        *EvaluationContext
+	rl         *readline.Instance
+	session    *Session
+	transcript *transcript // Written to by start-transcript, if running
+	mu         sync.Mutex  // Serializes evaluation with background reloads
+	quiet      bool        // Suppress the banner and result echo
+	progress   func(LoadProgress)
+}
+
+// LoadProgress reports how far EvalFile has got through a file: Done of
+// its Total top-level forms have been evaluated and Form, read from File,
+// is evaluated next. The last report has Done equal to Total and no Form.
+type LoadProgress struct {
+	File  string
+	Done  int
+	Total int
+	Form  Value
 }
 
 // NewREPL creates a new REPL with bootstrapped environment
@@ -207,37 +226,64 @@ func NewREPL() (*REPL, error) {
 		return nil, err
 	}
 
-	// Create REPL instance first (we need it to create the dynamic completer)
 	repl := &REPL{
-		env: env,
-		ctx: NewEvaluationContext(),
+		env:     env,
+		ctx:     NewEvaluationContext(),
+		session: NewSession(),
+	}
+	repl.setupSessionOperations()
+	repl.setupTranscriptOperations()
+	for _, name := range []string{"*1", "*2", "*3", "*e"} {
+		env.Set(Intern(name), Nil{})
 	}
+	return repl, nil
+}
 
+// readInteractiveLine reads one line through readline with a temporary prompt
+func (r *REPL) readInteractiveLine(prompt string) (string, error) {
+	previous := r.rl.Config.Prompt
+	r.rl.SetPrompt(prompt)
+	defer r.rl.SetPrompt(previous)
+	return r.rl.Readline()
+}
+
+// Run starts the REPL. The terminal is only set up here, so that REPLs
+// embedded to evaluate code never touch it.
+func (r *REPL) Run() error {
 	// Configure readline with history and completion
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:          "GoLisp> ",
-		AutoComplete:    repl.createDynamicCompleter(),
+		AutoComplete:    r.createDynamicCompleter(),
 		InterruptPrompt: "^C",
 		EOFPrompt:       "exit",
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create readline: %v", err)
+		return fmt.Errorf("failed to create readline: %v", err)
 	}
-
-	// Set the readline instance on the REPL
-	repl.rl = rl
-	
-	return repl, nil
-}
-
-// Run starts the REPL
-func (r *REPL) Run() error {
+	r.rl = rl
 	defer r.rl.Close()
 
-	fmt.Println("GoLisp Enhanced REPL")
-	fmt.Println("Type 'exit' or 'quit' to quit")
-	fmt.Println("Multi-line expressions supported - press Enter on incomplete expressions")
-	fmt.Println("Type ')' on empty line during multi-line input to force evaluation")
+	// Route interactive builtins (like inspect) through readline
+	state := r.env.interpreterState()
+	state.mu.Lock()
+	state.readLine = r.readInteractiveLine
+	state.mu.Unlock()
+	defer func() {
+		state.mu.Lock()
+		state.readLine = nil
+		state.mu.Unlock()
+	}()
+
+	defer r.stopTranscript()
+
+	if !r.quiet {
+		fmt.Println("GoLisp Enhanced REPL")
+		fmt.Println("Type 'exit' or 'quit' to quit")
+		fmt.Println("Multi-line expressions supported - press Enter on incomplete expressions")
+		fmt.Println("Type ')' on empty line during multi-line input to force evaluation")
+	}
+
+	r.setPrintLimitDefaults()
 
 	var inputBuffer strings.Builder
 	isMultiLine := false
@@ -346,7 +392,7 @@ func (r *REPL) Run() error {
 					if err != nil {
 						fmt.Printf("Error: %v\n", err)
 					} else {
-						fmt.Printf("%s\n", result.String())
+						r.printResult(result)
 						r.updateCompleter()
 					}
 				}
@@ -385,7 +431,7 @@ func (r *REPL) Run() error {
 			if err != nil {
 				fmt.Printf("Error: %v\n", err)
 			} else {
-				fmt.Printf("%s\n", result.String())
+				r.printResult(result)
 				// Update completer after successful evaluation
 				r.updateCompleter()
 			}
@@ -406,57 +452,212 @@ func (r *REPL) Run() error {
 	return nil
 }
 
+// setPrintLimitDefaults keeps an accidental (range 1e7) or deeply nested
+// value from flooding the terminal, unless *print-length* or *print-level*
+// were already set, e.g. by a loaded file
+func (r *REPL) setPrintLimitDefaults() {
+	for name, limit := range map[string]int64{"*print-length*": 100, "*print-level*": 10} {
+		current, err := r.env.Get(Intern(name))
+		if _, unset := current.(Nil); err != nil || unset {
+			r.env.Set(Intern(name), NewNumber(limit))
+		}
+	}
+}
+
+// SetQuiet suppresses the startup banner and result echo
+func (r *REPL) SetQuiet(quiet bool) {
+	r.quiet = quiet
+}
+
+// SetProgress has EvalFile call report before each top-level form of the
+// file, and once more when it is done, for example to show that loading a
+// large file is still going. A nil report turns this off.
+func (r *REPL) SetProgress(report func(LoadProgress)) {
+	r.progress = report
+}
+
+// printResult echoes an evaluation result unless the REPL is quiet,
+// reporting the error instead if a lazy sequence in it fails to realize
+func (r *REPL) printResult(result Value) {
+	if r.quiet {
+		return
+	}
+	printed, err := PrintString(result, r.env)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("%s\n", printed)
+}
+
 // Eval evaluates a string expression
 func (r *REPL) Eval(input string) (Value, error) {
-	// Parse the input
-	lexer := NewLexer(input)
-	tokens, err := lexer.Tokenize()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result, err := r.eval(input)
 	if err != nil {
+		r.env.Set(Intern("*e"), errorValue(err))
 		return nil, err
 	}
+	r.pushHistory(result)
+	return result, nil
+}
 
-	parser := NewParser(tokens)
-	expr, err := parser.Parse()
+// eval parses and evaluates input, recording it in the session
+func (r *REPL) eval(input string) (Value, error) {
+	// Parse the input
+	expr, _, err := r.env.NewReader(strings.NewReader(input)).Next()
+	if err == io.EOF {
+		return nil, NewLispError(ParseError, "unexpected end of input")
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	// Evaluate the expression with context
-	return EvalWithContext(expr, r.env, r.ctx)
+	result, err := EvalWithContext(expr, r.env, r.ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember definitions so the session can be saved
+	r.session.Record(expr)
+	r.recordTranscript(input, expr, result)
+	return result, nil
+}
+
+// pushHistory binds result to *1, moving the previous results to *2 and *3
+func (r *REPL) pushHistory(result Value) {
+	for i := 3; i > 1; i-- {
+		previous, err := r.env.Get(Intern(fmt.Sprintf("*%d", i-1)))
+		if err != nil {
+			previous = Nil{}
+		}
+		r.env.Set(Intern(fmt.Sprintf("*%d", i)), previous)
+	}
+	r.env.Set(Intern("*1"), result)
+}
+
+// errorValue describes an error as a map with :type, :message and, when
+// there is one, the :trace of stack frames, for binding to *e
+func errorValue(err error) Value {
+	lispErr, ok := err.(*LispError)
+	if !ok {
+		return NewHashMapWithPairs(
+			InternKeyword("type"), InternKeyword(RuntimeError.String()),
+			InternKeyword("message"), String(err.Error()),
+		)
+	}
+	result := NewHashMapWithPairs(
+		InternKeyword("type"), InternKeyword(lispErr.Type.String()),
+		InternKeyword("message"), String(lispErr.Message),
+	)
+	if len(lispErr.StackTrace) > 0 {
+		frames := make([]Value, len(lispErr.StackTrace))
+		for i, frame := range lispErr.StackTrace {
+			frames[i] = String(strings.TrimSpace(frame.String()))
+		}
+		result.Set(InternKeyword("trace"), NewVector(frames...))
+	}
+	return result
+}
+
+// WatchNamespaces polls required namespaces and reloads any whose file
+// changed, reporting each reload to out. Call the returned func to stop.
+func (r *REPL) WatchNamespaces(interval time.Duration, out io.Writer) func() {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.ReloadChangedNamespaces(out)
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// ReloadChangedNamespaces re-evaluates namespaces whose files changed on disk
+func (r *REPL) ReloadChangedNamespaces(out io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, ns := range ChangedNamespaces(r.env) {
+		if err := ReloadNamespace(ns, r.env); err != nil {
+			fmt.Fprintf(out, "\nError reloading %s: %v\n", ns, err)
+			continue
+		}
+		fmt.Fprintf(out, "\nReloaded %s\n", ns)
+	}
 }
 
 // LoadFile loads and evaluates a Lisp file
 func (r *REPL) LoadFile(filename string) error {
+	_, err := r.EvalFile(filename)
+	return err
+}
+
+// EvalFile loads and evaluates a Lisp file, returning the last value. Unlike
+// load-file it always evaluates the file, but a file that loads itself, directly
+// or through others, fails with the chain of files instead of recursing.
+func (r *REPL) EvalFile(filename string) (Value, error) {
+	return trackLoad(filename, r.env, func() (Value, error) {
+		return r.evalFile(filename)
+	})
+}
+
+func (r *REPL) evalFile(filename string) (Value, error) {
 	content, err := os.ReadFile(filename)
 	if err != nil {
-		return fmt.Errorf("failed to read file %s: %v", filename, err)
+		return nil, fmt.Errorf("failed to read file %s: %v", filename, err)
 	}
 
 	// Parse the file content
-	lexer := NewLexer(string(content))
-	tokens, err := lexer.Tokenize()
+	reader := r.env.NewReader(bytes.NewReader(content))
+	reader.SetFile(filename)
+	coverage := r.env.coverage()
+	spans := coverage.track(reader)
+	expressions, err := reader.ReadAll()
 	if err != nil {
-		return fmt.Errorf("failed to tokenize file %s: %v", filename, err)
-	}
-
-	parser := NewParser(tokens)
-	expressions, err := parser.ParseAll()
-	if err != nil {
-		return fmt.Errorf("failed to parse file %s: %v", filename, err)
+		return nil, fmt.Errorf("failed to parse file %s: %v", filename, err)
 	}
+	coverage.addFile(filename, string(content), spans)
 
 	// Set the file context for better error reporting
 	r.ctx.Position.File = filename
 
 	// Evaluate each expression
-	for _, expr := range expressions {
-		_, err := EvalWithContext(expr, r.env, r.ctx)
+	var result Value = Nil{}
+	for i, expr := range expressions {
+		if r.progress != nil {
+			r.progress(LoadProgress{File: filename, Done: i, Total: len(expressions), Form: expr})
+		}
+		result, err = EvalWithContext(expr, r.env, r.ctx)
 		if err != nil {
-			return fmt.Errorf("failed to evaluate expression in file %s: %v", filename, err)
+			return nil, fmt.Errorf("failed to evaluate expression in file %s: %v", filename, err)
 		}
 	}
+	if r.progress != nil {
+		r.progress(LoadProgress{File: filename, Done: len(expressions), Total: len(expressions)})
+	}
 
-	return nil
+	return result, nil
+}
+
+// SetCommandLineArgs binds *command-line-args* to the given script arguments
+func (r *REPL) SetCommandLineArgs(args []string) {
+	values := make([]Value, len(args))
+	for i, arg := range args {
+		values[i] = String(arg)
+	}
+	r.env.Set(Intern("*command-line-args*"), NewVector(values...))
 }
 
 // EvalString evaluates a string and returns the result
@@ -1,6 +1,10 @@
 package core
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -205,7 +209,6 @@ func TestREPLCreationAndEnvironment(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create REPL: %v", err)
 	}
-	defer repl.rl.Close() // Clean up readline instance
 
 	// Test that environment is accessible
 	env := repl.GetEnv()
@@ -239,7 +242,6 @@ func TestREPLEvaluation(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to create REPL: %v", err)
 	}
-	defer repl.rl.Close()
 
 	tests := []struct {
 		name     string
@@ -264,4 +266,201 @@ func TestREPLEvaluation(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+// Test saving and restoring REPL sessions
+func TestREPLSessionSaveRestore(t *testing.T) {
+	repl, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+
+	sessionFile := filepath.Join(t.TempDir(), "session.lisp")
+
+	inputs := []string{
+		"(def rate 2)",
+		"(defn scale [x] (* x rate))",
+		"(+ 1 2)",
+		"(def rate 3)",
+		"(do (def greeting \"hi\") (defmacro twice [x] (list 'do x x)))",
+	}
+	for _, input := range inputs {
+		if _, err := repl.Eval(input); err != nil {
+			t.Fatalf("REPL.Eval(%q) failed: %v", input, err)
+		}
+	}
+
+	count, err := repl.Eval(fmt.Sprintf("(save-session %q)", sessionFile))
+	if err != nil {
+		t.Fatalf("save-session failed: %v", err)
+	}
+	if count.String() != "4" {
+		t.Errorf("Expected 4 saved definitions, got %s", count)
+	}
+
+	restored, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+
+	if err := restored.LoadSession(sessionFile); err != nil {
+		t.Fatalf("LoadSession failed: %v", err)
+	}
+
+	result, err := restored.Eval("(str greeting (scale 5))")
+	if err != nil {
+		t.Fatalf("Evaluating restored definitions failed: %v", err)
+	}
+	if result.String() != "\"hi15\"" {
+		t.Errorf("Expected \"hi15\", got %s", result)
+	}
+}
+
+// Test recording a REPL session as a runnable transcript
+func TestREPLTranscript(t *testing.T) {
+	repl, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+
+	transcriptFile := filepath.Join(t.TempDir(), "transcript.lisp")
+	inputs := []string{
+		"(+ 1 1)",
+		fmt.Sprintf("(start-transcript %q)", transcriptFile),
+		"(def rate 2)",
+		"  (* rate 21)\n",
+		"(undefined-fn 1)",
+		"(list 1 \"two\" :three)",
+	}
+	for _, input := range inputs {
+		repl.Eval(input)
+	}
+	count, err := repl.Eval("(stop-transcript)")
+	if err != nil {
+		t.Fatalf("stop-transcript failed: %v", err)
+	}
+	if count.String() != "3" {
+		t.Errorf("Expected 3 recorded forms, got %s", count)
+	}
+	repl.Eval("(def after 1)")
+
+	content, err := os.ReadFile(transcriptFile)
+	if err != nil {
+		t.Fatalf("Failed to read transcript: %v", err)
+	}
+	expected := `;; GoLisp REPL transcript
+
+(def rate 2)
+;; => rate
+
+(* rate 21)
+;; => 42
+
+(list 1 "two" :three)
+;; => (1 "two" :three)
+`
+	if string(content) != expected {
+		t.Errorf("Unexpected transcript:\n%s", content)
+	}
+
+	// The transcript replays as a script
+	replayed, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+	if err := replayed.LoadFile(transcriptFile); err != nil {
+		t.Fatalf("Replaying the transcript failed: %v", err)
+	}
+	if result, _ := replayed.Eval("rate"); result == nil || result.String() != "2" {
+		t.Errorf("Expected rate to be defined by the replay, got %v", result)
+	}
+
+	if result, _ := repl.Eval("(stop-transcript)"); result.String() != "nil" {
+		t.Errorf("Expected nil when no transcript is running, got %s", result)
+	}
+}
+
+// Test the *1, *2, *3 and *e history bindings
+func TestREPLHistory(t *testing.T) {
+	repl, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"*1", "nil"},
+		{"*e", "nil"},
+		{"(+ 1 2)", "3"},
+		{"(* 2 5)", "10"},
+		{"(list 1 2)", "(1 2)"},
+		{"(list *1 *2 *3)", "((1 2) 10 3)"},
+		{"(first *1)", "(1 2)"},
+	}
+	for _, test := range tests {
+		result, err := repl.Eval(test.input)
+		if err != nil {
+			t.Fatalf("REPL.Eval(%q) failed: %v", test.input, err)
+		}
+		if result.String() != test.expected {
+			t.Errorf("For %s, expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// Errors leave the results alone and are kept in *e
+	if _, err := repl.Eval("(+ 1 \"a\")"); err == nil {
+		t.Fatal("Expected an error")
+	}
+	result, err := repl.Eval("(list (:type *e) (string? (:message *e)) *1)")
+	if err != nil {
+		t.Fatalf("Reading *e failed: %v", err)
+	}
+	if result.String() != "(:TypeError true (1 2))" {
+		t.Errorf("Unexpected error details %s", result)
+	}
+	if _, err := repl.Eval("(+ 1"); err == nil {
+		t.Fatal("Expected a parse error")
+	}
+	if result, _ := repl.Eval("(:message *e)"); result == nil || !strings.Contains(result.String(), "unexpected") {
+		t.Errorf("Expected *e to hold the parse error, got %v", result)
+	}
+}
+
+func TestEvalFileProgress(t *testing.T) {
+	repl, err := NewREPL()
+	if err != nil {
+		t.Fatalf("Failed to create REPL: %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "data.lisp")
+	if err := os.WriteFile(file, []byte("(def a 1)\n\n(def b 2)\n(+ a b)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var reports []string
+	repl.SetProgress(func(p LoadProgress) {
+		form := "-"
+		if p.Form != nil {
+			form = fmt.Sprintf("%s@%d", p.Form, p.Form.(*List).GetPosition().Line)
+		}
+		reports = append(reports, fmt.Sprintf("%d/%d %s", p.Done, p.Total, form))
+	})
+	result, err := repl.EvalFile(file)
+	if err != nil {
+		t.Fatalf("EvalFile failed: %v", err)
+	}
+	if result.String() != "3" {
+		t.Errorf("Expected 3, got %s", result)
+	}
+	expected := "0/3 (def a 1)@1, 1/3 (def b 2)@3, 2/3 (+ a b)@4, 3/3 -"
+	if got := strings.Join(reports, ", "); got != expected {
+		t.Errorf("Expected progress %s, got %s", expected, got)
+	}
+
+	// Without a callback nothing is reported
+	repl.SetProgress(nil)
+	reports = nil
+	if _, err := repl.EvalFile(file); err != nil || len(reports) != 0 {
+		t.Errorf("Expected no reports, got %v (%v)", reports, err)
+	}
+}
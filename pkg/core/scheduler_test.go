@@ -0,0 +1,152 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	from := time.Date(2024, 1, 31, 10, 7, 30, 0, time.UTC) // A Wednesday
+
+	tests := []struct {
+		expr     string
+		expected string
+	}{
+		{"* * * * *", "2024-01-31 10:08"},
+		{"*/5 * * * *", "2024-01-31 10:10"},
+		{"0 12 * * MON", "2024-02-05 12:00"},
+		{"30 9-17/4 * * *", "2024-01-31 13:30"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 0 1,15 * 0", "2024-02-01 00:00"}, // Day of month or Sunday
+		{"0 0 * * 7", "2024-02-04 00:00"},
+		{"@daily", "2024-02-01 00:00"},
+		{"@hourly", "2024-01-31 11:00"},
+		{"0 0 30 2 *", "none"},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseCron(test.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q) failed: %v", test.expr, err)
+		}
+		next := schedule.next(from)
+		got := "none"
+		if !next.IsZero() {
+			got = next.Format("2006-01-02 15:04")
+		}
+		if got != test.expected {
+			t.Errorf("Expected %q after %v to run at %s, got %s", test.expr, from, test.expected, got)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * * FOO *", "*/0 * * * *", "5-1 * * * *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("Expected parseCron(%q) to fail", expr)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+	env := NewCoreEnvironment()
+	env.Set(Intern("explode"), &BuiltinFunction{
+		Name: "explode",
+		Fn:   func(args []Value, env *Environment) (Value, error) { panic("boom") },
+	})
+	evalAll(t, env, `
+		(def ticks (atom 0))
+		(def errors (atom (list)))
+		(def ticker (every-ms 5 (fn [] (swap! ticks (fn [n] (+ n 1))))))
+		(def failing (every-ms 5 explode :on-error (fn [e] (swap! errors (fn [es] (cons e es))))))`)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if evalAll(t, env, "(and (> @ticks 2) (> (count @errors) 1))").String() == "true" {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	evalAll(t, env, "(cancel-job ticker) (cancel-job failing)")
+
+	if result := evalAll(t, env, "@ticks"); result.(Number).ToInt() < 3 {
+		t.Errorf("Expected the job to run repeatedly, got %s runs", result)
+	}
+	if result := evalAll(t, env, "(first @errors)"); !strings.Contains(result.String(), "panic: boom") {
+		t.Errorf("Expected the panic to reach the error handler, got %s", result)
+	}
+	if result := evalAll(t, env, "(list (job-active? ticker) ticker)"); result.String() != "(nil #<job every 5ms cancelled>)" {
+		t.Errorf("Unexpected job state: %s", result)
+	}
+
+	// Cancelled jobs stop running once a run in progress has finished
+	time.Sleep(10 * time.Millisecond)
+	stopped := evalAll(t, env, "@ticks").String()
+	time.Sleep(30 * time.Millisecond)
+	if result := evalAll(t, env, "@ticks"); result.String() != stopped {
+		t.Errorf("Expected no runs after cancel-job, went from %s to %s", stopped, result)
+	}
+
+	// With every job cancelled, wait-jobs returns at once
+	evalAll(t, env, "(wait-jobs)")
+
+	for input, message := range map[string]string{
+		`(schedule "* * *" (fn [] 1))`:       "must have 5 fields",
+		`(schedule "* * * * *" 1)`:           "expects a function",
+		`(every-ms 0 (fn [] 1))`:             "positive interval",
+		`(every-ms 10 (fn [] 1) :on-fail 1)`: "expects :on-error handler",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+
+	job := evalAll(t, env, `(schedule "0 0 1 1 *" (fn [] 1))`)
+	if job.String() != `#<job "0 0 1 1 *" active>` {
+		t.Errorf("Unexpected job: %s", job)
+	}
+	job.(*Job).Cancel()
+}
+
+func TestCronPlanning(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def noon-mondays (parse-cron "0 12 * * MON"))`)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`noon-mondays`, `#<cron "0 12 * * MON">`},
+		{`(next-run noon-mondays #inst "2024-01-31T10:07:30Z")`, `#inst "2024-02-05T12:00:00Z"`},
+		{`(next-run "@daily" #inst "2024-01-31T10:07:30Z")`, `#inst "2024-02-01T00:00:00Z"`},
+		{`(next-run "0 0 30 2 *" #inst "2024-01-01T00:00:00Z")`, `nil`},
+		{`(inst? (next-run noon-mondays))`, `true`},
+		{`(runs-between noon-mondays #inst "2024-01-29T12:00:00Z" #inst "2024-02-12T12:00:00Z")`,
+			`[#inst "2024-01-29T12:00:00Z" #inst "2024-02-05T12:00:00Z"]`},
+		{`(count (runs-between "*/15 * * * *" #inst "2024-01-01T00:00:00Z" (+ #inst "2024-01-01T00:00:00Z" (hours 2))))`, `8`},
+		{`(runs-between "0 0 1 1 *" #inst "2024-02-01T00:00:00Z" #inst "2024-03-01T00:00:00Z")`, `[]`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	job := evalAll(t, env, `(schedule noon-mondays (fn [] 1))`)
+	if job.String() != `#<job "0 12 * * MON" active>` {
+		t.Errorf("Unexpected job: %s", job)
+	}
+	job.(*Job).Cancel()
+
+	for input, message := range map[string]string{
+		`(parse-cron "61 * * * *")`:                            "invalid minute",
+		`(parse-cron 5)`:                                       "expects a cron expression string",
+		`(next-run noon-mondays 5)`:                            "expects an instant",
+		`(runs-between "* * * * *" (now) 5)`:                   "expects two instants",
+		`(runs-between "* * * * *" #inst "2000" #inst "2001")`: "more than 100000 runs",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Session records the source forms of top-level definitions made in a REPL
+// so they can be written out and replayed later
+type Session struct {
+	forms []Value
+	index map[Symbol]int // Latest form position for each defined name
+}
+
+// NewSession creates an empty session log
+func NewSession() *Session {
+	return &Session{index: make(map[Symbol]int)}
+}
+
+// Record stores expr if it is a top-level definition, replacing any earlier
+// definition of the same name
+func (s *Session) Record(expr Value) {
+	list, ok := expr.(*List)
+	if !ok || list.IsEmpty() {
+		return
+	}
+
+	head, ok := list.First().(Symbol)
+	if !ok {
+		return
+	}
+
+	switch head {
+	case "do":
+		for _, form := range listToSlice(list.Rest()) {
+			s.Record(form)
+		}
+	case "def", "defn", "defmacro":
+		name, ok := list.Rest().First().(Symbol)
+		if !ok {
+			return
+		}
+		if i, exists := s.index[name]; exists {
+			s.forms[i] = nil
+		}
+		s.index[name] = len(s.forms)
+		s.forms = append(s.forms, expr)
+	}
+}
+
+// Forms returns the recorded definitions in evaluation order
+func (s *Session) Forms() []Value {
+	var forms []Value
+	for _, form := range s.forms {
+		if form != nil {
+			forms = append(forms, form)
+		}
+	}
+	return forms
+}
+
+// Save writes the recorded definitions to filename as Lisp source
+func (s *Session) Save(filename string) error {
+	var out strings.Builder
+	out.WriteString(";; GoLisp REPL session\n")
+	for _, form := range s.Forms() {
+		out.WriteString(form.String())
+		out.WriteString("\n")
+	}
+
+	if err := os.WriteFile(filename, []byte(out.String()), 0644); err != nil {
+		return NewIOError("failed to save session %s: %v", filename, err)
+	}
+	return nil
+}
+
+// SaveSession writes all user definitions made in this REPL to filename
+func (r *REPL) SaveSession(filename string) error {
+	return r.session.Save(filename)
+}
+
+// LoadSession evaluates a saved session file, recording its definitions
+func (r *REPL) LoadSession(filename string) error {
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		return NewIOError("failed to read session %s: %v", filename, err)
+	}
+
+	lexer := NewLexer(string(content))
+	tokens, err := lexer.Tokenize()
+	if err != nil {
+		return fmt.Errorf("failed to tokenize session %s: %v", filename, err)
+	}
+
+	parser := NewParserWithSource(tokens, string(content))
+	parser.SetConfig(r.env.CurrentReaderConfig())
+	expressions, err := parser.ParseAll()
+	if err != nil {
+		return fmt.Errorf("failed to parse session %s: %v", filename, err)
+	}
+
+	for _, expr := range expressions {
+		if _, err := EvalWithContext(expr, r.env, r.ctx); err != nil {
+			return fmt.Errorf("failed to evaluate session %s: %v", filename, err)
+		}
+		r.session.Record(expr)
+	}
+
+	return nil
+}
+
+// setupSessionOperations adds save-session and load-session bound to this REPL
+func (r *REPL) setupSessionOperations() {
+	r.env.Set(Intern("save-session"), &BuiltinFunction{
+		Name: "save-session",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("save-session expects 1 argument, got %d", len(args))
+			}
+
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("save-session expects string filename, got %s", TypeName(args[0]))
+			}
+
+			if err := r.SaveSession(string(filename)); err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(len(r.session.Forms()))), nil
+		},
+	})
+
+	r.env.Set(Intern("load-session"), &BuiltinFunction{
+		Name: "load-session",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("load-session expects 1 argument, got %d", len(args))
+			}
+
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("load-session expects string filename, got %s", TypeName(args[0]))
+			}
+
+			if err := r.LoadSession(string(filename)); err != nil {
+				return nil, err
+			}
+			return Nil{}, nil
+		},
+	})
+
+	guardBuiltins(r.env, []Capability{CapabilityFS}, "save-session", "load-session")
+}
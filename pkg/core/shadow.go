@@ -0,0 +1,119 @@
+package core
+
+import "fmt"
+
+// noShadowWarning is the metadata that silences shadowing warnings for a
+// name, as in (def ^:no-shadow-warning first ...) or (let [^:no-shadow-warning
+// list ...] ...)
+var noShadowWarning = InternKeyword("no-shadow-warning")
+
+// quietNames returns the symbols among elements marked ^:no-shadow-warning,
+// or nil if there are none
+func quietNames(elements []Value) map[Symbol]bool {
+	var quiet map[Symbol]bool
+	for _, element := range elements {
+		m, ok := element.(*readerMeta)
+		if !ok || !isTruthy(m.meta.Get(noShadowWarning)) {
+			continue
+		}
+		if sym, ok := m.target.(Symbol); ok {
+			if quiet == nil {
+				quiet = make(map[Symbol]bool)
+			}
+			quiet[sym] = true
+		}
+	}
+	return quiet
+}
+
+// shadowedKind says what name would hide if bound in env: "special form",
+// "builtin" or nothing
+func shadowedKind(name Symbol, env *Environment) string {
+	if isSpecialForm(name) {
+		return "special form"
+	}
+	if value, err := env.Get(name); err == nil {
+		if _, builtin := value.(*BuiltinFunction); builtin {
+			return "builtin"
+		}
+	}
+	return ""
+}
+
+// checkShadowing warns, once per form, when a def, defn, defmacro, fn, let
+// or loop form binds a name that hides a builtin or special form
+func checkShadowing(form *List, env *Environment) error {
+	head, _ := form.First().(Symbol)
+	args := listToSlice(form.Rest())
+	if len(args) == 0 {
+		return nil
+	}
+
+	type binding struct {
+		name  Value
+		quiet map[Symbol]bool
+	}
+	var names []binding
+	params := func(v Value) {
+		items, _ := collectionToSlice(v)
+		var quiet map[Symbol]bool
+		if vec, ok := v.(*Vector); ok {
+			quiet = vec.quiet
+		}
+		for _, item := range items {
+			if item != Symbol("&") {
+				names = append(names, binding{item, quiet})
+			}
+		}
+	}
+	switch head {
+	case "def":
+		names = append(names, binding{args[0], form.quiet})
+	case "defn", "defmacro":
+		names = append(names, binding{args[0], form.quiet})
+		if _, _, rest := splitDocAndAttrs(args[1:]); len(rest) > 0 {
+			params(rest[0])
+		}
+	case "fn":
+		params(args[0])
+	case "let", "loop":
+		pairs, _ := collectionToSlice(args[0])
+		var quiet map[Symbol]bool
+		if vec, ok := args[0].(*Vector); ok {
+			quiet = vec.quiet
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			names = append(names, binding{pairs[i], quiet})
+		}
+	default:
+		return nil
+	}
+
+	var messages []string
+	for _, b := range names {
+		sym, ok := b.name.(Symbol)
+		if !ok || b.quiet[sym] {
+			continue
+		}
+		if kind := shadowedKind(sym, env); kind != "" {
+			messages = append(messages, fmt.Sprintf("%s shadows the %s %s", sym, kind, sym))
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	warned := &env.interpreterState().shadowWarned
+	if warned.contains(form) {
+		return nil
+	}
+	if !env.warningsAsErrors() && !env.Strict() {
+		warned.add(form)
+	}
+	for _, msg := range messages {
+		if err := warnIn(env, msg, form.GetPosition()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
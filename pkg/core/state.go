@@ -0,0 +1,148 @@
+package core
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// interpreterState is the mutable state of one interpreter other than its
+// bindings. It lives on the root environment, so interpreters embedded side
+// by side number their gensyms, draw random numbers, read code, print, warn
+// and log independently.
+type interpreterState struct {
+	gensyms       gensymCounter
+	shadowWarned  formSet    // Forms already warned about hiding a builtin
+	strictChecked formSet    // Function forms already checked in strict mode
+	mu            sync.Mutex // Guards the fields below
+	rng           *rand.Rand
+	stdout        io.Writer
+	logSinks      []*logSink // Set by log/set-sinks!; nil logs as text to stderr
+	pooled        bool       // Owned by a Pool, whose scripts may not end the process
+	reader        ReaderConfig
+
+	reports          *reporter
+	deprecatedWarned map[Symbol]bool // Deprecated names already warned about
+	exitHooks        []exitHook      // Registered with on-exit
+	exitHooksRan     bool
+	maxCallDepth     int64 // Set by SetMaxCallDepth; 0 is DefaultMaxCallDepth
+	coverage         *Coverage
+	readLine         func(prompt string) (string, error) // Set while a REPL runs
+}
+
+// reporter is where an interpreter's warnings and errors go. Connections
+// hold it rather than the interpreterState, whose exit hooks could keep the
+// connections themselves alive.
+type reporter struct {
+	mu       sync.Mutex
+	out      io.Writer // nil reports to os.Stderr
+	asErrors bool      // Warnings fail instead, as with golisp --werror
+}
+
+// gensymCounter numbers the symbols made by gensym and by x# in syntax-quote
+type gensymCounter struct {
+	last int64
+}
+
+// interpreterState returns the state of env's interpreter
+func (env *Environment) interpreterState() *interpreterState {
+	root := env.root()
+	root.mu.Lock()
+	defer root.mu.Unlock()
+	if root.interp == nil {
+		root.interp = &interpreterState{
+			rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
+			reports: &reporter{},
+		}
+	}
+	return root.interp
+}
+
+// pooled reports whether env belongs to an interpreter of a Pool
+func (env *Environment) pooled() bool {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.pooled
+}
+
+// nextGensym returns the number for a new gensym made in env, from the
+// counter of the innermost with-gensym-seed or else the interpreter's
+func nextGensym(env *Environment) int64 {
+	if env.calls != nil && env.calls.gensyms != nil {
+		return atomic.AddInt64(&env.calls.gensyms.last, 1)
+	}
+	return atomic.AddInt64(&env.interpreterState().gensyms.last, 1)
+}
+
+// ResetGensym starts the interpreter's numbering of gensyms made outside
+// with-gensym-seed over, so that a test harness sees the same
+// macroexpansions on every run
+func (env *Environment) ResetGensym() {
+	atomic.StoreInt64(&env.interpreterState().gensyms.last, 0)
+}
+
+// SeedRandom reseeds the interpreter's random numbers, which seed the
+// generators of gen/sample and check-property, for reproducible runs
+func (env *Environment) SeedRandom(seed int64) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.rng = rand.New(rand.NewSource(seed))
+}
+
+// SetOutput sends what the interpreter prints with print, println, prn and
+// pprint to w instead of os.Stdout
+func (env *Environment) SetOutput(w io.Writer) {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.stdout = w
+}
+
+// SetErrorOutput sends the interpreter's warnings and log records, and the
+// errors of its scheduled jobs, HTTP handlers and exit hooks, to w instead
+// of os.Stderr
+func (env *Environment) SetErrorOutput(w io.Writer) {
+	reports := env.interpreterState().reports
+	reports.mu.Lock()
+	defer reports.mu.Unlock()
+	reports.out = w
+}
+
+// output returns where the interpreter prints
+func (env *Environment) output() io.Writer {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.stdout == nil {
+		return os.Stdout
+	}
+	return state.stdout
+}
+
+// errorOutput returns where the interpreter reports errors
+func (env *Environment) errorOutput() io.Writer {
+	return env.interpreterState().reports.output()
+}
+
+func (r *reporter) output() io.Writer {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.out == nil {
+		return os.Stderr
+	}
+	return r.out
+}
+
+// randomSeed draws a seed for a new generator from the interpreter's random
+// numbers
+func (env *Environment) randomSeed() int64 {
+	state := env.interpreterState()
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.rng.Int63()
+}
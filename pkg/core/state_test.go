@@ -0,0 +1,53 @@
+package core
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestInterpreterState(t *testing.T) {
+	first, second := NewCoreEnvironment(), NewCoreEnvironment()
+
+	// Each interpreter numbers its own gensyms
+	evalAll(t, first, `(gensym)`)
+	if got := evalAll(t, first, `(gensym)`).String(); got != "G__2" {
+		t.Errorf("Expected G__2 from the first interpreter, got %s", got)
+	}
+	if got := evalAll(t, second, `(gensym)`).String(); got != "G__1" {
+		t.Errorf("Expected G__1 from the second interpreter, got %s", got)
+	}
+	first.ResetGensym()
+	if got := evalAll(t, first, `(gensym)`).String(); got != "G__1" {
+		t.Errorf("Expected G__1 after a reset, got %s", got)
+	}
+
+	// Gensyms made concurrently are still unique
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				id := nextGensym(second)
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Duplicate gensym number %d", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The same seed gives the same samples
+	sample := func(env *Environment, seed int64) string {
+		env.SeedRandom(seed)
+		return evalAll(t, env, `(gen/sample (gen/int) 5)`).String()
+	}
+	if a, b := sample(first, 42), sample(second, 42); a != b {
+		t.Errorf("Expected the same samples from the same seed, got %s and %s", a, b)
+	}
+}
@@ -0,0 +1,55 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatistics(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(mean [1 2 3 4])`, `2.5`},
+		{`(mean (list 2.5))`, `2.5`},
+		{`(median [3 1 2])`, `2.0`},
+		{`(median [4 1 3 2])`, `2.5`},
+		{`(mode [1 2 2 3 3])`, `2`},
+		{`(mode [1 2.0 2])`, `2.0`},
+		{`(mode ["a" "b" "b"])`, `"b"`},
+		{`(variance [2 4 4 4 5 5 7 9] :population true)`, `4.0`},
+		{`(stddev [2 4 4 4 5 5 7 9] :population true)`, `2.0`},
+		{`(variance [1 2 3 4])`, `1.6666666666666667`},
+		{`(variance [5])`, `0.0`},
+		{`(percentile [1 2 3 4 5] 90)`, `4.6`},
+		{`(percentile [15 20 35 40 50] 40)`, `29.0`},
+		{`(percentile [5 1] 0)`, `1.0`},
+		{`(percentile [5 1] 100)`, `5.0`},
+		{`(histogram [1 2 2 3 9 10] 3)`, `[{:from 1.0 :to 4.0 :count 4} {:from 4.0 :to 7.0 :count 0} {:from 7.0 :to 10.0 :count 2}]`},
+		{`(histogram [5 5] 2)`, `[{:from 5.0 :to 5.0 :count 2} {:from 5.0 :to 5.0 :count 0}]`},
+		{`(count (histogram [1 2 3]))`, `10`},
+		{`(linear-regression [1 2 3 4] [3 5 7 9])`, `{:slope 2.0 :intercept 1.0 :r2 1.0}`},
+		{`(linear-regression [1 2 3] [1 3 2])`, `{:slope 0.5 :intercept 1.0 :r2 0.25}`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(mean [])`:                       "mean of an empty collection",
+		`(median [1 "2"])`:                `median expects numbers, got "2"`,
+		`(percentile [1] 101)`:            "from 0 to 100",
+		`(stddev [1 2] :sample true)`:     ":population flag",
+		`(histogram [1 2] 0)`:             "positive number of bins",
+		`(linear-regression [1 2] [1])`:   "as many ys as xs",
+		`(linear-regression [1 1] [1 2])`: "two distinct xs",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package core_test
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/leinonen/go-lisp/pkg/core"
@@ -36,6 +38,12 @@ func TestStdlibCoreLibrary(t *testing.T) {
 		// Test map function
 		{"map-simple", "(map (fn [x] (* x 2)) (list 1 2 3))", "(2 4 6)"},
 		{"map-empty", "(map (fn [x] x) nil)", "()"},
+		{"map-two-colls", "(map + (list 1 2 3) (list 10 20 30))", "(11 22 33)"},
+		{"map-shortest", "(map + (list 1 2 3) (list 10 20) (list 100 200 300))", "(111 222)"},
+		{"map-mixed-colls", "(map list [1 2] (list :a :b :c))", "((1 :a) (2 :b))"},
+		{"map-one-empty", "(map + (list 1 2) nil)", "()"},
+		{"map-indexed", "(map-indexed (fn [i x] (list i x)) [:a :b])", "((0 :a) (1 :b))"},
+		{"map-indexed-empty", "(map-indexed list nil)", "()"},
 
 		// Test filter function
 		{"filter-positive", "(filter (fn [x] (> x 0)) (list -1 0 1 2))", "(1 2)"},
@@ -45,6 +53,13 @@ func TestStdlibCoreLibrary(t *testing.T) {
 		{"reduce-sum", "(reduce + 0 (list 1 2 3 4))", "10"},
 		{"reduce-multiply", "(reduce * 1 (list 2 3 4))", "24"},
 		{"reduce-empty", "(reduce + 0 nil)", "0"},
+		{"reduce-reduced", "(reduce (fn [acc x] (if (> acc 100) (reduced acc) (+ acc x))) 0 (list 50 60 70 80))", "110"},
+		{"reduce-reduced-last", "(reduce (fn [acc x] (reduced (+ acc x))) 1 (list 2))", "3"},
+		{"reduced?", "(list (reduced? (reduced 1)) (reduced? 1))", "(true nil)"},
+		{"unreduced", "(list (unreduced (reduced 1)) (unreduced 2) @(reduced 3))", "(1 2 3)"},
+		{"reduce-kv", "(reduce-kv (fn [acc k v] (assoc acc v k)) (hash-map) (hash-map :a 1 :b 2))", "{1 :a 2 :b}"},
+		{"reduce-kv-reduced", "(reduce-kv (fn [acc k v] (if (= k :b) (reduced acc) (+ acc v))) 0 (hash-map :a 1 :b 2 :c 3))", "1"},
+		{"reduce-kv-empty", "(reduce-kv (fn [acc k v] (+ acc v)) 0 (hash-map))", "0"},
 
 		// Test range function (reverse order for simplicity)
 		{"range-5", "(range 5)", "(4 3 2 1 0)"},
@@ -198,6 +213,17 @@ func TestStdlibComplexOperations(t *testing.T) {
 		// Test keep function
 		{"keep", "(keep (fn [x] (if (> x 2) x nil)) (list 1 2 3 4))", "(3 4)"},
 
+		// remove, keep and mapcat apply closures, builtins, keywords and sets
+		{"remove-closure", "(let [limit 2] (remove (fn [x] (> x limit)) (list 1 2 3 4)))", "(1 2)"},
+		{"remove-builtin", "(remove even? (list 1 2 3 4))", "(1 3)"},
+		{"remove-set", "(remove (set 2 3) (list 1 2 3 4))", "(1 4)"},
+		{"keep-builtin", "(keep first (list (list 1) (list) (list 3)))", "(1 3)"},
+		{"keep-keyword", "(keep :a (list (hash-map :a 1) (hash-map :b 2)))", "(1)"},
+		{"mapcat", "(mapcat (fn [x] (list x x)) (list 1 2))", "(1 1 2 2)"},
+		{"mapcat-closure", "(let [n 10] (mapcat (fn [x] (vector x (* x n))) (list 1 2)))", "(1 10 2 20)"},
+		{"mapcat-builtin", "(mapcat rest (list (list 1 2 3) (list 4 5)))", "(2 3 5)"},
+		{"mapcat-empty", "(mapcat list (list))", "()"},
+
 		// Test sort function - disabled due to nil terminator issues in current implementation
 		// {"sort", "(sort (list 3 1 4 2))", "(1 2 3 4 nil)"},
 
@@ -230,6 +256,45 @@ func TestStdlibComplexOperations(t *testing.T) {
 	}
 }
 
+func TestStdlibLongSequences(t *testing.T) {
+	// The sequence functions loop rather than recurse, so sequences longer
+	// than the maximum call depth work
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	n := core.DefaultMaxCallDepth * 2
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(count (range %d))", "20000"},
+		{"(first (range %d))", "19999"},
+		{"(reduce + 0 (range %d))", "199990000"},
+		{"(first (reverse (range %d)))", "0"},
+		{"(count (map inc (range %d)))", "20000"},
+		{"(count (filter even? (range %d)))", "10000"},
+		{"(count (remove even? (range %d)))", "10000"},
+		{"(count (keep (fn [x] (if (even? x) x nil)) (range %d)))", "10000"},
+		{"(count (mapcat (fn [x] (list x x)) (range %d)))", "40000"},
+	}
+	for _, test := range tests {
+		input := strings.ReplaceAll(test.input, "%d", fmt.Sprint(n))
+		expr, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Errorf("Eval error for '%s': %v", input, err)
+			continue
+		}
+		if result.String() != test.expected {
+			t.Errorf("Expected '%s' for input '%s', got '%s'", test.expected, input, result.String())
+		}
+	}
+}
+
 func TestPartialFunction(t *testing.T) {
 	// Create bootstrapped environment with stdlib loaded
 	env, err := core.CreateBootstrappedEnvironment()
@@ -297,6 +362,113 @@ func TestPartialFunction(t *testing.T) {
 	}
 }
 
+func TestThreadingMacros(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"thread-first", "(-> 5 inc (* 2) (- 1))", "11"},
+		{"thread-first-no-forms", "(-> 5)", "5"},
+		{"thread-last", "(->> (list 1 2 3) (map inc) (reduce + 0))", "9"},
+
+		{"cond-thread-first", "(cond-> 1 true inc nil (* 10) (= 1 1) (* 3))", "6"},
+		{"cond-thread-first-none", "(cond-> 1 nil inc)", "1"},
+		{"cond-thread-last", "(cond->> (list 1 2) true (map inc) nil (map inc))", "(2 3)"},
+		{"cond-tests-see-original", "(let [x 1] (cond-> x true inc (= x 1) (* 10)))", "20"},
+
+		{"some-thread-first", "(some-> {:a {:b 2}} (get :a) (get :b) inc)", "3"},
+		{"some-thread-first-nil", "(some-> {:a {:b 2}} (get :x) (get :b) inc)", "nil"},
+		{"some-thread-last", "(some->> (list 1 2) (map inc) first)", "2"},
+		{"some-thread-last-nil", "(some->> nil (map inc))", "nil"},
+
+		{"as-thread", "(as-> 3 x (+ x 1) (list 0 x 9) (count x))", "3"},
+		{"as-thread-no-forms", "(as-> 3 x)", "3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := core.ReadString(test.input)
+			if err != nil {
+				t.Errorf("Parse error for '%s': %v", test.input, err)
+				return
+			}
+
+			result, err := core.Eval(expr, env)
+			if err != nil {
+				t.Errorf("Eval error for '%s': %v", test.input, err)
+				return
+			}
+
+			if result.String() != test.expected {
+				t.Errorf("Expected '%s' for input '%s', got '%s'", test.expected, test.input, result.String())
+			}
+		})
+	}
+
+	expr, _ := core.ReadString("(cond->> 1 true)")
+	if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), "cond->> expects test/form pairs") {
+		t.Errorf("Expected odd clause error, got %v", err)
+	}
+}
+
+func TestDotoAndWithRedefs(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"doto-returns-object", "(deref (doto (atom 0) (swap! inc) (swap! + 10)))", "11"},
+		{"doto-symbol-form", "(deref (doto (atom 3) deref))", "3"},
+		{"define-greet", `(defn greet [n] (str "hi " n))`, "greet"},
+		{"redefs-stub", `(with-redefs [greet (fn [n] "stub")] (greet "a"))`, `"stub"`},
+		{"redefs-restored", `(greet "a")`, `"hi a"`},
+		{"with-timeout-value", "(with-timeout 1000 (+ 1 2))", "3"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expr, err := core.ReadString(test.input)
+			if err != nil {
+				t.Fatalf("Parse error for '%s': %v", test.input, err)
+			}
+			result, err := core.Eval(expr, env)
+			if err != nil {
+				t.Fatalf("Eval error for '%s': %v", test.input, err)
+			}
+			if result.String() != test.expected {
+				t.Errorf("Expected '%s' for input '%s', got '%s'", test.expected, test.input, result.String())
+			}
+		})
+	}
+
+	// The original comes back even when the body fails
+	for input, errorMatch := range map[string]string{
+		`(with-redefs [greet (fn [n] "stub")] (undefined-fn))`: "undefined-fn",
+		`(with-redefs [no-such-fn 1] nil)`:                     "unable to resolve var: no-such-fn",
+		`(with-timeout 50 (loop [] (recur)))`:                  "timed out after 50ms",
+	} {
+		expr, _ := core.ReadString(input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got %v", errorMatch, input, err)
+		}
+	}
+	expr, _ := core.ReadString(`(greet "b")`)
+	if result, err := core.Eval(expr, env); err != nil || result.String() != `"hi b"` {
+		t.Errorf("Expected greet to be restored, got %v, %v", result, err)
+	}
+}
+
 func TestSubsFunction(t *testing.T) {
 	// Create bootstrapped environment with stdlib loaded
 	env, err := core.CreateBootstrappedEnvironment()
@@ -347,6 +519,39 @@ func TestSubsFunction(t *testing.T) {
 	}
 }
 
+func TestFrequenciesAndGroupBy(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(frequencies (list 1 "1" 1 :a "1" 1))`, `{1 3 "1" 2 :a 1}`},
+		{`(frequencies (list (list 1 2) [1 2] (atom 0) (atom 0)))`, `{(1 2) 2 #<atom 0> 1 #<atom 0> 1}`},
+		{`(frequencies [])`, `{}`},
+		{`(group-by even? (list 1 2 3 4))`, `{nil [1 3] true [2 4]}`},
+		{`(group-by count (list "a" "bb" "c"))`, `{1 ["a" "c"] 2 ["bb"]}`},
+		{`(group-by (fn [x] (if (> x 1) "1" 1)) (list 1 2 3))`, `{1 [1] "1" [2 3]}`},
+		{`(get (group-by first (list [:a 1] [:b 2] [:a 3])) :a)`, `[[:a 1] [:a 3]]`},
+	}
+	for _, test := range tests {
+		expr, err := core.ReadString(test.input)
+		if err != nil {
+			t.Fatalf("Parse error for '%s': %v", test.input, err)
+		}
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", test.input, err)
+		}
+		if result.String() != test.expected {
+			t.Errorf("Expected %s for '%s', got %s", test.expected, test.input, result)
+		}
+	}
+}
+
 func TestStdlibErrorHandling(t *testing.T) {
 	// Create bootstrapped environment with stdlib loaded
 	env, err := core.CreateBootstrappedEnvironment()
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRefsAndDosync(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def checking (ref 100))
+		(def savings (ref 0))
+		(defn transfer [from to amount]
+		  (dosync
+		    (alter from - amount)
+		    (alter to + amount)))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(transfer checking savings 30)", "30"},
+		{"(list @checking @savings)", "(70 30)"},
+		{"(dosync (ref-set savings 5) (deref savings))", "5"},
+		{"(dosync (dosync (alter savings + 1)) @savings)", "6"},
+		{"savings", "#<ref 6>"},
+
+		// A generator started in a transaction runs in it on its own goroutine
+		{"(dosync (alter savings + 1) (first (generator (yield @savings))))", "7"},
+		{"(def later (dosync (delay (alter savings + 1)))) @savings", "7"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// A failing transaction leaves every ref untouched
+	expr, _ := ReadString(`(dosync (alter checking - 50) (throw "insufficient funds"))`)
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "insufficient funds") {
+		t.Errorf("Expected transaction error, got %v", err)
+	}
+	if result := evalAll(t, env, "@checking"); result.String() != "70" {
+		t.Errorf("Expected checking to stay 70 after a failed transaction, got %s", result)
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(alter checking str)":        "alter called outside of dosync",
+		"(ref-set checking 1)":        "ref-set called outside of dosync",
+		"(force later)":               "alter called outside of dosync",
+		"(dosync (alter 1 str))":      "alter expects a ref",
+		"(dosync (ref-set checking))": "ref-set expects 2 arguments",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestConcurrentTransfersPreserveTotal(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def a (ref 1000))
+		(def b (ref 1000))
+		(defn transfer [from to amount]
+		  (dosync
+		    (alter from - amount)
+		    (alter to + amount)))`)
+
+	forward, _ := ReadString("(transfer a b 1)")
+	backward, _ := ReadString("(transfer b a 2)")
+	total, _ := ReadString("(dosync (+ @a @b))")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				expr := forward
+				if i%2 == 1 {
+					expr = backward
+				}
+				if _, err := Eval(expr, env); err != nil {
+					t.Error(err)
+					return
+				}
+				if sum, err := Eval(total, env); err != nil || sum.String() != "2000" {
+					t.Errorf("Expected a consistent total of 2000, got %v (%v)", sum, err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if result := evalAll(t, env, "(list @a @b)"); result.String() != "(1400 600)" {
+		t.Errorf("Expected (1400 600), got %s", result)
+	}
+}
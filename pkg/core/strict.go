@@ -0,0 +1,301 @@
+package core
+
+import "fmt"
+
+// Strict mode, turned on with golisp --strict or (set-strict! true), makes
+// an environment refuse code that only fails later in permissive mode:
+// function bodies must only use names that are already defined (or
+// declared), existing definitions can't be silently replaced with def, defn
+// or defmacro, and warnings are errors
+
+// SetStrict turns strict mode on or off for env and everything sharing its
+// root
+func (env *Environment) SetStrict(enabled bool) {
+	env.varRegistry().strict = enabled
+}
+
+// Strict reports whether env is in strict mode
+func (env *Environment) Strict() bool {
+	return env != nil && env.root().vars != nil && env.root().vars.strict
+}
+
+// checkRedefinition refuses to replace the root binding of sym in strict
+// mode, unless sym was only declared
+func (env *Environment) checkRedefinition(sym Symbol) error {
+	if !env.Strict() {
+		return nil
+	}
+	registry := env.varRegistry()
+	if registry.declared[sym] {
+		delete(registry.declared, sym)
+		return nil
+	}
+	return NewRuntimeError("cannot redefine %s in strict mode; use alter-var-root to change it", sym)
+}
+
+// checkStrictFunction verifies, in strict mode, that the body of the
+// function defined by form only refers to its parameters, locals, special
+// forms and names already bound in env. Macro calls are checked by their
+// expansion
+func checkStrictFunction(form *List, name Symbol, params *List, body Value, env *Environment) error {
+	if !env.Strict() {
+		return nil
+	}
+	checked := &env.interpreterState().strictChecked
+	if checked.contains(form) {
+		return nil
+	}
+
+	locals := make(map[Symbol]bool)
+	if name != "" {
+		locals[name] = true
+	}
+	for _, param := range listToSlice(params) {
+		if sym, ok := param.(Symbol); ok {
+			locals[sym] = true
+		}
+	}
+	check := &strictCheck{env: env, fn: name}
+	if err := check.form(body, locals); err != nil {
+		return err
+	}
+
+	checked.add(form)
+	return nil
+}
+
+// strictCheck walks a function body looking for unresolvable symbols
+type strictCheck struct {
+	env *Environment
+	fn  Symbol
+	pos Position // Of the innermost list read from source
+}
+
+func (c *strictCheck) form(form Value, locals map[Symbol]bool) error {
+	switch v := form.(type) {
+	case Symbol:
+		return c.symbol(v, locals)
+	case *List:
+		if v.IsEmpty() {
+			return nil
+		}
+		return c.list(v, locals)
+	}
+	return nil
+}
+
+func (c *strictCheck) forms(forms []Value, locals map[Symbol]bool) error {
+	for _, form := range forms {
+		if err := c.form(form, locals); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *strictCheck) symbol(sym Symbol, locals map[Symbol]bool) error {
+	if locals[sym] || isSpecialForm(sym) {
+		return nil
+	}
+	if _, err := lookupSymbol(sym, c.env); err == nil {
+		return nil
+	}
+	where := ""
+	if c.fn != "" {
+		where = fmt.Sprintf(" in %s", c.fn)
+	}
+	err := NewNameError("undefined symbol: %s%s (strict mode)%s", sym, where, didYouMean(sym, c.env))
+	err.Position = c.pos
+	return err
+}
+
+func (c *strictCheck) list(list *List, locals map[Symbol]bool) error {
+	if pos := list.GetPosition(); pos.Line > 0 {
+		outer := c.pos
+		c.pos = pos
+		defer func() { c.pos = outer }()
+	}
+	args := listToSlice(list.Rest())
+	head, isSymbol := list.First().(Symbol)
+	if !isSymbol || locals[head] {
+		return c.forms(listToSlice(list), locals)
+	}
+
+	switch head {
+	case "quote", "quasiquote", "var", "defmacro":
+		return nil
+	case "def":
+		if len(args) == 0 {
+			return nil
+		}
+		if name, ok := args[0].(Symbol); ok {
+			locals[name] = true
+		}
+		return c.forms(args[1:], locals)
+	case "defn":
+		if len(args) < 2 {
+			return nil
+		}
+		inner := withLocals(locals)
+		if name, ok := args[0].(Symbol); ok {
+			locals[name] = true
+			inner[name] = true
+		}
+		_, _, rest := splitDocAndAttrs(args[1:])
+		if len(rest) == 0 {
+			return nil
+		}
+		return c.fnBody(rest[0], rest[1:], inner)
+	case "fn":
+		if len(args) == 0 {
+			return nil
+		}
+		return c.fnBody(args[0], args[1:], withLocals(locals))
+	case "let", "loop":
+		if len(args) == 0 {
+			return nil
+		}
+		pairs, _ := collectionToSlice(args[0])
+		inner := withLocals(locals)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if err := c.form(pairs[i+1], inner); err != nil {
+				return err
+			}
+			if name, ok := pairs[i].(Symbol); ok {
+				inner[name] = true
+			}
+		}
+		return c.forms(args[1:], inner)
+	case "for", "doseq":
+		if len(args) == 0 {
+			return nil
+		}
+		clauses, err := parseIterClauses(string(head), args[0])
+		if err != nil {
+			return nil // Reported when the form runs
+		}
+		inner := withLocals(locals)
+		for _, clause := range clauses {
+			if clause.modifier == "let" {
+				pairs, _ := collectionToSlice(clause.expr)
+				for i := 0; i+1 < len(pairs); i += 2 {
+					if err := c.form(pairs[i+1], inner); err != nil {
+						return err
+					}
+					if name, ok := pairs[i].(Symbol); ok {
+						inner[name] = true
+					}
+				}
+				continue
+			}
+			if err := c.form(clause.expr, inner); err != nil {
+				return err
+			}
+			if clause.modifier == "" {
+				inner[clause.name] = true
+			}
+		}
+		return c.forms(args[1:], inner)
+	case "dotimes":
+		if len(args) == 0 {
+			return nil
+		}
+		binding, _ := collectionToSlice(args[0])
+		inner := withLocals(locals)
+		if len(binding) == 2 {
+			if err := c.form(binding[1], locals); err != nil {
+				return err
+			}
+			if name, ok := binding[0].(Symbol); ok {
+				inner[name] = true
+			}
+		}
+		return c.forms(args[1:], inner)
+	case "case":
+		// Keys are constants; only the dispatch value and results are code
+		for i, arg := range args {
+			if i%2 == 0 || i == len(args)-1 {
+				if err := c.form(arg, locals); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if isSpecialForm(head) {
+		return c.forms(args, locals)
+	}
+	if value, err := c.env.Get(head); err == nil {
+		if _, isMacro := value.(*Macro); isMacro {
+			// Check what the macro expands to, if it expands cleanly
+			expansion, err := macroExpand(list, c.env)
+			if err != nil {
+				return nil
+			}
+			return c.form(expansion, locals)
+		}
+	}
+	if err := c.symbol(head, locals); err != nil {
+		return err
+	}
+	return c.forms(args, locals)
+}
+
+// fnBody checks a function's body with its parameters in scope
+func (c *strictCheck) fnBody(params Value, body []Value, locals map[Symbol]bool) error {
+	names, _ := collectionToSlice(params)
+	for _, name := range names {
+		if sym, ok := name.(Symbol); ok {
+			locals[sym] = true
+		}
+	}
+	return c.forms(body, locals)
+}
+
+// setupStrictOperations adds set-strict!, strict? and declare-var
+func setupStrictOperations(env *Environment) {
+	env.Set(Intern("set-strict!"), &BuiltinFunction{
+		Name: "set-strict!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("set-strict! expects 1 argument, got %d", len(args))
+			}
+			env.SetStrict(isTruthy(args[0]))
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("strict?"), &BuiltinFunction{
+		Name: "strict?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("strict? expects 0 arguments, got %d", len(args))
+			}
+			if env.Strict() {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	// The declare macro expands to declare-var calls
+	env.Set(Intern("declare-var"), &BuiltinFunction{
+		Name: "declare-var",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("declare-var expects 1 argument, got %d", len(args))
+			}
+			name, ok := args[0].(Symbol)
+			if !ok {
+				return nil, NewTypeError("declare-var expects a symbol, got %s", TypeName(args[0]))
+			}
+			root := env.root()
+			if _, exists := root.binding(name); !exists {
+				root.Set(name, Nil{})
+				root.varRegistry().declared[name] = true
+			}
+			return name, nil
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrictMode(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(def limit 10)
+		(set-strict! true)
+		(declare-var 'odd2?)
+		(defn even2? [n] (if (= n 0) true (odd2? (- n 1))))
+		(defn odd2? [n] (if (= n 0) nil (even2? (- n 1))))
+		(defn total [xs] (loop [ys xs acc 0] (if (empty? ys) acc (recur (rest ys) (+ acc (first ys))))))
+		(defn squares [n] (let [sq (fn [x] (* x x))] (for [i (list 1 2 n) :let [j (sq i)]] j)))`)
+
+	if result := evalAll(t, env, "(even2? 10)"); result.String() != "true" {
+		t.Errorf("Expected declared names to be usable before their definition, got %s", result)
+	}
+	if result := evalAll(t, env, "(squares 3)"); result.String() != "(1 4 9)" {
+		t.Errorf("Expected locals to resolve in strict mode, got %s", result)
+	}
+	if result := evalAll(t, env, "(strict?)"); result.String() != "true" {
+		t.Errorf("Expected strict? to be true, got %s", result)
+	}
+
+	for input, message := range map[string]string{
+		"(defn f [x] (+ x y))":               "undefined symbol: y in f (strict mode)",
+		"(fn [] (frist limit))":              "undefined symbol: frist (strict mode); did you mean first?",
+		"(defn g [] (let [a 1] (+ a b)))":    "undefined symbol: b in g",
+		"(defn h [] (cond (= 1 1) missing))": "undefined symbol: missing in h",
+		"(def limit 20)":                     "cannot redefine limit in strict mode",
+		"(defn total [xs] 0)":                "cannot redefine total in strict mode",
+		"(warn \"careful\")":                 "careful",
+	} {
+		expr, _ := ReadString(input)
+		_, err := Eval(expr, env)
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for '%s', got: %v", message, input, err)
+		}
+	}
+
+	// Redefinition through alter-var-root stays possible
+	if result := evalAll(t, env, "(alter-var-root (var limit) (fn [n] (+ n 1))) limit"); result.String() != "11" {
+		t.Errorf("Expected alter-var-root to work in strict mode, got %s", result)
+	}
+
+	// Other environments are unaffected
+	if result := evalAll(t, NewCoreEnvironment(), "(def limit 1) (def limit 2) (fn [] unknown) limit"); result.String() != "2" {
+		t.Errorf("Expected strict mode to be per environment, got %s", result)
+	}
+}
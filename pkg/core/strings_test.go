@@ -0,0 +1,52 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStringBuilding(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def b (string-builder "a"))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(str "a" 1 :k nil 'sym 1.5)`, `"a1:ksym1.5"`},
+		{`(str)`, `""`},
+		{`(apply str (list "x" "y" 3))`, `"xy3"`},
+		{`(string-join ", " (list 1 "x" :y))`, `"1, x, :y"`},
+		{`(string-join (vector "a" "b"))`, `"ab"`},
+		{`(string-join "-" nil)`, `""`},
+		{`(do (sb-append! b "b" 2 nil) (sb-str b))`, `"ab2"`},
+		{`(sb-str (sb-append! (sb-append! b :c) "d"))`, `"ab2:cd"`},
+		{`(sb-str (string-builder))`, `""`},
+		{`(type b)`, `:string-builder`},
+	}
+	for _, test := range tests {
+		if got := evalAll(t, env, test.input).String(); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	if err := evalSource(t, env, `(sb-append! "a" "b")`); err == nil || !strings.Contains(err.Error(), "sb-append! expects a string-builder, got string") {
+		t.Errorf("Expected a type error for a string, got %v", err)
+	}
+}
+
+func TestStrOnLargeSequences(t *testing.T) {
+	env := NewCoreEnvironment()
+	items := make([]Value, 20000)
+	for i := range items {
+		items[i] = String("ab")
+	}
+	env.Set(Intern("items"), NewList(items...))
+
+	if got := evalAll(t, env, `(string-length (apply str items))`).String(); got != "40000" {
+		t.Errorf("Expected 40000 characters, got %s", got)
+	}
+	if got := evalAll(t, env, `(string-length (string-join "," items))`).String(); got != "59999" {
+		t.Errorf("Expected 59999 characters, got %s", got)
+	}
+}
@@ -0,0 +1,437 @@
+package core
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Decode fills the Go value out points to from a Lisp value. Structs are
+// read from hash-maps, keyed by the field's `lisp:"name"` tag or, without a
+// tag, by the kebab-cased field name (CreatedAt reads :created-at). Keys may
+// be keywords or strings, and keys without a field are ignored. Nested
+// structs, pointers (nil for nil), slices, string-keyed maps and time.Time
+// (an RFC 3339 string) are supported.
+func Decode(value Value, out any) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Pointer || target.IsNil() {
+		return NewTypeError("Decode expects a non-nil pointer, got %T", out)
+	}
+	return decodeValue(value, target.Elem(), target.Elem().Type().Name())
+}
+
+// Encode converts a Go value into Lisp data, the reverse of Decode: structs
+// become hash-maps with keyword keys, slices become vectors, nil pointers
+// become nil and time.Time becomes an RFC 3339 string. Fields tagged
+// `lisp:",omitempty"` are left out when zero.
+func Encode(v any) (Value, error) {
+	if v == nil {
+		return Nil{}, nil
+	}
+	return encodeValue(reflect.ValueOf(v), reflect.TypeOf(v).Name())
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// structField is a struct field and the hash-map key it maps to
+type structField struct {
+	index     int
+	key       string
+	omitEmpty bool
+}
+
+// structFields returns the mapped fields of an exported struct type
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("lisp"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = kebabCase(field.Name)
+		}
+		fields = append(fields, structField{index: i, key: name, omitEmpty: options == "omitempty"})
+	}
+	return fields
+}
+
+// kebabCase turns a Go name like CreatedAt or HTTPPort into created-at or
+// http-port
+func kebabCase(name string) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			startsWord := i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1])))
+			if startsWord {
+				b.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// mapLookup finds key in m as a keyword or a string
+func mapLookup(m *HashMap, key string) (Value, bool) {
+	if k := Keyword(key); m.ContainsKey(k) {
+		return m.Get(k), true
+	}
+	if k := String(key); m.ContainsKey(k) {
+		return m.Get(k), true
+	}
+	return nil, false
+}
+
+func decodeValue(value Value, target reflect.Value, path string) error {
+	if value == nil {
+		value = Nil{}
+	}
+	if host, ok := value.(*GoStruct); ok {
+		source := reflect.ValueOf(host.Ptr).Elem()
+		if source.Type().AssignableTo(target.Type()) {
+			target.Set(source)
+			return nil
+		}
+	}
+
+	fail := func(expected string) error {
+		return NewTypeError("decoding %s: expected %s, got %s", path, expected, value)
+	}
+
+	if target.Type() == timeType {
+		if inst, ok := value.(Inst); ok {
+			target.Set(reflect.ValueOf(inst.Time))
+			return nil
+		}
+		text, ok := value.(String)
+		if !ok {
+			return fail("an RFC 3339 time string")
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, string(text))
+		if err != nil {
+			return NewTypeError("decoding %s: %v", path, err)
+		}
+		target.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	switch target.Kind() {
+	case reflect.Pointer:
+		if _, isNil := value.(Nil); isNil {
+			target.SetZero()
+			return nil
+		}
+		elem := reflect.New(target.Type().Elem())
+		if err := decodeValue(value, elem.Elem(), path); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+
+	case reflect.Interface:
+		if _, isNil := value.(Nil); isNil {
+			target.SetZero()
+			return nil
+		}
+		plain := reflect.ValueOf(valueToGo(value))
+		if !plain.Type().AssignableTo(target.Type()) {
+			return fail(target.Type().String())
+		}
+		target.Set(plain)
+		return nil
+
+	case reflect.String:
+		switch v := value.(type) {
+		case String:
+			target.SetString(string(v))
+		case Keyword:
+			target.SetString(string(v))
+		case Symbol:
+			target.SetString(string(v))
+		default:
+			return fail("a string")
+		}
+		return nil
+
+	case reflect.Bool:
+		target.SetBool(isTruthy(value) && value != Symbol("false"))
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(Number)
+		if !ok || !n.IsInteger() {
+			return fail("an integer")
+		}
+		if target.OverflowInt(n.ToInt()) {
+			return NewTypeError("decoding %s: %d overflows %s", path, n.ToInt(), target.Type())
+		}
+		target.SetInt(n.ToInt())
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(Number)
+		if !ok || !n.IsInteger() || n.ToInt() < 0 {
+			return fail("a non-negative integer")
+		}
+		if target.OverflowUint(uint64(n.ToInt())) {
+			return NewTypeError("decoding %s: %d overflows %s", path, n.ToInt(), target.Type())
+		}
+		target.SetUint(uint64(n.ToInt()))
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		n, ok := value.(Number)
+		if !ok {
+			return fail("a number")
+		}
+		target.SetFloat(n.ToFloat())
+		return nil
+
+	case reflect.Slice, reflect.Array:
+		if _, isNil := value.(Nil); isNil && target.Kind() == reflect.Slice {
+			target.SetZero()
+			return nil
+		}
+		items, err := collectionToSlice(value)
+		if err != nil {
+			return fail("a sequence")
+		}
+		if target.Kind() == reflect.Array {
+			if len(items) != target.Len() {
+				return NewTypeError("decoding %s: expected %d elements, got %d", path, target.Len(), len(items))
+			}
+		} else {
+			target.Set(reflect.MakeSlice(target.Type(), len(items), len(items)))
+		}
+		for i, item := range items {
+			if err := decodeValue(item, target.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case reflect.Map:
+		if _, isNil := value.(Nil); isNil {
+			target.SetZero()
+			return nil
+		}
+		m, ok := value.(*HashMap)
+		if !ok || target.Type().Key().Kind() != reflect.String {
+			return fail("a hash-map")
+		}
+		result := reflect.MakeMapWithSize(target.Type(), m.Count())
+		for _, key := range m.keys {
+			name := mapKeyToString(key)
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := decodeValue(m.Get(key), elem, path+"."+name); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(name).Convert(target.Type().Key()), elem)
+		}
+		target.Set(result)
+		return nil
+
+	case reflect.Struct:
+		m, ok := value.(*HashMap)
+		if !ok {
+			return fail("a hash-map")
+		}
+		for _, field := range structFields(target.Type()) {
+			fieldValue, present := mapLookup(m, field.key)
+			if !present {
+				continue
+			}
+			if err := decodeValue(fieldValue, target.Field(field.index), path+"."+field.key); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return NewTypeError("decoding %s: unsupported Go type %s", path, target.Type())
+}
+
+func encodeValue(v reflect.Value, path string) (Value, error) {
+	if !v.IsValid() {
+		return Nil{}, nil
+	}
+	if v.Type() == timeType {
+		return String(v.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return Nil{}, nil
+		}
+		return encodeValue(v.Elem(), path)
+
+	case reflect.String:
+		return String(v.String()), nil
+
+	case reflect.Bool:
+		if v.Bool() {
+			return Symbol("true"), nil
+		}
+		return Nil{}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewNumber(v.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, NewTypeError("encoding %s: %d does not fit an integer", path, v.Uint())
+		}
+		return NewNumber(int64(v.Uint())), nil
+
+	case reflect.Float32, reflect.Float64:
+		return NewNumber(v.Float()), nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return Nil{}, nil
+		}
+		elements := make([]Value, v.Len())
+		for i := range elements {
+			elem, err := encodeValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = elem
+		}
+		return NewVector(elements...), nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return Nil{}, nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, NewTypeError("encoding %s: map keys must be strings, got %s", path, v.Type().Key())
+		}
+		// Go map order is random, so keys are added sorted for stable output
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		result := NewHashMap()
+		for _, key := range keys {
+			elem, err := encodeValue(v.MapIndex(key), path+"."+key.String())
+			if err != nil {
+				return nil, err
+			}
+			result.Set(String(key.String()), elem)
+		}
+		return result, nil
+
+	case reflect.Struct:
+		result := NewHashMap()
+		for _, field := range structFields(v.Type()) {
+			fieldValue := v.Field(field.index)
+			if field.omitEmpty && fieldValue.IsZero() {
+				continue
+			}
+			elem, err := encodeValue(fieldValue, path+"."+field.key)
+			if err != nil {
+				return nil, err
+			}
+			result.Set(Keyword(field.key), elem)
+		}
+		return result, nil
+	}
+
+	return nil, NewTypeError("encoding %s: unsupported Go type %s", path, v.Type())
+}
+
+// GoStruct is a Go struct decoded from Lisp with decode-as. Embedders get
+// the struct from Ptr, a pointer to a value of the registered type.
+type GoStruct struct {
+	Name Symbol
+	Ptr  any
+}
+
+func (s *GoStruct) String() string {
+	encoded, err := Encode(s.Ptr)
+	if err != nil {
+		return fmt.Sprintf("#<%s>", s.Name)
+	}
+	return "#" + string(s.Name) + printString(encoded)
+}
+
+var structTypes = struct {
+	sync.RWMutex
+	byName map[Symbol]reflect.Type
+}{byName: make(map[Symbol]reflect.Type)}
+
+// RegisterStruct makes the struct type of example available to decode-as
+// under name, e.g. core.RegisterStruct("Config", Config{}). Its values then
+// also read back from the #Config{...} form they print as.
+func RegisterStruct(name string, example any) {
+	t := reflect.TypeOf(example)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	structTypes.Lock()
+	structTypes.byName[Symbol(name)] = t
+	structTypes.Unlock()
+	RegisterTagReader(name, func(form Value) (Value, error) {
+		return decodeStruct(Symbol(name), t, form)
+	})
+}
+
+// decodeStruct decodes value into a new struct of type t registered as name
+func decodeStruct(name Symbol, t reflect.Type, value Value) (Value, error) {
+	ptr := reflect.New(t)
+	if err := decodeValue(value, ptr.Elem(), string(name)); err != nil {
+		return nil, err
+	}
+	return &GoStruct{Name: name, Ptr: ptr.Interface()}, nil
+}
+
+// setupStructOperations adds decode-as and from-struct
+func setupStructOperations(env *Environment) {
+	env.Set(Intern("decode-as"), &BuiltinFunction{
+		Name: "decode-as",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("decode-as expects 2 arguments, got %d", len(args))
+			}
+			name, ok := args[0].(Symbol)
+			if !ok {
+				return nil, NewTypeError("decode-as expects a struct name symbol, got %s", TypeName(args[0]))
+			}
+			structTypes.RLock()
+			t, ok := structTypes.byName[name]
+			structTypes.RUnlock()
+			if !ok {
+				return nil, NewNameError("no Go struct registered as %s", name)
+			}
+
+			return decodeStruct(name, t, args[1])
+		},
+	})
+
+	env.Set(Intern("from-struct"), &BuiltinFunction{
+		Name: "from-struct",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("from-struct expects 1 argument, got %d", len(args))
+			}
+			host, ok := args[0].(*GoStruct)
+			if !ok {
+				return nil, NewTypeError("from-struct expects a decoded struct, got %s", TypeName(args[0]))
+			}
+			return Encode(host.Ptr)
+		},
+	})
+}
@@ -0,0 +1,121 @@
+package core_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+type address struct {
+	City string `lisp:"city"`
+	Zip  *int   `lisp:"zip"`
+}
+
+type account struct {
+	Name      string         `lisp:"name"`
+	Age       uint8          `lisp:"age"`
+	Admin     bool           `lisp:"admin"`
+	Score     float64        `lisp:"score,omitempty"`
+	Tags      []string       `lisp:"tags"`
+	Home      *address       `lisp:"home"`
+	Work      *address       `lisp:"work"`
+	Labels    map[string]int `lisp:"labels"`
+	CreatedAt time.Time
+	Secret    string `lisp:"-"`
+	Extra     any    `lisp:"extra"`
+}
+
+func TestDecodeEncodeStructs(t *testing.T) {
+	env := core.NewCoreEnvironment()
+	expr, _ := core.ReadString(`(hash-map :name "Ada" "age" 36 :admin true
+		:tags (list :math "code") :home (hash-map :city "London" :zip 1815) :work nil
+		:labels (hash-map :x 1 "y" 2) :created-at "2024-03-01T12:00:00Z" :secret "no"
+		:extra (vector 1 :a) :unknown 1)`)
+	value, err := core.Eval(expr, env)
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	var decoded account
+	if err := core.Decode(value, &decoded); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if decoded.Name != "Ada" || decoded.Age != 36 || !decoded.Admin || decoded.Secret != "" {
+		t.Errorf("Unexpected scalar fields: %+v", decoded)
+	}
+	if strings.Join(decoded.Tags, ",") != "math,code" || decoded.Labels["y"] != 2 {
+		t.Errorf("Unexpected collections: %v %v", decoded.Tags, decoded.Labels)
+	}
+	if decoded.Home == nil || decoded.Home.City != "London" || *decoded.Home.Zip != 1815 || decoded.Work != nil {
+		t.Errorf("Unexpected nested structs: %+v %+v", decoded.Home, decoded.Work)
+	}
+	if !decoded.CreatedAt.Equal(time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected time: %v", decoded.CreatedAt)
+	}
+
+	encoded, err := core.Encode(decoded)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	expected := `{:name "Ada" :age 36 :admin true :tags ["math" "code"] :home {:city "London" :zip 1815} ` +
+		`:work nil :labels {"x" 1 "y" 2} :created-at "2024-03-01T12:00:00Z" :extra [1 "a"]}`
+	if encoded.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, encoded)
+	}
+
+	for input, message := range map[string]string{
+		`(hash-map :age 300)`:                  "decoding account.age: 300 overflows uint8",
+		`(hash-map :age -1)`:                   "expected a non-negative integer",
+		`(hash-map :home (hash-map :zip "x"))`: "decoding account.home.zip: expected an integer",
+		`(hash-map :tags "x")`:                 "decoding account.tags: expected a sequence",
+		`(hash-map :created-at "yesterday")`:   "decoding account.created-at",
+		`(list 1 2)`:                           "decoding account: expected a hash-map",
+	} {
+		expr, _ := core.ReadString(input)
+		value, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		var target account
+		if err := core.Decode(value, &target); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
+
+func TestDecodeAs(t *testing.T) {
+	core.RegisterStruct("Address", address{})
+	env := core.NewCoreEnvironment()
+
+	for input, expected := range map[string]string{
+		`(decode-as 'Address (hash-map :city "Oslo"))`:                        `#Address{:city "Oslo" :zip nil}`,
+		`(from-struct (decode-as 'Address (hash-map :city "Oslo" :zip 150)))`: `{:city "Oslo" :zip 150}`,
+	} {
+		expr, _ := core.ReadString(input)
+		result, err := core.Eval(expr, env)
+		if err != nil {
+			t.Fatalf("Eval error for '%s': %v", input, err)
+		}
+		if result.String() != expected {
+			t.Errorf("Expected %s for '%s', got %s", expected, input, result)
+		}
+	}
+
+	expr, _ := core.ReadString(`(decode-as 'Address (hash-map :city "Rome"))`)
+	result, _ := core.Eval(expr, env)
+	if decoded, ok := result.(*core.GoStruct); !ok || decoded.Ptr.(*address).City != "Rome" {
+		t.Errorf("Expected embedders to get the Go struct back, got %#v", result)
+	}
+
+	for input, message := range map[string]string{
+		`(decode-as 'Nope (hash-map))`:            "no Go struct registered as Nope",
+		`(decode-as 'Address (hash-map :city 1))`: "decoding Address.city: expected a string",
+	} {
+		expr, _ := core.ReadString(input)
+		if _, err := core.Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
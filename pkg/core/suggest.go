@@ -0,0 +1,105 @@
+package core
+
+import (
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is how many names an undefined symbol error proposes
+const maxSuggestions = 3
+
+// undefinedSymbol returns the error for looking up an unbound sym in env,
+// suggesting the bound names and special forms closest to it
+func undefinedSymbol(sym Symbol, env *Environment) error {
+	return NewNameError("undefined symbol: %s%s", sym, didYouMean(sym, env))
+}
+
+// didYouMean returns "; did you mean a, b?" for the names closest to sym, or
+// "" if nothing is close
+func didYouMean(sym Symbol, env *Environment) string {
+	if suggestions := suggestSymbols(string(sym), env); len(suggestions) > 0 {
+		return "; did you mean " + strings.Join(suggestions, ", ") + "?"
+	}
+	return ""
+}
+
+// suggestSymbols returns up to maxSuggestions of the names in scope that are
+// fewest edits away from name, if any are within a few edits
+func suggestSymbols(name string, env *Environment) []string {
+	// Allow about one typo per four characters, and fewer edits than name
+	// has characters, so short names don't match everything
+	limit := min(len([]rune(name))/4+1, len([]rune(name))-1)
+
+	type candidate struct {
+		name     string
+		distance int
+	}
+	var candidates []candidate
+	seen := make(map[string]bool)
+	consider := func(other string) {
+		if seen[other] || other == name {
+			return
+		}
+		seen[other] = true
+		if d := editDistance(name, other, limit); d <= limit {
+			candidates = append(candidates, candidate{other, d})
+		}
+	}
+	for _, other := range env.GetAllSymbols() {
+		consider(other)
+	}
+	for form := range specialForms {
+		consider(string(form))
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].name < candidates[j].name
+	})
+	// Only the closest matches; a farther one is rarely what was meant
+	var names []string
+	for i := 0; i < len(candidates) && i < maxSuggestions && candidates[i].distance == candidates[0].distance; i++ {
+		names = append(names, candidates[i].name)
+	}
+	return names
+}
+
+// editDistance is the Damerau-Levenshtein distance between a and b, counting
+// a swap of adjacent characters as one edit. It gives up with limit+1 once
+// the distance is known to exceed limit
+func editDistance(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > limit || -diff > limit {
+		return limit + 1
+	}
+
+	// Three rows of the dynamic programming table: two back, previous, current
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		best := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+			best = min(best, curr[j])
+		}
+		if best > limit {
+			return limit + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,58 @@
+package core
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"length", "length", 0},
+		{"lenght", "length", 1},
+		{"frist", "first", 1},
+		{"mpa", "map", 1},
+		{"defm", "defn", 1},
+		{"reduce", "redcue", 1},
+		{"kitten", "sitting", 3},
+		{"", "abc", 3},
+	}
+	for _, test := range tests {
+		if got := editDistance(test.a, test.b, 10); got != test.expected {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", test.a, test.b, got, test.expected)
+		}
+	}
+	if got := editDistance("a", "abcdef", 2); got != 3 {
+		t.Errorf("Expected editDistance to stop at limit+1, got %d", got)
+	}
+}
+
+func TestUndefinedSymbolSuggestions(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, "(def customer-count 3)")
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(lenght [1 2])", "undefined symbol: lenght; did you mean length?"},
+		{"(frist [1 2])", "undefined symbol: frist; did you mean first?"},
+		{"customer-cuont", "undefined symbol: customer-cuont; did you mean customer-count?"},
+		{"(let [total 1] totl)", "undefined symbol: totl; did you mean total?"},
+		{"(defm x 1)", "undefined symbol: defm; did you mean def, defn?"},
+		{"(qqqqqq)", "undefined symbol: qqqqqq"},
+		// Replacing the only character would reach every one-character name
+		{"(q 1)", "undefined symbol: q"},
+		{"(nh [1] 0)", "undefined symbol: nh; did you mean nth?"},
+	}
+	for _, test := range tests {
+		expr, _ := ReadString(test.input)
+		_, err := Eval(expr, env)
+		if err == nil {
+			t.Errorf("Expected an error for '%s'", test.input)
+			continue
+		}
+		if message := err.(*LispError).Message; message != test.expected {
+			t.Errorf("For '%s', expected %q, got %q", test.input, test.expected, message)
+		}
+	}
+}
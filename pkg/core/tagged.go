@@ -0,0 +1,193 @@
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tagged literals are read as #tag form: #inst "2024-01-01T00:00:00Z",
+// #uuid "...", #matrix [[1 2] [3 4]], #duration "2h30m", #Config{...} for
+// structs registered with RegisterStruct, and any tag given a reader with
+// set-tag-reader! or RegisterTagReader. The reader function gets the form
+// unevaluated and returns the value it stands for. Values of these types
+// print as the literal that reads them back.
+
+// Inst is a point in time, read and printed as #inst "RFC 3339 time"
+type Inst struct {
+	Time time.Time
+}
+
+func (i Inst) String() string {
+	return fmt.Sprintf("#inst %q", i.Time.Format(time.RFC3339Nano))
+}
+
+// UUID is a universally unique identifier in canonical lower case form,
+// read and printed as #uuid "..."
+type UUID string
+
+func (u UUID) String() string {
+	return fmt.Sprintf("#uuid %q", string(u))
+}
+
+// TaggedLiteral is a tagged form whose tag had no reader when it was read.
+// Evaluating it applies the reader registered by then, so a file can
+// register a tag and use it further down; otherwise it prints as read.
+type TaggedLiteral struct {
+	Tag  Symbol
+	Form Value
+}
+
+func (t *TaggedLiteral) String() string {
+	return "#" + string(t.Tag) + " " + printString(t.Form)
+}
+
+// TagReader turns the form after a tag into the value it stands for
+type TagReader func(form Value) (Value, error)
+
+var tagReaders = struct {
+	sync.RWMutex
+	byTag map[Symbol]TagReader
+}{byTag: map[Symbol]TagReader{
+	"inst":     readInst,
+	"uuid":     readUUID,
+	"matrix":   readMatrix,
+	"duration": readDuration,
+}}
+
+// RegisterTagReader makes the reader handle #tag literals
+func RegisterTagReader(tag string, reader TagReader) {
+	tagReaders.Lock()
+	defer tagReaders.Unlock()
+	tagReaders.byTag[Symbol(tag)] = reader
+}
+
+// readTagged applies tag's reader to form, or returns form as a
+// TaggedLiteral if the tag has none yet
+func readTagged(tag Symbol, form Value) (Value, error) {
+	tagReaders.RLock()
+	reader, ok := tagReaders.byTag[tag]
+	tagReaders.RUnlock()
+	if !ok {
+		return &TaggedLiteral{Tag: tag, Form: form}, nil
+	}
+	value, err := reader(form)
+	if err != nil {
+		return nil, fmt.Errorf("#%s: %v", tag, err)
+	}
+	return value, nil
+}
+
+// resolve applies the reader registered for the literal's tag
+func (t *TaggedLiteral) resolve() (Value, error) {
+	value, err := readTagged(t.Tag, t.Form)
+	if err != nil {
+		return nil, NewRuntimeError("%v", err)
+	}
+	if _, unresolved := value.(*TaggedLiteral); unresolved {
+		return nil, NewNameError("no reader for tag #%s", t.Tag)
+	}
+	return value, nil
+}
+
+// instLayouts are the forms #inst accepts, from most to least precise
+var instLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02", "2006-01", "2006"}
+
+func readInst(form Value) (Value, error) {
+	text, ok := form.(String)
+	if !ok {
+		return nil, fmt.Errorf("expected a time string, got %s", form)
+	}
+	for _, layout := range instLayouts {
+		if t, err := time.Parse(layout, string(text)); err == nil {
+			return Inst{Time: t}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q, expected RFC 3339 like \"2024-01-01T00:00:00Z\"", string(text))
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+func readUUID(form Value) (Value, error) {
+	text, ok := form.(String)
+	if !ok || !uuidPattern.MatchString(string(text)) {
+		return nil, fmt.Errorf("expected a UUID string like \"123e4567-e89b-12d3-a456-426614174000\", got %s", form)
+	}
+	return UUID(strings.ToLower(string(text))), nil
+}
+
+// randomUUID returns a version 4 UUID
+func randomUUID() UUID {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return UUID(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]))
+}
+
+// setupTaggedLiteralOperations adds set-tag-reader!, inst?, uuid? and
+// random-uuid
+func setupTaggedLiteralOperations(env *Environment) {
+	env.Set(Intern("set-tag-reader!"), &BuiltinFunction{
+		Name: "set-tag-reader!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("set-tag-reader! expects 2 arguments, got %d", len(args))
+			}
+			tag, ok := args[0].(Symbol)
+			if !ok {
+				return nil, NewTypeError("set-tag-reader! expects a tag symbol, got %s", TypeName(args[0]))
+			}
+			if tag == "inst" || tag == "uuid" || tag == "matrix" || tag == "duration" {
+				return nil, NewRuntimeError("set-tag-reader! cannot replace the built-in #%s reader", tag)
+			}
+			fn := args[1]
+			if _, ok := fn.(Callable); !ok {
+				return nil, NewTypeError("set-tag-reader! expects a function, got %s", TypeName(fn))
+			}
+			RegisterTagReader(string(tag), func(form Value) (Value, error) {
+				return callFunction(fn, []Value{form}, env)
+			})
+			return tag, nil
+		},
+	})
+
+	env.Set(Intern("inst?"), &BuiltinFunction{
+		Name: "inst?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("inst? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(Inst); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("uuid?"), &BuiltinFunction{
+		Name: "uuid?",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("uuid? expects 1 argument, got %d", len(args))
+			}
+			if _, ok := args[0].(UUID); ok {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+	})
+
+	env.Set(Intern("random-uuid"), &BuiltinFunction{
+		Name: "random-uuid",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("random-uuid expects 0 arguments, got %d", len(args))
+			}
+			return randomUUID(), nil
+		},
+	})
+}
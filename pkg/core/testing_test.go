@@ -0,0 +1,178 @@
+package core_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+func TestDeftestAndGolden(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	source := `
+		(deftest arithmetic
+		  (is (= 3 (+ 1 2)))
+		  (is (= 4 (+ 1 2)) "one plus two"))
+		(deftest printer
+		  (is-golden "config" {:name "demo" :ports [8080 8081]}))
+		(deftest broken
+		  (throw "boom"))`
+	expressions, err := core.ReadString("(do " + source + ")")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if _, err := core.Eval(expressions, env); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	goldenDir := t.TempDir()
+	run := func(update bool) (core.TestSummary, string) {
+		var out bytes.Buffer
+		summary := core.RunTests(env, core.TestOptions{GoldenDir: goldenDir, Update: update, Out: &out})
+		return summary, out.String()
+	}
+
+	// Without a golden file the golden assertion fails
+	summary, out := run(false)
+	if summary.Tests != 3 || summary.Pass != 1 || summary.Fail != 2 || summary.Error != 1 {
+		t.Errorf("Unexpected summary %+v\n%s", summary, out)
+	}
+	for _, want := range []string{"FAIL in (arithmetic)", "one plus two", "expected: (= 4 (+ 1 2))", "missing golden file", "ERROR in (broken)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	// --update writes the golden file, after which it matches
+	run(true)
+	golden, err := os.ReadFile(filepath.Join(goldenDir, "config.golden"))
+	if err != nil {
+		t.Fatalf("Expected golden file to be written: %v", err)
+	}
+	if string(golden) != "{:name \"demo\" :ports [8080 8081]}\n" {
+		t.Errorf("Unexpected golden content %q", golden)
+	}
+	if summary, out = run(false); summary.Fail != 1 {
+		t.Errorf("Expected only the arithmetic failure, got %+v\n%s", summary, out)
+	}
+
+	// A changed golden file is reported as a difference
+	os.WriteFile(filepath.Join(goldenDir, "config.golden"), []byte("{:name \"old\"}\n"), 0644)
+	if summary, out = run(false); summary.Fail != 2 || !strings.Contains(out, "differs") || !strings.Contains(out, "-{:name \"old\"}\n+{:name \"demo\" :ports [8080 8081]}") {
+		t.Errorf("Expected golden mismatch, got %+v\n%s", summary, out)
+	}
+}
+
+func TestGoldenMacroexpansion(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	source := "(do (defmacro twice [x] `(let [v# ~x] (+ v# v#)))" +
+		" (deftest expansion (is-golden \"twice\" (macroexpand '(twice 1)))))"
+	expr, err := core.ReadString(source)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if _, err := core.Eval(expr, env); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	// Each test numbers its gensyms from 1, however many were made before
+	goldenDir := t.TempDir()
+	core.RunTests(env, core.TestOptions{GoldenDir: goldenDir, Update: true, Out: &bytes.Buffer{}})
+	expr, _ = core.ReadString("(gensym)")
+	core.Eval(expr, env)
+	var out bytes.Buffer
+	if summary := core.RunTests(env, core.TestOptions{GoldenDir: goldenDir, Out: &out}); summary.Pass != 1 {
+		t.Errorf("Expected the golden expansion to match, got %+v\n%s", summary, out.String())
+	}
+	golden, _ := os.ReadFile(filepath.Join(goldenDir, "twice.golden"))
+	if string(golden) != "(let [v__1__auto__ 1] (+ v__1__auto__ v__1__auto__))\n" {
+		t.Errorf("Unexpected golden content %q", golden)
+	}
+}
+
+func TestEqualityFailureShowsDiff(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	expr, _ := core.ReadString(`(do
+		(deftest equality
+		  (is (= {:name "demo" :ports [80 443]} {:name "demo" :ports [80 8443]}))
+		  (is (= "one\ntwo\nthree" "one\n2\nthree"))
+		  (is (= 1 (+ 1 0)))))`)
+	if _, err := core.Eval(expr, env); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+
+	var out bytes.Buffer
+	summary := core.RunTests(env, core.TestOptions{GoldenDir: t.TempDir(), Out: &out})
+	if summary.Pass != 1 || summary.Fail != 2 {
+		t.Errorf("Unexpected summary %+v\n%s", summary, out.String())
+	}
+	for _, want := range []string{
+		`actual: (not (= {:name "demo" :ports [80 443]} {:name "demo" :ports [80 8443]}))`,
+		"diff: - {:ports [nil 443]}",
+		"+ {:ports [nil 8443]}",
+		"--- expected\n+++ actual\n@@ -1,3 +1,3 @@\n one\n-two\n+2\n three",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestAssertionOutsideTestRun(t *testing.T) {
+	env, err := core.CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+
+	expr, _ := core.ReadString(`(is (= 1 1))`)
+	if result, err := core.Eval(expr, env); err != nil || result.String() != "true" {
+		t.Errorf("Expected passing assertion to return true, got %v, %v", result, err)
+	}
+
+	expr, _ = core.ReadString(`(is (= 1 2))`)
+	if _, err := core.Eval(expr, env); err == nil {
+		t.Error("Expected failing assertion outside a test run to raise an error")
+	}
+}
+
+func TestPrettyString(t *testing.T) {
+	value, err := core.ReadString(`{:name "demo" :servers [{:host "alpha.example.com" :port 8080} {:host "beta.example.com" :port 8081}]}`)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	tests := []struct {
+		width    int
+		expected string
+	}{
+		{200, `{:name "demo" :servers [{:host "alpha.example.com" :port 8080} {:host "beta.example.com" :port 8081}]}`},
+		{60, `{:name "demo"
+ :servers [{:host "alpha.example.com" :port 8080}
+           {:host "beta.example.com" :port 8081}]}`},
+		{30, `{:name "demo"
+ :servers [{:host "alpha.example.com"
+            :port 8080}
+           {:host "beta.example.com"
+            :port 8081}]}`},
+	}
+
+	for _, test := range tests {
+		if got := core.PrettyString(value, test.width); got != test.expected {
+			t.Errorf("width %d: expected\n%s\ngot\n%s", test.width, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// transcript writes each form evaluated in a REPL, followed by its result as
+// a comment, so an exploratory session can be kept as a script or in docs
+type transcript struct {
+	file  *os.File
+	forms int
+}
+
+func startTranscript(filename string) (*transcript, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, NewIOError("failed to start transcript %s: %v", filename, err)
+	}
+	if _, err := file.WriteString(";; GoLisp REPL transcript\n"); err != nil {
+		file.Close()
+		return nil, NewIOError("failed to write transcript %s: %v", filename, err)
+	}
+	return &transcript{file: file}, nil
+}
+
+// write appends the source of a form and its printed result, commented out
+// so that running the file replays the session
+func (t *transcript) write(source, result string) error {
+	var out strings.Builder
+	out.WriteString("\n")
+	out.WriteString(strings.TrimSpace(source))
+	out.WriteString("\n")
+	for i, line := range strings.Split(result, "\n") {
+		if i == 0 {
+			out.WriteString(";; => " + line + "\n")
+		} else {
+			out.WriteString(";;    " + line + "\n")
+		}
+	}
+	if _, err := t.file.WriteString(out.String()); err != nil {
+		return NewIOError("failed to write transcript %s: %v", t.file.Name(), err)
+	}
+	t.forms++
+	return nil
+}
+
+func (t *transcript) close() error {
+	if err := t.file.Close(); err != nil {
+		return NewIOError("failed to close transcript %s: %v", t.file.Name(), err)
+	}
+	return nil
+}
+
+// recordTranscript adds a successfully evaluated form to the running
+// transcript, if any. The transcript commands themselves are left out.
+func (r *REPL) recordTranscript(source string, expr Value, result Value) {
+	if r.transcript == nil {
+		return
+	}
+	if list, ok := expr.(*List); ok && !list.IsEmpty() {
+		if head, ok := list.First().(Symbol); ok && (head == "start-transcript" || head == "stop-transcript") {
+			return
+		}
+	}
+	printed, err := PrintString(result, r.env)
+	if err != nil {
+		return // Realizing the result failed, so the form did too
+	}
+	if err := r.transcript.write(source, printed); err != nil {
+		fmt.Fprintf(os.Stderr, "Transcript stopped: %v\n", err)
+		r.stopTranscript()
+	}
+}
+
+// stopTranscript closes the running transcript and returns how many forms
+// it recorded
+func (r *REPL) stopTranscript() (int, error) {
+	if r.transcript == nil {
+		return 0, nil
+	}
+	t := r.transcript
+	r.transcript = nil
+	return t.forms, t.close()
+}
+
+// setupTranscriptOperations adds start-transcript and stop-transcript bound
+// to this REPL
+func (r *REPL) setupTranscriptOperations() {
+	r.env.Set(Intern("start-transcript"), &BuiltinFunction{
+		Name: "start-transcript",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("start-transcript expects 1 argument, got %d", len(args))
+			}
+
+			filename, ok := args[0].(String)
+			if !ok {
+				return nil, NewTypeError("start-transcript expects string filename, got %s", TypeName(args[0]))
+			}
+
+			t, err := startTranscript(string(filename))
+			if err != nil {
+				return nil, err
+			}
+			if _, err := r.stopTranscript(); err != nil {
+				t.close()
+				return nil, err
+			}
+			r.transcript = t
+			return filename, nil
+		},
+	})
+
+	r.env.Set(Intern("stop-transcript"), &BuiltinFunction{
+		Name: "stop-transcript",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 0 {
+				return nil, NewArityError("stop-transcript expects no arguments, got %d", len(args))
+			}
+
+			if r.transcript == nil {
+				return Nil{}, nil
+			}
+			forms, err := r.stopTranscript()
+			if err != nil {
+				return nil, err
+			}
+			return NewNumber(int64(forms)), nil
+		},
+	})
+
+	guardBuiltins(r.env, []Capability{CapabilityFS}, "start-transcript", "stop-transcript")
+}
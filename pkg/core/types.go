@@ -3,7 +3,9 @@ package core
 import (
 	"fmt"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // Value is the core interface for all Lisp values
@@ -260,7 +262,12 @@ func NewIOError(format string, args ...any) *LispError {
 // Symbol represents an interned symbol
 type Symbol string
 
+// String returns the symbol as it is read, between bars if its name has
+// characters that would not read back as the symbol, like |two words|
 func (s Symbol) String() string {
+	if !isPlainSymbol(string(s)) {
+		return barSymbol(string(s))
+	}
 	return string(s)
 }
 
@@ -287,7 +294,7 @@ func (k Keyword) Call(args []Value, env *Environment) (Value, error) {
 		return value, nil
 	}
 
-	return nil, NewTypeError("keyword %s can only be called on hash-maps, got %T", k, args[0])
+	return nil, NewTypeError("keyword %s can only be called on hash-maps, got %s", k, TypeName(args[0]))
 }
 
 // Number represents both integers and floats
@@ -296,7 +303,14 @@ type Number struct {
 }
 
 func (n Number) String() string {
-	return fmt.Sprintf("%v", n.Value)
+	switch v := n.Value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return formatFloat(v)
+	default:
+		return fmt.Sprintf("%v", n.Value)
+	}
 }
 
 func (n Number) IsInteger() bool {
@@ -347,32 +361,27 @@ func (n Nil) String() string {
 type List struct {
 	head Value
 	tail *List
+	pos  *Position // Where the reader found the list, if it came from source
+
+	quiet    map[Symbol]bool // Names the source marked ^:no-shadow-warning
+	comments *FormComments   // Comments around the list, if the parser kept them
 }
 
-func (l *List) String() string {
-	if l == nil {
-		return "()"
+// GetPosition returns where the list was read, or the zero Position
+func (l *List) GetPosition() Position {
+	if l == nil || l.pos == nil {
+		return Position{}
 	}
+	return *l.pos
+}
 
-	result := "("
-	current := l
-	first := true
-
-	for current != nil {
-		if !first {
-			result += " "
-		}
-		if current.head != nil {
-			result += current.head.String()
-		} else {
-			result += "nil"
-		}
-		current = current.tail
-		first = false
-	}
+// SetPosition records where the list was read
+func (l *List) SetPosition(pos Position) {
+	l.pos = &pos
+}
 
-	result += ")"
-	return result
+func (l *List) String() string {
+	return printString(l)
 }
 
 func (l *List) IsEmpty() bool {
@@ -396,18 +405,11 @@ func (l *List) Rest() *List {
 // Vector represents an indexed collection
 type Vector struct {
 	elements []Value
+	quiet    map[Symbol]bool // Names the source marked ^:no-shadow-warning
 }
 
 func (v *Vector) String() string {
-	result := "["
-	for i, elem := range v.elements {
-		if i > 0 {
-			result += " "
-		}
-		result += elem.String()
-	}
-	result += "]"
-	return result
+	return printString(v)
 }
 
 func (v *Vector) Get(index int) Value {
@@ -421,43 +423,133 @@ func (v *Vector) Count() int {
 	return len(v.elements)
 }
 
-// HashMap represents a key-value mapping
+// Call makes vectors callable as functions of their indices
+func (v *Vector) Call(args []Value, env *Environment) (Value, error) {
+	if len(args) != 1 {
+		return nil, NewArityError("vector expects 1 argument, got %d", len(args))
+	}
+	index, ok := args[0].(Number)
+	if !ok || !index.IsInteger() {
+		return nil, NewTypeError("vector index must be an integer, got %s", args[0])
+	}
+	if i := index.ToInt(); i < 0 || i >= int64(len(v.elements)) {
+		return nil, NewRuntimeError("vector index %d out of bounds for length %d", i, len(v.elements))
+	}
+	return v.elements[index.ToInt()], nil
+}
+
+// HashMap represents a key-value mapping. Keys are printed and iterated in
+// insertion order, or in sorted order for maps made by sorted-map
 type HashMap struct {
-	pairs map[string]Value
-	keys  []Value // Maintain insertion order
+	entries map[uint64][]mapEntry // Entries by the Hash of their key
+	keys    []Value               // Maintain insertion order
+	sorted  bool                  // Keep keys ordered by compareKeys instead
 }
 
 func (h *HashMap) String() string {
-	result := "{"
-	first := true
-	for _, key := range h.keys {
-		if !first {
-			result += " "
-		}
-		result += key.String() + " " + h.pairs[h.keyToString(key)].String()
-		first = false
-	}
-	result += "}"
-	return result
+	return printString(h)
 }
 
-func (h *HashMap) keyToString(key Value) string {
-	return key.String()
+// lookup finds the entry of key: its hash, and its index in the entries
+// with that hash or -1
+func (h *HashMap) lookup(key Value) (uint64, int) {
+	hash := Hash(key)
+	for i, entry := range h.entries[hash] {
+		if valuesEqual(entry.key, key) {
+			return hash, i
+		}
+	}
+	return hash, -1
 }
 
 func (h *HashMap) Get(key Value) Value {
-	if value, exists := h.pairs[h.keyToString(key)]; exists {
-		return value
+	if hash, i := h.lookup(key); i >= 0 {
+		return h.entries[hash][i].value
 	}
 	return Nil{}
 }
 
 func (h *HashMap) Set(key Value, value Value) {
-	keyStr := h.keyToString(key)
-	if _, exists := h.pairs[keyStr]; !exists {
+	hash, i := h.lookup(key)
+	if i >= 0 {
+		h.entries[hash][i].value = value
+		return
+	}
+	h.entries[hash] = append(h.entries[hash], mapEntry{key, value})
+	if h.sorted {
+		i := sort.Search(len(h.keys), func(i int) bool { return compareKeys(h.keys[i], key) > 0 })
+		h.keys = append(h.keys, nil)
+		copy(h.keys[i+1:], h.keys[i:])
+		h.keys[i] = key
+	} else {
 		h.keys = append(h.keys, key)
 	}
-	h.pairs[keyStr] = value
+}
+
+// removeIf removes the entries whose keys match, in place, for maps that
+// aren't shared as values
+func (h *HashMap) removeIf(match func(key Value) bool) {
+	kept := h.keys[:0]
+	for _, key := range h.keys {
+		if !match(key) {
+			kept = append(kept, key)
+			continue
+		}
+		hash, i := h.lookup(key)
+		h.entries[hash] = append(h.entries[hash][:i], h.entries[hash][i+1:]...)
+		if len(h.entries[hash]) == 0 {
+			delete(h.entries, hash)
+		}
+	}
+	clear(h.keys[len(kept):])
+	h.keys = kept
+}
+
+// empty returns a new map that orders its keys the way h does
+func (h *HashMap) empty() *HashMap {
+	m := NewHashMap()
+	m.sorted = h.sorted
+	return m
+}
+
+// compareKeys orders sorted-map keys: numbers numerically, then strings,
+// keywords and symbols by name, then anything else by its printed form
+func compareKeys(a, b Value) int {
+	rank := func(v Value) int {
+		switch v.(type) {
+		case Nil:
+			return 0
+		case Number, Decimal:
+			return 1
+		case String:
+			return 2
+		case Keyword:
+			return 3
+		case Symbol:
+			return 4
+		default:
+			return 5
+		}
+	}
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	if hasDecimal([]Value{a, b}) {
+		order, _ := compareNumeric("compare", a, b)
+		return order
+	}
+	if na, ok := a.(Number); ok {
+		nb := b.(Number)
+		switch fa, fb := na.ToFloat(), nb.ToFloat(); {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		default:
+			return 0
+		}
+	}
+	return strings.Compare(a.String(), b.String())
 }
 
 func (h *HashMap) Count() int {
@@ -465,92 +557,155 @@ func (h *HashMap) Count() int {
 }
 
 func (h *HashMap) ContainsKey(key Value) bool {
-	_, exists := h.pairs[h.keyToString(key)]
-	return exists
+	_, i := h.lookup(key)
+	return i >= 0
+}
+
+// Call makes hash-maps callable as functions of their keys, with an
+// optional default for missing keys
+func (h *HashMap) Call(args []Value, env *Environment) (Value, error) {
+	if len(args) < 1 || len(args) > 2 {
+		return nil, NewArityError("hash-map expects 1-2 arguments, got %d", len(args))
+	}
+	if !h.ContainsKey(args[0]) && len(args) == 2 {
+		return args[1], nil
+	}
+	return h.Get(args[0]), nil
 }
 
 // Set represents a collection of unique values
 type Set struct {
-	elements map[string]Value
-	order    []Value // Maintain insertion order
+	elements map[uint64][]Value // Elements by their Hash
+	order    []Value            // Maintain insertion order
 }
 
 func (s *Set) String() string {
-	result := "#{"
-	first := true
-	for _, elem := range s.order {
-		if !first {
-			result += " "
-		}
-		result += elem.String()
-		first = false
-	}
-	result += "}"
-	return result
+	return printString(s)
 }
 
-func (s *Set) elemToString(elem Value) string {
-	return elem.String()
+// lookup finds elem: its hash, and its index in the elements with that
+// hash or -1
+func (s *Set) lookup(elem Value) (uint64, int) {
+	hash := Hash(elem)
+	for i, e := range s.elements[hash] {
+		if valuesEqual(e, elem) {
+			return hash, i
+		}
+	}
+	return hash, -1
 }
 
 func (s *Set) Add(elem Value) {
-	elemStr := s.elemToString(elem)
-	if _, exists := s.elements[elemStr]; !exists {
-		s.elements[elemStr] = elem
+	if hash, i := s.lookup(elem); i < 0 {
+		s.elements[hash] = append(s.elements[hash], elem)
 		s.order = append(s.order, elem)
 	}
 }
 
 func (s *Set) Contains(elem Value) bool {
-	_, exists := s.elements[s.elemToString(elem)]
-	return exists
+	_, i := s.lookup(elem)
+	return i >= 0
 }
 
 func (s *Set) Count() int {
 	return len(s.order)
 }
 
+// Call makes sets callable as membership tests, returning the element or nil
+func (s *Set) Call(args []Value, env *Environment) (Value, error) {
+	if len(args) != 1 {
+		return nil, NewArityError("set expects 1 argument, got %d", len(args))
+	}
+	if hash, i := s.lookup(args[0]); i >= 0 {
+		return s.elements[hash][i], nil
+	}
+	return Nil{}, nil
+}
+
 func (s *Set) Remove(elem Value) {
-	elemStr := s.elemToString(elem)
-	if _, exists := s.elements[elemStr]; exists {
-		delete(s.elements, elemStr)
-		// Remove from order slice
-		for i, e := range s.order {
-			if s.elemToString(e) == elemStr {
-				s.order = append(s.order[:i], s.order[i+1:]...)
-				break
-			}
+	hash, i := s.lookup(elem)
+	if i < 0 {
+		return
+	}
+	stored := s.elements[hash][i]
+	s.elements[hash] = append(s.elements[hash][:i], s.elements[hash][i+1:]...)
+	if len(s.elements[hash]) == 0 {
+		delete(s.elements, hash)
+	}
+	// Remove from order slice
+	for j, e := range s.order {
+		if valuesEqual(e, stored) {
+			s.order = append(s.order[:j], s.order[j+1:]...)
+			break
 		}
 	}
 }
 
 // Environment represents a lexical environment for variable bindings
 type Environment struct {
-	bindings map[Symbol]Value
-	parent   *Environment
+	mu         sync.RWMutex // Guards bindings, which scheduled jobs may read concurrently
+	bindings   map[Symbol]Value
+	parent     *Environment
+	calls      *callFrame        // The user function call evaluating in this environment
+	modules    *moduleRegistry   // Namespaces loaded with require (root only)
+	tests      *testRegistry     // Tests defined with deftest (root only)
+	vars       *varRegistry      // Vars and redefinition hooks (root only)
+	plugins    *pluginRegistry   // Plugins loaded with LoadPlugin (root only)
+	functions  *functionRegistry // Where each binding came from (root only)
+	moduleDefs *moduleTable      // Modules defined with module (root only)
+	interp     *interpreterState // Gensym counter and random numbers (root only)
 }
 
 func NewEnvironment(parent *Environment) *Environment {
-	return &Environment{
+	env := &Environment{
 		bindings: make(map[Symbol]Value),
 		parent:   parent,
 	}
+	if parent != nil {
+		env.calls = parent.calls
+	}
+	return env
 }
 
 func (env *Environment) Get(sym Symbol) (Value, error) {
-	if value, exists := env.bindings[sym]; exists {
-		return value, nil
-	}
-
-	if env.parent != nil {
-		return env.parent.Get(sym)
+	for current := env; current != nil; current = current.parent {
+		// Bindings made with binding hide root bindings, but not locals
+		if current.parent == nil && env.calls != nil && env.calls.dynamic != nil {
+			if value, exists := env.calls.dynamic.lookup(sym); exists {
+				return value, nil
+			}
+		}
+		if value, exists := current.binding(sym); exists {
+			return value, nil
+		}
 	}
 
 	return nil, NewNameError("undefined symbol: %s", sym)
 }
 
 func (env *Environment) Set(sym Symbol, value Value) {
+	env.mu.Lock()
 	env.bindings[sym] = value
+	env.mu.Unlock()
+}
+
+// binding returns the value bound to sym in env itself, ignoring parents
+func (env *Environment) binding(sym Symbol) (Value, bool) {
+	env.mu.RLock()
+	value, exists := env.bindings[sym]
+	env.mu.RUnlock()
+	return value, exists
+}
+
+// snapshot returns a copy of env's own bindings
+func (env *Environment) snapshot() map[Symbol]Value {
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	result := make(map[Symbol]Value, len(env.bindings))
+	for name, value := range env.bindings {
+		result[name] = value
+	}
+	return result
 }
 
 // GetAllSymbols returns all symbols defined in this environment and its parents
@@ -560,7 +715,7 @@ func (env *Environment) GetAllSymbols() []string {
 	// Collect symbols from this environment and all parent environments
 	current := env
 	for current != nil {
-		for sym := range current.bindings {
+		for sym := range current.snapshot() {
 			symbols[string(sym)] = true
 		}
 		current = current.parent
@@ -595,11 +750,18 @@ func NewVector(elements ...Value) *Vector {
 
 func NewHashMap() *HashMap {
 	return &HashMap{
-		pairs: make(map[string]Value),
-		keys:  make([]Value, 0),
+		entries: make(map[uint64][]mapEntry),
+		keys:    make([]Value, 0),
 	}
 }
 
+// NewSortedMap creates an empty map whose keys stay sorted
+func NewSortedMap() *HashMap {
+	m := NewHashMap()
+	m.sorted = true
+	return m
+}
+
 func NewHashMapWithPairs(pairs ...Value) *HashMap {
 	hm := NewHashMap()
 	for i := 0; i < len(pairs)-1; i += 2 {
@@ -610,7 +772,7 @@ func NewHashMapWithPairs(pairs ...Value) *HashMap {
 
 func NewSet() *Set {
 	return &Set{
-		elements: make(map[string]Value),
+		elements: make(map[uint64][]Value),
 		order:    make([]Value, 0),
 	}
 }
@@ -627,28 +789,45 @@ func NewNumber(value any) Number {
 	return Number{Value: value}
 }
 
-// Intern table for symbols
-var internTable = make(map[string]Symbol)
+// Intern tables for symbols and keywords, shared by all interpreters
+var (
+	internMu           sync.RWMutex
+	internTable        = make(map[string]Symbol)
+	keywordInternTable = make(map[string]Keyword)
+)
 
 // Intern ensures symbol uniqueness
 func Intern(name string) Symbol {
+	internMu.RLock()
+	sym, exists := internTable[name]
+	internMu.RUnlock()
+	if exists {
+		return sym
+	}
+	internMu.Lock()
+	defer internMu.Unlock()
 	if sym, exists := internTable[name]; exists {
 		return sym
 	}
-	sym := Symbol(name)
+	sym = Symbol(name)
 	internTable[name] = sym
 	return sym
 }
 
-// Keyword intern table
-var keywordInternTable = make(map[string]Keyword)
-
 // InternKeyword ensures keyword uniqueness
 func InternKeyword(name string) Keyword {
+	internMu.RLock()
+	kw, exists := keywordInternTable[name]
+	internMu.RUnlock()
+	if exists {
+		return kw
+	}
+	internMu.Lock()
+	defer internMu.Unlock()
 	if kw, exists := keywordInternTable[name]; exists {
 		return kw
 	}
-	kw := Keyword(name)
+	kw = Keyword(name)
 	keywordInternTable[name] = kw
 	return kw
 }
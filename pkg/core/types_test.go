@@ -1,6 +1,7 @@
 package core_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/leinonen/go-lisp/pkg/core"
@@ -241,6 +242,32 @@ func TestHashMap(t *testing.T) {
 	}
 }
 
+func TestHashMapOrder(t *testing.T) {
+	// Hash-maps print in insertion order, whatever Go's map iteration does
+	for i := 0; i < 20; i++ {
+		hm := core.NewHashMap()
+		for _, name := range []string{"zeta", "alpha", "mid", "beta", "omega"} {
+			hm.Set(core.InternKeyword(name), core.NewNumber(int64(len(name))))
+		}
+		hm.Set(core.InternKeyword("alpha"), core.NewNumber(int64(0)))
+		if expected := "{:zeta 4 :alpha 0 :mid 3 :beta 4 :omega 5}"; hm.String() != expected {
+			t.Fatalf("Expected %s, got %s", expected, hm.String())
+		}
+	}
+
+	// Sorted maps print in key order however they were built
+	sm := core.NewSortedMap()
+	for _, key := range []core.Value{
+		core.InternKeyword("b"), core.NewNumber(int64(10)), core.String("x"),
+		core.NewNumber(2.5), core.InternKeyword("a"), core.NewNumber(int64(-1)),
+	} {
+		sm.Set(key, core.Nil{})
+	}
+	if expected := `{-1 nil 2.5 nil 10 nil "x" nil :a nil :b nil}`; sm.String() != expected {
+		t.Errorf("Expected %s, got %s", expected, sm.String())
+	}
+}
+
 func TestSet(t *testing.T) {
 	// Test empty set
 	emptySet := core.NewSet()
@@ -378,3 +405,85 @@ func TestValueInterface(t *testing.T) {
 		_ = val.String()
 	}
 }
+
+func TestValueHash(t *testing.T) {
+	read := func(input string) core.Value {
+		t.Helper()
+		value, err := core.ReadString(input)
+		if err != nil {
+			t.Fatalf("Parse error for %q: %v", input, err)
+		}
+		return value
+	}
+
+	// Values that are = must hash alike
+	equal := [][2]core.Value{
+		{core.NewNumber(int64(1)), core.NewNumber(1.0)},
+		{core.NewNumber(int64(2)), read("2.00M")},
+		{read("1.50M"), read("1.5M")},
+		{read("(1 [2 3])"), read("[1 (2 3)]")},
+		{read("{:a 1 :b 2}"), read("{:b 2 :a 1}")},
+		{core.NewSetWithElements(core.String("x"), core.Nil{}), core.NewSetWithElements(core.Nil{}, core.String("x"))},
+	}
+	for _, pair := range equal {
+		if !pair[0].(core.Hasher).Equals(pair[1]) {
+			t.Errorf("Expected %s to equal %s", pair[0], pair[1])
+		}
+		if core.Hash(pair[0]) != core.Hash(pair[1]) {
+			t.Errorf("Expected %s and %s to hash alike", pair[0], pair[1])
+		}
+	}
+
+	// Keys that print alike but are not = stay apart
+	atom1, atom2 := core.NewAtom(core.NewNumber(int64(0))), core.NewAtom(core.NewNumber(int64(0)))
+	m := core.NewHashMapWithPairs(
+		core.String("a"), core.NewNumber(int64(1)),
+		core.Symbol("a"), core.NewNumber(int64(2)),
+		atom1, core.NewNumber(int64(3)),
+		atom2, core.NewNumber(int64(4)),
+	)
+	if m.Count() != 4 {
+		t.Fatalf("Expected 4 keys, got %d: %s", m.Count(), m)
+	}
+	if got := m.Get(atom2); got.String() != "4" {
+		t.Errorf("Expected the second atom to map to 4, got %s", got)
+	}
+	if got := m.Get(read("a")); got.String() != "2" {
+		t.Errorf("Expected the symbol a to map to 2, got %s", got)
+	}
+
+	// Equal keys are one key
+	m.Set(core.NewNumber(1.0), core.String("float"))
+	m.Set(core.NewNumber(int64(1)), core.String("int"))
+	if got := m.Get(core.NewNumber(1.0)); got.String() != `"int"` || m.Count() != 5 {
+		t.Errorf("Expected 1 and 1.0 to be one key, got %s", m)
+	}
+}
+
+func TestTypeName(t *testing.T) {
+	tests := []struct {
+		value    core.Value
+		expected string
+	}{
+		{core.NewNumber(int64(1)), "integer"},
+		{core.NewNumber(1.5), "float"},
+		{core.String("s"), "string"},
+		{core.Symbol("true"), "boolean"},
+		{core.Nil{}, "nil"},
+		{core.NewVector(), "vector"},
+		{core.NewHashMap(), "hash-map"},
+		{core.NewAtom(core.Nil{}), "atom"},
+	}
+	for _, test := range tests {
+		if got := core.TypeName(test.value); got != test.expected {
+			t.Errorf("Expected %s to be a %s, got %s", test.value, test.expected, got)
+		}
+	}
+
+	// Type errors name the type the way Lisp code sees it
+	expr, _ := core.ReadString(`(swap! "a" +)`)
+	_, err := core.Eval(expr, core.NewCoreEnvironment())
+	if err == nil || !strings.Contains(err.Error(), "got string") {
+		t.Errorf("Expected a type error naming the string type, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Strings are indexed by rune, so count, nth and substring work on
+// characters rather than bytes. Characters a reader sees as one, like an
+// accented letter written with a combining mark or a flag emoji, are
+// grapheme clusters, and string-normalize converts between the composed
+// (NFC) and decomposed (NFD) ways of writing them.
+
+// normalizationTables are parsed from unicode_tables.go on first use
+var normalizationTables struct {
+	once      sync.Once
+	decompose map[rune][]rune
+	compose   map[[2]rune]rune
+	classes   map[rune]uint8
+}
+
+func loadNormalizationTables() {
+	t := &normalizationTables
+	t.decompose = make(map[rune][]rune)
+	t.compose = make(map[[2]rune]rune)
+	t.classes = make(map[rune]uint8)
+
+	hex := func(text string) rune {
+		n, _ := strconv.ParseUint(text, 16, 32)
+		return rune(n)
+	}
+	for _, entry := range strings.Fields(canonicalDecompositionData) {
+		char, parts, _ := strings.Cut(entry, "=")
+		primary := strings.HasSuffix(char, "*")
+		r := hex(strings.TrimSuffix(char, "*"))
+		var decomposed []rune
+		for _, part := range strings.Split(parts, "+") {
+			decomposed = append(decomposed, hex(part))
+		}
+		t.decompose[r] = decomposed
+		if primary {
+			t.compose[[2]rune{decomposed[0], decomposed[1]}] = r
+		}
+	}
+	for _, entry := range strings.Fields(combiningClassData) {
+		chars, class, _ := strings.Cut(entry, ":")
+		first, last, isRange := strings.Cut(chars, "-")
+		if !isRange {
+			last = first
+		}
+		n, _ := strconv.Atoi(class)
+		for r := hex(first); r <= hex(last); r++ {
+			t.classes[r] = uint8(n)
+		}
+	}
+}
+
+// Hangul syllables are composed from leading consonant, vowel and optional
+// trailing consonant jamo arithmetically
+const (
+	hangulBase     = 0xAC00
+	hangulLBase    = 0x1100
+	hangulVBase    = 0x1161
+	hangulTBase    = 0x11A7
+	hangulVCount   = 21
+	hangulTCount   = 28
+	hangulNCount   = hangulVCount * hangulTCount
+	hangulSCount   = 19 * hangulNCount
+	hangulLastJamo = hangulLBase + 19
+)
+
+func combiningClass(r rune) uint8 {
+	return normalizationTables.classes[r]
+}
+
+// decomposeRune appends the full canonical decomposition of r to out
+func decomposeRune(out []rune, r rune) []rune {
+	if s := r - hangulBase; s >= 0 && s < hangulSCount {
+		out = append(out, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			out = append(out, hangulTBase+t)
+		}
+		return out
+	}
+	parts, ok := normalizationTables.decompose[r]
+	if !ok {
+		return append(out, r)
+	}
+	for _, part := range parts {
+		out = decomposeRune(out, part)
+	}
+	return out
+}
+
+// composePair returns the character that a followed by b compose to
+func composePair(a, b rune) (rune, bool) {
+	if a >= hangulLBase && a < hangulLastJamo && b >= hangulVBase && b < hangulVBase+hangulVCount {
+		return hangulBase + ((a-hangulLBase)*hangulVCount+(b-hangulVBase))*hangulTCount, true
+	}
+	if s := a - hangulBase; s >= 0 && s < hangulSCount && s%hangulTCount == 0 && b > hangulTBase && b < hangulTBase+hangulTCount {
+		return a + (b - hangulTBase), true
+	}
+	r, ok := normalizationTables.compose[[2]rune{a, b}]
+	return r, ok
+}
+
+// normalizeNFD decomposes every character and puts combining marks in
+// canonical order
+func normalizeNFD(s string) []rune {
+	normalizationTables.once.Do(loadNormalizationTables)
+	var out []rune
+	for _, r := range s {
+		out = decomposeRune(out, r)
+	}
+	// Sort each run of combining marks by class, keeping equal classes in order
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0; j-- {
+			class, prev := combiningClass(out[j]), combiningClass(out[j-1])
+			if class == 0 || prev <= class {
+				break
+			}
+			out[j], out[j-1] = out[j-1], out[j]
+		}
+	}
+	return out
+}
+
+// normalizeNFC decomposes s and then composes each combining mark with the
+// preceding starter unless another mark of the same or a lower class is in
+// between
+func normalizeNFC(s string) []rune {
+	decomposed := normalizeNFD(s)
+	out := make([]rune, 0, len(decomposed))
+	starter, lastClass := -1, -1 // lastClass is -1 right after the starter
+	for _, r := range decomposed {
+		class := int(combiningClass(r))
+		if starter >= 0 && (lastClass == -1 || lastClass < class) {
+			if composed, ok := composePair(out[starter], r); ok {
+				out[starter] = composed
+				continue
+			}
+		}
+		if class == 0 {
+			starter, lastClass = len(out), -1
+		} else {
+			lastClass = class
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// normalizeString converts s to the normalization form named by form
+func normalizeString(s string, form Keyword) (string, error) {
+	switch form {
+	case "nfc":
+		return string(normalizeNFC(s)), nil
+	case "nfd":
+		return string(normalizeNFD(s)), nil
+	}
+	return "", NewRuntimeError("string-normalize supports :nfc and :nfd, got %s", form)
+}
+
+// graphemes splits s into the characters a reader sees, following the
+// Unicode segmentation rules closely enough for combining marks, Hangul,
+// emoji modifier and ZWJ sequences and flags
+func graphemes(s string) []string {
+	var clusters []string
+	var current []rune
+	regional := 0 // Regional indicators in the current run, pairs make a flag
+	for _, r := range s {
+		if len(current) > 0 && !joinsGrapheme(current[len(current)-1], r, regional) {
+			clusters = append(clusters, string(current))
+			current = current[:0]
+		}
+		if unicode.Is(unicode.Regional_Indicator, r) {
+			regional++
+		} else {
+			regional = 0
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		clusters = append(clusters, string(current))
+	}
+	return clusters
+}
+
+const zeroWidthJoiner = 0x200D
+
+// joinsGrapheme reports whether r continues the cluster ending in prev,
+// where regional counts the regional indicators up to prev
+func joinsGrapheme(prev, r rune, regional int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case unicode.IsControl(prev) || unicode.IsControl(r):
+		return false
+	case hangulJoins(prev, r):
+		return true
+	case r == zeroWidthJoiner || unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) || isEmojiModifier(r):
+		return true
+	case prev == zeroWidthJoiner && isPictographic(r):
+		return true
+	case unicode.Is(unicode.Regional_Indicator, r):
+		return regional%2 == 1
+	}
+	return false
+}
+
+// hangulJoins applies the rules that keep a syllable's jamo together
+func hangulJoins(prev, r rune) bool {
+	isL := func(r rune) bool { return r >= 0x1100 && r <= 0x115F || r >= 0xA960 && r <= 0xA97C }
+	isV := func(r rune) bool { return r >= 0x1160 && r <= 0x11A7 || r >= 0xD7B0 && r <= 0xD7C6 }
+	isT := func(r rune) bool { return r >= 0x11A8 && r <= 0x11FF || r >= 0xD7CB && r <= 0xD7FB }
+	syllable := r >= hangulBase && r < hangulBase+hangulSCount
+	prevLV := prev >= hangulBase && prev < hangulBase+hangulSCount && (prev-hangulBase)%hangulTCount == 0
+	prevLVT := prev >= hangulBase && prev < hangulBase+hangulSCount && !prevLV
+
+	switch {
+	case isL(prev):
+		return isL(r) || isV(r) || syllable
+	case prevLV || isV(prev):
+		return isV(r) || isT(r)
+	case prevLVT || isT(prev):
+		return isT(r)
+	}
+	return false
+}
+
+func isEmojiModifier(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isPictographic approximates the Extended_Pictographic property, which Go's
+// unicode package lacks
+func isPictographic(r rune) bool {
+	return r >= 0x1F000 && r <= 0x1FAFF || r >= 0x2600 && r <= 0x27BF
+}
+
+// reverseGraphemes reverses s without separating combining marks, emoji
+// sequences or flags from their characters
+func reverseGraphemes(s string) string {
+	clusters := graphemes(s)
+	var out strings.Builder
+	for i := len(clusters) - 1; i >= 0; i-- {
+		out.WriteString(clusters[i])
+	}
+	return out.String()
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRuneAwareStrings(t *testing.T) {
+	env := NewCoreEnvironment()
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(count \"h\u00e9llo\")", "5"},
+		{"(string-length \"\u65e5\u672c\u8a9e\")", "3"},
+		{"(nth \"h\u00e9llo\" 1)", "\"\u00e9\""},
+		{"(substring \"\u65e5\u672c\u8a9e\" 1 3)", "\"\u672c\u8a9e\""},
+		{"(substring \"h\u00e9llo\" 1)", "\"\u00e9llo\""},
+		{"(graphemes \"e\u0301a\")", "(\"e\u0301\" \"a\")"},
+		{"(count (graphemes \"\U0001F44D\U0001F3FD\U0001F1EB\U0001F1EE\U0001F468\u200d\U0001F469\"))", "3"},
+		{"(graphemes \"\u1100\u1161\u11a8\uac00\")", "(\"\u1100\u1161\u11a8\" \"\uac00\")"},
+		{"(string-reverse \"noe\u0308l\")", "\"le\u0308on\""},
+		{"(string-reverse \"\U0001F1EB\U0001F1EE\U0001F1F8\U0001F1EA\")", "\"\U0001F1F8\U0001F1EA\U0001F1EB\U0001F1EE\""},
+		{"(string-normalize \"e\u0301\")", "\"\u00e9\""},
+		{"(count (string-normalize \"\u00e9\" :nfd))", "2"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For %+q, expected %+q, got %+q", test.input, test.expected, result)
+		}
+	}
+
+	expr, _ := ReadString(`(string-normalize "x" :nfkc)`)
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "supports :nfc and :nfd") {
+		t.Errorf("Expected an unsupported form error, got %v", err)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	tests := []struct {
+		input, nfc, nfd string
+	}{
+		{"\u00e9", "\u00e9", "e\u0301"},
+		{"e\u0301", "\u00e9", "e\u0301"},
+		{"s\u0307\u0323", "\u1e69", "s\u0323\u0307"}, // Marks are put in canonical order
+		{"\u212b", "\u00c5", "A\u030a"},              // The Angstrom sign is a singleton
+		{"\u0344", "\u0308\u0301", "\u0308\u0301"},   // Excluded from composition
+		{"\uac01", "\uac01", "\u1100\u1161\u11a8"},
+		{"\u1100\u1161\u11a8", "\uac01", "\u1100\u1161\u11a8"},
+		{"a\u0301\u0301", "\u00e1\u0301", "a\u0301\u0301"}, // The second mark is blocked
+		{"plain ascii", "plain ascii", "plain ascii"},
+	}
+	for _, test := range tests {
+		if nfc, _ := normalizeString(test.input, "nfc"); nfc != test.nfc {
+			t.Errorf("NFC of %+q: expected %+q, got %+q", test.input, test.nfc, nfc)
+		}
+		if nfd, _ := normalizeString(test.input, "nfd"); nfd != test.nfd {
+			t.Errorf("NFD of %+q: expected %+q, got %+q", test.input, test.nfd, nfd)
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package core
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// Hasher is implemented by values compared by their contents: numbers,
+// strings, symbols, keywords, collections and the like. Hash-maps and sets
+// key on Hash and Equals, and = uses Equals. Values that are Equals must
+// have the same Hash.
+type Hasher interface {
+	Value
+	Hash() uint64
+	Equals(other Value) bool
+}
+
+// TypeNamer is implemented by values that name their own type for
+// TypeName, such as those defined by plugins
+type TypeNamer interface {
+	TypeName() string
+}
+
+// Hash returns the hash of value: from its contents for a Hasher, and from
+// its identity for references like atoms and functions
+func Hash(value Value) uint64 {
+	if value == nil {
+		return 0
+	}
+	if h, ok := value.(Hasher); ok {
+		return h.Hash()
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice, reflect.UnsafePointer:
+		return mixHash(hashKind('&'), uint64(v.Pointer()))
+	}
+	return hashText('?', fmt.Sprintf("%T %s", value, value))
+}
+
+// TypeName returns a short, user-facing name for the type of value, like
+// "integer" or "hash-map", for type and error messages
+func TypeName(value Value) string {
+	if named, ok := value.(TypeNamer); ok {
+		return named.TypeName()
+	}
+	switch v := value.(type) {
+	case nil, Nil:
+		return "nil"
+	case Number:
+		if v.IsFloat() {
+			return "float"
+		}
+		return "integer"
+	case Decimal:
+		return "decimal"
+	case String:
+		return "string"
+	case Symbol:
+		if v == "true" {
+			return "boolean"
+		}
+		return "symbol"
+	case Keyword:
+		return "keyword"
+	case *List:
+		return "list"
+	case *LazySeq:
+		return "lazy-seq"
+	case *Var:
+		return "var"
+	case *Atom:
+		return "atom"
+	case *Ref:
+		return "ref"
+	case *Delay:
+		return "delay"
+	case *Reduced:
+		return "reduced"
+	case *WeakRef:
+		return "weak-ref"
+	case *Vector:
+		return "vector"
+	case *HashMap:
+		return "hash-map"
+	case *Set:
+		return "set"
+	case Inst:
+		return "inst"
+	case UUID:
+		return "uuid"
+	case Duration:
+		return "duration"
+	case *Matrix:
+		return "matrix"
+	case *Macro:
+		return "macro"
+	case Callable:
+		return "function"
+	default:
+		// Other Go types by their name, like http-server for *HTTPServer
+		t := reflect.TypeOf(value)
+		if t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Name() == "" {
+			return fmt.Sprintf("%T", value)
+		}
+		return kebabCase(t.Name())
+	}
+}
+
+// Hash kinds keep values of different types that share a representation,
+// like the string "a" and the symbol a, apart
+func hashKind(kind byte) uint64 {
+	return mixHash(14695981039346656037, uint64(kind))
+}
+
+func mixHash(h, x uint64) uint64 {
+	h ^= x
+	h *= 1099511628211
+	return h ^ h>>29
+}
+
+func hashText(kind byte, text string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte{kind})
+	h.Write([]byte(text))
+	return h.Sum64()
+}
+
+// hashFloat hashes a number by value, so that 1 and 1.0, which are =, hash
+// alike
+func hashFloat(f float64) uint64 {
+	if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+		return mixHash(hashKind('n'), uint64(int64(f)))
+	}
+	return mixHash(hashKind('n'), math.Float64bits(f))
+}
+
+// hashUnordered combines the hashes of map entries or set elements, whose
+// order does not matter
+func hashUnordered(kind byte, hashes []uint64) uint64 {
+	var sum uint64
+	for _, h := range hashes {
+		sum += h
+	}
+	return mixHash(hashKind(kind), sum)
+}
+
+// hashSequence hashes the elements of a list, vector or lazy sequence in
+// order, alike for all three since they are = with the same elements
+func hashSequence(seq Value) uint64 {
+	h := hashKind('(')
+	if _, lazy := seq.(*LazySeq); !lazy {
+		items, _ := collectionToSlice(seq)
+		for _, item := range items {
+			h = mixHash(h, Hash(item))
+		}
+		return h
+	}
+	for {
+		first, ok, err := seqFirst(seq)
+		if err != nil || !ok {
+			return h
+		}
+		h = mixHash(h, Hash(first))
+		if seq, err = seqRest(seq); err != nil {
+			return h
+		}
+	}
+}
+
+func (s Symbol) Hash() uint64 { return hashText('s', string(s)) }
+
+func (s Symbol) Equals(other Value) bool {
+	o, ok := other.(Symbol)
+	return ok && s == o
+}
+
+func (k Keyword) Hash() uint64 { return hashText(':', string(k)) }
+
+func (k Keyword) Equals(other Value) bool {
+	o, ok := other.(Keyword)
+	return ok && k == o
+}
+
+func (s String) Hash() uint64 { return hashText('"', string(s)) }
+
+func (s String) Equals(other Value) bool {
+	o, ok := other.(String)
+	return ok && s == o
+}
+
+func (n Nil) Hash() uint64 { return hashKind('0') }
+
+func (n Nil) Equals(other Value) bool {
+	_, ok := other.(Nil)
+	return ok
+}
+
+func (n Number) Hash() uint64 { return hashFloat(n.ToFloat()) }
+
+// Equals compares numbers by value, and an integer with a decimal exactly
+func (n Number) Equals(other Value) bool {
+	switch o := other.(type) {
+	case Number:
+		return n.ToFloat() == o.ToFloat()
+	case Decimal:
+		return n.IsInteger() && o.cmp(decimalFromInt(n.ToInt())) == 0
+	}
+	return false
+}
+
+// Hash hashes a whole decimal like the integer it equals, and any other
+// by its digits without trailing zeros, since 1.50M = 1.5M
+func (d Decimal) Hash() uint64 {
+	unscaled, scale := new(big.Int).Set(d.unscaled), d.scale
+	ten, rem := big.NewInt(10), new(big.Int)
+	for scale > 0 && unscaled.Sign() != 0 {
+		quo, _ := new(big.Int).QuoRem(unscaled, ten, rem)
+		if rem.Sign() != 0 {
+			break
+		}
+		unscaled, scale = quo, scale-1
+	}
+	if scale <= 0 {
+		whole := new(big.Int).Mul(unscaled, pow10(-scale))
+		if whole.IsInt64() {
+			return hashFloat(float64(whole.Int64()))
+		}
+	}
+	return hashText('M', fmt.Sprintf("%se%d", unscaled, scale))
+}
+
+func (d Decimal) Equals(other Value) bool {
+	o, ok := asExactDecimal(other)
+	return ok && d.cmp(o) == 0
+}
+
+func (i Inst) Hash() uint64 { return mixHash(hashKind('@'), uint64(i.Time.UnixNano())) }
+
+func (i Inst) Equals(other Value) bool {
+	o, ok := other.(Inst)
+	return ok && i.Time.Equal(o.Time)
+}
+
+func (d Duration) Hash() uint64 { return mixHash(hashKind('d'), uint64(d.Duration)) }
+
+func (d Duration) Equals(other Value) bool {
+	o, ok := other.(Duration)
+	return ok && d == o
+}
+
+func (m *Matrix) Hash() uint64 {
+	h := mixHash(mixHash(hashKind('m'), uint64(m.rows)), uint64(m.cols))
+	for _, x := range m.data {
+		h = mixHash(h, hashFloat(x))
+	}
+	return h
+}
+
+func (m *Matrix) Equals(other Value) bool {
+	o, ok := other.(*Matrix)
+	if !ok || m.rows != o.rows || m.cols != o.cols {
+		return false
+	}
+	for i := range m.data {
+		if m.data[i] != o.data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *List) Hash() uint64 { return hashSequence(l) }
+
+func (l *List) Equals(other Value) bool { return sequenceEquals(l, other) }
+
+func (v *Vector) Hash() uint64 { return hashSequence(v) }
+
+func (v *Vector) Equals(other Value) bool { return sequenceEquals(v, other) }
+
+func (s *LazySeq) Hash() uint64 { return hashSequence(s) }
+
+func (s *LazySeq) Equals(other Value) bool { return sequenceEquals(s, other) }
+
+// sequenceEquals reports whether other is a list, vector or lazy sequence
+// with the same elements as seq
+func sequenceEquals(seq, other Value) bool {
+	switch other.(type) {
+	case *List, *Vector, *LazySeq:
+		return sequencesEqual(seq, other)
+	}
+	return false
+}
+
+func (h *HashMap) Hash() uint64 {
+	hashes := make([]uint64, 0, len(h.keys))
+	for _, key := range h.keys {
+		hashes = append(hashes, mixHash(Hash(key), Hash(h.Get(key))))
+	}
+	return hashUnordered('{', hashes)
+}
+
+func (h *HashMap) Equals(other Value) bool {
+	o, ok := other.(*HashMap)
+	if !ok || h.Count() != o.Count() {
+		return false
+	}
+	for _, key := range h.keys {
+		if !o.ContainsKey(key) || !valuesEqual(h.Get(key), o.Get(key)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Set) Hash() uint64 {
+	hashes := make([]uint64, 0, len(s.order))
+	for _, elem := range s.order {
+		hashes = append(hashes, Hash(elem))
+	}
+	return hashUnordered('#', hashes)
+}
+
+func (s *Set) Equals(other Value) bool {
+	o, ok := other.(*Set)
+	if !ok || s.Count() != o.Count() {
+		return false
+	}
+	for _, elem := range s.order {
+		if !o.Contains(elem) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVarsFollowRedefinition(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn greet [n] (str "hi " n))
+		(def by-var #'greet)
+		(def by-value greet)
+		(defn greet [n] (str "hello " n))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(by-var "a")`, `"hello a"`},
+		{`(by-value "a")`, `"hi a"`},
+		{`(var greet)`, "#'greet"},
+		{`(@#'greet "b")`, `"hello b"`},
+		{`(do (def n 1) (alter-var-root #'n + 10 100))`, "111"},
+		{`n`, "111"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, errorMatch := range map[string]string{
+		"(var undefined-thing)":      "unable to resolve var: undefined-thing",
+		"(let [x 1] (var x))":        "unable to resolve var: x",
+		"(alter-var-root greet str)": "alter-var-root expects a var",
+		"(add-watch #'greet :k 1)":   "add-watch expects a function",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), errorMatch) {
+			t.Errorf("Expected error containing '%s' for '%s', got: %v", errorMatch, input, err)
+		}
+	}
+}
+
+func TestRedefinitionWatches(t *testing.T) {
+	env := NewCoreEnvironment()
+	var events []string
+	env.Set(Intern("record"), &BuiltinFunction{
+		Name: "record",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			parts := make([]string, len(args))
+			for i, arg := range args {
+				parts[i] = arg.String()
+			}
+			events = append(events, strings.Join(parts, " "))
+			return Nil{}, nil
+		},
+	})
+	var hooked []string
+	env.OnRedefine(func(name Symbol, old, new Value) {
+		hooked = append(hooked, string(name)+" "+old.String()+" -> "+new.String())
+	})
+
+	evalAll(t, env, `
+		(def x 1)
+		(add-watch #'x :a (fn [k v old new] (record k v old new)))
+		(add-watch #'x :b (fn [k v old new] (record k)))
+		(def x 2)
+		(remove-watch #'x :b)
+		(alter-var-root #'x (fn [v] (* v 10)))
+		(def y 1)
+		(let [x 5] (def x 6))`)
+
+	expectedEvents := []string{":a #'x 1 2", ":b", ":a #'x 2 20"}
+	if strings.Join(events, "|") != strings.Join(expectedEvents, "|") {
+		t.Errorf("Expected watch events %q, got %q", expectedEvents, events)
+	}
+	expectedHooks := []string{"x 1 -> 2", "x 2 -> 20"}
+	if strings.Join(hooked, "|") != strings.Join(expectedHooks, "|") {
+		t.Errorf("Expected hook calls %q, got %q", expectedHooks, hooked)
+	}
+}
+
+func TestBindingConveyance(t *testing.T) {
+	env, err := CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	evalAll(t, env, `
+		(def *level* :info)
+		(defn level [] *level*)
+		(def bound (binding [*level* :trace] (bound-fn level)))
+		(def unbound (binding [*level* :trace] (fn [] (level))))
+		(def seen (atom nil))
+		(def job (binding [*level* :job] (every-ms 5 (fn [] (reset! seen (level))))))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"(binding [*level* :debug] (level))", ":debug"},
+		{"(binding [*level* :debug] (binding [*level* :warn] (level)))", ":warn"},
+		{"(level)", ":info"},
+		{"(bound)", ":trace"},
+		{"(binding [*level* :debug] (bound))", ":trace"},
+		{"(unbound)", ":info"},
+		{"(let [*level* 1] (binding [*level* 2] *level*))", "1"},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	// Jobs run on their own goroutine with the bindings they were started in
+	deadline := time.Now().Add(5 * time.Second)
+	for evalAll(t, env, "@seen").String() == "nil" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	evalAll(t, env, "(cancel-job job)")
+	if result := evalAll(t, env, "@seen"); result.String() != ":job" {
+		t.Errorf("Expected the job to see its spawn-time binding, got %s", result)
+	}
+
+	expr, _ := ReadString("(binding [no-such-var 1] nil)")
+	if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), "unable to resolve var: no-such-var") {
+		t.Errorf("Expected an unresolved var error, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package core
+
+import "errors"
+
+// SkipChildren is returned by a Walk function to walk on past the
+// collection it was given without visiting its elements
+var SkipChildren = errors.New("skip children")
+
+// Walk calls fn on value and then, depth first, on everything inside it:
+// the elements of lists, vectors, sets and lazy sequences and the keys and
+// values of hash-maps. An error from fn stops the walk and is returned,
+// except SkipChildren.
+func Walk(value Value, fn func(Value) error) error {
+	if err := fn(value); err != nil {
+		if err == SkipChildren {
+			return nil
+		}
+		return err
+	}
+	if m, ok := value.(*HashMap); ok {
+		for _, key := range m.keys {
+			if err := Walk(key, fn); err != nil {
+				return err
+			}
+			if err := Walk(m.Get(key), fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !isWalkable(value) {
+		return nil
+	}
+	items, err := collectionToSlice(value)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := Walk(item, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkForm rebuilds the collection form with inner applied to each of its
+// elements, or to each key and value of a hash-map, and returns outer of the
+// result. Anything but a collection is given to outer as it is. Lists keep
+// their source position; lazy sequences become lists.
+func WalkForm(form Value, inner, outer func(Value) (Value, error)) (Value, error) {
+	switch f := form.(type) {
+	case *HashMap:
+		result := f.empty()
+		for _, key := range f.keys {
+			k, err := inner(key)
+			if err != nil {
+				return nil, err
+			}
+			v, err := inner(f.Get(key))
+			if err != nil {
+				return nil, err
+			}
+			result.Set(k, v)
+		}
+		return outer(result)
+	case *List, *Vector, *Set, *LazySeq:
+		items, err := collectionToSlice(form)
+		if err != nil {
+			return nil, err
+		}
+		walked := make([]Value, len(items))
+		for i, item := range items {
+			if walked[i], err = inner(item); err != nil {
+				return nil, err
+			}
+		}
+		switch f := form.(type) {
+		case *Vector:
+			return outer(NewVector(walked...))
+		case *Set:
+			return outer(NewSetWithElements(walked...))
+		case *List:
+			list := NewList(walked...)
+			if pos := f.GetPosition(); list != nil && pos.Line > 0 {
+				list.SetPosition(pos)
+			}
+			return outer(list)
+		default:
+			return outer(NewList(walked...))
+		}
+	default:
+		return outer(form)
+	}
+}
+
+// Prewalk replaces each form inside form, starting with form itself, by fn
+// of it, and then walks into what fn returned
+func Prewalk(form Value, fn func(Value) (Value, error)) (Value, error) {
+	replaced, err := fn(form)
+	if err != nil {
+		return nil, err
+	}
+	return WalkForm(replaced, func(v Value) (Value, error) { return Prewalk(v, fn) }, identityForm)
+}
+
+// Postwalk replaces each form inside form by fn of it, innermost first, so
+// fn sees collections whose elements have already been replaced
+func Postwalk(form Value, fn func(Value) (Value, error)) (Value, error) {
+	return WalkForm(form, func(v Value) (Value, error) { return Postwalk(v, fn) }, fn)
+}
+
+func identityForm(v Value) (Value, error) {
+	return v, nil
+}
+
+// isWalkable reports whether Walk goes into value
+func isWalkable(value Value) bool {
+	switch value.(type) {
+	case *List, *Vector, *Set, *LazySeq, *HashMap:
+		return true
+	}
+	return false
+}
+
+// setupWalkOperations adds walk, prewalk, postwalk, keywordize-keys,
+// stringify-keys and deep-merge
+func setupWalkOperations(env *Environment) {
+	// lispFn adapts a Lisp function to the Go walkers
+	lispFn := func(fn Value, env *Environment) func(Value) (Value, error) {
+		return func(v Value) (Value, error) {
+			return callFunction(fn, []Value{v}, env)
+		}
+	}
+
+	env.Set(Intern("walk"), &BuiltinFunction{
+		Name: "walk",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 3 {
+				return nil, NewArityError("walk expects 3 arguments (inner outer form), got %d", len(args))
+			}
+			return WalkForm(args[2], lispFn(args[0], env), lispFn(args[1], env))
+		},
+	})
+
+	env.Set(Intern("prewalk"), &BuiltinFunction{
+		Name: "prewalk",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("prewalk expects 2 arguments (f form), got %d", len(args))
+			}
+			return Prewalk(args[1], lispFn(args[0], env))
+		},
+	})
+
+	env.Set(Intern("postwalk"), &BuiltinFunction{
+		Name: "postwalk",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("postwalk expects 2 arguments (f form), got %d", len(args))
+			}
+			return Postwalk(args[1], lispFn(args[0], env))
+		},
+	})
+
+	env.Set(Intern("keywordize-keys"), &BuiltinFunction{
+		Name: "keywordize-keys",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("keywordize-keys expects 1 argument, got %d", len(args))
+			}
+			return Postwalk(args[0], renameKeys(func(key Value) Value {
+				if s, ok := key.(String); ok {
+					return InternKeyword(string(s))
+				}
+				return key
+			}))
+		},
+	})
+
+	env.Set(Intern("stringify-keys"), &BuiltinFunction{
+		Name: "stringify-keys",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("stringify-keys expects 1 argument, got %d", len(args))
+			}
+			return Postwalk(args[0], renameKeys(func(key Value) Value {
+				if k, ok := key.(Keyword); ok {
+					return String(string(k))
+				}
+				return key
+			}))
+		},
+	})
+
+	env.Set(Intern("deep-merge"), &BuiltinFunction{
+		Name: "deep-merge",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			var result Value = Nil{}
+			for _, arg := range args {
+				if _, ok := arg.(Nil); ok {
+					continue
+				}
+				if _, ok := arg.(*HashMap); !ok {
+					return nil, NewTypeError("deep-merge expects hash-maps, got %s", TypeName(arg))
+				}
+				result = deepMerge(result, arg)
+			}
+			return result, nil
+		},
+	})
+}
+
+// renameKeys returns a Postwalk function that replaces the keys of every
+// hash-map by rename of them
+func renameKeys(rename func(Value) Value) func(Value) (Value, error) {
+	return func(v Value) (Value, error) {
+		m, ok := v.(*HashMap)
+		if !ok {
+			return v, nil
+		}
+		result := m.empty()
+		for _, key := range m.keys {
+			result.Set(rename(key), m.Get(key))
+		}
+		return result, nil
+	}
+}
+
+// deepMerge merges b into a: where both have a hash-map under a key the
+// maps are merged the same way, and otherwise the value from b wins
+func deepMerge(a, b Value) Value {
+	ma, okA := a.(*HashMap)
+	mb, okB := b.(*HashMap)
+	if !okA || !okB {
+		return b
+	}
+	result := ma.empty()
+	for _, key := range ma.keys {
+		result.Set(key, ma.Get(key))
+	}
+	for _, key := range mb.keys {
+		if result.ContainsKey(key) {
+			result.Set(key, deepMerge(result.Get(key), mb.Get(key)))
+		} else {
+			result.Set(key, mb.Get(key))
+		}
+	}
+	return result
+}
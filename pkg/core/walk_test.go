@@ -0,0 +1,105 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWalkFunctions(t *testing.T) {
+	env, err := CreateBootstrappedEnvironment()
+	if err != nil {
+		t.Fatalf("Failed to create bootstrapped environment: %v", err)
+	}
+	evalAll(t, env, `(def visited (atom []))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(postwalk (fn [x] (if (number? x) (* x 10) x)) '[1 (2 {:a 3}) #{4}])`, `[10 (20 {:a 30}) #{40}]`},
+		{`(postwalk (fn [x] (if (vector? x) (count x) x)) [[1 2] [3]])`, `2`},
+		{`(prewalk (fn [x] (if (vector? x) (apply list x) x)) [[1 2] [3]])`, `((1 2) (3))`},
+		{`(walk inc (fn [xs] (apply + xs)) [1 2 3])`, `9`},
+		{`(walk identity identity :leaf)`, `:leaf`},
+		{`(postwalk-replace (hash-map 'x 'y) '(+ x (* x 2)))`, `(+ y (* y 2))`},
+		{`(prewalk-replace {[1] :one} [[1] [2]])`, `[:one [2]]`},
+		{`(do (postwalk (fn [x] (swap! visited conj x) x) '(a (b))) @visited)`, `[a b (b) (a (b))]`},
+		{`(do (reset! visited []) (prewalk (fn [x] (swap! visited conj x) x) '(a (b))) @visited)`, `[(a (b)) a (b) b]`},
+	}
+	for _, test := range tests {
+		if got := evalAll(t, env, test.input).String(); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	form, err := ReadString("(defn f [x] {:doc \"d\"} (let [y x] (g y)))")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	var symbols []string
+	err = Walk(form, func(v Value) error {
+		if m, ok := v.(*HashMap); ok && m.ContainsKey(InternKeyword("doc")) {
+			return SkipChildren
+		}
+		if sym, ok := v.(Symbol); ok {
+			symbols = append(symbols, string(sym))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk failed: %v", err)
+	}
+	if got := strings.Join(symbols, " "); got != "defn f x let y x g y" {
+		t.Errorf("Expected the symbols in order, got %s", got)
+	}
+
+	// Rebuilt lists keep the position the reader gave them
+	renamed, err := Postwalk(form, func(v Value) (Value, error) {
+		if v == Symbol("g") {
+			return Symbol("h"), nil
+		}
+		return v, nil
+	})
+	if err != nil {
+		t.Fatalf("Postwalk failed: %v", err)
+	}
+	if !strings.Contains(renamed.String(), "(h y)") {
+		t.Errorf("Expected g to be replaced, got %s", renamed)
+	}
+	if pos := renamed.(*List).GetPosition(); pos.Line != 1 || pos.Column != 1 {
+		t.Errorf("Expected the rebuilt list at line 1, column 1, got %+v", pos)
+	}
+}
+
+func TestKeyHelpers(t *testing.T) {
+	env := NewCoreEnvironment()
+	// Decoded JSON has string keys
+	evalAll(t, env, `(def payload (hash-map "user" (hash-map "name" "Ada" "tags" (vector (hash-map "id" 1))) "ok" true))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(keywordize-keys payload)`, `{:user {:name "Ada" :tags [{:id 1}]} :ok true}`},
+		{`(stringify-keys (keywordize-keys payload))`, `{"user" {"name" "Ada" "tags" [{"id" 1}]} "ok" true}`},
+		{`(keywordize-keys (list (hash-map "a" (set (hash-map "b" 1))) 2))`, `({:a #{{:b 1}}} 2)`},
+		{`(stringify-keys (hash-map :a 1 'b 2 3 4))`, `{"a" 1 b 2 3 4}`},
+		{`(deep-merge {:db {:host "localhost" :port 5432} :debug false} {:db {:host "prod"}} nil {:debug true})`,
+			`{:db {:host "prod" :port 5432} :debug true}`},
+		{`(deep-merge {:a {:b 1}} {:a 2})`, `{:a 2}`},
+		{`(deep-merge {:a 1} {:a {:b 2}})`, `{:a {:b 2}}`},
+		{`(deep-merge)`, `nil`},
+	}
+	for _, test := range tests {
+		if got := evalAll(t, env, test.input).String(); got != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.input, test.expected, got)
+		}
+	}
+
+	if err := evalSource(t, env, `(deep-merge {:a 1} [1 2])`); err == nil || !strings.Contains(err.Error(), "deep-merge expects hash-maps, got vector") {
+		t.Errorf("Expected a type error for a vector, got %v", err)
+	}
+}
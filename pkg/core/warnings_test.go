@@ -0,0 +1,133 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// withWarnings captures the warnings of env
+func withWarnings(env *Environment, asErrors bool) *bytes.Buffer {
+	var out bytes.Buffer
+	env.SetErrorOutput(&out)
+	env.SetWarningsAsErrors(asErrors)
+	return &out
+}
+
+func evalSource(t *testing.T, env *Environment, source string) error {
+	tokens, err := NewLexer(source).Tokenize()
+	if err != nil {
+		t.Fatalf("Tokenize error: %v", err)
+	}
+	parser := NewParserWithSource(tokens, source)
+	parser.SetFile("main.lisp")
+	forms, err := parser.ParseAll()
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	for _, form := range forms {
+		if _, err := Eval(form, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWarn(t *testing.T) {
+	env := NewCoreEnvironment()
+	out := withWarnings(env, false)
+	if err := evalSource(t, env, `(warn "careful")`); err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if got := out.String(); got != "WARNING: careful\n" {
+		t.Errorf("Expected warning, got %q", got)
+	}
+}
+
+func TestDeprecatedWarnsOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"keyword", "(defn ^:deprecated old [x] x)\n(old 1)\n(old 2)",
+			"WARNING: old is deprecated (main.lisp:2:1)\n"},
+		{"map with message", "(defn ^{:deprecated \"use new\"} old \"Doc.\" [x] x)\n(+ 1 (old 1))",
+			"WARNING: old is deprecated: use new (main.lisp:2:6)\n"},
+		{"attribute map", "(defn old {:deprecated \"use new\"} [x] x)\n(old 1)",
+			"WARNING: old is deprecated: use new (main.lisp:2:1)\n"},
+		{"as a value", "(defn ^:deprecated old [x] x)\n(list old)",
+			"WARNING: old is deprecated\n"},
+		{"macro", "(defmacro ^:deprecated old [x] x)\n(old 1)",
+			"WARNING: old is deprecated (main.lisp:2:1)\n"},
+		{"not deprecated", "(defn ^:private fine [x] x)\n(fine 1)", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := NewCoreEnvironment()
+			out := withWarnings(env, false)
+			if err := evalSource(t, env, tt.source); err != nil {
+				t.Fatalf("Eval error: %v", err)
+			}
+			if got := out.String(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestWarningsAsErrors(t *testing.T) {
+	env := NewCoreEnvironment()
+	out := withWarnings(env, true)
+
+	err := evalSource(t, env, "(defn ^:deprecated old [x] x)\n(old 1)")
+	if err == nil || !strings.Contains(err.Error(), "old is deprecated (main.lisp:2:1)") {
+		t.Errorf("Expected deprecation error, got %v", err)
+	}
+	if err := evalSource(t, env, `(warn "careful")`); err == nil {
+		t.Error("Expected warn to fail")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing printed, got %q", out.String())
+	}
+}
+
+func TestShadowingWarnings(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{"def", "(def first 1)", "WARNING: first shadows the builtin first (main.lisp:1:1)\n"},
+		{"defn", "(defn count [x] x)", "WARNING: count shadows the builtin count (main.lisp:1:1)\n"},
+		{"let special form", "\n(let [if 1] if)", "WARNING: if shadows the special form if (main.lisp:2:1)\n"},
+		{"fn param", "((fn [str] str) 1)", "WARNING: str shadows the builtin str (main.lisp:1:2)\n"},
+		{"defn param", "(defn f [x & list] x)", "WARNING: list shadows the builtin list (main.lisp:1:1)\n"},
+		{"loop", "(loop [rest 1] rest)", "WARNING: rest shadows the builtin rest (main.lisp:1:1)\n"},
+		{"once per form", "(defn f [] (let [first 1] first)) (f) (f)", "WARNING: first shadows the builtin first (main.lisp:1:12)\n"},
+		{"local rebinding", "(let [x 1] (let [x 2] x))", ""},
+		{"suppressed def", "(def ^:no-shadow-warning first 1)", ""},
+		{"suppressed defn", "(defn ^:no-shadow-warning count [x] x)", ""},
+		{"suppressed binding", "(let [^:no-shadow-warning list 1 str 2] list)", "WARNING: str shadows the builtin str (main.lisp:1:1)\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			env := NewCoreEnvironment()
+			out := withWarnings(env, false)
+			if err := evalSource(t, env, test.source); err != nil {
+				t.Fatalf("Eval error: %v", err)
+			}
+			if got := out.String(); got != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+
+	env := NewCoreEnvironment()
+	withWarnings(env, true)
+	err := evalSource(t, env, "(def first 1)")
+	if err == nil || !strings.Contains(err.Error(), "warning treated as error: first shadows the builtin first") {
+		t.Errorf("Expected the warning to fail under -werror, got: %v", err)
+	}
+}
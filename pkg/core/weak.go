@@ -0,0 +1,215 @@
+package core
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+	"weak"
+)
+
+// WeakRef refers to a value without keeping it alive, made with weak-ref.
+// Once nothing else holds the value and it has been collected, deref-weak
+// gives nil.
+type WeakRef struct {
+	get func() Value // The value, or nil once collected
+}
+
+func (r *WeakRef) String() string {
+	if r.get() == nil {
+		return "#<weak-ref cleared>"
+	}
+	return "#<weak-ref live>"
+}
+
+// Deref returns the value, or Nil once it has been collected
+func (r *WeakRef) Deref() Value {
+	if v := r.get(); v != nil {
+		return v
+	}
+	return Nil{}
+}
+
+// weakPointer returns a function giving p while it is alive
+func weakPointer[T any, P interface {
+	*T
+	Value
+}](p P) func() Value {
+	w := weak.Make((*T)(p))
+	return func() Value {
+		if v := w.Value(); v != nil {
+			return P(v)
+		}
+		return nil
+	}
+}
+
+// NewWeakRef makes a weak reference to a collection, function, atom or
+// resource; numbers, strings and keywords are copied, not referenced, and
+// can't be referred to weakly
+func NewWeakRef(v Value) (*WeakRef, error) {
+	var get func() Value
+	switch v := v.(type) {
+	case *List:
+		get = weakPointer(v)
+	case *Vector:
+		get = weakPointer(v)
+	case *HashMap:
+		get = weakPointer(v)
+	case *Set:
+		get = weakPointer(v)
+	case *LazySeq:
+		get = weakPointer(v)
+	case *UserFunction:
+		get = weakPointer(v)
+	case *Atom:
+		get = weakPointer(v)
+	case *Ref:
+		get = weakPointer(v)
+	case *Delay:
+		get = weakPointer(v)
+	case *StringBuilder:
+		get = weakPointer(v)
+	case *MQConnection:
+		get = weakPointer(v)
+	default:
+		return nil, NewTypeError("weak-ref expects a collection, function, reference or resource, got %s", TypeName(v))
+	}
+	return &WeakRef{get: get}, nil
+}
+
+// finalizers are the functions to run when a value is collected, by the
+// address of the value. Keying by address doesn't keep the value alive, and
+// the entry is gone before the address can be reused.
+var finalizers = struct {
+	sync.Mutex
+	fns map[uintptr][]func(Value)
+}{fns: make(map[uintptr][]func(Value))}
+
+// AddFinalizer arranges for fn to be called with v some time after nothing
+// refers to v any more. A value may have several; the most recently added
+// runs first. They run one at a time on a goroutine of their own, so they
+// should be quick. v must be a pointer type, like a collection or resource.
+func AddFinalizer(v Value, fn func(Value)) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return NewTypeError("add-finalizer! expects a collection, function, reference or resource, got %s", TypeName(v))
+	}
+	addr := rv.Pointer()
+
+	finalizers.Lock()
+	defer finalizers.Unlock()
+	if _, set := finalizers.fns[addr]; !set {
+		runtime.SetFinalizer(v, runFinalizers)
+	}
+	finalizers.fns[addr] = append(finalizers.fns[addr], fn)
+	return nil
+}
+
+// runFinalizers calls the finalizers of a collected value
+func runFinalizers(v Value) {
+	addr := reflect.ValueOf(v).Pointer()
+	finalizers.Lock()
+	fns := finalizers.fns[addr]
+	delete(finalizers.fns, addr)
+	finalizers.Unlock()
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		fns[i](v)
+	}
+}
+
+// resource is a value holding something outside the interpreter, like a
+// network connection, that must be closed
+type resource interface {
+	Value
+	// closer returns a function that closes what the resource holds,
+	// reporting whether it was still open. It must not refer to the
+	// resource itself.
+	closer() func() bool
+}
+
+// closeWhenForgotten makes r close what it holds, with a warning from env's
+// interpreter, if it is collected while still open. It uses a cleanup rather than a finalizer so
+// that r is collected even when it is part of a cycle, like a connection
+// whose subscription handlers refer to it.
+func closeWhenForgotten[T any, P interface {
+	*T
+	resource
+}](r P, env *Environment) {
+	description := r.String()
+	// Only the interpreter's reporter is kept, since env may refer to r
+	reports := env.interpreterState().reports
+	runtime.AddCleanup((*T)(r), func(close func() bool) {
+		if close() {
+			reports.warn(fmt.Sprintf("%s was never closed; closing it now", description), Position{}, false)
+		}
+	}, r.closer())
+}
+
+// formSet is a set of forms that doesn't keep them alive: a form is dropped
+// once it has been collected, so forms made by eval don't pile up
+type formSet struct {
+	forms sync.Map // weak.Pointer[List] -> struct{}
+}
+
+func (s *formSet) contains(form *List) bool {
+	_, ok := s.forms.Load(weak.Make(form))
+	return ok
+}
+
+func (s *formSet) add(form *List) {
+	key := weak.Make(form)
+	if _, loaded := s.forms.LoadOrStore(key, struct{}{}); !loaded {
+		runtime.AddCleanup(form, func(key weak.Pointer[List]) { s.forms.Delete(key) }, key)
+	}
+}
+
+// setupWeakOperations adds weak-ref, deref-weak and add-finalizer!
+func setupWeakOperations(env *Environment) {
+	env.Set(Intern("weak-ref"), &BuiltinFunction{
+		Name: "weak-ref",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("weak-ref expects 1 argument, got %d", len(args))
+			}
+			return NewWeakRef(args[0])
+		},
+	})
+
+	env.Set(Intern("deref-weak"), &BuiltinFunction{
+		Name: "deref-weak",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("deref-weak expects 1 argument, got %d", len(args))
+			}
+			ref, ok := args[0].(*WeakRef)
+			if !ok {
+				return nil, NewTypeError("deref-weak expects a weak-ref, got %s", TypeName(args[0]))
+			}
+			return ref.Deref(), nil
+		},
+	})
+
+	env.Set(Intern("add-finalizer!"), &BuiltinFunction{
+		Name: "add-finalizer!",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 2 {
+				return nil, NewArityError("add-finalizer! expects 2 arguments, got %d", len(args))
+			}
+			fn := args[1]
+			if _, ok := fn.(Callable); !ok {
+				return nil, NewTypeError("add-finalizer! expects a function, got %s", TypeName(fn))
+			}
+			err := AddFinalizer(args[0], func(v Value) {
+				if _, err := callFunction(fn, []Value{v}, env); err != nil {
+					fmt.Fprintf(env.errorOutput(), "Error in finalizer: %v\n", err)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+			return args[0], nil
+		},
+	})
+}
@@ -0,0 +1,133 @@
+package core
+
+import (
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// collectUntil runs the garbage collector until done reports true, giving
+// finalizers time to run, or fails after a few seconds
+func collectUntil(t *testing.T, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		runtime.GC()
+		if done() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("Timed out waiting for the garbage collector")
+}
+
+func TestWeakRef(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def kept (vector 1 2 3))
+		(def kept-ref (weak-ref kept))`)
+
+	// Only the weak reference holds the vector passed in from Go
+	env.Set(Intern("temp"), NewVector(String("temporary")))
+	evalAll(t, env, `(def temp-ref (weak-ref temp))`)
+	if got := evalAll(t, env, `(deref-weak temp-ref)`).String(); got != `["temporary"]` {
+		t.Errorf("Expected the value while it is held, got %s", got)
+	}
+	env.Set(Intern("temp"), Nil{})
+
+	collectUntil(t, func() bool {
+		return evalAll(t, env, `(nil? (deref-weak temp-ref))`) == Symbol("true")
+	})
+	if got := evalAll(t, env, `temp-ref`).String(); got != "#<weak-ref cleared>" {
+		t.Errorf("Expected a cleared weak-ref, got %s", got)
+	}
+	if got := evalAll(t, env, `(deref-weak kept-ref)`).String(); got != "[1 2 3]" {
+		t.Errorf("Expected a value still held to stay reachable, got %s", got)
+	}
+
+	failures := []struct {
+		input   string
+		message string
+	}{
+		{`(weak-ref 42)`, "weak-ref expects a collection, function, reference or resource, got integer"},
+		{`(deref-weak kept)`, "deref-weak expects a weak-ref, got vector"},
+		{`(add-finalizer! "s" (fn [x] x))`, "add-finalizer! expects a collection"},
+		{`(add-finalizer! kept 42)`, "add-finalizer! expects a function"},
+	}
+	for _, test := range failures {
+		if err := evalSource(t, env, test.input); err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected an error containing %q, got %v", test.input, test.message, err)
+		}
+	}
+}
+
+func TestAddFinalizer(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def finalized (atom []))`)
+
+	env.Set(Intern("temp"), NewVector(String("handle")))
+	evalAll(t, env, `(add-finalizer! temp (fn [v] (swap! finalized conj (list :first v))))
+		(add-finalizer! temp (fn [v] (swap! finalized conj :second)))`)
+	env.Set(Intern("temp"), Nil{})
+
+	collectUntil(t, func() bool {
+		return evalAll(t, env, `(count @finalized)`).String() == "2"
+	})
+	// Most recently added first, each given the value
+	if got := evalAll(t, env, `@finalized`).String(); got != `[:second (:first ["handle"])]` {
+		t.Errorf("Expected both finalizers to run, latest first, got %s", got)
+	}
+}
+
+// formCount counts the forms in s
+func formCount(s *formSet) int {
+	n := 0
+	s.forms.Range(func(key, value any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+func TestShadowWarnedFormsAreCollected(t *testing.T) {
+	env := NewCoreEnvironment()
+	env.SetErrorOutput(io.Discard)
+	warned := &env.interpreterState().shadowWarned
+	// Each eval reads a new let form that hides count
+	evalAll(t, env, `(dotimes [i 50] (eval (read-string (str "(let [count " i "] count)"))))`)
+	if got := formCount(warned); got < 50 {
+		t.Fatalf("Expected each evaluated form to be remembered, got %d", got)
+	}
+	collectUntil(t, func() bool { return formCount(warned) == 0 })
+}
+
+func TestStrictCheckedFormsAreCollected(t *testing.T) {
+	env := NewCoreEnvironment()
+	env.SetStrict(true)
+	checked := &env.interpreterState().strictChecked
+	// Each eval reads a new fn form whose body is checked
+	evalAll(t, env, `(dotimes [i 50] (eval (read-string (str "(fn [x] (+ x " i "))"))))`)
+	if got := formCount(checked); got < 50 {
+		t.Fatalf("Expected each evaluated form to be remembered, got %d", got)
+	}
+	collectUntil(t, func() bool { return formCount(checked) == 0 })
+}
+
+func TestCaseTablesAreCollected(t *testing.T) {
+	env := NewCoreEnvironment()
+	tables := func() int {
+		n := 0
+		caseTables.Range(func(key, value any) bool {
+			n++
+			return true
+		})
+		return n
+	}
+	before := tables()
+	// Each eval reads a new case form whose table is cached
+	evalAll(t, env, `(dotimes [i 50] (eval (read-string (str "(case " i " 0 :zero :other)"))))`)
+	if got := tables() - before; got < 50 {
+		t.Fatalf("Expected a table per evaluated form, got %d", got)
+	}
+	collectUntil(t, func() bool { return tables() <= before })
+}
@@ -0,0 +1,491 @@
+package core
+
+import (
+	"fmt"
+)
+
+// Zipper is a location in a tree: the node there plus the path back to the
+// root, so that moving around and editing are cheap and leave the original
+// tree alone. zip/root rebuilds the tree with any edits. What counts as a
+// branch, how to get its children and how to rebuild it with new children
+// are given by zipOps, so the same functions work on nested vectors, lists,
+// maps describing XML elements or forms from read-string.
+type Zipper struct {
+	node    Value
+	lefts   []Value // Siblings to the left, nearest last
+	rights  []Value // Siblings to the right, nearest first
+	parent  *Zipper // Location of the parent node, nil at the root
+	changed bool    // Whether node or its siblings were edited
+	end     bool    // Set by zip/next after the last node
+	ops     *zipOps
+}
+
+// zipOps describes a kind of tree
+type zipOps struct {
+	branch   func(node Value) (bool, error)
+	children func(node Value) ([]Value, error)
+	makeNode func(node Value, children []Value) (Value, error)
+}
+
+func (z *Zipper) String() string {
+	if z.end {
+		return fmt.Sprintf("#<zipper end %s>", printString(z.node))
+	}
+	return fmt.Sprintf("#<zipper %s>", printString(z.node))
+}
+
+// newZipper returns a zipper at the root of the tree
+func newZipper(root Value, ops *zipOps) *Zipper {
+	return &Zipper{node: root, ops: ops}
+}
+
+var vectorZipOps = &zipOps{
+	branch: func(node Value) (bool, error) {
+		_, ok := node.(*Vector)
+		return ok, nil
+	},
+	children: collectionToSlice,
+	makeNode: func(node Value, children []Value) (Value, error) {
+		return NewVector(children...), nil
+	},
+}
+
+var seqZipOps = &zipOps{
+	branch: func(node Value) (bool, error) {
+		_, ok := node.(*List)
+		return ok, nil
+	},
+	children: collectionToSlice,
+	makeNode: func(node Value, children []Value) (Value, error) {
+		return NewList(children...), nil
+	},
+}
+
+// userZipOps calls Lisp functions given to zip/zipper
+func userZipOps(branch, children, makeNode Value, env *Environment) *zipOps {
+	return &zipOps{
+		branch: func(node Value) (bool, error) {
+			result, err := callFunction(branch, []Value{node}, env)
+			if err != nil {
+				return false, err
+			}
+			return isTruthy(result), nil
+		},
+		children: func(node Value) ([]Value, error) {
+			result, err := callFunction(children, []Value{node}, env)
+			if err != nil {
+				return nil, err
+			}
+			if _, isNil := result.(Nil); isNil {
+				return nil, nil
+			}
+			items, err := collectionToSlice(result)
+			if err != nil {
+				return nil, NewTypeError("zipper children function must return a collection, got %s", result)
+			}
+			return items, nil
+		},
+		makeNode: func(node Value, items []Value) (Value, error) {
+			return callFunction(makeNode, []Value{node, NewVector(items...)}, env)
+		},
+	}
+}
+
+// with returns a copy of z with a different node, marked as changed
+func (z *Zipper) with(node Value) *Zipper {
+	loc := *z
+	loc.node, loc.changed = node, true
+	return &loc
+}
+
+// joined returns the items of the given slices as one new slice
+func joined(parts ...[]Value) []Value {
+	var out []Value
+	for _, part := range parts {
+		out = append(out, part...)
+	}
+	return out
+}
+
+func (z *Zipper) isBranch() (bool, error) {
+	return z.ops.branch(z.node)
+}
+
+func (z *Zipper) children() ([]Value, error) {
+	branch, err := z.isBranch()
+	if err != nil {
+		return nil, err
+	}
+	if !branch {
+		return nil, NewRuntimeError("zipper node %s is not a branch", printString(z.node))
+	}
+	return z.ops.children(z.node)
+}
+
+// down moves to the leftmost child, or returns nil for a leaf or a branch
+// without children
+func (z *Zipper) down() (*Zipper, error) {
+	if branch, err := z.isBranch(); err != nil || !branch {
+		return nil, err
+	}
+	children, err := z.ops.children(z.node)
+	if err != nil || len(children) == 0 {
+		return nil, err
+	}
+	return &Zipper{node: children[0], rights: children[1:], parent: z, ops: z.ops}, nil
+}
+
+// up moves to the parent, rebuilding it if anything below was edited
+func (z *Zipper) up() (*Zipper, error) {
+	if z.parent == nil {
+		return nil, nil
+	}
+	if !z.changed {
+		return z.parent, nil
+	}
+	node, err := z.ops.makeNode(z.parent.node, joined(z.lefts, []Value{z.node}, z.rights))
+	if err != nil {
+		return nil, err
+	}
+	return z.parent.with(node), nil
+}
+
+func (z *Zipper) right() *Zipper {
+	if z.parent == nil || len(z.rights) == 0 {
+		return nil
+	}
+	loc := *z
+	loc.node, loc.lefts, loc.rights = z.rights[0], joined(z.lefts, []Value{z.node}), z.rights[1:]
+	return &loc
+}
+
+func (z *Zipper) left() *Zipper {
+	if z.parent == nil || len(z.lefts) == 0 {
+		return nil
+	}
+	loc := *z
+	last := len(z.lefts) - 1
+	loc.node, loc.lefts, loc.rights = z.lefts[last], z.lefts[:last:last], joined([]Value{z.node}, z.rights)
+	return &loc
+}
+
+func (z *Zipper) leftmost() *Zipper {
+	if z.parent == nil || len(z.lefts) == 0 {
+		return z
+	}
+	loc := *z
+	loc.node, loc.lefts, loc.rights = z.lefts[0], nil, joined(z.lefts[1:], []Value{z.node}, z.rights)
+	return &loc
+}
+
+func (z *Zipper) rightmost() *Zipper {
+	if z.parent == nil || len(z.rights) == 0 {
+		return z
+	}
+	loc := *z
+	last := len(z.rights) - 1
+	loc.node, loc.lefts, loc.rights = z.rights[last], joined(z.lefts, []Value{z.node}, z.rights[:last]), nil
+	return &loc
+}
+
+// root zips all the way up and returns the tree with all edits
+func (z *Zipper) root() (Value, error) {
+	for {
+		parent, err := z.up()
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return z.node, nil
+		}
+		z = parent
+	}
+}
+
+// next moves to the next location in a depth-first walk; after the last
+// one it returns a location for which zip/end? is true
+func (z *Zipper) next() (*Zipper, error) {
+	if z.end {
+		return z, nil
+	}
+	if child, err := z.down(); err != nil || child != nil {
+		return child, err
+	}
+	for loc := z; ; {
+		if right := loc.right(); right != nil {
+			return right, nil
+		}
+		parent, err := loc.up()
+		if err != nil {
+			return nil, err
+		}
+		if parent == nil {
+			return &Zipper{node: loc.node, end: true, ops: z.ops}, nil
+		}
+		loc = parent
+	}
+}
+
+// prev moves to the previous location in a depth-first walk, or returns nil
+// at the root
+func (z *Zipper) prev() (*Zipper, error) {
+	loc := z.left()
+	if loc == nil {
+		return z.up()
+	}
+	for {
+		child, err := loc.down()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return loc, nil
+		}
+		loc = child.rightmost()
+	}
+}
+
+// remove deletes the node, returning the location before it in a
+// depth-first walk
+func (z *Zipper) remove() (*Zipper, error) {
+	if z.parent == nil {
+		return nil, NewRuntimeError("zip/remove cannot remove the root")
+	}
+	if len(z.lefts) == 0 {
+		node, err := z.ops.makeNode(z.parent.node, z.rights)
+		if err != nil {
+			return nil, err
+		}
+		return z.parent.with(node), nil
+	}
+	last := len(z.lefts) - 1
+	loc := &Zipper{node: z.lefts[last], lefts: z.lefts[:last:last], rights: z.rights, parent: z.parent, changed: true, ops: z.ops}
+	for {
+		child, err := loc.down()
+		if err != nil {
+			return nil, err
+		}
+		if child == nil {
+			return loc, nil
+		}
+		loc = child.rightmost()
+	}
+}
+
+func (z *Zipper) insertLeft(item Value) (*Zipper, error) {
+	if z.parent == nil {
+		return nil, NewRuntimeError("zip/insert-left cannot insert at the root")
+	}
+	loc := *z
+	loc.lefts, loc.changed = joined(z.lefts, []Value{item}), true
+	return &loc, nil
+}
+
+func (z *Zipper) insertRight(item Value) (*Zipper, error) {
+	if z.parent == nil {
+		return nil, NewRuntimeError("zip/insert-right cannot insert at the root")
+	}
+	loc := *z
+	loc.rights, loc.changed = joined([]Value{item}, z.rights), true
+	return &loc, nil
+}
+
+// withChildren rebuilds the node with new children
+func (z *Zipper) withChildren(update func([]Value) []Value) (*Zipper, error) {
+	children, err := z.children()
+	if err != nil {
+		return nil, err
+	}
+	node, err := z.ops.makeNode(z.node, update(children))
+	if err != nil {
+		return nil, err
+	}
+	return z.with(node), nil
+}
+
+// path returns the nodes from the root down to the parent of z
+func (z *Zipper) path() []Value {
+	var nodes []Value
+	for p := z.parent; p != nil; p = p.parent {
+		nodes = append([]Value{p.node}, nodes...)
+	}
+	return nodes
+}
+
+func zipperArg(name string, value Value) (*Zipper, error) {
+	z, ok := value.(*Zipper)
+	if !ok {
+		return nil, NewTypeError("%s expects a zipper, got %s", name, TypeName(value))
+	}
+	return z, nil
+}
+
+// locationOrNil turns a missing location into nil
+func locationOrNil(z *Zipper, err error) (Value, error) {
+	if err != nil {
+		return nil, err
+	}
+	if z == nil {
+		return Nil{}, nil
+	}
+	return z, nil
+}
+
+// setupZipperOperations adds the zip/* functions
+func setupZipperOperations(env *Environment) {
+	env.Set(Intern("zip/vector-zip"), &BuiltinFunction{
+		Name: "zip/vector-zip",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("zip/vector-zip expects 1 argument, got %d", len(args))
+			}
+			return newZipper(args[0], vectorZipOps), nil
+		},
+	})
+
+	env.Set(Intern("zip/seq-zip"), &BuiltinFunction{
+		Name: "zip/seq-zip",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 1 {
+				return nil, NewArityError("zip/seq-zip expects 1 argument, got %d", len(args))
+			}
+			return newZipper(args[0], seqZipOps), nil
+		},
+	})
+
+	// (zip/zipper branch? children make-node root), where make-node gets a
+	// node and a vector of new children
+	env.Set(Intern("zip/zipper"), &BuiltinFunction{
+		Name: "zip/zipper",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) != 4 {
+				return nil, NewArityError("zip/zipper expects 4 arguments, got %d", len(args))
+			}
+			for _, fn := range args[:3] {
+				if _, ok := fn.(Callable); !ok {
+					return nil, NewTypeError("zip/zipper expects branch?, children and make-node functions, got %s", TypeName(fn))
+				}
+			}
+			return newZipper(args[3], userZipOps(args[0], args[1], args[2], env)), nil
+		},
+	})
+
+	moves := map[string]func(z *Zipper) (*Zipper, error){
+		"zip/down":      (*Zipper).down,
+		"zip/up":        (*Zipper).up,
+		"zip/next":      (*Zipper).next,
+		"zip/prev":      (*Zipper).prev,
+		"zip/remove":    (*Zipper).remove,
+		"zip/right":     func(z *Zipper) (*Zipper, error) { return z.right(), nil },
+		"zip/left":      func(z *Zipper) (*Zipper, error) { return z.left(), nil },
+		"zip/leftmost":  func(z *Zipper) (*Zipper, error) { return z.leftmost(), nil },
+		"zip/rightmost": func(z *Zipper) (*Zipper, error) { return z.rightmost(), nil },
+	}
+	for name, move := range moves {
+		name, move := name, move
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				z, err := zipperArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				return locationOrNil(move(z))
+			},
+		})
+	}
+
+	queries := map[string]func(z *Zipper) (Value, error){
+		"zip/node": func(z *Zipper) (Value, error) { return z.node, nil },
+		"zip/root": (*Zipper).root,
+		"zip/branch?": func(z *Zipper) (Value, error) {
+			branch, err := z.isBranch()
+			if err != nil || !branch {
+				return Nil{}, err
+			}
+			return Symbol("true"), nil
+		},
+		"zip/end?": func(z *Zipper) (Value, error) {
+			if z.end {
+				return Symbol("true"), nil
+			}
+			return Nil{}, nil
+		},
+		"zip/children": func(z *Zipper) (Value, error) {
+			children, err := z.children()
+			if err != nil {
+				return nil, err
+			}
+			return NewList(children...), nil
+		},
+		"zip/lefts":  func(z *Zipper) (Value, error) { return NewList(z.lefts...), nil },
+		"zip/rights": func(z *Zipper) (Value, error) { return NewList(z.rights...), nil },
+		"zip/path":   func(z *Zipper) (Value, error) { return NewVector(z.path()...), nil },
+	}
+	for name, query := range queries {
+		name, query := name, query
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 1 {
+					return nil, NewArityError("%s expects 1 argument, got %d", name, len(args))
+				}
+				z, err := zipperArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				return query(z)
+			},
+		})
+	}
+
+	edits := map[string]func(z *Zipper, item Value) (*Zipper, error){
+		"zip/replace":      func(z *Zipper, item Value) (*Zipper, error) { return z.with(item), nil },
+		"zip/insert-left":  (*Zipper).insertLeft,
+		"zip/insert-right": (*Zipper).insertRight,
+		"zip/insert-child": func(z *Zipper, item Value) (*Zipper, error) {
+			return z.withChildren(func(children []Value) []Value { return joined([]Value{item}, children) })
+		},
+		"zip/append-child": func(z *Zipper, item Value) (*Zipper, error) {
+			return z.withChildren(func(children []Value) []Value { return joined(children, []Value{item}) })
+		},
+	}
+	for name, edit := range edits {
+		name, edit := name, edit
+		env.Set(Intern(name), &BuiltinFunction{
+			Name: name,
+			Fn: func(args []Value, env *Environment) (Value, error) {
+				if len(args) != 2 {
+					return nil, NewArityError("%s expects 2 arguments, got %d", name, len(args))
+				}
+				z, err := zipperArg(name, args[0])
+				if err != nil {
+					return nil, err
+				}
+				return edit(z, args[1])
+			},
+		})
+	}
+
+	// (zip/edit loc f & args) replaces the node with (f node args...)
+	env.Set(Intern("zip/edit"), &BuiltinFunction{
+		Name: "zip/edit",
+		Fn: func(args []Value, env *Environment) (Value, error) {
+			if len(args) < 2 {
+				return nil, NewArityError("zip/edit expects at least 2 arguments, got %d", len(args))
+			}
+			z, err := zipperArg("zip/edit", args[0])
+			if err != nil {
+				return nil, err
+			}
+			node, err := callFunction(args[1], joined([]Value{z.node}, args[2:]), env)
+			if err != nil {
+				return nil, err
+			}
+			return z.with(node), nil
+		},
+	})
+}
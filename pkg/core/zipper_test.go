@@ -0,0 +1,89 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVectorZipper(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `(def z (zip/vector-zip [1 [2 3] 4]))`)
+	evalAll(t, env, `(def inner (zip/down (zip/right (zip/down z))))`) // At 2
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`z`, `#<zipper [1 [2 3] 4]>`},
+		{`(zip/node (zip/down z))`, `1`},
+		{`(zip/node inner)`, `2`},
+		{`(zip/up z)`, `nil`},
+		{`(zip/left (zip/down z))`, `nil`},
+		{`(zip/down (zip/down z))`, `nil`},
+		{`(zip/branch? z)`, `true`},
+		{`(zip/children z)`, `(1 [2 3] 4)`},
+		{`(zip/path inner)`, `[[1 [2 3] 4] [2 3]]`},
+		{`(zip/rights (zip/down z))`, `([2 3] 4)`},
+		{`(zip/lefts (zip/rightmost (zip/down z)))`, `(1 [2 3])`},
+		{`(zip/node (zip/leftmost (zip/rightmost (zip/down z))))`, `1`},
+		{`(zip/root (zip/edit (zip/right inner) * 10))`, `[1 [2 30] 4]`},
+		{`(zip/root (zip/replace inner :two))`, `[1 [:two 3] 4]`},
+		{`(zip/root (zip/insert-left inner 0))`, `[1 [0 2 3] 4]`},
+		{`(zip/root (zip/insert-right inner 0))`, `[1 [2 0 3] 4]`},
+		{`(zip/root (zip/insert-child z 0))`, `[0 1 [2 3] 4]`},
+		{`(zip/root (zip/append-child (zip/up inner) 5))`, `[1 [2 3 5] 4]`},
+		{`(zip/root (zip/remove inner))`, `[1 [3] 4]`},
+		{`(zip/node (zip/remove (zip/right inner)))`, `2`},
+		{`(zip/node (zip/remove (zip/rightmost (zip/down z))))`, `3`},
+		{`(zip/node (zip/prev (zip/rightmost (zip/down z))))`, `3`},
+		{`(zip/node (zip/prev inner))`, `[2 3]`},
+		// Edits leave the original tree alone
+		{`(do (zip/root (zip/replace inner 0)) (zip/root inner))`, `[1 [2 3] 4]`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+}
+
+func TestZipperWalkAndCustomTrees(t *testing.T) {
+	env := NewCoreEnvironment()
+	evalAll(t, env, `
+		(defn inc-numbers [loc]
+		  (if (zip/end? loc)
+		    (zip/root loc)
+		    (inc-numbers (zip/next (if (number? (zip/node loc))
+		                             (zip/edit loc (fn [n] (+ n 1)))
+		                             loc)))))`)
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(inc-numbers (zip/vector-zip [1 [2 [3]] [] 4]))`, `[2 [3 [4]] [] 5]`},
+		{`(zip/end? (zip/next (zip/vector-zip 1)))`, `true`},
+		{`(zip/root (zip/replace (zip/down (zip/right (zip/right (zip/down (zip/seq-zip (read-string "(+ 1 (* 2 3))")))))) '-))`, `(+ 1 (- 2 3))`},
+		{`(zip/root (zip/edit (zip/down (zip/right (zip/down
+		   (zip/zipper hash-map? :children (fn [node children] (assoc node :children children))
+		     (hash-map :tag :ul :children (vector (hash-map :tag :li) (hash-map :tag :li :children (vector (hash-map :tag :b)))))))))
+		   assoc :tag :i))`, `{:tag :ul :children [{:tag :li} {:tag :li :children [{:tag :i}]}]}`},
+	}
+	for _, test := range tests {
+		if result := evalAll(t, env, test.input); result.String() != test.expected {
+			t.Errorf("For '%s', expected %s, got %s", test.input, test.expected, result)
+		}
+	}
+
+	for input, message := range map[string]string{
+		`(zip/down [1 2])`:                                     "expects a zipper",
+		`(zip/remove (zip/vector-zip [1]))`:                    "cannot remove the root",
+		`(zip/append-child (zip/down (zip/vector-zip [1])) 2)`: "is not a branch",
+		`(zip/zipper 1 2 3 4)`:                                 "expects branch?, children and make-node functions",
+	} {
+		expr, _ := ReadString(input)
+		if _, err := Eval(expr, env); err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected an error containing '%s' for %s, got: %v", message, input, err)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package format
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Edit replaces the bytes of the source from Start to End with Text. The
+// structural operations return edits rather than new source, so an editor
+// can apply them to its buffer and keep the cursor and undo history.
+type Edit struct {
+	Start int
+	End   int
+	Text  string
+}
+
+// Apply returns source with edits made. Edits must not overlap.
+func Apply(source string, edits []Edit) string {
+	sorted := append([]Edit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	var out strings.Builder
+	last := 0
+	for _, edit := range sorted {
+		out.WriteString(source[last:edit.Start])
+		out.WriteString(edit.Text)
+		last = edit.End
+	}
+	out.WriteString(source[last:])
+	return out.String()
+}
+
+// SlurpForward moves the closing delimiter of the collection around offset
+// past the form that follows it: (a |b) c becomes (a b c)
+func SlurpForward(source string, offset int) ([]Edit, error) {
+	coll, err := collectionNode(source, offset, "slurp")
+	if err != nil {
+		return nil, err
+	}
+	next := sibling(coll, 1)
+	if next == nil {
+		return nil, fmt.Errorf("slurp: no form after the %s to slurp", describe(source, coll))
+	}
+	_, closer := coll.Delimiters(source)
+	return []Edit{
+		{Start: coll.close, End: coll.End},
+		{Start: next.End, End: next.End, Text: closer},
+	}, nil
+}
+
+// SlurpBackward moves the opening delimiter of the collection around offset
+// before the form that precedes it: a (b |c) becomes (a b c)
+func SlurpBackward(source string, offset int) ([]Edit, error) {
+	coll, err := collectionNode(source, offset, "slurp")
+	if err != nil {
+		return nil, err
+	}
+	previous := sibling(coll, -1)
+	if previous == nil {
+		return nil, fmt.Errorf("slurp: no form before the %s to slurp", describe(source, coll))
+	}
+	return []Edit{
+		{Start: previous.Start, End: previous.Start, Text: source[coll.Start:coll.open]},
+		{Start: coll.Start, End: coll.open},
+	}, nil
+}
+
+// BarfForward moves the last form of the collection around offset out past
+// its closing delimiter: (a |b c) becomes (a b) c
+func BarfForward(source string, offset int) ([]Edit, error) {
+	coll, err := collectionNode(source, offset, "barf")
+	if err != nil {
+		return nil, err
+	}
+	if len(coll.Children) == 0 {
+		return nil, fmt.Errorf("barf: the %s is empty", describe(source, coll))
+	}
+	// The delimiter closes after the form before the last, or right after
+	// the opening delimiter
+	at := coll.open
+	if n := len(coll.Children); n > 1 {
+		at = coll.Children[n-2].End
+	}
+	_, closer := coll.Delimiters(source)
+	return []Edit{
+		{Start: at, End: at, Text: closer},
+		{Start: coll.close, End: coll.End},
+	}, nil
+}
+
+// BarfBackward moves the first form of the collection around offset out
+// before its opening delimiter: (a b |c) becomes a (b c)
+func BarfBackward(source string, offset int) ([]Edit, error) {
+	coll, err := collectionNode(source, offset, "barf")
+	if err != nil {
+		return nil, err
+	}
+	if len(coll.Children) == 0 {
+		return nil, fmt.Errorf("barf: the %s is empty", describe(source, coll))
+	}
+	at := coll.close
+	if len(coll.Children) > 1 {
+		at = coll.Children[1].Start
+	}
+	return []Edit{
+		{Start: coll.Start, End: coll.open},
+		{Start: at, End: at, Text: source[coll.Start:coll.open]},
+	}, nil
+}
+
+// Raise replaces the collection around the form at offset with that form:
+// (a (b |c) d) becomes (a c d)
+func Raise(source string, offset int) ([]Edit, error) {
+	form, err := formNode(source, offset, "raise")
+	if err != nil {
+		return nil, err
+	}
+	parent := form.Parent
+	if parent.Parent == nil {
+		return nil, fmt.Errorf("raise: %s is already at the top level", source[form.Start:form.End])
+	}
+	return []Edit{{Start: parent.Start, End: parent.End, Text: source[form.Start:form.End]}}, nil
+}
+
+// Wrap puts the form at offset inside a new collection opened by open, one
+// of "(", "[", "{" or "#{": |a b becomes (a) b
+func Wrap(source string, offset int, open string) ([]Edit, error) {
+	closers := map[string]string{"(": ")", "[": "]", "{": "}", "#{": "}"}
+	closer, ok := closers[open]
+	if !ok {
+		return nil, fmt.Errorf("wrap: unknown delimiter %q", open)
+	}
+	form, err := formNode(source, offset, "wrap")
+	if err != nil {
+		return nil, err
+	}
+	return []Edit{
+		{Start: form.Start, End: form.Start, Text: open},
+		{Start: form.End, End: form.End, Text: closer},
+	}, nil
+}
+
+// Splice removes the delimiters of the collection around offset, leaving
+// its forms in the enclosing one: (a (b |c) d) becomes (a b c d)
+func Splice(source string, offset int) ([]Edit, error) {
+	coll, err := collectionNode(source, offset, "splice")
+	if err != nil {
+		return nil, err
+	}
+	return []Edit{
+		{Start: coll.Start, End: coll.open},
+		{Start: coll.close, End: coll.End},
+	}, nil
+}
+
+// collectionNode parses source and finds the collection around offset
+func collectionNode(source string, offset int, op string) (*Node, error) {
+	root, err := Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	coll := root.collectionAt(offset)
+	if coll == nil {
+		return nil, fmt.Errorf("%s: offset %d is not inside a list, vector, map or set", op, offset)
+	}
+	return coll, nil
+}
+
+// formNode parses source and finds the form at offset
+func formNode(source string, offset int, op string) (*Node, error) {
+	root, err := Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", op, err)
+	}
+	form := root.formAt(offset)
+	if form == nil {
+		return nil, fmt.Errorf("%s: no form at offset %d", op, offset)
+	}
+	return form, nil
+}
+
+// sibling returns the form after (step 1) or before (step -1) node in its
+// parent, or nil
+func sibling(node *Node, step int) *Node {
+	siblings := node.Parent.Children
+	for i, s := range siblings {
+		if s == node {
+			if j := i + step; j >= 0 && j < len(siblings) {
+				return siblings[j]
+			}
+			return nil
+		}
+	}
+	return nil
+}
+
+// describe names a collection for errors, by its delimiters
+func describe(source string, coll *Node) string {
+	opener, closer := coll.Delimiters(source)
+	return opener + "..." + closer
+}
@@ -0,0 +1,104 @@
+package format_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leinonen/go-lisp/pkg/format"
+)
+
+func TestStructuralEdits(t *testing.T) {
+	wrapParen := func(source string, offset int) ([]format.Edit, error) {
+		return format.Wrap(source, offset, "(")
+	}
+	wrapSet := func(source string, offset int) ([]format.Edit, error) {
+		return format.Wrap(source, offset, "#{")
+	}
+
+	tests := []struct {
+		name     string
+		op       func(string, int) ([]format.Edit, error)
+		input    string // | marks the cursor
+		expected string
+	}{
+		{"slurp forward", format.SlurpForward, "(a |b) c d", "(a b c) d"},
+		{"slurp forward over a comment", format.SlurpForward, "(f |x) ; why\n  [y z]", "(f x ; why\n  [y z])"},
+		{"slurp forward into a vector", format.SlurpForward, "(let [a| 1] b 2)", "(let [a 1 b] 2)"},
+		{"slurp backward", format.SlurpBackward, "a '(b |c)", "'(a b c)"},
+		{"barf forward", format.BarfForward, "(a |b c) d", "(a b) c d"},
+		{"barf forward of the only form", format.BarfForward, "(|a)", "()a"},
+		{"barf backward", format.BarfBackward, "(a b |c)", "a (b c)"},
+		{"raise", format.Raise, "(if x (do |(f) (g)) y)", "(if x (f) y)"},
+		{"raise a quoted form", format.Raise, "(a (b '|c) d)", "(a 'c d)"},
+		{"wrap", wrapParen, "(map |inc xs)", "(map (inc) xs)"},
+		{"wrap after a form", wrapSet, "(def s [1 2]|)", "(def s #{[1 2]})"},
+		{"splice", format.Splice, "(a (b |c) d)", "(a b c d)"},
+		{"splice a set", format.Splice, "[#{1 |2}]", "[1 2]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.input, "|")
+			source := strings.Replace(test.input, "|", "", 1)
+			edits, err := test.op(source, offset)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := format.Apply(source, edits); got != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestStructuralEditErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       func(string, int) ([]format.Edit, error)
+		input    string
+		expected string
+	}{
+		{"nothing to slurp", format.SlurpForward, "(a |b)", "no form after the (...) to slurp"},
+		{"nothing to barf", format.BarfForward, "[|]", "the [...] is empty"},
+		{"outside any collection", format.Splice, "a |b", "not inside a list"},
+		{"raise at the top level", format.Raise, "|(a b)", "already at the top level"},
+		{"unbalanced source", format.SlurpForward, "(a |b", "unclosed form"},
+		{"stray delimiter", format.Splice, "(a |b))", "unexpected )"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			offset := strings.Index(test.input, "|")
+			source := strings.Replace(test.input, "|", "", 1)
+			_, err := test.op(source, offset)
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	source := "(defn f [x] ; doc\n  #{x} #inst \"2024-01-01T00:00:00Z\" ^:private y)"
+	root, err := format.Parse(source)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(root.Children) != 1 {
+		t.Fatalf("Expected 1 top-level form, got %d", len(root.Children))
+	}
+
+	var got []string
+	for _, child := range root.Children[0].Children {
+		got = append(got, source[child.Start:child.End])
+	}
+	expected := []string{"defn", "f", "[x]", "#{x}", `#inst "2024-01-01T00:00:00Z"`, "^:private", "y"}
+	if strings.Join(got, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected forms %q, got %q", expected, got)
+	}
+
+	set := root.Children[0].Children[3]
+	if open, close := set.Delimiters(source); !set.IsCollection() || open != "#{" || close != "}" {
+		t.Errorf("Expected a #{ } collection, got %q %q", open, close)
+	}
+}
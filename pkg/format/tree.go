@@ -0,0 +1,129 @@
+// Package format works on GoLisp source as text, for formatters and editor
+// tooling. Forms are located by byte range, so edits keep everything around
+// them, comments and whitespace included, exactly as written.
+package format
+
+import (
+	"fmt"
+
+	"github.com/leinonen/go-lisp/pkg/core"
+)
+
+// Node is a form in the source. The root node spans the whole source and
+// holds the top-level forms.
+type Node struct {
+	Start    int // Offset of the form, including reader prefixes like ' or #inst
+	End      int // Offset just past the form
+	Children []*Node
+	Parent   *Node
+
+	openStart int           // Offset of the opening delimiter, for collections
+	open      int           // Offset just past the opening delimiter, 0 for atoms
+	close     int           // Offset of the closing delimiter
+	pos       core.Position // Where the opening delimiter is, for errors
+}
+
+// IsCollection reports whether the node is a list, vector, map or set
+func (n *Node) IsCollection() bool {
+	return n.open > 0
+}
+
+// Delimiters returns the opening and closing delimiters of a collection as
+// written in source, like "#{" and "}"
+func (n *Node) Delimiters(source string) (string, string) {
+	if !n.IsCollection() {
+		return "", ""
+	}
+	return source[n.openStart:n.open], source[n.close:n.End]
+}
+
+// Parse reads the forms of source into a tree. Comments are not nodes; edits
+// keep them because they lie between or inside the nodes.
+func Parse(source string) (*Node, error) {
+	tokens, err := core.NewLexer(source).Tokenize()
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{End: len(source)}
+	current := root
+	prefix := -1 // Start of the reader prefixes before the next form
+
+	open := func(start int, delimiter core.Token) {
+		node := &Node{Start: prefix, Parent: current, openStart: start, open: delimiter.End, pos: delimiter.Position}
+		current.Children = append(current.Children, node)
+		current, prefix = node, -1
+	}
+
+	for i := 0; i < len(tokens)-1; i++ {
+		token := tokens[i]
+		start := token.Position.Offset
+		if prefix < 0 {
+			prefix = start
+		}
+
+		switch token.Type {
+		case core.TokenQuote, core.TokenQuasiquote, core.TokenUnquote, core.TokenUnquoteSplicing,
+			core.TokenDeref, core.TokenCaret:
+		case core.TokenHash:
+			next := tokens[i+1]
+			switch {
+			case next.Type == core.TokenLeftBrace && next.Position.Offset == token.End:
+				// #{ opens a set
+				i++
+				open(start, next)
+			case next.Type == core.TokenSymbol:
+				// A tag like #inst belongs to the form after it
+				i++
+			}
+		case core.TokenLeftParen, core.TokenLeftBracket, core.TokenLeftBrace:
+			open(start, token)
+		case core.TokenRightParen, core.TokenRightBracket, core.TokenRightBrace:
+			if current == root || prefix != start {
+				return nil, fmt.Errorf("unexpected %s at %s", token.Value, token.Position)
+			}
+			current.close, current.End = start, token.End
+			current, prefix = current.Parent, -1
+		default:
+			current.Children = append(current.Children, &Node{Start: prefix, End: token.End, Parent: current})
+			prefix = -1
+		}
+	}
+
+	if current != root {
+		return nil, fmt.Errorf("unclosed form at %s", current.pos)
+	}
+	return root, nil
+}
+
+// collectionAt returns the innermost collection whose contents contain
+// offset, so that a cursor just inside its delimiters is in it
+func (n *Node) collectionAt(offset int) *Node {
+	for _, child := range n.Children {
+		if child.IsCollection() && offset >= child.open && offset <= child.close {
+			return child.collectionAt(offset)
+		}
+	}
+	if n.Parent == nil {
+		return nil
+	}
+	return n
+}
+
+// formAt returns the innermost form that offset is on: a form starting at
+// or containing offset, or else one ending right before it
+func (n *Node) formAt(offset int) *Node {
+	var before *Node
+	for _, child := range n.Children {
+		if offset >= child.Start && offset < child.End {
+			if inner := child.formAt(offset); inner != nil {
+				return inner
+			}
+			return child
+		}
+		if offset == child.End {
+			before = child
+		}
+	}
+	return before
+}